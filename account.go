@@ -0,0 +1,218 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type account struct {
+	controller *controller
+}
+
+// Account implements Controller.
+func (c *controller) Account() Account {
+	return &account{controller: c}
+}
+
+// CreateToken implements Account.
+func (a *account) CreateToken(name string) (AuthorisationToken, error) {
+	params := NewURLParams()
+	params.MaybeAdd("name", name)
+	source, err := a.controller.post("account", "create_authorisation_token", params.Values)
+	if err != nil {
+		return nil, translateAccountError(err)
+	}
+	token, err := readAuthorisationToken(a.controller.apiVersion, a.controller.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return token, nil
+}
+
+// DeleteToken implements Account.
+func (a *account) DeleteToken(tokenKey string) error {
+	if tokenKey == "" {
+		return errors.NotValidf("missing token key")
+	}
+	params := NewURLParams()
+	params.Values.Add("token_key", tokenKey)
+	if _, err := a.controller._postRaw("account", "delete_authorisation_token", params.Values, nil); err != nil {
+		return translateAccountError(err)
+	}
+	return nil
+}
+
+// Tokens implements Account.
+func (a *account) Tokens() ([]AuthorisationToken, error) {
+	source, err := a.controller.getOp("account", "list_authorisation_tokens")
+	if err != nil {
+		return nil, translateAccountError(err)
+	}
+	tokens, err := readAuthorisationTokens(a.controller.apiVersion, a.controller.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make([]AuthorisationToken, len(tokens))
+	for i, token := range tokens {
+		result[i] = token
+	}
+	return result, nil
+}
+
+func translateAccountError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+type authorisationToken struct {
+	name        string
+	consumerKey string
+	tokenKey    string
+	tokenSecret string
+}
+
+// Name implements AuthorisationToken.
+func (t *authorisationToken) Name() string {
+	return t.name
+}
+
+// ConsumerKey implements AuthorisationToken.
+func (t *authorisationToken) ConsumerKey() string {
+	return t.consumerKey
+}
+
+// TokenKey implements AuthorisationToken.
+func (t *authorisationToken) TokenKey() string {
+	return t.tokenKey
+}
+
+// TokenSecret implements AuthorisationToken.
+func (t *authorisationToken) TokenSecret() string {
+	return t.tokenSecret
+}
+
+// APIKey implements AuthorisationToken.
+func (t *authorisationToken) APIKey() string {
+	return strings.Join([]string{t.consumerKey, t.tokenKey, t.tokenSecret}, ":")
+}
+
+func readAuthorisationToken(controllerVersion version.Number, source interface{}) (*authorisationToken, error) {
+	readFunc, err := getAuthorisationTokenDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "authorisation token base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readAuthorisationTokens(controllerVersion version.Number, source interface{}) ([]*authorisationToken, error) {
+	readFunc, err := getAuthorisationTokenDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "authorisation token base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	result := make([]*authorisationToken, 0, len(valid))
+	for i, value := range valid {
+		token, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(err, "authorisation token %d", i)
+		}
+		result = append(result, token)
+	}
+	return result, nil
+}
+
+func getAuthorisationTokenDeserializationFunc(controllerVersion version.Number) (authorisationTokenDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range authorisationTokenDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no authorisation token read func for version %s", controllerVersion)
+	}
+	return authorisationTokenDeserializationFuncs[deserialisationVersion], nil
+}
+
+type authorisationTokenDeserializationFunc func(map[string]interface{}) (*authorisationToken, error)
+
+var authorisationTokenDeserializationFuncs = map[version.Number]authorisationTokenDeserializationFunc{
+	twoDotOh: authorisationToken_2_0,
+}
+
+// authorisationToken_2_0 reads both forms MAAS uses for tokens: the
+// separate keys returned when a token is created, and the single "token"
+// field, holding the keys joined with colons, used when listing them.
+func authorisationToken_2_0(source map[string]interface{}) (*authorisationToken, error) {
+	fields := schema.Fields{
+		"name":         nullable(stringField()),
+		"token":        stringField(),
+		"consumer_key": stringField(),
+		"token_key":    stringField(),
+		"token_secret": stringField(),
+	}
+	defaults := schema.Defaults{
+		"name":         "",
+		"token":        "",
+		"consumer_key": "",
+		"token_key":    "",
+		"token_secret": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "authorisation token 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	name, _ := valid["name"].(string)
+	result := &authorisationToken{
+		name:        name,
+		consumerKey: valid["consumer_key"].(string),
+		tokenKey:    valid["token_key"].(string),
+		tokenSecret: valid["token_secret"].(string),
+	}
+	if joined := valid["token"].(string); joined != "" {
+		parts := strings.Split(joined, ":")
+		if len(parts) != 3 {
+			return nil, NewDeserializationError("authorisation token: expected 3 parts, got %d", len(parts))
+		}
+		result.consumerKey, result.tokenKey, result.tokenSecret = parts[0], parts[1], parts[2]
+	}
+	if result.tokenKey == "" {
+		return nil, NewDeserializationError("authorisation token: missing token key")
+	}
+	return result, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type accountSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&accountSuite{})
+
+const createTokenResponse = `
+{
+    "name": "rotation",
+    "consumer_key": "ck",
+    "token_key": "tk",
+    "token_secret": "ts"
+}
+`
+
+const tokensResponse = `
+[
+    {"name": "MAAS consumer", "token": "ck1:tk1:ts1"},
+    {"name": null, "token": "ck2:tk2:ts2"}
+]
+`
+
+func (*accountSuite) TestReadAuthorisationTokenCreated(c *gc.C) {
+	token, err := readAuthorisationToken(twoDotOh, parseJSON(c, createTokenResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(token.Name(), gc.Equals, "rotation")
+	c.Check(token.ConsumerKey(), gc.Equals, "ck")
+	c.Check(token.TokenKey(), gc.Equals, "tk")
+	c.Check(token.TokenSecret(), gc.Equals, "ts")
+	c.Check(token.APIKey(), gc.Equals, "ck:tk:ts")
+}
+
+func (*accountSuite) TestReadAuthorisationTokens(c *gc.C) {
+	tokens, err := readAuthorisationTokens(twoDotOh, parseJSON(c, tokensResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(tokens, gc.HasLen, 2)
+	c.Check(tokens[0].Name(), gc.Equals, "MAAS consumer")
+	c.Check(tokens[0].APIKey(), gc.Equals, "ck1:tk1:ts1")
+	c.Check(tokens[1].Name(), gc.Equals, "")
+	c.Check(tokens[1].TokenKey(), gc.Equals, "tk2")
+}
+
+func (*accountSuite) TestReadAuthorisationTokensBadSchema(c *gc.C) {
+	_, err := readAuthorisationTokens(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `authorisation token base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*accountSuite) TestReadAuthorisationTokenBadToken(c *gc.C) {
+	_, err := readAuthorisationToken(twoDotOh, map[string]interface{}{"token": "a:b"})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, "authorisation token: expected 3 parts, got 2")
+	_, err = readAuthorisationToken(twoDotOh, map[string]interface{}{"name": "empty"})
+	c.Check(err, gc.ErrorMatches, "authorisation token: missing token key")
+}
+
+func (*accountSuite) TestLowVersion(c *gc.C) {
+	_, err := readAuthorisationTokens(version.MustParse("1.9.0"), parseJSON(c, tokensResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (*accountSuite) TestHighVersion(c *gc.C) {
+	tokens, err := readAuthorisationTokens(version.MustParse("2.1.9"), parseJSON(c, tokensResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(tokens, gc.HasLen, 2)
+}
+
+func (s *accountSuite) TestCreateToken(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/account/?op=create_authorisation_token", http.StatusOK, createTokenResponse)
+	token, err := controller.Account().CreateToken("rotation")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(token.APIKey(), gc.Equals, "ck:tk:ts")
+	c.Check(server.LastRequest().PostForm.Get("name"), gc.Equals, "rotation")
+}
+
+func (s *accountSuite) TestCreateTokenNoName(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/account/?op=create_authorisation_token", http.StatusOK, createTokenResponse)
+	_, err := controller.Account().CreateToken("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().PostForm, gc.HasLen, 0)
+}
+
+func (s *accountSuite) TestCreateTokenForbidden(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/account/?op=create_authorisation_token", http.StatusForbidden, "no")
+	_, err := controller.Account().CreateToken("rotation")
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *accountSuite) TestDeleteToken(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/account/?op=delete_authorisation_token", http.StatusOK, "")
+	err := controller.Account().DeleteToken("tk1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().PostForm.Get("token_key"), gc.Equals, "tk1")
+}
+
+func (s *accountSuite) TestDeleteTokenMissingKey(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	err := controller.Account().DeleteToken("")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *accountSuite) TestDeleteTokenNotFound(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/account/?op=delete_authorisation_token", http.StatusNotFound, "no such token")
+	err := controller.Account().DeleteToken("tk9")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, "no such token")
+}
+
+func (s *accountSuite) TestTokens(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/account/?op=list_authorisation_tokens", http.StatusOK, tokensResponse)
+	tokens, err := controller.Account().Tokens()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(tokens, gc.HasLen, 2)
+	c.Check(tokens[1].APIKey(), gc.Equals, "ck2:tk2:ts2")
+}
+
+func (s *accountSuite) TestTokensUnexpected(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/account/?op=list_authorisation_tokens", http.StatusInternalServerError, "boom")
+	_, err := controller.Account().Tokens()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
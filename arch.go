@@ -0,0 +1,141 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// architecturePattern matches an architecture with an optional
+// subarchitecture, such as "amd64" or "arm64/xgene-uboot".
+var architecturePattern = regexp.MustCompile(`^[a-z0-9_]+(/[a-z0-9_.-]+)?$`)
+
+// architectureAliases maps the names that the kernel and other tools use
+// for architectures to the Debian names that MAAS uses.
+var architectureAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"x64":     "amd64",
+	"aarch64": "arm64",
+	"armv8":   "arm64",
+	"armv7l":  "armhf",
+	"armv7":   "armhf",
+	"arm":     "armhf",
+	"i686":    "i386",
+	"i586":    "i386",
+	"x86":     "i386",
+	"ppc64le": "ppc64el",
+}
+
+// NormalizeArchitecture returns the architecture in the form MAAS uses in
+// constraints and boot resources: the Debian name of the architecture,
+// such as amd64, arm64, ppc64el or s390x, optionally followed by a slash
+// and a subarchitecture or kernel, such as generic or hwe-16.04. Case and
+// surrounding white space are ignored, and the names the kernel reports,
+// such as x86_64, aarch64 and ppc64le, are converted. An architecture that
+// is still not of the form arch or arch/subarch is not valid.
+func NormalizeArchitecture(arch string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(arch))
+	name, subarch := normalized, ""
+	if i := strings.Index(normalized, "/"); i >= 0 {
+		name, subarch = normalized[:i], normalized[i:]
+	}
+	if alias, ok := architectureAliases[name]; ok {
+		name = alias
+	}
+	normalized = name + subarch
+	if !architecturePattern.MatchString(normalized) {
+		return "", errors.NotValidf("architecture %q", arch)
+	}
+	return normalized, nil
+}
+
+// ArchKernel is an architecture and kernel that machines can be deployed
+// with, as found in the boot resources.
+type ArchKernel struct {
+	// Architecture is the architecture without a subarchitecture, such
+	// as "amd64".
+	Architecture string
+
+	// Kernel is the subarchitecture, such as "generic" or "hwe-16.04".
+	Kernel string
+
+	// Series are the names of the images with the architecture and
+	// kernel, such as "ubuntu/xenial", sorted.
+	Series []string
+}
+
+// String returns the combination in the form used for the architecture
+// of AllocateMachineArgs, such as "amd64/hwe-16.04".
+func (a ArchKernel) String() string {
+	return a.Architecture + "/" + a.Kernel
+}
+
+// bootloaderFirmwares are the firmwares that name the bootloader boot
+// resources, such as "grub-efi-signed/uefi" and "pxelinux/pxe".
+var bootloaderFirmwares = set.NewStrings("uefi", "pxe", "open-firmware")
+
+// isBootloader reports whether the boot resource is a bootloader rather
+// than an image. MAAS lists bootloaders with the images, with an
+// architecture of their own such as "amd64/generic", but names them
+// bootloader/firmware rather than os/series.
+func isBootloader(resource BootResource) bool {
+	i := strings.Index(resource.Name(), "/")
+	return i >= 0 && bootloaderFirmwares.Contains(resource.Name()[i+1:])
+}
+
+// ArchitectureKernels returns the architecture and kernel combinations of
+// the boot resources, sorted by architecture and then kernel. Resources
+// whose architecture has no subarchitecture use the generic kernel.
+// Bootloaders, and resources with malformed architectures, are skipped.
+func ArchitectureKernels(resources []BootResource) []ArchKernel {
+	type archKernel struct{ arch, kernel string }
+	series := make(map[archKernel]set.Strings)
+	for _, resource := range resources {
+		if isBootloader(resource) {
+			continue
+		}
+		normalized, err := NormalizeArchitecture(resource.Architecture())
+		if err != nil {
+			continue
+		}
+		key := archKernel{arch: normalized, kernel: "generic"}
+		if i := strings.Index(normalized, "/"); i >= 0 {
+			key.arch, key.kernel = normalized[:i], normalized[i+1:]
+		}
+		if series[key] == nil {
+			series[key] = set.NewStrings()
+		}
+		series[key].Add(resource.Name())
+	}
+	result := make([]ArchKernel, 0, len(series))
+	for key, names := range series {
+		result = append(result, ArchKernel{
+			Architecture: key.arch,
+			Kernel:       key.kernel,
+			Series:       names.SortedValues(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Architecture != result[j].Architecture {
+			return result[i].Architecture < result[j].Architecture
+		}
+		return result[i].Kernel < result[j].Kernel
+	})
+	return result
+}
+
+// ArchitectureKernels implements Controller.
+func (c *controller) ArchitectureKernels() ([]ArchKernel, error) {
+	resources, err := c.BootResources()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return ArchitectureKernels(resources), nil
+}
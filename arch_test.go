@@ -0,0 +1,114 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type archSuite struct{}
+
+var _ = gc.Suite(&archSuite{})
+
+func (*archSuite) TestNormalizeArchitecture(c *gc.C) {
+	for i, test := range []struct {
+		arch     string
+		expected string
+	}{
+		{"amd64", "amd64"},
+		{" AMD64 ", "amd64"},
+		{"x86_64", "amd64"},
+		{"aarch64/generic", "arm64/generic"},
+		{"ppc64le", "ppc64el"},
+		{"i686/hwe-16.04", "i386/hwe-16.04"},
+		{"armv7l", "armhf"},
+		{"s390x", "s390x"},
+		{"arm64/xgene-uboot", "arm64/xgene-uboot"},
+		{"Amd64/HWE-X", "amd64/hwe-x"},
+	} {
+		c.Logf("test %d: %q", i, test.arch)
+		arch, err := NormalizeArchitecture(test.arch)
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(arch, gc.Equals, test.expected)
+	}
+}
+
+func (*archSuite) TestNormalizeArchitectureNotValid(c *gc.C) {
+	for i, arch := range []string{"", "amd64/", "/generic", "amd 64", "amd64/hwe/16.04"} {
+		c.Logf("test %d: %q", i, arch)
+		_, err := NormalizeArchitecture(arch)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (*archSuite) TestArchitectureKernels(c *gc.C) {
+	var resources []BootResource
+	for _, response := range []string{bootResourcesResponse, bootloadersResponse} {
+		read, err := readBootResources(twoDotOh, parseJSON(c, response))
+		c.Assert(err, jc.ErrorIsNil)
+		for _, resource := range read {
+			resources = append(resources, resource)
+		}
+	}
+	resources = append(resources,
+		&bootResource{name: "ubuntu/xenial", architecture: "arm64"},
+		&bootResource{name: "ubuntu/bionic", architecture: "arm64/generic"},
+		&bootResource{name: "custom/broken", architecture: "bad arch"},
+	)
+	combos := ArchitectureKernels(resources)
+	c.Check(combos, jc.DeepEquals, []ArchKernel{
+		{"amd64", "hwe-t", []string{"ubuntu/trusty"}},
+		{"amd64", "hwe-u", []string{"ubuntu/trusty"}},
+		{"amd64", "hwe-v", []string{"ubuntu/trusty"}},
+		{"amd64", "hwe-w", []string{"ubuntu/trusty"}},
+		{"amd64", "hwe-x", []string{"ubuntu/xenial"}},
+		{"arm64", "generic", []string{"ubuntu/bionic", "ubuntu/xenial"}},
+	})
+	c.Check(combos[0].String(), gc.Equals, "amd64/hwe-t")
+}
+
+func (s *controllerSuite) TestArchitectureKernels(c *gc.C) {
+	controller := s.getController(c)
+	combos, err := controller.ArchitectureKernels()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(combos, gc.HasLen, 5)
+	c.Check(combos[4], jc.DeepEquals, ArchKernel{"amd64", "hwe-x", []string{"ubuntu/xenial"}})
+}
+
+// bootloadersResponse holds the bootloaders that MAAS lists with the
+// images it syncs.
+var bootloadersResponse = `
+[
+    {
+        "architecture": "amd64/generic",
+        "type": "Synced",
+        "name": "grub-efi-signed/uefi",
+        "id": 10,
+        "resource_uri": "/MAAS/api/2.0/boot-resources/10/"
+    },
+    {
+        "architecture": "arm64/generic",
+        "type": "Synced",
+        "name": "grub-efi/uefi",
+        "id": 11,
+        "resource_uri": "/MAAS/api/2.0/boot-resources/11/"
+    },
+    {
+        "architecture": "ppc64el/generic",
+        "type": "Synced",
+        "name": "grub-ieee1275/open-firmware",
+        "id": 12,
+        "resource_uri": "/MAAS/api/2.0/boot-resources/12/"
+    },
+    {
+        "architecture": "i386/generic",
+        "type": "Synced",
+        "name": "pxelinux/pxe",
+        "id": 13,
+        "resource_uri": "/MAAS/api/2.0/boot-resources/13/"
+    }
+]
+`
@@ -0,0 +1,65 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// DefaultBatchWorkers is the number of concurrent requests BatchGet makes
+// when no worker count is given.
+const DefaultBatchWorkers = 4
+
+// BatchResult holds the outcome of fetching a single URI with BatchGet.
+type BatchResult struct {
+	URI  string
+	Body []byte
+	Err  error
+}
+
+// BatchGet fetches each of the resource URIs concurrently, making at most
+// workers requests at a time. If workers is less than one,
+// DefaultBatchWorkers is used. The results are returned in the same order as
+// the uris, and a failure to fetch one URI is recorded in its result rather
+// than stopping the others.
+func (client Client) BatchGet(uris []string, workers int) []BatchResult {
+	if workers < 1 {
+		workers = DefaultBatchWorkers
+	}
+	if workers > len(uris) {
+		workers = len(uris)
+	}
+	results := make([]BatchResult, len(uris))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				results[index] = client.batchGetOne(uris[index])
+			}
+		}()
+	}
+	for index := range uris {
+		indices <- index
+	}
+	close(indices)
+	wg.Wait()
+	return results
+}
+
+func (client Client) batchGetOne(uri string) BatchResult {
+	result := BatchResult{URI: uri}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		result.Err = errors.Trace(err)
+		return result
+	}
+	result.Body, result.Err = client.Get(parsed, "", nil)
+	return result
+}
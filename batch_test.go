@@ -0,0 +1,134 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type batchSuite struct{}
+
+var _ = gc.Suite(&batchSuite{})
+
+// concurrencyServer answers every request with its path, after a short
+// delay, and records the highest number of requests in flight at once.
+type concurrencyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+}
+
+func newConcurrencyServer() *concurrencyServer {
+	server := &concurrencyServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handler))
+	return server
+}
+
+func (s *concurrencyServer) handler(writer http.ResponseWriter, request *http.Request) {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxSeen {
+		s.maxSeen = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if request.URL.Path == "/api/2.0/missing/" {
+		http.Error(writer, "no such thing", http.StatusNotFound)
+		return
+	}
+	fmt.Fprintf(writer, "%q", request.URL.Path)
+}
+
+func (*batchSuite) TestBatchGetOrderAndErrors(c *gc.C) {
+	server := newConcurrencyServer()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	uris := []string{"machines/1/", "missing/", "/api/2.0/subnets/3/", "fabrics/2/"}
+	results := client.BatchGet(uris, 2)
+	c.Assert(results, gc.HasLen, 4)
+
+	c.Check(results[0].URI, gc.Equals, "machines/1/")
+	c.Check(results[0].Err, jc.ErrorIsNil)
+	c.Check(string(results[0].Body), gc.Equals, `"/api/2.0/machines/1/"`)
+
+	c.Check(results[1].URI, gc.Equals, "missing/")
+	svrErr, ok := GetServerError(results[1].Err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrErr.StatusCode, gc.Equals, http.StatusNotFound)
+
+	c.Check(results[2].Err, jc.ErrorIsNil)
+	c.Check(string(results[2].Body), gc.Equals, `"/api/2.0/subnets/3/"`)
+	c.Check(results[3].Err, jc.ErrorIsNil)
+	c.Check(string(results[3].Body), gc.Equals, `"/api/2.0/fabrics/2/"`)
+}
+
+func (*batchSuite) TestBatchGetBoundsConcurrency(c *gc.C) {
+	server := newConcurrencyServer()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	var uris []string
+	for i := 0; i < 12; i++ {
+		uris = append(uris, fmt.Sprintf("machines/%d/", i))
+	}
+	results := client.BatchGet(uris, 3)
+	c.Assert(results, gc.HasLen, 12)
+	for i, result := range results {
+		c.Check(result.Err, jc.ErrorIsNil)
+		c.Check(string(result.Body), gc.Equals, fmt.Sprintf(`"/api/2.0/machines/%d/"`, i))
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	c.Check(server.maxSeen <= 3, jc.IsTrue)
+	c.Check(server.maxSeen > 1, jc.IsTrue)
+}
+
+func (*batchSuite) TestBatchGetDefaultWorkers(c *gc.C) {
+	server := newConcurrencyServer()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	var uris []string
+	for i := 0; i < 10; i++ {
+		uris = append(uris, fmt.Sprintf("zones/%d/", i))
+	}
+	results := client.BatchGet(uris, 0)
+	c.Assert(results, gc.HasLen, 10)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	c.Check(server.maxSeen <= DefaultBatchWorkers, jc.IsTrue)
+}
+
+func (*batchSuite) TestBatchGetEmpty(c *gc.C) {
+	client, err := NewAnonymousClient("http://example.com/", "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.BatchGet(nil, 4), gc.HasLen, 0)
+}
+
+func (*batchSuite) TestBatchGetBadURI(c *gc.C) {
+	client, err := NewAnonymousClient("http://example.com/", "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	results := client.BatchGet([]string{"%zz"}, 1)
+	c.Assert(results, gc.HasLen, 1)
+	c.Assert(results[0].Err, gc.NotNil)
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"testing"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+// machinesPayload returns a listing of n machines like machineResponse,
+// each with its own system ID.
+func machinesPayload(n int) []byte {
+	var machine map[string]interface{}
+	if err := json.Unmarshal([]byte(machineResponse), &machine); err != nil {
+		panic(err)
+	}
+	machines := make([]interface{}, n)
+	for i := range machines {
+		copied := make(map[string]interface{}, len(machine))
+		for key, value := range machine {
+			copied[key] = value
+		}
+		copied["system_id"] = fmt.Sprintf("node-%04d", i)
+		copied["resource_uri"] = fmt.Sprintf("/MAAS/api/2.0/machines/node-%04d/", i)
+		machines[i] = copied
+	}
+	payload, err := json.Marshal(machines)
+	if err != nil {
+		panic(err)
+	}
+	return payload
+}
+
+func BenchmarkParseMachines(b *testing.B) {
+	payload := machinesPayload(500)
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(Client{}, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadMachines(b *testing.B) {
+	payload := machinesPayload(500)
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var source interface{}
+		if err := json.Unmarshal(payload, &source); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := readMachines(twoDotOh, source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClientGetMachines(b *testing.B) {
+	payload := machinesPayload(500)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		w.Write(payload)
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	client.HTTPClient, err = NewHTTPClient(DefaultTransportOptions())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Get(&url.URL{Path: "machines/"}, "", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClientPostOwnerData(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "fake:as:key", "2.0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	client.HTTPClient, err = NewHTTPClient(DefaultTransportOptions())
+	if err != nil {
+		b.Fatal(err)
+	}
+	params := make(url.Values)
+	for i := 0; i < 20; i++ {
+		params.Set(fmt.Sprintf("owner_data.key-%02d", i), fmt.Sprintf("value for key %d", i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		uri := &url.URL{Path: "machines/4y3ha3/"}
+		if _, err := client.Post(uri, "set_owner_data", params, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// readBodyAllocated returns the bytes allocated to read a response of the
+// given length with readResponseBody.
+func readBodyAllocated(length int, header bool) uint64 {
+	response := &http.Response{
+		Body:          ioutil.NopCloser(bytes.NewReader(make([]byte, length))),
+		ContentLength: -1,
+	}
+	if header {
+		response.ContentLength = int64(length)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := readResponseBody(response); err != nil {
+		panic(err)
+	}
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+type budgetSuite struct{}
+
+var _ = gc.Suite(&budgetSuite{})
+
+// TestReadResponseBody checks that a response with a Content-Length is
+// read into a single buffer of about its size.
+func (*budgetSuite) TestReadResponseBody(c *gc.C) {
+	const length = 4 << 20
+	c.Check(readBodyAllocated(length, true) <= length*11/10, jc.IsTrue)
+	c.Check(readBodyAllocated(length, false) >= length, jc.IsTrue)
+}
+
+func BenchmarkSendFormEncoding(b *testing.B) {
+	params := make(url.Values)
+	for i := 0; i < 20; i++ {
+		params.Set(fmt.Sprintf("owner_data.key-%02d", i), fmt.Sprintf("value for key %d", i))
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		form, err := newPooledForm(params)
+		if err != nil {
+			b.Fatal(err)
+		}
+		form.release()
+	}
+}
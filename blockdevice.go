@@ -10,6 +10,8 @@ import (
 )
 
 type blockdevice struct {
+	rawJSON
+
 	resourceURI string
 
 	id      int
@@ -126,18 +128,18 @@ var blockdeviceDeserializationFuncs = map[version.Number]blockdeviceDeserializat
 
 func blockdevice_2_0(source map[string]interface{}) (*blockdevice, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
+		"resource_uri": stringField(),
 
-		"id":       schema.ForceInt(),
-		"name":     schema.String(),
-		"model":    schema.OneOf(schema.Nil(""), schema.String()),
-		"path":     schema.String(),
-		"used_for": schema.String(),
-		"tags":     schema.List(schema.String()),
+		"id":       intField(),
+		"name":     stringField(),
+		"model":    nullable(stringField()),
+		"path":     stringField(),
+		"used_for": stringField(),
+		"tags":     schema.List(stringField()),
 
-		"block_size": schema.ForceUint(),
-		"used_size":  schema.ForceUint(),
-		"size":       schema.ForceUint(),
+		"block_size": uintField(),
+		"used_size":  uintField(),
+		"size":       uintField(),
 
 		"partitions": schema.List(schema.StringMap(schema.Any())),
 	}
@@ -157,6 +159,7 @@ func blockdevice_2_0(source map[string]interface{}) (*blockdevice, error) {
 
 	model, _ := valid["model"].(string)
 	result := &blockdevice{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 
 		id:      valid["id"].(int),
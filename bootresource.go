@@ -13,6 +13,8 @@ import (
 )
 
 type bootResource struct {
+	rawJSON
+
 	// Add the controller in when we need to do things with the bootResource.
 	// controller Controller
 
@@ -102,13 +104,13 @@ var bootResourceDeserializationFuncs = map[version.Number]bootResourceDeserializ
 
 func bootResource_2_0(source map[string]interface{}) (*bootResource, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"name":         schema.String(),
-		"type":         schema.String(),
-		"architecture": schema.String(),
-		"subarches":    schema.String(),
-		"kflavor":      schema.String(),
+		"resource_uri": stringField(),
+		"id":           intField(),
+		"name":         stringField(),
+		"type":         stringField(),
+		"architecture": stringField(),
+		"subarches":    stringField(),
+		"kflavor":      stringField(),
 	}
 	defaults := schema.Defaults{
 		"subarches": "",
@@ -124,6 +126,7 @@ func bootResource_2_0(source map[string]interface{}) (*bootResource, error) {
 	// contains fields of the right type.
 
 	result := &bootResource{
+		rawJSON:      rawJSON{source},
 		resourceURI:  valid["resource_uri"].(string),
 		id:           valid["id"].(int),
 		name:         valid["name"].(string),
@@ -0,0 +1,469 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type bootSource struct {
+	rawJSON
+
+	controller *controller
+
+	resourceURI string
+
+	id              int
+	url             string
+	keyringFilename string
+	keyringData     string
+}
+
+func (b *bootSource) updateFrom(other *bootSource) {
+	b.rawJSON = other.rawJSON
+	b.resourceURI = other.resourceURI
+	b.id = other.id
+	b.url = other.url
+	b.keyringFilename = other.keyringFilename
+	b.keyringData = other.keyringData
+}
+
+// ID implements BootSource.
+func (b *bootSource) ID() int {
+	return b.id
+}
+
+// URL implements BootSource.
+func (b *bootSource) URL() string {
+	return b.url
+}
+
+// KeyringFilename implements BootSource.
+func (b *bootSource) KeyringFilename() string {
+	return b.keyringFilename
+}
+
+// KeyringData implements BootSource.
+func (b *bootSource) KeyringData() string {
+	return b.keyringData
+}
+
+// UpdateBootSourceArgs is an argument struct for calling BootSource.Update.
+// Only the values that are set are updated.
+type UpdateBootSourceArgs struct {
+	URL             string
+	KeyringFilename string
+}
+
+// Update implements BootSource.
+func (b *bootSource) Update(args UpdateBootSourceArgs) error {
+	var empty UpdateBootSourceArgs
+	if args == empty {
+		return nil
+	}
+	params := NewURLParams()
+	params.MaybeAdd("url", args.URL)
+	params.MaybeAdd("keyring_filename", args.KeyringFilename)
+	source, err := b.controller.put(b.resourceURI, params.Values)
+	if err != nil {
+		return translateBootSourceError(err)
+	}
+	response, err := readBootSource(b.controller.apiVersion, b.controller.markLeaves(source))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	b.updateFrom(response)
+	return nil
+}
+
+// Delete implements BootSource.
+func (b *bootSource) Delete() error {
+	if err := b.controller.delete(b.resourceURI); err != nil {
+		return translateBootSourceError(err)
+	}
+	return nil
+}
+
+func (b *bootSource) selectionsURI() string {
+	return EnsureTrailingSlash(b.resourceURI) + "selections/"
+}
+
+// Selections implements BootSource.
+func (b *bootSource) Selections() ([]BootSourceSelection, error) {
+	source, err := b.controller.get(b.selectionsURI())
+	if err != nil {
+		return nil, translateBootSourceError(err)
+	}
+	selections, err := readBootSourceSelections(b.controller.apiVersion, b.controller.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []BootSourceSelection
+	for _, s := range selections {
+		s.controller = b.controller
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// CreateBootSourceSelectionArgs is an argument struct for passing information
+// into BootSource.CreateSelection. OS and Release are required. Arches,
+// Subarches and Labels default to all ("*") on the server if not specified.
+type CreateBootSourceSelectionArgs struct {
+	OS        string
+	Release   string
+	Arches    []string
+	Subarches []string
+	Labels    []string
+}
+
+// Validate checks that the required fields are set.
+func (a *CreateBootSourceSelectionArgs) Validate() error {
+	if a.OS == "" {
+		return errors.NotValidf("missing OS")
+	}
+	if a.Release == "" {
+		return errors.NotValidf("missing Release")
+	}
+	return nil
+}
+
+// CreateSelection implements BootSource.
+func (b *bootSource) CreateSelection(args CreateBootSourceSelectionArgs) (BootSourceSelection, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("os", args.OS)
+	params.Values.Add("release", args.Release)
+	params.MaybeAddMany("arches", args.Arches)
+	params.MaybeAddMany("subarches", args.Subarches)
+	params.MaybeAddMany("labels", args.Labels)
+	source, err := b.controller.post(b.selectionsURI(), "", params.Values)
+	if err != nil {
+		return nil, translateBootSourceError(err)
+	}
+	selection, err := readBootSourceSelection(b.controller.apiVersion, b.controller.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	selection.controller = b.controller
+	return selection, nil
+}
+
+type bootSourceSelection struct {
+	rawJSON
+
+	controller *controller
+
+	resourceURI string
+
+	id           int
+	bootSourceID int
+	os           string
+	release      string
+	arches       []string
+	subarches    []string
+	labels       []string
+}
+
+func (s *bootSourceSelection) updateFrom(other *bootSourceSelection) {
+	s.rawJSON = other.rawJSON
+	s.resourceURI = other.resourceURI
+	s.id = other.id
+	s.bootSourceID = other.bootSourceID
+	s.os = other.os
+	s.release = other.release
+	s.arches = other.arches
+	s.subarches = other.subarches
+	s.labels = other.labels
+}
+
+// ID implements BootSourceSelection.
+func (s *bootSourceSelection) ID() int {
+	return s.id
+}
+
+// BootSourceID implements BootSourceSelection.
+func (s *bootSourceSelection) BootSourceID() int {
+	return s.bootSourceID
+}
+
+// OS implements BootSourceSelection.
+func (s *bootSourceSelection) OS() string {
+	return s.os
+}
+
+// Release implements BootSourceSelection.
+func (s *bootSourceSelection) Release() string {
+	return s.release
+}
+
+// Arches implements BootSourceSelection.
+func (s *bootSourceSelection) Arches() []string {
+	return s.arches
+}
+
+// Subarches implements BootSourceSelection.
+func (s *bootSourceSelection) Subarches() []string {
+	return s.subarches
+}
+
+// Labels implements BootSourceSelection.
+func (s *bootSourceSelection) Labels() []string {
+	return s.labels
+}
+
+// UpdateBootSourceSelectionArgs is an argument struct for calling
+// BootSourceSelection.Update. Only the values that are set are updated.
+type UpdateBootSourceSelectionArgs struct {
+	OS        string
+	Release   string
+	Arches    []string
+	Subarches []string
+	Labels    []string
+}
+
+func (a *UpdateBootSourceSelectionArgs) isEmpty() bool {
+	return a.OS == "" && a.Release == "" &&
+		len(a.Arches) == 0 && len(a.Subarches) == 0 && len(a.Labels) == 0
+}
+
+// Update implements BootSourceSelection.
+func (s *bootSourceSelection) Update(args UpdateBootSourceSelectionArgs) error {
+	if args.isEmpty() {
+		return nil
+	}
+	params := NewURLParams()
+	params.MaybeAdd("os", args.OS)
+	params.MaybeAdd("release", args.Release)
+	params.MaybeAddMany("arches", args.Arches)
+	params.MaybeAddMany("subarches", args.Subarches)
+	params.MaybeAddMany("labels", args.Labels)
+	source, err := s.controller.put(s.resourceURI, params.Values)
+	if err != nil {
+		return translateBootSourceError(err)
+	}
+	response, err := readBootSourceSelection(s.controller.apiVersion, s.controller.markLeaves(source))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.updateFrom(response)
+	return nil
+}
+
+// Delete implements BootSourceSelection.
+func (s *bootSourceSelection) Delete() error {
+	if err := s.controller.delete(s.resourceURI); err != nil {
+		return translateBootSourceError(err)
+	}
+	return nil
+}
+
+func translateBootSourceError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func getBootSourceDeserializationFunc(controllerVersion version.Number) (bootSourceDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range bootSourceDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no boot source read func for version %s", controllerVersion)
+	}
+	return bootSourceDeserializationFuncs[deserialisationVersion], nil
+}
+
+func readBootSource(controllerVersion version.Number, source interface{}) (*bootSource, error) {
+	readFunc, err := getBootSourceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readBootSources(controllerVersion version.Number, source interface{}) ([]*bootSource, error) {
+	readFunc, err := getBootSourceDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	return readBootSourceList(valid, readFunc)
+}
+
+// readBootSourceList expects the values of the sourceList to be string maps.
+func readBootSourceList(sourceList []interface{}, readFunc bootSourceDeserializationFunc) ([]*bootSource, error) {
+	result := make([]*bootSource, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for boot source %d, %T", i, value)
+		}
+		bootSource, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "boot source %d", i)
+		}
+		result = append(result, bootSource)
+	}
+	return result, nil
+}
+
+type bootSourceDeserializationFunc func(map[string]interface{}) (*bootSource, error)
+
+var bootSourceDeserializationFuncs = map[version.Number]bootSourceDeserializationFunc{
+	twoDotOh: bootSource_2_0,
+}
+
+func bootSource_2_0(source map[string]interface{}) (*bootSource, error) {
+	fields := schema.Fields{
+		"resource_uri":     stringField(),
+		"id":               intField(),
+		"url":              stringField(),
+		"keyring_filename": stringField(),
+		"keyring_data":     stringField(),
+	}
+	defaults := schema.Defaults{
+		"keyring_filename": "",
+		"keyring_data":     "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	result := &bootSource{
+		rawJSON:         rawJSON{source},
+		resourceURI:     valid["resource_uri"].(string),
+		id:              valid["id"].(int),
+		url:             valid["url"].(string),
+		keyringFilename: valid["keyring_filename"].(string),
+		keyringData:     valid["keyring_data"].(string),
+	}
+	return result, nil
+}
+
+func getBootSourceSelectionDeserializationFunc(controllerVersion version.Number) (bootSourceSelectionDeserializationFunc, error) {
+	var deserialisationVersion version.Number
+	for v := range bootSourceSelectionDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no boot source selection read func for version %s", controllerVersion)
+	}
+	return bootSourceSelectionDeserializationFuncs[deserialisationVersion], nil
+}
+
+func readBootSourceSelection(controllerVersion version.Number, source interface{}) (*bootSourceSelection, error) {
+	readFunc, err := getBootSourceSelectionDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source selection base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readBootSourceSelections(controllerVersion version.Number, source interface{}) ([]*bootSourceSelection, error) {
+	readFunc, err := getBootSourceSelectionDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source selection base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	result := make([]*bootSourceSelection, 0, len(valid))
+	for i, value := range valid {
+		// The schema check guarantees the cast is safe.
+		selection, err := readFunc(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(err, "boot source selection %d", i)
+		}
+		result = append(result, selection)
+	}
+	return result, nil
+}
+
+type bootSourceSelectionDeserializationFunc func(map[string]interface{}) (*bootSourceSelection, error)
+
+var bootSourceSelectionDeserializationFuncs = map[version.Number]bootSourceSelectionDeserializationFunc{
+	twoDotOh: bootSourceSelection_2_0,
+}
+
+func bootSourceSelection_2_0(source map[string]interface{}) (*bootSourceSelection, error) {
+	fields := schema.Fields{
+		"resource_uri":   stringField(),
+		"id":             intField(),
+		"boot_source_id": intField(),
+		"os":             stringField(),
+		"release":        stringField(),
+		"arches":         schema.List(stringField()),
+		"subarches":      schema.List(stringField()),
+		"labels":         schema.List(stringField()),
+	}
+	defaults := schema.Defaults{
+		"boot_source_id": 0,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "boot source selection 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	result := &bootSourceSelection{
+		rawJSON:      rawJSON{source},
+		resourceURI:  valid["resource_uri"].(string),
+		id:           valid["id"].(int),
+		bootSourceID: valid["boot_source_id"].(int),
+		os:           valid["os"].(string),
+		release:      valid["release"].(string),
+		arches:       convertToStringSlice(valid["arches"]),
+		subarches:    convertToStringSlice(valid["subarches"]),
+		labels:       convertToStringSlice(valid["labels"]),
+	}
+	return result, nil
+}
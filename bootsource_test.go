@@ -0,0 +1,257 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type bootSourceSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&bootSourceSuite{})
+
+func (*bootSourceSuite) TestReadBootSourcesBadSchema(c *gc.C) {
+	_, err := readBootSources(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `boot source base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*bootSourceSuite) TestReadBootSources(c *gc.C) {
+	bootSources, err := readBootSources(twoDotOh, parseJSON(c, bootSourcesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bootSources, gc.HasLen, 1)
+	source := bootSources[0]
+	c.Check(source.ID(), gc.Equals, 1)
+	c.Check(source.URL(), gc.Equals, "http://images.maas.io/ephemeral-v3/daily/")
+	c.Check(source.KeyringFilename(), gc.Equals, "/usr/share/keyrings/ubuntu-cloudimage-keyring.gpg")
+	c.Check(source.KeyringData(), gc.Equals, "")
+}
+
+func (*bootSourceSuite) TestReadBootSourceSelections(c *gc.C) {
+	selections, err := readBootSourceSelections(twoDotOh, parseJSON(c, bootSourceSelectionsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(selections, gc.HasLen, 1)
+	selection := selections[0]
+	c.Check(selection.ID(), gc.Equals, 1)
+	c.Check(selection.BootSourceID(), gc.Equals, 1)
+	c.Check(selection.OS(), gc.Equals, "ubuntu")
+	c.Check(selection.Release(), gc.Equals, "xenial")
+	c.Check(selection.Arches(), jc.DeepEquals, []string{"amd64"})
+	c.Check(selection.Subarches(), jc.DeepEquals, []string{"*"})
+	c.Check(selection.Labels(), jc.DeepEquals, []string{"*"})
+}
+
+func (*bootSourceSuite) TestLowVersion(c *gc.C) {
+	_, err := readBootSources(version.MustParse("1.9.0"), parseJSON(c, bootSourcesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	_, err = readBootSourceSelections(version.MustParse("1.9.0"), parseJSON(c, bootSourceSelectionsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (*bootSourceSuite) TestHighVersion(c *gc.C) {
+	bootSources, err := readBootSources(version.MustParse("2.1.9"), parseJSON(c, bootSourcesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bootSources, gc.HasLen, 1)
+}
+
+func (s *bootSourceSuite) getServerAndBootSource(c *gc.C) (*SimpleTestServer, *bootSource) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/boot-sources/", http.StatusOK, bootSourcesResponse)
+	bootSources, err := controller.BootSources()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bootSources, gc.HasLen, 1)
+	source := bootSources[0].(*bootSource)
+	server.ResetRequests()
+	return server, source
+}
+
+func (s *bootSourceSuite) TestCreateBootSourceArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    CreateBootSourceArgs
+		errText string
+	}{{
+		errText: "missing URL not valid",
+	}, {
+		args: CreateBootSourceArgs{
+			URL:             "http://example.com/",
+			KeyringFilename: "/some/file",
+			KeyringData:     []byte("data"),
+		},
+		errText: "specifying KeyringFilename and KeyringData not valid",
+	}, {
+		args: CreateBootSourceArgs{URL: "http://example.com/"},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *bootSourceSuite) TestCreateBootSource(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/boot-sources/", http.StatusOK, bootSourceResponse)
+	source, err := controller.CreateBootSource(CreateBootSourceArgs{
+		URL:             "http://images.maas.io/ephemeral-v3/daily/",
+		KeyringFilename: "/usr/share/keyrings/ubuntu-cloudimage-keyring.gpg",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(source.ID(), gc.Equals, 1)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("url"), gc.Equals, "http://images.maas.io/ephemeral-v3/daily/")
+	c.Check(form.Get("keyring_filename"), gc.Equals, "/usr/share/keyrings/ubuntu-cloudimage-keyring.gpg")
+}
+
+func (s *bootSourceSuite) TestCreateBootSourceKeyringData(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/boot-sources/", http.StatusOK, bootSourceResponse)
+	_, err := controller.CreateBootSource(CreateBootSourceArgs{
+		URL:         "http://mirror.example.com/",
+		KeyringData: []byte("keyring"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	request := server.LastRequest()
+	c.Check(request.MultipartForm.Value["url"], jc.DeepEquals, []string{"http://mirror.example.com/"})
+	c.Check(request.MultipartForm.File["keyring_data"], gc.HasLen, 1)
+}
+
+func (s *bootSourceSuite) TestCreateBootSourceBadRequest(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/boot-sources/", http.StatusBadRequest, "bad url")
+	_, err := controller.CreateBootSource(CreateBootSourceArgs{URL: "wat"})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "bad url")
+}
+
+func (s *bootSourceSuite) TestUpdate(c *gc.C) {
+	server, source := s.getServerAndBootSource(c)
+	response := updateJSONMap(c, bootSourceResponse, map[string]interface{}{
+		"url": "http://mirror.example.com/",
+	})
+	server.AddPutResponse(source.resourceURI, http.StatusOK, response)
+	err := source.Update(UpdateBootSourceArgs{URL: "http://mirror.example.com/"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(source.URL(), gc.Equals, "http://mirror.example.com/")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("url"), gc.Equals, "http://mirror.example.com/")
+}
+
+func (s *bootSourceSuite) TestUpdateNoChanges(c *gc.C) {
+	server, source := s.getServerAndBootSource(c)
+	err := source.Update(UpdateBootSourceArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *bootSourceSuite) TestDelete(c *gc.C) {
+	server, source := s.getServerAndBootSource(c)
+	server.AddDeleteResponse(source.resourceURI, http.StatusNoContent, "")
+	err := source.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *bootSourceSuite) TestDeleteNotFound(c *gc.C) {
+	_, source := s.getServerAndBootSource(c)
+	err := source.Delete()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *bootSourceSuite) TestSelections(c *gc.C) {
+	server, source := s.getServerAndBootSource(c)
+	server.AddGetResponse(source.resourceURI+"selections/", http.StatusOK, bootSourceSelectionsResponse)
+	selections, err := source.Selections()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(selections, gc.HasLen, 1)
+	c.Assert(selections[0].Release(), gc.Equals, "xenial")
+}
+
+func (s *bootSourceSuite) TestCreateSelectionValidates(c *gc.C) {
+	_, source := s.getServerAndBootSource(c)
+	_, err := source.CreateSelection(CreateBootSourceSelectionArgs{OS: "ubuntu"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, "missing Release not valid")
+}
+
+func (s *bootSourceSuite) TestCreateSelection(c *gc.C) {
+	server, source := s.getServerAndBootSource(c)
+	server.AddPostResponse(source.resourceURI+"selections/", http.StatusOK, bootSourceSelectionResponse)
+	selection, err := source.CreateSelection(CreateBootSourceSelectionArgs{
+		OS:      "ubuntu",
+		Release: "xenial",
+		Arches:  []string{"amd64", "arm64"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(selection.OS(), gc.Equals, "ubuntu")
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 3)
+	c.Check(form["arches"], jc.DeepEquals, []string{"amd64", "arm64"})
+}
+
+func (s *bootSourceSuite) TestSelectionUpdateAndDelete(c *gc.C) {
+	server, source := s.getServerAndBootSource(c)
+	server.AddGetResponse(source.resourceURI+"selections/", http.StatusOK, bootSourceSelectionsResponse)
+	selections, err := source.Selections()
+	c.Assert(err, jc.ErrorIsNil)
+	selection := selections[0].(*bootSourceSelection)
+
+	response := updateJSONMap(c, bootSourceSelectionResponse, map[string]interface{}{
+		"release": "bionic",
+	})
+	server.AddPutResponse(selection.resourceURI, http.StatusOK, response)
+	err = selection.Update(UpdateBootSourceSelectionArgs{Release: "bionic"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(selection.Release(), gc.Equals, "bionic")
+
+	server.AddDeleteResponse(selection.resourceURI, http.StatusForbidden, "not yours")
+	err = selection.Delete()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+const (
+	bootSourceResponse = `
+{
+    "url": "http://images.maas.io/ephemeral-v3/daily/",
+    "keyring_filename": "/usr/share/keyrings/ubuntu-cloudimage-keyring.gpg",
+    "keyring_data": "",
+    "id": 1,
+    "resource_uri": "/MAAS/api/2.0/boot-sources/1/",
+    "created": "2016-04-19T10:50:24.017",
+    "updated": "2016-04-19T10:50:24.017"
+}
+`
+	bootSourceSelectionResponse = `
+{
+    "os": "ubuntu",
+    "release": "xenial",
+    "arches": ["amd64"],
+    "subarches": ["*"],
+    "labels": ["*"],
+    "boot_source_id": 1,
+    "id": 1,
+    "resource_uri": "/MAAS/api/2.0/boot-sources/1/selections/1/"
+}
+`
+)
+
+var (
+	bootSourcesResponse          = "[" + bootSourceResponse + "]"
+	bootSourceSelectionsResponse = "[" + bootSourceSelectionResponse + "]"
+)
@@ -0,0 +1,145 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+)
+
+// callResource sends a GET or POST with the op and params to the resource
+// URI of an entity, and returns the response as a JSONObject. A response
+// without a body is returned as a null JSONObject.
+func (c *controller) callResource(method, resourceURI, op string, params url.Values) (JSONObject, error) {
+	var body []byte
+	var err error
+	if method == http.MethodGet {
+		body, err = c._getRaw(resourceURI, op, params)
+	} else {
+		body, err = c._postRaw(resourceURI, op, params, nil)
+	}
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return JSONObject{}, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return JSONObject{}, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return JSONObject{}, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return JSONObject{}, NewUnexpectedError(err)
+	}
+	if len(body) == 0 {
+		return maasify(*c.client, nil), nil
+	}
+	result, err := Parse(*c.client, body)
+	if err != nil {
+		return JSONObject{}, NewUnexpectedError(err)
+	}
+	return result, nil
+}
+
+// CallGet implements ResourceCaller.
+func (m *machine) CallGet(op string, params url.Values) (JSONObject, error) {
+	return m.controller.callResource(http.MethodGet, m.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (m *machine) CallPost(op string, params url.Values) (JSONObject, error) {
+	return m.controller.callResource(http.MethodPost, m.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (d *device) CallGet(op string, params url.Values) (JSONObject, error) {
+	return d.controller.callResource(http.MethodGet, d.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (d *device) CallPost(op string, params url.Values) (JSONObject, error) {
+	return d.controller.callResource(http.MethodPost, d.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (n *node) CallGet(op string, params url.Values) (JSONObject, error) {
+	return n.controller.callResource(http.MethodGet, n.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (n *node) CallPost(op string, params url.Values) (JSONObject, error) {
+	return n.controller.callResource(http.MethodPost, n.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (r *rackController) CallGet(op string, params url.Values) (JSONObject, error) {
+	return r.controller.callResource(http.MethodGet, r.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (r *rackController) CallPost(op string, params url.Values) (JSONObject, error) {
+	return r.controller.callResource(http.MethodPost, r.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (i *interface_) CallGet(op string, params url.Values) (JSONObject, error) {
+	return i.controller.callResource(http.MethodGet, i.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (i *interface_) CallPost(op string, params url.Values) (JSONObject, error) {
+	return i.controller.callResource(http.MethodPost, i.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (s *space) CallGet(op string, params url.Values) (JSONObject, error) {
+	return s.controller.callResource(http.MethodGet, s.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (s *space) CallPost(op string, params url.Values) (JSONObject, error) {
+	return s.controller.callResource(http.MethodPost, s.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (s *subnet) CallGet(op string, params url.Values) (JSONObject, error) {
+	return s.controller.callResource(http.MethodGet, s.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (s *subnet) CallPost(op string, params url.Values) (JSONObject, error) {
+	return s.controller.callResource(http.MethodPost, s.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (b *bootSource) CallGet(op string, params url.Values) (JSONObject, error) {
+	return b.controller.callResource(http.MethodGet, b.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (b *bootSource) CallPost(op string, params url.Values) (JSONObject, error) {
+	return b.controller.callResource(http.MethodPost, b.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (h *vmHost) CallGet(op string, params url.Values) (JSONObject, error) {
+	return h.controller.callResource(http.MethodGet, h.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (h *vmHost) CallPost(op string, params url.Values) (JSONObject, error) {
+	return h.controller.callResource(http.MethodPost, h.resourceURI, op, params)
+}
+
+// CallGet implements ResourceCaller.
+func (f *file) CallGet(op string, params url.Values) (JSONObject, error) {
+	return f.controller.callResource(http.MethodGet, f.resourceURI, op, params)
+}
+
+// CallPost implements ResourceCaller.
+func (f *file) CallPost(op string, params url.Values) (JSONObject, error) {
+	return f.controller.callResource(http.MethodPost, f.resourceURI, op, params)
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *machineSuite) TestCallGet(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=details&verbose=1", http.StatusOK, `{"lshw": "data"}`)
+
+	result, err := machine.CallGet("details", url.Values{"verbose": {"1"}})
+	c.Assert(err, jc.ErrorIsNil)
+	details, err := result.GetMap()
+	c.Assert(err, jc.ErrorIsNil)
+	lshw, err := details["lshw"].GetString()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(lshw, gc.Equals, "data")
+}
+
+func (s *machineSuite) TestCallPost(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=rescue_mode", http.StatusOK, machineResponse)
+
+	result, err := machine.CallPost("rescue_mode", url.Values{"comment": {"help"}})
+	c.Assert(err, jc.ErrorIsNil)
+	fields, err := result.GetMap()
+	c.Assert(err, jc.ErrorIsNil)
+	systemID, err := fields["system_id"].GetString()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(systemID, gc.Equals, "4y3ha3")
+	c.Check(server.LastRequest().PostForm.Get("comment"), gc.Equals, "help")
+}
+
+func (s *machineSuite) TestCallPostEmptyResponse(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=abort", http.StatusOK, "")
+
+	result, err := machine.CallPost("abort", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.IsNil(), jc.IsTrue)
+}
+
+func (s *machineSuite) TestCallErrors(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=missing", http.StatusNotFound, "no such op")
+	server.AddPostResponse(machine.resourceURI+"?op=secret", http.StatusForbidden, "not yours")
+	server.AddPostResponse(machine.resourceURI+"?op=broken", http.StatusInternalServerError, "oops")
+
+	_, err := machine.CallGet("missing", nil)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	_, err = machine.CallPost("secret", nil)
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	_, err = machine.CallPost("broken", nil)
+	c.Check(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *subnetSuite) TestCallGet(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI, http.StatusOK, `{"id": 1}`)
+
+	result, err := subnet.CallGet("", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	fields, err := result.GetMap()
+	c.Assert(err, jc.ErrorIsNil)
+	id, err := fields["id"].GetFloat64()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(id, gc.Equals, float64(1))
+}
+
+func (s *fileSuite) TestCallGet(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, fileResponse)
+	server.AddGetResponse("/MAAS/api/2.0/files/testing/", http.StatusOK, `{"filename": "testing"}`)
+	file, err := controller.GetFile("testing")
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := file.CallGet("", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	fields, err := result.GetMap()
+	c.Assert(err, jc.ErrorIsNil)
+	filename, err := fields["filename"].GetString()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(filename, gc.Equals, "testing")
+}
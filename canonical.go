@@ -0,0 +1,93 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/url"
+	"sort"
+)
+
+const upperhex = "0123456789ABCDEF"
+
+// isUnreserved reports whether the byte is one of the characters that RFC
+// 5849 section 3.6 leaves unencoded: ALPHA, DIGIT, "-", ".", "_" and "~".
+func isUnreserved(b byte) bool {
+	switch {
+	case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9':
+		return true
+	case b == '-', b == '.', b == '_', b == '~':
+		return true
+	}
+	return false
+}
+
+// percentEncode encodes the string as RFC 5849 section 3.6 requires. Every
+// byte of the UTF-8 encoding other than the unreserved characters is
+// written as "%" and two upper case hex digits. Unlike url.QueryEscape, a
+// space is "%20" rather than "+", and the result is the same as the OAuth
+// verification in MAAS computes.
+func percentEncode(s string) string {
+	for i := 0; i < len(s); i++ {
+		if !isUnreserved(s[i]) {
+			return string(appendPercentEncoded(make([]byte, 0, len(s)+8), s))
+		}
+	}
+	return s
+}
+
+// appendPercentEncoded appends the percentEncode encoding of s to dst.
+func appendPercentEncoded(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if isUnreserved(b) {
+			dst = append(dst, b)
+			continue
+		}
+		dst = append(dst, '%', upperhex[b>>4], upperhex[b&15])
+	}
+	return dst
+}
+
+// canonicalQuery encodes the values as a query string or form body with
+// percentEncode. The parameters are sorted by their encoded names, and the
+// values of a repeated parameter keep their order, as MAAS gives meaning
+// to it for some parameters. The same values always encode to the same
+// string, so what is signed is what is sent.
+func canonicalQuery(values url.Values) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return string(appendCanonicalQuery(nil, values))
+}
+
+// appendCanonicalQuery appends the canonicalQuery encoding of the values
+// to dst, so that a form body can be encoded straight into its buffer.
+func appendCanonicalQuery(dst []byte, values url.Values) []byte {
+	if len(values) == 0 {
+		return dst
+	}
+	type param struct {
+		encoded string
+		values  []string
+	}
+	params := make([]param, 0, len(values))
+	for key, list := range values {
+		params = append(params, param{percentEncode(key), list})
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].encoded < params[j].encoded
+	})
+	start := len(dst)
+	for _, p := range params {
+		for _, value := range p.values {
+			if len(dst) > start {
+				dst = append(dst, '&')
+			}
+			dst = append(dst, p.encoded...)
+			dst = append(dst, '=')
+			dst = appendPercentEncoded(dst, value)
+		}
+	}
+	return dst
+}
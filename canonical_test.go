@@ -0,0 +1,185 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type canonicalSuite struct{}
+
+var _ = gc.Suite(&canonicalSuite{})
+
+func (*canonicalSuite) TestPercentEncodeUnreserved(c *gc.C) {
+	unreserved := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~"
+	c.Check(percentEncode(unreserved), gc.Equals, unreserved)
+}
+
+func (*canonicalSuite) TestPercentEncodeEveryASCIIByte(c *gc.C) {
+	for b := 0; b < 128; b++ {
+		s := string([]byte{byte(b)})
+		expected := fmt.Sprintf("%%%02X", b)
+		if isUnreserved(byte(b)) {
+			expected = s
+		}
+		c.Check(percentEncode(s), gc.Equals, expected, gc.Commentf("byte %#x", b))
+	}
+}
+
+func (*canonicalSuite) TestPercentEncodeEdgeCharacters(c *gc.C) {
+	for _, test := range []struct {
+		in, out string
+	}{
+		{"", ""},
+		{" ", "%20"},
+		{"+", "%2B"},
+		{"*", "%2A"},
+		{"%", "%25"},
+		{"%20", "%2520"},
+		{"&", "%26"},
+		{"=", "%3D"},
+		{"/", "%2F"},
+		{"?", "%3F"},
+		{"#", "%23"},
+		{"!'()", "%21%27%28%29"},
+		{"a b+c", "a%20b%2Bc"},
+		{"&secret", "%26secret"},
+		{"é", "%C3%A9"},
+		{"☃", "%E2%98%83"},
+		{"\xff", "%FF"},
+		{"line\nbreak", "line%0Abreak"},
+	} {
+		c.Check(percentEncode(test.in), gc.Equals, test.out, gc.Commentf("%q", test.in))
+	}
+}
+
+func (*canonicalSuite) TestPercentEncodeRoundTrips(c *gc.C) {
+	for _, s := range []string{"a b+c", "100%", "é☃", "&=?#/", "\x00\xff"} {
+		decoded, err := url.QueryUnescape(percentEncode(s))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(decoded, gc.Equals, s)
+	}
+}
+
+func (*canonicalSuite) TestCanonicalQueryEmpty(c *gc.C) {
+	c.Check(canonicalQuery(nil), gc.Equals, "")
+	c.Check(canonicalQuery(url.Values{}), gc.Equals, "")
+}
+
+func (*canonicalSuite) TestCanonicalQuerySortsNames(c *gc.C) {
+	values := url.Values{
+		"zone":     {"default"},
+		"agent":    {"juju"},
+		"hostname": {"node one"},
+	}
+	c.Check(canonicalQuery(values), gc.Equals, "agent=juju&hostname=node%20one&zone=default")
+}
+
+func (*canonicalSuite) TestCanonicalQueryKeepsValueOrder(c *gc.C) {
+	values := url.Values{
+		"tags": {"virtual", "!ssd", "arm64"},
+		"arch": {"amd64"},
+	}
+	c.Check(canonicalQuery(values), gc.Equals, "arch=amd64&tags=virtual&tags=%21ssd&tags=arm64")
+}
+
+func (*canonicalSuite) TestCanonicalQueryIsDeterministic(c *gc.C) {
+	values := url.Values{}
+	for i := 0; i < 20; i++ {
+		values.Add(fmt.Sprintf("key %d", i), fmt.Sprintf("value+%d", i))
+	}
+	first := canonicalQuery(values)
+	for i := 0; i < 10; i++ {
+		c.Check(canonicalQuery(values), gc.Equals, first)
+	}
+}
+
+func (*canonicalSuite) TestCanonicalQueryParses(c *gc.C) {
+	values := url.Values{
+		"name":      {"a b+c&d=e"},
+		"user_data": {"IyEvYmluL3NoCg=="},
+		"empty":     {""},
+		"unicode":   {"café ☃"},
+	}
+	parsed, err := url.ParseQuery(canonicalQuery(values))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(parsed, jc.DeepEquals, values)
+}
+
+func (*canonicalSuite) TestHeaderModeIsCanonical(c *gc.C) {
+	token := &OAuthToken{
+		ConsumerKey: "con sumer",
+		TokenKey:    "tok+en",
+		TokenSecret: "sec&ret",
+	}
+	signer, err := NewPlainTestOAuthSigner(token, "")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/api/2.0/machines/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	header := request.Header.Get("Authorization")
+	c.Check(header, jc.Contains, `oauth_consumer_key="con%20sumer"`)
+	c.Check(header, jc.Contains, `oauth_token="tok%2Ben"`)
+	c.Check(header, jc.Contains, `oauth_signature="%26sec%26ret"`)
+
+	var names []string
+	for _, part := range strings.Split(strings.TrimPrefix(header, "OAuth "), ", ") {
+		names = append(names, strings.SplitN(part, "=", 2)[0])
+	}
+	c.Check(names, jc.DeepEquals, []string{
+		"oauth_consumer_key",
+		"oauth_nonce",
+		"oauth_signature",
+		"oauth_signature_method",
+		"oauth_timestamp",
+		"oauth_token",
+		"oauth_version",
+		"realm",
+	})
+}
+
+func (*canonicalSuite) TestQueryModeIsCanonical(c *gc.C) {
+	signer, err := NewPlainTextOAuthSignerWithMode(testOAuthToken, "", OAuthQueryMode)
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/api/2.0/machines/?hostname=a+b", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	c.Check(request.URL.RawQuery, jc.Contains, "hostname=a%20b&")
+	c.Check(request.URL.RawQuery, jc.Contains, "oauth_signature=%26secret&")
+	c.Check(request.URL.Query().Get("hostname"), gc.Equals, "a b")
+}
+
+func (*canonicalSuite) TestClientSendsCanonicalParams(c *gc.C) {
+	URI, err := url.Parse("/some/url/")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer("/some/url/?op=update", "ok", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Post(URI, "update", url.Values{"name": {"a b+c"}, "comment": {"~*"}}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(*server.requestContent, gc.Equals, "comment=~%2A&name=a%20b%2Bc")
+}
+
+func (*canonicalSuite) TestClientGetSendsCanonicalQuery(c *gc.C) {
+	URI, err := url.Parse("/some/url/")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer("/some/url/?hostname=a%20b&op=list", "ok", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.Get(URI, "list", url.Values{"hostname": {"a b"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "ok")
+}
@@ -0,0 +1,239 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// CircuitState is the state of the circuit of a CircuitBreaker for one
+// region.
+type CircuitState int
+
+const (
+	// CircuitClosed - requests are sent, and their failures counted.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen - requests fail at once with a CircuitOpenError.
+	CircuitOpen
+
+	// CircuitHalfOpen - a single request is sent to see whether the
+	// region has recovered. The others fail at once.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerOptions configures a CircuitBreaker.
+type CircuitBreakerOptions struct {
+	// Window is the number of most recent requests whose failure rate is
+	// looked at. Default: 20.
+	Window int
+
+	// MinRequests is the number of requests in the window needed before
+	// the circuit can open, so that a few early failures do not open it.
+	// Default: 10.
+	MinRequests int
+
+	// FailureRate is the fraction of the requests in the window that must
+	// fail for the circuit to open, between 0 and 1. Default: 0.5.
+	FailureRate float64
+
+	// OpenFor is how long the circuit stays open before a request is let
+	// through to try the region again. Default: 30 seconds.
+	OpenFor time.Duration
+
+	// OnStateChange, if set, is called when the circuit of a region
+	// changes state, for example to update metrics. It is called without
+	// locks held, but may be called concurrently.
+	OnStateChange func(baseURL string, from, to CircuitState)
+}
+
+// DefaultCircuitBreakerOptions returns the recommended options.
+func DefaultCircuitBreakerOptions() CircuitBreakerOptions {
+	return CircuitBreakerOptions{
+		Window:      20,
+		MinRequests: 10,
+		FailureRate: 0.5,
+		OpenFor:     30 * time.Second,
+	}
+}
+
+// Validate ensures that the options are in range.
+func (o *CircuitBreakerOptions) Validate() error {
+	if o.Window < 1 {
+		return errors.NotValidf("Window %d", o.Window)
+	}
+	if o.MinRequests < 1 || o.MinRequests > o.Window {
+		return errors.NotValidf("MinRequests %d with Window %d", o.MinRequests, o.Window)
+	}
+	if o.FailureRate <= 0 || o.FailureRate > 1 {
+		return errors.NotValidf("FailureRate %v", o.FailureRate)
+	}
+	if o.OpenFor <= 0 {
+		return errors.NotValidf("OpenFor %v", o.OpenFor)
+	}
+	return nil
+}
+
+// CircuitBreaker stops the Clients that share it from sending requests to
+// a region that is failing, so that services that depend on MAAS fail fast
+// during an outage rather than piling up timeouts. Each region, identified
+// by the scheme and host of its BaseURL, has its own circuit.
+//
+// A request fails if it cannot be sent or the response has a 5xx status.
+// When enough of the recent requests to a region fail, its circuit opens
+// and requests return a CircuitOpenError without being sent. Once OpenFor
+// has passed the circuit is half open, and the next request is sent. If it
+// succeeds the circuit closes, and if not it opens again.
+type CircuitBreaker struct {
+	options  CircuitBreakerOptions
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    CircuitState
+	outcomes []bool // true for failures, oldest first
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker returns a CircuitBreaker with the options.
+func NewCircuitBreaker(options CircuitBreakerOptions) (*CircuitBreaker, error) {
+	if err := options.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &CircuitBreaker{options: options, circuits: make(map[string]*circuit)}, nil
+}
+
+// State returns the state of the circuit for the region at baseURL.
+func (b *CircuitBreaker) State(baseURL string) CircuitState {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[circuitKey(parsed)]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// circuitKey returns the region of the URL.
+func circuitKey(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// allow returns an error if a request to the region may not be sent, and
+// otherwise whether the request is the probe of a half open circuit.
+func (b *CircuitBreaker) allow(key string, now time.Time) (probe bool, err error) {
+	b.mu.Lock()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	from := c.state
+	switch c.state {
+	case CircuitOpen:
+		until := c.openedAt.Add(b.options.OpenFor)
+		if now.Before(until) {
+			b.mu.Unlock()
+			return false, NewCircuitOpenError(key, until)
+		}
+		c.state = CircuitHalfOpen
+		c.probing = true
+		probe = true
+	case CircuitHalfOpen:
+		if c.probing {
+			b.mu.Unlock()
+			return false, NewCircuitOpenError(key, now)
+		}
+		c.probing = true
+		probe = true
+	}
+	to := c.state
+	b.mu.Unlock()
+	b.changed(key, from, to)
+	return probe, nil
+}
+
+// record counts the outcome of a request to the region. While the circuit
+// is half open only the outcome of the probe counts, as other requests in
+// flight were sent before the circuit opened.
+func (b *CircuitBreaker) record(key string, probe, failed bool, now time.Time) {
+	b.mu.Lock()
+	c := b.circuits[key]
+	from := c.state
+	switch c.state {
+	case CircuitHalfOpen:
+		if !probe {
+			break
+		}
+		c.probing = false
+		c.outcomes = nil
+		if failed {
+			c.state = CircuitOpen
+			c.openedAt = now
+		} else {
+			c.state = CircuitClosed
+		}
+	case CircuitClosed:
+		c.outcomes = append(c.outcomes, failed)
+		if len(c.outcomes) > b.options.Window {
+			c.outcomes = c.outcomes[1:]
+		}
+		if b.tripped(c.outcomes) {
+			c.state = CircuitOpen
+			c.openedAt = now
+			c.outcomes = nil
+		}
+	}
+	to := c.state
+	b.mu.Unlock()
+	b.changed(key, from, to)
+}
+
+func (b *CircuitBreaker) tripped(outcomes []bool) bool {
+	if len(outcomes) < b.options.MinRequests {
+		return false
+	}
+	failures := 0
+	for _, failed := range outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) >= b.options.FailureRate*float64(len(outcomes))
+}
+
+func (b *CircuitBreaker) changed(key string, from, to CircuitState) {
+	if from != to && b.options.OnStateChange != nil {
+		b.options.OnStateChange(key, from, to)
+	}
+}
+
+// requestFailed returns whether the outcome of a request counts against
+// its region.
+func requestFailed(response *http.Response, err error) bool {
+	return err != nil || response.StatusCode >= 500
+}
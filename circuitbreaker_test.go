@@ -0,0 +1,197 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type circuitBreakerSuite struct{}
+
+var _ = gc.Suite(&circuitBreakerSuite{})
+
+func (*circuitBreakerSuite) TestValidate(c *gc.C) {
+	for i, modify := range []func(*CircuitBreakerOptions){
+		func(o *CircuitBreakerOptions) { o.Window = 0 },
+		func(o *CircuitBreakerOptions) { o.MinRequests = 30 },
+		func(o *CircuitBreakerOptions) { o.FailureRate = 0 },
+		func(o *CircuitBreakerOptions) { o.FailureRate = 1.5 },
+		func(o *CircuitBreakerOptions) { o.OpenFor = 0 },
+	} {
+		c.Logf("test %d", i)
+		options := DefaultCircuitBreakerOptions()
+		modify(&options)
+		_, err := NewCircuitBreaker(options)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+	options := DefaultCircuitBreakerOptions()
+	c.Check(options.Validate(), jc.ErrorIsNil)
+}
+
+type stateChange struct {
+	baseURL  string
+	from, to CircuitState
+}
+
+func (*circuitBreakerSuite) TestStates(c *gc.C) {
+	var changes []stateChange
+	options := CircuitBreakerOptions{
+		Window:      4,
+		MinRequests: 2,
+		FailureRate: 0.5,
+		OpenFor:     time.Minute,
+		OnStateChange: func(baseURL string, from, to CircuitState) {
+			changes = append(changes, stateChange{baseURL, from, to})
+		},
+	}
+	breaker, err := NewCircuitBreaker(options)
+	c.Assert(err, jc.ErrorIsNil)
+	const key = "http://maas"
+	now := time.Date(2016, 9, 1, 10, 0, 0, 0, time.UTC)
+
+	// One failure is not enough requests to open the circuit.
+	allow := func(at time.Time) bool {
+		probe, err := breaker.allow(key, at)
+		c.Assert(err, jc.ErrorIsNil)
+		return probe
+	}
+	c.Check(allow(now), jc.IsFalse)
+	breaker.record(key, false, true, now)
+	c.Check(breaker.State("http://MAAS/MAAS/"), gc.Equals, CircuitClosed)
+
+	allow(now)
+	breaker.record(key, false, false, now)
+	c.Check(breaker.State(key), gc.Equals, CircuitOpen)
+
+	_, err = breaker.allow(key, now.Add(time.Second))
+	c.Check(err, jc.Satisfies, IsCircuitOpenError)
+	c.Check(err, gc.ErrorMatches, "circuit open for http://maas until 2016-09-01T10:01:00Z")
+
+	// Once OpenFor has passed, a single request is let through.
+	c.Check(allow(now.Add(time.Minute)), jc.IsTrue)
+	c.Check(breaker.State(key), gc.Equals, CircuitHalfOpen)
+	_, err = breaker.allow(key, now.Add(time.Minute))
+	c.Check(err, jc.Satisfies, IsCircuitOpenError)
+	breaker.record(key, true, true, now.Add(time.Minute))
+	c.Check(breaker.State(key), gc.Equals, CircuitOpen)
+
+	c.Check(allow(now.Add(2*time.Minute)), jc.IsTrue)
+	breaker.record(key, true, false, now.Add(2*time.Minute))
+	c.Check(breaker.State(key), gc.Equals, CircuitClosed)
+
+	c.Check(changes, jc.DeepEquals, []stateChange{
+		{key, CircuitClosed, CircuitOpen},
+		{key, CircuitOpen, CircuitHalfOpen},
+		{key, CircuitHalfOpen, CircuitOpen},
+		{key, CircuitOpen, CircuitHalfOpen},
+		{key, CircuitHalfOpen, CircuitClosed},
+	})
+	c.Check(CircuitHalfOpen.String(), gc.Equals, "half-open")
+}
+
+func (*circuitBreakerSuite) TestWindow(c *gc.C) {
+	options := CircuitBreakerOptions{Window: 3, MinRequests: 3, FailureRate: 0.6, OpenFor: time.Minute}
+	breaker, err := NewCircuitBreaker(options)
+	c.Assert(err, jc.ErrorIsNil)
+	now := time.Now()
+	// Only the last three outcomes count, and two of three is over 0.6.
+	for _, failed := range []bool{true, false, false, true, false, true} {
+		_, err := breaker.allow("http://maas", now)
+		c.Assert(err, jc.ErrorIsNil)
+		breaker.record("http://maas", false, failed, now)
+	}
+	c.Check(breaker.State("http://maas"), gc.Equals, CircuitOpen)
+}
+
+func (*circuitBreakerSuite) TestOnlyProbeCountsWhenHalfOpen(c *gc.C) {
+	options := CircuitBreakerOptions{Window: 1, MinRequests: 1, FailureRate: 1, OpenFor: time.Minute}
+	breaker, err := NewCircuitBreaker(options)
+	c.Assert(err, jc.ErrorIsNil)
+	const key = "http://maas"
+	now := time.Now()
+
+	// A slow request is sent while the circuit is closed.
+	_, err = breaker.allow(key, now)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = breaker.allow(key, now)
+	c.Assert(err, jc.ErrorIsNil)
+	breaker.record(key, false, true, now)
+	c.Assert(breaker.State(key), gc.Equals, CircuitOpen)
+
+	probe, err := breaker.allow(key, now.Add(time.Minute))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(probe, jc.IsTrue)
+
+	// The slow request finishing does not decide the probe's outcome.
+	breaker.record(key, false, false, now.Add(time.Minute))
+	c.Check(breaker.State(key), gc.Equals, CircuitHalfOpen)
+	_, err = breaker.allow(key, now.Add(time.Minute))
+	c.Check(err, jc.Satisfies, IsCircuitOpenError)
+
+	breaker.record(key, true, true, now.Add(time.Minute))
+	c.Check(breaker.State(key), gc.Equals, CircuitOpen)
+}
+
+func (*circuitBreakerSuite) TestClientFailsFast(c *gc.C) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/api/2.0/missing/" {
+			http.Error(w, "no such thing", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	options := DefaultCircuitBreakerOptions()
+	options.MinRequests = 3
+	breaker, err := NewCircuitBreaker(options)
+	c.Assert(err, jc.ErrorIsNil)
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.CircuitBreaker = breaker
+
+	// A 404 shows that the region is up.
+	_, err = client.Get(&url.URL{Path: "missing/"}, "", nil)
+	c.Check(err, gc.ErrorMatches, `(?s)ServerError: 404 Not Found.*`)
+	c.Check(breaker.State(server.URL), gc.Equals, CircuitClosed)
+
+	for i := 0; i < 2; i++ {
+		_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+		c.Check(err, gc.ErrorMatches, `(?s)ServerError: 500 Internal Server Error.*`)
+	}
+	c.Check(breaker.State(server.URL), gc.Equals, CircuitOpen)
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+	c.Check(err, jc.Satisfies, IsCircuitOpenError)
+	c.Check(requests, gc.Equals, 3)
+}
+
+func (*circuitBreakerSuite) TestControllerCircuitBreaker(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	breaker, err := NewCircuitBreaker(DefaultCircuitBreakerOptions())
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = NewController(ControllerArgs{
+		BaseURL:        server.URL,
+		APIKey:         "fake:as:key",
+		CircuitBreaker: breaker,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	key := circuitKey(&url.URL{Scheme: "http", Host: server.Server.Listener.Addr().String()})
+	breaker.mu.Lock()
+	outcomes := fmt.Sprint(breaker.circuits[key].outcomes)
+	breaker.mu.Unlock()
+	c.Check(outcomes, gc.Equals, "[false false]")
+}
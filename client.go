@@ -4,13 +4,17 @@
 package gomaasapi
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/rsa"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,6 +36,40 @@ const (
 type Client struct {
 	APIURL *url.URL
 	Signer OAuthSigner
+
+	// HTTPClient is used to send requests if set. Connections are reused
+	// according to its transport. If nil, a new connection is made for
+	// each request. See NewHTTPClient.
+	HTTPClient *http.Client
+
+	// Debug, if true, logs each request and response, including headers
+	// and bodies, at debug level. OAuth credentials, user data and other
+	// secrets are redacted so that the logs can be shared. Response bodies
+	// are read in full before they are returned, so streamed responses are
+	// buffered in this mode.
+	Debug bool
+
+	// Clock, if set, is used to wait before retrying requests and for the
+	// timestamps of requests signed by the signers in this package. If nil,
+	// WallClock is used.
+	Clock Clock
+
+	// Scheduler, if set, limits the requests in progress and orders the
+	// waiting ones by priority. It may be shared by several Clients. See
+	// RequestScheduler.
+	Scheduler *RequestScheduler
+
+	// CircuitBreaker, if set, fails requests at once while the region is
+	// failing. It may be shared by several Clients. See CircuitBreaker.
+	CircuitBreaker *CircuitBreaker
+
+	// Coalescer, if set, makes identical GET requests made at the same
+	// time share one request. It may be shared by Clients with the same
+	// API key. See GetCoalescer.
+	Coalescer *GetCoalescer
+
+	// recorder, if set, records the requests sent for Measure.
+	recorder *callRecorder
 }
 
 // ServerError is an http error (or at least, a non-2xx result) received from
@@ -52,6 +90,84 @@ func GetServerError(err error) (ServerError, bool) {
 	return svrErr, ok
 }
 
+// ProxyError is returned when the response is an HTML page rather than the
+// JSON that the API returns. This usually means that a proxy in front of
+// MAAS answered, as with a 502 page, or that Django returned its debug error
+// page. StatusCode is the status of the response, and Title is the title of
+// the page, if it has one.
+type ProxyError struct {
+	StatusCode int
+	Status     string
+	Title      string
+	Body       string
+}
+
+// Error implements error.
+func (e *ProxyError) Error() string {
+	message := fmt.Sprintf("expected JSON but got an HTML page, probably from a proxy: %s", e.Status)
+	if e.Title != "" && e.Title != e.Status {
+		message += fmt.Sprintf(" (%s)", e.Title)
+	}
+	return message
+}
+
+// GetProxyError returns the ProxyError in the chain of err, if there is one.
+func GetProxyError(err error) (*ProxyError, bool) {
+	for err != nil {
+		if perr, ok := err.(*ProxyError); ok {
+			return perr, true
+		}
+		wrapper, ok := err.(interface {
+			Underlying() error
+		})
+		if !ok {
+			break
+		}
+		err = wrapper.Underlying()
+	}
+	return nil, false
+}
+
+// IsProxyError returns true if err is, or wraps, a ProxyError.
+func IsProxyError(err error) bool {
+	_, ok := GetProxyError(err)
+	return ok
+}
+
+var htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// htmlSniffLength is how much of a body isHTMLResponse needs to see.
+const htmlSniffLength = 512
+
+// isHTMLResponse returns true if the response is an HTML page: the body
+// starts like one and the content type, if any, is text/html. The content
+// type alone is not enough, as MAAS returns plain text error messages
+// under Django's default content type of text/html.
+func isHTMLResponse(response *http.Response, body []byte) bool {
+	contentType := response.Header.Get("Content-Type")
+	if contentType != "" && !strings.HasPrefix(strings.ToLower(contentType), "text/html") {
+		return false
+	}
+	if len(body) > htmlSniffLength {
+		body = body[:htmlSniffLength]
+	}
+	start := strings.ToLower(strings.TrimSpace(string(body)))
+	return strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html")
+}
+
+func newProxyError(response *http.Response, body []byte) error {
+	var title string
+	if match := htmlTitle.FindSubmatch(body); match != nil {
+		title = strings.Join(strings.Fields(string(match[1])), " ")
+	}
+	return errors.Trace(&ProxyError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Title:      title,
+		Body:       string(body),
+	})
+}
+
 // readAndClose reads and closes the given ReadCloser.
 //
 // Trying to read from a nil simply returns nil, no error.
@@ -63,6 +179,27 @@ func readAndClose(stream io.ReadCloser) ([]byte, error) {
 	return ioutil.ReadAll(stream)
 }
 
+// maxPresizedBody is the largest Content-Length that readResponseBody
+// allocates for up front, so that a wrong header cannot make it allocate
+// much more than is sent.
+const maxPresizedBody = 64 << 20
+
+// readResponseBody reads and closes the body of the response. When the
+// length is known the buffer is allocated once, rather than grown and
+// copied as the body is read, which halves the memory used to read a
+// large listing.
+func readResponseBody(response *http.Response) ([]byte, error) {
+	if response.ContentLength <= 0 || response.ContentLength > maxPresizedBody {
+		return readAndClose(response.Body)
+	}
+	defer response.Body.Close()
+	buf := bytes.NewBuffer(make([]byte, 0, response.ContentLength+bytes.MinRead))
+	if _, err := buf.ReadFrom(response.Body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // dispatchRequest sends a request to the server, and interprets the response.
 // Client-side errors will return an empty response and a non-nil error.  For
 // server-side errors however (i.e. responses with a non 2XX status code), the
@@ -70,16 +207,25 @@ func readAndClose(stream io.ReadCloser) ([]byte, error) {
 // server's response.  If the server returns a 503 response with a 'Retry-after'
 // header, the request will be transparenty retried.
 func (client Client) dispatchRequest(request *http.Request) ([]byte, error) {
-	// First, store the request's body into a byte[] to be able to restore it
-	// after each request.
-	bodyContent, err := readAndClose(request.Body)
-	if err != nil {
-		return nil, err
+	// Requests made by http.NewRequest from an in-memory body can make a
+	// new reader of it for each attempt. Otherwise, store the request's
+	// body into a byte[] to be able to restore it after each request.
+	getBody := request.GetBody
+	if getBody == nil {
+		bodyContent, err := readAndClose(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		getBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(bodyContent)), nil
+		}
 	}
+	var err error
 	for retry := 0; retry < NumberOfRetries; retry++ {
 		// Restore body before issuing request.
-		newBody := ioutil.NopCloser(bytes.NewReader(bodyContent))
-		request.Body = newBody
+		if request.Body, err = getBody(); err != nil {
+			return nil, err
+		}
 		body, err := client.dispatchSingleRequest(request)
 		// If this is a 503 response with a non-void "Retry-After" header: wait
 		// as instructed and retry the request.
@@ -88,9 +234,7 @@ func (client Client) dispatchRequest(request *http.Request) ([]byte, error) {
 			if ok && serverError.StatusCode == http.StatusServiceUnavailable {
 				retry_time_int, errConv := strconv.Atoi(serverError.Header.Get(RetryAfterHeaderName))
 				if errConv == nil {
-					select {
-					case <-time.After(time.Duration(retry_time_int) * time.Second):
-					}
+					<-clockOrWall(client.Clock).After(time.Duration(retry_time_int) * time.Second)
 					continue
 				}
 			}
@@ -98,33 +242,129 @@ func (client Client) dispatchRequest(request *http.Request) ([]byte, error) {
 		return body, err
 	}
 	// Restore body before issuing request.
-	newBody := ioutil.NopCloser(bytes.NewReader(bodyContent))
-	request.Body = newBody
+	if request.Body, err = getBody(); err != nil {
+		return nil, err
+	}
 	return client.dispatchSingleRequest(request)
 }
 
 func (client Client) dispatchSingleRequest(request *http.Request) ([]byte, error) {
-	client.Signer.OAuthSign(request)
-	httpClient := http.Client{}
-	// See https://code.google.com/p/go/issues/detail?id=4677
-	// We need to force the connection to close each time so that we don't
-	// hit the above Go bug.
-	request.Close = true
-	response, err := httpClient.Do(request)
+	response, err := client.DoRaw(request)
 	if err != nil {
 		return nil, err
 	}
-	body, err := readAndClose(response.Body)
+	body, err := readResponseBody(response)
 	if err != nil {
 		return nil, err
 	}
+	if isHTMLResponse(response, body) {
+		return nil, newProxyError(response, body)
+	}
 	if response.StatusCode < 200 || response.StatusCode > 299 {
-		err := errors.Errorf("ServerError: %v (%s)", response.Status, body)
-		return body, errors.Trace(ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: string(body)})
+		return body, newServerError(response, body)
 	}
 	return body, nil
 }
 
+// DoRaw signs and sends the request, returning the response without reading
+// its body or interpreting its status code. It is intended for callers that
+// stream or proxy responses from MAAS; most callers should use Get or Post,
+// which also retry 503 responses and return ServerError for failures. The
+// caller must close the response body. The request accepts JSON unless it
+// already has an Accept header.
+func (client Client) DoRaw(request *http.Request) (*http.Response, error) {
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+	if request.Header.Get("Accept") == "" {
+		request.Header.Set("Accept", "application/json")
+	}
+	signer := client.Signer
+	if clocked, ok := signer.(clockedSigner); ok && client.Clock != nil {
+		signer = clocked.withClock(client.Clock)
+	}
+	if err := signRequest(signer, request); err != nil {
+		return nil, errors.Annotate(err, "signing request")
+	}
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+		// See https://code.google.com/p/go/issues/detail?id=4677
+		// We need to force the connection to close each time so that we don't
+		// hit the above Go bug.
+		request.Close = true
+	}
+	if client.Scheduler == nil {
+		return client.sendGuarded(httpClient, request)
+	}
+	apiPath := ""
+	if client.APIURL != nil {
+		apiPath = client.APIURL.Path
+	}
+	priority := requestPriority(request, apiPath)
+	if err := client.Scheduler.acquire(request.Context(), priority); err != nil {
+		return nil, errors.Annotate(err, "waiting to send request")
+	}
+	response, err := client.sendGuarded(httpClient, request)
+	if err != nil {
+		client.Scheduler.release()
+		return nil, err
+	}
+	response.Body = &scheduledBody{ReadCloser: response.Body, release: client.Scheduler.release}
+	return response, nil
+}
+
+// sendGuarded sends the request through the circuit breaker, if there is
+// one.
+func (client Client) sendGuarded(httpClient *http.Client, request *http.Request) (*http.Response, error) {
+	if client.CircuitBreaker == nil {
+		return client.send(httpClient, request)
+	}
+	clock := clockOrWall(client.Clock)
+	key := circuitKey(request.URL)
+	probe, err := client.CircuitBreaker.allow(key, clock.Now())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	response, err := client.send(httpClient, request)
+	client.CircuitBreaker.record(key, probe, requestFailed(response, err), clock.Now())
+	return response, err
+}
+
+// send sends the signed request, recording it for Measure.
+func (client Client) send(httpClient *http.Client, request *http.Request) (*http.Response, error) {
+	response, err := client.sendLogged(httpClient, request)
+	if client.recorder != nil {
+		client.recorder.record(response)
+	}
+	return response, err
+}
+
+// sendLogged sends the signed request, logging it in debug mode.
+func (client Client) sendLogged(httpClient *http.Client, request *http.Request) (*http.Response, error) {
+	if !client.Debug {
+		return httpClient.Do(request)
+	}
+	id, err := client.debugRequest(request)
+	if err != nil {
+		return nil, errors.Annotate(err, "reading request body")
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		logger.Debugf("response %d: error: %v", id, err)
+		return nil, err
+	}
+	if err := client.debugResponse(id, response); err != nil {
+		return nil, errors.Annotate(err, "reading response body")
+	}
+	return response, nil
+}
+
+func newServerError(response *http.Response, body []byte) error {
+	err := errors.Errorf("ServerError: %v (%s)", response.Status, body)
+	return errors.Trace(ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: string(body)})
+}
+
 // GetURL returns the URL to a given resource on the API, based on its URI.
 // The resource URI may be absolute or relative; either way the result is a
 // full absolute URL including the network part.
@@ -136,6 +376,66 @@ func (client Client) GetURL(uri *url.URL) *url.URL {
 // invocation (if you pass its name in "operation") or plain resource
 // retrieval (if you leave "operation" blank).
 func (client Client) Get(uri *url.URL, operation string, parameters url.Values) ([]byte, error) {
+	request, err := client.newGetRequest(uri, operation, parameters)
+	if err != nil {
+		return nil, err
+	}
+	if client.Coalescer != nil {
+		return client.Coalescer.do(request.URL.String(), func() ([]byte, error) {
+			return client.dispatchRequest(request)
+		})
+	}
+	return client.dispatchRequest(request)
+}
+
+// GetStream performs an HTTP "GET" to the API like Get, but returns the
+// response body unread so that large responses can be decoded
+// incrementally. The caller must close the returned body. Server errors
+// are returned as ServerError, and 503 responses with a 'Retry-After'
+// header are retried as for Get.
+func (client Client) GetStream(uri *url.URL, operation string, parameters url.Values) (io.ReadCloser, error) {
+	request, err := client.newGetRequest(uri, operation, parameters)
+	if err != nil {
+		return nil, err
+	}
+	for retry := 0; ; retry++ {
+		response, err := client.DoRaw(request)
+		if err != nil {
+			return nil, err
+		}
+		success := response.StatusCode >= 200 && response.StatusCode <= 299
+		if success {
+			// Look at the start of the body for an HTML page without
+			// reading the rest.
+			buffered := bufio.NewReaderSize(response.Body, htmlSniffLength)
+			start, _ := buffered.Peek(htmlSniffLength)
+			response.Body = struct {
+				io.Reader
+				io.Closer
+			}{buffered, response.Body}
+			if !isHTMLResponse(response, start) {
+				return response.Body, nil
+			}
+		}
+		body, err := readAndClose(response.Body)
+		if err != nil {
+			return nil, err
+		}
+		if isHTMLResponse(response, body) {
+			return nil, newProxyError(response, body)
+		}
+		if retry < NumberOfRetries && response.StatusCode == http.StatusServiceUnavailable {
+			retryTime, errConv := strconv.Atoi(response.Header.Get(RetryAfterHeaderName))
+			if errConv == nil {
+				<-clockOrWall(client.Clock).After(time.Duration(retryTime) * time.Second)
+				continue
+			}
+		}
+		return nil, newServerError(response, body)
+	}
+}
+
+func (client Client) newGetRequest(uri *url.URL, operation string, parameters url.Values) (*http.Request, error) {
 	if parameters == nil {
 		parameters = make(url.Values)
 	}
@@ -148,12 +448,8 @@ func (client Client) Get(uri *url.URL, operation string, parameters url.Values)
 		parameters.Set("op", operation)
 	}
 	queryUrl := client.GetURL(uri)
-	queryUrl.RawQuery = parameters.Encode()
-	request, err := http.NewRequest("GET", queryUrl.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	return client.dispatchRequest(request)
+	queryUrl.RawQuery = canonicalQuery(parameters)
+	return http.NewRequest("GET", queryUrl.String(), nil)
 }
 
 // writeMultiPartFiles writes the given files as parts of a multipart message
@@ -171,20 +467,34 @@ func writeMultiPartFiles(writer *multipart.Writer, files map[string][]byte) erro
 }
 
 // writeMultiPartParams writes the given parameters as parts of a multipart
-// message using the given writer.
+// message using the given writer. The names are written in sorted order so
+// that the message does not depend on map iteration.
 func writeMultiPartParams(writer *multipart.Writer, parameters url.Values) error {
-	for key, values := range parameters {
-		for _, value := range values {
-			fw, err := writer.CreateFormField(key)
-			if err != nil {
-				return err
-			}
-			buffer := bytes.NewBufferString(value)
-			io.Copy(fw, buffer)
+	keys := make([]string, 0, len(parameters))
+	for key := range parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := NewOrderedParams()
+	for _, key := range keys {
+		params.AddMany(key, parameters[key]...)
+	}
+	return writeMultiPartOrderedParams(writer, params)
+}
+
+// writeMultiPartOrderedParams writes the given parameters as parts of a
+// multipart message in order.
+func writeMultiPartOrderedParams(writer *multipart.Writer, parameters *OrderedParams) error {
+	for _, param := range parameters.params {
+		fw, err := writer.CreateFormField(param.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, param.Value); err != nil {
+			return err
 		}
 	}
 	return nil
-
 }
 
 // nonIdempotentRequestFiles implements the common functionality of PUT and
@@ -215,13 +525,7 @@ func (client Client) nonIdempotentRequestFiles(method string, uri *url.URL, para
 // nonIdempotentRequest implements the common functionality of PUT and POST
 // requests (but not GET or DELETE requests).
 func (client Client) nonIdempotentRequest(method string, uri *url.URL, parameters url.Values) ([]byte, error) {
-	url := client.GetURL(uri)
-	request, err := http.NewRequest(method, url.String(), strings.NewReader(string(parameters.Encode())))
-	if err != nil {
-		return nil, err
-	}
-	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	return client.dispatchRequest(request)
+	return client.Send(method, uri, parameters, FormSerializer)
 }
 
 // Post performs an HTTP "POST" to the API.  This may be either an API method
@@ -229,13 +533,56 @@ func (client Client) nonIdempotentRequest(method string, uri *url.URL, parameter
 // retrieval (if you leave "operation" blank).
 func (client Client) Post(uri *url.URL, operation string, parameters url.Values, files map[string][]byte) ([]byte, error) {
 	queryParams := url.Values{"op": {operation}}
-	uri.RawQuery = queryParams.Encode()
+	uri.RawQuery = canonicalQuery(queryParams)
 	if files != nil {
 		return client.nonIdempotentRequestFiles("POST", uri, parameters, files)
 	}
 	return client.nonIdempotentRequest("POST", uri, parameters)
 }
 
+// PostWithoutOp performs an HTTP "POST" to the API without an op parameter,
+// as the 2.0 API expects when an object is created in a collection. Post
+// always sends the parameter, even when the operation is blank.
+func (client Client) PostWithoutOp(uri *url.URL, parameters url.Values, files map[string][]byte) ([]byte, error) {
+	uri.RawQuery = ""
+	if files != nil {
+		return client.nonIdempotentRequestFiles("POST", uri, parameters, files)
+	}
+	return client.nonIdempotentRequest("POST", uri, parameters)
+}
+
+// PostOrdered performs an HTTP "POST" to the API like Post, sending the
+// parameters in the order they were added. If there are files, the
+// parameters are sent as multipart parts, in order, after the files. An
+// empty operation is left out.
+func (client Client) PostOrdered(uri *url.URL, operation string, parameters *OrderedParams, files map[string][]byte) ([]byte, error) {
+	uri.RawQuery = ""
+	if operation != "" {
+		uri.RawQuery = canonicalQuery(url.Values{"op": {operation}})
+	}
+	if parameters == nil {
+		parameters = NewOrderedParams()
+	}
+	if files == nil {
+		return client.Send("POST", uri, parameters, FormSerializer)
+	}
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	if err := writeMultiPartFiles(writer, files); err != nil {
+		return nil, err
+	}
+	if err := writeMultiPartOrderedParams(writer, parameters); err != nil {
+		return nil, err
+	}
+	writer.Close()
+	request, err := http.NewRequest("POST", client.GetURL(uri).String(), buf)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return client.dispatchRequest(request)
+}
+
 // Put updates an object on the API, using an HTTP "PUT" request.
 func (client Client) Put(uri *url.URL, parameters url.Values) ([]byte, error) {
 	return client.nonIdempotentRequest("PUT", uri, parameters)
@@ -243,16 +590,20 @@ func (client Client) Put(uri *url.URL, parameters url.Values) ([]byte, error) {
 
 // Delete deletes an object on the API, using an HTTP "DELETE" request.
 func (client Client) Delete(uri *url.URL) error {
+	_, err := client.DeleteWithResponse(uri)
+	return err
+}
+
+// DeleteWithResponse deletes an object on the API like Delete, and returns
+// the body of the response. Most deletes return an empty body, but some
+// describe what was deleted, such as the addresses that were released.
+func (client Client) DeleteWithResponse(uri *url.URL) ([]byte, error) {
 	url := client.GetURL(uri)
 	request, err := http.NewRequest("DELETE", url.String(), strings.NewReader(""))
 	if err != nil {
-		return err
-	}
-	_, err = client.dispatchRequest(request)
-	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return client.dispatchRequest(request)
 }
 
 // Anonymous "signature method" implementation.
@@ -266,11 +617,26 @@ func (signer anonSigner) OAuthSign(request *http.Request) error {
 var _ OAuthSigner = anonSigner{}
 
 func composeAPIURL(BaseURL string, apiVersion string) (*url.URL, error) {
-	baseurl := EnsureTrailingSlash(BaseURL)
+	baseurl := EnsureTrailingSlash(escapeIPv6Zone(BaseURL))
 	apiurl := fmt.Sprintf("%sapi/%s/", baseurl, apiVersion)
 	return url.Parse(apiurl)
 }
 
+// rawZonePattern matches the start of a URL whose host is an IPv6 literal
+// with a zone, such as "http://[fe80::1%eth0]:5240/MAAS/".
+var rawZonePattern = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*://(?:[^/@]*@)?\[[0-9a-fA-F:.]+)%([^\]]*\])`)
+
+// escapeIPv6Zone percent-encodes the "%" that starts the zone of an IPv6
+// literal host, which url.Parse requires and people rarely write. A zone
+// that is already encoded, as in "[fe80::1%25eth0]", is left alone.
+func escapeIPv6Zone(baseURL string) string {
+	match := rawZonePattern.FindStringSubmatchIndex(baseURL)
+	if match == nil || strings.HasPrefix(baseURL[match[4]:], "25") {
+		return baseURL
+	}
+	return baseURL[:match[3]] + "%25" + baseURL[match[4]:]
+}
+
 // NewAnonymousClient creates a client that issues anonymous requests.
 // BaseURL should refer to the root of the MAAS server path, e.g.
 // http://my.maas.server.example.com/MAAS/
@@ -290,6 +656,12 @@ func NewAnonymousClient(BaseURL string, apiVersion string) (*Client, error) {
 // http://my.maas.server.example.com/MAAS/
 // apiVersion should contain the version of the MAAS API that you want to use.
 func NewAuthenticatedClient(BaseURL string, apiKey string, apiVersion string) (*Client, error) {
+	return newAuthenticatedClient(BaseURL, apiKey, apiVersion, OAuthHeaderMode, nil, nil)
+}
+
+// newAuthenticatedClient signs requests with PLAINTEXT, or with RSA-SHA1
+// if signingKey is not nil.
+func newAuthenticatedClient(BaseURL string, apiKey string, apiVersion string, mode OAuthSignatureMode, nonces NonceSource, signingKey *rsa.PrivateKey) (*Client, error) {
 	elements := strings.Split(apiKey, ":")
 	if len(elements) != 3 {
 		errString := fmt.Sprintf("invalid API key %q; expected \"<consumer secret>:<token key>:<token secret>\"", apiKey)
@@ -302,7 +674,13 @@ func NewAuthenticatedClient(BaseURL string, apiKey string, apiVersion string) (*
 		TokenKey:       elements[1],
 		TokenSecret:    elements[2],
 	}
-	signer, err := NewPlainTestOAuthSigner(token, "MAAS API")
+	var signer OAuthSigner
+	var err error
+	if signingKey != nil {
+		signer, err = NewRSASHA1OAuthSigner(token, "MAAS API", signingKey, mode, nonces)
+	} else {
+		signer, err = NewPlainTextOAuthSignerWithNonces(token, "MAAS API", mode, nonces)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -6,8 +6,12 @@ package gomaasapi
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 
@@ -173,6 +177,231 @@ func (suite *ClientSuite) TestClientGetFormatsOperationAsGetParameter(c *gc.C) {
 	c.Check(string(result), gc.Equals, expectedResult)
 }
 
+func newHTMLServer(status int, contentType, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+const badGatewayPage = `<html>
+<head><title>502 Bad
+  Gateway</title></head>
+<body><center><h1>502 Bad Gateway</h1></center></body>
+</html>`
+
+func (suite *ClientSuite) TestClientSendsAcceptJSON(c *gc.C) {
+	var accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		fmt.Fprint(w, "{}")
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Get(&url.URL{Path: "/some/url"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(accept, gc.Equals, "application/json")
+
+	request, err := http.NewRequest("GET", server.URL, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Accept", "text/plain")
+	response, err := client.DoRaw(request)
+	c.Assert(err, jc.ErrorIsNil)
+	response.Body.Close()
+	c.Check(accept, gc.Equals, "text/plain")
+}
+
+func (suite *ClientSuite) TestClientGetReturnsProxyError(c *gc.C) {
+	server := newHTMLServer(http.StatusBadGateway, "text/html", badGatewayPage)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.Get(&url.URL{Path: "/some/url"}, "", nil)
+	c.Check(result, gc.IsNil)
+	c.Assert(err, jc.Satisfies, IsProxyError)
+	_, ok := GetServerError(err)
+	c.Check(ok, jc.IsFalse)
+	proxyErr, _ := GetProxyError(err)
+	c.Check(proxyErr.StatusCode, gc.Equals, http.StatusBadGateway)
+	c.Check(proxyErr.Title, gc.Equals, "502 Bad Gateway")
+	c.Check(proxyErr.Body, gc.Equals, badGatewayPage)
+	c.Check(err, gc.ErrorMatches, "expected JSON but got an HTML page, probably from a proxy: 502 Bad Gateway")
+}
+
+func (suite *ClientSuite) TestClientGetReturnsProxyErrorForSuccess(c *gc.C) {
+	// Django's debug page, or a captive portal, may come back with 200.
+	server := newHTMLServer(http.StatusOK, "text/html; charset=utf-8", "<html><title>Sign in</title></html>")
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Get(&url.URL{Path: "/some/url"}, "", nil)
+	c.Assert(err, jc.Satisfies, IsProxyError)
+	c.Check(err, gc.ErrorMatches, `expected JSON but got an HTML page, probably from a proxy: 200 OK \(Sign in\)`)
+}
+
+func (suite *ClientSuite) TestClientProxyErrorWithoutContentType(c *gc.C) {
+	server := newHTMLServer(http.StatusInternalServerError, "", "\n<!DOCTYPE html><html><body>Traceback</body></html>")
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Get(&url.URL{Path: "/some/url"}, "", nil)
+	c.Assert(err, jc.Satisfies, IsProxyError)
+}
+
+func (suite *ClientSuite) TestClientPlainTextHTMLContentTypeIsServerError(c *gc.C) {
+	// Django sends MAAS error messages as text/html, its default.
+	for _, status := range []int{http.StatusNotFound, http.StatusBadRequest} {
+		server := newHTMLServer(status, "text/html; charset=utf-8", "No Tag matches the given query.")
+		client, err := NewAnonymousClient(server.URL, "1.0")
+		c.Assert(err, jc.ErrorIsNil)
+
+		_, err = client.Get(&url.URL{Path: "/some/url"}, "", nil)
+		c.Check(err, gc.Not(jc.Satisfies), IsProxyError)
+		svrErr, ok := GetServerError(err)
+		c.Assert(ok, jc.IsTrue)
+		c.Check(svrErr.StatusCode, gc.Equals, status)
+		c.Check(svrErr.BodyMessage, gc.Equals, "No Tag matches the given query.")
+
+		_, err = client.GetStream(&url.URL{Path: "/some/url"}, "", nil)
+		c.Check(err, gc.Not(jc.Satisfies), IsProxyError)
+		server.Close()
+	}
+}
+
+func (suite *ClientSuite) TestClientGetStreamReturnsPlainTextBody(c *gc.C) {
+	server := newHTMLServer(http.StatusOK, "text/html", "plain text")
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	body, err := client.GetStream(&url.URL{Path: "/some/url"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	defer body.Close()
+	content, err := ioutil.ReadAll(body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "plain text")
+}
+
+func (suite *ClientSuite) TestClientGetStreamReturnsProxyError(c *gc.C) {
+	server := newHTMLServer(http.StatusOK, "text/html", badGatewayPage)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	body, err := client.GetStream(&url.URL{Path: "/some/url"}, "", nil)
+	c.Check(body, gc.IsNil)
+	c.Assert(err, jc.Satisfies, IsProxyError)
+}
+
+func (suite *ClientSuite) TestControllerErrorWrapsProxyError(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusBadGateway, badGatewayPage)
+	server.Start()
+	defer server.Close()
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = controller.Zones()
+	c.Check(err, jc.Satisfies, IsUnexpectedError)
+	c.Check(err, jc.Satisfies, IsProxyError)
+}
+
+func (suite *ClientSuite) TestClientGetStreamReturnsBody(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	expectedResult := "expected:result"
+	server := newSingleServingServer(URI.String()+"?op=list", expectedResult, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	body, err := client.GetStream(URI, "list", nil)
+
+	c.Assert(err, jc.ErrorIsNil)
+	result, err := readAndClose(body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, expectedResult)
+}
+
+func (suite *ClientSuite) TestClientGetStreamReturnsServerError(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer(URI.String(), "no such thing", http.StatusNotFound)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	body, err := client.GetStream(URI, "", nil)
+
+	c.Check(body, gc.IsNil)
+	svrError, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrError.StatusCode, gc.Equals, http.StatusNotFound)
+	c.Check(svrError.BodyMessage, gc.Equals, "no such thing")
+}
+
+func (suite *ClientSuite) TestClientGetStreamRetries503(c *gc.C) {
+	URI, err := url.Parse("/some/url/")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newFlakyServer(URI.String(), 503, NumberOfRetries)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	body, err := client.GetStream(URI, "", nil)
+
+	c.Assert(err, jc.ErrorIsNil)
+	body.Close()
+	c.Check(*server.nbRequests, gc.Equals, NumberOfRetries+1)
+}
+
+func (suite *ClientSuite) TestClientDoRawReturnsUnreadResponse(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "not here", http.StatusNotFound)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	response, err := client.DoRaw(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(response.StatusCode, gc.Equals, http.StatusNotFound)
+	body, err := readAndClose(response.Body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(body), gc.Equals, "not here")
+}
+
+func (suite *ClientSuite) TestClientDoRawSignsRequest(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "content", http.StatusOK)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "the:api:key", "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	response, err := client.DoRaw(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	response.Body.Close()
+	c.Check(request.Header.Get("Authorization"), gc.Matches, "^OAuth .*")
+}
+
 func (suite *ClientSuite) TestClientPostSendsRequestWithParams(c *gc.C) {
 	URI, err := url.Parse("/some/url")
 	c.Assert(err, jc.ErrorIsNil)
@@ -237,6 +466,125 @@ func (suite *ClientSuite) TestClientPostSendsMultipartRequest(c *gc.C) {
 	c.Check(receivedFileContent, jc.DeepEquals, fileContent)
 }
 
+func (suite *ClientSuite) TestClientPostWithoutOpSendsNoOp(c *gc.C) {
+	URI, err := url.Parse("/some/url/?op=stale")
+	c.Assert(err, jc.ErrorIsNil)
+	expectedResult := "expected:result"
+	params := url.Values{"test": {"123"}}
+	server := newSingleServingServer("/some/url/", expectedResult, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.PostWithoutOp(URI, params, nil)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, expectedResult)
+	postedValues, err := url.ParseQuery(*server.requestContent)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(postedValues, jc.DeepEquals, params)
+}
+
+func (suite *ClientSuite) TestClientPostWithoutOpSendsMultipartRequest(c *gc.C) {
+	URI, err := url.Parse("/some/url/")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer(URI.String(), "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	fileContent := []byte("content")
+
+	_, err = client.PostWithoutOp(URI, nil, map[string][]byte{"testfile": fileContent})
+
+	c.Assert(err, jc.ErrorIsNil)
+	receivedFileContent, err := extractFileContent(*server.requestContent, server.requestHeader, URI.String(), "testfile")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(receivedFileContent, jc.DeepEquals, fileContent)
+}
+
+func (suite *ClientSuite) TestClientPostOrderedKeepsOrder(c *gc.C) {
+	URI, err := url.Parse("/some/url/")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer(URI.String()+"?op=allocate", "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	params := NewOrderedParams()
+	params.Add("zone", "z1")
+	params.AddMany("not_tags", "b", "a")
+	params.Add("arch", "amd64")
+
+	_, err = client.PostOrdered(URI, "allocate", params, nil)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(*server.requestContent, gc.Equals, "zone=z1&not_tags=b&not_tags=a&arch=amd64")
+	c.Check(server.requestHeader.Get("Content-Type"), gc.Equals, "application/x-www-form-urlencoded")
+}
+
+// multipartFieldNames returns the names of the form fields of a multipart
+// request body, in the order they were sent.
+func multipartFieldNames(c *gc.C, requestContent string, requestHeader http.Header) []string {
+	_, mediaParams, err := mime.ParseMediaType(requestHeader.Get("Content-Type"))
+	c.Assert(err, jc.ErrorIsNil)
+	reader := multipart.NewReader(strings.NewReader(requestContent), mediaParams["boundary"])
+	var names []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return names
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		if part.FileName() == "" {
+			value, err := ioutil.ReadAll(part)
+			c.Assert(err, jc.ErrorIsNil)
+			names = append(names, part.FormName()+"="+string(value))
+		}
+	}
+}
+
+func (suite *ClientSuite) TestClientPostOrderedMultipartKeepsOrder(c *gc.C) {
+	URI, err := url.Parse("/some/url/")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer(URI.String(), "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	params := NewOrderedParams()
+	params.Add("mac_addresses", "52:54:00:00:00:02")
+	params.Add("hostname", "node")
+	params.Add("mac_addresses", "52:54:00:00:00:01")
+	fileContent := []byte("content")
+
+	_, err = client.PostOrdered(URI, "", params, map[string][]byte{"testfile": fileContent})
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(multipartFieldNames(c, *server.requestContent, *server.requestHeader), jc.DeepEquals, []string{
+		"mac_addresses=52:54:00:00:00:02",
+		"hostname=node",
+		"mac_addresses=52:54:00:00:00:01",
+	})
+	receivedFileContent, err := extractFileContent(*server.requestContent, server.requestHeader, URI.String(), "testfile")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(receivedFileContent, jc.DeepEquals, fileContent)
+}
+
+func (suite *ClientSuite) TestClientPostMultipartParamsSorted(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer(URI.String()+"?op=add", "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	params := url.Values{"zeta": {"1"}, "alpha": {"2", "3"}, "mu": {"4"}}
+
+	_, err = client.Post(URI, "add", params, map[string][]byte{"testfile": []byte("content")})
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(multipartFieldNames(c, *server.requestContent, *server.requestHeader), jc.DeepEquals, []string{
+		"alpha=2", "alpha=3", "mu=4", "zeta=1",
+	})
+}
+
 func (suite *ClientSuite) TestClientPutSendsRequest(c *gc.C) {
 	URI, err := url.Parse("/some/url")
 	c.Assert(err, jc.ErrorIsNil)
@@ -268,6 +616,36 @@ func (suite *ClientSuite) TestClientDeleteSendsRequest(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 }
 
+func (suite *ClientSuite) TestClientDeleteWithResponseReturnsBody(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	expectedResult := `{"released": ["10.0.0.5"]}`
+	server := newSingleServingServer(URI.String(), expectedResult, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.DeleteWithResponse(URI)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, expectedResult)
+}
+
+func (suite *ClientSuite) TestClientDeleteWithResponseReturnsServerError(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer(URI.String(), "in use", http.StatusConflict)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.DeleteWithResponse(URI)
+
+	svrError, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrError.StatusCode, gc.Equals, http.StatusConflict)
+}
+
 func (suite *ClientSuite) TestNewAnonymousClientEnsuresTrailingSlash(c *gc.C) {
 	client, err := NewAnonymousClient("http://example.com/", "1.0")
 	c.Assert(err, jc.ErrorIsNil)
@@ -317,3 +695,42 @@ func (suite *ClientSuite) TestcomposeAPIURLReturnsURL(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(expectedURL, jc.DeepEquals, apiurl)
 }
+
+func (suite *ClientSuite) TestComposeAPIURLIPv6(c *gc.C) {
+	for i, test := range []struct {
+		baseURL, host, apiURL string
+	}{{
+		baseURL: "http://[2001:db8::1]:5240/MAAS",
+		host:    "[2001:db8::1]:5240",
+		apiURL:  "http://[2001:db8::1]:5240/MAAS/api/2.0/",
+	}, {
+		baseURL: "http://[fe80::1%eth0]:5240/MAAS/",
+		host:    "[fe80::1%eth0]:5240",
+		apiURL:  "http://[fe80::1%25eth0]:5240/MAAS/api/2.0/",
+	}, {
+		baseURL: "http://[fe80::1%25eth0]/MAAS/",
+		host:    "[fe80::1%eth0]",
+		apiURL:  "http://[fe80::1%25eth0]/MAAS/api/2.0/",
+	}, {
+		baseURL: "https://user@[fe80::1%br-maas]/MAAS/",
+		host:    "[fe80::1%br-maas]",
+		apiURL:  "https://user@[fe80::1%25br-maas]/MAAS/api/2.0/",
+	}} {
+		c.Logf("test %d", i)
+		apiURL, err := composeAPIURL(test.baseURL, "2.0")
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(apiURL.Host, gc.Equals, test.host)
+		c.Check(apiURL.String(), gc.Equals, test.apiURL)
+	}
+}
+
+func (suite *ClientSuite) TestGetURLIPv6(c *gc.C) {
+	client, err := NewAnonymousClient("http://[fe80::1%eth0]:5240/MAAS/", "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	uri, err := url.Parse("/MAAS/api/2.0/machines/4y3ha3/")
+	c.Assert(err, jc.ErrorIsNil)
+	URL := client.GetURL(uri)
+	c.Check(URL.Hostname(), gc.Equals, "fe80::1%eth0")
+	c.Check(URL.Port(), gc.Equals, "5240")
+	c.Check(URL.String(), gc.Equals, "http://[fe80::1%25eth0]:5240/MAAS/api/2.0/machines/4y3ha3/")
+}
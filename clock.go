@@ -0,0 +1,44 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"time"
+)
+
+// Clock provides the time for the client and controller: the timestamps of
+// signed requests, the waits before retrying requests, and the polling
+// intervals of the helpers that wait for machines to change status. Tests
+// can supply a Clock that advances time without sleeping.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current
+	// time on the returned channel.
+	After(time.Duration) <-chan time.Time
+}
+
+// WallClock is the Clock that uses the system time.
+var WallClock Clock = wallClock{}
+
+type wallClock struct{}
+
+// Now implements Clock.
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements Clock.
+func (wallClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// clockOrWall returns the clock, or WallClock if it is nil.
+func clockOrWall(clock Clock) Clock {
+	if clock == nil {
+		return WallClock
+	}
+	return clock
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+// testClock is a Clock whose waits finish at once, moving its time on by
+// the duration waited for.
+type testClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2016, 9, 1, 10, 0, 0, 0, time.UTC)}
+}
+
+// Now implements Clock.
+func (clock *testClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+// After implements Clock.
+func (clock *testClock) After(d time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(d)
+	clock.waits = append(clock.waits, d)
+	fired := make(chan time.Time, 1)
+	fired <- clock.now
+	return fired
+}
+
+func (clock *testClock) Waits() []time.Duration {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return append([]time.Duration(nil), clock.waits...)
+}
+
+type clockSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&clockSuite{})
+
+func (*clockSuite) TestWallClock(c *gc.C) {
+	before := time.Now()
+	now := WallClock.Now()
+	c.Check(now.Before(before), jc.IsFalse)
+	select {
+	case <-WallClock.After(0):
+	case <-time.After(time.Second):
+		c.Fatalf("timed out")
+	}
+}
+
+func (*clockSuite) TestClockOrWall(c *gc.C) {
+	c.Check(clockOrWall(nil), gc.Equals, WallClock)
+	clock := newTestClock()
+	c.Check(clockOrWall(clock), gc.Equals, clock)
+}
+
+func (*clockSuite) TestClientRetryWaitsOnClock(c *gc.C) {
+	URI := "/some/url/"
+	server := newFlakyServer(URI, http.StatusServiceUnavailable, 2)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	clock := newTestClock()
+	client.Clock = clock
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(clock.Waits(), jc.DeepEquals, []time.Duration{0, 0})
+}
+
+func (*clockSuite) TestSignatureTimestampFromClock(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "ok", http.StatusOK)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "a:b:c", "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	clock := newTestClock()
+	client.Clock = clock
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+	c.Assert(err, jc.ErrorIsNil)
+	timestamp := fmt.Sprintf(`oauth_timestamp="%d"`, clock.Now().Unix())
+	c.Check((*server.requestHeader).Get("Authorization"), jc.Contains, timestamp)
+}
+
+func (s *clockSuite) TestControllerPollsOnClock(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	clock := newTestClock()
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+		Clock:   clock,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Allocated", "")+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Deploying", "")+"]")
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Deployed", "")+"]")
+
+	outcomes := controller.DeployMany(context.Background(), []MachineSpec{{Machine: machines[0]}})
+	c.Assert(outcomes, gc.HasLen, 1)
+	c.Assert(outcomes[0].Err, jc.ErrorIsNil)
+	// The deployment took two polling intervals of simulated time.
+	c.Check(clock.Waits(), jc.DeepEquals, []time.Duration{deployPollInterval, deployPollInterval})
+	c.Check(outcomes[0].Started, gc.Equals, time.Date(2016, 9, 1, 10, 0, 0, 0, time.UTC))
+	c.Check(outcomes[0].Deployment, gc.Equals, 2*deployPollInterval)
+	c.Check(outcomes[0].Elapsed, gc.Equals, 2*deployPollInterval)
+}
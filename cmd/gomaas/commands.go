@@ -0,0 +1,220 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+)
+
+// command is a subcommand of gomaas. Its run function is given the
+// arguments after the command name.
+type command struct {
+	name  string
+	usage string
+	run   func(controller gomaasapi.Controller, args []string, stdout io.Writer) error
+}
+
+var commands = []command{{
+	name:  "machines",
+	usage: "machines [-hostname names] [-zone zone] [-tags tags]",
+	run:   listMachines,
+}, {
+	name:  "allocate",
+	usage: "allocate [-hostname name] [-arch arch] [-zone zone] [-tags tags] [-comment text]",
+	run:   allocateMachine,
+}, {
+	name:  "deploy",
+	usage: "deploy [-series series] [-kernel kernel] [-force] [-comment text] system-id",
+	run:   deployMachine,
+}, {
+	name:  "release",
+	usage: "release [-comment text] system-id...",
+	run:   releaseMachines,
+}, {
+	name:  "tag",
+	usage: "tag [-remove] tag system-id...",
+	run:   tagMachines,
+}}
+
+// run runs the command named by the first of the args.
+func run(controller gomaasapi.Controller, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return errors.Annotate(cmd.run(controller, args[1:], stdout), cmd.name)
+		}
+	}
+	return errors.Errorf("unknown command %q", args[0])
+}
+
+// splitList splits a comma separated list, ignoring empty items.
+func splitList(list string) []string {
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// parseFlags parses the flags of the command, returning an error if the
+// number of arguments left is less than min, or more than max when max is
+// not negative.
+func parseFlags(flags *flag.FlagSet, args []string, min, max int) error {
+	if err := flags.Parse(args); err != nil {
+		return errors.Trace(err)
+	}
+	switch n := flags.NArg(); {
+	case n < min:
+		return errors.New("not enough arguments")
+	case max >= 0 && n > max:
+		return errors.Errorf("unexpected arguments %q", flags.Args()[max:])
+	}
+	return nil
+}
+
+func listMachines(controller gomaasapi.Controller, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("machines", flag.ContinueOnError)
+	hostnames := flags.String("hostname", "", "only machines with these `names`")
+	zone := flags.String("zone", "", "only machines in the `zone`")
+	tags := flags.String("tags", "", "only machines with all these `tags`")
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return errors.Trace(err)
+	}
+	result, err := controller.SearchMachines(gomaasapi.SearchMachinesArgs{
+		Hostnames: splitList(*hostnames),
+		Zone:      *zone,
+		Tags:      splitList(*tags),
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SYSTEM ID\tHOSTNAME\tSTATUS\tPOWER\tZONE")
+	for _, m := range result.Machines {
+		zoneName := ""
+		if z := m.Zone(); z != nil {
+			zoneName = z.Name()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.SystemID(), m.Hostname(), m.StatusName(), m.PowerState(), zoneName)
+	}
+	return errors.Trace(w.Flush())
+}
+
+func allocateMachine(controller gomaasapi.Controller, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("allocate", flag.ContinueOnError)
+	hostname := flags.String("hostname", "", "allocate the machine with the `name`")
+	arch := flags.String("arch", "", "allocate a machine of the `architecture`")
+	zone := flags.String("zone", "", "allocate a machine in the `zone`")
+	tags := flags.String("tags", "", "allocate a machine with all these `tags`")
+	comment := flags.String("comment", "", "record the `text` in the machine events")
+	if err := parseFlags(flags, args, 0, 0); err != nil {
+		return errors.Trace(err)
+	}
+	machine, _, err := controller.AllocateMachine(gomaasapi.AllocateMachineArgs{
+		Hostname:     *hostname,
+		Architecture: *arch,
+		Zone:         *zone,
+		Tags:         splitList(*tags),
+		Comment:      *comment,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Fprintf(stdout, "%s %s\n", machine.SystemID(), machine.Hostname())
+	return nil
+}
+
+func deployMachine(controller gomaasapi.Controller, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("deploy", flag.ContinueOnError)
+	series := flags.String("series", "", "deploy the `series`, such as xenial")
+	kernel := flags.String("kernel", "", "deploy with the `kernel`, such as hwe-16.04")
+	force := flags.Bool("force", false, "deploy even if the power state is unknown")
+	comment := flags.String("comment", "", "record the `text` in the machine events")
+	if err := parseFlags(flags, args, 1, 1); err != nil {
+		return errors.Trace(err)
+	}
+	machine, err := machineByID(controller, flags.Arg(0))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := gomaasapi.CheckPowerKnown(machine, *force); err != nil {
+		return errors.Trace(err)
+	}
+	err = machine.Start(gomaasapi.StartArgs{
+		DistroSeries: *series,
+		Kernel:       *kernel,
+		Comment:      *comment,
+		CheckImage:   true,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Fprintf(stdout, "%s %s\n", machine.SystemID(), machine.StatusName())
+	return nil
+}
+
+func releaseMachines(controller gomaasapi.Controller, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("release", flag.ContinueOnError)
+	comment := flags.String("comment", "", "record the `text` in the machine events")
+	if err := parseFlags(flags, args, 1, -1); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(controller.ReleaseMachines(gomaasapi.ReleaseMachinesArgs{
+		SystemIDs: flags.Args(),
+		Comment:   *comment,
+	}))
+}
+
+func tagMachines(controller gomaasapi.Controller, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("tag", flag.ContinueOnError)
+	remove := flags.Bool("remove", false, "remove the tag instead of adding it")
+	if err := parseFlags(flags, args, 2, -1); err != nil {
+		return errors.Trace(err)
+	}
+	tag, systemIDs := flags.Arg(0), flags.Args()[1:]
+	results, err := controller.ApplyTagRules(gomaasapi.ApplyTagRulesArgs{
+		Rules: []gomaasapi.TagRule{{
+			Tag:   tag,
+			Match: func(gomaasapi.Machine) bool { return !*remove },
+		}},
+		Machines: gomaasapi.MachinesArgs{SystemIDs: systemIDs},
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	result := results[0]
+	if result.Err != nil {
+		return errors.Trace(result.Err)
+	}
+	for _, systemID := range result.Added {
+		fmt.Fprintf(stdout, "%s +%s\n", systemID, tag)
+	}
+	for _, systemID := range result.Removed {
+		fmt.Fprintf(stdout, "%s -%s\n", systemID, tag)
+	}
+	return nil
+}
+
+// machineByID returns the machine with the system ID.
+func machineByID(controller gomaasapi.Controller, systemID string) (gomaasapi.Machine, error) {
+	machines, err := controller.Machines(gomaasapi.MachinesArgs{SystemIDs: []string{systemID}})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(machines) != 1 {
+		return nil, errors.NotFoundf("machine %q", systemID)
+	}
+	return machines[0], nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	stdtesting "testing"
+
+	"github.com/juju/gomaasapi"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func Test(t *stdtesting.T) {
+	gc.TestingT(t)
+}
+
+type commandsSuite struct {
+	server     *gomaasapi.SimpleTestServer
+	controller gomaasapi.Controller
+	machine    string
+}
+
+var _ = gc.Suite(&commandsSuite{})
+
+const versionResponse = `{"version": "unknown", "subversion": "", "capabilities": []}`
+
+func (s *commandsSuite) SetUpTest(c *gc.C) {
+	machine, err := ioutil.ReadFile(filepath.Join("testdata", "machine.json"))
+	c.Assert(err, jc.ErrorIsNil)
+	s.machine = string(machine)
+	s.server = gomaasapi.NewSimpleServer()
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	s.server.Start()
+	s.controller, err = gomaasapi.NewController(gomaasapi.ControllerArgs{
+		BaseURL: s.server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *commandsSuite) TearDownTest(c *gc.C) {
+	s.server.Close()
+}
+
+func (s *commandsSuite) run(args ...string) (string, error) {
+	var stdout bytes.Buffer
+	err := run(s.controller, args, &stdout)
+	return stdout.String(), err
+}
+
+func (s *commandsSuite) TestMachines(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?zone=default", http.StatusOK, "["+s.machine+"]")
+	out, err := s.run("machines", "-zone", "default", "-tags", "virtual,magic")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(out, gc.Equals, ""+
+		"SYSTEM ID  HOSTNAME          STATUS    POWER  ZONE\n"+
+		"4y3ha3     untasted-markita  Deployed  on     default\n")
+}
+
+func (s *commandsSuite) TestAllocate(c *gc.C) {
+	allocated := strings.Replace(s.machine, "{", `{"constraints_by_type": {},`, 1)
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocated)
+	out, err := s.run("allocate", "-tags", "virtual", "-zone", "default", "-comment", "ticket 42")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(out, gc.Equals, "4y3ha3 untasted-markita\n")
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("tags"), gc.Equals, "virtual")
+	c.Check(form.Get("zone"), gc.Equals, "default")
+	c.Check(form.Get("comment"), gc.Equals, "ticket 42")
+}
+
+func (s *commandsSuite) TestDeploy(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+s.machine+"]")
+	s.server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, s.machine)
+	out, err := s.run("deploy", "-kernel", "hwe-t", "-comment", "ticket 42", "4y3ha3")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(out, gc.Equals, "4y3ha3 Deployed\n")
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("hwe_kernel"), gc.Equals, "hwe-t")
+	c.Check(form.Get("comment"), gc.Equals, "ticket 42")
+}
+
+func (s *commandsSuite) TestDeployPowerUnknown(c *gc.C) {
+	unknown := strings.Replace(s.machine, `"power_state": "on"`, `"power_state": "unknown"`, 1)
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+unknown+"]")
+	_, err := s.run("deploy", "4y3ha3")
+	c.Check(err, gc.ErrorMatches, "deploy: machine 4y3ha3: power state is unknown, .*")
+	c.Check(s.server.RequestCount(), gc.Equals, 3)
+}
+
+func (s *commandsSuite) TestDeployNotFound(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?id=nope", http.StatusOK, "[]")
+	_, err := s.run("deploy", "nope")
+	c.Check(err, gc.ErrorMatches, `deploy: machine "nope" not found`)
+}
+
+func (s *commandsSuite) TestRelease(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "["+s.machine+"]")
+	_, err := s.run("release", "-comment", "done", "4y3ha3", "4y3ha4")
+	c.Assert(err, jc.ErrorIsNil)
+	form := s.server.LastRequest().PostForm
+	c.Check(form["machines"], jc.DeepEquals, []string{"4y3ha3", "4y3ha4"})
+	c.Check(form.Get("comment"), gc.Equals, "done")
+}
+
+func (s *commandsSuite) TestTag(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+s.machine+"]")
+	s.server.AddGetResponse("/api/2.0/tags/magic/", http.StatusOK, `{"name": "magic"}`)
+	s.server.AddPostResponse("/api/2.0/tags/magic/?op=update_nodes", http.StatusOK, `{"added": 0, "removed": 1}`)
+	out, err := s.run("tag", "-remove", "magic", "4y3ha3")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(out, gc.Equals, "4y3ha3 -magic\n")
+	c.Check(s.server.LastRequest().PostForm["remove"], jc.DeepEquals, []string{"4y3ha3"})
+}
+
+func (s *commandsSuite) TestUsageErrors(c *gc.C) {
+	_, err := s.run("frobnicate")
+	c.Check(err, gc.ErrorMatches, `unknown command "frobnicate"`)
+	_, err = s.run("deploy")
+	c.Check(err, gc.ErrorMatches, "deploy: not enough arguments")
+	_, err = s.run("machines", "extra")
+	c.Check(err, gc.ErrorMatches, `machines: unexpected arguments \["extra"\]`)
+}
+
+func (*commandsSuite) TestSplitList(c *gc.C) {
+	c.Check(splitList(""), gc.IsNil)
+	c.Check(splitList("a, b,,c"), jc.DeepEquals, []string{"a", "b", "c"})
+}
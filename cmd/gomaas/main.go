@@ -0,0 +1,80 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+/*
+Command gomaas is a small command line client for MAAS, built on the typed
+API of gomaasapi. It covers the common life cycle of a machine:
+
+	gomaas machines [-hostname names] [-zone zone] [-tags tags]
+	gomaas allocate [-hostname name] [-arch arch] [-zone zone] [-tags tags] [-comment text]
+	gomaas deploy [-series series] [-kernel kernel] [-force] [-comment text] system-id
+	gomaas release [-comment text] system-id...
+	gomaas tag [-remove] tag system-id...
+
+Lists of names and tags are separated by commas. The controller is given
+with -url and -apikey before the command, and defaults to $MAAS_API_URL
+and $MAAS_API_KEY:
+
+	export MAAS_API_URL=http://maas/MAAS/ MAAS_API_KEY=...
+	gomaas allocate -tags virtual
+	gomaas deploy -series xenial 4y3ha3
+
+The comments given to allocate, deploy and release are recorded in the
+event logs of the machines, for audits to see why they were changed.
+
+Deploy refuses machines whose power state is unknown, as they may already
+be running a workload, unless -force is given.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+)
+
+func main() {
+	baseURL := flag.String("url", os.Getenv(gomaasapi.APIURLEnvVar), "root `url` of the MAAS controller")
+	// The key is not the flag's default, so that usage does not print it.
+	apiKey := flag.String("apikey", "", "API `key` of the MAAS user (default $"+gomaasapi.APIKeyEnvVar+")")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	if *apiKey == "" {
+		*apiKey = os.Getenv(gomaasapi.APIKeyEnvVar)
+	}
+
+	if err := connectAndRun(*baseURL, *apiKey, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "gomaas: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gomaas [-url url] [-apikey key] command [flags] [args]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func connectAndRun(baseURL, apiKey string, args []string) error {
+	if baseURL == "" {
+		return errors.Errorf("no MAAS URL: use -url or set $%s", gomaasapi.APIURLEnvVar)
+	}
+	controller, err := gomaasapi.NewController(gomaasapi.ControllerArgs{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return run(controller, args, os.Stdout)
+}
@@ -0,0 +1,240 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+)
+
+// initialisms are the words written in upper case in Go names.
+var initialisms = map[string]bool{
+	"api": true, "cidr": true, "dhcp": true, "dns": true, "html": true,
+	"http": true, "id": true, "ip": true, "ipmi": true, "json": true,
+	"mac": true, "ntp": true, "os": true, "uri": true, "url": true,
+	"uuid": true, "vlan": true,
+}
+
+// generate returns the formatted source of the wrappers for every handler
+// in the description.
+func generate(description gomaasapi.APIDescription, packageName string) ([]byte, error) {
+	if !token.IsIdentifier(packageName) {
+		return nil, errors.NotValidf("package name %q", packageName)
+	}
+	var body bytes.Buffer
+	usesParams := false
+	for _, handler := range handlers(description) {
+		if writeHandler(&body, handler) {
+			usesParams = true
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gomaasgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+	fmt.Fprintf(&buf, "import (\n")
+	if usesParams {
+		fmt.Fprintf(&buf, "\t\"net/url\"\n\n")
+	}
+	fmt.Fprintf(&buf, "\t\"github.com/juju/gomaasapi\"\n)\n")
+	buf.Write(body.Bytes())
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Annotate(err, "formatting generated code")
+	}
+	return source, nil
+}
+
+// handlers returns the distinct handlers of the description, both
+// authenticated and anonymous, sorted by name.
+func handlers(description gomaasapi.APIDescription) []gomaasapi.APIHandler {
+	byName := make(map[string]gomaasapi.APIHandler)
+	for _, resource := range description.Resources() {
+		for _, handler := range []gomaasapi.APIHandler{resource.Authenticated(), resource.Anonymous()} {
+			if handler != nil {
+				byName[handler.Name()] = handler
+			}
+		}
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]gomaasapi.APIHandler, len(names))
+	for i, name := range names {
+		result[i] = byName[name]
+	}
+	return result
+}
+
+// writeHandler writes the type, constructor and methods for the handler,
+// and reports whether any method takes url.Values.
+func writeHandler(buf *bytes.Buffer, handler gomaasapi.APIHandler) bool {
+	typeName := goName(handler.Name(), true)
+	fmt.Fprintf(buf, "\n// %s wraps the %s handler at\n// %s.\n", typeName, handler.Name(), handler.Path())
+	writeDoc(buf, handler.Doc())
+	fmt.Fprintf(buf, "type %s struct {\n\tObject gomaasapi.MAASObject\n}\n", typeName)
+
+	names, expression := pathExpression(relativePath(handler.Path()))
+	args := []string{"maas *gomaasapi.MAASObject"}
+	for _, name := range names {
+		args = append(args, name+" string")
+	}
+	fmt.Fprintf(buf, "\n// New%s returns the %s for the MAAS API.\n", typeName, typeName)
+	fmt.Fprintf(buf, "func New%s(%s) %s {\n", typeName, strings.Join(args, ", "), typeName)
+	fmt.Fprintf(buf, "\treturn %s{Object: maas.GetSubObject(%s)}\n}\n", typeName, expression)
+
+	usesParams := false
+	used := map[string]bool{"Object": true}
+	for _, action := range handler.Actions() {
+		name := goName(action.Name(), true)
+		if used[name] {
+			name += goName(strings.ToLower(action.Method()), true)
+		}
+		if used[name] {
+			continue
+		}
+		signature, call, ok := actionCall(action)
+		if !ok {
+			continue
+		}
+		used[name] = true
+		if strings.Contains(signature, "url.Values") {
+			usesParams = true
+		}
+		fmt.Fprintf(buf, "\n// %s calls %s", name, action.Method())
+		if action.Op() != "" {
+			fmt.Fprintf(buf, " op=%s", action.Op())
+		}
+		fmt.Fprintf(buf, " on the handler.\n")
+		writeDoc(buf, action.Doc())
+		fmt.Fprintf(buf, "func (h %s) %s%s {\n\treturn h.Object.%s\n}\n", typeName, name, signature, call)
+	}
+	return usesParams
+}
+
+// actionCall returns the signature of the wrapper for the action, and the
+// MAASObject call it makes. Actions that MAASObject cannot make are not
+// wrapped.
+func actionCall(action gomaasapi.APIAction) (signature, call string, ok bool) {
+	op := strconv.Quote(action.Op())
+	switch {
+	case action.Restful() && action.Method() == "GET":
+		return "() (gomaasapi.MAASObject, error)", "Get()", true
+	case action.Restful() && action.Method() == "POST":
+		return "(params url.Values) (gomaasapi.JSONObject, error)", "Post(params)", true
+	case action.Restful() && action.Method() == "PUT":
+		return "(params url.Values) (gomaasapi.MAASObject, error)", "Update(params)", true
+	case action.Restful() && action.Method() == "DELETE":
+		return "() error", "Delete()", true
+	case action.Method() == "GET":
+		return "(params url.Values) (gomaasapi.JSONObject, error)", "CallGet(" + op + ", params)", true
+	case action.Method() == "POST":
+		return "(params url.Values) (gomaasapi.JSONObject, error)", "CallPost(" + op + ", params)", true
+	}
+	return "", "", false
+}
+
+// writeDoc writes the first paragraph of the documentation as a comment,
+// after a blank comment line.
+func writeDoc(buf *bytes.Buffer, doc string) {
+	paragraph := strings.TrimSpace(doc)
+	if i := strings.Index(paragraph, "\n\n"); i >= 0 {
+		paragraph = paragraph[:i]
+	}
+	words := strings.Fields(paragraph)
+	if len(words) == 0 {
+		return
+	}
+	buf.WriteString("//\n//")
+	width := 2
+	for _, word := range words {
+		if width > 2 && width+1+len(word) > 76 {
+			buf.WriteString("\n//")
+			width = 2
+		}
+		buf.WriteString(" " + word)
+		width += 1 + len(word)
+	}
+	buf.WriteString("\n")
+}
+
+// relativePath returns the path of the handler relative to the API URL,
+// so "/MAAS/api/2.0/machines/{system_id}/" becomes
+// "machines/{system_id}/".
+func relativePath(path string) string {
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		rest := path[i+len("/api/"):]
+		if j := strings.Index(rest, "/"); j >= 0 {
+			return rest[j+1:]
+		}
+		return ""
+	}
+	return strings.TrimPrefix(path, "/")
+}
+
+// pathExpression returns the Go parameter names for the parameters in the
+// path template, and a string expression that fills them in.
+func pathExpression(path string) ([]string, string) {
+	var names, parts []string
+	for {
+		start := strings.Index(path, "{")
+		end := strings.Index(path, "}")
+		if start < 0 || end < start {
+			break
+		}
+		if start > 0 {
+			parts = append(parts, strconv.Quote(path[:start]))
+		}
+		name := goName(path[start+1:end], false)
+		if token.Lookup(name).IsKeyword() || name == "maas" {
+			name += "Param"
+		}
+		names = append(names, name)
+		parts = append(parts, name)
+		path = path[end+1:]
+	}
+	if path != "" || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(path))
+	}
+	return names, strings.Join(parts, " + ")
+}
+
+// goName converts a name such as "set_storage_layout" or "system_id" to
+// the Go style, "SetStorageLayout" or "systemID".
+func goName(name string, exported bool) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var result strings.Builder
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		switch {
+		case i == 0 && !exported:
+			result.WriteString(lower)
+		case initialisms[lower]:
+			result.WriteString(strings.ToUpper(word))
+		default:
+			result.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	if result.Len() == 0 || unicode.IsDigit(rune(result.String()[0])) {
+		if exported {
+			return "X" + result.String()
+		}
+		return "x" + result.String()
+	}
+	return result.String()
+}
@@ -0,0 +1,164 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	stdtesting "testing"
+
+	"github.com/juju/gomaasapi"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func Test(t *stdtesting.T) {
+	gc.TestingT(t)
+}
+
+type generateSuite struct{}
+
+var _ = gc.Suite(&generateSuite{})
+
+func readTestDescription(c *gc.C) gomaasapi.APIDescription {
+	f, err := os.Open(filepath.Join("testdata", "describe.json"))
+	c.Assert(err, jc.ErrorIsNil)
+	defer f.Close()
+	description, err := gomaasapi.ReadAPIDescription(f)
+	c.Assert(err, jc.ErrorIsNil)
+	return description
+}
+
+func (*generateSuite) TestGenerateParses(c *gc.C) {
+	source, err := generate(readTestDescription(c), "maasapi")
+	c.Assert(err, jc.ErrorIsNil)
+	file, err := parser.ParseFile(token.NewFileSet(), "maasapi.go", source, parser.ParseComments)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(file.Name.Name, gc.Equals, "maasapi")
+	c.Check(string(source), jc.HasPrefix, "// Code generated by gomaasgen. DO NOT EDIT.\n")
+}
+
+func (*generateSuite) TestGenerateHandler(c *gc.C) {
+	source, err := generate(readTestDescription(c), "maasapi")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(source), jc.Contains, `
+// MachineHandler wraps the MachineHandler handler at
+// /MAAS/api/2.0/machines/{system_id}/.
+//
+// Manage an individual machine.
+type MachineHandler struct {
+	Object gomaasapi.MAASObject
+}
+
+// NewMachineHandler returns the MachineHandler for the MAAS API.
+func NewMachineHandler(maas *gomaasapi.MAASObject, systemID string) MachineHandler {
+	return MachineHandler{Object: maas.GetSubObject("machines/" + systemID + "/")}
+}
+`)
+}
+
+func (*generateSuite) TestGenerateActions(c *gc.C) {
+	source, err := generate(readTestDescription(c), "maasapi")
+	c.Assert(err, jc.ErrorIsNil)
+	for _, expected := range []string{`
+// Read calls GET on the handler.
+//
+// Read a machine.
+func (h MachineHandler) Read() (gomaasapi.MAASObject, error) {
+	return h.Object.Get()
+}
+`, `
+func (h MachineHandler) Update(params url.Values) (gomaasapi.MAASObject, error) {
+	return h.Object.Update(params)
+}
+`, `
+func (h MachineHandler) Delete() error {
+	return h.Object.Delete()
+}
+`, `
+// Deploy calls POST op=deploy on the handler.
+//
+// Deploy an operating system to a machine.
+func (h MachineHandler) Deploy(params url.Values) (gomaasapi.JSONObject, error) {
+	return h.Object.CallPost("deploy", params)
+}
+`, `
+// SetStorageLayout calls POST op=set_storage_layout on the handler.
+func (h MachineHandler) SetStorageLayout(params url.Values) (gomaasapi.JSONObject, error) {
+	return h.Object.CallPost("set_storage_layout", params)
+}
+`, `
+func (h MachineHandler) GetCurtinConfig(params url.Values) (gomaasapi.JSONObject, error) {
+	return h.Object.CallGet("get_curtin_config", params)
+}
+`} {
+		c.Check(string(source), jc.Contains, expected)
+	}
+}
+
+func (*generateSuite) TestGeneratePathParams(c *gc.C) {
+	source, err := generate(readTestDescription(c), "maasapi")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(source), jc.Contains, `
+func NewVlanHandler(maas *gomaasapi.MAASObject, fabricID string, vid string) VlanHandler {
+	return VlanHandler{Object: maas.GetSubObject("fabrics/" + fabricID + "/vlans/" + vid + "/")}
+}
+`)
+}
+
+func (*generateSuite) TestGenerateAnonymousHandler(c *gc.C) {
+	source, err := generate(readTestDescription(c), "maasapi")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(source), jc.Contains, `
+func NewAnonVersionHandler(maas *gomaasapi.MAASObject) AnonVersionHandler {
+	return AnonVersionHandler{Object: maas.GetSubObject("version/")}
+}
+`)
+}
+
+func (*generateSuite) TestGenerateBadPackage(c *gc.C) {
+	_, err := generate(readTestDescription(c), "not-a-package")
+	c.Check(err, gc.ErrorMatches, `package name "not-a-package" not valid`)
+}
+
+func (*generateSuite) TestGoName(c *gc.C) {
+	for _, test := range []struct {
+		name     string
+		exported bool
+		expected string
+	}{
+		{"set_storage_layout", true, "SetStorageLayout"},
+		{"system_id", false, "systemID"},
+		{"system_id", true, "SystemID"},
+		{"id", false, "id"},
+		{"mac_address", true, "MACAddress"},
+		{"VlanHandler", true, "VlanHandler"},
+		{"3rd_party", true, "X3rdParty"},
+		{"", false, "x"},
+	} {
+		c.Check(goName(test.name, test.exported), gc.Equals, test.expected, gc.Commentf("%q", test.name))
+	}
+}
+
+func (*generateSuite) TestRelativePath(c *gc.C) {
+	c.Check(relativePath("/MAAS/api/2.0/machines/{system_id}/"), gc.Equals, "machines/{system_id}/")
+	c.Check(relativePath("/api/2.0/"), gc.Equals, "")
+	c.Check(relativePath("/other/"), gc.Equals, "other/")
+}
+
+func (*generateSuite) TestPathExpression(c *gc.C) {
+	names, expression := pathExpression("tags/{name}/")
+	c.Check(names, jc.DeepEquals, []string{"name"})
+	c.Check(expression, gc.Equals, `"tags/" + name + "/"`)
+
+	names, expression = pathExpression("{type}")
+	c.Check(names, jc.DeepEquals, []string{"typeParam"})
+	c.Check(expression, gc.Equals, `typeParam`)
+
+	names, expression = pathExpression("")
+	c.Check(names, gc.HasLen, 0)
+	c.Check(expression, gc.Equals, `""`)
+}
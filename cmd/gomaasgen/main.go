@@ -0,0 +1,89 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+/*
+Command gomaasgen generates typed Go wrappers for the handlers of the MAAS
+API from its description document, which MAAS serves from the describe
+endpoint. Each handler becomes a type holding a gomaasapi.MAASObject, with
+a constructor taking the parameters of the handler's path and a method for
+each of its actions.
+
+The description is read from a saved document, or from a live controller:
+
+	curl http://maas/MAAS/api/2.0/describe/ > describe.json
+	gomaasgen -describe describe.json -package maasapi -o maasapi.go
+
+	gomaasgen -url http://maas/MAAS -apikey $MAAS_API_KEY -o maasapi.go
+
+It is intended to be run with go generate, from a file in the package that
+holds the wrappers:
+
+	//go:generate go run github.com/juju/gomaasapi/cmd/gomaasgen -describe describe.json -package maasapi -o maasapi.go
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+)
+
+func main() {
+	describe := flag.String("describe", "", "read the API description from `file`, or standard input for -")
+	baseURL := flag.String("url", "", "read the API description from the MAAS controller at `url`")
+	apiKey := flag.String("apikey", "", "API key for -url")
+	packageName := flag.String("package", "maasapi", "package `name` of the generated code")
+	output := flag.String("o", "", "write the generated code to `file` instead of standard output")
+	flag.Parse()
+
+	if err := run(*describe, *baseURL, *apiKey, *packageName, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "gomaasgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(describe, baseURL, apiKey, packageName, output string) error {
+	description, err := loadDescription(describe, baseURL, apiKey)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	source, err := generate(description, packageName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(source)
+		return errors.Trace(err)
+	}
+	return errors.Trace(ioutil.WriteFile(output, source, 0644))
+}
+
+func loadDescription(describe, baseURL, apiKey string) (gomaasapi.APIDescription, error) {
+	switch {
+	case describe != "" && baseURL != "":
+		return nil, errors.New("-describe and -url cannot be used together")
+	case describe == "-":
+		return gomaasapi.ReadAPIDescription(os.Stdin)
+	case describe != "":
+		f, err := os.Open(describe)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		defer f.Close()
+		return gomaasapi.ReadAPIDescription(f)
+	case baseURL != "":
+		controller, err := gomaasapi.NewController(gomaasapi.ControllerArgs{
+			BaseURL: baseURL,
+			APIKey:  apiKey,
+		})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return controller.Describe()
+	}
+	return nil, errors.New("one of -describe or -url is required")
+}
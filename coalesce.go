@@ -0,0 +1,86 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// GetCoalescer makes identical GET requests that are in progress at the
+// same time share a single request to MAAS, so that many callers
+// refreshing the same listing, such as the widgets of a dashboard, do not
+// each load the region. Requests are identical if they have the same URL,
+// including the operation and parameters. A request that starts after
+// the shared one has completed is sent again, so no response is cached.
+//
+// The response is shared whatever the credentials of the callers, so a
+// GetCoalescer should only be shared by Clients with the same API key.
+type GetCoalescer struct {
+	mu        sync.Mutex
+	calls     map[string]*coalescedGet
+	coalesced int
+}
+
+// coalescedGet is a GET request in progress, and its outcome once done is
+// closed.
+type coalescedGet struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// NewGetCoalescer returns a GetCoalescer with no requests in progress.
+func NewGetCoalescer() *GetCoalescer {
+	return &GetCoalescer{calls: make(map[string]*coalescedGet)}
+}
+
+// Coalesced returns the number of requests that were answered with the
+// response of another request rather than being sent.
+func (g *GetCoalescer) Coalesced() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.coalesced
+}
+
+// do returns the outcome of get, calling it only if no call for the key is
+// in progress, and waiting for that call otherwise. Each caller gets its
+// own copy of the body. If get panics, the waiting callers get an error
+// and the panic continues in the caller that made the call.
+func (g *GetCoalescer) do(key string, get func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.coalesced++
+		g.mu.Unlock()
+		<-call.done
+		return copyBody(call.body), call.err
+	}
+	call := &coalescedGet{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			call.body, call.err = nil, errors.Errorf("GET %s panicked: %v", key, r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+		if r != nil {
+			panic(r)
+		}
+	}()
+	call.body, call.err = get()
+	return copyBody(call.body), call.err
+}
+
+func copyBody(body []byte) []byte {
+	if body == nil {
+		return nil
+	}
+	return append([]byte(nil), body...)
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type coalesceSuite struct{}
+
+var _ = gc.Suite(&coalesceSuite{})
+
+func (*coalesceSuite) TestDoSharesCallInProgress(c *gc.C) {
+	g := NewGetCoalescer()
+	release := make(chan struct{})
+	var calls int32
+	get := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte(`"zones"`), nil
+	}
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make([][]byte, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body, err := g.do("zones", get)
+			c.Check(err, jc.ErrorIsNil)
+			results[i] = body
+		}(i)
+	}
+	// Wait until all but the first caller are waiting on the first.
+	for g.Coalesced() < callers-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	c.Check(atomic.LoadInt32(&calls), gc.Equals, int32(1))
+	for _, body := range results {
+		c.Check(string(body), gc.Equals, `"zones"`)
+	}
+	// Each caller has its own copy.
+	results[0][0] = 'x'
+	c.Check(string(results[1]), gc.Equals, `"zones"`)
+
+	// Once done, the call is made again.
+	_, err := g.do("zones", func() ([]byte, error) { return nil, errors.New("boom") })
+	c.Check(err, gc.ErrorMatches, "boom")
+	c.Check(g.Coalesced(), gc.Equals, callers-1)
+}
+
+func (*coalesceSuite) TestDoPanic(c *gc.C) {
+	g := NewGetCoalescer()
+	release := make(chan struct{})
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		g.do("zones", func() ([]byte, error) {
+			<-release
+			panic("boom")
+		})
+	}()
+	waited := make(chan error)
+	go func() {
+		// Wait until the first call is in progress.
+		for {
+			g.mu.Lock()
+			_, ok := g.calls["zones"]
+			g.mu.Unlock()
+			if ok {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		_, err := g.do("zones", func() ([]byte, error) { return nil, nil })
+		waited <- err
+	}()
+	for g.Coalesced() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	c.Check(<-panicked, gc.Equals, "boom")
+	select {
+	case err := <-waited:
+		c.Check(err, gc.ErrorMatches, "GET zones panicked: boom")
+	case <-time.After(5 * time.Second):
+		c.Fatalf("waiting caller not released")
+	}
+
+	// The key is free for the next call.
+	body, err := g.do("zones", func() ([]byte, error) { return []byte("ok"), nil })
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(body), gc.Equals, "ok")
+}
+
+func (*coalesceSuite) TestClientCoalescesIdenticalGets(c *gc.C) {
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Query().Get("op") == "list" {
+			<-release
+		}
+		w.Write([]byte(`"ok"`))
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.Coalescer = NewGetCoalescer()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Get(&url.URL{Path: "machines/"}, "list", url.Values{"zone": {"a"}})
+			c.Check(err, jc.ErrorIsNil)
+		}()
+	}
+	for client.Coalescer.Coalesced() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// A different query is not coalesced.
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", url.Values{"zone": {"b"}})
+	c.Assert(err, jc.ErrorIsNil)
+	close(release)
+	wg.Wait()
+	c.Check(atomic.LoadInt32(&requests), gc.Equals, int32(2))
+}
+
+func (*coalesceSuite) TestControllerCoalesceGets(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	maas, err := NewController(ControllerArgs{
+		BaseURL:      server.URL,
+		APIKey:       "fake:as:key",
+		CoalesceGets: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(maas.(*controller).client.Coalescer, gc.NotNil)
+}
@@ -0,0 +1,280 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// ComposeDisk is a disk of a VM composed by VMHost.ComposeAndDeploy.
+type ComposeDisk struct {
+	// Size is the size of the disk in GB.
+	Size int
+
+	// Tags, if not empty, select the storage pool of the VM host that the
+	// disk is created in.
+	Tags []string
+}
+
+// ComposeSpec is an argument struct for VMHost.ComposeAndDeploy.
+type ComposeSpec struct {
+	// Cores and Memory, in MB, size the VM. Zero leaves them to MAAS.
+	Cores  int
+	Memory int
+
+	// Disks are the disks of the VM, the first being the root disk. If
+	// empty, MAAS creates a single root disk.
+	Disks []ComposeDisk
+
+	Hostname     string
+	Architecture string
+
+	// HugepagesBacked, if true, backs the memory of the VM with hugepages
+	// of the host. Memory must then be set. It needs MAAS 2.9 or later.
+	HugepagesBacked bool
+
+	// PinnedCores, if not empty, are the host cores the VM runs on, and
+	// set its core count. Cores must then be zero or the same count. It
+	// needs MAAS 2.9 or later.
+	PinnedCores []int
+
+	// Interfaces, if not empty, constrain the spaces of the interfaces of
+	// the VM, as for AllocateMachineArgs. It needs MAAS 2.9 or later.
+	Interfaces []InterfaceSpec
+
+	// Start is passed to Machine.Start once the VM is commissioned and
+	// allocated, and holds the series, kernel and user data.
+	Start StartArgs
+}
+
+// Validate makes sure that the sizes are not negative and that the
+// hostname, architecture and start args are valid.
+func (s *ComposeSpec) Validate() error {
+	if s.Cores < 0 {
+		return NewArgumentError("Cores", "negative count %d", s.Cores)
+	}
+	if s.Memory < 0 {
+		return NewArgumentError("Memory", "negative size %d", s.Memory)
+	}
+	if s.HugepagesBacked && s.Memory == 0 {
+		return NewArgumentError("Memory", "must be set for a hugepages backed VM")
+	}
+	for i, disk := range s.Disks {
+		if disk.Size <= 0 {
+			return NewArgumentError("Disks", "disk %d has size %d", i, disk.Size)
+		}
+	}
+	pinned := make(map[int]bool)
+	for _, core := range s.PinnedCores {
+		if core < 0 {
+			return NewArgumentError("PinnedCores", "negative core %d", core)
+		}
+		if pinned[core] {
+			return NewArgumentError("PinnedCores", "core %d pinned twice", core)
+		}
+		pinned[core] = true
+	}
+	if len(s.PinnedCores) > 0 && s.Cores != 0 && s.Cores != len(s.PinnedCores) {
+		return NewArgumentError("Cores", "%d cores with %d pinned cores", s.Cores, len(s.PinnedCores))
+	}
+	labels := make(map[string]bool)
+	for _, spec := range s.Interfaces {
+		if err := spec.Validate(); err != nil {
+			return NewArgumentError("Interfaces", "%v", err)
+		}
+		if labels[spec.Label] {
+			return NewArgumentError("Interfaces", "duplicate label %q", spec.Label)
+		}
+		labels[spec.Label] = true
+	}
+	if s.Hostname != "" && !isValidHostname(s.Hostname) {
+		return NewArgumentError("Hostname", "%q is not a valid hostname", s.Hostname)
+	}
+	if s.Architecture != "" {
+		if _, err := NormalizeArchitecture(s.Architecture); err != nil {
+			return NewArgumentError("Architecture", "%q is not a valid architecture", s.Architecture)
+		}
+	}
+	if err := s.Start.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// storage returns the disks in the form the compose operation takes,
+// such as "disk0:20(ssd),disk1:100".
+func (s *ComposeSpec) storage() string {
+	var disks []string
+	for i, disk := range s.Disks {
+		value := fmt.Sprintf("disk%d:%d", i, disk.Size)
+		if len(disk.Tags) > 0 {
+			value += "(" + strings.Join(disk.Tags, ",") + ")"
+		}
+		disks = append(disks, value)
+	}
+	return strings.Join(disks, ",")
+}
+
+// interfaces returns the interface constraints in the form the compose
+// operation takes.
+func (s *ComposeSpec) interfaces() string {
+	var values []string
+	for _, spec := range s.Interfaces {
+		values = append(values, spec.String())
+	}
+	return strings.Join(values, ";")
+}
+
+// checkHost makes sure that the VM host can pin the cores and provide the
+// hugepages the spec asks for, using the NUMA layout it reports.
+func (s *ComposeSpec) checkHost(host VMHost) error {
+	if !s.HugepagesBacked && len(s.PinnedCores) == 0 {
+		return nil
+	}
+	nodes := host.NUMANodes()
+	if len(nodes) == 0 {
+		return errors.NotSupportedf("NUMA pinning on VM host %q without a NUMA layout", host.Name())
+	}
+	free := make(map[int]bool)
+	var hugepages uint64
+	for _, node := range nodes {
+		for _, core := range node.FreeCores {
+			free[core] = true
+		}
+		for _, pages := range node.Hugepages {
+			hugepages += pages.Free
+		}
+	}
+	for _, core := range s.PinnedCores {
+		if !free[core] {
+			return NewArgumentError("PinnedCores", "core %d is not free on VM host %q", core, host.Name())
+		}
+	}
+	if s.HugepagesBacked {
+		if hugepages == 0 {
+			return NewArgumentError("HugepagesBacked", "VM host %q has no free hugepages", host.Name())
+		}
+		if memory := uint64(s.Memory) * 1024 * 1024; memory > hugepages {
+			return NewArgumentError("Memory", "%d MB is more than the %d MB of free hugepages on VM host %q", s.Memory, hugepages/(1024*1024), host.Name())
+		}
+	}
+	return nil
+}
+
+// Machine status names that end the wait for a composed VM to be
+// commissioned.
+const (
+	statusNameFailedCommissioning = "Failed commissioning"
+	statusNameFailedTesting       = "Failed testing"
+)
+
+// ComposeAndDeploy implements VMHost.
+func (h *vmHost) ComposeAndDeploy(ctx context.Context, spec ComposeSpec) (Machine, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := spec.checkHost(h); err != nil {
+		return nil, errors.Trace(err)
+	}
+	systemID, err := h.compose(spec)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	c := h.controller
+	machine, err := c.waitForCommissioning(ctx, systemID)
+	if err != nil {
+		return machine, errors.Trace(err)
+	}
+	allocated, _, err := c.AllocateMachine(AllocateMachineArgs{Hostname: machine.Hostname()})
+	if err != nil {
+		return machine, errors.Annotatef(err, "allocating machine %s", systemID)
+	}
+	if allocated.SystemID() != systemID {
+		return machine, errors.Errorf("allocating machine %s: got machine %s", systemID, allocated.SystemID())
+	}
+	if err := ctx.Err(); err != nil {
+		return allocated, errors.Annotatef(err, "starting machine %s", systemID)
+	}
+	if err := allocated.Start(spec.Start); err != nil {
+		return allocated, errors.Annotatef(err, "starting machine %s", systemID)
+	}
+	return c.waitForDeployment(ctx, allocated)
+}
+
+// compose asks the VM host for a new VM and returns its system ID.
+func (h *vmHost) compose(spec ComposeSpec) (string, error) {
+	params := NewURLParams()
+	params.MaybeAddInt("cores", spec.Cores)
+	params.MaybeAddInt("memory", spec.Memory)
+	params.MaybeAdd("storage", spec.storage())
+	params.MaybeAdd("hostname", spec.Hostname)
+	arch, _ := NormalizeArchitecture(spec.Architecture)
+	params.MaybeAdd("architecture", arch)
+	params.MaybeAddBool("hugepages_backed", spec.HugepagesBacked)
+	for _, core := range spec.PinnedCores {
+		params.Values.Add("pinned_cores", strconv.Itoa(core))
+	}
+	params.MaybeAdd("interfaces", spec.interfaces())
+	result, err := h.controller.post(h.resourceURI, "compose", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return "", errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return "", errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return "", errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusServiceUnavailable:
+				return "", errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return "", NewUnexpectedError(err)
+	}
+	checker := schema.FieldMap(schema.Fields{"system_id": stringField()}, nil)
+	coerced, err := checker.Coerce(h.controller.markLeaves(result), nil)
+	if err != nil {
+		return "", WrapWithDeserializationError(err, "compose response schema check failed")
+	}
+	return coerced.(map[string]interface{})["system_id"].(string), nil
+}
+
+// waitForCommissioning polls the machine until it is Ready, commissioning
+// or testing fails, or the context is done. The last machine state read,
+// if any, is returned, even on error.
+func (c *controller) waitForCommissioning(ctx context.Context, systemID string) (Machine, error) {
+	var machine Machine
+	for {
+		select {
+		case <-ctx.Done():
+			return machine, errors.Annotatef(ctx.Err(), "waiting for machine %s", systemID)
+		case <-c.clock.After(deployPollInterval):
+		}
+		machines, err := c.Machines(MachinesArgs{SystemIDs: []string{systemID}})
+		if err != nil {
+			return machine, errors.Annotatef(err, "waiting for machine %s", systemID)
+		}
+		if len(machines) != 1 {
+			return machine, NewNoMatchError(fmt.Sprintf("machine %s not found", systemID))
+		}
+		machine = machines[0]
+		switch status := machine.StatusName(); status {
+		case statusNameReady:
+			return machine, nil
+		case statusNameFailedCommissioning, statusNameFailedTesting, statusNameBroken:
+			message := fmt.Sprintf("machine %s: %s", systemID, status)
+			if detail := machine.StatusMessage(); detail != "" {
+				message += ": " + detail
+			}
+			return machine, NewCannotCompleteError(message)
+		}
+	}
+}
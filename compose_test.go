@@ -0,0 +1,231 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type composeSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&composeSuite{})
+
+func (s *composeSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.PatchValue(&deployPollInterval, time.Millisecond)
+}
+
+func (*composeSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		spec  ComposeSpec
+		field string
+	}{{
+		spec:  ComposeSpec{Cores: -1},
+		field: "Cores",
+	}, {
+		spec:  ComposeSpec{Memory: -1},
+		field: "Memory",
+	}, {
+		spec:  ComposeSpec{HugepagesBacked: true},
+		field: "Memory",
+	}, {
+		spec:  ComposeSpec{Disks: []ComposeDisk{{Size: 10}, {}}},
+		field: "Disks",
+	}, {
+		spec:  ComposeSpec{Hostname: "not valid"},
+		field: "Hostname",
+	}, {
+		spec:  ComposeSpec{Architecture: "AMD 64"},
+		field: "Architecture",
+	}, {
+		spec:  ComposeSpec{PinnedCores: []int{-1}},
+		field: "PinnedCores",
+	}, {
+		spec:  ComposeSpec{PinnedCores: []int{2, 2}},
+		field: "PinnedCores",
+	}, {
+		spec:  ComposeSpec{Cores: 4, PinnedCores: []int{2, 3}},
+		field: "Cores",
+	}, {
+		spec:  ComposeSpec{Interfaces: []InterfaceSpec{{Label: "a"}}},
+		field: "Interfaces",
+	}, {
+		spec:  ComposeSpec{Interfaces: []InterfaceSpec{{Label: "a", Space: "x"}, {Label: "a", Space: "y"}}},
+		field: "Interfaces",
+	}, {
+		spec:  ComposeSpec{Start: StartArgs{UserData: "!"}},
+		field: "UserData",
+	}} {
+		c.Logf("test %d", i)
+		err := test.spec.Validate()
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(errors.Cause(err).(*ArgumentError).Field, gc.Equals, test.field)
+	}
+}
+
+func (*composeSuite) TestStorage(c *gc.C) {
+	spec := ComposeSpec{Disks: []ComposeDisk{{Size: 20, Tags: []string{"ssd", "fast"}}, {Size: 100}}}
+	c.Check(spec.storage(), gc.Equals, "disk0:20(ssd,fast),disk1:100")
+}
+
+func (s *composeSuite) getServerAndHost(c *gc.C) (*SimpleTestServer, VMHost) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/pods/", http.StatusOK, vmHostsResponse)
+	hosts, err := controller.VMHosts()
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+	return server, hosts[0]
+}
+
+func composedMachineJSON(c *gc.C, status, message string) string {
+	return updateJSONMap(c, machineJSON(c, "vm1", status, message), map[string]interface{}{
+		"hostname": "vm1",
+	})
+}
+
+func (s *composeSuite) TestComposeAndDeploy(c *gc.C) {
+	server, host := s.getServerAndHost(c)
+	server.AddPostResponse("/MAAS/api/2.0/pods/1/?op=compose", http.StatusOK, `{"system_id": "vm1", "resource_uri": "/MAAS/api/2.0/machines/vm1/"}`)
+	server.AddGetResponse("/api/2.0/machines/?id=vm1", http.StatusOK, "["+composedMachineJSON(c, "Commissioning", "")+"]")
+	server.AddGetResponse("/api/2.0/machines/?id=vm1", http.StatusOK, "["+composedMachineJSON(c, "Ready", "")+"]")
+	allocated := updateJSONMap(c, composedMachineJSON(c, "Allocated", ""), map[string]interface{}{
+		"constraints_by_type": map[string]interface{}{},
+	})
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocated)
+	server.AddPostResponse("/MAAS/api/2.0/machines/vm1/?op=deploy", http.StatusOK, composedMachineJSON(c, "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id=vm1", http.StatusOK, "["+composedMachineJSON(c, "Deployed", "")+"]")
+
+	machine, err := host.ComposeAndDeploy(context.Background(), ComposeSpec{
+		Cores:  2,
+		Memory: 4096,
+		Disks:  []ComposeDisk{{Size: 20}},
+		Start:  StartArgs{DistroSeries: "xenial"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "vm1")
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+
+	requests := server.LastNRequests(server.RequestCount())
+	compose := requests[0].PostForm
+	c.Check(compose.Get("cores"), gc.Equals, "2")
+	c.Check(compose.Get("memory"), gc.Equals, "4096")
+	c.Check(compose.Get("storage"), gc.Equals, "disk0:20")
+	var allocate, deploy *http.Request
+	for _, request := range requests {
+		switch request.URL.Query().Get("op") {
+		case "allocate":
+			allocate = request
+		case "deploy":
+			deploy = request
+		}
+	}
+	c.Assert(allocate, gc.NotNil)
+	c.Check(allocate.PostForm.Get("name"), gc.Equals, "vm1")
+	c.Assert(deploy, gc.NotNil)
+	c.Check(deploy.PostForm.Get("distro_series"), gc.Equals, "xenial")
+}
+
+func (s *composeSuite) TestComposeFails(c *gc.C) {
+	server, host := s.getServerAndHost(c)
+	server.AddPostResponse("/MAAS/api/2.0/pods/1/?op=compose", http.StatusServiceUnavailable, "not enough cores")
+
+	machine, err := host.ComposeAndDeploy(context.Background(), ComposeSpec{Cores: 64})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, "not enough cores")
+	c.Check(machine, gc.IsNil)
+}
+
+func (s *composeSuite) TestCommissioningFails(c *gc.C) {
+	server, host := s.getServerAndHost(c)
+	server.AddPostResponse("/MAAS/api/2.0/pods/1/?op=compose", http.StatusOK, `{"system_id": "vm1"}`)
+	server.AddGetResponse("/api/2.0/machines/?id=vm1", http.StatusOK, "["+composedMachineJSON(c, "Failed commissioning", "no network")+"]")
+
+	machine, err := host.ComposeAndDeploy(context.Background(), ComposeSpec{})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, "machine vm1: Failed commissioning: no network")
+	c.Assert(machine, gc.NotNil)
+	c.Check(machine.SystemID(), gc.Equals, "vm1")
+}
+
+func (s *composeSuite) TestCancelled(c *gc.C) {
+	server, host := s.getServerAndHost(c)
+	server.AddPostResponse("/MAAS/api/2.0/pods/1/?op=compose", http.StatusOK, `{"system_id": "vm1"}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := host.ComposeAndDeploy(ctx, ComposeSpec{})
+	c.Check(err, gc.ErrorMatches, "waiting for machine vm1: context canceled")
+}
+
+func (s *composeSuite) TestCheckHost(c *gc.C) {
+	hosts, err := readVMHosts(twoDotOh, parseJSON(c, vmHostsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	numa, flat := hosts[0], hosts[1]
+	for i, test := range []struct {
+		spec    ComposeSpec
+		host    VMHost
+		message string
+	}{{
+		spec: ComposeSpec{PinnedCores: []int{2, 5}, HugepagesBacked: true, Memory: 2048},
+		host: numa,
+	}, {
+		// Without pinning or hugepages the NUMA layout does not matter.
+		spec: ComposeSpec{Cores: 2},
+		host: flat,
+	}, {
+		spec:    ComposeSpec{PinnedCores: []int{1}},
+		host:    numa,
+		message: `PinnedCores: core 1 is not free on VM host "gentle-eel"`,
+	}, {
+		spec:    ComposeSpec{HugepagesBacked: true, Memory: 4096},
+		host:    numa,
+		message: `Memory: 4096 MB is more than the 2048 MB of free hugepages on VM host "gentle-eel"`,
+	}, {
+		spec:    ComposeSpec{HugepagesBacked: true, Memory: 1024},
+		host:    flat,
+		message: `NUMA pinning on VM host "by-address" without a NUMA layout not supported`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.spec.checkHost(test.host)
+		if test.message == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.message)
+		}
+	}
+}
+
+func (s *composeSuite) TestComposeSendsPinning(c *gc.C) {
+	server, host := s.getServerAndHost(c)
+	server.AddPostResponse("/MAAS/api/2.0/pods/1/?op=compose", http.StatusServiceUnavailable, "stop here")
+
+	_, err := host.ComposeAndDeploy(context.Background(), ComposeSpec{
+		PinnedCores:     []int{2, 3},
+		HugepagesBacked: true,
+		Memory:          1024,
+		Interfaces:      []InterfaceSpec{{Label: "eth0", Space: "storage"}, {Label: "eth1", Space: "public"}},
+	})
+	c.Assert(err, gc.ErrorMatches, "stop here")
+	form := server.LastRequest().PostForm
+	c.Check(form["pinned_cores"], jc.DeepEquals, []string{"2", "3"})
+	c.Check(form.Get("hugepages_backed"), gc.Equals, "true")
+	c.Check(form.Get("interfaces"), gc.Equals, "eth0:space=storage;eth1:space=public")
+	c.Check(form.Get("cores"), gc.Equals, "")
+}
+
+func (s *composeSuite) TestComposeChecksHost(c *gc.C) {
+	server, host := s.getServerAndHost(c)
+	_, err := host.ComposeAndDeploy(context.Background(), ComposeSpec{PinnedCores: []int{0}})
+	c.Check(err, jc.Satisfies, IsArgumentError)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// The names of the region configuration values that have typed helpers on
+// the Controller. Any other configuration value can be accessed using
+// GetConfig and SetConfig directly.
+const (
+	ConfigNTPServers       = "ntp_servers"
+	ConfigUpstreamDNS      = "upstream_dns"
+	ConfigDNSSECValidation = "dnssec_validation"
+	ConfigRemoteSyslog     = "remote_syslog"
+	ConfigCurtinVerbose    = "curtin_verbose"
+	ConfigKernelOptions    = "kernel_opts"
+)
+
+// DNSSECValidation is the mode MAAS uses when validating DNSSEC responses
+// from the upstream DNS servers.
+type DNSSECValidation string
+
+// The valid DNSSEC validation modes.
+const (
+	DNSSECAuto DNSSECValidation = "auto"
+	DNSSECYes  DNSSECValidation = "yes"
+	DNSSECNo   DNSSECValidation = "no"
+)
+
+// Validate checks that the mode is one that MAAS understands.
+func (d DNSSECValidation) Validate() error {
+	switch d {
+	case DNSSECAuto, DNSSECYes, DNSSECNo:
+		return nil
+	}
+	return errors.NotValidf("DNSSEC validation %q", string(d))
+}
+
+// splitConfigList splits a configuration value that holds a list of
+// addresses. MAAS accepts both spaces and commas as separators.
+func splitConfigList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+// configString converts a configuration value returned by get_config into a
+// string. Unset values are returned as null, which becomes the empty string.
+func configString(name string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", NewDeserializationError("config %q: expected string, got %T", name, value)
+}
+
+// configBool converts a configuration value returned by get_config into a
+// bool. Unset values are returned as null, which becomes false.
+func configBool(name string, value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+	return false, NewDeserializationError("config %q: expected bool, got %T", name, value)
+}
+
+func validateNTPServer(server string) error {
+	if server == "" {
+		return errors.NotValidf("empty NTP server")
+	}
+	if net.ParseIP(server) != nil {
+		return nil
+	}
+	if !isValidHostname(server) {
+		return errors.NotValidf("NTP server %q", server)
+	}
+	return nil
+}
+
+func validateUpstreamDNS(server string) error {
+	if net.ParseIP(server) == nil {
+		return errors.NotValidf("upstream DNS server %q", server)
+	}
+	return nil
+}
+
+// validateRemoteSyslog checks a remote syslog value in the form host[:port].
+// The empty string is valid and means that remote logging is disabled.
+func validateRemoteSyslog(value string) error {
+	if value == "" {
+		return nil
+	}
+	host := value
+	// A bare IPv6 address contains colons but has no port.
+	if strings.Contains(value, ":") && net.ParseIP(value) == nil {
+		h, port, err := net.SplitHostPort(value)
+		if err != nil {
+			return errors.NotValidf("remote syslog %q", value)
+		}
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			return errors.NotValidf("remote syslog port %q", port)
+		}
+		host = h
+	}
+	if net.ParseIP(host) == nil && !isValidHostname(host) {
+		return errors.NotValidf("remote syslog host %q", host)
+	}
+	return nil
+}
+
+// isValidHostname performs a basic check against RFC 1123 hostname rules.
+func isValidHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
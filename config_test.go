@@ -0,0 +1,88 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type configSuite struct{}
+
+var _ = gc.Suite(&configSuite{})
+
+func (*configSuite) TestDNSSECValidationValidate(c *gc.C) {
+	for _, mode := range []DNSSECValidation{DNSSECAuto, DNSSECYes, DNSSECNo} {
+		c.Check(mode.Validate(), jc.ErrorIsNil)
+	}
+	err := DNSSECValidation("Yes").Validate()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, `DNSSEC validation "Yes" not valid`)
+}
+
+func (*configSuite) TestSplitConfigList(c *gc.C) {
+	c.Check(splitConfigList(""), gc.HasLen, 0)
+	c.Check(splitConfigList("a b,c, d"), jc.DeepEquals, []string{"a", "b", "c", "d"})
+}
+
+func (*configSuite) TestValidateNTPServer(c *gc.C) {
+	for _, server := range []string{"ntp.ubuntu.com", "ntp.ubuntu.com.", "10.0.0.1", "fd00::1"} {
+		c.Check(validateNTPServer(server), jc.ErrorIsNil)
+	}
+	for _, server := range []string{"", "-bad.com", "bad..com", "under_score.com"} {
+		c.Check(validateNTPServer(server), jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (*configSuite) TestValidateRemoteSyslog(c *gc.C) {
+	for i, test := range []struct {
+		value   string
+		errText string
+	}{{
+		value: "",
+	}, {
+		value: "syslog.example.com",
+	}, {
+		value: "syslog.example.com:514",
+	}, {
+		value: "10.0.0.1:5247",
+	}, {
+		value: "fd00::1",
+	}, {
+		value: "[fd00::1]:514",
+	}, {
+		value:   "syslog.example.com:",
+		errText: `remote syslog port "" not valid`,
+	}, {
+		value:   "syslog.example.com:0",
+		errText: `remote syslog port "0" not valid`,
+	}, {
+		value:   "syslog.example.com:syslog",
+		errText: `remote syslog port "syslog" not valid`,
+	}, {
+		value:   "bad host:514",
+		errText: `remote syslog host "bad host" not valid`,
+	}} {
+		c.Logf("test %d: %q", i, test.value)
+		err := validateRemoteSyslog(test.value)
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err, gc.ErrorMatches, test.errText)
+		}
+	}
+}
+
+func (*configSuite) TestConfigBool(c *gc.C) {
+	value, err := configBool("curtin_verbose", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(value, jc.IsFalse)
+	value, err = configBool("curtin_verbose", true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(value, jc.IsTrue)
+	_, err = configBool("curtin_verbose", "true")
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
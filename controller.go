@@ -4,13 +4,16 @@
 package gomaasapi
 
 import (
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 	"sync/atomic"
 
@@ -42,6 +45,62 @@ var (
 type ControllerArgs struct {
 	BaseURL string
 	APIKey  string
+
+	// Credentials is used to read the API key when APIKey is empty.
+	Credentials Credentials
+
+	// Transport optionally tunes connection reuse, HTTP/2 and TLS session
+	// caching. If nil, each request uses a new connection.
+	Transport *TransportOptions
+
+	// HTTPClient, if not nil, sends the requests, for setups that need a
+	// transport of their own, such as test harnesses that serve the API in
+	// process. Transport and ProxyURL are not used with it.
+	HTTPClient *http.Client
+
+	// ProxyURL, if not empty, is the proxy the requests of this controller
+	// are sent through, whatever the proxy environment variables say. It
+	// overrides the ProxyURL of Transport. See TransportOptions.ProxyURL.
+	ProxyURL string
+
+	// SignatureMode selects where requests carry their OAuth credentials.
+	// The zero value uses the Authorization header.
+	SignatureMode OAuthSignatureMode
+
+	// NonceSource generates the OAuth nonces of requests. If nil, the
+	// source from NewRandomNonceSource is used.
+	NonceSource NonceSource
+
+	// SigningKey, if not nil, signs requests with the OAuth RSA-SHA1 method
+	// instead of PLAINTEXT, for API gateways in front of MAAS that require
+	// it. See ParseRSAPrivateKey.
+	SigningKey *rsa.PrivateKey
+
+	// DecodeMode selects how strictly responses are checked against the
+	// expected types. The zero value keeps the default checking.
+	DecodeMode DecodeMode
+
+	// Debug, if true, logs every request and response in full at debug
+	// level, with credentials and user data redacted. See Client.Debug.
+	Debug bool
+
+	// Clock is used for request timestamps, retry waits and the polling of
+	// the helpers that wait for machines, such as DeployMany. If nil,
+	// WallClock is used.
+	Clock Clock
+
+	// Scheduler, if not nil, limits the requests in progress and lets
+	// interactive requests go before bulk listings. See RequestScheduler.
+	Scheduler *RequestScheduler
+
+	// CircuitBreaker, if not nil, fails requests at once while the region
+	// is failing. See CircuitBreaker.
+	CircuitBreaker *CircuitBreaker
+
+	// CoalesceGets, if true, makes identical GET requests made through the
+	// controller at the same time share one request to MAAS. See
+	// GetCoalescer.
+	CoalesceGets bool
 }
 
 // NewController creates an authenticated client to the MAAS API, and checks
@@ -50,6 +109,38 @@ type ControllerArgs struct {
 // If the APIKey is not valid, a NotValid error is returned.
 // If the credentials are incorrect, a PermissionError is returned.
 func NewController(args ControllerArgs) (Controller, error) {
+	if err := args.SignatureMode.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.DecodeMode.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	apiKey := args.APIKey
+	if apiKey == "" && args.Credentials != nil {
+		var err error
+		if apiKey, err = args.Credentials.APIKey(); err != nil {
+			return nil, errors.Annotate(err, "reading API key")
+		}
+	}
+	httpClient := args.HTTPClient
+	if httpClient == nil && (args.Transport != nil || args.ProxyURL != "") {
+		// Without Transport, keep a new connection for each request.
+		options := TransportOptions{DisableKeepAlives: true}
+		if args.Transport != nil {
+			options = *args.Transport
+		}
+		if args.ProxyURL != "" {
+			options.ProxyURL = args.ProxyURL
+		}
+		var err error
+		if httpClient, err = NewHTTPClient(options); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	var coalescer *GetCoalescer
+	if args.CoalesceGets {
+		coalescer = NewGetCoalescer()
+	}
 	// For now we don't need to test multiple versions. It is expected that at
 	// some time in the future, we will try the most up to date version and then
 	// work our way backwards.
@@ -59,7 +150,7 @@ func NewController(args ControllerArgs) (Controller, error) {
 		if err != nil {
 			return nil, errors.Errorf("bad version defined in supported versions: %q", apiVersion)
 		}
-		client, err := NewAuthenticatedClient(args.BaseURL, args.APIKey, apiVersion)
+		client, err := newAuthenticatedClient(args.BaseURL, apiKey, apiVersion, args.SignatureMode, args.NonceSource, args.SigningKey)
 		if err != nil {
 			// If the credentials aren't valid, return now.
 			if errors.IsNotValid(err) {
@@ -69,11 +160,22 @@ func NewController(args ControllerArgs) (Controller, error) {
 			// is an unexpected error and return now.
 			return nil, NewUnexpectedError(err)
 		}
+		client.HTTPClient = httpClient
+		client.Debug = args.Debug
+		client.Clock = args.Clock
+		client.Scheduler = args.Scheduler
+		client.CircuitBreaker = args.CircuitBreaker
+		client.Coalescer = coalescer
 		controllerVersion := version.Number{
 			Major: major,
 			Minor: minor,
 		}
-		controller := &controller{client: client}
+		controller := &controller{
+			client:     client,
+			decodeMode: args.DecodeMode,
+			clock:      clockOrWall(args.Clock),
+			networks:   &networkIndex{},
+		}
 		// The controllerVersion returned from the function will include any patch version.
 		controller.capabilities, controller.apiVersion, err = controller.readAPIVersion(controllerVersion)
 		if err != nil {
@@ -94,6 +196,19 @@ type controller struct {
 	client       *Client
 	apiVersion   version.Number
 	capabilities set.Strings
+	decodeMode   DecodeMode
+	clock        Clock
+	networks     *networkIndex
+
+	// serverVersion is the MAAS version from the version response, such
+	// as "2.9.2", or "" if it was not given.
+	serverVersion string
+}
+
+// markLeaves prepares a response for the readers according to the decode
+// mode of the controller.
+func (c *controller) markLeaves(source interface{}) interface{} {
+	return markLeaves(source, c.decodeMode)
 }
 
 // Capabilities implements Controller.
@@ -107,7 +222,7 @@ func (c *controller) BootResources() ([]BootResource, error) {
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	resources, err := readBootResources(c.apiVersion, source)
+	resources, err := readBootResources(c.apiVersion, c.markLeaves(source))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -118,13 +233,80 @@ func (c *controller) BootResources() ([]BootResource, error) {
 	return result, nil
 }
 
+// CreateBootSourceArgs is an argument struct for passing information into
+// CreateBootSource. URL is required, and one of KeyringFilename or
+// KeyringData should be specified unless the source is unsigned.
+type CreateBootSourceArgs struct {
+	URL             string
+	KeyringFilename string
+	KeyringData     []byte
+}
+
+// Validate checks that the URL is set, and that at most one of the keyring
+// values is specified.
+func (a *CreateBootSourceArgs) Validate() error {
+	if a.URL == "" {
+		return errors.NotValidf("missing URL")
+	}
+	if a.KeyringFilename != "" && len(a.KeyringData) > 0 {
+		return errors.NotValidf("specifying KeyringFilename and KeyringData")
+	}
+	return nil
+}
+
+// BootSources implements Controller.
+func (c *controller) BootSources() ([]BootSource, error) {
+	source, err := c.get("boot-sources")
+	if err != nil {
+		return nil, translateBootSourceError(err)
+	}
+	bootSources, err := readBootSources(c.apiVersion, c.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []BootSource
+	for _, b := range bootSources {
+		b.controller = c
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+// CreateBootSource implements Controller.
+func (c *controller) CreateBootSource(args CreateBootSourceArgs) (BootSource, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("url", args.URL)
+	params.MaybeAdd("keyring_filename", args.KeyringFilename)
+	var files map[string][]byte
+	if len(args.KeyringData) > 0 {
+		files = map[string][]byte{"keyring_data": args.KeyringData}
+	}
+	bytes, err := c._postRaw("boot-sources", "", params.Values, files)
+	if err != nil {
+		return nil, translateBootSourceError(err)
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(bytes, &parsed); err != nil {
+		return nil, errors.Trace(err)
+	}
+	bootSource, err := readBootSource(c.apiVersion, c.markLeaves(parsed))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	bootSource.controller = c
+	return bootSource, nil
+}
+
 // Fabrics implements Controller.
 func (c *controller) Fabrics() ([]Fabric, error) {
 	source, err := c.get("fabrics")
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	fabrics, err := readFabrics(c.apiVersion, source)
+	fabrics, err := readFabrics(c.apiVersion, c.markLeaves(source))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -141,24 +323,43 @@ func (c *controller) Spaces() ([]Space, error) {
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	spaces, err := readSpaces(c.apiVersion, source)
+	spaces, err := readSpaces(c.apiVersion, c.markLeaves(source))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	var result []Space
 	for _, space := range spaces {
+		space.controller = c
 		result = append(result, space)
 	}
 	return result, nil
 }
 
+// Subnets implements Controller.
+func (c *controller) Subnets() ([]Subnet, error) {
+	source, err := c.get("subnets")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	subnets, err := readSubnets(c.apiVersion, c.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Subnet
+	for _, subnet := range subnets {
+		subnet.controller = c
+		result = append(result, subnet)
+	}
+	return result, nil
+}
+
 // StaticRoutes implements Controller.
 func (c *controller) StaticRoutes() ([]StaticRoute, error) {
 	source, err := c.get("static-routes")
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	staticRoutes, err := readStaticRoutes(c.apiVersion, source)
+	staticRoutes, err := readStaticRoutes(c.apiVersion, c.markLeaves(source))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -175,7 +376,7 @@ func (c *controller) Zones() ([]Zone, error) {
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	zones, err := readZones(c.apiVersion, source)
+	zones, err := readZones(c.apiVersion, c.markLeaves(source))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -195,13 +396,21 @@ type DevicesArgs struct {
 	Domain       string
 	Zone         string
 	AgentName    string
+
+	// Expand, if true, resolves the fabric of each VLAN and the space of
+	// each subnet referenced by the devices' interfaces.
+	Expand bool
 }
 
 // Devices implements Controller.
 func (c *controller) Devices(args DevicesArgs) ([]Device, error) {
+	macs, err := normalizeMACAddresses("MACAddresses", args.MACAddresses)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	params := NewURLParams()
 	params.MaybeAddMany("hostname", args.Hostname)
-	params.MaybeAddMany("mac_address", args.MACAddresses)
+	params.MaybeAddMany("mac_address", macs)
 	params.MaybeAddMany("id", args.SystemIDs)
 	params.MaybeAdd("domain", args.Domain)
 	params.MaybeAdd("zone", args.Zone)
@@ -210,13 +419,22 @@ func (c *controller) Devices(args DevicesArgs) ([]Device, error) {
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	devices, err := readDevices(c.apiVersion, source)
+	devices, err := readDevices(c.apiVersion, c.markLeaves(source))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	var expander *referenceExpander
+	if args.Expand && len(devices) > 0 {
+		if expander, err = c.newReferenceExpander(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	var result []Device
 	for _, d := range devices {
 		d.controller = c
+		if expander != nil {
+			expander.expandInterfaces(d.interfaceSet...)
+		}
 		result = append(result, d)
 	}
 	return result, nil
@@ -236,10 +454,14 @@ func (c *controller) CreateDevice(args CreateDeviceArgs) (Device, error) {
 	if len(args.MACAddresses) == 0 {
 		return nil, NewBadRequestError("at least one MAC address must be specified")
 	}
+	macs, err := normalizeMACAddresses("MACAddresses", args.MACAddresses)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	params := NewURLParams()
 	params.MaybeAdd("hostname", args.Hostname)
 	params.MaybeAdd("domain", args.Domain)
-	params.MaybeAddMany("mac_addresses", args.MACAddresses)
+	params.MaybeAddMany("mac_addresses", macs)
 	params.MaybeAdd("parent", args.Parent)
 	result, err := c.post("devices", "", params.Values)
 	if err != nil {
@@ -252,7 +474,7 @@ func (c *controller) CreateDevice(args CreateDeviceArgs) (Device, error) {
 		return nil, NewUnexpectedError(err)
 	}
 
-	device, err := readDevice(c.apiVersion, result)
+	device, err := readDevice(c.apiVersion, c.markLeaves(result))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -270,37 +492,107 @@ type MachinesArgs struct {
 	Zone         string
 	AgentName    string
 	OwnerData    map[string]string
+
+	// Expand, if true, resolves the fabric of each VLAN and the space of
+	// each subnet referenced by the machines' interfaces.
+	Expand bool
+
+	// Fields, if not empty, limits the machines to the named fields of the
+	// MAAS response, such as "hostname" and "status_name". The
+	// resource_uri and system_id are always kept. MAAS versions that
+	// support it only send these fields; the response of others is
+	// trimmed as it is read. The accessors for the other fields return
+	// zero values, and Zone returns nil. OwnerData filtering needs the
+	// owner_data field.
+	Fields []string
 }
 
 // Machines implements Controller.
 func (c *controller) Machines(args MachinesArgs) ([]Machine, error) {
+	macs, err := normalizeMACAddresses("MACAddresses", args.MACAddresses)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	params := NewURLParams()
 	params.MaybeAddMany("hostname", args.Hostnames)
-	params.MaybeAddMany("mac_address", args.MACAddresses)
+	params.MaybeAddMany("mac_address", macs)
 	params.MaybeAddMany("id", args.SystemIDs)
 	params.MaybeAdd("domain", args.Domain)
 	params.MaybeAdd("zone", args.Zone)
 	params.MaybeAdd("agent_name", args.AgentName)
+	params.MaybeAddMany("fields", args.Fields)
 	// At the moment the MAAS API doesn't support filtering by owner
 	// data so we do that ourselves below.
 	source, err := c.getQuery("machines", params.Values)
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	machines, err := readMachines(c.apiVersion, source)
+	machines, err := readSelectedMachines(c.apiVersion, c.markLeaves(source), args.Fields)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	var expander *referenceExpander
+	if args.Expand && len(machines) > 0 {
+		if expander, err = c.newReferenceExpander(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	var result []Machine
 	for _, m := range machines {
 		m.controller = c
 		if ownerDataMatches(m.ownerData, args.OwnerData) {
+			if expander != nil {
+				expander.expandInterfaces(m.bootInterface)
+				expander.expandInterfaces(m.interfaceSet...)
+			}
 			result = append(result, m)
 		}
 	}
 	return result, nil
 }
 
+// StreamMachines implements Controller.
+func (c *controller) StreamMachines(args MachinesArgs, callback func(Machine) error) error {
+	macs, err := normalizeMACAddresses("MACAddresses", args.MACAddresses)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAddMany("hostname", args.Hostnames)
+	params.MaybeAddMany("mac_address", macs)
+	params.MaybeAddMany("id", args.SystemIDs)
+	params.MaybeAdd("domain", args.Domain)
+	params.MaybeAdd("zone", args.Zone)
+	params.MaybeAdd("agent_name", args.AgentName)
+	params.MaybeAddMany("fields", args.Fields)
+	// The fabrics and spaces are fetched up front, as the number of
+	// machines isn't known until the stream has been read.
+	var expander *referenceExpander
+	if args.Expand {
+		var err error
+		if expander, err = c.newReferenceExpander(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	logger.Tracef("request: GET %smachines/ (streamed)", c.client.APIURL)
+	body, err := c.client.GetStream(&url.URL{Path: "machines/"}, "", params.Values)
+	if err != nil {
+		return NewUnexpectedError(err)
+	}
+	defer body.Close()
+	return readMachineStream(c.apiVersion, c.decodeMode, body, args.Fields, func(m *machine) error {
+		m.controller = c
+		if !ownerDataMatches(m.ownerData, args.OwnerData) {
+			return nil
+		}
+		if expander != nil {
+			expander.expandInterfaces(m.bootInterface)
+			expander.expandInterfaces(m.interfaceSet...)
+		}
+		return callback(m)
+	})
+}
+
 func ownerDataMatches(ownerData, filter map[string]string) bool {
 	for key, value := range filter {
 		if ownerData[key] != value {
@@ -398,6 +690,8 @@ type AllocateMachineArgs struct {
 	NotTags   []string
 	Zone      string
 	NotInZone []string
+	// Pool is the name of the resource pool the machine must be in.
+	Pool string
 	// Storage represents the required disks on the Machine. If any are specified
 	// the first value is used for the root disk.
 	Storage []StorageSpec
@@ -406,15 +700,54 @@ type AllocateMachineArgs struct {
 	Interfaces []InterfaceSpec
 	// NotSpace is a machine level constraint, and applies to the entire machine
 	// rather than specific interfaces.
-	NotSpace  []string
-	AgentName string
-	Comment   string
-	DryRun    bool
+	NotSpace []string
+	// NotSubnets excludes machines with an interface on any of the subnets,
+	// given in CIDR form.
+	NotSubnets []string
+	AgentName  string
+	Comment    string
+	DryRun     bool
+
+	// IdempotencyKey, if set, makes retrying the allocation safe. A machine
+	// already allocated with the same key is returned instead of
+	// allocating another one. The key is sent as the agent name, so
+	// AgentName must be empty, and is also recorded in the owner data
+	// under IdempotencyKeyOwnerData. It is ignored for a dry run.
+	IdempotencyKey string
+}
+
+// architecture returns the normalized Architecture.
+func (a *AllocateMachineArgs) architecture() string {
+	arch, _ := NormalizeArchitecture(a.Architecture)
+	return arch
 }
 
 // Validate makes sure that any labels specifed in Storage or Interfaces
-// are unique, and that the required specifications are valid.
+// are unique, and that the required specifications are valid. Malformed
+// values in the other fields are reported as an ArgumentError.
 func (a *AllocateMachineArgs) Validate() error {
+	if a.Hostname != "" && !isValidHostname(a.Hostname) {
+		return NewArgumentError("Hostname", "%q is not a valid hostname", a.Hostname)
+	}
+	if a.Architecture != "" {
+		if _, err := NormalizeArchitecture(a.Architecture); err != nil {
+			return NewArgumentError("Architecture", "%q is not of the form arch or arch/subarch", a.Architecture)
+		}
+	}
+	if a.IdempotencyKey != "" && a.AgentName != "" {
+		return NewArgumentError("AgentName", "cannot be set with IdempotencyKey, which is sent as the agent name")
+	}
+	if a.MinCPUCount < 0 {
+		return NewArgumentError("MinCPUCount", "%d is negative", a.MinCPUCount)
+	}
+	if a.MinMemory < 0 {
+		return NewArgumentError("MinMemory", "%d is negative", a.MinMemory)
+	}
+	for _, cidr := range a.NotSubnets {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return NewArgumentError("NotSubnets", "%q is not a valid CIDR", cidr)
+		}
+	}
 	storageLabels := set.NewStrings()
 	for _, spec := range a.Storage {
 		if err := spec.Validate(); err != nil {
@@ -466,6 +799,9 @@ func (a *AllocateMachineArgs) notSubnets() []string {
 	for _, v := range a.NotSpace {
 		values = append(values, "space:"+v)
 	}
+	for _, v := range a.NotSubnets {
+		values = append(values, "cidr:"+v)
+	}
 	return values
 }
 
@@ -488,9 +824,24 @@ type ConstraintMatches struct {
 // constraints cannot be met.
 func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, ConstraintMatches, error) {
 	var matches ConstraintMatches
+	if err := args.Validate(); err != nil {
+		return nil, matches, errors.Trace(err)
+	}
+	idempotent := args.IdempotencyKey != "" && !args.DryRun
+	agentName := args.AgentName
+	if idempotent {
+		existing, err := c.findIdempotentAllocation(args)
+		if err != nil {
+			return nil, matches, errors.Trace(err)
+		}
+		if existing != nil {
+			return existing, matches, nil
+		}
+		agentName = args.IdempotencyKey
+	}
 	params := NewURLParams()
 	params.MaybeAdd("name", args.Hostname)
-	params.MaybeAdd("arch", args.Architecture)
+	params.MaybeAdd("arch", args.architecture())
 	params.MaybeAddInt("cpu_count", args.MinCPUCount)
 	params.MaybeAddInt("mem", args.MinMemory)
 	params.MaybeAddMany("tags", args.Tags)
@@ -500,7 +851,8 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 	params.MaybeAddMany("not_subnets", args.notSubnets())
 	params.MaybeAdd("zone", args.Zone)
 	params.MaybeAddMany("not_in_zone", args.NotInZone)
-	params.MaybeAdd("agent_name", args.AgentName)
+	params.MaybeAdd("pool", args.Pool)
+	params.MaybeAdd("agent_name", agentName)
 	params.MaybeAdd("comment", args.Comment)
 	params.MaybeAddBool("dry_run", args.DryRun)
 	result, err := c.post("machines", "allocate", params.Values)
@@ -515,7 +867,7 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 		return nil, matches, NewUnexpectedError(err)
 	}
 
-	machine, err := readMachine(c.apiVersion, result)
+	machine, err := readMachine(c.apiVersion, c.markLeaves(result))
 	if err != nil {
 		return nil, matches, errors.Trace(err)
 	}
@@ -527,6 +879,13 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 		return nil, matches, errors.Trace(err)
 	}
 
+	if idempotent {
+		if err := recordIdempotencyKey(machine, args.IdempotencyKey); err != nil {
+			// The machine is allocated, so return it for the caller
+			// to release or retry.
+			return machine, matches, errors.Trace(err)
+		}
+	}
 	return machine, matches, nil
 }
 
@@ -565,6 +924,17 @@ func (c *controller) ReleaseMachines(args ReleaseMachinesArgs) error {
 	return nil
 }
 
+// actionComment returns the comment for an action that a helper takes on
+// the user's behalf, such as releasing a machine that failed to deploy.
+// The reason is followed by the user's comment for the operation, if any,
+// so that the event log shows both.
+func actionComment(reason, comment string) string {
+	if comment == "" {
+		return reason
+	}
+	return reason + ": " + comment
+}
+
 // Files implements Controller.
 func (c *controller) Files(prefix string) ([]File, error) {
 	params := NewURLParams()
@@ -573,7 +943,7 @@ func (c *controller) Files(prefix string) ([]File, error) {
 	if err != nil {
 		return nil, NewUnexpectedError(err)
 	}
-	files, err := readFiles(c.apiVersion, source)
+	files, err := readFiles(c.apiVersion, c.markLeaves(source))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -599,7 +969,7 @@ func (c *controller) GetFile(filename string) (File, error) {
 		}
 		return nil, NewUnexpectedError(err)
 	}
-	file, err := readFile(c.apiVersion, source)
+	file, err := readFile(c.apiVersion, c.markLeaves(source))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -670,6 +1040,149 @@ func (c *controller) AddFile(args AddFileArgs) error {
 	return nil
 }
 
+// GetConfig implements Controller.
+func (c *controller) GetConfig(name string) (interface{}, error) {
+	if name == "" {
+		return nil, errors.NotValidf("missing name")
+	}
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	value, err := c._get("maas", "get_config", params.Values)
+	if err != nil {
+		return nil, translateConfigError(err)
+	}
+	return value, nil
+}
+
+// SetConfig implements Controller.
+func (c *controller) SetConfig(name, value string) error {
+	if name == "" {
+		return errors.NotValidf("missing name")
+	}
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	params.Values.Add("value", value)
+	if _, err := c._postRaw("maas", "set_config", params.Values, nil); err != nil {
+		return translateConfigError(err)
+	}
+	return nil
+}
+
+func (c *controller) getConfigString(name string) (string, error) {
+	value, err := c.GetConfig(name)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return configString(name, value)
+}
+
+// NTPServers implements Controller.
+func (c *controller) NTPServers() ([]string, error) {
+	value, err := c.getConfigString(ConfigNTPServers)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return splitConfigList(value), nil
+}
+
+// SetNTPServers implements Controller.
+func (c *controller) SetNTPServers(servers []string) error {
+	for _, server := range servers {
+		if err := validateNTPServer(server); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return c.SetConfig(ConfigNTPServers, strings.Join(servers, " "))
+}
+
+// UpstreamDNS implements Controller.
+func (c *controller) UpstreamDNS() ([]string, error) {
+	value, err := c.getConfigString(ConfigUpstreamDNS)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return splitConfigList(value), nil
+}
+
+// SetUpstreamDNS implements Controller.
+func (c *controller) SetUpstreamDNS(servers []string) error {
+	for _, server := range servers {
+		if err := validateUpstreamDNS(server); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return c.SetConfig(ConfigUpstreamDNS, strings.Join(servers, " "))
+}
+
+// DNSSECValidation implements Controller.
+func (c *controller) DNSSECValidation() (DNSSECValidation, error) {
+	value, err := c.getConfigString(ConfigDNSSECValidation)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return DNSSECValidation(value), nil
+}
+
+// SetDNSSECValidation implements Controller.
+func (c *controller) SetDNSSECValidation(mode DNSSECValidation) error {
+	if err := mode.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.SetConfig(ConfigDNSSECValidation, string(mode))
+}
+
+// RemoteSyslog implements Controller.
+func (c *controller) RemoteSyslog() (string, error) {
+	return c.getConfigString(ConfigRemoteSyslog)
+}
+
+// SetRemoteSyslog implements Controller.
+func (c *controller) SetRemoteSyslog(hostPort string) error {
+	if err := validateRemoteSyslog(hostPort); err != nil {
+		return errors.Trace(err)
+	}
+	return c.SetConfig(ConfigRemoteSyslog, hostPort)
+}
+
+// CurtinVerbose implements Controller.
+func (c *controller) CurtinVerbose() (bool, error) {
+	value, err := c.GetConfig(ConfigCurtinVerbose)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return configBool(ConfigCurtinVerbose, value)
+}
+
+// SetCurtinVerbose implements Controller.
+func (c *controller) SetCurtinVerbose(verbose bool) error {
+	return c.SetConfig(ConfigCurtinVerbose, strconv.FormatBool(verbose))
+}
+
+// KernelOptions implements Controller.
+func (c *controller) KernelOptions() (string, error) {
+	return c.getConfigString(ConfigKernelOptions)
+}
+
+// SetKernelOptions implements Controller.
+func (c *controller) SetKernelOptions(options string) error {
+	if strings.ContainsAny(options, "\n\r") {
+		return errors.NotValidf("kernel options containing newlines")
+	}
+	return c.SetConfig(ConfigKernelOptions, options)
+}
+
+func translateConfigError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
 func (c *controller) checkCreds() error {
 	if _, err := c.getOp("users", "whoami"); err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -683,16 +1196,34 @@ func (c *controller) checkCreds() error {
 }
 
 func (c *controller) put(path string, params url.Values) (interface{}, error) {
+	return c.send("PUT", path, "", params, FormSerializer)
+}
+
+// send makes a PUT or POST request with the body encoded by the serializer,
+// so that typed calls can send JSON to the endpoints that accept it.
+func (c *controller) send(method, path, op string, body interface{}, serializer BodySerializer) (interface{}, error) {
 	path = EnsureTrailingSlash(path)
+	uri := &url.URL{Path: path}
+	if op != "" {
+		uri.RawQuery = canonicalQuery(url.Values{"op": {op}})
+	}
 	requestID := nextRequestID()
-	logger.Tracef("request %x: PUT %s%s, params: %s", requestID, c.client.APIURL, path, params.Encode())
-	bytes, err := c.client.Put(&url.URL{Path: path}, params)
+	if logger.IsTraceEnabled() {
+		var params string
+		if content, contentType, err := serializer.Serialize(body); err == nil {
+			params = redactBody(contentType, content)
+		}
+		logger.Tracef("request %x: %s %s%s, params: %s", requestID, method, c.client.APIURL, uri, params)
+	}
+	bytes, err := c.client.Send(method, uri, body, serializer)
 	if err != nil {
 		logger.Tracef("response %x: error: %q", requestID, err.Error())
 		logger.Tracef("error detail: %#v", err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	if logger.IsTraceEnabled() {
+		logger.Tracef("response %x: %s", requestID, redactBody("application/json", bytes))
+	}
 
 	var parsed interface{}
 	err = json.Unmarshal(bytes, &parsed)
@@ -703,17 +1234,7 @@ func (c *controller) put(path string, params url.Values) (interface{}, error) {
 }
 
 func (c *controller) post(path, op string, params url.Values) (interface{}, error) {
-	bytes, err := c._postRaw(path, op, params, nil)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	var parsed interface{}
-	err = json.Unmarshal(bytes, &parsed)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	return parsed, nil
+	return c.send("POST", path, op, params, FormSerializer)
 }
 
 func (c *controller) postFile(path, op string, params url.Values, fileContent []byte) (interface{}, error) {
@@ -730,15 +1251,23 @@ func (c *controller) _postRaw(path, op string, params url.Values, files map[stri
 		if op != "" {
 			opArg = "?op=" + op
 		}
-		logger.Tracef("request %x: POST %s%s%s, params=%s", requestID, c.client.APIURL, path, opArg, params.Encode())
+		logger.Tracef("request %x: POST %s%s%s, params=%s", requestID, c.client.APIURL, path, opArg, redactValues(params).Encode())
+	}
+	var bytes []byte
+	var err error
+	if op == "" {
+		bytes, err = c.client.PostWithoutOp(&url.URL{Path: path}, params, files)
+	} else {
+		bytes, err = c.client.Post(&url.URL{Path: path}, op, params, files)
 	}
-	bytes, err := c.client.Post(&url.URL{Path: path}, op, params, files)
 	if err != nil {
 		logger.Tracef("response %x: error: %q", requestID, err.Error())
 		logger.Tracef("error detail: %#v", err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	if logger.IsTraceEnabled() {
+		logger.Tracef("response %x: %s", requestID, redactBody("application/json", bytes))
+	}
 	return bytes, nil
 }
 
@@ -746,13 +1275,17 @@ func (c *controller) delete(path string) error {
 	path = EnsureTrailingSlash(path)
 	requestID := nextRequestID()
 	logger.Tracef("request %x: DELETE %s%s", requestID, c.client.APIURL, path)
-	err := c.client.Delete(&url.URL{Path: path})
+	bytes, err := c.client.DeleteWithResponse(&url.URL{Path: path})
 	if err != nil {
 		logger.Tracef("response %x: error: %q", requestID, err.Error())
 		logger.Tracef("error detail: %#v", err)
 		return errors.Trace(err)
 	}
-	logger.Tracef("response %x: complete", requestID)
+	if len(bytes) > 0 && logger.IsTraceEnabled() {
+		logger.Tracef("response %x: %s", requestID, redactBody("application/json", bytes))
+	} else {
+		logger.Tracef("response %x: complete", requestID)
+	}
 	return nil
 }
 
@@ -787,7 +1320,7 @@ func (c *controller) _getRaw(path, op string, params url.Values) ([]byte, error)
 	if logger.IsTraceEnabled() {
 		var query string
 		if params != nil {
-			query = "?" + params.Encode()
+			query = "?" + redactValues(params).Encode()
 		}
 		logger.Tracef("request %x: GET %s%s%s", requestID, c.client.APIURL, path, query)
 	}
@@ -797,7 +1330,9 @@ func (c *controller) _getRaw(path, op string, params url.Values) ([]byte, error)
 		logger.Tracef("error detail: %#v", err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	if logger.IsTraceEnabled() {
+		logger.Tracef("response %x: %s", requestID, redactBody("application/json", bytes))
+	}
 	return bytes, nil
 }
 
@@ -813,9 +1348,13 @@ func (c *controller) readAPIVersion(apiVersion version.Number) (set.Strings, ver
 
 	// As we care about other fields, add them.
 	fields := schema.Fields{
-		"capabilities": schema.List(schema.String()),
+		"capabilities": schema.List(stringField()),
+		"version":      schema.String(),
 	}
-	checker := schema.FieldMap(fields, nil) // no defaults
+	defaults := schema.Defaults{
+		"version": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(parsed, nil)
 	if err != nil {
 		return nil, apiVersion, WrapWithDeserializationError(err, "version response")
@@ -831,6 +1370,7 @@ func (c *controller) readAPIVersion(apiVersion version.Number) (set.Strings, ver
 	for _, value := range capabilityValues {
 		capabilities.Add(value.(string))
 	}
+	c.serverVersion = valid["version"].(string)
 
 	return capabilities, apiVersion, nil
 }
@@ -838,8 +1378,8 @@ func (c *controller) readAPIVersion(apiVersion version.Number) (set.Strings, ver
 func parseAllocateConstraintsResponse(source interface{}, machine *machine) (ConstraintMatches, error) {
 	var empty ConstraintMatches
 	matchFields := schema.Fields{
-		"storage":    schema.StringMap(schema.List(schema.ForceInt())),
-		"interfaces": schema.StringMap(schema.List(schema.ForceInt())),
+		"storage":    schema.StringMap(schema.List(intField())),
+		"interfaces": schema.StringMap(schema.List(intField())),
 	}
 	matchDefaults := schema.Defaults{
 		"storage":    schema.Omit,
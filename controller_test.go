@@ -5,8 +5,10 @@ package gomaasapi
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 
 	"github.com/juju/errors"
@@ -50,6 +52,7 @@ func (s *controllerSuite) SetUpTest(c *gc.C) {
 	server.AddGetResponse("/api/2.0/machines/?hostname=untasted-markita", http.StatusOK, "["+machineResponse+"]")
 	server.AddGetResponse("/api/2.0/spaces/", http.StatusOK, spacesResponse)
 	server.AddGetResponse("/api/2.0/static-routes/", http.StatusOK, staticRoutesResponse)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
 	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
 	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
 	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
@@ -153,7 +156,7 @@ func (s *controllerSuite) TestDevicesArgs(c *gc.C) {
 	// and make sure that all the values were set.
 	controller.Devices(DevicesArgs{
 		Hostname:     []string{"untasted-markita"},
-		MACAddresses: []string{"something"},
+		MACAddresses: []string{"52:54:00:c9:6a:47"},
 		SystemIDs:    []string{"something-else"},
 		Domain:       "magic",
 		Zone:         "foo",
@@ -165,10 +168,10 @@ func (s *controllerSuite) TestDevicesArgs(c *gc.C) {
 }
 
 func (s *controllerSuite) TestCreateDevice(c *gc.C) {
-	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, deviceResponse)
+	s.server.AddPostResponse("/api/2.0/devices/", http.StatusOK, deviceResponse)
 	controller := s.getController(c)
 	device, err := controller.CreateDevice(CreateDeviceArgs{
-		MACAddresses: []string{"a-mac-address"},
+		MACAddresses: []string{"52:54:00:c9:6a:45"},
 	})
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(device.SystemID(), gc.Equals, "4y3haf")
@@ -181,23 +184,46 @@ func (s *controllerSuite) TestCreateDeviceMissingAddress(c *gc.C) {
 	c.Assert(err.Error(), gc.Equals, "at least one MAC address must be specified")
 }
 
+func (s *controllerSuite) TestCreateDeviceSendsNoOp(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/devices/", http.StatusOK, deviceResponse)
+	controller := s.getController(c)
+	_, err := controller.CreateDevice(CreateDeviceArgs{
+		MACAddresses: []string{"52:54:00:c9:6a:45"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	request := s.server.LastRequest()
+	c.Assert(request.Method, gc.Equals, "POST")
+	c.Check(request.URL.RawQuery, gc.Equals, "")
+}
+
 func (s *controllerSuite) TestCreateDeviceBadRequest(c *gc.C) {
-	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusBadRequest, "some error")
+	s.server.AddPostResponse("/api/2.0/devices/", http.StatusBadRequest, "some error")
 	controller := s.getController(c)
 	_, err := controller.CreateDevice(CreateDeviceArgs{
-		MACAddresses: []string{"a-mac-address"},
+		MACAddresses: []string{"52:54:00:c9:6a:45"},
 	})
 	c.Assert(err, jc.Satisfies, IsBadRequestError)
 	c.Assert(err.Error(), gc.Equals, "some error")
 }
 
+func (s *controllerSuite) TestCreateDeviceValidationError(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/devices/", http.StatusBadRequest, `{"hostname": ["Node with this Hostname already exists."]}`)
+	controller := s.getController(c)
+	_, err := controller.CreateDevice(CreateDeviceArgs{
+		MACAddresses: []string{"52:54:00:c9:6a:45"},
+	})
+	c.Assert(err, jc.Satisfies, IsValidationError)
+	verr := errors.Cause(err).(*ValidationError)
+	c.Assert(verr.Messages("hostname"), jc.DeepEquals, []string{"Node with this Hostname already exists."})
+}
+
 func (s *controllerSuite) TestCreateDeviceArgs(c *gc.C) {
-	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, deviceResponse)
+	s.server.AddPostResponse("/api/2.0/devices/", http.StatusOK, deviceResponse)
 	controller := s.getController(c)
 	// Create an arg structure that sets all the values.
 	args := CreateDeviceArgs{
 		Hostname:     "foobar",
-		MACAddresses: []string{"an-address"},
+		MACAddresses: []string{"52:54:00:c9:6a:46"},
 		Domain:       "a domain",
 		Parent:       "parent",
 	}
@@ -223,6 +249,14 @@ func (s *controllerSuite) TestSpaces(c *gc.C) {
 	c.Assert(spaces, gc.HasLen, 1)
 }
 
+func (s *controllerSuite) TestSubnets(c *gc.C) {
+	controller := s.getController(c)
+	subnets, err := controller.Subnets()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 2)
+	c.Assert(subnets[0].CIDR(), gc.Equals, "192.168.100.0/24")
+}
+
 func (s *controllerSuite) TestStaticRoutes(c *gc.C) {
 	controller := s.getController(c)
 	staticRoutes, err := controller.StaticRoutes()
@@ -299,7 +333,7 @@ func (s *controllerSuite) TestMachinesArgs(c *gc.C) {
 	// and make sure that all the values were set.
 	controller.Machines(MachinesArgs{
 		Hostnames:    []string{"untasted-markita"},
-		MACAddresses: []string{"something"},
+		MACAddresses: []string{"52:54:00:c9:6a:47"},
 		SystemIDs:    []string{"something-else"},
 		Domain:       "magic",
 		Zone:         "foo",
@@ -310,6 +344,52 @@ func (s *controllerSuite) TestMachinesArgs(c *gc.C) {
 	c.Assert(request.URL.Query(), gc.HasLen, 6)
 }
 
+func (s *controllerSuite) TestStreamMachines(c *gc.C) {
+	controller := s.getController(c)
+	var hostnames []string
+	err := controller.StreamMachines(MachinesArgs{}, func(m Machine) error {
+		hostnames = append(hostnames, m.Hostname())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(hostnames, jc.DeepEquals, []string{"untasted-markita", "lowlier-glady", "icier-nina"})
+}
+
+func (s *controllerSuite) TestStreamMachinesFilterWithOwnerData(c *gc.C) {
+	controller := s.getController(c)
+	var hostnames []string
+	err := controller.StreamMachines(MachinesArgs{
+		OwnerData: map[string]string{
+			"braid": "jonathan blow",
+		},
+	}, func(m Machine) error {
+		hostnames = append(hostnames, m.Hostname())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(hostnames, jc.DeepEquals, []string{"lowlier-glady", "icier-nina"})
+}
+
+func (s *controllerSuite) TestStreamMachinesCallbackError(c *gc.C) {
+	controller := s.getController(c)
+	count := 0
+	err := controller.StreamMachines(MachinesArgs{}, func(m Machine) error {
+		count++
+		return errors.New("stop")
+	})
+	c.Assert(err, gc.ErrorMatches, "stop")
+	c.Assert(count, gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestStreamMachinesServerError(c *gc.C) {
+	controller := s.getController(c)
+	err := controller.StreamMachines(MachinesArgs{Zone: "missing"}, func(m Machine) error {
+		c.Fatalf("unexpected machine %q", m.SystemID())
+		return nil
+	})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
 func (s *controllerSuite) TestStorageSpec(c *gc.C) {
 	for i, test := range []struct {
 		spec StorageSpec
@@ -439,6 +519,14 @@ func (s *controllerSuite) TestAllocateMachineArgs(c *gc.C) {
 			NotSpace: []string{"foo", "bar"},
 		},
 		notSubnets: []string{"space:foo", "space:bar"},
+	}, {
+		args: AllocateMachineArgs{
+			Hostname:     "node-1.maas",
+			Architecture: "arm64/xgene-uboot",
+			NotSpace:     []string{"foo"},
+			NotSubnets:   []string{"10.0.0.0/24", "2001:db8::/64"},
+		},
+		notSubnets: []string{"space:foo", "cidr:10.0.0.0/24", "cidr:2001:db8::/64"},
 	}} {
 		c.Logf("test %d", i)
 		err := test.args.Validate()
@@ -454,6 +542,54 @@ func (s *controllerSuite) TestAllocateMachineArgs(c *gc.C) {
 	}
 }
 
+func (s *controllerSuite) TestAllocateMachineArgsArgumentErrors(c *gc.C) {
+	for i, test := range []struct {
+		args  AllocateMachineArgs
+		field string
+		err   string
+	}{{
+		args:  AllocateMachineArgs{Hostname: "bad_name"},
+		field: "Hostname",
+		err:   `Hostname: "bad_name" is not a valid hostname`,
+	}, {
+		args:  AllocateMachineArgs{Architecture: "amd64/"},
+		field: "Architecture",
+		err:   `Architecture: "amd64/" is not of the form arch or arch/subarch`,
+	}, {
+		args:  AllocateMachineArgs{Architecture: "amd 64"},
+		field: "Architecture",
+		err:   `Architecture: "amd 64" is not of the form arch or arch/subarch`,
+	}, {
+		args:  AllocateMachineArgs{MinCPUCount: -1},
+		field: "MinCPUCount",
+		err:   "MinCPUCount: -1 is negative",
+	}, {
+		args:  AllocateMachineArgs{MinMemory: -1024},
+		field: "MinMemory",
+		err:   "MinMemory: -1024 is negative",
+	}, {
+		args:  AllocateMachineArgs{NotSubnets: []string{"10.0.0.0/24", "10.0.1.0"}},
+		field: "NotSubnets",
+		err:   `NotSubnets: "10.0.1.0" is not a valid CIDR`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(err.Error(), gc.Equals, test.err)
+		if argErr, ok := errors.Cause(err).(*ArgumentError); ok {
+			c.Check(argErr.Field, gc.Equals, test.field)
+		}
+	}
+}
+
+func (s *controllerSuite) TestAllocateMachineValidatesBeforeRequest(c *gc.C) {
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	_, _, err := controller.AllocateMachine(AllocateMachineArgs{MinCPUCount: -2})
+	c.Assert(err, jc.Satisfies, IsArgumentError)
+	c.Assert(s.server.RequestCount(), gc.Equals, 0)
+}
+
 type constraintMatchInfo map[string][]int
 
 func (s *controllerSuite) addAllocateResponse(c *gc.C, status int, interfaceMatches, storageMatches constraintMatchInfo) {
@@ -557,7 +693,7 @@ func (s *controllerSuite) TestAllocateMachineArgsForm(c *gc.C) {
 	// Create an arg structure that sets all the values.
 	args := AllocateMachineArgs{
 		Hostname:     "foobar",
-		Architecture: "amd64",
+		Architecture: "X86_64",
 		MinCPUCount:  42,
 		MinMemory:    20000,
 		Tags:         []string{"good"},
@@ -567,6 +703,7 @@ func (s *controllerSuite) TestAllocateMachineArgsForm(c *gc.C) {
 		NotSpace:     []string{"special"},
 		Zone:         "magic",
 		NotInZone:    []string{"not-magic"},
+		Pool:         "gold",
 		AgentName:    "agent 42",
 		Comment:      "testing",
 		DryRun:       true,
@@ -577,7 +714,9 @@ func (s *controllerSuite) TestAllocateMachineArgsForm(c *gc.C) {
 	request := s.server.LastRequest()
 	// There should be one entry in the form values for each of the args.
 	form := request.PostForm
-	c.Assert(form, gc.HasLen, 14)
+	c.Assert(form, gc.HasLen, 15)
+	c.Assert(form.Get("pool"), gc.Equals, "gold")
+	c.Assert(form.Get("arch"), gc.Equals, "amd64")
 	// Positive space check.
 	c.Assert(form.Get("interfaces"), gc.Equals, "default:space=magic")
 	// Negative space check.
@@ -764,7 +903,7 @@ func (s *controllerSuite) assertFile(c *gc.C, request *http.Request, filename, c
 }
 
 func (s *controllerSuite) TestAddFileContent(c *gc.C) {
-	s.server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	s.server.AddPostResponse("/api/2.0/files/", http.StatusOK, "")
 	controller := s.getController(c)
 	err := controller.AddFile(AddFileArgs{
 		Filename: "foo.txt",
@@ -778,7 +917,7 @@ func (s *controllerSuite) TestAddFileContent(c *gc.C) {
 
 func (s *controllerSuite) TestAddFileReader(c *gc.C) {
 	reader := bytes.NewBufferString("test\n extra over length ignored")
-	s.server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	s.server.AddPostResponse("/api/2.0/files/", http.StatusOK, "")
 	controller := s.getController(c)
 	err := controller.AddFile(AddFileArgs{
 		Filename: "foo.txt",
@@ -814,3 +953,317 @@ func createTestServerController(c *gc.C, suite cleanup) (*SimpleTestServer, Cont
 	c.Assert(err, jc.ErrorIsNil)
 	return server, controller
 }
+
+func (s *controllerSuite) TestGetConfig(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=maas_name&op=get_config", http.StatusOK, `"my-maas"`)
+	controller := s.getController(c)
+	value, err := controller.GetConfig("maas_name")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(value, gc.Equals, "my-maas")
+}
+
+func (s *controllerSuite) TestGetConfigValidates(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.GetConfig("")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestSetConfig(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusOK, "OK")
+	controller := s.getController(c)
+	err := controller.SetConfig("maas_name", "my-maas")
+	c.Assert(err, jc.ErrorIsNil)
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "maas_name")
+	c.Check(form.Get("value"), gc.Equals, "my-maas")
+}
+
+func (s *controllerSuite) TestSetConfigBadRequest(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusBadRequest, "unknown config")
+	controller := s.getController(c)
+	err := controller.SetConfig("wat", "value")
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "unknown config")
+}
+
+func (s *controllerSuite) TestSetConfigPermission(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusForbidden, "admins only")
+	controller := s.getController(c)
+	err := controller.SetConfig("maas_name", "value")
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *controllerSuite) TestNTPServers(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=ntp_servers&op=get_config", http.StatusOK, `"ntp.ubuntu.com, 10.0.0.1"`)
+	controller := s.getController(c)
+	servers, err := controller.NTPServers()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(servers, jc.DeepEquals, []string{"ntp.ubuntu.com", "10.0.0.1"})
+}
+
+func (s *controllerSuite) TestSetNTPServers(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusOK, "OK")
+	controller := s.getController(c)
+	err := controller.SetNTPServers([]string{"ntp.ubuntu.com", "10.0.0.1"})
+	c.Assert(err, jc.ErrorIsNil)
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "ntp_servers")
+	c.Check(form.Get("value"), gc.Equals, "ntp.ubuntu.com 10.0.0.1")
+}
+
+func (s *controllerSuite) TestSetNTPServersValidates(c *gc.C) {
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	err := controller.SetNTPServers([]string{"ntp_ubuntu.com"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, `NTP server "ntp_ubuntu.com" not valid`)
+	c.Assert(s.server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *controllerSuite) TestUpstreamDNS(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=upstream_dns&op=get_config", http.StatusOK, `null`)
+	controller := s.getController(c)
+	servers, err := controller.UpstreamDNS()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(servers, gc.HasLen, 0)
+}
+
+func (s *controllerSuite) TestSetUpstreamDNSValidates(c *gc.C) {
+	controller := s.getController(c)
+	err := controller.SetUpstreamDNS([]string{"8.8.8.8", "dns.example.com"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, `upstream DNS server "dns.example.com" not valid`)
+}
+
+func (s *controllerSuite) TestDNSSECValidation(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=dnssec_validation&op=get_config", http.StatusOK, `"auto"`)
+	controller := s.getController(c)
+	mode, err := controller.DNSSECValidation()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(mode, gc.Equals, DNSSECAuto)
+}
+
+func (s *controllerSuite) TestDNSSECValidationBadValue(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=dnssec_validation&op=get_config", http.StatusOK, `42`)
+	controller := s.getController(c)
+	_, err := controller.DNSSECValidation()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *controllerSuite) TestSetDNSSECValidation(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusOK, "OK")
+	controller := s.getController(c)
+	err := controller.SetDNSSECValidation(DNSSECNo)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.server.LastRequest().PostForm.Get("value"), gc.Equals, "no")
+
+	err = controller.SetDNSSECValidation("maybe")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestRemoteSyslog(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=remote_syslog&op=get_config", http.StatusOK, `"syslog.example.com:514"`)
+	controller := s.getController(c)
+	value, err := controller.RemoteSyslog()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(value, gc.Equals, "syslog.example.com:514")
+}
+
+func (s *controllerSuite) TestSetRemoteSyslog(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusOK, "OK")
+	controller := s.getController(c)
+	err := controller.SetRemoteSyslog("")
+	c.Assert(err, jc.ErrorIsNil)
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "remote_syslog")
+	c.Check(form["value"], jc.DeepEquals, []string{""})
+
+	err = controller.SetRemoteSyslog("syslog.example.com:99999")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestCurtinVerbose(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=curtin_verbose&op=get_config", http.StatusOK, `true`)
+	controller := s.getController(c)
+	verbose, err := controller.CurtinVerbose()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(verbose, jc.IsTrue)
+}
+
+func (s *controllerSuite) TestSetCurtinVerbose(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusOK, "OK")
+	controller := s.getController(c)
+	err := controller.SetCurtinVerbose(false)
+	c.Assert(err, jc.ErrorIsNil)
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "curtin_verbose")
+	c.Check(form.Get("value"), gc.Equals, "false")
+}
+
+func (s *controllerSuite) TestKernelOptions(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/maas/?name=kernel_opts&op=get_config", http.StatusOK, `"console=ttyS0 nomodeset"`)
+	controller := s.getController(c)
+	options, err := controller.KernelOptions()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(options, gc.Equals, "console=ttyS0 nomodeset")
+}
+
+func (s *controllerSuite) TestSetKernelOptions(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/maas/?op=set_config", http.StatusOK, "OK")
+	controller := s.getController(c)
+	err := controller.SetKernelOptions("console=ttyS0")
+	c.Assert(err, jc.ErrorIsNil)
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("name"), gc.Equals, "kernel_opts")
+	c.Check(form.Get("value"), gc.Equals, "console=ttyS0")
+
+	err = controller.SetKernelOptions("quiet\nsplash")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestNewControllerWithTransport(c *gc.C) {
+	options := DefaultTransportOptions()
+	result, err := NewController(ControllerArgs{
+		BaseURL:   s.server.URL,
+		APIKey:    "fake:as:key",
+		Transport: &options,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	client := result.(*controller).client
+	c.Assert(client.HTTPClient, gc.NotNil)
+}
+
+func (s *controllerSuite) TestNewControllerBadTransport(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:   s.server.URL,
+		APIKey:    "fake:as:key",
+		Transport: &TransportOptions{MaxIdleConns: -1},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestNewControllerWithCredentials(c *gc.C) {
+	result, err := NewController(ControllerArgs{
+		BaseURL:     s.server.URL,
+		Credentials: StaticCredentials("fake:as:key"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	signer := result.(*controller).client.Signer.(*plainTextOAuthSigner)
+	c.Assert(signer.token.TokenSecret, gc.Equals, "key")
+}
+
+func (s *controllerSuite) TestNewControllerCredentialsError(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:     s.server.URL,
+		Credentials: StaticCredentials(""),
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, "reading API key: empty API key not valid")
+}
+
+func (s *controllerSuite) TestMachinesExpand(c *gc.C) {
+	controller := s.getController(c)
+	machines, err := controller.Machines(MachinesArgs{Expand: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+
+	iface := machines[0].BootInterface()
+	fabric := iface.VLAN().ExpandedFabric()
+	c.Assert(fabric, gc.NotNil)
+	c.Check(fabric.Name(), gc.Equals, "fabric-0")
+	c.Check(fabric.VLANs(), gc.Not(gc.HasLen), 0)
+
+	links := machines[0].InterfaceSet()[0].Links()
+	c.Assert(links, gc.Not(gc.HasLen), 0)
+	subnet := links[0].Subnet()
+	space := subnet.ExpandedSpace()
+	c.Assert(space, gc.NotNil)
+	c.Check(space.Name(), gc.Equals, "space-0")
+	c.Check(subnet.VLAN().ExpandedFabric(), gc.NotNil)
+}
+
+func (s *controllerSuite) TestMachinesNoExpand(c *gc.C) {
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.RequestCount(), gc.Equals, 1)
+	c.Assert(machines[0].BootInterface().VLAN().ExpandedFabric(), gc.IsNil)
+	subnet := machines[0].InterfaceSet()[0].Links()[0].Subnet()
+	c.Assert(subnet.ExpandedSpace(), gc.IsNil)
+}
+
+func (s *controllerSuite) TestMachinesExpandFailure(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	_, err := controller.Machines(MachinesArgs{Expand: true})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *controllerSuite) TestDevicesExpand(c *gc.C) {
+	controller := s.getController(c)
+	devices, err := controller.Devices(DevicesArgs{Expand: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+	fabric := devices[0].InterfaceSet()[0].VLAN().ExpandedFabric()
+	c.Assert(fabric, gc.NotNil)
+	c.Check(fabric.Name(), gc.Equals, "fabric-0")
+}
+
+func (s *controllerSuite) TestNewControllerQuerySigning(c *gc.C) {
+	// A server that, like a proxy stripping headers, only accepts
+	// credentials in the query string.
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Authorization") != "" || request.URL.Query().Get("oauth_token") != "as" {
+			http.Error(writer, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch request.URL.Path {
+		case "/api/2.0/version/":
+			fmt.Fprint(writer, versionResponse)
+		case "/api/2.0/users/":
+			fmt.Fprint(writer, `"captain awesome"`)
+		default:
+			http.NotFound(writer, request)
+		}
+	}))
+	defer server.Close()
+	_, err := NewController(ControllerArgs{
+		BaseURL:       server.URL,
+		APIKey:        "fake:as:key",
+		SignatureMode: OAuthQueryMode,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *controllerSuite) TestNewControllerBadSignatureMode(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:       s.server.URL,
+		APIKey:        "fake:as:key",
+		SignatureMode: OAuthSignatureMode(-1),
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestMachinesFields(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?fields=hostname&fields=status_name", http.StatusOK, machinesResponse)
+	controller := s.getController(c)
+	machines, err := controller.Machines(MachinesArgs{Fields: []string{"hostname", "status_name"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	c.Check(machines[0].Hostname(), gc.Equals, "untasted-markita")
+	c.Check(machines[0].OperatingSystem(), gc.Equals, "")
+	c.Check(s.server.LastRequest().URL.Query()["fields"], jc.DeepEquals, []string{"hostname", "status_name"})
+}
+
+func (s *controllerSuite) TestStreamMachinesFields(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?fields=hostname", http.StatusOK, machinesResponse)
+	controller := s.getController(c)
+	var statuses []string
+	err := controller.StreamMachines(MachinesArgs{Fields: []string{"hostname"}}, func(m Machine) error {
+		statuses = append(statuses, m.StatusName())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(statuses, jc.DeepEquals, []string{"", "", ""})
+}
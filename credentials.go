@@ -0,0 +1,298 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// APIKeyEnvVar is the environment variable that EnvCredentials reads by
+// default.
+const APIKeyEnvVar = "MAAS_API_KEY"
+
+// Credentials provides the API key used to authenticate with MAAS. The key
+// has the form "<consumer key>:<token key>:<token secret>".
+type Credentials interface {
+	APIKey() (string, error)
+}
+
+// StaticCredentials is an API key known in advance.
+type StaticCredentials string
+
+// APIKey implements Credentials.
+func (c StaticCredentials) APIKey() (string, error) {
+	if c == "" {
+		return "", errors.NotValidf("empty API key")
+	}
+	return string(c), nil
+}
+
+// EnvCredentials reads the API key from an environment variable.
+type EnvCredentials struct {
+	// Name is the environment variable to read. If empty, APIKeyEnvVar
+	// is used.
+	Name string
+}
+
+// APIKey implements Credentials.
+func (c EnvCredentials) APIKey() (string, error) {
+	name := c.Name
+	if name == "" {
+		name = APIKeyEnvVar
+	}
+	key := strings.TrimSpace(os.Getenv(name))
+	if key == "" {
+		return "", errors.NotFoundf("API key in $%s", name)
+	}
+	return key, nil
+}
+
+// CommandCredentials runs an external command and uses its standard output
+// as the API key, in the same way as a git credential helper. Surrounding
+// whitespace is removed from the output.
+type CommandCredentials struct {
+	Command string
+	Args    []string
+}
+
+// APIKey implements Credentials.
+func (c CommandCredentials) APIKey() (string, error) {
+	if c.Command == "" {
+		return "", errors.NotValidf("missing Command")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(c.Command, c.Args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", errors.Annotatef(err, "running %q: %s", c.Command, message)
+		}
+		return "", errors.Annotatef(err, "running %q", c.Command)
+	}
+	key := strings.TrimSpace(string(output))
+	if key == "" {
+		return "", errors.NotFoundf("API key in output of %q", c.Command)
+	}
+	return key, nil
+}
+
+// ProfileCredentials reads the API key of a profile stored by the maas CLI
+// with "maas login".
+type ProfileCredentials struct {
+	// Path is the maas CLI database. If empty, DefaultProfilePath is used.
+	Path string
+	// Profile is the name given to "maas login".
+	Profile string
+}
+
+// APIKey implements Credentials.
+func (c ProfileCredentials) APIKey() (string, error) {
+	if c.Profile == "" {
+		return "", errors.NotValidf("missing Profile")
+	}
+	path := c.Path
+	if path == "" {
+		var err error
+		if path, err = DefaultProfilePath(); err != nil {
+			return "", errors.Trace(err)
+		}
+	}
+	profile, err := readCLIProfile(path, c.Profile)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if profile.APIKey == "" {
+		return "", errors.NotFoundf("API key for anonymous profile %q", c.Profile)
+	}
+	return profile.APIKey, nil
+}
+
+// DefaultProfilePath returns the location of the maas CLI database,
+// ~/.maascli.db.
+func DefaultProfilePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return filepath.Join(home, ".maascli.db"), nil
+}
+
+// CLIProfile is a profile stored by the maas CLI.
+type CLIProfile struct {
+	Name string
+	// URL is the API URL given to "maas login", including the API version,
+	// such as http://maas.example.com/MAAS/api/2.0/.
+	URL string
+	// APIKey is empty for anonymous profiles.
+	APIKey string
+}
+
+var apiURLPattern = regexp.MustCompile(`^(.*/)api/([0-9]+\.[0-9]+)/?$`)
+
+// SplitURL splits the profile URL into the base URL of the MAAS server and
+// the API version, as needed by NewAuthenticatedClient.
+func (p CLIProfile) SplitURL() (baseURL, apiVersion string, err error) {
+	match := apiURLPattern.FindStringSubmatch(p.URL)
+	if match == nil {
+		return "", "", errors.NotValidf("maas CLI profile %q URL %q", p.Name, p.URL)
+	}
+	return match[1], match[2], nil
+}
+
+// Client returns a Client for the profile, which is anonymous if the
+// profile has no credentials.
+func (p CLIProfile) Client() (*Client, error) {
+	baseURL, apiVersion, err := p.SplitURL()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if p.APIKey == "" {
+		return NewAnonymousClient(baseURL, apiVersion)
+	}
+	return NewAuthenticatedClient(baseURL, p.APIKey, apiVersion)
+}
+
+// ControllerArgs returns the arguments for NewController that connect with
+// the profile. NewController negotiates the API version itself, so the
+// version in the profile URL is dropped.
+func (p CLIProfile) ControllerArgs() (ControllerArgs, error) {
+	baseURL, _, err := p.SplitURL()
+	if err != nil {
+		return ControllerArgs{}, errors.Trace(err)
+	}
+	return ControllerArgs{BaseURL: baseURL, APIKey: p.APIKey}, nil
+}
+
+// ReadCLIProfiles reads all the profiles from a maas CLI database, sorted
+// by name. As well as the SQLite database written by "maas login", a JSON
+// file holding an object that maps profile names to the stored profile
+// data is accepted, for profiles exported from another machine.
+func ReadCLIProfiles(path string) ([]CLIProfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Annotatef(err, "reading maas CLI profiles from %q", path)
+	}
+	sources := make(map[string]interface{})
+	if isSQLite(data) {
+		db, err := newSQLiteDB(data)
+		if err != nil {
+			return nil, errors.Annotatef(err, "reading maas CLI profiles from %q", path)
+		}
+		rows, err := db.readTable("profiles")
+		if err != nil {
+			return nil, errors.Annotatef(err, "reading maas CLI profiles from %q", path)
+		}
+		for _, row := range rows {
+			if name, ok := row["name"].(string); ok {
+				sources[name] = row["data"]
+			}
+		}
+	} else {
+		var stored map[string]json.RawMessage
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, WrapWithDeserializationError(err, "maas CLI profiles in %q", path)
+		}
+		for name, raw := range stored {
+			sources[name] = []byte(raw)
+		}
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	profiles := make([]CLIProfile, len(names))
+	for i, name := range names {
+		if profiles[i], err = parseCLIProfile(name, sources[name]); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return profiles, nil
+}
+
+// readCLIProfile reads the named profile from the maas CLI database.
+func readCLIProfile(path, name string) (CLIProfile, error) {
+	profiles, err := ReadCLIProfiles(path)
+	if err != nil {
+		return CLIProfile{}, errors.Trace(err)
+	}
+	for _, profile := range profiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+	return CLIProfile{}, errors.NotFoundf("maas CLI profile %q", name)
+}
+
+// NewClientFromProfile creates a Client from the named profile in the maas
+// CLI database at path. If path is empty, DefaultProfilePath is used.
+func NewClientFromProfile(path, name string) (*Client, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultProfilePath(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	profile, err := readCLIProfile(path, name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return profile.Client()
+}
+
+// parseCLIProfile decodes the JSON data the maas CLI stores for a profile.
+// The credentials are stored as a list of the consumer key, token key and
+// token secret, or null for anonymous profiles.
+func parseCLIProfile(name string, data interface{}) (CLIProfile, error) {
+	var raw []byte
+	switch value := data.(type) {
+	case string:
+		raw = []byte(value)
+	case []byte:
+		raw = value
+	default:
+		return CLIProfile{}, NewDeserializationError("maas CLI profile %q: unexpected data %T", name, data)
+	}
+	var stored struct {
+		Name        string   `json:"name"`
+		URL         string   `json:"url"`
+		Credentials []string `json:"credentials"`
+	}
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return CLIProfile{}, WrapWithDeserializationError(err, "maas CLI profile %q", name)
+	}
+	profile := CLIProfile{Name: name, URL: stored.URL}
+	switch len(stored.Credentials) {
+	case 0:
+	case 3:
+		profile.APIKey = strings.Join(stored.Credentials, ":")
+	default:
+		return CLIProfile{}, NewDeserializationError("maas CLI profile %q: expected 3 credential parts, got %d", name, len(stored.Credentials))
+	}
+	return profile, nil
+}
+
+// NewAuthenticatedClientFromCredentials creates a Client like
+// NewAuthenticatedClient, reading the API key from the credentials.
+func NewAuthenticatedClientFromCredentials(BaseURL string, credentials Credentials, apiVersion string) (*Client, error) {
+	if credentials == nil {
+		return nil, errors.NotValidf("missing credentials")
+	}
+	apiKey, err := credentials.APIKey()
+	if err != nil {
+		return nil, errors.Annotate(err, "reading API key")
+	}
+	return NewAuthenticatedClient(BaseURL, apiKey, apiVersion)
+}
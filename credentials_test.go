@@ -0,0 +1,191 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type credentialsSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&credentialsSuite{})
+
+func (*credentialsSuite) TestStaticCredentials(c *gc.C) {
+	key, err := StaticCredentials("a:b:c").APIKey()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(key, gc.Equals, "a:b:c")
+
+	_, err = StaticCredentials("").APIKey()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *credentialsSuite) TestEnvCredentials(c *gc.C) {
+	s.PatchEnvironment(APIKeyEnvVar, " a:b:c\n")
+	key, err := EnvCredentials{}.APIKey()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(key, gc.Equals, "a:b:c")
+}
+
+func (s *credentialsSuite) TestEnvCredentialsName(c *gc.C) {
+	s.PatchEnvironment("OTHER_MAAS_KEY", "d:e:f")
+	key, err := EnvCredentials{Name: "OTHER_MAAS_KEY"}.APIKey()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(key, gc.Equals, "d:e:f")
+}
+
+func (s *credentialsSuite) TestEnvCredentialsUnset(c *gc.C) {
+	s.PatchEnvironment(APIKeyEnvVar, "")
+	_, err := EnvCredentials{}.APIKey()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `API key in \$MAAS_API_KEY not found`)
+}
+
+// The command tests run /bin/sh by its path, as IsolationSuite clears PATH.
+
+func (*credentialsSuite) TestCommandCredentials(c *gc.C) {
+	key, err := CommandCredentials{Command: "/bin/sh", Args: []string{"-c", "echo a:b:c"}}.APIKey()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(key, gc.Equals, "a:b:c")
+}
+
+func (*credentialsSuite) TestCommandCredentialsFailure(c *gc.C) {
+	_, err := CommandCredentials{Command: "/bin/sh", Args: []string{"-c", "echo locked >&2; exit 1"}}.APIKey()
+	c.Assert(err, gc.ErrorMatches, `running "/bin/sh": locked: exit status 1`)
+}
+
+func (*credentialsSuite) TestCommandCredentialsNoOutput(c *gc.C) {
+	_, err := CommandCredentials{Command: "/bin/sh", Args: []string{"-c", "true"}}.APIKey()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (*credentialsSuite) TestProfileCredentials(c *gc.C) {
+	path := writeCLIDatabase(c)
+	key, err := ProfileCredentials{Path: path, Profile: "admin"}.APIKey()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(key, gc.Equals, "ckey:tkey:tsecret")
+
+	key, err = ProfileCredentials{Path: path, Profile: "big"}.APIKey()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(key, gc.Equals, "bc:bt:bs")
+}
+
+func (*credentialsSuite) TestProfileCredentialsAnonymous(c *gc.C) {
+	path := writeCLIDatabase(c)
+	_, err := ProfileCredentials{Path: path, Profile: "anon"}.APIKey()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (*credentialsSuite) TestProfileCredentialsMissingProfile(c *gc.C) {
+	path := writeCLIDatabase(c)
+	_, err := ProfileCredentials{Path: path, Profile: "nobody"}.APIKey()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `maas CLI profile "nobody" not found`)
+}
+
+func (s *credentialsSuite) TestProfileCredentialsDefaultPath(c *gc.C) {
+	home := c.MkDir()
+	s.PatchEnvironment("HOME", home)
+	_, err := ProfileCredentials{Profile: "admin"}.APIKey()
+	c.Assert(errors.Cause(err), jc.Satisfies, os.IsNotExist)
+}
+
+func (*credentialsSuite) TestParseCLIProfileBadCredentials(c *gc.C) {
+	_, err := parseCLIProfile("admin", `{"credentials": ["a", "b"]}`)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (*credentialsSuite) TestNewAuthenticatedClientFromCredentials(c *gc.C) {
+	client, err := NewAuthenticatedClientFromCredentials("http://example.com/MAAS/", StaticCredentials("a:b:c"), "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.APIURL.String(), gc.Equals, "http://example.com/MAAS/api/2.0/")
+
+	_, err = NewAuthenticatedClientFromCredentials("http://example.com/MAAS/", EnvCredentials{Name: "UNSET_MAAS_KEY"}, "2.0")
+	c.Assert(err, gc.ErrorMatches, `reading API key: API key in \$UNSET_MAAS_KEY not found`)
+}
+
+func (*credentialsSuite) TestReadCLIProfiles(c *gc.C) {
+	profiles, err := ReadCLIProfiles(writeCLIDatabase(c))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(profiles, gc.HasLen, 15)
+	c.Assert(profiles[0], jc.DeepEquals, CLIProfile{
+		Name:   "admin",
+		URL:    "http://10.0.0.2:5240/MAAS/api/2.0/",
+		APIKey: "ckey:tkey:tsecret",
+	})
+	c.Assert(profiles[1], jc.DeepEquals, CLIProfile{
+		Name: "anon",
+		URL:  "http://10.0.0.2:5240/MAAS/api/2.0/",
+	})
+}
+
+func (*credentialsSuite) TestReadCLIProfilesJSON(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "profiles.json")
+	err := ioutil.WriteFile(path, []byte(`{
+		"prod": {"name": "prod", "url": "https://maas.example.com/MAAS/api/2.0/", "credentials": ["a", "b", "c"]},
+		"lab": {"name": "lab", "url": "http://lab:5240/MAAS/api/2.0/", "credentials": null}
+	}`), 0600)
+	c.Assert(err, jc.ErrorIsNil)
+	profiles, err := ReadCLIProfiles(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(profiles, jc.DeepEquals, []CLIProfile{{
+		Name: "lab",
+		URL:  "http://lab:5240/MAAS/api/2.0/",
+	}, {
+		Name:   "prod",
+		URL:    "https://maas.example.com/MAAS/api/2.0/",
+		APIKey: "a:b:c",
+	}})
+}
+
+func (*credentialsSuite) TestReadCLIProfilesBadJSON(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "profiles.json")
+	err := ioutil.WriteFile(path, []byte(`["prod"]`), 0600)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = ReadCLIProfiles(path)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (*credentialsSuite) TestCLIProfileSplitURL(c *gc.C) {
+	profile := CLIProfile{Name: "admin", URL: "http://10.0.0.2:5240/MAAS/api/2.0/"}
+	baseURL, apiVersion, err := profile.SplitURL()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(baseURL, gc.Equals, "http://10.0.0.2:5240/MAAS/")
+	c.Assert(apiVersion, gc.Equals, "2.0")
+
+	profile.URL = "http://10.0.0.2:5240/MAAS/"
+	_, _, err = profile.SplitURL()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (*credentialsSuite) TestCLIProfileControllerArgs(c *gc.C) {
+	profile := CLIProfile{Name: "admin", URL: "http://maas/MAAS/api/2.0", APIKey: "a:b:c"}
+	args, err := profile.ControllerArgs()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(args.BaseURL, gc.Equals, "http://maas/MAAS/")
+	c.Assert(args.APIKey, gc.Equals, "a:b:c")
+}
+
+func (*credentialsSuite) TestNewClientFromProfile(c *gc.C) {
+	path := writeCLIDatabase(c)
+	client, err := NewClientFromProfile(path, "user3")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.APIURL.String(), gc.Equals, "http://maas3.example.com/MAAS/api/2.0/")
+	c.Assert(client.Signer, gc.FitsTypeOf, &plainTextOAuthSigner{})
+
+	client, err = NewClientFromProfile(path, "anon")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.Signer, gc.FitsTypeOf, &anonSigner{})
+
+	_, err = NewClientFromProfile(path, "nobody")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
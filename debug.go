@@ -0,0 +1,200 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/juju/utils/set"
+)
+
+// redacted replaces secret values in logged requests and responses.
+const redacted = "REDACTED"
+
+// redactedParams are the form, query string and JSON keys whose values are
+// never logged: OAuth credentials sent in the query string, user data
+// given to cloud-init, and secrets that MAAS returns.
+var redactedParams = set.NewStrings(
+	"oauth_consumer_key",
+	"oauth_token",
+	"oauth_signature",
+	"user_data",
+	"token_key",
+	"token_secret",
+	"password",
+	"power_pass",
+	"power_parameters_power_pass",
+)
+
+// redactedHeaders are the headers whose values are never logged.
+var redactedHeaders = set.NewStrings(
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+)
+
+var debugRequestNumber int64
+
+// redactValues returns a copy of the values with the secrets replaced.
+func redactValues(values url.Values) url.Values {
+	result := make(url.Values, len(values))
+	for key, list := range values {
+		if redactedParams.Contains(key) {
+			result[key] = []string{redacted}
+			continue
+		}
+		result[key] = append([]string(nil), list...)
+	}
+	return result
+}
+
+// redactURL returns the URL with secrets in the query string replaced.
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	copied := *u
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		copied.RawQuery = redacted
+	} else {
+		copied.RawQuery = redactValues(query).Encode()
+	}
+	return copied.String()
+}
+
+// redactHeader formats the header, one field per line in sorted order,
+// with the secret fields replaced.
+func redactHeader(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, key := range keys {
+		for _, value := range header[key] {
+			if redactedHeaders.Contains(http.CanonicalHeaderKey(key)) {
+				value = redacted
+			}
+			fmt.Fprintf(&buf, "\n%s: %s", key, value)
+		}
+	}
+	return buf.String()
+}
+
+// redactBody formats a request or response body. Forms and JSON have their
+// secrets replaced. Uploaded files and other binary content are not
+// logged, only their size.
+func redactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			break
+		}
+		return redactValues(values).Encode()
+	case mediaType == "application/json" || json.Valid(body):
+		var parsed interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&parsed); err != nil {
+			break
+		}
+		out, err := json.Marshal(redactJSON(parsed))
+		if err != nil {
+			break
+		}
+		return string(out)
+	case strings.HasPrefix(mediaType, "multipart/"):
+		return fmt.Sprintf("<%d byte %s body>", len(body), mediaType)
+	}
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("<%d byte binary body>", len(body))
+	}
+	return string(body)
+}
+
+func redactJSON(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if redactedParams.Contains(key) {
+				value[key] = redacted
+			} else {
+				value[key] = redactJSON(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactJSON(item)
+		}
+	}
+	return value
+}
+
+// readBodyForLog returns the content of the body and a replacement reader
+// with the same content, as the body can only be read once.
+func readBodyForLog(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	if body == nil || body == http.NoBody {
+		return nil, body, nil
+	}
+	defer body.Close()
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+// debugRequest logs the signed request, and returns the number used to
+// match the response to it.
+func (client Client) debugRequest(request *http.Request) (int64, error) {
+	id := atomic.AddInt64(&debugRequestNumber, 1)
+	body, replacement, err := readBodyForLog(request.Body)
+	if err != nil {
+		return id, err
+	}
+	request.Body = replacement
+	logger.Debugf("request %d: %s", id, formatRequestForLog(request, body))
+	return id, nil
+}
+
+// debugResponse logs the response to the numbered request. The body is
+// read in full so that it can be logged.
+func (client Client) debugResponse(id int64, response *http.Response) error {
+	body, replacement, err := readBodyForLog(response.Body)
+	if err != nil {
+		return err
+	}
+	response.Body = replacement
+	logger.Debugf("response %d: %s", id, formatResponseForLog(response, body))
+	return nil
+}
+
+func formatRequestForLog(request *http.Request, body []byte) string {
+	return fmt.Sprintf("%s %s%s\n\n%s", request.Method, redactURL(request.URL),
+		redactHeader(request.Header), redactBody(request.Header.Get("Content-Type"), body))
+}
+
+func formatResponseForLog(response *http.Response, body []byte) string {
+	return fmt.Sprintf("%s%s\n\n%s", response.Status,
+		redactHeader(response.Header), redactBody(response.Header.Get("Content-Type"), body))
+}
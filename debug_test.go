@@ -0,0 +1,149 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type debugSuite struct{}
+
+var _ = gc.Suite(&debugSuite{})
+
+func (*debugSuite) TestRedactValues(c *gc.C) {
+	values := url.Values{
+		"hostname":  {"untasted-markita"},
+		"user_data": {"c2VjcmV0"},
+		"password":  {"one", "two"},
+	}
+	redactedValues := redactValues(values)
+	c.Check(redactedValues, jc.DeepEquals, url.Values{
+		"hostname":  {"untasted-markita"},
+		"user_data": {"REDACTED"},
+		"password":  {"REDACTED"},
+	})
+	// The original values are unchanged.
+	c.Check(values.Get("user_data"), gc.Equals, "c2VjcmV0")
+}
+
+func (*debugSuite) TestRedactURL(c *gc.C) {
+	u, err := url.Parse("http://maas/api/2.0/machines/?op=allocate&oauth_token=secret&oauth_signature=sig")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(redactURL(u), gc.Equals, "http://maas/api/2.0/machines/?oauth_signature=REDACTED&oauth_token=REDACTED&op=allocate")
+	c.Check(u.RawQuery, gc.Equals, "op=allocate&oauth_token=secret&oauth_signature=sig")
+}
+
+func (*debugSuite) TestRedactURLWithoutQuery(c *gc.C) {
+	u, err := url.Parse("http://maas/api/2.0/machines/")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(redactURL(u), gc.Equals, "http://maas/api/2.0/machines/")
+}
+
+func (*debugSuite) TestRedactHeader(c *gc.C) {
+	header := http.Header{
+		"Authorization": {`OAuth oauth_token="secret"`},
+		"Content-Type":  {"application/json"},
+		"Set-Cookie":    {"sessionid=secret"},
+	}
+	c.Check(redactHeader(header), gc.Equals, ""+
+		"\nAuthorization: REDACTED"+
+		"\nContent-Type: application/json"+
+		"\nSet-Cookie: REDACTED")
+}
+
+func (*debugSuite) TestRedactBodyForm(c *gc.C) {
+	body := redactBody("application/x-www-form-urlencoded", []byte("distro_series=xenial&user_data=c2VjcmV0"))
+	c.Check(body, gc.Equals, "distro_series=xenial&user_data=REDACTED")
+}
+
+func (*debugSuite) TestRedactBodyJSON(c *gc.C) {
+	body := redactBody("application/json", []byte(`[{"name": "juju", "token_key": "key", "token_secret": "secret", "nested": {"password": "pw", "id": 10}}]`))
+	c.Check(body, gc.Equals, `[{"name":"juju","nested":{"id":10,"password":"REDACTED"},"token_key":"REDACTED","token_secret":"REDACTED"}]`)
+}
+
+func (*debugSuite) TestRedactBodyJSONWithoutContentType(c *gc.C) {
+	body := redactBody("text/plain", []byte(`{"token_secret": "secret"}`))
+	c.Check(body, gc.Equals, `{"token_secret":"REDACTED"}`)
+}
+
+func (*debugSuite) TestRedactBodyMultipart(c *gc.C) {
+	body := redactBody("multipart/form-data; boundary=xyz", []byte("--xyz\r\nuser_data=secret\r\n--xyz--"))
+	c.Check(body, gc.Equals, "<32 byte multipart/form-data body>")
+}
+
+func (*debugSuite) TestRedactBodyBinary(c *gc.C) {
+	body := redactBody("application/octet-stream", []byte{0xff, 0xfe, 0x00})
+	c.Check(body, gc.Equals, "<3 byte binary body>")
+}
+
+func (*debugSuite) TestRedactBodyText(c *gc.C) {
+	c.Check(redactBody("text/plain", []byte("No such machine")), gc.Equals, "No such machine")
+	c.Check(redactBody("text/plain", nil), gc.Equals, "")
+}
+
+func (*debugSuite) TestFormatRequestForLog(c *gc.C) {
+	request, err := http.NewRequest("POST", "http://maas/api/2.0/machines/?op=allocate", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Authorization", "OAuth secret")
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	formatted := formatRequestForLog(request, []byte("user_data=secret"))
+	c.Check(formatted, gc.Equals, ""+
+		"POST http://maas/api/2.0/machines/?op=allocate"+
+		"\nAuthorization: REDACTED"+
+		"\nContent-Type: application/x-www-form-urlencoded"+
+		"\n\nuser_data=REDACTED")
+}
+
+func (*debugSuite) TestReadBodyForLogReplacesBody(c *gc.C) {
+	content, body, err := readBodyForLog(ioutil.NopCloser(strings.NewReader("content")))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "content")
+	replaced, err := readAndClose(body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(replaced), gc.Equals, "content")
+}
+
+func (*debugSuite) TestDebugDoRawKeepsBodies(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, `{"token_secret": "secret"}`, http.StatusOK)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "the:api:key", "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.Debug = true
+	request, err := http.NewRequest("POST", server.URL+URI, bytes.NewBufferString("user_data=secret"))
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := client.DoRaw(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(response.StatusCode, gc.Equals, http.StatusOK)
+	body, err := readAndClose(response.Body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(body), gc.Equals, `{"token_secret": "secret"}`)
+	c.Check(*server.requestContent, gc.Equals, "user_data=secret")
+}
+
+func (*debugSuite) TestControllerArgsDebug(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+
+	result, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+		Debug:   true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.(*controller).client.Debug, jc.IsTrue)
+}
@@ -0,0 +1,303 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// DecodeMode determines how strictly the values in responses from the MAAS
+// controller are checked against the types the client expects.
+type DecodeMode int
+
+const (
+	// DecodeDefault accepts the values the client has always accepted:
+	// integers may be sent as numeric strings, but strings and booleans
+	// must have the correct type.
+	DecodeDefault DecodeMode = iota
+
+	// DecodeStrict requires every value to have exactly the expected JSON
+	// type, and integers to be whole numbers. Use it to catch changes in
+	// the server's responses early.
+	DecodeStrict
+
+	// DecodeLenient converts between numbers, strings and booleans where
+	// the conversion is unambiguous, so that minor changes in the server's
+	// responses do not break the client.
+	DecodeLenient
+)
+
+// Validate ensures that the mode is a known one.
+func (m DecodeMode) Validate() error {
+	switch m {
+	case DecodeDefault, DecodeStrict, DecodeLenient:
+		return nil
+	}
+	return errors.NotValidf("decode mode %d", int(m))
+}
+
+// ParseError is returned when a value in a response does not have the
+// expected type, and the controller is using DecodeStrict or DecodeLenient.
+// The Path is the location of the value in the JSON document, for example
+// "[0].interface_set[1].vlan.vid".
+type ParseError struct {
+	Path     string
+	Expected string
+	Got      interface{}
+}
+
+// Error implements error.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: expected %s, got %T(%#v)", e.Path, e.Expected, e.Got, e.Got)
+}
+
+// GetParseError returns the ParseError in the chain of err, if there is one.
+func GetParseError(err error) (*ParseError, bool) {
+	for err != nil {
+		if perr, ok := err.(*ParseError); ok {
+			return perr, true
+		}
+		wrapper, ok := err.(interface {
+			Underlying() error
+		})
+		if !ok {
+			break
+		}
+		err = wrapper.Underlying()
+	}
+	return nil, false
+}
+
+// IsParseError returns true if err is, or wraps, a ParseError.
+func IsParseError(err error) bool {
+	_, ok := GetParseError(err)
+	return ok
+}
+
+// jsonLeaf holds a scalar value from a response along with its location, so
+// that the field checkers can apply the controller's decode mode. Leaves are
+// only created for DecodeStrict and DecodeLenient; null values are never
+// wrapped.
+type jsonLeaf struct {
+	value interface{}
+	path  string
+	mode  DecodeMode
+}
+
+// GoString makes schema errors that include a leaf readable.
+func (l jsonLeaf) GoString() string {
+	return fmt.Sprintf("%#v at %s", l.value, l.path)
+}
+
+// markLeaves returns a copy of source with each scalar value replaced by a
+// jsonLeaf. For DecodeDefault the source is returned unchanged.
+func markLeaves(source interface{}, mode DecodeMode) interface{} {
+	if mode == DecodeDefault {
+		return source
+	}
+	return markLeavesPath(source, "", mode)
+}
+
+func markLeavesPath(source interface{}, path string, mode DecodeMode) interface{} {
+	switch value := source.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+			result[key] = markLeavesPath(item, itemPath, mode)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = markLeavesPath(item, fmt.Sprintf("%s[%d]", path, i), mode)
+		}
+		return result
+	}
+	return jsonLeaf{value: source, path: path, mode: mode}
+}
+
+type leafConverter func(leaf jsonLeaf) (interface{}, bool)
+
+// leafChecker wraps a schema checker. Plain values are passed through to the
+// wrapped checker, so DecodeDefault behaves exactly as the schema package
+// does. Leaves are converted using the strict or lenient converter.
+type leafChecker struct {
+	expected string
+	base     schema.Checker
+	strict   leafConverter
+	lenient  leafConverter
+}
+
+// Coerce implements schema.Checker.
+func (c leafChecker) Coerce(v interface{}, path []string) (interface{}, error) {
+	leaf, ok := v.(jsonLeaf)
+	if !ok {
+		return c.base.Coerce(v, path)
+	}
+	convert := c.strict
+	if leaf.mode == DecodeLenient {
+		convert = c.lenient
+	}
+	if result, ok := convert(leaf); ok {
+		return result, nil
+	}
+	return nil, &ParseError{Path: leaf.path, Expected: c.expected, Got: leaf.value}
+}
+
+// nullableChecker is the equivalent of schema.OneOf(schema.Nil(""), base)
+// that keeps the ParseError from base when a leaf has the wrong type.
+type nullableChecker struct {
+	base schema.Checker
+}
+
+// Coerce implements schema.Checker.
+func (c nullableChecker) Coerce(v interface{}, path []string) (interface{}, error) {
+	if _, ok := v.(jsonLeaf); ok {
+		return c.base.Coerce(v, path)
+	}
+	return schema.OneOf(schema.Nil(""), c.base).Coerce(v, path)
+}
+
+func nullable(base schema.Checker) schema.Checker {
+	return nullableChecker{base: base}
+}
+
+func stringField() schema.Checker {
+	return leafChecker{
+		expected: "string",
+		base:     schema.String(),
+		strict: func(leaf jsonLeaf) (interface{}, bool) {
+			value, ok := leaf.value.(string)
+			return value, ok
+		},
+		lenient: func(leaf jsonLeaf) (interface{}, bool) {
+			switch value := leaf.value.(type) {
+			case string:
+				return value, true
+			case float64:
+				return strconv.FormatFloat(value, 'f', -1, 64), true
+			case bool:
+				return strconv.FormatBool(value), true
+			}
+			return nil, false
+		},
+	}
+}
+
+func wholeNumber(value float64) bool {
+	return value == math.Trunc(value) && !math.IsInf(value, 0)
+}
+
+// lenientNumber converts numbers, numeric strings and booleans to float64.
+func lenientNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(value, 64)
+		return number, err == nil
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func intField() schema.Checker {
+	return leafChecker{
+		expected: "int",
+		base:     schema.ForceInt(),
+		strict: func(leaf jsonLeaf) (interface{}, bool) {
+			value, ok := leaf.value.(float64)
+			if !ok || !wholeNumber(value) {
+				return nil, false
+			}
+			return int(value), true
+		},
+		lenient: func(leaf jsonLeaf) (interface{}, bool) {
+			value, ok := lenientNumber(leaf.value)
+			if !ok {
+				return nil, false
+			}
+			return int(value), true
+		},
+	}
+}
+
+func uintField() schema.Checker {
+	return leafChecker{
+		expected: "uint",
+		base:     schema.ForceUint(),
+		strict: func(leaf jsonLeaf) (interface{}, bool) {
+			value, ok := leaf.value.(float64)
+			if !ok || !wholeNumber(value) || value < 0 {
+				return nil, false
+			}
+			return uint64(value), true
+		},
+		lenient: func(leaf jsonLeaf) (interface{}, bool) {
+			value, ok := lenientNumber(leaf.value)
+			if !ok || value < 0 {
+				return nil, false
+			}
+			return uint64(value), true
+		},
+	}
+}
+
+func floatField() schema.Checker {
+	return leafChecker{
+		expected: "float",
+		base:     schema.Float(),
+		strict: func(leaf jsonLeaf) (interface{}, bool) {
+			value, ok := leaf.value.(float64)
+			return value, ok
+		},
+		lenient: func(leaf jsonLeaf) (interface{}, bool) {
+			value, ok := lenientNumber(leaf.value)
+			if !ok {
+				return nil, false
+			}
+			return value, true
+		},
+	}
+}
+
+func boolField() schema.Checker {
+	return leafChecker{
+		expected: "bool",
+		base:     schema.Bool(),
+		strict: func(leaf jsonLeaf) (interface{}, bool) {
+			value, ok := leaf.value.(bool)
+			return value, ok
+		},
+		lenient: func(leaf jsonLeaf) (interface{}, bool) {
+			switch value := leaf.value.(type) {
+			case bool:
+				return value, true
+			case string:
+				result, err := strconv.ParseBool(value)
+				return result, err == nil
+			case float64:
+				if value == 0 || value == 1 {
+					return value == 1, true
+				}
+			}
+			return nil, false
+		},
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type decodeSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&decodeSuite{})
+
+func (*decodeSuite) TestValidate(c *gc.C) {
+	for _, mode := range []DecodeMode{DecodeDefault, DecodeStrict, DecodeLenient} {
+		c.Check(mode.Validate(), jc.ErrorIsNil)
+	}
+	err := DecodeMode(7).Validate()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, "decode mode 7 not valid")
+}
+
+func (*decodeSuite) TestMarkLeavesDefault(c *gc.C) {
+	source := parseJSON(c, `{"a": [1, "b"]}`)
+	c.Assert(markLeaves(source, DecodeDefault), jc.DeepEquals, source)
+}
+
+func (*decodeSuite) TestMarkLeavesPaths(c *gc.C) {
+	source := parseJSON(c, `[{"a": {"b": [true, null]}}]`)
+	marked := markLeaves(source, DecodeStrict)
+	list := marked.([]interface{})
+	b := list[0].(map[string]interface{})["a"].(map[string]interface{})["b"].([]interface{})
+	c.Check(b[0], jc.DeepEquals, jsonLeaf{value: true, path: "[0].a.b[0]", mode: DecodeStrict})
+	c.Check(b[1], gc.IsNil)
+}
+
+func (*decodeSuite) TestCheckers(c *gc.C) {
+	for i, test := range []struct {
+		checker schema.Checker
+		value   interface{}
+		mode    DecodeMode
+		result  interface{}
+		errText string
+	}{{
+		checker: stringField(),
+		value:   "foo",
+		mode:    DecodeStrict,
+		result:  "foo",
+	}, {
+		checker: stringField(),
+		value:   float64(42),
+		mode:    DecodeStrict,
+		errText: `field: expected string, got float64\(42\)`,
+	}, {
+		checker: stringField(),
+		value:   float64(42),
+		mode:    DecodeLenient,
+		result:  "42",
+	}, {
+		checker: stringField(),
+		value:   true,
+		mode:    DecodeLenient,
+		result:  "true",
+	}, {
+		checker: intField(),
+		value:   float64(3),
+		mode:    DecodeStrict,
+		result:  3,
+	}, {
+		checker: intField(),
+		value:   "3",
+		mode:    DecodeStrict,
+		errText: `field: expected int, got string\("3"\)`,
+	}, {
+		checker: intField(),
+		value:   float64(3.5),
+		mode:    DecodeStrict,
+		errText: `field: expected int, got float64\(3.5\)`,
+	}, {
+		checker: intField(),
+		value:   "3",
+		mode:    DecodeLenient,
+		result:  3,
+	}, {
+		checker: intField(),
+		value:   "three",
+		mode:    DecodeLenient,
+		errText: `field: expected int, got string\("three"\)`,
+	}, {
+		checker: uintField(),
+		value:   float64(-1),
+		mode:    DecodeLenient,
+		errText: `field: expected uint, got float64\(-1\)`,
+	}, {
+		checker: uintField(),
+		value:   "8",
+		mode:    DecodeLenient,
+		result:  uint64(8),
+	}, {
+		checker: floatField(),
+		value:   "0.5",
+		mode:    DecodeLenient,
+		result:  0.5,
+	}, {
+		checker: floatField(),
+		value:   "0.5",
+		mode:    DecodeStrict,
+		errText: `field: expected float, got string\("0.5"\)`,
+	}, {
+		checker: boolField(),
+		value:   "true",
+		mode:    DecodeLenient,
+		result:  true,
+	}, {
+		checker: boolField(),
+		value:   float64(0),
+		mode:    DecodeLenient,
+		result:  false,
+	}, {
+		checker: boolField(),
+		value:   float64(2),
+		mode:    DecodeLenient,
+		errText: `field: expected bool, got float64\(2\)`,
+	}, {
+		checker: boolField(),
+		value:   "true",
+		mode:    DecodeStrict,
+		errText: `field: expected bool, got string\("true"\)`,
+	}, {
+		checker: nullable(stringField()),
+		value:   float64(1),
+		mode:    DecodeStrict,
+		errText: `field: expected string, got float64\(1\)`,
+	}} {
+		c.Logf("test %d", i)
+		leaf := jsonLeaf{value: test.value, path: "field", mode: test.mode}
+		result, err := test.checker.Coerce(leaf, nil)
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+			c.Check(result, jc.DeepEquals, test.result)
+		} else {
+			c.Check(err, jc.Satisfies, IsParseError)
+			c.Check(err, gc.ErrorMatches, test.errText)
+		}
+	}
+}
+
+func (*decodeSuite) TestCheckersDefault(c *gc.C) {
+	// Without leaves, the checkers behave as the schema package does.
+	result, err := intField().Coerce("12", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, gc.Equals, 12)
+	_, err = stringField().Coerce(float64(12), nil)
+	c.Assert(err, gc.ErrorMatches, `expected string, got float64\(12\)`)
+	result, err = nullable(stringField()).Coerce(nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, gc.IsNil)
+}
+
+func (*decodeSuite) TestStrictReadsFixtures(c *gc.C) {
+	// All the fixtures are well typed, so they must read in strict mode.
+	// This ensures that every reader uses the mode aware checkers.
+	for _, mode := range []DecodeMode{DecodeStrict, DecodeLenient} {
+		c.Logf("mode %d", mode)
+		_, err := readMachines(twoDotOh, markLeaves(parseJSON(c, machinesResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readDevices(twoDotOh, markLeaves(parseJSON(c, devicesResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readFabrics(twoDotOh, markLeaves(parseJSON(c, fabricResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readSpaces(twoDotOh, markLeaves(parseJSON(c, spacesResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readSubnets(twoDotOh, markLeaves(parseJSON(c, subnetResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readStaticRoutes(twoDotOh, markLeaves(parseJSON(c, staticRoutesResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readZones(twoDotOh, markLeaves(parseJSON(c, zoneResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readFiles(twoDotOh, markLeaves(parseJSON(c, filesResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readBootResources(twoDotOh, markLeaves(parseJSON(c, bootResourcesResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readBootSources(twoDotOh, markLeaves(parseJSON(c, bootSourcesResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readBootSourceSelections(twoDotOh, markLeaves(parseJSON(c, bootSourceSelectionsResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+		_, err = readSubnetStatistics(markLeaves(parseJSON(c, subnetStatisticsResponse), mode))
+		c.Check(err, jc.ErrorIsNil)
+	}
+}
+
+func (*decodeSuite) TestStrictError(c *gc.C) {
+	source := parseJSON(c, machinesResponse)
+	machine := source.([]interface{})[1].(map[string]interface{})
+	machine["memory"] = "4096"
+	_, err := readMachines(twoDotOh, markLeaves(source, DecodeStrict))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	perr, ok := GetParseError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(perr.Path, gc.Equals, "[1].memory")
+	c.Check(perr.Expected, gc.Equals, "int")
+	c.Check(perr.Got, gc.Equals, "4096")
+
+	// The default mode accepts numeric strings for integers.
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+}
+
+func (*decodeSuite) TestNestedStrictErrorPath(c *gc.C) {
+	source := parseJSON(c, machinesResponse)
+	machine := source.([]interface{})[0].(map[string]interface{})
+	iface := machine["interface_set"].([]interface{})[0].(map[string]interface{})
+	iface["vlan"].(map[string]interface{})["vid"] = "0"
+	_, err := readMachines(twoDotOh, markLeaves(source, DecodeStrict))
+	perr, ok := GetParseError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(perr.Path, gc.Equals, "[0].interface_set[0].vlan.vid")
+}
+
+func (*decodeSuite) TestLenientRead(c *gc.C) {
+	source := parseJSON(c, machinesResponse)
+	machine := source.([]interface{})[0].(map[string]interface{})
+	machine["hostname"] = float64(1234)
+	machine["cpu_count"] = "8"
+
+	_, err := readMachines(twoDotOh, markLeaves(source, DecodeDefault))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+
+	machines, err := readMachines(twoDotOh, markLeaves(source, DecodeLenient))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].Hostname(), gc.Equals, "1234")
+	c.Check(machines[0].CPUCount(), gc.Equals, 8)
+}
+
+func (s *decodeSuite) TestControllerDecodeMode(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, `[{"name": 5, "description": "", "resource_uri": "/MAAS/api/2.0/zones/5/"}]`)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:    server.URL,
+		APIKey:     "fake:as:key",
+		DecodeMode: DecodeLenient,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	zones, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(zones[0].Name(), gc.Equals, "5")
+}
+
+func (s *decodeSuite) TestControllerBadDecodeMode(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:    "http://example.com/",
+		APIKey:     "fake:as:key",
+		DecodeMode: DecodeMode(-1),
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
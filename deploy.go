@@ -0,0 +1,237 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// deployPollInterval is how often DeployMany reads the status of the
+// machines while waiting for deployment to finish.
+var deployPollInterval = 15 * time.Second
+
+// MachineSpec describes one machine for DeployMany.
+type MachineSpec struct {
+	// Machine, if not nil, is an already allocated machine to deploy.
+	// Otherwise a machine is allocated using Allocate.
+	Machine Machine
+	// Allocate holds the constraints used to allocate a machine when
+	// Machine is nil.
+	Allocate AllocateMachineArgs
+	// Start is passed to Machine.Start.
+	Start StartArgs
+}
+
+// DeployOutcome is the result of deploying one MachineSpec with
+// DeployMany.
+type DeployOutcome struct {
+	// Machine is the machine as last read from MAAS, or nil if no machine
+	// could be allocated.
+	Machine Machine
+	// Err is nil if the machine reached the Deployed state.
+	Err error
+
+	// Started is when work on the spec began.
+	Started time.Time
+	// Allocation is how long allocating the machine took, which is zero
+	// for a spec with a Machine.
+	Allocation time.Duration
+	// Deployment is how long the machine took to reach a terminal state
+	// after it was started.
+	Deployment time.Duration
+	// Elapsed is the total time spent on the spec.
+	Elapsed time.Duration
+}
+
+// Deployment status names that end the wait for a machine.
+const (
+	statusNameDeployed         = "Deployed"
+	statusNameFailedDeployment = "Failed deployment"
+	statusNameBroken           = "Broken"
+	statusNameReady            = "Ready"
+)
+
+// DeployMany implements Controller.
+func (c *controller) DeployMany(ctx context.Context, specs []MachineSpec) []DeployOutcome {
+	outcomes := make([]DeployOutcome, len(specs))
+	deploying := make([]time.Time, len(specs))
+	var wg sync.WaitGroup
+	for i := range specs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			deploying[i] = c.startOne(ctx, specs[i], &outcomes[i])
+		}(i)
+	}
+	wg.Wait()
+
+	// The started machines are polled together, so each interval costs
+	// one request however many machines are deploying.
+	pending := make(map[string][]int)
+	for i := range outcomes {
+		if outcomes[i].Err != nil {
+			c.finishOne(&outcomes[i], deploying[i])
+			continue
+		}
+		systemID := outcomes[i].Machine.SystemID()
+		pending[systemID] = append(pending[systemID], i)
+	}
+	for len(pending) > 0 {
+		machines, err := c.pollMachines(ctx, pending)
+		if err != nil {
+			for systemID, indices := range pending {
+				for _, i := range indices {
+					outcomes[i].Err = errors.Annotatef(err, "waiting for machine %s", systemID)
+					c.finishOne(&outcomes[i], deploying[i])
+				}
+			}
+			break
+		}
+		for systemID, indices := range pending {
+			machine, found := machines[systemID]
+			var done bool
+			if !found {
+				done, err = true, NewNoMatchError(fmt.Sprintf("machine %s not found", systemID))
+			} else {
+				done, err = deploymentDone(machine)
+			}
+			for _, i := range indices {
+				if found {
+					outcomes[i].Machine = machine
+				}
+				if done {
+					outcomes[i].Err = err
+					c.finishOne(&outcomes[i], deploying[i])
+				}
+			}
+			if done {
+				delete(pending, systemID)
+			}
+		}
+	}
+	return outcomes
+}
+
+// pollMachines waits for the poll interval and then reads the pending
+// machines with a single request.
+func (c *controller) pollMachines(ctx context.Context, pending map[string][]int) (map[string]Machine, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.clock.After(deployPollInterval):
+	}
+	systemIDs := make([]string, 0, len(pending))
+	for systemID := range pending {
+		systemIDs = append(systemIDs, systemID)
+	}
+	sort.Strings(systemIDs)
+	machines, err := c.Machines(MachinesArgs{SystemIDs: systemIDs})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make(map[string]Machine, len(machines))
+	for _, machine := range machines {
+		result[machine.SystemID()] = machine
+	}
+	return result, nil
+}
+
+// deployOne deploys a single spec, waiting for its machine on its own.
+func (c *controller) deployOne(ctx context.Context, spec MachineSpec) (outcome DeployOutcome) {
+	deploying := c.startOne(ctx, spec, &outcome)
+	if outcome.Err == nil {
+		outcome.Machine, outcome.Err = c.waitForDeployment(ctx, outcome.Machine)
+	}
+	c.finishOne(&outcome, deploying)
+	return outcome
+}
+
+// startOne allocates the spec's machine if needed and starts it, recording
+// the machine or the error in outcome. It returns when the machine was
+// started, which is zero if it never was.
+func (c *controller) startOne(ctx context.Context, spec MachineSpec, outcome *DeployOutcome) time.Time {
+	outcome.Started = c.clock.Now()
+	machine := spec.Machine
+	if machine == nil {
+		if err := ctx.Err(); err != nil {
+			outcome.Err = errors.Annotate(err, "allocating machine")
+			return time.Time{}
+		}
+		allocated, _, err := c.AllocateMachine(spec.Allocate)
+		outcome.Allocation = c.clock.Now().Sub(outcome.Started)
+		if err != nil {
+			outcome.Err = errors.Annotate(err, "allocating machine")
+			return time.Time{}
+		}
+		machine = allocated
+	}
+	outcome.Machine = machine
+
+	if err := ctx.Err(); err != nil {
+		outcome.Err = errors.Annotatef(err, "starting machine %s", machine.SystemID())
+		return time.Time{}
+	}
+	deploying := c.clock.Now()
+	if err := machine.Start(spec.Start); err != nil {
+		outcome.Err = errors.Annotatef(err, "starting machine %s", machine.SystemID())
+	}
+	return deploying
+}
+
+// finishOne records the timings of an outcome whose machine has reached a
+// terminal state, or was never started.
+func (c *controller) finishOne(outcome *DeployOutcome, deploying time.Time) {
+	now := c.clock.Now()
+	if !deploying.IsZero() {
+		outcome.Deployment = now.Sub(deploying)
+	}
+	outcome.Elapsed = now.Sub(outcome.Started)
+}
+
+// waitForDeployment polls the machine until it is deployed, deployment
+// fails, or the context is done. The last machine state read is returned,
+// even on error.
+func (c *controller) waitForDeployment(ctx context.Context, machine Machine) (Machine, error) {
+	systemID := machine.SystemID()
+	for {
+		select {
+		case <-ctx.Done():
+			return machine, errors.Annotatef(ctx.Err(), "waiting for machine %s", systemID)
+		case <-c.clock.After(deployPollInterval):
+		}
+		machines, err := c.Machines(MachinesArgs{SystemIDs: []string{systemID}})
+		if err != nil {
+			return machine, errors.Annotatef(err, "waiting for machine %s", systemID)
+		}
+		if len(machines) != 1 {
+			return machine, NewNoMatchError(fmt.Sprintf("machine %s not found", systemID))
+		}
+		machine = machines[0]
+		if done, err := deploymentDone(machine); done {
+			return machine, err
+		}
+	}
+}
+
+// deploymentDone reports whether the machine has stopped deploying, and
+// the error if it did not end up deployed.
+func deploymentDone(machine Machine) (bool, error) {
+	switch status := machine.StatusName(); status {
+	case statusNameDeployed:
+		return true, nil
+	case statusNameFailedDeployment, statusNameBroken, statusNameReady:
+		message := fmt.Sprintf("machine %s: %s", machine.SystemID(), status)
+		if detail := machine.StatusMessage(); detail != "" {
+			message += ": " + detail
+		}
+		return true, NewCannotCompleteError(message)
+	}
+	return false, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type deploySuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&deploySuite{})
+
+func (s *deploySuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.PatchValue(&deployPollInterval, time.Millisecond)
+}
+
+func machineJSON(c *gc.C, systemID, status, message string) string {
+	return updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id":      systemID,
+		"resource_uri":   "/MAAS/api/2.0/machines/" + systemID + "/",
+		"status_name":    status,
+		"status_message": message,
+	})
+}
+
+func (s *deploySuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, Controller, Machine) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Allocated", "")+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	server.ResetRequests()
+	return server, controller, machines[0]
+}
+
+func (s *deploySuite) TestDeployMany(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+	allocated := updateJSONMap(c, machineJSON(c, "4y3ha4", "Allocated", ""), map[string]interface{}{
+		"constraints_by_type": map[string]interface{}{},
+	})
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocated)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha4/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha4", "Deploying", ""))
+	// Both machines are read by the first poll, where the allocated one
+	// has failed. The given machine deploys on the second poll.
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=4y3ha4", http.StatusOK,
+		"["+machineJSON(c, "4y3ha3", "Deploying", "")+","+machineJSON(c, "4y3ha4", "Failed deployment", "curtin failed")+"]")
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Deployed", "")+"]")
+
+	outcomes := controller.DeployMany(context.Background(), []MachineSpec{{
+		Machine: machine,
+		Start:   StartArgs{DistroSeries: "xenial"},
+	}, {
+		Allocate: AllocateMachineArgs{Tags: []string{"gpu"}},
+	}})
+	c.Assert(outcomes, gc.HasLen, 2)
+
+	deployed := outcomes[0]
+	c.Check(deployed.Err, jc.ErrorIsNil)
+	c.Check(deployed.Machine.StatusName(), gc.Equals, "Deployed")
+	c.Check(deployed.Allocation, gc.Equals, time.Duration(0))
+	c.Check(deployed.Deployment > 0, jc.IsTrue)
+	c.Check(deployed.Elapsed >= deployed.Deployment, jc.IsTrue)
+	c.Check(deployed.Started.IsZero(), jc.IsFalse)
+
+	failed := outcomes[1]
+	c.Check(failed.Err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(failed.Err, gc.ErrorMatches, "machine 4y3ha4: Failed deployment: curtin failed")
+	c.Check(failed.Machine.SystemID(), gc.Equals, "4y3ha4")
+	c.Check(failed.Allocation > 0, jc.IsTrue)
+	c.Check(failed.Elapsed >= failed.Allocation+failed.Deployment, jc.IsTrue)
+
+	// One allocate, two deploys and two polls.
+	c.Check(server.RequestCount(), gc.Equals, 5)
+}
+
+func (s *deploySuite) TestDeployManyMachineNotFound(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "[]")
+
+	outcomes := controller.DeployMany(context.Background(), []MachineSpec{{Machine: machine}})
+	c.Assert(outcomes, gc.HasLen, 1)
+	c.Check(outcomes[0].Err, jc.Satisfies, IsNoMatchError)
+	c.Check(outcomes[0].Err, gc.ErrorMatches, "machine 4y3ha3 not found")
+	c.Check(outcomes[0].Machine.StatusName(), gc.Equals, "Deploying")
+}
+
+func (s *deploySuite) TestDeployManyPollError(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusInternalServerError, "boom")
+
+	outcomes := controller.DeployMany(context.Background(), []MachineSpec{{Machine: machine}})
+	c.Assert(outcomes, gc.HasLen, 1)
+	c.Check(outcomes[0].Err, gc.ErrorMatches, "waiting for machine 4y3ha3: .*boom.*")
+	c.Check(outcomes[0].Deployment > 0, jc.IsTrue)
+}
+
+func (s *deploySuite) TestDeployManyAllocateError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "no machines")
+
+	outcomes := controller.DeployMany(context.Background(), []MachineSpec{{}})
+	c.Assert(outcomes, gc.HasLen, 1)
+	c.Check(outcomes[0].Machine, gc.IsNil)
+	c.Check(outcomes[0].Err, jc.Satisfies, IsNoMatchError)
+	c.Check(outcomes[0].Err, gc.ErrorMatches, "allocating machine: no machines")
+}
+
+func (s *deploySuite) TestDeployManyStartError(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusForbidden, "not yours")
+
+	outcomes := controller.DeployMany(context.Background(), []MachineSpec{{Machine: machine}})
+	c.Assert(outcomes, gc.HasLen, 1)
+	c.Check(outcomes[0].Err, jc.Satisfies, IsPermissionError)
+	c.Check(outcomes[0].Err, gc.ErrorMatches, "starting machine 4y3ha3: not yours")
+}
+
+func (s *deploySuite) TestDeployManyInvalidStartArgs(c *gc.C) {
+	_, controller, machine := s.getServerAndMachine(c)
+	outcomes := controller.DeployMany(context.Background(), []MachineSpec{{
+		Machine: machine,
+		Start:   StartArgs{Kernel: "bad kernel"},
+	}})
+	c.Assert(outcomes, gc.HasLen, 1)
+	c.Check(outcomes[0].Err, jc.Satisfies, IsArgumentError)
+}
+
+func (s *deploySuite) TestDeployManyCancelled(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	s.PatchValue(&deployPollInterval, time.Hour)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	outcomes := controller.DeployMany(ctx, []MachineSpec{{Machine: machine}, {}})
+	c.Assert(outcomes, gc.HasLen, 2)
+	c.Check(errors.Cause(outcomes[0].Err), gc.Equals, context.DeadlineExceeded)
+	c.Check(outcomes[0].Err, gc.ErrorMatches, "waiting for machine 4y3ha3: context deadline exceeded")
+	c.Check(outcomes[0].Machine.StatusName(), gc.Equals, "Deploying")
+}
+
+func (s *deploySuite) TestDeployManyNotStartedAfterCancel(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	outcomes := controller.DeployMany(ctx, []MachineSpec{{Machine: machine}, {}})
+	c.Assert(outcomes, gc.HasLen, 2)
+	c.Check(outcomes[0].Err, gc.ErrorMatches, "starting machine 4y3ha3: context canceled")
+	c.Check(outcomes[1].Err, gc.ErrorMatches, "allocating machine: context canceled")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *deploySuite) TestDeployManyEmpty(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	outcomes := controller.DeployMany(context.Background(), nil)
+	c.Check(outcomes, gc.HasLen, 0)
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// CheckDeployImage returns an ArgumentError, listing the images that are
+// available, unless the boot resources include an image of the series for
+// the architecture and, if kernel is not empty, with that kernel. The
+// series may be given with or without the operating system, as "xenial"
+// or "ubuntu/xenial", and the architecture is normalized as by
+// NormalizeArchitecture. Any subarchitecture of arch is ignored. If arch
+// is empty, as it is for machines whose architecture MAAS does not know,
+// an image of the series for any architecture will do.
+func CheckDeployImage(resources []BootResource, series, arch, kernel string) error {
+	normalized, target := "", "any architecture"
+	if arch != "" {
+		var err error
+		normalized, err = NormalizeArchitecture(arch)
+		if err != nil {
+			return NewArgumentError("Architecture", "%q is not a valid architecture", arch)
+		}
+		if i := strings.Index(normalized, "/"); i >= 0 {
+			normalized = normalized[:i]
+		}
+		target = normalized
+	}
+	arches := set.NewStrings()
+	for _, resource := range resources {
+		name := resource.Name()
+		if name != series && !strings.HasSuffix(name, "/"+series) {
+			continue
+		}
+		resourceArch := strings.SplitN(resource.Architecture(), "/", 2)[0]
+		if normalized == "" || resourceArch == normalized {
+			arches.Add(resourceArch)
+		}
+	}
+	if arches.IsEmpty() {
+		return NewArgumentError("DistroSeries", "no image of %q for %s, available: %s",
+			series, target, availableImages(resources))
+	}
+	if kernel == "" {
+		return nil
+	}
+	kernels := set.NewStrings()
+	for _, resourceArch := range arches.Values() {
+		kernels = kernels.Union(set.NewStrings(HWEKernels(resources, series, resourceArch)...))
+	}
+	if kernels.Contains(kernel) {
+		return nil
+	}
+	return NewArgumentError("Kernel", "%q is not available for %q on %s, available: %s",
+		kernel, series, target, listOrNone(kernels.SortedValues()))
+}
+
+// availableImages describes the images of the boot resources, as their
+// names followed by their architectures, such as
+// "ubuntu/trusty (amd64, i386), ubuntu/xenial (amd64)".
+func availableImages(resources []BootResource) string {
+	arches := make(map[string]set.Strings)
+	for _, resource := range resources {
+		name := resource.Name()
+		if arches[name] == nil {
+			arches[name] = set.NewStrings()
+		}
+		arches[name].Add(strings.SplitN(resource.Architecture(), "/", 2)[0])
+	}
+	names := make([]string, 0, len(arches))
+	for name := range arches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	images := make([]string, len(names))
+	for i, name := range names {
+		images[i] = fmt.Sprintf("%s (%s)", name, strings.Join(arches[name].SortedValues(), ", "))
+	}
+	return listOrNone(images)
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+// checkDeployImage checks that the boot resources of the controller have
+// an image for deploying the machine with the args.
+func (m *machine) checkDeployImage(args StartArgs) error {
+	if args.DistroSeries == "" {
+		return nil
+	}
+	resources, err := m.controller.BootResources()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(CheckDeployImage(resources, args.DistroSeries, m.architecture, args.Kernel))
+}
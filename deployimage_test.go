@@ -0,0 +1,103 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (*bootResourceSuite) TestCheckDeployImage(c *gc.C) {
+	read, err := readBootResources(twoDotOh, parseJSON(c, bootResourcesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	var resources []BootResource
+	for _, resource := range read {
+		resources = append(resources, resource)
+	}
+	for i, test := range []struct {
+		series, arch, kernel string
+		message              string
+	}{{
+		series: "trusty",
+		arch:   "amd64/generic",
+	}, {
+		series: "ubuntu/xenial",
+		arch:   "x86_64",
+		kernel: "hwe-x",
+	}, {
+		series:  "bionic",
+		arch:    "amd64",
+		message: `DistroSeries: no image of "bionic" for amd64, available: ubuntu/trusty \(amd64\), ubuntu/xenial \(amd64\)`,
+	}, {
+		series:  "trusty",
+		arch:    "arm64",
+		message: `DistroSeries: no image of "trusty" for arm64, available: .*`,
+	}, {
+		series:  "xenial",
+		arch:    "amd64",
+		kernel:  "hwe-y",
+		message: `Kernel: "hwe-y" is not available for "xenial" on amd64, available: hwe-p, .*, hwe-x`,
+	}, {
+		// The architecture of the machine is not known.
+		series: "xenial",
+		kernel: "hwe-x",
+	}, {
+		series:  "bionic",
+		message: `DistroSeries: no image of "bionic" for any architecture, available: .*`,
+	}, {
+		series:  "xenial",
+		kernel:  "hwe-y",
+		message: `Kernel: "hwe-y" is not available for "xenial" on any architecture, available: .*`,
+	}, {
+		series:  "trusty",
+		arch:    "amd 64",
+		message: `Architecture: "amd 64" is not a valid architecture`,
+	}} {
+		c.Logf("test %d", i)
+		err := CheckDeployImage(resources, test.series, test.arch, test.kernel)
+		if test.message == "" {
+			c.Check(err, jc.ErrorIsNil)
+			continue
+		}
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (*bootResourceSuite) TestCheckDeployImageNoResources(c *gc.C) {
+	err := CheckDeployImage(nil, "xenial", "amd64", "")
+	c.Check(err, gc.ErrorMatches, `DistroSeries: no image of "xenial" for amd64, available: none`)
+}
+
+func (s *machineSuite) TestStartCheckImage(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, bootResourcesResponse)
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, machineResponse)
+
+	err := machine.Start(StartArgs{DistroSeries: "xenial", Kernel: "hwe-x", CheckImage: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.RequestCount(), gc.Equals, 2)
+	c.Check(server.LastRequest().PostForm.Get("distro_series"), gc.Equals, "xenial")
+}
+
+func (s *machineSuite) TestStartCheckImageFailsFast(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, bootResourcesResponse)
+
+	err := machine.Start(StartArgs{DistroSeries: "bionic", CheckImage: true})
+	c.Assert(err, jc.Satisfies, IsArgumentError)
+	c.Check(err, gc.ErrorMatches, `DistroSeries: no image of "bionic" for amd64, available: .*`)
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *machineSuite) TestStartCheckImageDefaultSeries(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, machineResponse)
+
+	err := machine.Start(StartArgs{CheckImage: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
@@ -0,0 +1,132 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+
+	"github.com/juju/errors"
+)
+
+// DeployWithRetryArgs is an argument struct for Controller.DeployWithRetry.
+type DeployWithRetryArgs struct {
+	// Machine, if not nil, is an already allocated machine to deploy
+	// first. Otherwise the first machine is allocated using Allocate.
+	Machine Machine
+
+	// Allocate holds the constraints used to allocate each substitute
+	// machine, and the first one when Machine is nil. The Hostname and
+	// IdempotencyKey are only used for the first allocation, as they
+	// would select the machine that failed again.
+	Allocate AllocateMachineArgs
+
+	// Start is passed to Machine.Start for each machine.
+	Start StartArgs
+
+	// Attempts is the largest number of machines that are deployed,
+	// including the first. It must be at least one.
+	Attempts int
+
+	// ReleaseFailed, if true, releases each machine that fails to deploy.
+	// It is released once its substitute has been allocated, so that the
+	// substitute cannot be the same machine. Otherwise the machines that
+	// fail stay allocated to the user, for their logs to be looked at.
+	// The release is recorded with the comment of Start.
+	ReleaseFailed bool
+}
+
+// Validate checks that Attempts is at least one.
+func (a *DeployWithRetryArgs) Validate() error {
+	if a.Attempts < 1 {
+		return NewArgumentError("Attempts", "%d is less than one", a.Attempts)
+	}
+	return nil
+}
+
+// DeployAttempt records the deployment of one machine by DeployWithRetry.
+type DeployAttempt struct {
+	// Machine is the machine as last read from MAAS.
+	Machine Machine
+
+	// Err is nil if the machine was deployed.
+	Err error
+
+	// Released is true if the machine was released after it failed.
+	// ReleaseErr is set if releasing it failed.
+	Released   bool
+	ReleaseErr error
+}
+
+// DeployRetryResult is returned by Controller.DeployWithRetry.
+type DeployRetryResult struct {
+	// Machine is the deployed machine, or nil if no machine was deployed.
+	Machine Machine
+
+	// Attempts are the machines that were tried, in order, each one
+	// substituting for the one before.
+	Attempts []DeployAttempt
+}
+
+// DeployWithRetry implements Controller.
+//
+// Only failures of the deployment itself, reported as a
+// CannotCompleteError, lead to a substitute; other errors, such as bad
+// StartArgs or no machine matching the constraints, are returned at once.
+func (c *controller) DeployWithRetry(ctx context.Context, args DeployWithRetryArgs) (DeployRetryResult, error) {
+	var result DeployRetryResult
+	if err := args.Validate(); err != nil {
+		return result, errors.Trace(err)
+	}
+	machine := args.Machine
+	allocate := args.Allocate
+	for {
+		if machine == nil {
+			allocated, err := c.allocateForDeploy(ctx, allocate)
+			if args.ReleaseFailed && len(result.Attempts) > 0 {
+				c.releaseFailed(&result.Attempts[len(result.Attempts)-1], args.Start.Comment)
+			}
+			if err != nil {
+				return result, errors.Annotate(err, "allocating machine")
+			}
+			machine = allocated
+		}
+		outcome := c.deployOne(ctx, MachineSpec{Machine: machine, Start: args.Start})
+		result.Attempts = append(result.Attempts, DeployAttempt{Machine: outcome.Machine, Err: outcome.Err})
+		if outcome.Err == nil {
+			result.Machine = outcome.Machine
+			return result, nil
+		}
+		if !IsCannotCompleteError(outcome.Err) {
+			return result, errors.Trace(outcome.Err)
+		}
+		if len(result.Attempts) == args.Attempts {
+			if args.ReleaseFailed {
+				c.releaseFailed(&result.Attempts[len(result.Attempts)-1], args.Start.Comment)
+			}
+			return result, errors.Trace(outcome.Err)
+		}
+		logger.Debugf("deploying machine %s failed, allocating another: %v", machine.SystemID(), outcome.Err)
+		machine = nil
+		allocate.Hostname = ""
+		allocate.IdempotencyKey = ""
+	}
+}
+
+func (c *controller) allocateForDeploy(ctx context.Context, args AllocateMachineArgs) (Machine, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	machine, _, err := c.AllocateMachine(args)
+	return machine, errors.Trace(err)
+}
+
+// releaseFailed releases the machine of the attempt, recording the outcome.
+// The comment is the one given for the deployment.
+func (c *controller) releaseFailed(attempt *DeployAttempt, comment string) {
+	attempt.ReleaseErr = c.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs: []string{attempt.Machine.SystemID()},
+		Comment:   actionComment("deployment failed", comment),
+	})
+	attempt.Released = attempt.ReleaseErr == nil
+}
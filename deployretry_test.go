@@ -0,0 +1,106 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func allocatedJSON(c *gc.C, systemID string) string {
+	return updateJSONMap(c, machineJSON(c, systemID, "Allocated", ""), map[string]interface{}{
+		"constraints_by_type": map[string]interface{}{},
+	})
+}
+
+func (s *deploySuite) addFailedDeploy(c *gc.C, server *SimpleTestServer, systemID string) {
+	server.AddPostResponse("/MAAS/api/2.0/machines/"+systemID+"/?op=deploy", http.StatusOK, machineJSON(c, systemID, "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id="+systemID, http.StatusOK, "["+machineJSON(c, systemID, "Failed deployment", "curtin failed")+"]")
+}
+
+func (s *deploySuite) TestDeployWithRetry(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	s.addFailedDeploy(c, server, "4y3ha3")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocatedJSON(c, "4y3ha4"))
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha4/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha4", "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha4", http.StatusOK, "["+machineJSON(c, "4y3ha4", "Deployed", "")+"]")
+
+	result, err := controller.DeployWithRetry(context.Background(), DeployWithRetryArgs{
+		Machine: machine,
+		Allocate: AllocateMachineArgs{
+			Hostname:       "untasted-markita",
+			Tags:           []string{"gpu"},
+			IdempotencyKey: "key",
+		},
+		Start:         StartArgs{Comment: "ticket 42"},
+		Attempts:      3,
+		ReleaseFailed: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.Machine.SystemID(), gc.Equals, "4y3ha4")
+	c.Assert(result.Attempts, gc.HasLen, 2)
+	c.Check(result.Attempts[0].Machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(result.Attempts[0].Err, gc.ErrorMatches, "machine 4y3ha3: Failed deployment: curtin failed")
+	c.Check(result.Attempts[0].Released, jc.IsTrue)
+	c.Check(result.Attempts[1].Err, jc.ErrorIsNil)
+	c.Check(result.Attempts[1].Released, jc.IsFalse)
+
+	// The failed machine is released after its substitute is allocated,
+	// which is allocated without the hostname and idempotency key.
+	requests := server.LastNRequests(4)
+	c.Check(requests[0].URL.String(), gc.Equals, "/api/2.0/machines/?op=allocate")
+	c.Check(requests[0].PostForm.Get("tags"), gc.Equals, "gpu")
+	c.Check(requests[0].PostForm.Get("name"), gc.Equals, "")
+	c.Check(requests[0].PostForm.Get("agent_name"), gc.Equals, "")
+	c.Check(requests[1].URL.String(), gc.Equals, "/api/2.0/machines/?op=release")
+	c.Check(requests[1].PostForm["machines"], jc.DeepEquals, []string{"4y3ha3"})
+	c.Check(requests[1].PostForm.Get("comment"), gc.Equals, "deployment failed: ticket 42")
+	c.Check(requests[2].PostForm.Get("comment"), gc.Equals, "ticket 42")
+}
+
+func (s *deploySuite) TestDeployWithRetryGivesUp(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocatedJSON(c, "4y3ha3"))
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocatedJSON(c, "4y3ha4"))
+	s.addFailedDeploy(c, server, "4y3ha3")
+	s.addFailedDeploy(c, server, "4y3ha4")
+
+	result, err := controller.DeployWithRetry(context.Background(), DeployWithRetryArgs{Attempts: 2})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, "machine 4y3ha4: Failed deployment: curtin failed")
+	c.Check(result.Machine, gc.IsNil)
+	c.Assert(result.Attempts, gc.HasLen, 2)
+	// The failed machines are left allocated.
+	c.Check(result.Attempts[0].Released, jc.IsFalse)
+	c.Check(result.Attempts[1].Machine.SystemID(), gc.Equals, "4y3ha4")
+}
+
+func (s *deploySuite) TestDeployWithRetryStopsOnOtherErrors(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	s.addFailedDeploy(c, server, "4y3ha3")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "no machines")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+
+	result, err := controller.DeployWithRetry(context.Background(), DeployWithRetryArgs{
+		Machine:       machine,
+		Attempts:      3,
+		ReleaseFailed: true,
+	})
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, "allocating machine: no machines")
+	c.Assert(result.Attempts, gc.HasLen, 1)
+	// The failed machine is still released.
+	c.Check(result.Attempts[0].Released, jc.IsTrue)
+}
+
+func (s *deploySuite) TestDeployWithRetryValidate(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := controller.DeployWithRetry(context.Background(), DeployWithRetryArgs{})
+	c.Check(err, jc.Satisfies, IsArgumentError)
+	c.Check(err, gc.ErrorMatches, "Attempts: 0 is less than one")
+}
@@ -0,0 +1,352 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type apiDescription struct {
+	doc       string
+	hash      string
+	resources []*apiResource
+}
+
+// Doc implements APIDescription.
+func (d *apiDescription) Doc() string {
+	return d.doc
+}
+
+// Hash implements APIDescription.
+func (d *apiDescription) Hash() string {
+	return d.hash
+}
+
+// Resources implements APIDescription.
+func (d *apiDescription) Resources() []APIResource {
+	result := make([]APIResource, len(d.resources))
+	for i, resource := range d.resources {
+		result[i] = resource
+	}
+	return result
+}
+
+// Resource implements APIDescription.
+func (d *apiDescription) Resource(name string) APIResource {
+	for _, resource := range d.resources {
+		if resource.name == name {
+			return resource
+		}
+	}
+	return nil
+}
+
+type apiResource struct {
+	name string
+	anon *apiHandler
+	auth *apiHandler
+}
+
+// Name implements APIResource.
+func (r *apiResource) Name() string {
+	return r.name
+}
+
+// Anonymous implements APIResource.
+func (r *apiResource) Anonymous() APIHandler {
+	if r.anon == nil {
+		return nil
+	}
+	return r.anon
+}
+
+// Authenticated implements APIResource.
+func (r *apiResource) Authenticated() APIHandler {
+	if r.auth == nil {
+		return nil
+	}
+	return r.auth
+}
+
+type apiHandler struct {
+	name    string
+	doc     string
+	path    string
+	uri     string
+	params  []string
+	actions []*apiAction
+}
+
+// Name implements APIHandler.
+func (h *apiHandler) Name() string {
+	return h.name
+}
+
+// Doc implements APIHandler.
+func (h *apiHandler) Doc() string {
+	return h.doc
+}
+
+// Path implements APIHandler.
+func (h *apiHandler) Path() string {
+	return h.path
+}
+
+// URI implements APIHandler.
+func (h *apiHandler) URI() string {
+	return h.uri
+}
+
+// Params implements APIHandler.
+func (h *apiHandler) Params() []string {
+	return append([]string(nil), h.params...)
+}
+
+// Actions implements APIHandler.
+func (h *apiHandler) Actions() []APIAction {
+	result := make([]APIAction, len(h.actions))
+	for i, action := range h.actions {
+		result[i] = action
+	}
+	return result
+}
+
+// Action implements APIHandler.
+func (h *apiHandler) Action(method, op string) APIAction {
+	for _, action := range h.actions {
+		if action.method == method && action.op == op {
+			return action
+		}
+	}
+	return nil
+}
+
+type apiAction struct {
+	name    string
+	op      string
+	method  string
+	doc     string
+	restful bool
+}
+
+// Name implements APIAction.
+func (a *apiAction) Name() string {
+	return a.name
+}
+
+// Op implements APIAction.
+func (a *apiAction) Op() string {
+	return a.op
+}
+
+// Method implements APIAction.
+func (a *apiAction) Method() string {
+	return a.method
+}
+
+// Doc implements APIAction.
+func (a *apiAction) Doc() string {
+	return a.doc
+}
+
+// Restful implements APIAction.
+func (a *apiAction) Restful() bool {
+	return a.restful
+}
+
+// Describe implements Controller.
+func (c *controller) Describe() (APIDescription, error) {
+	source, err := c.get("describe")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	description, err := readAPIDescription(c.apiVersion, c.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return description, nil
+}
+
+// ReadAPIDescription reads an API description document, as saved from the
+// describe endpoint of a MAAS 2.0 controller.
+func ReadAPIDescription(r io.Reader) (APIDescription, error) {
+	var source interface{}
+	if err := json.NewDecoder(r).Decode(&source); err != nil {
+		return nil, errors.Annotate(err, "reading API description")
+	}
+	description, err := readAPIDescription(twoDotOh, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return description, nil
+}
+
+func readAPIDescription(controllerVersion version.Number, source interface{}) (*apiDescription, error) {
+	var deserialisationVersion version.Number
+	for v := range apiDescriptionDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no API description read func for version %s", controllerVersion)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "API description base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return apiDescriptionDeserializationFuncs[deserialisationVersion](valid)
+}
+
+type apiDescriptionDeserializationFunc func(map[string]interface{}) (*apiDescription, error)
+
+var apiDescriptionDeserializationFuncs = map[version.Number]apiDescriptionDeserializationFunc{
+	twoDotOh: apiDescription_2_0,
+}
+
+func apiDescription_2_0(source map[string]interface{}) (*apiDescription, error) {
+	fields := schema.Fields{
+		"doc":       stringField(),
+		"hash":      stringField(),
+		"resources": schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"doc":  "",
+		"hash": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "API description 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	resources := valid["resources"].([]interface{})
+	result := &apiDescription{
+		doc:       valid["doc"].(string),
+		hash:      valid["hash"].(string),
+		resources: make([]*apiResource, 0, len(resources)),
+	}
+	for i, value := range resources {
+		resource, err := apiResource_2_0(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(err, "resource %d", i)
+		}
+		result.resources = append(result.resources, resource)
+	}
+	return result, nil
+}
+
+func apiResource_2_0(source map[string]interface{}) (*apiResource, error) {
+	fields := schema.Fields{
+		"name": stringField(),
+		"anon": nullable(schema.StringMap(schema.Any())),
+		"auth": nullable(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"anon": nil,
+		"auth": nil,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "API resource 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	result := &apiResource{name: valid["name"].(string)}
+	if anon, ok := valid["anon"].(map[string]interface{}); ok {
+		if result.anon, err = apiHandler_2_0(anon); err != nil {
+			return nil, errors.Annotatef(err, "%s anonymous handler", result.name)
+		}
+	}
+	if auth, ok := valid["auth"].(map[string]interface{}); ok {
+		if result.auth, err = apiHandler_2_0(auth); err != nil {
+			return nil, errors.Annotatef(err, "%s handler", result.name)
+		}
+	}
+	return result, nil
+}
+
+func apiHandler_2_0(source map[string]interface{}) (*apiHandler, error) {
+	fields := schema.Fields{
+		"name":    stringField(),
+		"doc":     nullable(stringField()),
+		"path":    stringField(),
+		"uri":     stringField(),
+		"params":  schema.List(stringField()),
+		"actions": schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"doc":    "",
+		"uri":    "",
+		"params": []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "API handler 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	doc, _ := valid["doc"].(string)
+	result := &apiHandler{
+		name: valid["name"].(string),
+		doc:  doc,
+		path: valid["path"].(string),
+		uri:  valid["uri"].(string),
+	}
+	for _, param := range valid["params"].([]interface{}) {
+		result.params = append(result.params, param.(string))
+	}
+	for i, value := range valid["actions"].([]interface{}) {
+		action, err := apiAction_2_0(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(err, "action %d", i)
+		}
+		result.actions = append(result.actions, action)
+	}
+	return result, nil
+}
+
+func apiAction_2_0(source map[string]interface{}) (*apiAction, error) {
+	fields := schema.Fields{
+		"name":    stringField(),
+		"op":      nullable(stringField()),
+		"method":  stringField(),
+		"doc":     nullable(stringField()),
+		"restful": boolField(),
+	}
+	defaults := schema.Defaults{
+		"op":      nil,
+		"doc":     "",
+		"restful": false,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "API action 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	op, _ := valid["op"].(string)
+	doc, _ := valid["doc"].(string)
+	return &apiAction{
+		name:    valid["name"].(string),
+		op:      op,
+		method:  valid["method"].(string),
+		doc:     doc,
+		restful: valid["restful"].(bool),
+	}, nil
+}
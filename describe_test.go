@@ -0,0 +1,167 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type describeSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&describeSuite{})
+
+const describeResponse = `
+{
+    "doc": "MAAS API",
+    "hash": "ad0d8bf1b14b3c11a1cf47f8ef8ab36e8f9ce2a3",
+    "resources": [
+        {
+            "name": "MachineHandler",
+            "anon": null,
+            "auth": {
+                "name": "MachineHandler",
+                "doc": "Manage an individual machine.",
+                "path": "/MAAS/api/2.0/machines/{system_id}/",
+                "uri": "http://maas/MAAS/api/2.0/machines/{system_id}/",
+                "params": ["system_id"],
+                "actions": [
+                    {"name": "read", "op": null, "method": "GET", "restful": true, "doc": "Read a machine."},
+                    {"name": "deploy", "op": "deploy", "method": "POST", "restful": false, "doc": "Deploy an OS."},
+                    {"name": "details", "op": "details", "method": "GET", "restful": false, "doc": null}
+                ]
+            }
+        },
+        {
+            "name": "VersionHandler",
+            "anon": {
+                "name": "AnonVersionHandler",
+                "doc": null,
+                "path": "/MAAS/api/2.0/version/",
+                "uri": "http://maas/MAAS/api/2.0/version/",
+                "params": [],
+                "actions": [
+                    {"name": "read", "op": null, "method": "GET", "restful": true, "doc": "Version info."}
+                ]
+            },
+            "auth": null
+        }
+    ]
+}
+`
+
+func (*describeSuite) TestReadAPIDescription(c *gc.C) {
+	description, err := readAPIDescription(twoDotOh, parseJSON(c, describeResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(description.Doc(), gc.Equals, "MAAS API")
+	c.Check(description.Hash(), gc.Equals, "ad0d8bf1b14b3c11a1cf47f8ef8ab36e8f9ce2a3")
+	resources := description.Resources()
+	c.Assert(resources, gc.HasLen, 2)
+	c.Check(resources[0].Name(), gc.Equals, "MachineHandler")
+	c.Check(resources[0].Anonymous(), gc.IsNil)
+
+	handler := resources[0].Authenticated()
+	c.Assert(handler, gc.NotNil)
+	c.Check(handler.Name(), gc.Equals, "MachineHandler")
+	c.Check(handler.Doc(), gc.Equals, "Manage an individual machine.")
+	c.Check(handler.Path(), gc.Equals, "/MAAS/api/2.0/machines/{system_id}/")
+	c.Check(handler.URI(), gc.Equals, "http://maas/MAAS/api/2.0/machines/{system_id}/")
+	c.Check(handler.Params(), jc.DeepEquals, []string{"system_id"})
+
+	actions := handler.Actions()
+	c.Assert(actions, gc.HasLen, 3)
+	c.Check(actions[0].Name(), gc.Equals, "read")
+	c.Check(actions[0].Op(), gc.Equals, "")
+	c.Check(actions[0].Method(), gc.Equals, "GET")
+	c.Check(actions[0].Restful(), jc.IsTrue)
+	c.Check(actions[0].Doc(), gc.Equals, "Read a machine.")
+	c.Check(actions[1].Op(), gc.Equals, "deploy")
+	c.Check(actions[1].Restful(), jc.IsFalse)
+	c.Check(actions[2].Doc(), gc.Equals, "")
+}
+
+func (*describeSuite) TestReadAPIDescriptionAnonymous(c *gc.C) {
+	description, err := readAPIDescription(twoDotOh, parseJSON(c, describeResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	resource := description.Resource("VersionHandler")
+	c.Assert(resource, gc.NotNil)
+	c.Check(resource.Authenticated(), gc.IsNil)
+	handler := resource.Anonymous()
+	c.Assert(handler, gc.NotNil)
+	c.Check(handler.Name(), gc.Equals, "AnonVersionHandler")
+	c.Check(handler.Doc(), gc.Equals, "")
+	c.Check(handler.Params(), gc.HasLen, 0)
+}
+
+func (*describeSuite) TestResourceMissing(c *gc.C) {
+	description, err := readAPIDescription(twoDotOh, parseJSON(c, describeResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(description.Resource("NoSuchHandler"), gc.IsNil)
+}
+
+func (*describeSuite) TestHandlerAction(c *gc.C) {
+	description, err := readAPIDescription(twoDotOh, parseJSON(c, describeResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	handler := description.Resource("MachineHandler").Authenticated()
+	c.Check(handler.Action("POST", "deploy").Name(), gc.Equals, "deploy")
+	c.Check(handler.Action("GET", "").Name(), gc.Equals, "read")
+	c.Check(handler.Action("GET", "deploy"), gc.IsNil)
+}
+
+func (*describeSuite) TestReadAPIDescriptionBadSchema(c *gc.C) {
+	_, err := readAPIDescription(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `API description base schema check failed: expected map, got string("wat?")`)
+}
+
+func (*describeSuite) TestReadAPIDescriptionBadAction(c *gc.C) {
+	source := parseJSON(c, `{"resources": [{"name": "R", "auth": {"name": "H", "path": "/", "actions": [{"name": "read"}]}}]}`)
+	_, err := readAPIDescription(twoDotOh, source)
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, `resource 0: R handler: action 0: API action 2.0 schema check failed: method: expected string, got nothing`)
+}
+
+func (*describeSuite) TestLowVersion(c *gc.C) {
+	_, err := readAPIDescription(version.MustParse("1.9.0"), parseJSON(c, describeResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (*describeSuite) TestHighVersion(c *gc.C) {
+	description, err := readAPIDescription(version.MustParse("2.1.9"), parseJSON(c, describeResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(description.Resources(), gc.HasLen, 2)
+}
+
+func (s *describeSuite) TestDescribe(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/describe/", http.StatusOK, describeResponse)
+	description, err := controller.Describe()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(description.Resources(), gc.HasLen, 2)
+}
+
+func (s *describeSuite) TestDescribeServerError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/describe/", http.StatusInternalServerError, "boom")
+	_, err := controller.Describe()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (*describeSuite) TestReadAPIDescriptionDocument(c *gc.C) {
+	description, err := ReadAPIDescription(strings.NewReader(describeResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(description.Resources(), gc.HasLen, 2)
+}
+
+func (*describeSuite) TestReadAPIDescriptionDocumentBadJSON(c *gc.C) {
+	_, err := ReadAPIDescription(strings.NewReader("{"))
+	c.Check(err, gc.ErrorMatches, "reading API description: unexpected EOF")
+}
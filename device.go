@@ -14,6 +14,8 @@ import (
 )
 
 type device struct {
+	rawJSON
+
 	controller *controller
 
 	resourceURI string
@@ -105,6 +107,9 @@ func (a *CreateInterfaceArgs) Validate() error {
 	if a.MACAddress == "" {
 		return errors.NotValidf("missing MACAddress")
 	}
+	if _, err := NormalizeMACAddress(a.MACAddress); err != nil {
+		return errors.Trace(err)
+	}
 	if a.VLAN == nil {
 		return errors.NotValidf("missing VLAN")
 	}
@@ -124,7 +129,8 @@ func (d *device) CreateInterface(args CreateInterfaceArgs) (Interface, error) {
 	}
 	params := NewURLParams()
 	params.Values.Add("name", args.Name)
-	params.Values.Add("mac_address", args.MACAddress)
+	mac, _ := NormalizeMACAddress(args.MACAddress)
+	params.Values.Add("mac_address", mac)
 	params.Values.Add("vlan", fmt.Sprint(args.VLAN.ID()))
 	params.MaybeAdd("tags", strings.Join(args.Tags, ","))
 	params.MaybeAddInt("mtu", args.MTU)
@@ -145,7 +151,7 @@ func (d *device) CreateInterface(args CreateInterfaceArgs) (Interface, error) {
 		return nil, NewUnexpectedError(err)
 	}
 
-	iface, err := readInterface(d.controller.apiVersion, result)
+	iface, err := readInterface(d.controller.apiVersion, d.controller.markLeaves(result))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -241,15 +247,15 @@ var deviceDeserializationFuncs = map[version.Number]deviceDeserializationFunc{
 
 func device_2_0(source map[string]interface{}) (*device, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
+		"resource_uri": stringField(),
 
-		"system_id": schema.String(),
-		"hostname":  schema.String(),
-		"fqdn":      schema.String(),
-		"parent":    schema.OneOf(schema.Nil(""), schema.String()),
-		"owner":     schema.OneOf(schema.Nil(""), schema.String()),
+		"system_id": stringField(),
+		"hostname":  stringField(),
+		"fqdn":      stringField(),
+		"parent":    nullable(stringField()),
+		"owner":     nullable(stringField()),
 
-		"ip_addresses":  schema.List(schema.String()),
+		"ip_addresses":  schema.List(stringField()),
 		"interface_set": schema.List(schema.StringMap(schema.Any())),
 		"zone":          schema.StringMap(schema.Any()),
 	}
@@ -277,6 +283,7 @@ func device_2_0(source map[string]interface{}) (*device, error) {
 	owner, _ := valid["owner"].(string)
 	parent, _ := valid["parent"].(string)
 	result := &device{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 
 		systemID: valid["system_id"].(string),
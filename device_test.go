@@ -96,10 +96,10 @@ func (s *controllerSuite) TestCreateInterfaceArgsValidate(c *gc.C) {
 		args:    CreateInterfaceArgs{Name: "eth3"},
 		errText: "missing MACAddress not valid",
 	}, {
-		args:    CreateInterfaceArgs{Name: "eth3", MACAddress: "a-mac-address"},
+		args:    CreateInterfaceArgs{Name: "eth3", MACAddress: "52:54:00:c9:6a:45"},
 		errText: `missing VLAN not valid`,
 	}, {
-		args: CreateInterfaceArgs{Name: "eth3", MACAddress: "a-mac-address", VLAN: &fakeVLAN{}},
+		args: CreateInterfaceArgs{Name: "eth3", MACAddress: "52:54:00:c9:6a:45", VLAN: &fakeVLAN{}},
 	}} {
 		c.Logf("test %d", i)
 		err := test.args.Validate()
@@ -124,7 +124,7 @@ func (s *deviceSuite) TestCreateInterface(c *gc.C) {
 
 	iface, err := device.CreateInterface(CreateInterfaceArgs{
 		Name:       "eth43",
-		MACAddress: "some-mac-address",
+		MACAddress: "52:54:00:C9:6A:48",
 		VLAN:       &fakeVLAN{id: 33},
 		Tags:       []string{"foo", "bar"},
 	})
@@ -134,7 +134,7 @@ func (s *deviceSuite) TestCreateInterface(c *gc.C) {
 	request := server.LastRequest()
 	form := request.PostForm
 	c.Assert(form.Get("name"), gc.Equals, "eth43")
-	c.Assert(form.Get("mac_address"), gc.Equals, "some-mac-address")
+	c.Assert(form.Get("mac_address"), gc.Equals, "52:54:00:c9:6a:48")
 	c.Assert(form.Get("vlan"), gc.Equals, "33")
 	c.Assert(form.Get("tags"), gc.Equals, "foo,bar")
 }
@@ -142,7 +142,7 @@ func (s *deviceSuite) TestCreateInterface(c *gc.C) {
 func minimalCreateInterfaceArgs() CreateInterfaceArgs {
 	return CreateInterfaceArgs{
 		Name:       "eth43",
-		MACAddress: "some-mac-address",
+		MACAddress: "52:54:00:C9:6A:48",
 		VLAN:       &fakeVLAN{id: 33},
 	}
 }
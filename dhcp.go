@@ -0,0 +1,125 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// EnableDHCPArgs is an argument struct for Controller.EnableDHCP.
+type EnableDHCPArgs struct {
+	// VLAN is the VLAN to serve DHCP on. It must have been read from the
+	// controller.
+	VLAN VLAN
+
+	// PrimaryRack is the system ID of the rack controller that serves DHCP.
+	PrimaryRack string
+
+	// SecondaryRack is optional, and is the system ID of a second rack
+	// controller that takes over if the primary fails.
+	SecondaryRack string
+
+	// DynamicRange is the range of addresses to hand out to unknown
+	// machines. Only Start and End are used.
+	DynamicRange IPRange
+
+	// Comment is recorded against the dynamic range.
+	Comment string
+}
+
+// Validate ensures that the required values are set, and that the dynamic
+// range is a well formed range of a single IP family.
+func (a *EnableDHCPArgs) Validate() error {
+	if a.VLAN == nil {
+		return errors.NotValidf("missing VLAN")
+	}
+	if _, ok := a.VLAN.(*vlan); !ok {
+		return errors.NotValidf("VLAN not read from the controller")
+	}
+	if a.PrimaryRack == "" {
+		return errors.NotValidf("missing PrimaryRack")
+	}
+	if a.SecondaryRack == a.PrimaryRack {
+		return errors.NotValidf("SecondaryRack same as PrimaryRack")
+	}
+	start, end := net.ParseIP(a.DynamicRange.Start), net.ParseIP(a.DynamicRange.End)
+	if start == nil || end == nil {
+		return errors.NotValidf("DynamicRange %s-%s", a.DynamicRange.Start, a.DynamicRange.End)
+	}
+	if (start.To4() == nil) != (end.To4() == nil) || bytes.Compare(start.To16(), end.To16()) > 0 {
+		return errors.NotValidf("DynamicRange %s-%s", a.DynamicRange.Start, a.DynamicRange.End)
+	}
+	return nil
+}
+
+// EnableDHCP implements Controller.
+func (c *controller) EnableDHCP(args EnableDHCPArgs) (VLAN, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	rangeURI, err := c.createDynamicRange(args.DynamicRange, args.Comment)
+	if err != nil {
+		return nil, errors.Annotate(err, "creating dynamic range")
+	}
+
+	params := NewURLParams()
+	params.Values.Add("dhcp_on", "true")
+	params.Values.Add("primary_rack", args.PrimaryRack)
+	params.MaybeAdd("secondary_rack", args.SecondaryRack)
+	source, err := c.put(args.VLAN.(*vlan).resourceURI, params.Values)
+	if err != nil {
+		err = errors.Annotate(translateDHCPError(err), "enabling DHCP")
+		// Remove the range so that a failed attempt leaves no trace.
+		if rollbackErr := c.delete(rangeURI); rollbackErr != nil {
+			logger.Errorf("cannot remove dynamic range %s after failure: %v", rangeURI, rollbackErr)
+			return nil, errors.Annotatef(err, "dynamic range %s was not removed", rangeURI)
+		}
+		return nil, err
+	}
+	result, err := readVLAN(c.apiVersion, c.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// createDynamicRange creates a dynamic IP range, and returns its URI.
+func (c *controller) createDynamicRange(r IPRange, comment string) (string, error) {
+	params := NewURLParams()
+	params.Values.Add("type", "dynamic")
+	params.Values.Add("start_ip", r.Start)
+	params.Values.Add("end_ip", r.End)
+	params.MaybeAdd("comment", comment)
+	source, err := c.post("ipranges", "", params.Values)
+	if err != nil {
+		return "", translateDHCPError(err)
+	}
+	checker := schema.FieldMap(schema.Fields{
+		"resource_uri": stringField(),
+	}, nil)
+	coerced, err := checker.Coerce(c.markLeaves(source), nil)
+	if err != nil {
+		return "", WrapWithDeserializationError(err, "ip range schema check failed")
+	}
+	return coerced.(map[string]interface{})["resource_uri"].(string), nil
+}
+
+func translateDHCPError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
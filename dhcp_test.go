@@ -0,0 +1,155 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type dhcpSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&dhcpSuite{})
+
+const ipRangeResponse = `
+{
+    "id": 7,
+    "type": "dynamic",
+    "start_ip": "192.168.100.100",
+    "end_ip": "192.168.100.199",
+    "comment": "",
+    "resource_uri": "/MAAS/api/2.0/ipranges/7/"
+}
+`
+
+func (s *dhcpSuite) getServerAndVLAN(c *gc.C) (*SimpleTestServer, Controller, VLAN) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, fabricResponse)
+	fabrics, err := controller.Fabrics()
+	c.Assert(err, jc.ErrorIsNil)
+	// The untagged VLAN on fabric-1 has DHCP off.
+	vlan := fabrics[1].VLANs()[0]
+	server.ResetRequests()
+	return server, controller, vlan
+}
+
+func (s *dhcpSuite) enableArgs(vlan VLAN) EnableDHCPArgs {
+	return EnableDHCPArgs{
+		VLAN:          vlan,
+		PrimaryRack:   "4y3h7n",
+		SecondaryRack: "xr3g8a",
+		DynamicRange:  IPRange{Start: "192.168.100.100", End: "192.168.100.199"},
+		Comment:       "pxe",
+	}
+}
+
+func (s *dhcpSuite) TestEnableDHCP(c *gc.C) {
+	server, controller, vlan := s.getServerAndVLAN(c)
+	server.AddPostResponse("/api/2.0/ipranges/", http.StatusOK, ipRangeResponse)
+	response := `{
+		"name": "untagged",
+		"vid": 0,
+		"primary_rack": "4y3h7n",
+		"resource_uri": "/MAAS/api/2.0/vlans/5001/",
+		"id": 5001,
+		"secondary_rack": "xr3g8a",
+		"fabric": "fabric-1",
+		"mtu": 1500,
+		"dhcp_on": true
+	}`
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusOK, response)
+
+	result, err := controller.EnableDHCP(s.enableArgs(vlan))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.DHCP(), jc.IsTrue)
+	c.Check(result.PrimaryRack(), gc.Equals, "4y3h7n")
+	c.Check(result.SecondaryRack(), gc.Equals, "xr3g8a")
+
+	requests := server.LastNRequests(2)
+	form := requests[0].PostForm
+	c.Check(form.Get("type"), gc.Equals, "dynamic")
+	c.Check(form.Get("start_ip"), gc.Equals, "192.168.100.100")
+	c.Check(form.Get("end_ip"), gc.Equals, "192.168.100.199")
+	c.Check(form.Get("comment"), gc.Equals, "pxe")
+	form = requests[1].PostForm
+	c.Check(form.Get("dhcp_on"), gc.Equals, "true")
+	c.Check(form.Get("primary_rack"), gc.Equals, "4y3h7n")
+	c.Check(form.Get("secondary_rack"), gc.Equals, "xr3g8a")
+}
+
+func (s *dhcpSuite) TestEnableDHCPRangeFailure(c *gc.C) {
+	server, controller, vlan := s.getServerAndVLAN(c)
+	server.AddPostResponse("/api/2.0/ipranges/", http.StatusBadRequest, "range overlaps")
+	_, err := controller.EnableDHCP(s.enableArgs(vlan))
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err, gc.ErrorMatches, "creating dynamic range: range overlaps")
+	c.Assert(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *dhcpSuite) TestEnableDHCPRollsBack(c *gc.C) {
+	server, controller, vlan := s.getServerAndVLAN(c)
+	server.AddPostResponse("/api/2.0/ipranges/", http.StatusOK, ipRangeResponse)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusBadRequest, "rack not connected")
+	server.AddDeleteResponse("/MAAS/api/2.0/ipranges/7/", http.StatusNoContent, "")
+
+	_, err := controller.EnableDHCP(s.enableArgs(vlan))
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err, gc.ErrorMatches, "enabling DHCP: rack not connected")
+	c.Assert(server.RequestCount(), gc.Equals, 3)
+	c.Assert(server.LastRequest().Method, gc.Equals, "DELETE")
+}
+
+func (s *dhcpSuite) TestEnableDHCPRollbackFailure(c *gc.C) {
+	server, controller, vlan := s.getServerAndVLAN(c)
+	server.AddPostResponse("/api/2.0/ipranges/", http.StatusOK, ipRangeResponse)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusForbidden, "not admin")
+	server.AddDeleteResponse("/MAAS/api/2.0/ipranges/7/", http.StatusInternalServerError, "boom")
+
+	_, err := controller.EnableDHCP(s.enableArgs(vlan))
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err, gc.ErrorMatches, `dynamic range /MAAS/api/2.0/ipranges/7/ was not removed: enabling DHCP: not admin`)
+}
+
+func (s *dhcpSuite) TestEnableDHCPArgsValidate(c *gc.C) {
+	_, _, vlan := s.getServerAndVLAN(c)
+	for i, test := range []struct {
+		args EnableDHCPArgs
+		err  string
+	}{{
+		args: EnableDHCPArgs{},
+		err:  "missing VLAN not valid",
+	}, {
+		args: EnableDHCPArgs{VLAN: vlan},
+		err:  "missing PrimaryRack not valid",
+	}, {
+		args: EnableDHCPArgs{VLAN: vlan, PrimaryRack: "a", SecondaryRack: "a"},
+		err:  "SecondaryRack same as PrimaryRack not valid",
+	}, {
+		args: EnableDHCPArgs{VLAN: vlan, PrimaryRack: "a"},
+		err:  "DynamicRange - not valid",
+	}, {
+		args: EnableDHCPArgs{VLAN: vlan, PrimaryRack: "a", DynamicRange: IPRange{Start: "10.0.0.20", End: "10.0.0.10"}},
+		err:  "DynamicRange 10.0.0.20-10.0.0.10 not valid",
+	}, {
+		args: EnableDHCPArgs{VLAN: vlan, PrimaryRack: "a", DynamicRange: IPRange{Start: "10.0.0.20", End: "2001:db8::1"}},
+		err:  "DynamicRange 10.0.0.20-2001:db8::1 not valid",
+	}, {
+		args: EnableDHCPArgs{VLAN: vlan, PrimaryRack: "a", DynamicRange: IPRange{Start: "10.0.0.10", End: "10.0.0.20"}},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+type discoveredAddress struct {
+	rawJSON
+
+	controller *controller
+
+	subnet    *subnet
+	ipAddress string
+}
+
+// withController returns a copy of the address that uses the controller,
+// as the addresses of an interface are shared by everyone reading it.
+func (d *discoveredAddress) withController(c *controller) *discoveredAddress {
+	copied := *d
+	copied.controller = c
+	return &copied
+}
+
+// Subnet implements DiscoveredAddress.
+func (d *discoveredAddress) Subnet() Subnet {
+	if d.subnet == nil {
+		return nil
+	}
+	return d.subnet.withController(d.controller)
+}
+
+// IPAddress implements DiscoveredAddress.
+func (d *discoveredAddress) IPAddress() string {
+	return d.ipAddress
+}
+
+// Discovered implements Interface.
+func (i *interface_) Discovered() []DiscoveredAddress {
+	result := make([]DiscoveredAddress, len(i.discovered))
+	for index, address := range i.discovered {
+		result[index] = address.withController(i.controller)
+	}
+	return result
+}
+
+// DHCPLeases implements Interface.
+func (i *interface_) DHCPLeases() []DiscoveredAddress {
+	var result []DiscoveredAddress
+	for _, address := range i.discovered {
+		if i.dhcpLinkFor(address) != nil {
+			result = append(result, address.withController(i.controller))
+		}
+	}
+	return result
+}
+
+// AddressDrift describes how the addresses observed on an interface differ
+// from the links that MAAS configured on it.
+type AddressDrift struct {
+	// Missing lists the links whose addresses were not observed. That is
+	// static and auto links with an address that was not discovered, and
+	// DHCP links with no address discovered on their subnet.
+	Missing []Link
+
+	// Unexpected lists the discovered addresses that no link accounts for.
+	Unexpected []DiscoveredAddress
+}
+
+// InSync returns true if there is no drift.
+func (d AddressDrift) InSync() bool {
+	return len(d.Missing) == 0 && len(d.Unexpected) == 0
+}
+
+// AddressDrift implements Interface.
+func (i *interface_) AddressDrift() AddressDrift {
+	var drift AddressDrift
+	if len(i.discovered) == 0 {
+		return drift
+	}
+	discovered := make(map[string]bool)
+	for _, address := range i.discovered {
+		discovered[address.ipAddress] = true
+	}
+	for _, link := range i.links {
+		var missing bool
+		if isDHCPLink(link) {
+			missing = !i.hasDiscoveredOn(link.subnet)
+		} else {
+			missing = link.ipAddress != "" && !discovered[link.ipAddress]
+		}
+		if missing {
+			drift.Missing = append(drift.Missing, link.withController(i.controller))
+		}
+	}
+	assigned := make(map[string]bool)
+	for _, link := range i.links {
+		if link.ipAddress != "" {
+			assigned[link.ipAddress] = true
+		}
+	}
+	for _, address := range i.discovered {
+		if assigned[address.ipAddress] || i.dhcpLinkFor(address) != nil {
+			continue
+		}
+		drift.Unexpected = append(drift.Unexpected, address.withController(i.controller))
+	}
+	return drift
+}
+
+// dhcpLinkFor returns the DHCP link on the subnet of the address, if there
+// is one. The address of a DHCP link is not known in advance, so any
+// address on its subnet is taken to be its lease.
+func (i *interface_) dhcpLinkFor(address *discoveredAddress) *link {
+	if address.subnet == nil {
+		return nil
+	}
+	for _, link := range i.links {
+		if isDHCPLink(link) && link.subnet != nil && link.subnet.id == address.subnet.id {
+			return link
+		}
+	}
+	return nil
+}
+
+// isDHCPLink returns true if the link's mode is DHCP. Links report their
+// mode in lower case, where LinkSubnetArgs uses upper case.
+func isDHCPLink(k *link) bool {
+	return strings.EqualFold(k.mode, string(LinkModeDHCP))
+}
+
+func (i *interface_) hasDiscoveredOn(s *subnet) bool {
+	if s == nil {
+		return false
+	}
+	for _, address := range i.discovered {
+		if address.subnet != nil && address.subnet.id == s.id {
+			return true
+		}
+	}
+	return false
+}
+
+// readDiscoveredAddressList expects the values of the sourceList to be
+// string maps.
+func readDiscoveredAddressList(sourceList []interface{}) ([]*discoveredAddress, error) {
+	result := make([]*discoveredAddress, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for discovered address %d, %T", i, value)
+		}
+		address, err := discoveredAddress_2_0(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "discovered address %d", i)
+		}
+		result = append(result, address)
+	}
+	return result, nil
+}
+
+func discoveredAddress_2_0(source map[string]interface{}) (*discoveredAddress, error) {
+	fields := schema.Fields{
+		"subnet":     schema.StringMap(schema.Any()),
+		"ip_address": stringField(),
+	}
+	defaults := schema.Defaults{
+		"subnet": schema.Omit,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "discovered address 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var subnet *subnet
+	if value, ok := valid["subnet"]; ok {
+		subnet, err = subnet_2_0(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	result := &discoveredAddress{
+		rawJSON:   rawJSON{source},
+		subnet:    subnet,
+		ipAddress: valid["ip_address"].(string),
+	}
+	return result, nil
+}
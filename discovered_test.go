@@ -0,0 +1,122 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type discoveredSuite struct{}
+
+var _ = gc.Suite(&discoveredSuite{})
+
+// driftInterface returns an interface with a static link to 192.168.100.5
+// on subnet 1, a DHCP link on subnet 2, and the discovered addresses.
+func (*discoveredSuite) driftInterface(c *gc.C, discovered ...interface{}) *interface_ {
+	source := parseJSON(c, interfaceResponse).(map[string]interface{})
+	links := source["links"].([]interface{})
+	staticLink := links[0].(map[string]interface{})
+	staticLink["mode"] = "static"
+	staticLink["ip_address"] = "192.168.100.5"
+	source["links"] = []interface{}{staticLink, map[string]interface{}{
+		"id":     70,
+		"mode":   "dhcp",
+		"subnet": testSubnet(c, 2, "10.0.0.0/24"),
+	}}
+	if discovered == nil {
+		discovered = []interface{}{}
+	}
+	source["discovered"] = discovered
+	iface, err := readInterface(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	return iface
+}
+
+func testSubnet(c *gc.C, id int, cidr string) map[string]interface{} {
+	source := parseJSON(c, interfaceResponse).(map[string]interface{})
+	link := source["links"].([]interface{})[0].(map[string]interface{})
+	subnet := link["subnet"].(map[string]interface{})
+	subnet["id"] = id
+	subnet["cidr"] = cidr
+	subnet["name"] = cidr
+	return subnet
+}
+
+func discovered(c *gc.C, subnetID int, cidr, address string) map[string]interface{} {
+	return map[string]interface{}{
+		"subnet":     testSubnet(c, subnetID, cidr),
+		"ip_address": address,
+	}
+}
+
+func (s *discoveredSuite) TestReadDiscovered(c *gc.C) {
+	iface := s.driftInterface(c, discovered(c, 2, "10.0.0.0/24", "10.0.0.7"), map[string]interface{}{
+		"ip_address": "172.16.0.3",
+	})
+	addresses := iface.Discovered()
+	c.Assert(addresses, gc.HasLen, 2)
+	c.Check(addresses[0].IPAddress(), gc.Equals, "10.0.0.7")
+	c.Check(addresses[0].Subnet().CIDR(), gc.Equals, "10.0.0.0/24")
+	c.Check(addresses[1].IPAddress(), gc.Equals, "172.16.0.3")
+	c.Check(addresses[1].Subnet(), gc.IsNil)
+}
+
+func (*discoveredSuite) TestReadDiscoveredNullOrMissing(c *gc.C) {
+	source := parseJSON(c, interfaceResponse).(map[string]interface{})
+	source["discovered"] = nil
+	iface, err := readInterface(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Discovered(), gc.HasLen, 0)
+
+	delete(source, "discovered")
+	iface, err = readInterface(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Discovered(), gc.HasLen, 0)
+}
+
+func (*discoveredSuite) TestReadDiscoveredBadSchema(c *gc.C) {
+	source := parseJSON(c, interfaceResponse).(map[string]interface{})
+	source["discovered"] = []interface{}{map[string]interface{}{"subnet": "wat"}}
+	_, err := readInterface(twoDotOh, source)
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, `discovered address 0: discovered address 2.0 schema check failed: .*`)
+}
+
+func (s *discoveredSuite) TestDHCPLeases(c *gc.C) {
+	iface := s.driftInterface(c,
+		discovered(c, 1, "192.168.100.0/24", "192.168.100.5"),
+		discovered(c, 2, "10.0.0.0/24", "10.0.0.7"),
+	)
+	leases := iface.DHCPLeases()
+	c.Assert(leases, gc.HasLen, 1)
+	c.Check(leases[0].IPAddress(), gc.Equals, "10.0.0.7")
+}
+
+func (s *discoveredSuite) TestAddressDriftInSync(c *gc.C) {
+	iface := s.driftInterface(c,
+		discovered(c, 1, "192.168.100.0/24", "192.168.100.5"),
+		discovered(c, 2, "10.0.0.0/24", "10.0.0.7"),
+	)
+	drift := iface.AddressDrift()
+	c.Check(drift.InSync(), jc.IsTrue)
+}
+
+func (s *discoveredSuite) TestAddressDriftNothingDiscovered(c *gc.C) {
+	iface := s.driftInterface(c)
+	c.Check(iface.AddressDrift().InSync(), jc.IsTrue)
+}
+
+func (s *discoveredSuite) TestAddressDrift(c *gc.C) {
+	iface := s.driftInterface(c,
+		discovered(c, 1, "192.168.100.0/24", "192.168.100.9"),
+	)
+	drift := iface.AddressDrift()
+	c.Check(drift.InSync(), jc.IsFalse)
+	c.Assert(drift.Missing, gc.HasLen, 2)
+	c.Check(drift.Missing[0].IPAddress(), gc.Equals, "192.168.100.5")
+	c.Check(drift.Missing[1].Mode(), gc.Equals, "dhcp")
+	c.Assert(drift.Unexpected, gc.HasLen, 1)
+	c.Check(drift.Unexpected[0].IPAddress(), gc.Equals, "192.168.100.9")
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// RackHTTPPort is the port on which rack controllers proxy the metadata
+// service of the region for the nodes that boot from them.
+const RackHTTPPort = 5248
+
+// The paths of the enlistment resources, relative to the metadata URL.
+const (
+	enlistPreseedPath  = "latest/enlist-preseed/"
+	enlistPreseedOp    = "get_enlist_preseed"
+	enlistMetaDataPath = "enlist/latest/meta-data/"
+	enlistUserDataPath = "enlist/latest/user-data"
+)
+
+// EnlistmentURLs are the URLs that an unknown node booting from a rack
+// controller reads while it enlists. They are all served anonymously.
+type EnlistmentURLs struct {
+	// MetadataURL is the metadata service, such as
+	// http://10.0.0.2:5248/MAAS/metadata/.
+	MetadataURL string
+
+	// PreseedURL is the cloud-config-url given to the enlistment kernel.
+	PreseedURL string
+
+	// MetaDataURL lists the meta-data items of enlistment.
+	MetaDataURL string
+
+	// UserDataURL is the script that cloud-init runs to enlist the node.
+	UserDataURL string
+}
+
+// EnlistmentURLsForAddress returns the enlistment URLs that a node reaching
+// a rack controller at the address, an IP address or host name, uses.
+func EnlistmentURLsForAddress(address string) (EnlistmentURLs, error) {
+	if address == "" || strings.ContainsAny(address, "/[]") {
+		return EnlistmentURLs{}, errors.NotValidf("rack address %q", address)
+	}
+	metadataURL := "http://" + net.JoinHostPort(address, strconv.Itoa(RackHTTPPort)) + "/MAAS/metadata/"
+	return EnlistmentURLs{
+		MetadataURL: metadataURL,
+		PreseedURL:  metadataURL + enlistPreseedPath + "?op=" + enlistPreseedOp,
+		MetaDataURL: metadataURL + enlistMetaDataPath,
+		UserDataURL: metadataURL + enlistUserDataPath,
+	}, nil
+}
+
+// RackEnlistmentURLs returns the enlistment URLs for each IP address of the
+// rack controller, in the order of its addresses. Which one a node uses
+// depends on the subnet it boots from.
+func RackEnlistmentURLs(rack RackController) ([]EnlistmentURLs, error) {
+	addresses := rack.IPAddresses()
+	if len(addresses) == 0 {
+		return nil, errors.NotFoundf("IP address of rack controller %s", rack.SystemID())
+	}
+	result := make([]EnlistmentURLs, len(addresses))
+	for i, address := range addresses {
+		urls, err := EnlistmentURLsForAddress(address)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		result[i] = urls
+	}
+	return result, nil
+}
+
+// Enlistment is what a node booting from a rack controller receives when
+// it enlists.
+type Enlistment struct {
+	URLs EnlistmentURLs
+
+	// Preseed is the cloud-config read from the PreseedURL.
+	Preseed string
+
+	// MetaData holds the meta-data items of enlistment by name, such as
+	// "instance-id" and "local-hostname".
+	MetaData map[string]string
+
+	// UserData is the enlistment script.
+	UserData string
+}
+
+// FetchEnlistment reads the enlistment resources anonymously, as a booting
+// node does, so that the answers of a rack controller can be checked when
+// nodes fail to enlist. The resources are read from urls.MetadataURL.
+func FetchEnlistment(urls EnlistmentURLs) (Enlistment, error) {
+	result := Enlistment{URLs: urls}
+	metadata, err := newEnlistmentClient(urls.MetadataURL)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	if result.Preseed, err = metadata.get(enlistPreseedPath, enlistPreseedOp); err != nil {
+		return result, errors.Annotate(err, "reading enlistment preseed")
+	}
+	index, err := metadata.get(enlistMetaDataPath, "")
+	if err != nil {
+		return result, errors.Annotate(err, "reading enlistment meta-data")
+	}
+	result.MetaData = make(map[string]string)
+	for _, key := range strings.Fields(index) {
+		value, err := metadata.get(enlistMetaDataPath+key, "")
+		if err != nil {
+			return result, errors.Annotatef(err, "reading enlistment meta-data %q", key)
+		}
+		result.MetaData[key] = strings.TrimSpace(value)
+	}
+	if result.UserData, err = metadata.get(enlistUserDataPath, ""); err != nil {
+		return result, errors.Annotate(err, "reading enlistment user-data")
+	}
+	return result, nil
+}
+
+// enlistmentClient reads the metadata service anonymously, as a node that
+// MAAS does not know yet does.
+type enlistmentClient struct {
+	client *Client
+}
+
+func newEnlistmentClient(metadataURL string) (*enlistmentClient, error) {
+	client, err := NewAnonymousClient(metadataURL, "")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if client.APIURL, err = url.Parse(EnsureTrailingSlash(escapeIPv6Zone(metadataURL))); err != nil {
+		return nil, errors.Annotatef(err, "metadata URL %q", metadataURL)
+	}
+	return &enlistmentClient{client: client}, nil
+}
+
+// get returns the body of a resource of the metadata service, relative to
+// its URL.
+func (e *enlistmentClient) get(path, op string) (string, error) {
+	bytes, err := e.client.Get(&url.URL{Path: path}, op, nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return "", errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusUnauthorized, http.StatusForbidden:
+				return "", errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return "", NewUnexpectedError(err)
+	}
+	return string(bytes), nil
+}
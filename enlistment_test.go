@@ -0,0 +1,83 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type enlistmentSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&enlistmentSuite{})
+
+func (*enlistmentSuite) TestEnlistmentURLsForAddress(c *gc.C) {
+	urls, err := EnlistmentURLsForAddress("fd00::2")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(urls, jc.DeepEquals, EnlistmentURLs{
+		MetadataURL: "http://[fd00::2]:5248/MAAS/metadata/",
+		PreseedURL:  "http://[fd00::2]:5248/MAAS/metadata/latest/enlist-preseed/?op=get_enlist_preseed",
+		MetaDataURL: "http://[fd00::2]:5248/MAAS/metadata/enlist/latest/meta-data/",
+		UserDataURL: "http://[fd00::2]:5248/MAAS/metadata/enlist/latest/user-data",
+	})
+	for _, bad := range []string{"", "[fd00::2]", "rack/1"} {
+		_, err := EnlistmentURLsForAddress(bad)
+		c.Check(err, jc.Satisfies, errors.IsNotValid, gc.Commentf("%q", bad))
+	}
+}
+
+func (*enlistmentSuite) TestRackEnlistmentURLs(c *gc.C) {
+	racks, err := readRackControllers(twoDotOh, parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	urls, err := RackEnlistmentURLs(racks[0])
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(urls, gc.HasLen, 1)
+	c.Check(urls[0].MetadataURL, gc.Equals, "http://192.168.100.2:5248/MAAS/metadata/")
+
+	_, err = RackEnlistmentURLs(&rackController{systemID: "4y3h7n"})
+	c.Check(err, gc.ErrorMatches, "IP address of rack controller 4y3h7n not found")
+}
+
+func (s *enlistmentSuite) TestFetchEnlistment(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/MAAS/metadata/latest/enlist-preseed/?op=get_enlist_preseed", http.StatusOK, "#cloud-config\n")
+	server.AddGetResponse("/MAAS/metadata/enlist/latest/meta-data/", http.StatusOK, "instance-id\nlocal-hostname\n")
+	server.AddGetResponse("/MAAS/metadata/enlist/latest/meta-data/instance-id", http.StatusOK, "i-maas-enlistment")
+	server.AddGetResponse("/MAAS/metadata/enlist/latest/meta-data/local-hostname", http.StatusOK, "maas-enlisting-node\n")
+	server.AddGetResponse("/MAAS/metadata/enlist/latest/user-data", http.StatusOK, "#!/bin/sh\n")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	urls := EnlistmentURLs{MetadataURL: server.URL + "/MAAS/metadata/"}
+	enlistment, err := FetchEnlistment(urls)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(enlistment, jc.DeepEquals, Enlistment{
+		URLs:    urls,
+		Preseed: "#cloud-config\n",
+		MetaData: map[string]string{
+			"instance-id":    "i-maas-enlistment",
+			"local-hostname": "maas-enlisting-node",
+		},
+		UserData: "#!/bin/sh\n",
+	})
+	// The node is not known yet, so the requests are anonymous.
+	c.Check(server.LastRequest().Header.Get("Authorization"), gc.Equals, "")
+}
+
+func (s *enlistmentSuite) TestFetchEnlistmentError(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/MAAS/metadata/latest/enlist-preseed/?op=get_enlist_preseed", http.StatusNotFound, "no preseed")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	_, err := FetchEnlistment(EnlistmentURLs{MetadataURL: server.URL + "/MAAS/metadata/"})
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, "reading enlistment preseed: no preseed")
+}
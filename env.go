@@ -0,0 +1,109 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// The environment variables read by NewClientFromEnv. The standard
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables are also honoured.
+const (
+	// APIURLEnvVar is the root of the MAAS server, such as
+	// http://maas.example.com/MAAS/. A URL that includes the API version,
+	// as used by the maas CLI, is also accepted.
+	APIURLEnvVar = "MAAS_API_URL"
+
+	// APIVersionEnvVar overrides the API version. It defaults to 2.0.
+	APIVersionEnvVar = "MAAS_API_VERSION"
+
+	// CACertEnvVar is the path of a PEM file with the certificates that
+	// are trusted to sign the server's certificate, in addition to the
+	// system ones.
+	CACertEnvVar = "MAAS_CA_CERT"
+
+	// InsecureSkipVerifyEnvVar disables verification of the server's
+	// certificate when set to a true value. Use it only for testing.
+	InsecureSkipVerifyEnvVar = "MAAS_INSECURE_SKIP_VERIFY"
+)
+
+const defaultEnvAPIVersion = "2.0"
+
+// NewClientFromEnv creates a Client configured from the environment. The
+// client is authenticated with the key in MAAS_API_KEY if it is set, and
+// anonymous otherwise. Connections are reused as described by
+// DefaultTransportOptions.
+func NewClientFromEnv() (*Client, error) {
+	baseURL := strings.TrimSpace(os.Getenv(APIURLEnvVar))
+	if baseURL == "" {
+		return nil, errors.NotFoundf("MAAS URL in $%s", APIURLEnvVar)
+	}
+	apiVersion := strings.TrimSpace(os.Getenv(APIVersionEnvVar))
+	if match := apiURLPattern.FindStringSubmatch(baseURL); match != nil {
+		baseURL = match[1]
+		if apiVersion == "" {
+			apiVersion = match[2]
+		}
+	}
+	if apiVersion == "" {
+		apiVersion = defaultEnvAPIVersion
+	}
+	httpClient, err := NewHTTPClient(DefaultTransportOptions())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := configureTLSFromEnv(httpClient.Transport.(*http.Transport)); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var client *Client
+	apiKey := strings.TrimSpace(os.Getenv(APIKeyEnvVar))
+	if apiKey == "" {
+		client, err = NewAnonymousClient(baseURL, apiVersion)
+	} else {
+		client, err = NewAuthenticatedClient(baseURL, apiKey, apiVersion)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	client.HTTPClient = httpClient
+	return client, nil
+}
+
+func configureTLSFromEnv(transport *http.Transport) error {
+	config := transport.TLSClientConfig
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if value := os.Getenv(InsecureSkipVerifyEnvVar); value != "" {
+		skip, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.NotValidf("$%s value %q", InsecureSkipVerifyEnvVar, value)
+		}
+		config.InsecureSkipVerify = skip
+	}
+	if path := os.Getenv(CACertEnvVar); path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Annotatef(err, "reading $%s", CACertEnvVar)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.NotValidf("$%s file %q with no certificates", CACertEnvVar, path)
+		}
+		config.RootCAs = pool
+	}
+	transport.TLSClientConfig = config
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type envSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&envSuite{})
+
+func (s *envSuite) TestMissingURL(c *gc.C) {
+	_, err := NewClientFromEnv()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `MAAS URL in \$MAAS_API_URL not found`)
+}
+
+func (s *envSuite) TestAnonymous(c *gc.C) {
+	s.PatchEnvironment(APIURLEnvVar, "http://maas.example.com/MAAS/")
+	client, err := NewClientFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.APIURL.String(), gc.Equals, "http://maas.example.com/MAAS/api/2.0/")
+	c.Assert(client.Signer, gc.FitsTypeOf, &anonSigner{})
+	c.Assert(client.HTTPClient, gc.NotNil)
+}
+
+func (s *envSuite) TestAuthenticated(c *gc.C) {
+	s.PatchEnvironment(APIURLEnvVar, "http://maas.example.com/MAAS/")
+	s.PatchEnvironment(APIKeyEnvVar, "a:b:c")
+	s.PatchEnvironment(APIVersionEnvVar, "1.0")
+	client, err := NewClientFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.APIURL.String(), gc.Equals, "http://maas.example.com/MAAS/api/1.0/")
+	c.Assert(client.Signer, gc.FitsTypeOf, &plainTextOAuthSigner{})
+}
+
+func (s *envSuite) TestBadKey(c *gc.C) {
+	s.PatchEnvironment(APIURLEnvVar, "http://maas.example.com/MAAS/")
+	s.PatchEnvironment(APIKeyEnvVar, "a:b")
+	_, err := NewClientFromEnv()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *envSuite) TestVersionedURL(c *gc.C) {
+	s.PatchEnvironment(APIURLEnvVar, "http://maas.example.com/MAAS/api/2.0/")
+	client, err := NewClientFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(client.APIURL.String(), gc.Equals, "http://maas.example.com/MAAS/api/2.0/")
+}
+
+func (s *envSuite) TestProxy(c *gc.C) {
+	s.PatchEnvironment(APIURLEnvVar, "http://maas.example.com/MAAS/")
+	client, err := NewClientFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	// The proxy variables are read by http.ProxyFromEnvironment, which
+	// caches them for the life of the process.
+	transport := client.HTTPClient.Transport.(*http.Transport)
+	c.Assert(transport.Proxy, gc.NotNil)
+}
+
+func (s *envSuite) TestInsecureSkipVerify(c *gc.C) {
+	s.PatchEnvironment(APIURLEnvVar, "https://maas.example.com/MAAS/")
+	s.PatchEnvironment(InsecureSkipVerifyEnvVar, "true")
+	client, err := NewClientFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	transport := client.HTTPClient.Transport.(*http.Transport)
+	c.Assert(transport.TLSClientConfig.InsecureSkipVerify, jc.IsTrue)
+
+	s.PatchEnvironment(InsecureSkipVerifyEnvVar, "sometimes")
+	_, err = NewClientFromEnv()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *envSuite) TestCACert(c *gc.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`"ok"`))
+	}))
+	defer server.Close()
+	path := filepath.Join(c.MkDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	err := ioutil.WriteFile(path, certPEM, 0600)
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchEnvironment(APIURLEnvVar, server.URL+"/MAAS/")
+	s.PatchEnvironment(CACertEnvVar, path)
+
+	client, err := NewClientFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	result, err := client.Get(&url.URL{Path: "version/"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(result), gc.Equals, `"ok"`)
+}
+
+func (s *envSuite) TestCACertNoCertificates(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "ca.pem")
+	err := ioutil.WriteFile(path, []byte("not a certificate"), 0600)
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchEnvironment(APIURLEnvVar, "https://maas.example.com/MAAS/")
+	s.PatchEnvironment(CACertEnvVar, path)
+	_, err = NewClientFromEnv()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// erasePollInterval is how often WatchErasing reads the machines and their
+// event logs.
+var erasePollInterval = 15 * time.Second
+
+// Status names of machines erasing their disks.
+const (
+	statusNameDiskErasing       = "Disk erasing"
+	statusNameFailedDiskErasing = "Failed disk erasing"
+)
+
+// EraseProgress is passed to the progress callback of
+// Controller.WatchErasing when a machine logs events or finishes.
+type EraseProgress struct {
+	// Machine is the machine as last read from MAAS.
+	Machine Machine
+	// Events are the entries added to the machine's event log since the
+	// previous report, oldest first.
+	Events []Event
+	// Done is true for the last report for the machine, when it reached
+	// Ready or failed.
+	Done bool
+	// Err is set in the last report if the machine failed to erase its
+	// disks or disappeared.
+	Err error
+}
+
+// WatchErasing implements Controller.
+func (c *controller) WatchErasing(ctx context.Context, systemIDs []string, progress func(EraseProgress)) error {
+	if len(systemIDs) == 0 {
+		return nil
+	}
+	pending := set.NewStrings(systemIDs...)
+	machines, err := c.Machines(MachinesArgs{SystemIDs: pending.SortedValues()})
+	if err != nil {
+		return errors.Annotate(err, "reading machines")
+	}
+	// Only the events logged from now on are reported.
+	lastEvent := make(map[string]int)
+	for _, machine := range machines {
+		events, err := machine.Events(0)
+		if err != nil {
+			return errors.Annotatef(err, "reading events of machine %s", machine.SystemID())
+		}
+		lastEvent[machine.SystemID()] = newestEventID(events)
+	}
+	if len(machines) != pending.Size() {
+		found := set.NewStrings()
+		for _, machine := range machines {
+			found.Add(machine.SystemID())
+		}
+		missing := pending.Difference(found).SortedValues()
+		return NewNoMatchError(fmt.Sprintf("machines %s not found", strings.Join(missing, ", ")))
+	}
+
+	var failed []string
+	for !pending.IsEmpty() {
+		select {
+		case <-ctx.Done():
+			return errors.Annotate(ctx.Err(), "waiting for disk erasing")
+		case <-c.clock.After(erasePollInterval):
+		}
+		machines, err := c.Machines(MachinesArgs{SystemIDs: pending.SortedValues()})
+		if err != nil {
+			return errors.Annotate(err, "reading machines")
+		}
+		seen := set.NewStrings()
+		for _, machine := range machines {
+			systemID := machine.SystemID()
+			if !pending.Contains(systemID) {
+				continue
+			}
+			seen.Add(systemID)
+			events, err := machine.Events(0)
+			if err != nil {
+				return errors.Annotatef(err, "reading events of machine %s", systemID)
+			}
+			update := EraseProgress{
+				Machine: machine,
+				Events:  eventsAfter(events, lastEvent[systemID]),
+			}
+			lastEvent[systemID] = newestEventID(events)
+			switch status := machine.StatusName(); status {
+			case statusNameReady:
+				update.Done = true
+			case statusNameFailedDiskErasing, statusNameBroken:
+				message := fmt.Sprintf("machine %s: %s", systemID, status)
+				if detail := machine.StatusMessage(); detail != "" {
+					message += ": " + detail
+				}
+				update.Done = true
+				update.Err = NewCannotCompleteError(message)
+			}
+			if update.Done {
+				pending.Remove(systemID)
+				if update.Err != nil {
+					failed = append(failed, systemID)
+				}
+			}
+			if progress != nil && (update.Done || len(update.Events) > 0) {
+				progress(update)
+			}
+		}
+		// Machines that are no longer listed were deleted.
+		for _, systemID := range pending.Difference(seen).SortedValues() {
+			pending.Remove(systemID)
+			failed = append(failed, systemID)
+			if progress != nil {
+				progress(EraseProgress{
+					Done: true,
+					Err:  NewNoMatchError(fmt.Sprintf("machine %s not found", systemID)),
+				})
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return NewCannotCompleteError(fmt.Sprintf("disk erasing failed for machines %s", strings.Join(failed, ", ")))
+	}
+	return nil
+}
+
+// newestEventID returns the largest event ID, or zero if there are no
+// events.
+func newestEventID(events []Event) int {
+	newest := 0
+	for _, event := range events {
+		if event.ID > newest {
+			newest = event.ID
+		}
+	}
+	return newest
+}
+
+// eventsAfter returns the events with IDs greater than last, oldest first.
+// MAAS lists events newest first.
+func eventsAfter(events []Event, last int) []Event {
+	var result []Event
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].ID > last {
+			result = append(result, events[i])
+		}
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type eraseSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&eraseSuite{})
+
+func (s *eraseSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.PatchValue(&erasePollInterval, time.Millisecond)
+}
+
+// eraseEventsJSON returns an events query response with the events for the
+// IDs, which are listed newest first as MAAS does.
+func eraseEventsJSON(c *gc.C, ids ...int) string {
+	events := []interface{}{}
+	for _, id := range ids {
+		events = append(events, map[string]interface{}{
+			"id":          id,
+			"level":       "INFO",
+			"created":     "Thu, 01 Sep. 2016 10:00:00",
+			"type":        "Erasing disk",
+			"description": "step",
+		})
+	}
+	out, err := json.Marshal(map[string]interface{}{"count": len(events), "events": events})
+	c.Assert(err, jc.ErrorIsNil)
+	return string(out)
+}
+
+func (s *eraseSuite) TestWatchErasing(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=4y3ha4", http.StatusOK,
+		"["+machineJSON(c, "4y3ha3", "Disk erasing", "")+","+machineJSON(c, "4y3ha4", "Disk erasing", "")+"]")
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&op=query", http.StatusOK, eraseEventsJSON(c, 10, 9))
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha4&op=query", http.StatusOK, eraseEventsJSON(c))
+	// The first machine logs progress, then finishes; the second fails.
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=4y3ha4", http.StatusOK,
+		"["+machineJSON(c, "4y3ha3", "Disk erasing", "")+","+machineJSON(c, "4y3ha4", "Failed disk erasing", "bad disk")+"]")
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&op=query", http.StatusOK, eraseEventsJSON(c, 12, 11, 10, 9))
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha4&op=query", http.StatusOK, eraseEventsJSON(c, 20))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Ready", "")+"]")
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&op=query", http.StatusOK, eraseEventsJSON(c, 13, 12, 11, 10, 9))
+
+	var updates []EraseProgress
+	err := controller.WatchErasing(context.Background(), []string{"4y3ha3", "4y3ha4"}, func(update EraseProgress) {
+		updates = append(updates, update)
+	})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, "disk erasing failed for machines 4y3ha4")
+
+	c.Assert(updates, gc.HasLen, 3)
+	c.Check(updates[0].Machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(eventIDs(updates[0].Events), jc.DeepEquals, []int{11, 12})
+	c.Check(updates[0].Done, jc.IsFalse)
+
+	c.Check(updates[1].Machine.SystemID(), gc.Equals, "4y3ha4")
+	c.Check(eventIDs(updates[1].Events), jc.DeepEquals, []int{20})
+	c.Check(updates[1].Done, jc.IsTrue)
+	c.Check(updates[1].Err, gc.ErrorMatches, "machine 4y3ha4: Failed disk erasing: bad disk")
+
+	c.Check(updates[2].Machine.StatusName(), gc.Equals, "Ready")
+	c.Check(eventIDs(updates[2].Events), jc.DeepEquals, []int{13})
+	c.Check(updates[2].Done, jc.IsTrue)
+	c.Check(updates[2].Err, jc.ErrorIsNil)
+}
+
+func eventIDs(events []Event) []int {
+	var ids []int
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func (s *eraseSuite) TestWatchErasingAllReady(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Disk erasing", "")+"]")
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&op=query", http.StatusOK, eraseEventsJSON(c, 1))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Ready", "")+"]")
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&op=query", http.StatusOK, eraseEventsJSON(c, 1))
+
+	err := controller.WatchErasing(context.Background(), []string{"4y3ha3"}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *eraseSuite) TestWatchErasingNoMachines(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	err := controller.WatchErasing(context.Background(), nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *eraseSuite) TestWatchErasingMissingMachine(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=missing", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Disk erasing", "")+"]")
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&op=query", http.StatusOK, eraseEventsJSON(c))
+	err := controller.WatchErasing(context.Background(), []string{"4y3ha3", "missing"}, nil)
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, "machines missing not found")
+}
+
+func (s *eraseSuite) TestWatchErasingMachineDeleted(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Disk erasing", "")+"]")
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&op=query", http.StatusOK, eraseEventsJSON(c))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "[]")
+
+	var updates []EraseProgress
+	err := controller.WatchErasing(context.Background(), []string{"4y3ha3"}, func(update EraseProgress) {
+		updates = append(updates, update)
+	})
+	c.Assert(err, gc.ErrorMatches, "disk erasing failed for machines 4y3ha3")
+	c.Assert(updates, gc.HasLen, 1)
+	c.Check(updates[0].Done, jc.IsTrue)
+	c.Check(updates[0].Err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *eraseSuite) TestWatchErasingContextDone(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	s.PatchValue(&erasePollInterval, time.Hour)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Disk erasing", "")+"]")
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&op=query", http.StatusOK, eraseEventsJSON(c))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := controller.WatchErasing(ctx, []string{"4y3ha3"}, nil)
+	c.Assert(err, gc.ErrorMatches, "waiting for disk erasing: context canceled")
+}
+
+func (*eraseSuite) TestEventsAfter(c *gc.C) {
+	events := []Event{{ID: 5}, {ID: 4}, {ID: 3}}
+	c.Check(eventIDs(eventsAfter(events, 3)), jc.DeepEquals, []int{4, 5})
+	c.Check(eventsAfter(events, 5), gc.HasLen, 0)
+	c.Check(newestEventID(events), gc.Equals, 5)
+	c.Check(newestEventID(nil), gc.Equals, 0)
+}
@@ -4,7 +4,11 @@
 package gomaasapi
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/juju/errors"
 )
@@ -110,18 +114,118 @@ type BadRequestError struct {
 }
 
 // NewBadRequestError constructs a new BadRequestError and sets the location.
+// If the message is a JSON object of field errors, as MAAS returns when a
+// form fails validation, a ValidationError is returned instead.
 func NewBadRequestError(message string) error {
+	if fields, ok := parseValidationErrors(message); ok {
+		err := newValidationError(fields)
+		err.SetLocation(1)
+		return err
+	}
 	err := &BadRequestError{Err: errors.NewErr(message)}
 	err.SetLocation(1)
 	return err
 }
 
-// IsBadRequestError returns true if err is a NoMatchError.
+// IsBadRequestError returns true if err is a BadRequestError or a
+// ValidationError.
 func IsBadRequestError(err error) bool {
-	_, ok := errors.Cause(err).(*BadRequestError)
+	switch errors.Cause(err).(type) {
+	case *BadRequestError, *ValidationError:
+		return true
+	}
+	return false
+}
+
+// NonFieldErrors is the key that MAAS uses for validation errors that do not
+// belong to a single field.
+const NonFieldErrors = "__all__"
+
+// ValidationError is returned when the controller rejects a request because
+// some of its parameters failed validation. Fields maps the parameter names
+// to the messages for them. Messages that are not about one parameter are
+// under NonFieldErrors.
+type ValidationError struct {
+	errors.Err
+	Fields map[string][]string
+}
+
+// NewValidationError constructs a new ValidationError for the field messages
+// and sets the location.
+func NewValidationError(fields map[string][]string) error {
+	err := newValidationError(fields)
+	err.SetLocation(1)
+	return err
+}
+
+func newValidationError(fields map[string][]string) *ValidationError {
+	var parts []string
+	for _, field := range sortedFieldNames(fields) {
+		message := strings.Join(fields[field], " ")
+		if field != NonFieldErrors {
+			message = field + ": " + message
+		}
+		parts = append(parts, message)
+	}
+	return &ValidationError{
+		Err:    errors.NewErr(strings.Join(parts, "; ")),
+		Fields: fields,
+	}
+}
+
+// FieldNames returns the names of the fields with messages, sorted.
+func (e *ValidationError) FieldNames() []string {
+	return sortedFieldNames(e.Fields)
+}
+
+// Messages returns the messages for the field, or nil if there are none.
+func (e *ValidationError) Messages(field string) []string {
+	return e.Fields[field]
+}
+
+// IsValidationError returns true if err is a ValidationError.
+func IsValidationError(err error) bool {
+	_, ok := errors.Cause(err).(*ValidationError)
 	return ok
 }
 
+func sortedFieldNames(fields map[string][]string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseValidationErrors parses a response body of the form
+// {"field": ["message", ...], ...}. A single message as a string is also
+// accepted. It returns false if the body is not of that form.
+func parseValidationErrors(body string) (map[string][]string, bool) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &parsed); err != nil || len(parsed) == 0 {
+		return nil, false
+	}
+	fields := make(map[string][]string)
+	for field, value := range parsed {
+		switch value := value.(type) {
+		case string:
+			fields[field] = []string{value}
+		case []interface{}:
+			for _, item := range value {
+				message, ok := item.(string)
+				if !ok {
+					return nil, false
+				}
+				fields[field] = append(fields[field], message)
+			}
+		default:
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
 // PermissionError is returned when the user does not have permission to do the
 // requested action.
 type PermissionError struct {
@@ -159,3 +263,137 @@ func IsCannotCompleteError(err error) bool {
 	_, ok := errors.Cause(err).(*CannotCompleteError)
 	return ok
 }
+
+// ArgumentError is returned when an argument is found to be malformed
+// before any request is sent to the controller. Field is the name of the
+// argument struct field that is in error.
+type ArgumentError struct {
+	errors.Err
+	Field string
+}
+
+// NewArgumentError constructs a new ArgumentError for the field and sets the
+// location.
+func NewArgumentError(field, format string, args ...interface{}) error {
+	err := &ArgumentError{
+		Err:   errors.NewErr("%s: %s", field, fmt.Sprintf(format, args...)),
+		Field: field,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsArgumentError returns true if err is an ArgumentError.
+func IsArgumentError(err error) bool {
+	_, ok := errors.Cause(err).(*ArgumentError)
+	return ok
+}
+
+// ConflictError is returned when an update is refused because the object
+// changed on the server since it was read. Field is the attribute that was
+// compared, and Expected and Actual are its values when the object was
+// read and when it was checked.
+type ConflictError struct {
+	errors.Err
+	Field    string
+	Expected string
+	Actual   string
+}
+
+// NewConflictError constructs a new ConflictError and sets the location.
+func NewConflictError(field, expected, actual string) error {
+	err := &ConflictError{
+		Err:      errors.NewErr("object changed on the server: %s is %q, expected %q", field, actual, expected),
+		Field:    field,
+		Expected: expected,
+		Actual:   actual,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsConflictError returns true if err is a ConflictError.
+func IsConflictError(err error) bool {
+	_, ok := errors.Cause(err).(*ConflictError)
+	return ok
+}
+
+// PowerCycleError is returned by Machine.PowerCycle when one of its stages
+// fails. Stage names the stage and Reason is the error that stopped it.
+type PowerCycleError struct {
+	errors.Err
+	Stage  PowerCycleStage
+	Reason error
+}
+
+// NewPowerCycleError constructs a new PowerCycleError for the stage and
+// sets the location.
+func NewPowerCycleError(stage PowerCycleStage, reason error) error {
+	err := &PowerCycleError{
+		Err:    errors.NewErr("%s: %v", stage, reason),
+		Stage:  stage,
+		Reason: reason,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsPowerCycleError returns true if err is a PowerCycleError.
+func IsPowerCycleError(err error) bool {
+	_, ok := errors.Cause(err).(*PowerCycleError)
+	return ok
+}
+
+// PowerStateUnknownError is returned by CheckPowerKnown when the power
+// state of a machine is not known. SystemID is the machine and State its
+// power state.
+type PowerStateUnknownError struct {
+	errors.Err
+	SystemID string
+	State    PowerState
+}
+
+// NewPowerStateUnknownError constructs a new PowerStateUnknownError and
+// sets the location.
+func NewPowerStateUnknownError(systemID string, state PowerState) error {
+	err := &PowerStateUnknownError{
+		Err:      errors.NewErr("machine %s: power state is %s, use force to act on it anyway", systemID, state),
+		SystemID: systemID,
+		State:    state,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsPowerStateUnknownError returns true if err is a PowerStateUnknownError.
+func IsPowerStateUnknownError(err error) bool {
+	_, ok := errors.Cause(err).(*PowerStateUnknownError)
+	return ok
+}
+
+// CircuitOpenError is returned instead of sending a request when the
+// CircuitBreaker for its region is open. BaseURL is the region and Until
+// is when a request will next be tried.
+type CircuitOpenError struct {
+	errors.Err
+	BaseURL string
+	Until   time.Time
+}
+
+// NewCircuitOpenError constructs a new CircuitOpenError and sets the
+// location.
+func NewCircuitOpenError(baseURL string, until time.Time) error {
+	err := &CircuitOpenError{
+		Err:     errors.NewErr("circuit open for %s until %s", baseURL, until.Format(time.RFC3339)),
+		BaseURL: baseURL,
+		Until:   until,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsCircuitOpenError returns true if err is a CircuitOpenError.
+func IsCircuitOpenError(err error) bool {
+	_, ok := errors.Cause(err).(*CircuitOpenError)
+	return ok
+}
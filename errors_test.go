@@ -60,6 +60,41 @@ func (*errorTypesSuite) TestBadRequestError(c *gc.C) {
 	c.Assert(err.Error(), gc.Equals, "omg")
 }
 
+func (*errorTypesSuite) TestValidationError(c *gc.C) {
+	err := NewValidationError(map[string][]string{
+		"hostname":     {"Node with this Hostname already exists."},
+		NonFieldErrors: {"Bad things.", "Worse things."},
+	})
+	c.Assert(err, gc.NotNil)
+	c.Assert(err, jc.Satisfies, IsValidationError)
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "Bad things. Worse things.; hostname: Node with this Hostname already exists.")
+	verr := err.(*ValidationError)
+	c.Check(verr.FieldNames(), jc.DeepEquals, []string{NonFieldErrors, "hostname"})
+	c.Check(verr.Messages("hostname"), jc.DeepEquals, []string{"Node with this Hostname already exists."})
+	c.Check(verr.Messages("domain"), gc.IsNil)
+}
+
+func (*errorTypesSuite) TestBadRequestErrorWithFieldErrors(c *gc.C) {
+	err := NewBadRequestError(`{"mac_addresses": ["'wat' is not a valid MAC address."], "domain": "Unknown domain."}`)
+	c.Assert(err, jc.Satisfies, IsValidationError)
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.(*ValidationError).Fields, jc.DeepEquals, map[string][]string{
+		"mac_addresses": {"'wat' is not a valid MAC address."},
+		"domain":        {"Unknown domain."},
+	})
+	c.Check(err.Error(), gc.Equals, "domain: Unknown domain.; mac_addresses: 'wat' is not a valid MAC address.")
+}
+
+func (*errorTypesSuite) TestBadRequestErrorNotFieldErrors(c *gc.C) {
+	for _, body := range []string{`{}`, `[]`, `"omg"`, `{"count": 3}`, `{"name": [1]}`, `{"name": "ok", "other": null}`} {
+		err := NewBadRequestError(body)
+		c.Check(err, gc.Not(jc.Satisfies), IsValidationError, gc.Commentf(body))
+		c.Check(err, jc.Satisfies, IsBadRequestError)
+		c.Check(err.Error(), gc.Equals, body)
+	}
+}
+
 func (*errorTypesSuite) TestPermissionError(c *gc.C) {
 	err := NewPermissionError("naughty")
 	c.Assert(err, gc.NotNil)
@@ -73,3 +108,29 @@ func (*errorTypesSuite) TestCannotCompleteError(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
 	c.Assert(err.Error(), gc.Equals, "server says no")
 }
+
+func (*errorTypesSuite) TestArgumentError(c *gc.C) {
+	err := NewArgumentError("MinMemory", "%d is negative", -1)
+	c.Assert(err, gc.NotNil)
+	c.Assert(err, jc.Satisfies, IsArgumentError)
+	c.Assert(err.Error(), gc.Equals, "MinMemory: -1 is negative")
+	c.Assert(err.(*ArgumentError).Field, gc.Equals, "MinMemory")
+}
+
+func (*errorTypesSuite) TestConflictError(c *gc.C) {
+	err := NewConflictError("updated", "then", "now")
+	c.Assert(err, gc.NotNil)
+	c.Assert(err, jc.Satisfies, IsConflictError)
+	c.Assert(err.Error(), gc.Equals, `object changed on the server: updated is "now", expected "then"`)
+	c.Assert(err.(*ConflictError).Field, gc.Equals, "updated")
+}
+
+func (*errorTypesSuite) TestPowerCycleError(c *gc.C) {
+	reason := NewCannotCompleteError("bmc unreachable")
+	err := NewPowerCycleError(PowerCycleOn, reason)
+	c.Assert(err, gc.NotNil)
+	c.Assert(err, jc.Satisfies, IsPowerCycleError)
+	c.Assert(err.Error(), gc.Equals, "powering on: bmc unreachable")
+	c.Assert(err.(*PowerCycleError).Stage, gc.Equals, PowerCycleOn)
+	c.Assert(err.(*PowerCycleError).Reason, gc.Equals, reason)
+}
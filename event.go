@@ -0,0 +1,94 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// Event is an entry in the MAAS event log for a node.
+type Event struct {
+	ID          int
+	Level       string
+	Created     string
+	Type        string
+	Description string
+}
+
+// readEvents reads the response of the events query operation, which wraps
+// the list of events in an object with paging information.
+func readEvents(controllerVersion version.Number, source interface{}) ([]Event, error) {
+	checker := schema.FieldMap(schema.Fields{
+		"events": schema.List(schema.StringMap(schema.Any())),
+	}, nil)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "event base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})["events"].([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range eventDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no event read func for version %s", controllerVersion)
+	}
+	readFunc := eventDeserializationFuncs[deserialisationVersion]
+	return readEventList(valid, readFunc)
+}
+
+// readEventList expects the values of the sourceList to be string maps.
+func readEventList(sourceList []interface{}, readFunc eventDeserializationFunc) ([]Event, error) {
+	result := make([]Event, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for event %d, %T", i, value)
+		}
+		event, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "event %d", i)
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+type eventDeserializationFunc func(map[string]interface{}) (Event, error)
+
+var eventDeserializationFuncs = map[version.Number]eventDeserializationFunc{
+	twoDotOh: event_2_0,
+}
+
+func event_2_0(source map[string]interface{}) (Event, error) {
+	fields := schema.Fields{
+		"id":          intField(),
+		"level":       stringField(),
+		"created":     stringField(),
+		"type":        stringField(),
+		"description": stringField(),
+	}
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return Event{}, WrapWithDeserializationError(err, "event 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	result := Event{
+		ID:          valid["id"].(int),
+		Level:       valid["level"].(string),
+		Created:     valid["created"].(string),
+		Type:        valid["type"].(string),
+		Description: valid["description"].(string),
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type eventSuite struct{}
+
+var _ = gc.Suite(&eventSuite{})
+
+func (*eventSuite) TestReadEventsBadSchema(c *gc.C) {
+	_, err := readEvents(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `event base schema check failed: expected map, got string("wat?")`)
+}
+
+func (*eventSuite) TestReadEvents(c *gc.C) {
+	events, err := readEvents(twoDotOh, parseJSON(c, eventsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(events, gc.HasLen, 2)
+	event := events[0]
+	c.Check(event.ID, gc.Equals, 1232)
+	c.Check(event.Level, gc.Equals, "ERROR")
+	c.Check(event.Created, gc.Equals, "Tue, 19 Apr. 2016 10:58:01")
+	c.Check(event.Type, gc.Equals, "Failed deployment")
+	c.Check(event.Description, gc.Equals, "Installation failed (refer to the installation log for more information).")
+}
+
+func (*eventSuite) TestLowVersion(c *gc.C) {
+	_, err := readEvents(version.MustParse("1.9.0"), parseJSON(c, eventsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (*eventSuite) TestHighVersion(c *gc.C) {
+	events, err := readEvents(version.MustParse("2.1.9"), parseJSON(c, eventsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(events, gc.HasLen, 2)
+}
+
+const eventsResponse = `
+{
+    "count": 2,
+    "events": [
+        {
+            "username": "admin",
+            "node": "4y3ha3",
+            "hostname": "untasted-markita",
+            "id": 1232,
+            "level": "ERROR",
+            "created": "Tue, 19 Apr. 2016 10:58:01",
+            "type": "Failed deployment",
+            "description": "Installation failed (refer to the installation log for more information)."
+        },
+        {
+            "username": "admin",
+            "node": "4y3ha3",
+            "hostname": "untasted-markita",
+            "id": 1230,
+            "level": "INFO",
+            "created": "Tue, 19 Apr. 2016 10:52:40",
+            "type": "Node installation",
+            "description": "'curtin' Installing OS"
+        }
+    ],
+    "next_uri": "/MAAS/api/2.0/events/?op=query&id=4y3ha3&after=1232",
+    "prev_uri": "/MAAS/api/2.0/events/?op=query&id=4y3ha3&before=1230"
+}
+`
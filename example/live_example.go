@@ -90,21 +90,19 @@ func ManipulateFiles(maas *gomaasapi.MAASObject) {
 	// Fetch list of files.
 	listFiles, err := files.CallGet("list", url.Values{})
 	checkError(err)
-	listFilesArray, err := listFiles.GetArray()
+	listFilesArray, err := gomaasapi.DecodeList[gomaasapi.MAASObject](listFiles)
 	checkError(err)
 	fmt.Printf("We've got %v file(s)\n", len(listFilesArray))
 
 	// Delete the file.
 	fmt.Println("Deleting the file...")
-	fileObject, err := listFilesArray[0].GetMAASObject()
-	checkError(err)
-	errDelete := fileObject.Delete()
+	errDelete := listFilesArray[0].Delete()
 	checkError(errDelete)
 
 	// Count the files.
 	listFiles, err = files.CallGet("list", url.Values{})
 	checkError(err)
-	listFilesArray, err = listFiles.GetArray()
+	listFilesArray, err = gomaasapi.DecodeList[gomaasapi.MAASObject](listFiles)
 	checkError(err)
 	fmt.Printf("We've got %v file(s)\n", len(listFilesArray))
 }
@@ -119,12 +117,10 @@ func ManipulateNodes(maas *gomaasapi.MAASObject) {
 	fmt.Println("Fetching list of nodes...")
 	listNodeObjects, err := nodeListing.CallGet("list", url.Values{})
 	checkError(err)
-	listNodes, err := listNodeObjects.GetArray()
+	listNodes, err := gomaasapi.DecodeList[gomaasapi.MAASObject](listNodeObjects)
 	checkError(err)
 	fmt.Printf("Got list of %v nodes\n", len(listNodes))
-	for index, nodeObj := range listNodes {
-		node, err := nodeObj.GetMAASObject()
-		checkError(err)
+	for index, node := range listNodes {
 		hostname, err := node.GetField("hostname")
 		checkError(err)
 		fmt.Printf("Node #%d is named '%v' (%v)\n", index, hostname, node.URL())
@@ -0,0 +1,80 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+
+	"github.com/juju/errors"
+)
+
+// referenceExpander resolves the fabric and space references that MAAS
+// returns as names, so callers can walk from a machine to the fabric of a
+// VLAN or the space of a subnet without further requests. Expansion is only
+// one level deep: the fabrics and spaces themselves are not expanded.
+type referenceExpander struct {
+	fabrics map[string]*fabric
+	spaces  map[string]*space
+}
+
+// newReferenceExpander fetches all the fabrics and spaces concurrently.
+func (c *controller) newReferenceExpander() (*referenceExpander, error) {
+	results := c.client.BatchGet([]string{"fabrics/", "spaces/"}, 2)
+	sources := make([]interface{}, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			return nil, NewUnexpectedError(result.Err)
+		}
+		if err := json.Unmarshal(result.Body, &sources[i]); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	fabrics, err := readFabrics(c.apiVersion, c.markLeaves(sources[0]))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	spaces, err := readSpaces(c.apiVersion, c.markLeaves(sources[1]))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	expander := &referenceExpander{
+		fabrics: make(map[string]*fabric),
+		spaces:  make(map[string]*space),
+	}
+	for _, f := range fabrics {
+		expander.fabrics[f.name] = f
+	}
+	for _, s := range spaces {
+		s.controller = c
+		expander.spaces[s.name] = s
+	}
+	return expander, nil
+}
+
+func (e *referenceExpander) expandInterfaces(interfaces ...*interface_) {
+	for _, iface := range interfaces {
+		if iface == nil {
+			continue
+		}
+		e.expandVLAN(iface.vlan)
+		for _, link := range iface.links {
+			e.expandSubnet(link.subnet)
+		}
+	}
+}
+
+func (e *referenceExpander) expandVLAN(v *vlan) {
+	if v == nil {
+		return
+	}
+	v.expandedFabric = e.fabrics[v.fabric]
+}
+
+func (e *referenceExpander) expandSubnet(s *subnet) {
+	if s == nil {
+		return
+	}
+	s.expandedSpace = e.spaces[s.space]
+	e.expandVLAN(s.vlan)
+}
@@ -0,0 +1,251 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Snapshot is a read-only copy of the state of a MAAS controller. The
+// entities are kept as MAAS returned them, so that nothing is lost, and
+// each collection is sorted so that two snapshots of the same state are
+// identical.
+type Snapshot struct {
+	APIVersion string `json:"api_version"`
+
+	Machines []map[string]interface{} `json:"machines"`
+	Devices  []map[string]interface{} `json:"devices"`
+	Subnets  []map[string]interface{} `json:"subnets"`
+	Fabrics  []map[string]interface{} `json:"fabrics"`
+	Tags     []map[string]interface{} `json:"tags"`
+	Zones    []map[string]interface{} `json:"zones"`
+}
+
+// snapshotCollection describes where a collection is read from and the
+// field that identifies its entities.
+type snapshotCollection struct {
+	name  string
+	key   string
+	field func(*Snapshot) *[]map[string]interface{}
+}
+
+var snapshotCollections = []snapshotCollection{
+	{"machines", "system_id", func(s *Snapshot) *[]map[string]interface{} { return &s.Machines }},
+	{"devices", "system_id", func(s *Snapshot) *[]map[string]interface{} { return &s.Devices }},
+	{"subnets", "id", func(s *Snapshot) *[]map[string]interface{} { return &s.Subnets }},
+	{"fabrics", "id", func(s *Snapshot) *[]map[string]interface{} { return &s.Fabrics }},
+	{"tags", "name", func(s *Snapshot) *[]map[string]interface{} { return &s.Tags }},
+	{"zones", "name", func(s *Snapshot) *[]map[string]interface{} { return &s.Zones }},
+}
+
+// Exporter reads the major collections of a MAAS controller into a
+// Snapshot, for backups and for comparing the state of a controller over
+// time.
+type Exporter struct {
+	controller *controller
+}
+
+// NewExporter returns an Exporter that reads from a Controller created by
+// NewController.
+func NewExporter(source Controller) (*Exporter, error) {
+	c, ok := source.(*controller)
+	if !ok {
+		return nil, errors.NotValidf("controller %T", source)
+	}
+	return &Exporter{controller: c}, nil
+}
+
+// Export reads the machines, devices, subnets, fabrics, tags and zones.
+// Nothing is changed on the controller.
+func (e *Exporter) Export() (*Snapshot, error) {
+	snapshot := &Snapshot{APIVersion: e.controller.apiVersion.String()}
+	for _, collection := range snapshotCollections {
+		entities, err := readSnapshotCollection(e.controller, collection.name)
+		if err != nil {
+			return nil, errors.Annotatef(err, "exporting %s", collection.name)
+		}
+		sortSnapshotEntities(entities, collection.key)
+		*collection.field(snapshot) = entities
+	}
+	return snapshot, nil
+}
+
+func readSnapshotCollection(c *controller, name string) ([]map[string]interface{}, error) {
+	body, err := c._getRaw(name, "", nil)
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	// Numbers are kept as json.Number so that they are written back out
+	// exactly as MAAS sent them.
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var entities []map[string]interface{}
+	if err := decoder.Decode(&entities); err != nil {
+		return nil, WrapWithDeserializationError(err, "%s", name)
+	}
+	if entities == nil {
+		entities = []map[string]interface{}{}
+	}
+	return entities, nil
+}
+
+// sortSnapshotEntities orders the entities by the key field. Numeric keys
+// are compared as numbers, and entities without the key keep their
+// relative order after the others.
+func sortSnapshotEntities(entities []map[string]interface{}, key string) {
+	sort.SliceStable(entities, func(i, j int) bool {
+		a, aOK := entities[i][key]
+		b, bOK := entities[j][key]
+		if !aOK || !bOK {
+			return aOK && !bOK
+		}
+		if an, ok := a.(json.Number); ok {
+			if bn, ok := b.(json.Number); ok {
+				af, aErr := an.Float64()
+				bf, bErr := bn.Float64()
+				if aErr == nil && bErr == nil {
+					return af < bf
+				}
+			}
+		}
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	})
+}
+
+// ReadSnapshot reads a snapshot written by Snapshot.WriteJSON.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var snapshot Snapshot
+	if err := decoder.Decode(&snapshot); err != nil {
+		return nil, WrapWithDeserializationError(err, "snapshot")
+	}
+	return &snapshot, nil
+}
+
+// WriteJSON writes the snapshot as indented JSON. The keys of each entity
+// are sorted.
+func (s *Snapshot) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return errors.Trace(encoder.Encode(s))
+}
+
+// WriteYAML writes the snapshot as a YAML document. The keys of each entity
+// are sorted and all strings are quoted, so the output does not depend on
+// how a YAML reader would interpret an unquoted value.
+func (s *Snapshot) WriteYAML(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("api_version: ")
+	writeYAMLScalar(&buf, s.APIVersion)
+	buf.WriteString("\n")
+	for _, collection := range snapshotCollections {
+		entities := *collection.field(s)
+		list := make([]interface{}, len(entities))
+		for i, entity := range entities {
+			list[i] = entity
+		}
+		buf.WriteString(collection.name + ":")
+		writeYAMLValue(&buf, list, 0)
+	}
+	_, err := w.Write(buf.Bytes())
+	return errors.Trace(err)
+}
+
+// writeYAMLValue writes the value following a "key:" or "-" that has
+// already been written, ending with a newline.
+func writeYAMLValue(buf *bytes.Buffer, value interface{}, indent int) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if len(value) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAMLMap(buf, value, indent+2, "")
+	case []interface{}:
+		if len(value) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAMLList(buf, value, indent)
+	default:
+		buf.WriteString(" ")
+		writeYAMLScalar(buf, value)
+		buf.WriteString("\n")
+	}
+}
+
+// writeYAMLMap writes the sorted keys of the map at the indent. If first
+// is not empty it is written in place of the indent before the first key,
+// for maps that start on the line of a list item.
+func writeYAMLMap(buf *bytes.Buffer, value map[string]interface{}, indent int, first string) {
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i == 0 && first != "" {
+			buf.WriteString(first)
+		} else {
+			buf.WriteString(strings.Repeat(" ", indent))
+		}
+		writeYAMLKey(buf, key)
+		buf.WriteString(":")
+		writeYAMLValue(buf, value[key], indent)
+	}
+}
+
+// writeYAMLList writes the list items at the indent.
+func writeYAMLList(buf *bytes.Buffer, value []interface{}, indent int) {
+	prefix := strings.Repeat(" ", indent) + "- "
+	for _, item := range value {
+		if m, ok := item.(map[string]interface{}); ok && len(m) > 0 {
+			writeYAMLMap(buf, m, indent+2, prefix)
+			continue
+		}
+		buf.WriteString(strings.Repeat(" ", indent) + "-")
+		writeYAMLValue(buf, item, indent+2)
+	}
+}
+
+var plainYAMLKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func writeYAMLKey(buf *bytes.Buffer, key string) {
+	switch strings.ToLower(key) {
+	case "y", "n", "yes", "no", "on", "off", "true", "false", "null":
+		writeYAMLScalar(buf, key)
+		return
+	}
+	if plainYAMLKey.MatchString(key) {
+		buf.WriteString(key)
+		return
+	}
+	writeYAMLScalar(buf, key)
+}
+
+// writeYAMLScalar writes strings as double quoted, using JSON escapes, which
+// YAML accepts.
+func writeYAMLScalar(buf *bytes.Buffer, value interface{}) {
+	switch value := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool, json.Number, float64, int:
+		fmt.Fprint(buf, value)
+	default:
+		quoted, _ := json.Marshal(fmt.Sprint(value))
+		buf.Write(quoted)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type exportSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&exportSuite{})
+
+func (s *exportSuite) getExporter(c *gc.C) (*SimpleTestServer, *Exporter) {
+	server, controller := createTestServerController(c, s)
+	exporter, err := NewExporter(controller)
+	c.Assert(err, jc.ErrorIsNil)
+	return server, exporter
+}
+
+func addExportResponses(server *SimpleTestServer) {
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, `[
+        {"system_id": "4y3ha6", "hostname": "b"},
+        {"system_id": "4y3ha3", "hostname": "a"}
+    ]`)
+	server.AddGetResponse("/api/2.0/devices/", http.StatusOK, `[]`)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, `[
+        {"id": 34, "cidr": "192.168.122.0/24"},
+        {"id": 4, "cidr": "192.168.100.0/24"}
+    ]`)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, `[{"id": 0, "name": "fabric-0"}]`)
+	server.AddGetResponse("/api/2.0/tags/", http.StatusOK, `[
+        {"name": "virtual", "definition": "", "comment": "<vm>"},
+        {"name": "gpu", "definition": "//node[@class=\"display\"]", "comment": ""}
+    ]`)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, `[
+        {"name": "special", "description": "special description"},
+        {"name": "default", "description": ""}
+    ]`)
+}
+
+func (*exportSuite) TestNewExporterOtherController(c *gc.C) {
+	var controller Controller
+	_, err := NewExporter(controller)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *exportSuite) TestExport(c *gc.C) {
+	server, exporter := s.getExporter(c)
+	addExportResponses(server)
+	snapshot, err := exporter.Export()
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(snapshot.APIVersion, gc.Equals, "2.0.0")
+	c.Assert(snapshot.Machines, gc.HasLen, 2)
+	c.Check(snapshot.Machines[0]["system_id"], gc.Equals, "4y3ha3")
+	c.Check(snapshot.Machines[1]["system_id"], gc.Equals, "4y3ha6")
+	c.Check(snapshot.Devices, gc.HasLen, 0)
+	c.Check(snapshot.Devices, gc.NotNil)
+	// Numeric identifiers are ordered by value, not as strings.
+	c.Assert(snapshot.Subnets, gc.HasLen, 2)
+	c.Check(snapshot.Subnets[0]["id"], gc.Equals, json.Number("4"))
+	c.Check(snapshot.Subnets[1]["id"], gc.Equals, json.Number("34"))
+	c.Check(snapshot.Fabrics, gc.HasLen, 1)
+	c.Assert(snapshot.Tags, gc.HasLen, 2)
+	c.Check(snapshot.Tags[0]["name"], gc.Equals, "gpu")
+	c.Assert(snapshot.Zones, gc.HasLen, 2)
+	c.Check(snapshot.Zones[0]["name"], gc.Equals, "default")
+
+	// Only GET requests are made.
+	for _, request := range server.LastNRequests(6) {
+		c.Check(request.Method, gc.Equals, "GET")
+	}
+}
+
+func (s *exportSuite) TestExportError(c *gc.C) {
+	server, exporter := s.getExporter(c)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, `[]`)
+	server.AddGetResponse("/api/2.0/devices/", http.StatusForbidden, "no")
+	_, err := exporter.Export()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, gc.ErrorMatches, "exporting devices: .*")
+}
+
+func (s *exportSuite) TestExportBadResponse(c *gc.C) {
+	server, exporter := s.getExporter(c)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, `{"not": "a list"}`)
+	_, err := exporter.Export()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, "exporting machines: machines: .*")
+}
+
+func (s *exportSuite) TestExportStable(c *gc.C) {
+	server, exporter := s.getExporter(c)
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		addExportResponses(server)
+		snapshot, err := exporter.Export()
+		c.Assert(err, jc.ErrorIsNil)
+		var buf bytes.Buffer
+		c.Assert(snapshot.WriteJSON(&buf), jc.ErrorIsNil)
+		outputs = append(outputs, buf.String())
+	}
+	c.Assert(outputs[0], gc.Equals, outputs[1])
+}
+
+func (s *exportSuite) TestWriteJSON(c *gc.C) {
+	server, exporter := s.getExporter(c)
+	addExportResponses(server)
+	snapshot, err := exporter.Export()
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	c.Assert(snapshot.WriteJSON(&buf), jc.ErrorIsNil)
+
+	var parsed map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &parsed), jc.ErrorIsNil)
+	c.Check(parsed["api_version"], gc.Equals, "2.0.0")
+	c.Check(parsed["devices"], jc.DeepEquals, []interface{}{})
+	c.Check(parsed["subnets"], jc.DeepEquals, []interface{}{
+		map[string]interface{}{"id": float64(4), "cidr": "192.168.100.0/24"},
+		map[string]interface{}{"id": float64(34), "cidr": "192.168.122.0/24"},
+	})
+	// HTML characters are not escaped.
+	c.Check(buf.String(), jc.Contains, `"comment": "<vm>"`)
+}
+
+func (*exportSuite) TestWriteYAML(c *gc.C) {
+	snapshot := &Snapshot{
+		APIVersion: "2.0.0",
+		Machines: []map[string]interface{}{{
+			"system_id":    "4y3ha3",
+			"ip_addresses": []interface{}{"192.168.100.4"},
+			"zone":         map[string]interface{}{"name": "default"},
+			"owner_data":   map[string]interface{}{},
+			"tags":         []interface{}{},
+			"cpu_count":    json.Number("1"),
+			"netboot":      true,
+			"owner":        nil,
+		}},
+		Subnets: []map[string]interface{}{{
+			"id":  json.Number("1"),
+			"on":  "yes",
+			"a:b": []interface{}{[]interface{}{"x"}, map[string]interface{}{}},
+		}},
+		Zones: []map[string]interface{}{},
+	}
+	var buf bytes.Buffer
+	c.Assert(snapshot.WriteYAML(&buf), jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, `api_version: "2.0.0"
+machines:
+- cpu_count: 1
+  ip_addresses:
+  - "192.168.100.4"
+  netboot: true
+  owner: null
+  owner_data: {}
+  system_id: "4y3ha3"
+  tags: []
+  zone:
+    name: "default"
+devices: []
+subnets:
+- "a:b":
+  -
+    - "x"
+  - {}
+  id: 1
+  "on": "yes"
+fabrics: []
+tags: []
+zones: []
+`)
+}
@@ -10,6 +10,8 @@ import (
 )
 
 type fabric struct {
+	rawJSON
+
 	// Add the controller in when we need to do things with the fabric.
 	// controller Controller
 
@@ -92,10 +94,10 @@ var fabricDeserializationFuncs = map[version.Number]fabricDeserializationFunc{
 
 func fabric_2_0(source map[string]interface{}) (*fabric, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"name":         schema.String(),
-		"class_type":   schema.OneOf(schema.Nil(""), schema.String()),
+		"resource_uri": stringField(),
+		"id":           intField(),
+		"name":         stringField(),
+		"class_type":   nullable(stringField()),
 		"vlans":        schema.List(schema.StringMap(schema.Any())),
 	}
 	checker := schema.FieldMap(fields, nil) // no defaults
@@ -118,6 +120,7 @@ func fabric_2_0(source map[string]interface{}) (*fabric, error) {
 	classType, _ := valid["class_type"].(string)
 
 	result := &fabric{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 		id:          valid["id"].(int),
 		name:        valid["name"].(string),
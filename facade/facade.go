@@ -0,0 +1,305 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+/*
+Package facade serves a minimal REST API in front of a MAAS controller,
+for consumers in other languages that need only a slice of MAAS and would
+rather not implement OAuth signing and the MAAS API themselves. It also
+shows how the typed operations of gomaasapi fit together in a service:
+
+	controller, err := gomaasapi.NewController(gomaasapi.ControllerArgs{
+		BaseURL: "http://maas/MAAS/",
+		APIKey:  apiKey,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return http.ListenAndServe("localhost:8080", facade.NewServer(controller))
+
+The requests and responses are JSON:
+
+	GET  /machines?hostname=a,b&zone=z&tags=t1,t2  the machines that match
+	GET  /machines/{system-id}                     one machine
+	POST /machines/allocate                        allocate a machine
+	POST /machines/{system-id}/deploy              deploy a machine
+	POST /machines/{system-id}/release             release a machine
+	GET  /zones                                    the zones
+
+The facade holds the MAAS credentials, so anyone who can reach it acts as
+the MAAS user. Serve it behind whatever authentication the deployment
+uses. The comment given to allocate, deploy or release is recorded in the
+event log of the machine, so say in it who asked for the operation.
+
+Errors are returned as {"error": "message"} with a status that follows the
+error type: 404 for a NoMatchError, 400 for bad arguments, 403 for a
+PermissionError, 409 for a CannotCompleteError or a machine whose power
+state is unknown, and 502 for anything else.
+*/
+package facade
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+)
+
+// maxBodySize limits the size of request bodies.
+const maxBodySize = 64 * 1024
+
+// Server is an http.Handler serving the REST API for a MAAS controller.
+type Server struct {
+	controller gomaasapi.Controller
+}
+
+// NewServer returns a Server for the controller.
+func NewServer(controller gomaasapi.Controller) *Server {
+	return &Server{controller: controller}
+}
+
+// Machine is the JSON form of a machine.
+type Machine struct {
+	SystemID     string   `json:"system_id"`
+	Hostname     string   `json:"hostname"`
+	Status       string   `json:"status"`
+	PowerState   string   `json:"power_state"`
+	Architecture string   `json:"architecture"`
+	Zone         string   `json:"zone,omitempty"`
+	Tags         []string `json:"tags"`
+	IPAddresses  []string `json:"ip_addresses"`
+}
+
+// Zone is the JSON form of a zone.
+type Zone struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// AllocateRequest is the body of POST /machines/allocate.
+type AllocateRequest struct {
+	Hostname     string   `json:"hostname"`
+	Architecture string   `json:"architecture"`
+	Zone         string   `json:"zone"`
+	Tags         []string `json:"tags"`
+	Comment      string   `json:"comment"`
+}
+
+// DeployRequest is the body of POST /machines/{system-id}/deploy. Force
+// deploys a machine whose power state is unknown.
+type DeployRequest struct {
+	Series  string `json:"series"`
+	Kernel  string `json:"kernel"`
+	Comment string `json:"comment"`
+	Force   bool   `json:"force"`
+}
+
+// ReleaseRequest is the body of POST /machines/{system-id}/release.
+type ReleaseRequest struct {
+	Comment string `json:"comment"`
+}
+
+func machineView(m gomaasapi.Machine) Machine {
+	view := Machine{
+		SystemID:     m.SystemID(),
+		Hostname:     m.Hostname(),
+		Status:       m.StatusName(),
+		PowerState:   m.PowerState(),
+		Architecture: m.Architecture(),
+		Tags:         m.Tags(),
+		IPAddresses:  m.IPAddresses(),
+	}
+	if zone := m.Zone(); zone != nil {
+		view.Zone = zone.Name()
+	}
+	return view
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "machines":
+		s.handle(w, r, "GET", s.listMachines)
+	case len(parts) == 1 && parts[0] == "zones":
+		s.handle(w, r, "GET", s.listZones)
+	case len(parts) == 2 && parts[0] == "machines" && parts[1] == "allocate":
+		s.handle(w, r, "POST", s.allocate)
+	case len(parts) == 2 && parts[0] == "machines":
+		s.handle(w, r, "GET", func(r *http.Request) (interface{}, error) {
+			return s.getMachine(parts[1])
+		})
+	case len(parts) == 3 && parts[0] == "machines" && parts[2] == "deploy":
+		s.handle(w, r, "POST", func(r *http.Request) (interface{}, error) {
+			return s.deploy(parts[1], r)
+		})
+	case len(parts) == 3 && parts[0] == "machines" && parts[2] == "release":
+		s.handle(w, r, "POST", func(r *http.Request) (interface{}, error) {
+			return s.release(parts[1], r)
+		})
+	default:
+		writeError(w, http.StatusNotFound, errors.Errorf("no such resource %s", r.URL.Path))
+	}
+}
+
+// handle calls the operation if the request has the method, and writes
+// its result or error. A nil result is written as 204 No Content.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request, method string, op func(*http.Request) (interface{}, error)) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	result, err := op(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) listMachines(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	result, err := s.controller.SearchMachines(gomaasapi.SearchMachinesArgs{
+		Hostnames: splitList(query.Get("hostname")),
+		Zone:      query.Get("zone"),
+		Tags:      splitList(query.Get("tags")),
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	views := make([]Machine, len(result.Machines))
+	for i, m := range result.Machines {
+		views[i] = machineView(m)
+	}
+	return views, nil
+}
+
+func (s *Server) getMachine(systemID string) (interface{}, error) {
+	machine, err := s.controller.GetMachine(systemID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return machineView(machine), nil
+}
+
+func (s *Server) allocate(r *http.Request) (interface{}, error) {
+	var request AllocateRequest
+	if err := readJSON(r, &request); err != nil {
+		return nil, errors.Trace(err)
+	}
+	machine, _, err := s.controller.AllocateMachine(gomaasapi.AllocateMachineArgs{
+		Hostname:     request.Hostname,
+		Architecture: request.Architecture,
+		Zone:         request.Zone,
+		Tags:         request.Tags,
+		Comment:      request.Comment,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return machineView(machine), nil
+}
+
+func (s *Server) deploy(systemID string, r *http.Request) (interface{}, error) {
+	var request DeployRequest
+	if err := readJSON(r, &request); err != nil {
+		return nil, errors.Trace(err)
+	}
+	machine, err := s.controller.GetMachine(systemID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := gomaasapi.CheckPowerKnown(machine, request.Force); err != nil {
+		return nil, errors.Trace(err)
+	}
+	err = machine.Start(gomaasapi.StartArgs{
+		DistroSeries: request.Series,
+		Kernel:       request.Kernel,
+		Comment:      request.Comment,
+		CheckImage:   true,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return machineView(machine), nil
+}
+
+func (s *Server) release(systemID string, r *http.Request) (interface{}, error) {
+	var request ReleaseRequest
+	if err := readJSON(r, &request); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err := gomaasapi.ParseSystemID(systemID); err != nil {
+		return nil, errors.Trace(err)
+	}
+	err := s.controller.ReleaseMachines(gomaasapi.ReleaseMachinesArgs{
+		SystemIDs: []string{systemID},
+		Comment:   request.Comment,
+	})
+	return nil, errors.Trace(err)
+}
+
+func (s *Server) listZones(r *http.Request) (interface{}, error) {
+	zones, err := s.controller.Zones()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	views := make([]Zone, len(zones))
+	for i, zone := range zones {
+		views[i] = Zone{Name: zone.Name(), Description: zone.Description()}
+	}
+	return views, nil
+}
+
+// readJSON decodes the body of the request into v. An empty body leaves v
+// unchanged.
+func readJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	if err := decoder.Decode(v); err != nil && err != io.EOF {
+		return gomaasapi.NewBadRequestError("cannot decode request: " + err.Error())
+	}
+	return nil
+}
+
+// errorStatus returns the HTTP status for an error of the typed API.
+func errorStatus(err error) int {
+	switch {
+	case gomaasapi.IsNoMatchError(err), errors.IsNotFound(err):
+		return http.StatusNotFound
+	case gomaasapi.IsBadRequestError(err), gomaasapi.IsArgumentError(err), errors.IsNotValid(err):
+		return http.StatusBadRequest
+	case gomaasapi.IsPermissionError(err):
+		return http.StatusForbidden
+	case gomaasapi.IsCannotCompleteError(err), gomaasapi.IsPowerStateUnknownError(err):
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// splitList splits a comma separated list, ignoring empty items.
+func splitList(list string) []string {
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
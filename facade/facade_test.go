@@ -0,0 +1,190 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package facade
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	stdtesting "testing"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func Test(t *stdtesting.T) {
+	gc.TestingT(t)
+}
+
+// fakeController implements the Controller methods that the facade
+// calls. The others panic, as the embedded interface is nil.
+type fakeController struct {
+	gomaasapi.Controller
+	machines []*fakeMachine
+	search   gomaasapi.SearchMachinesArgs
+	released gomaasapi.ReleaseMachinesArgs
+}
+
+func (f *fakeController) SearchMachines(args gomaasapi.SearchMachinesArgs) (gomaasapi.SearchMachinesResult, error) {
+	f.search = args
+	var result gomaasapi.SearchMachinesResult
+	for _, m := range f.machines {
+		result.Machines = append(result.Machines, m)
+	}
+	return result, nil
+}
+
+func (f *fakeController) GetMachine(systemID string) (gomaasapi.Machine, error) {
+	for _, m := range f.machines {
+		if m.systemID == systemID {
+			return m, nil
+		}
+	}
+	return nil, gomaasapi.NewNoMatchError("no machine " + systemID)
+}
+
+func (f *fakeController) AllocateMachine(args gomaasapi.AllocateMachineArgs) (gomaasapi.Machine, gomaasapi.ConstraintMatches, error) {
+	if len(args.Tags) == 0 {
+		return nil, gomaasapi.ConstraintMatches{}, gomaasapi.NewNoMatchError("no machine matches")
+	}
+	return f.machines[0], gomaasapi.ConstraintMatches{}, nil
+}
+
+func (f *fakeController) ReleaseMachines(args gomaasapi.ReleaseMachinesArgs) error {
+	f.released = args
+	return nil
+}
+
+func (f *fakeController) Zones() ([]gomaasapi.Zone, error) {
+	return nil, errors.New("boom")
+}
+
+type fakeMachine struct {
+	gomaasapi.Machine
+	systemID   string
+	powerState string
+	started    *gomaasapi.StartArgs
+}
+
+func (m *fakeMachine) SystemID() string      { return m.systemID }
+func (m *fakeMachine) Hostname() string      { return m.systemID + ".maas" }
+func (m *fakeMachine) StatusName() string    { return "Ready" }
+func (m *fakeMachine) PowerState() string    { return m.powerState }
+func (m *fakeMachine) Architecture() string  { return "amd64/generic" }
+func (m *fakeMachine) Tags() []string        { return []string{"virtual"} }
+func (m *fakeMachine) IPAddresses() []string { return nil }
+func (m *fakeMachine) Zone() gomaasapi.Zone  { return nil }
+func (m *fakeMachine) Start(args gomaasapi.StartArgs) error {
+	m.started = &args
+	return nil
+}
+
+type facadeSuite struct {
+	controller *fakeController
+	server     *httptest.Server
+}
+
+var _ = gc.Suite(&facadeSuite{})
+
+func (s *facadeSuite) SetUpTest(c *gc.C) {
+	s.controller = &fakeController{machines: []*fakeMachine{
+		{systemID: "4y3ha3", powerState: "off"},
+		{systemID: "4y3ha4", powerState: "unknown"},
+	}}
+	s.server = httptest.NewServer(NewServer(s.controller))
+}
+
+func (s *facadeSuite) TearDownTest(c *gc.C) {
+	s.server.Close()
+}
+
+// call sends the request and decodes the JSON response into result, if
+// it is not nil, returning the status.
+func (s *facadeSuite) call(c *gc.C, method, path, body string, result interface{}) int {
+	request, err := http.NewRequest(method, s.server.URL+path, strings.NewReader(body))
+	c.Assert(err, jc.ErrorIsNil)
+	response, err := http.DefaultClient.Do(request)
+	c.Assert(err, jc.ErrorIsNil)
+	defer response.Body.Close()
+	if result != nil {
+		c.Assert(json.NewDecoder(response.Body).Decode(result), jc.ErrorIsNil)
+	}
+	return response.StatusCode
+}
+
+func (s *facadeSuite) TestListMachines(c *gc.C) {
+	var machines []Machine
+	status := s.call(c, "GET", "/machines?zone=default&tags=virtual,,magic", "", &machines)
+	c.Assert(status, gc.Equals, http.StatusOK)
+	c.Check(machines, gc.HasLen, 2)
+	c.Check(machines[0], jc.DeepEquals, Machine{
+		SystemID:     "4y3ha3",
+		Hostname:     "4y3ha3.maas",
+		Status:       "Ready",
+		PowerState:   "off",
+		Architecture: "amd64/generic",
+		Tags:         []string{"virtual"},
+	})
+	c.Check(s.controller.search, jc.DeepEquals, gomaasapi.SearchMachinesArgs{
+		Zone: "default",
+		Tags: []string{"virtual", "magic"},
+	})
+}
+
+func (s *facadeSuite) TestGetMachine(c *gc.C) {
+	var machine Machine
+	c.Assert(s.call(c, "GET", "/machines/4y3ha4", "", &machine), gc.Equals, http.StatusOK)
+	c.Check(machine.PowerState, gc.Equals, "unknown")
+
+	var failure map[string]string
+	c.Check(s.call(c, "GET", "/machines/nope", "", &failure), gc.Equals, http.StatusNotFound)
+	c.Check(failure["error"], gc.Equals, "no machine nope")
+}
+
+func (s *facadeSuite) TestAllocate(c *gc.C) {
+	var machine Machine
+	status := s.call(c, "POST", "/machines/allocate", `{"tags": ["virtual"]}`, &machine)
+	c.Assert(status, gc.Equals, http.StatusOK)
+	c.Check(machine.SystemID, gc.Equals, "4y3ha3")
+
+	c.Check(s.call(c, "POST", "/machines/allocate", `{"tags": `, nil), gc.Equals, http.StatusBadRequest)
+	c.Check(s.call(c, "GET", "/machines/allocate", "", nil), gc.Equals, http.StatusMethodNotAllowed)
+}
+
+func (s *facadeSuite) TestDeploy(c *gc.C) {
+	status := s.call(c, "POST", "/machines/4y3ha3/deploy", `{"series": "xenial", "comment": "for alice"}`, nil)
+	c.Assert(status, gc.Equals, http.StatusOK)
+	c.Check(s.controller.machines[0].started, jc.DeepEquals, &gomaasapi.StartArgs{
+		DistroSeries: "xenial",
+		Comment:      "for alice",
+		CheckImage:   true,
+	})
+
+	// The power state of 4y3ha4 is unknown.
+	var failure map[string]string
+	c.Check(s.call(c, "POST", "/machines/4y3ha4/deploy", "", &failure), gc.Equals, http.StatusConflict)
+	c.Check(failure["error"], gc.Matches, "machine 4y3ha4: power state is unknown, .*")
+	c.Check(s.controller.machines[1].started, gc.IsNil)
+	c.Check(s.call(c, "POST", "/machines/4y3ha4/deploy", `{"force": true}`, nil), gc.Equals, http.StatusOK)
+}
+
+func (s *facadeSuite) TestRelease(c *gc.C) {
+	status := s.call(c, "POST", "/machines/4y3ha3/release", `{"comment": "done"}`, nil)
+	c.Assert(status, gc.Equals, http.StatusNoContent)
+	c.Check(s.controller.released, jc.DeepEquals, gomaasapi.ReleaseMachinesArgs{
+		SystemIDs: []string{"4y3ha3"},
+		Comment:   "done",
+	})
+	c.Check(s.call(c, "POST", "/machines/NOT-AN-ID/release", "", nil), gc.Equals, http.StatusBadRequest)
+}
+
+func (s *facadeSuite) TestErrors(c *gc.C) {
+	var failure map[string]string
+	c.Check(s.call(c, "GET", "/zones", "", &failure), gc.Equals, http.StatusBadGateway)
+	c.Check(failure["error"], gc.Equals, "boom")
+	c.Check(s.call(c, "GET", "/devices", "", nil), gc.Equals, http.StatusNotFound)
+}
@@ -14,6 +14,8 @@ import (
 )
 
 type file struct {
+	rawJSON
+
 	controller *controller
 
 	resourceURI  string
@@ -149,10 +151,10 @@ var fileDeserializationFuncs = map[version.Number]fileDeserializationFunc{
 
 func file_2_0(source map[string]interface{}) (*file, error) {
 	fields := schema.Fields{
-		"resource_uri":      schema.String(),
-		"filename":          schema.String(),
-		"anon_resource_uri": schema.String(),
-		"content":           schema.String(),
+		"resource_uri":      stringField(),
+		"filename":          stringField(),
+		"anon_resource_uri": stringField(),
+		"content":           stringField(),
 	}
 	defaults := schema.Defaults{
 		"content": "",
@@ -172,6 +174,7 @@ func file_2_0(source map[string]interface{}) (*file, error) {
 	}
 
 	result := &file{
+		rawJSON:      rawJSON{source},
 		resourceURI:  valid["resource_uri"].(string),
 		filename:     valid["filename"].(string),
 		anonymousURI: anonURI,
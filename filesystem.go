@@ -6,6 +6,8 @@ package gomaasapi
 import "github.com/juju/schema"
 
 type filesystem struct {
+	rawJSON
+
 	fstype     string
 	mountPoint string
 	label      string
@@ -38,10 +40,10 @@ func (f *filesystem) UUID() string {
 
 func filesystem2_0(source map[string]interface{}) (*filesystem, error) {
 	fields := schema.Fields{
-		"fstype":      schema.String(),
-		"mount_point": schema.OneOf(schema.Nil(""), schema.String()),
-		"label":       schema.OneOf(schema.Nil(""), schema.String()),
-		"uuid":        schema.String(),
+		"fstype":      stringField(),
+		"mount_point": nullable(stringField()),
+		"label":       nullable(stringField()),
+		"uuid":        stringField(),
 		// TODO: mount_options when we know the type (note it can be
 		// nil).
 	}
@@ -60,6 +62,7 @@ func filesystem2_0(source map[string]interface{}) (*filesystem, error) {
 	mount_point, _ := valid["mount_point"].(string)
 	label, _ := valid["label"].(string)
 	result := &filesystem{
+		rawJSON:    rawJSON{source},
 		fstype:     valid["fstype"].(string),
 		mountPoint: mount_point,
 		label:      label,
@@ -0,0 +1,104 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// maxPooledForm is the capacity above which a form buffer is left to the
+// garbage collector rather than pooled, so that one large upload does not
+// keep its buffer alive.
+const maxPooledForm = 64 << 10
+
+// formBuffers holds the buffers that form bodies are encoded into, as
+// *[]byte.
+var formBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+// pooledForm is a form body encoded into a buffer from formBuffers. Each
+// attempt to send the request reads the body with a reader of its own,
+// and the buffer goes back to the pool once the sender and every reader
+// are done with it. A reader that is never closed only means the buffer
+// is not reused.
+type pooledForm struct {
+	mu   sync.Mutex
+	buf  *[]byte
+	refs int
+}
+
+// newPooledForm encodes the body into a pooled buffer, held by the
+// caller until it calls release.
+func newPooledForm(body interface{}) (*pooledForm, error) {
+	buf := formBuffers.Get().(*[]byte)
+	content, err := appendForm((*buf)[:0], body)
+	if err != nil {
+		formBuffers.Put(buf)
+		return nil, errors.Trace(err)
+	}
+	*buf = content
+	return &pooledForm{buf: buf, refs: 1}, nil
+}
+
+// reader returns a new reader of the body, which holds the buffer until it
+// is closed.
+func (f *pooledForm) reader() *pooledFormReader {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refs++
+	return &pooledFormReader{Reader: bytes.NewReader(*f.buf), form: f}
+}
+
+// release gives up a hold on the buffer.
+func (f *pooledForm) release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refs--
+	if f.refs == 0 && cap(*f.buf) <= maxPooledForm {
+		formBuffers.Put(f.buf)
+	}
+}
+
+type pooledFormReader struct {
+	*bytes.Reader
+	form *pooledForm
+	once sync.Once
+}
+
+// Close implements io.Closer.
+func (r *pooledFormReader) Close() error {
+	r.once.Do(r.form.release)
+	return nil
+}
+
+// sendForm sends the body form encoded as Send does with FormSerializer,
+// encoding it into a pooled buffer rather than a new string, for callers
+// that make many small updates, such as of tags and owner data.
+func (client Client) sendForm(method string, uri *url.URL, body interface{}) ([]byte, error) {
+	form, err := newPooledForm(body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer form.release()
+	request, err := http.NewRequest(method, client.GetURL(uri).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.ContentLength = int64(len(*form.buf))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return form.reader(), nil
+	}
+	request.Header.Set("Content-Type", formContentType)
+	return client.dispatchRequest(request)
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type formBodySuite struct{}
+
+var _ = gc.Suite(&formBodySuite{})
+
+func (*formBodySuite) TestPooledFormReleasedWhenReadersClosed(c *gc.C) {
+	form, err := newPooledForm(url.Values{"b": {"2"}, "a": {"1 2"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(*form.buf), gc.Equals, "a=1%202&b=2")
+
+	first, second := form.reader(), form.reader()
+	content, err := ioutil.ReadAll(first)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "a=1%202&b=2")
+	first.Close()
+	first.Close()
+	form.release()
+	c.Check(form.refs, gc.Equals, 1)
+	second.Close()
+	c.Check(form.refs, gc.Equals, 0)
+}
+
+func (*formBodySuite) TestPooledFormInvalidBody(c *gc.C) {
+	_, err := newPooledForm(42)
+	c.Check(err, gc.ErrorMatches, "form body of type int not valid")
+}
+
+func (*formBodySuite) TestSendFormSetsContentLengthAndRetries(c *gc.C) {
+	var lengths []int64
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lengths = append(lengths, r.ContentLength)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Header().Set(RetryAfterHeaderName, "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`"ok"`))
+	}))
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "fake:as:key", "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	params := NewOrderedParams()
+	params.Add("owner_data.rack", "r1")
+	params.Add("owner_data.owner", "team a")
+	_, err = client.Send("POST", &url.URL{Path: "machines/4y3ha3/", RawQuery: "op=set_owner_data"}, params, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	const expected = "owner_data.rack=r1&owner_data.owner=team%20a"
+	c.Check(bodies, jc.DeepEquals, []string{expected, expected})
+	c.Check(lengths, jc.DeepEquals, []int64{int64(len(expected)), int64(len(expected))})
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/version"
+)
+
+// SetHostname implements Machine.
+func (m *machine) SetHostname(name, domain string) error {
+	if err := validateHostname(name, domain); err != nil {
+		return errors.Trace(err)
+	}
+	params := hostnameParams(m.controller.apiVersion, name, domain)
+	result, err := m.controller.put(m.resourceURI, params)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	machine, err := readMachine(m.controller.apiVersion, m.controller.markLeaves(result))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// validateHostname checks that the name is a single DNS label and that the
+// domain, if given, is a valid domain name. A name with dots is refused
+// rather than split, as the domain must then be given separately.
+func validateHostname(name, domain string) error {
+	if strings.Contains(name, ".") {
+		return NewArgumentError("name", "%q contains a dot, pass the domain separately", name)
+	}
+	if !isValidHostname(name) {
+		return NewArgumentError("name", "%q is not a valid DNS label", name)
+	}
+	if domain == "" {
+		return nil
+	}
+	if !isValidHostname(domain) {
+		return NewArgumentError("domain", "%q is not a valid domain name", domain)
+	}
+	if fqdn := name + "." + strings.TrimSuffix(domain, "."); len(fqdn) > 253 {
+		return NewArgumentError("domain", "%q makes the FQDN longer than 253 characters", domain)
+	}
+	return nil
+}
+
+// hostnameParams returns the parameters that rename a machine. The 1.0 API
+// takes the FQDN as the hostname, where 2.0 takes the domain as a separate
+// parameter. An empty domain leaves the machine in its current domain for
+// 2.0, and for 1.0 sets the hostname without a domain.
+func hostnameParams(apiVersion version.Number, name, domain string) url.Values {
+	domain = strings.TrimSuffix(domain, ".")
+	params := make(url.Values)
+	if apiVersion.Major < 2 {
+		if domain != "" {
+			name += "." + domain
+		}
+		params.Set("hostname", name)
+		return params
+	}
+	params.Set("hostname", name)
+	if domain != "" {
+		params.Set("domain", domain)
+	}
+	return params
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type hostnameSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&hostnameSuite{})
+
+func (s *hostnameSuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, *machine) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, machines[0].(*machine)
+}
+
+func (*hostnameSuite) TestValidateHostname(c *gc.C) {
+	for _, test := range []struct {
+		name, domain string
+		message      string
+	}{
+		{name: "node-1"},
+		{name: "node-1", domain: "maas"},
+		{name: "Node1", domain: "example.com."},
+		{name: "", message: `name: "" is not a valid DNS label`},
+		{name: "-node", message: `name: "-node" is not a valid DNS label`},
+		{name: "node_1", message: `name: "node_1" is not a valid DNS label`},
+		{name: strings.Repeat("a", 64), message: `name: "a+" is not a valid DNS label`},
+		{name: "node.maas", message: `name: "node.maas" contains a dot, pass the domain separately`},
+		{name: "node", domain: "bad..domain", message: `domain: "bad..domain" is not a valid domain name`},
+		{name: strings.Repeat("a", 63), domain: strings.Repeat(strings.Repeat("b", 62)+".", 3) + "c", message: `domain: .* makes the FQDN longer than 253 characters`},
+	} {
+		err := validateHostname(test.name, test.domain)
+		if test.message == "" {
+			c.Check(err, jc.ErrorIsNil)
+			continue
+		}
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (*hostnameSuite) TestHostnameParams(c *gc.C) {
+	oneDotNine := version.MustParse("1.9.0")
+	c.Check(hostnameParams(twoDotOh, "node", "example.com"), jc.DeepEquals, url.Values{
+		"hostname": {"node"},
+		"domain":   {"example.com"},
+	})
+	c.Check(hostnameParams(twoDotOh, "node", ""), jc.DeepEquals, url.Values{"hostname": {"node"}})
+	c.Check(hostnameParams(oneDotNine, "node", "example.com."), jc.DeepEquals, url.Values{"hostname": {"node.example.com"}})
+	c.Check(hostnameParams(oneDotNine, "node", ""), jc.DeepEquals, url.Values{"hostname": {"node"}})
+}
+
+func (s *hostnameSuite) TestSetHostname(c *gc.C) {
+	server, m := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"hostname": "renamed",
+		"fqdn":     "renamed.example.com",
+	})
+	server.AddPutResponse(m.resourceURI, http.StatusOK, response)
+
+	err := m.SetHostname("renamed", "example.com")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(m.Hostname(), gc.Equals, "renamed")
+	c.Check(m.FQDN(), gc.Equals, "renamed.example.com")
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("hostname"), gc.Equals, "renamed")
+	c.Check(form.Get("domain"), gc.Equals, "example.com")
+}
+
+func (s *hostnameSuite) TestSetHostnameInvalid(c *gc.C) {
+	server, m := s.getServerAndMachine(c)
+	count := server.RequestCount()
+	err := m.SetHostname("renamed.example.com", "")
+	c.Assert(err, jc.Satisfies, IsArgumentError)
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *hostnameSuite) TestSetHostnameTaken(c *gc.C) {
+	server, m := s.getServerAndMachine(c)
+	server.AddPutResponse(m.resourceURI, http.StatusBadRequest, `{"hostname": ["Node with this Hostname already exists."]}`)
+	err := m.SetHostname("taken", "")
+	c.Assert(err, jc.Satisfies, IsValidationError)
+	c.Check(err.Error(), gc.Equals, "hostname: Node with this Hostname already exists.")
+}
+
+func (s *hostnameSuite) TestSetHostnameForbidden(c *gc.C) {
+	server, m := s.getServerAndMachine(c)
+	server.AddPutResponse(m.resourceURI, http.StatusForbidden, "not yours")
+	err := m.SetHostname("mine", "")
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// HWEKernel implements Machine.
+func (m *machine) HWEKernel() string {
+	return m.hweKernel
+}
+
+// MinHWEKernel implements Machine.
+func (m *machine) MinHWEKernel() string {
+	return m.minHWEKernel
+}
+
+// SetMinHWEKernel implements Machine.
+func (m *machine) SetMinHWEKernel(kernel string) error {
+	if strings.IndexFunc(kernel, unicode.IsSpace) >= 0 {
+		return NewArgumentError("kernel", "%q contains white space", kernel)
+	}
+	params := NewURLParams()
+	params.Values.Set("min_hwe_kernel", kernel)
+	result, err := m.controller.put(m.resourceURI, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	machine, err := readMachine(m.controller.apiVersion, m.controller.markLeaves(result))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// isHWEKernel returns whether the subarchitecture names a kernel that
+// can be used as a machine's HWE kernel, such as "ga-16.04", "hwe-16.04"
+// or "hwe-x", rather than a platform such as "generic".
+func isHWEKernel(subarch string) bool {
+	return strings.HasPrefix(subarch, "hwe-") || strings.HasPrefix(subarch, "ga-")
+}
+
+// HWEKernels returns the kernels, sorted, that the boot resources for the
+// series and architecture provide, for use as the Kernel of StartArgs or
+// with SetMinHWEKernel. The series may be given with or without the
+// operating system, as "xenial" or "ubuntu/xenial", and the architecture
+// is normalized as by NormalizeArchitecture, so "x86_64" finds the amd64
+// kernels. Any subarchitecture of arch is ignored.
+func HWEKernels(resources []BootResource, series, arch string) []string {
+	normalized, err := NormalizeArchitecture(arch)
+	if err != nil {
+		return nil
+	}
+	if i := strings.Index(normalized, "/"); i >= 0 {
+		normalized = normalized[:i]
+	}
+	kernels := set.NewStrings()
+	for _, resource := range resources {
+		name := resource.Name()
+		if name != series && !strings.HasSuffix(name, "/"+series) {
+			continue
+		}
+		resourceArch := strings.SplitN(resource.Architecture(), "/", 2)
+		if resourceArch[0] != normalized {
+			continue
+		}
+		subarches := resource.SubArchitectures()
+		if len(resourceArch) == 2 {
+			subarches.Add(resourceArch[1])
+		}
+		for _, subarch := range subarches.Values() {
+			if isHWEKernel(subarch) {
+				kernels.Add(subarch)
+			}
+		}
+	}
+	return kernels.SortedValues()
+}
+
+// HWEKernels implements Controller.
+func (c *controller) HWEKernels(series, arch string) ([]string, error) {
+	resources, err := c.BootResources()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return HWEKernels(resources, series, arch), nil
+}
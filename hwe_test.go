@@ -0,0 +1,89 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *machineSuite) TestHWEKernel(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	c.Check(machine.HWEKernel(), gc.Equals, "hwe-t")
+	c.Check(machine.MinHWEKernel(), gc.Equals, "")
+}
+
+func (s *machineSuite) TestSetMinHWEKernel(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"min_hwe_kernel": "hwe-16.04",
+	})
+	server.AddPutResponse(machine.resourceURI, http.StatusOK, response)
+
+	err := machine.SetMinHWEKernel("hwe-16.04")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.MinHWEKernel(), gc.Equals, "hwe-16.04")
+	form := server.LastRequest().PostForm
+	c.Check(form["min_hwe_kernel"], gc.DeepEquals, []string{"hwe-16.04"})
+}
+
+func (s *machineSuite) TestSetMinHWEKernelClears(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPutResponse(machine.resourceURI, http.StatusOK, machineResponse)
+
+	err := machine.SetMinHWEKernel("")
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form["min_hwe_kernel"], gc.DeepEquals, []string{""})
+}
+
+func (s *machineSuite) TestSetMinHWEKernelInvalid(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	err := machine.SetMinHWEKernel("hwe 16.04")
+	c.Check(err, jc.Satisfies, IsArgumentError)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestSetMinHWEKernelForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPutResponse(machine.resourceURI, http.StatusForbidden, "not yours")
+	err := machine.SetMinHWEKernel("hwe-16.04")
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (*bootResourceSuite) TestHWEKernels(c *gc.C) {
+	read, err := readBootResources(twoDotOh, parseJSON(c, bootResourcesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	var resources []BootResource
+	for _, resource := range read {
+		resources = append(resources, resource)
+	}
+	resources = append(resources, &bootResource{
+		name:         "ubuntu/xenial",
+		architecture: "arm64/generic",
+		subArches:    "generic,ga-16.04,hwe-16.04",
+	})
+	for i, test := range []struct {
+		series, arch string
+		expected     []string
+	}{
+		{"trusty", "amd64", []string{"hwe-p", "hwe-q", "hwe-r", "hwe-s", "hwe-t", "hwe-u", "hwe-v", "hwe-w"}},
+		{"ubuntu/xenial", "x86_64/generic", []string{"hwe-p", "hwe-q", "hwe-r", "hwe-s", "hwe-t", "hwe-u", "hwe-v", "hwe-w", "hwe-x"}},
+		{"xenial", "aarch64", []string{"ga-16.04", "hwe-16.04"}},
+		{"bionic", "amd64", []string{}},
+		{"trusty", "amd 64", nil},
+	} {
+		c.Logf("test %d: %s %s", i, test.series, test.arch)
+		c.Check(HWEKernels(resources, test.series, test.arch), jc.DeepEquals, test.expected)
+	}
+}
+
+func (s *controllerSuite) TestHWEKernels(c *gc.C) {
+	controller := s.getController(c)
+	kernels, err := controller.HWEKernels("xenial", "amd64")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(kernels, gc.HasLen, 9)
+}
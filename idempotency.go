@@ -0,0 +1,40 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+)
+
+// IdempotencyKeyOwnerData is the owner data key under which AllocateMachine
+// also records AllocateMachineArgs.IdempotencyKey, for people looking at the
+// machine. Allocations are matched by agent name, not by this record.
+const IdempotencyKeyOwnerData = "gomaasapi-idempotency-key"
+
+// findIdempotentAllocation returns the machine already allocated with the
+// same idempotency key as its agent name, or nil if there is none. The key
+// is sent with the allocate request itself, so a machine allocated by a
+// request whose response was lost is found. MAAS clears the agent name
+// when a machine is released, so only current allocations are found.
+func (c *controller) findIdempotentAllocation(args AllocateMachineArgs) (Machine, error) {
+	machines, err := c.Machines(MachinesArgs{AgentName: args.IdempotencyKey})
+	if err != nil {
+		return nil, errors.Annotate(err, "checking for existing allocation")
+	}
+	if len(machines) == 0 {
+		return nil, nil
+	}
+	if len(machines) > 1 {
+		logger.Warningf("%d machines allocated with idempotency key %q, using %s",
+			len(machines), args.IdempotencyKey, machines[0].SystemID())
+	}
+	return machines[0], nil
+}
+
+// recordIdempotencyKey stores the key in the owner data of a newly
+// allocated machine.
+func recordIdempotencyKey(machine Machine, key string) error {
+	err := machine.SetOwnerData(map[string]string{IdempotencyKeyOwnerData: key})
+	return errors.Annotate(err, "recording idempotency key")
+}
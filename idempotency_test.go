@@ -0,0 +1,111 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type idempotencySuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&idempotencySuite{})
+
+func allocatedWithOwnerData(c *gc.C, data string) string {
+	return updateJSONMap(c, machineWithOwnerData(data), map[string]interface{}{
+		"constraints_by_type": map[string]interface{}{},
+	})
+}
+
+func (s *idempotencySuite) TestAllocateNew(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?agent_name=key-1", http.StatusOK, "[]")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocatedWithOwnerData(c, "{}"))
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=set_owner_data", http.StatusOK,
+		machineWithOwnerData(`{"gomaasapi-idempotency-key": "key-1"}`))
+	server.ResetRequests()
+
+	machine, _, err := controller.AllocateMachine(AllocateMachineArgs{IdempotencyKey: "key-1"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.OwnerData(), jc.DeepEquals, map[string]string{IdempotencyKeyOwnerData: "key-1"})
+
+	requests := server.LastNRequests(3)
+	c.Assert(requests, gc.HasLen, 3)
+	c.Check(requests[0].Method, gc.Equals, "GET")
+	c.Check(requests[1].PostForm.Get("agent_name"), gc.Equals, "key-1")
+	c.Check(requests[2].PostForm.Get(IdempotencyKeyOwnerData), gc.Equals, "key-1")
+}
+
+func (s *idempotencySuite) TestAllocateExisting(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?agent_name=key-1", http.StatusOK, "["+machineResponse+"]")
+	server.ResetRequests()
+
+	machine, _, err := controller.AllocateMachine(AllocateMachineArgs{IdempotencyKey: "key-1"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *idempotencySuite) TestAllocateWithAgentNameRejected(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+
+	// The key could not be sent with the allocation, so a lost response
+	// would leave a machine that a retry cannot find.
+	_, _, err := controller.AllocateMachine(AllocateMachineArgs{AgentName: "juju", IdempotencyKey: "key-1"})
+	c.Check(err, jc.Satisfies, IsArgumentError)
+	c.Check(err, gc.ErrorMatches, "AgentName: cannot be set with IdempotencyKey, .*")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *idempotencySuite) TestAllocateAfterLostResponse(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	// The first allocation completed, but recording the key in the owner
+	// data failed. The retry finds the machine by its agent name.
+	server.AddGetResponse("/api/2.0/machines/?agent_name=key-1", http.StatusOK, "["+machineWithOwnerData("{}")+"]")
+	server.ResetRequests()
+
+	machine, _, err := controller.AllocateMachine(AllocateMachineArgs{IdempotencyKey: "key-1"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *idempotencySuite) TestAllocateDryRunIgnoresKey(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocatedWithOwnerData(c, "{}"))
+	server.ResetRequests()
+
+	_, _, err := controller.AllocateMachine(AllocateMachineArgs{IdempotencyKey: "key-1", DryRun: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.RequestCount(), gc.Equals, 1)
+	c.Check(server.LastRequest().PostForm.Get("agent_name"), gc.Equals, "")
+}
+
+func (s *idempotencySuite) TestAllocateLookupError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?agent_name=key-1", http.StatusInternalServerError, "boom")
+
+	_, _, err := controller.AllocateMachine(AllocateMachineArgs{IdempotencyKey: "key-1"})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, gc.ErrorMatches, "checking for existing allocation: .*")
+}
+
+func (s *idempotencySuite) TestAllocateRecordError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?agent_name=key-1", http.StatusOK, "[]")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocatedWithOwnerData(c, "{}"))
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=set_owner_data", http.StatusInternalServerError, "boom")
+
+	machine, _, err := controller.AllocateMachine(AllocateMachineArgs{IdempotencyKey: "key-1"})
+	c.Assert(err, gc.ErrorMatches, "recording idempotency key: .*")
+	c.Assert(machine, gc.NotNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+}
@@ -0,0 +1,354 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/version"
+)
+
+// The IDs of MAAS entities are either system IDs, for nodes, or integers,
+// for most other entities. Interfaces and block devices are numbered
+// within MAAS as a whole, but are reached through their node, so they are
+// identified by a NodeChildID. Zones are identified by name. The helpers
+// here turn these IDs to and from strings, such as the import IDs of a
+// Terraform provider, and the Get calls of Controller read an entity by
+// its ID.
+
+// systemIDPattern matches the system IDs that MAAS generates.
+var systemIDPattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// ParseSystemID checks that the string, with any surrounding space
+// removed, is a system ID, such as "4y3ha3", and returns it.
+func ParseSystemID(s string) (string, error) {
+	systemID := strings.TrimSpace(s)
+	if !systemIDPattern.MatchString(systemID) {
+		return "", errors.NotValidf("system ID %q", s)
+	}
+	return systemID, nil
+}
+
+// FormatID returns the string form of the integer ID of an entity, such
+// as a subnet or fabric.
+func FormatID(id int) string {
+	return strconv.Itoa(id)
+}
+
+// ParseID parses the string form of the integer ID of an entity. IDs are
+// never negative; the default fabric has ID 0.
+func ParseID(s string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || id < 0 {
+		return 0, errors.NotValidf("ID %q", s)
+	}
+	return id, nil
+}
+
+// NodeChildID identifies an entity that belongs to a node, such as an
+// interface or a block device, by the system ID of the node and the ID
+// of the entity.
+type NodeChildID struct {
+	SystemID string
+	ID       int
+}
+
+// String returns the ID in the form "<system-id>/<id>", such as
+// "4y3ha3/40", which ParseNodeChildID reads.
+func (id NodeChildID) String() string {
+	return id.SystemID + "/" + FormatID(id.ID)
+}
+
+// ParseNodeChildID parses an ID in the form returned by NodeChildID.String.
+func ParseNodeChildID(s string) (NodeChildID, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return NodeChildID{}, errors.NotValidf("node child ID %q", s)
+	}
+	systemID, err := ParseSystemID(parts[0])
+	if err != nil {
+		return NodeChildID{}, errors.NotValidf("node child ID %q", s)
+	}
+	id, err := ParseID(parts[1])
+	if err != nil {
+		return NodeChildID{}, errors.NotValidf("node child ID %q", s)
+	}
+	return NodeChildID{SystemID: systemID, ID: id}, nil
+}
+
+// nodeChildURIPattern matches the resource URIs of interfaces and block
+// devices, such as "/MAAS/api/2.0/nodes/4y3ha3/interfaces/40/".
+var nodeChildURIPattern = regexp.MustCompile(`/nodes/([^/]+)/(?:interfaces|blockdevices)/(\d+)/?$`)
+
+func nodeChildIDFromURI(resourceURI string) (NodeChildID, error) {
+	match := nodeChildURIPattern.FindStringSubmatch(resourceURI)
+	if match == nil {
+		return NodeChildID{}, errors.NotValidf("resource URI %q", resourceURI)
+	}
+	id, err := ParseID(match[2])
+	if err != nil {
+		return NodeChildID{}, errors.Trace(err)
+	}
+	return NodeChildID{SystemID: match[1], ID: id}, nil
+}
+
+// InterfaceImportID returns the NodeChildID of an interface read from
+// MAAS, which GetInterface reads it again with.
+func InterfaceImportID(iface Interface) (NodeChildID, error) {
+	i, ok := iface.(*interface_)
+	if !ok {
+		return NodeChildID{}, errors.NotValidf("interface %T", iface)
+	}
+	return nodeChildIDFromURI(i.resourceURI)
+}
+
+// BlockDeviceImportID returns the NodeChildID of a block device read from
+// MAAS, which GetBlockDevice reads it again with.
+func BlockDeviceImportID(device BlockDevice) (NodeChildID, error) {
+	d, ok := device.(*blockdevice)
+	if !ok {
+		return NodeChildID{}, errors.NotValidf("block device %T", device)
+	}
+	return nodeChildIDFromURI(d.resourceURI)
+}
+
+// getByID reads the entity at the path, returning a NoMatchError if there
+// is none.
+func (c *controller) getByID(path string) (interface{}, error) {
+	source, err := c.get(path)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	return c.markLeaves(source), nil
+}
+
+// readOne reads a single entity with the reader of a list of them.
+func readOne[T any](read func(version.Number, interface{}) ([]T, error), controllerVersion version.Number, source interface{}) (T, error) {
+	var empty T
+	items, err := read(controllerVersion, []interface{}{source})
+	if err != nil {
+		return empty, errors.Trace(err)
+	}
+	return items[0], nil
+}
+
+// GetMachine implements Controller.
+func (c *controller) GetMachine(systemID string) (Machine, error) {
+	if _, err := ParseSystemID(systemID); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := c.getByID("machines/" + systemID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	machine, err := readMachine(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	machine.controller = c
+	return machine, nil
+}
+
+// GetDevice implements Controller.
+func (c *controller) GetDevice(systemID string) (Device, error) {
+	if _, err := ParseSystemID(systemID); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := c.getByID("devices/" + systemID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	device, err := readDevice(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	device.controller = c
+	return device, nil
+}
+
+// GetRackController implements Controller.
+func (c *controller) GetRackController(systemID string) (RackController, error) {
+	if _, err := ParseSystemID(systemID); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := c.getByID("rackcontrollers/" + systemID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rack, err := readOne(readRackControllers, c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rack.controller = c
+	return rack, nil
+}
+
+// GetInterface implements Controller.
+func (c *controller) GetInterface(id NodeChildID) (Interface, error) {
+	if _, err := ParseSystemID(id.SystemID); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := c.getByID("nodes/" + id.SystemID + "/interfaces/" + FormatID(id.ID))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	iface, err := readInterface(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	iface.controller = c
+	return iface, nil
+}
+
+// GetBlockDevice implements Controller.
+func (c *controller) GetBlockDevice(id NodeChildID) (BlockDevice, error) {
+	if _, err := ParseSystemID(id.SystemID); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := c.getByID("nodes/" + id.SystemID + "/blockdevices/" + FormatID(id.ID))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	device, err := readOne(readBlockDevices, c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return device, nil
+}
+
+// GetSubnet implements Controller.
+func (c *controller) GetSubnet(id int) (Subnet, error) {
+	source, err := c.getByID("subnets/" + FormatID(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	subnet, err := readSubnet(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	subnet.controller = c
+	return subnet, nil
+}
+
+// GetFabric implements Controller.
+func (c *controller) GetFabric(id int) (Fabric, error) {
+	source, err := c.getByID("fabrics/" + FormatID(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	fabric, err := readOne(readFabrics, c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return fabric, nil
+}
+
+// GetVLAN implements Controller.
+func (c *controller) GetVLAN(id int) (VLAN, error) {
+	source, err := c.getByID("vlans/" + FormatID(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	vlan, err := readVLAN(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return vlan, nil
+}
+
+// GetSpace implements Controller.
+func (c *controller) GetSpace(id int) (Space, error) {
+	source, err := c.getByID("spaces/" + FormatID(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	space, err := readOne(readSpaces, c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	space.controller = c
+	return space, nil
+}
+
+// GetStaticRoute implements Controller.
+func (c *controller) GetStaticRoute(id int) (StaticRoute, error) {
+	source, err := c.getByID("static-routes/" + FormatID(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	route, err := readOne(readStaticRoutes, c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return route, nil
+}
+
+// GetVMHost implements Controller.
+func (c *controller) GetVMHost(id int) (VMHost, error) {
+	source, err := c.getByID("pods/" + FormatID(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	host, err := readOne(readVMHosts, c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	host.controller = c
+	return host, nil
+}
+
+// GetBootSource implements Controller.
+func (c *controller) GetBootSource(id int) (BootSource, error) {
+	source, err := c.getByID("boot-sources/" + FormatID(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	bootSource, err := readBootSource(c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	bootSource.controller = c
+	return bootSource, nil
+}
+
+// GetBootResource implements Controller.
+func (c *controller) GetBootResource(id int) (BootResource, error) {
+	source, err := c.getByID("boot-resources/" + FormatID(id))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resource, err := readOne(readBootResources, c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return resource, nil
+}
+
+// GetZone implements Controller.
+func (c *controller) GetZone(name string) (Zone, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, errors.NotValidf("zone name %q", name)
+	}
+	source, err := c.getByID("zones/" + name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	zone, err := readOne(readZones, c.apiVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return zone, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type idsSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&idsSuite{})
+
+func (*idsSuite) TestParseSystemID(c *gc.C) {
+	id, err := ParseSystemID(" 4y3ha3\n")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(id, gc.Equals, "4y3ha3")
+	for _, bad := range []string{"", "4Y3HA3", "../admin", "4y3ha3/40"} {
+		_, err := ParseSystemID(bad)
+		c.Check(err, jc.Satisfies, errors.IsNotValid, gc.Commentf("%q", bad))
+	}
+}
+
+func (*idsSuite) TestParseID(c *gc.C) {
+	id, err := ParseID("0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(id, gc.Equals, 0)
+	c.Check(FormatID(5001), gc.Equals, "5001")
+	for _, bad := range []string{"", "-1", "1.5", "one"} {
+		_, err := ParseID(bad)
+		c.Check(err, jc.Satisfies, errors.IsNotValid, gc.Commentf("%q", bad))
+	}
+}
+
+func (*idsSuite) TestNodeChildID(c *gc.C) {
+	id := NodeChildID{SystemID: "4y3ha6", ID: 40}
+	c.Check(id.String(), gc.Equals, "4y3ha6/40")
+	parsed, err := ParseNodeChildID(id.String())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(parsed, gc.Equals, id)
+	for _, bad := range []string{"4y3ha6", "4y3ha6/", "/40", "4y3ha6/40/1", "4y3ha6/eth0"} {
+		_, err := ParseNodeChildID(bad)
+		c.Check(err, gc.ErrorMatches, `node child ID ".*" not valid`, gc.Commentf("%q", bad))
+	}
+}
+
+func (*idsSuite) TestImportIDs(c *gc.C) {
+	iface, err := readInterface(twoDotOh, parseJSON(c, interfaceResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	id, err := InterfaceImportID(iface)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(id, gc.Equals, NodeChildID{SystemID: "4y3ha6", ID: 40})
+
+	device := &blockdevice{resourceURI: "/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/"}
+	id, err = BlockDeviceImportID(device)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(id.String(), gc.Equals, "4y3ha3/34")
+
+	_, err = BlockDeviceImportID(&blockdevice{resourceURI: "/MAAS/api/2.0/nodes/4y3ha3/"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+// firstOf returns the first entity of a JSON list.
+func firstOf(c *gc.C, list string) string {
+	first, err := json.Marshal(parseJSON(c, list).([]interface{})[0])
+	c.Assert(err, jc.ErrorIsNil)
+	return string(first)
+}
+
+func (s *idsSuite) getServerAndController(c *gc.C) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+func (s *idsSuite) TestGetMachine(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/machines/4y3ha3/", http.StatusOK, machineResponse)
+	m, err := controller.GetMachine("4y3ha3")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(m.Hostname(), gc.Equals, "untasted-markita")
+	// The machine can be used like one from Machines.
+	c.Check(m.(*machine).controller, gc.NotNil)
+
+	_, err = controller.GetMachine("missing")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	_, err = controller.GetMachine("../users")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *idsSuite) TestGetByIntegerID(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/subnets/1/", http.StatusOK, firstOf(c, subnetResponse))
+	server.AddGetResponse("/api/2.0/fabrics/0/", http.StatusOK, firstOf(c, fabricResponse))
+	server.AddGetResponse("/api/2.0/zones/default/", http.StatusOK, firstOf(c, zoneResponse))
+
+	subnet, err := controller.GetSubnet(1)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(subnet.CIDR(), gc.Equals, "192.168.100.0/24")
+	fabric, err := controller.GetFabric(0)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(fabric.ID(), gc.Equals, 0)
+	zone, err := controller.GetZone("default")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(zone.Name(), gc.Equals, "default")
+
+	_, err = controller.GetSpace(7)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	_, err = controller.GetZone("a/b")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *idsSuite) TestGetInterface(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/nodes/4y3ha6/interfaces/40/", http.StatusOK, interfaceResponse)
+	id, err := ParseNodeChildID("4y3ha6/40")
+	c.Assert(err, jc.ErrorIsNil)
+	iface, err := controller.GetInterface(id)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.ID(), gc.Equals, 40)
+	imported, err := InterfaceImportID(iface)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(imported, gc.Equals, id)
+}
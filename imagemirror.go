@@ -0,0 +1,267 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// BootResourceUploaded is the type of boot resources that users uploaded
+// to MAAS, rather than ones synced from a boot source.
+const BootResourceUploaded = "Uploaded"
+
+// uploadFileTypes maps the file types of the files in a boot resource set
+// to the file types accepted when uploading a boot resource.
+var uploadFileTypes = map[string]string{
+	"root-tgz":        "tgz",
+	"root-tbz":        "tbz",
+	"root-txz":        "txz",
+	"root-dd":         "ddtgz",
+	"root-dd.tar":     "ddtar",
+	"root-dd.raw":     "ddraw",
+	"root-dd.bz2":     "ddbz2",
+	"root-dd.gz":      "ddgz",
+	"root-dd.xz":      "ddxz",
+	"root-dd.tar.bz2": "ddtbz",
+	"root-dd.tar.xz":  "ddtxz",
+}
+
+// ImageMirrorResult records what ImageMirror.Mirror did with each custom
+// image of the source. Images are described by name and architecture, as
+// in "centos7 amd64/generic".
+type ImageMirrorResult struct {
+	// Copied lists the images that were missing from the target and were
+	// uploaded to it, or would have been for a dry run.
+	Copied []string
+	// Existing lists the images that the target already has. They are not
+	// compared with the source.
+	Existing []string
+}
+
+// ImageMirror copies the custom images that were uploaded to one MAAS
+// region to another that does not have them yet, such as from a lab region
+// to a production one. Images synced from boot sources are left to each
+// region's own boot source configuration.
+//
+// The content of each image is downloaded from the source and checked
+// against the SHA256 checksum that the source reports before it is
+// uploaded, and the checksum that the target reports for the upload is
+// checked in turn. Images are held in memory while they are copied.
+type ImageMirror struct {
+	source *controller
+	target *controller
+
+	// DryRun, if true, reports what would be copied without downloading
+	// or uploading any image.
+	DryRun bool
+}
+
+// NewImageMirror returns an ImageMirror that copies images between two
+// Controllers created by NewController.
+func NewImageMirror(source, target Controller) (*ImageMirror, error) {
+	s, ok := source.(*controller)
+	if !ok {
+		return nil, errors.NotValidf("source controller %T", source)
+	}
+	t, ok := target.(*controller)
+	if !ok {
+		return nil, errors.NotValidf("target controller %T", target)
+	}
+	return &ImageMirror{source: s, target: t}, nil
+}
+
+// Mirror uploads the uploaded images of the source that the target does
+// not have, matching them by name and architecture. The result records
+// what was done before any error, so an interrupted mirror can be run
+// again to copy the rest.
+func (m *ImageMirror) Mirror() (*ImageMirrorResult, error) {
+	result := &ImageMirrorResult{}
+	sourceResources, err := m.source.BootResources()
+	if err != nil {
+		return result, errors.Annotate(err, "listing source boot resources")
+	}
+	targetResources, err := m.target.BootResources()
+	if err != nil {
+		return result, errors.Annotate(err, "listing target boot resources")
+	}
+	existing := make(map[string]bool)
+	for _, resource := range targetResources {
+		existing[imageName(resource)] = true
+	}
+	for _, resource := range sourceResources {
+		if resource.Type() != BootResourceUploaded {
+			continue
+		}
+		name := imageName(resource)
+		if existing[name] {
+			result.Existing = append(result.Existing, name)
+			continue
+		}
+		if !m.DryRun {
+			if err := m.copyImage(resource); err != nil {
+				return result, errors.Annotatef(err, "image %s", name)
+			}
+		}
+		result.Copied = append(result.Copied, name)
+	}
+	return result, nil
+}
+
+func imageName(resource BootResource) string {
+	return resource.Name() + " " + resource.Architecture()
+}
+
+// imageFile describes the file of the newest complete set of a boot
+// resource.
+type imageFile struct {
+	filename string
+	filetype string
+	sha256   string
+	size     int
+	version  string
+}
+
+func (m *ImageMirror) copyImage(resource BootResource) error {
+	source, err := m.source.getByID(fmt.Sprintf("boot-resources/%d", resource.ID()))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	file, err := readImageFile(source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	uploadType, ok := uploadFileTypes[file.filetype]
+	if !ok {
+		return errors.NotSupportedf("file type %q", file.filetype)
+	}
+	content, err := m.download(resource, file)
+	if err != nil {
+		return errors.Annotate(err, "downloading")
+	}
+	params := url.Values{
+		"name":         {resource.Name()},
+		"architecture": {resource.Architecture()},
+		"filetype":     {uploadType},
+		"sha256":       {file.sha256},
+		"size":         {fmt.Sprint(file.size)},
+	}
+	bytes, err := m.target._postRaw("boot-resources", "", params, map[string][]byte{"content": content})
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	var uploaded interface{}
+	if err := json.Unmarshal(bytes, &uploaded); err != nil {
+		return errors.Trace(err)
+	}
+	uploadedFile, err := readImageFile(m.target.markLeaves(uploaded))
+	if err != nil {
+		return errors.Annotate(err, "reading uploaded boot resource")
+	}
+	if uploadedFile.sha256 != file.sha256 {
+		return errors.Errorf("target reports checksum %s, expected %s", uploadedFile.sha256, file.sha256)
+	}
+	return nil
+}
+
+// download reads the content of the file from the simplestreams mirror
+// that the source region serves to its rack controllers, checking its
+// size and checksum.
+func (m *ImageMirror) download(resource BootResource, file imageFile) ([]byte, error) {
+	osName, series := "custom", resource.Name()
+	if parts := strings.SplitN(series, "/", 2); len(parts) == 2 {
+		osName, series = parts[0], parts[1]
+	}
+	arch, subarch := resource.Architecture(), "generic"
+	if parts := strings.SplitN(arch, "/", 2); len(parts) == 2 {
+		arch, subarch = parts[0], parts[1]
+	}
+	// The API URL ends in api/2.0/, and the stream is served beside it.
+	path := strings.Join([]string{"..", "..", "images-stream", osName, arch, subarch, series, file.version, file.filename}, "/")
+	body, err := m.source.client.GetStream(&url.URL{Path: path}, "", nil)
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	defer body.Close()
+	hash := sha256.New()
+	content, err := ioutil.ReadAll(io.TeeReader(io.LimitReader(body, int64(file.size)+1), hash))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(content) != file.size {
+		return nil, errors.Errorf("content is not %d bytes long", file.size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != file.sha256 {
+		return nil, errors.Errorf("checksum %s, expected %s", sum, file.sha256)
+	}
+	return content, nil
+}
+
+// readImageFile returns the single file of the newest complete set of a
+// boot resource, as returned when it is read by ID.
+func readImageFile(source interface{}) (imageFile, error) {
+	fileChecker := schema.FieldMap(schema.Fields{
+		"filename": stringField(),
+		"filetype": stringField(),
+		"sha256":   stringField(),
+		"size":     intField(),
+	}, nil)
+	checker := schema.FieldMap(schema.Fields{
+		"sets": schema.StringMap(schema.FieldMap(schema.Fields{
+			"complete": boolField(),
+			"files":    schema.StringMap(fileChecker),
+		}, schema.Defaults{"complete": false})),
+	}, schema.Defaults{"sets": schema.Omit})
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return imageFile{}, WrapWithDeserializationError(err, "boot resource sets schema check failed")
+	}
+	sets, _ := coerced.(map[string]interface{})["sets"].(map[string]interface{})
+	var versions []string
+	for version, set := range sets {
+		if set.(map[string]interface{})["complete"].(bool) {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return imageFile{}, errors.NotFoundf("complete set")
+	}
+	// Set versions are dates, such as 20170317 or 20170317.1.
+	sort.Strings(versions)
+	version := versions[len(versions)-1]
+	files := sets[version].(map[string]interface{})["files"].(map[string]interface{})
+	if len(files) != 1 {
+		return imageFile{}, errors.NotSupportedf("set %s with %d files", version, len(files))
+	}
+	var valid map[string]interface{}
+	for _, value := range files {
+		valid = value.(map[string]interface{})
+	}
+	return imageFile{
+		filename: valid["filename"].(string),
+		filetype: valid["filetype"].(string),
+		sha256:   valid["sha256"].(string),
+		size:     valid["size"].(int),
+		version:  version,
+	}, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type imageMirrorSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&imageMirrorSuite{})
+
+const imageContent = "not really a tarball"
+
+func imageChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func bootResourceJSON(id int, type_, name, arch string) string {
+	return fmt.Sprintf(`{
+		"id": %d, "type": %q, "name": %q, "architecture": %q,
+		"resource_uri": "/MAAS/api/2.0/boot-resources/%d/"
+	}`, id, type_, name, arch, id)
+}
+
+// uploadedResourceJSON returns a boot resource read by ID, with an older
+// incomplete set and a complete one holding the image.
+func uploadedResourceJSON(id int, name, checksum string) string {
+	return fmt.Sprintf(`{
+		"id": %d, "type": "Uploaded", "name": %q, "architecture": "amd64/generic",
+		"resource_uri": "/MAAS/api/2.0/boot-resources/%d/",
+		"sets": {
+			"20170101": {"complete": false, "files": {}},
+			"20170317.1": {"complete": true, "files": {
+				"root-tgz": {"filename": "root-tgz", "filetype": "root-tgz", "sha256": %q, "size": %d}
+			}}
+		}
+	}`, id, name, id, checksum, len(imageContent))
+}
+
+func (s *imageMirrorSuite) newController(c *gc.C, resources ...string) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	list := "["
+	for i, resource := range resources {
+		if i > 0 {
+			list += ","
+		}
+		list += resource
+	}
+	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, list+"]")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+func (s *imageMirrorSuite) newMirror(c *gc.C) (*ImageMirror, *SimpleTestServer, *SimpleTestServer) {
+	sourceServer, source := s.newController(c,
+		bootResourceJSON(5, "Synced", "ubuntu/xenial", "amd64/generic"),
+		bootResourceJSON(7, "Uploaded", "centos7", "amd64/generic"),
+		bootResourceJSON(8, "Uploaded", "windows/win2016", "amd64/generic"),
+	)
+	targetServer, target := s.newController(c,
+		bootResourceJSON(2, "Uploaded", "windows/win2016", "amd64/generic"),
+	)
+	mirror, err := NewImageMirror(source, target)
+	c.Assert(err, jc.ErrorIsNil)
+	return mirror, sourceServer, targetServer
+}
+
+func (s *imageMirrorSuite) TestMirror(c *gc.C) {
+	mirror, source, target := s.newMirror(c)
+	checksum := imageChecksum(imageContent)
+	source.AddGetResponse("/api/2.0/boot-resources/7/", http.StatusOK, uploadedResourceJSON(7, "centos7", checksum))
+	source.AddGetResponse("/images-stream/custom/amd64/generic/centos7/20170317.1/root-tgz", http.StatusOK, imageContent)
+	target.AddPostResponse("/api/2.0/boot-resources/", http.StatusCreated, uploadedResourceJSON(3, "centos7", checksum))
+
+	result, err := mirror.Mirror()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result, jc.DeepEquals, &ImageMirrorResult{
+		Copied:   []string{"centos7 amd64/generic"},
+		Existing: []string{"windows/win2016 amd64/generic"},
+	})
+
+	request := target.LastRequest()
+	c.Check(request.PostForm.Get("name"), gc.Equals, "centos7")
+	c.Check(request.PostForm.Get("architecture"), gc.Equals, "amd64/generic")
+	c.Check(request.PostForm.Get("filetype"), gc.Equals, "tgz")
+	c.Check(request.PostForm.Get("sha256"), gc.Equals, checksum)
+	c.Check(request.PostForm.Get("size"), gc.Equals, fmt.Sprint(len(imageContent)))
+	file, _, err := request.FormFile("content")
+	c.Assert(err, jc.ErrorIsNil)
+	content, err := ioutil.ReadAll(file)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, imageContent)
+}
+
+func (s *imageMirrorSuite) TestMirrorDryRun(c *gc.C) {
+	mirror, source, target := s.newMirror(c)
+	mirror.DryRun = true
+	result, err := mirror.Mirror()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.Copied, jc.DeepEquals, []string{"centos7 amd64/generic"})
+	c.Check(source.LastRequest().URL.Path, gc.Equals, "/api/2.0/boot-resources/")
+	c.Check(target.LastRequest().URL.Path, gc.Equals, "/api/2.0/boot-resources/")
+}
+
+func (s *imageMirrorSuite) TestMirrorSizeMismatch(c *gc.C) {
+	mirror, source, target := s.newMirror(c)
+	source.AddGetResponse("/api/2.0/boot-resources/7/", http.StatusOK, uploadedResourceJSON(7, "centos7", imageChecksum(imageContent)))
+	source.AddGetResponse("/images-stream/custom/amd64/generic/centos7/20170317.1/root-tgz", http.StatusOK, "corrupted image content")
+
+	result, err := mirror.Mirror()
+	c.Check(err, gc.ErrorMatches, "image centos7 amd64/generic: downloading: content is not 20 bytes long")
+	c.Check(result.Copied, gc.HasLen, 0)
+	c.Check(target.LastRequest().Method, gc.Equals, "GET")
+}
+
+func (s *imageMirrorSuite) TestMirrorChecksumMismatch(c *gc.C) {
+	mirror, source, target := s.newMirror(c)
+	source.AddGetResponse("/api/2.0/boot-resources/7/", http.StatusOK, uploadedResourceJSON(7, "centos7", imageChecksum("other")))
+	source.AddGetResponse("/images-stream/custom/amd64/generic/centos7/20170317.1/root-tgz", http.StatusOK, imageContent)
+	_, err := mirror.Mirror()
+	c.Check(err, gc.ErrorMatches, `image centos7 amd64/generic: downloading: checksum [0-9a-f]+, expected [0-9a-f]+`)
+	c.Check(target.LastRequest().Method, gc.Equals, "GET")
+}
+
+func (s *imageMirrorSuite) TestMirrorTargetChecksum(c *gc.C) {
+	mirror, source, target := s.newMirror(c)
+	checksum := imageChecksum(imageContent)
+	source.AddGetResponse("/api/2.0/boot-resources/7/", http.StatusOK, uploadedResourceJSON(7, "centos7", checksum))
+	source.AddGetResponse("/images-stream/custom/amd64/generic/centos7/20170317.1/root-tgz", http.StatusOK, imageContent)
+	target.AddPostResponse("/api/2.0/boot-resources/", http.StatusCreated, uploadedResourceJSON(3, "centos7", imageChecksum("other")))
+
+	_, err := mirror.Mirror()
+	c.Check(err, gc.ErrorMatches, "image centos7 amd64/generic: target reports checksum [0-9a-f]+, expected "+checksum)
+}
+
+func (s *imageMirrorSuite) TestNewImageMirrorNeedsControllers(c *gc.C) {
+	_, err := NewImageMirror(nil, nil)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
@@ -0,0 +1,178 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// The image statuses that MAAS reports for a rack controller.
+const (
+	ImagesSynced    = "synced"
+	ImagesSyncing   = "syncing"
+	ImagesOutOfSync = "out-of-sync"
+	ImagesUnknown   = "unknown"
+)
+
+// RackBootImages describes the boot images that a rack controller has.
+type RackBootImages struct {
+	// Connected is false if the region could not reach the rack controller.
+	Connected bool
+	// Status is one of ImagesSynced, ImagesSyncing, ImagesOutOfSync or
+	// ImagesUnknown.
+	Status string
+	Images []RackBootImage
+}
+
+// RackBootImage is a boot image that a rack controller has.
+type RackBootImage struct {
+	// Name is the name of the image, such as "ubuntu/xenial".
+	Name string
+	// Architecture is the architecture without a subarchitecture, such as
+	// "amd64".
+	Architecture     string
+	SubArchitectures []string
+}
+
+// ImageSyncStatus compares the boot images on a rack controller with the
+// boot resources of the region.
+type ImageSyncStatus struct {
+	RackController RackController
+	BootImages     RackBootImages
+
+	// Missing lists the region boot resources that the rack controller
+	// does not have.
+	Missing []BootResource
+
+	// Err is set if the boot images of the rack controller could not be
+	// listed. The other fields are then empty.
+	Err error
+}
+
+// InSync returns true if the rack controller was queried, reports that its
+// images are synced and has all the images of the region.
+func (s ImageSyncStatus) InSync() bool {
+	return s.Err == nil && s.BootImages.Status == ImagesSynced && len(s.Missing) == 0
+}
+
+// BootImages implements RackController.
+func (r *rackController) BootImages() (RackBootImages, error) {
+	source, err := r.controller.getOp(r.resourceURI, "list_boot_images")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return RackBootImages{}, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return RackBootImages{}, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return RackBootImages{}, NewUnexpectedError(err)
+	}
+	images, err := readRackBootImages(r.controller.markLeaves(source))
+	if err != nil {
+		return RackBootImages{}, errors.Trace(err)
+	}
+	return images, nil
+}
+
+// ImageSyncStatus implements Controller.
+func (c *controller) ImageSyncStatus() ([]ImageSyncStatus, error) {
+	resources, err := c.BootResources()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	racks, err := c.RackControllers()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make([]ImageSyncStatus, len(racks))
+	for i, rack := range racks {
+		result[i].RackController = rack
+		images, err := rack.BootImages()
+		if err != nil {
+			// A rack controller that can't be queried shouldn't hide the
+			// state of the others.
+			result[i].Err = errors.Annotatef(err, "rack controller %s", rack.SystemID())
+			continue
+		}
+		result[i].BootImages = images
+		result[i].Missing = missingBootResources(resources, images.Images)
+	}
+	return result, nil
+}
+
+// missingBootResources returns the resources that are not in the images.
+// The region names architectures with a subarchitecture, "amd64/generic",
+// where the rack controller uses "amd64", so only the base architecture is
+// compared.
+func missingBootResources(resources []BootResource, images []RackBootImage) []BootResource {
+	have := make(map[string]bool)
+	for _, image := range images {
+		have[image.Name+" "+baseArchitecture(image.Architecture)] = true
+	}
+	var missing []BootResource
+	for _, resource := range resources {
+		if !have[resource.Name()+" "+baseArchitecture(resource.Architecture())] {
+			missing = append(missing, resource)
+		}
+	}
+	return missing
+}
+
+func baseArchitecture(arch string) string {
+	if i := strings.Index(arch, "/"); i >= 0 {
+		return arch[:i]
+	}
+	return arch
+}
+
+func readRackBootImages(source interface{}) (RackBootImages, error) {
+	fields := schema.Fields{
+		"connected": boolField(),
+		"status":    stringField(),
+		"images":    schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"status": ImagesUnknown,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return RackBootImages{}, WrapWithDeserializationError(err, "rack boot images schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	imageFields := schema.Fields{
+		"name":         stringField(),
+		"architecture": stringField(),
+		"subarches":    schema.List(stringField()),
+	}
+	imageDefaults := schema.Defaults{
+		"subarches": []interface{}{},
+	}
+	imageChecker := schema.FieldMap(imageFields, imageDefaults)
+	var images []RackBootImage
+	for i, value := range valid["images"].([]interface{}) {
+		coerced, err := imageChecker.Coerce(value, nil)
+		if err != nil {
+			return RackBootImages{}, WrapWithDeserializationError(err, "rack boot image %d schema check failed", i)
+		}
+		image := coerced.(map[string]interface{})
+		images = append(images, RackBootImage{
+			Name:             image["name"].(string),
+			Architecture:     image["architecture"].(string),
+			SubArchitectures: convertToStringSlice(image["subarches"]),
+		})
+	}
+	return RackBootImages{
+		Connected: valid["connected"].(bool),
+		Status:    valid["status"].(string),
+		Images:    images,
+	}, nil
+}
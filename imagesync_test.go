@@ -0,0 +1,163 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type imageSyncSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&imageSyncSuite{})
+
+const rackBootImagesPath = "/MAAS/api/2.0/rackcontrollers/4y3h7n/?op=list_boot_images"
+
+func (s *imageSyncSuite) getServerAndController(c *gc.C) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, bootResourcesResponse)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, rackControllersResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+func (*imageSyncSuite) TestReadRackBootImages(c *gc.C) {
+	images, err := readRackBootImages(parseJSON(c, rackBootImagesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(images.Connected, jc.IsTrue)
+	c.Check(images.Status, gc.Equals, ImagesSynced)
+	c.Check(images.Images, jc.DeepEquals, []RackBootImage{{
+		Name:             "ubuntu/trusty",
+		Architecture:     "amd64",
+		SubArchitectures: []string{"generic", "hwe-t"},
+	}, {
+		Name:             "ubuntu/xenial",
+		Architecture:     "amd64",
+		SubArchitectures: []string{},
+	}})
+}
+
+func (*imageSyncSuite) TestReadRackBootImagesStrict(c *gc.C) {
+	for _, mode := range []DecodeMode{DecodeStrict, DecodeLenient} {
+		images, err := readRackBootImages(markLeaves(parseJSON(c, rackBootImagesResponse), mode))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(images.Connected, jc.IsTrue)
+		c.Check(images.Status, gc.Equals, ImagesSynced)
+		c.Check(images.Images[0].SubArchitectures, jc.DeepEquals, []string{"generic", "hwe-t"})
+	}
+}
+
+func (*imageSyncSuite) TestReadRackBootImagesNoStatus(c *gc.C) {
+	images, err := readRackBootImages(parseJSON(c, `{"connected": false, "images": []}`))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(images.Connected, jc.IsFalse)
+	c.Check(images.Status, gc.Equals, ImagesUnknown)
+	c.Check(images.Images, gc.HasLen, 0)
+}
+
+func (*imageSyncSuite) TestReadRackBootImagesBadImage(c *gc.C) {
+	_, err := readRackBootImages(parseJSON(c, `{"connected": true, "images": [{"name": "ubuntu/xenial"}]}`))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, `rack boot image 0 schema check failed: .*`)
+}
+
+func (s *imageSyncSuite) TestBootImages(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse(rackBootImagesPath, http.StatusOK, rackBootImagesResponse)
+	racks, err := controller.RackControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	images, err := racks[0].BootImages()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(images.Status, gc.Equals, ImagesSynced)
+	c.Check(images.Images, gc.HasLen, 2)
+}
+
+func (s *imageSyncSuite) TestBootImagesForbidden(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse(rackBootImagesPath, http.StatusForbidden, "admins only")
+	racks, err := controller.RackControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = racks[0].BootImages()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *imageSyncSuite) TestImageSyncStatusInSync(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse(rackBootImagesPath, http.StatusOK, rackBootImagesResponse)
+	status, err := controller.ImageSyncStatus()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(status, gc.HasLen, 1)
+	c.Check(status[0].RackController.SystemID(), gc.Equals, "4y3h7n")
+	c.Check(status[0].Missing, gc.HasLen, 0)
+	c.Check(status[0].InSync(), jc.IsTrue)
+}
+
+func (s *imageSyncSuite) TestImageSyncStatusMissing(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse(rackBootImagesPath, http.StatusOK, `
+{
+    "connected": true,
+    "status": "out-of-sync",
+    "images": [{"name": "ubuntu/trusty", "architecture": "amd64"}]
+}`)
+	status, err := controller.ImageSyncStatus()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(status, gc.HasLen, 1)
+	c.Check(status[0].InSync(), jc.IsFalse)
+	c.Check(status[0].BootImages.Status, gc.Equals, ImagesOutOfSync)
+	c.Assert(status[0].Missing, gc.HasLen, 1)
+	c.Check(status[0].Missing[0].Name(), gc.Equals, "ubuntu/xenial")
+}
+
+func (s *imageSyncSuite) TestImageSyncStatusRackError(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse(rackBootImagesPath, http.StatusInternalServerError, "boom")
+	status, err := controller.ImageSyncStatus()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(status, gc.HasLen, 1)
+	c.Check(status[0].InSync(), jc.IsFalse)
+	c.Check(status[0].Err, jc.Satisfies, IsUnexpectedError)
+	c.Check(status[0].Err, gc.ErrorMatches, "rack controller 4y3h7n: .*")
+}
+
+func (s *imageSyncSuite) TestImageSyncStatusNoRacks(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusForbidden, "admins only")
+	// The first rack controllers response is still queued, so use it up.
+	_, err := controller.RackControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.ImageSyncStatus()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+var rackBootImagesResponse = `
+{
+    "connected": true,
+    "status": "synced",
+    "images": [
+        {
+            "name": "ubuntu/trusty",
+            "architecture": "amd64",
+            "subarches": ["generic", "hwe-t"]
+        },
+        {
+            "name": "ubuntu/xenial",
+            "architecture": "amd64"
+        }
+    ]
+}
+`
@@ -0,0 +1,307 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// ImportResult records what Importer.Apply did with each entity in the
+// snapshot. Entities are described as "zone default", "fabric fabric-1",
+// "vlan 42 on fabric fabric-1", "subnet 10.0.0.0/24" or "tag virtual".
+type ImportResult struct {
+	// Created lists the entities that were missing and were created, or
+	// would have been for a dry run.
+	Created []string
+	// Existing lists the entities that were already present. They are not
+	// changed.
+	Existing []string
+}
+
+// Importer applies a Snapshot to a MAAS controller, creating the zones,
+// fabrics, VLANs, subnets and tags that it does not have yet. Applying the
+// same snapshot again creates nothing, so an interrupted import can be
+// retried. Machines and devices are not imported, as they need to be
+// enlisted by MAAS itself.
+type Importer struct {
+	controller *controller
+
+	// DryRun, if true, reports what would be created without changing the
+	// controller.
+	DryRun bool
+}
+
+// NewImporter returns an Importer that applies snapshots to a Controller
+// created by NewController.
+func NewImporter(target Controller) (*Importer, error) {
+	c, ok := target.(*controller)
+	if !ok {
+		return nil, errors.NotValidf("controller %T", target)
+	}
+	return &Importer{controller: c}, nil
+}
+
+// Apply creates the entities in the snapshot that are missing from the
+// controller. Zones, fabrics and tags are matched by name, subnets by CIDR
+// and VLANs by fabric name and VID. A subnet is created on the VLAN with
+// the same fabric name and VID as in the snapshot. The result records what
+// was done before any error.
+func (i *Importer) Apply(snapshot *Snapshot) (*ImportResult, error) {
+	if snapshot == nil {
+		return nil, errors.NotValidf("missing snapshot")
+	}
+	result := &ImportResult{}
+	if err := i.applyZones(snapshot.Zones, result); err != nil {
+		return result, errors.Trace(err)
+	}
+	vlanIDs, err := i.applyFabrics(snapshot.Fabrics, result)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	if err := i.applySubnets(snapshot.Subnets, vlanIDs, result); err != nil {
+		return result, errors.Trace(err)
+	}
+	if err := i.applyTags(snapshot.Tags, result); err != nil {
+		return result, errors.Trace(err)
+	}
+	return result, nil
+}
+
+func (i *Importer) applyZones(zones []map[string]interface{}, result *ImportResult) error {
+	existing, err := i.existingNames("zones", "name")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, zone := range zones {
+		name := snapshotString(zone, "name")
+		description := "zone " + name
+		if existing[name] {
+			result.Existing = append(result.Existing, description)
+			continue
+		}
+		params := NewURLParams()
+		params.Values.Add("name", name)
+		params.MaybeAdd("description", snapshotString(zone, "description"))
+		if _, err := i.create("zones", params.Values, description); err != nil {
+			return errors.Trace(err)
+		}
+		result.Created = append(result.Created, description)
+	}
+	return nil
+}
+
+// vlanKey identifies a VLAN across controllers, where the database IDs
+// differ.
+type vlanKey struct {
+	fabric string
+	vid    string
+}
+
+// applyFabrics creates the missing fabrics and VLANs, and returns the IDs
+// of the VLANs on the controller. The IDs of VLANs that a dry run would
+// create are empty.
+func (i *Importer) applyFabrics(fabrics []map[string]interface{}, result *ImportResult) (map[vlanKey]string, error) {
+	current, err := readSnapshotCollection(i.controller, "fabrics")
+	if err != nil {
+		return nil, errors.Annotate(err, "reading fabrics")
+	}
+	byName := make(map[string]map[string]interface{})
+	for _, fabric := range current {
+		byName[snapshotString(fabric, "name")] = fabric
+	}
+	vlanIDs := make(map[vlanKey]string)
+	for _, fabric := range fabrics {
+		name := snapshotString(fabric, "name")
+		description := "fabric " + name
+		target, ok := byName[name]
+		if ok {
+			result.Existing = append(result.Existing, description)
+		} else {
+			params := NewURLParams()
+			params.Values.Add("name", name)
+			params.MaybeAdd("description", snapshotString(fabric, "description"))
+			params.MaybeAdd("class_type", snapshotString(fabric, "class_type"))
+			if target, err = i.create("fabrics", params.Values, description); err != nil {
+				return nil, errors.Trace(err)
+			}
+			result.Created = append(result.Created, description)
+		}
+		targetVLANs := make(map[string]map[string]interface{})
+		for _, vlan := range snapshotList(target, "vlans") {
+			vid := snapshotString(vlan, "vid")
+			targetVLANs[vid] = vlan
+			vlanIDs[vlanKey{name, vid}] = snapshotString(vlan, "id")
+		}
+		for _, vlan := range snapshotList(fabric, "vlans") {
+			vid := snapshotString(vlan, "vid")
+			description := fmt.Sprintf("vlan %s on fabric %s", vid, name)
+			if _, ok := targetVLANs[vid]; ok {
+				result.Existing = append(result.Existing, description)
+				continue
+			}
+			params := NewURLParams()
+			params.Values.Add("vid", vid)
+			params.MaybeAdd("name", snapshotString(vlan, "name"))
+			params.MaybeAdd("mtu", snapshotString(vlan, "mtu"))
+			params.MaybeAdd("description", snapshotString(vlan, "description"))
+			path := fmt.Sprintf("fabrics/%s/vlans", snapshotString(target, "id"))
+			created, err := i.create(path, params.Values, description)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			vlanIDs[vlanKey{name, vid}] = snapshotString(created, "id")
+			result.Created = append(result.Created, description)
+		}
+	}
+	return vlanIDs, nil
+}
+
+func (i *Importer) applySubnets(subnets []map[string]interface{}, vlanIDs map[vlanKey]string, result *ImportResult) error {
+	existing, err := i.existingNames("subnets", "cidr")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, subnet := range subnets {
+		cidr := snapshotString(subnet, "cidr")
+		description := "subnet " + cidr
+		if existing[cidr] {
+			result.Existing = append(result.Existing, description)
+			continue
+		}
+		vlan, _ := subnet["vlan"].(map[string]interface{})
+		key := vlanKey{snapshotString(vlan, "fabric"), snapshotString(vlan, "vid")}
+		vlanID, ok := vlanIDs[key]
+		if !ok {
+			return errors.NotFoundf("vlan %s on fabric %q for %s", key.vid, key.fabric, description)
+		}
+		params := NewURLParams()
+		params.Values.Add("cidr", cidr)
+		params.MaybeAdd("vlan", vlanID)
+		params.MaybeAdd("name", snapshotString(subnet, "name"))
+		params.MaybeAdd("gateway_ip", snapshotString(subnet, "gateway_ip"))
+		params.MaybeAdd("rdns_mode", snapshotString(subnet, "rdns_mode"))
+		var dnsServers []string
+		for _, server := range snapshotValues(subnet, "dns_servers") {
+			dnsServers = append(dnsServers, snapshotScalar(server))
+		}
+		params.MaybeAdd("dns_servers", strings.Join(dnsServers, ","))
+		if _, err := i.create("subnets", params.Values, description); err != nil {
+			return errors.Trace(err)
+		}
+		result.Created = append(result.Created, description)
+	}
+	return nil
+}
+
+func (i *Importer) applyTags(tags []map[string]interface{}, result *ImportResult) error {
+	existing, err := i.existingNames("tags", "name")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, tag := range tags {
+		name := snapshotString(tag, "name")
+		description := "tag " + name
+		if existing[name] {
+			result.Existing = append(result.Existing, description)
+			continue
+		}
+		params := NewURLParams()
+		params.Values.Add("name", name)
+		params.MaybeAdd("comment", snapshotString(tag, "comment"))
+		params.MaybeAdd("definition", snapshotString(tag, "definition"))
+		params.MaybeAdd("kernel_opts", snapshotString(tag, "kernel_opts"))
+		if _, err := i.create("tags", params.Values, description); err != nil {
+			return errors.Trace(err)
+		}
+		result.Created = append(result.Created, description)
+	}
+	return nil
+}
+
+// existingNames returns the values of the key field of the entities in the
+// named collection on the controller.
+func (i *Importer) existingNames(collection, key string) (map[string]bool, error) {
+	entities, err := readSnapshotCollection(i.controller, collection)
+	if err != nil {
+		return nil, errors.Annotatef(err, "reading %s", collection)
+	}
+	names := make(map[string]bool)
+	for _, entity := range entities {
+		names[snapshotString(entity, key)] = true
+	}
+	return names, nil
+}
+
+// create posts to the collection, unless this is a dry run, and returns
+// the created entity.
+func (i *Importer) create(path string, params url.Values, description string) (map[string]interface{}, error) {
+	if i.DryRun {
+		return map[string]interface{}{}, nil
+	}
+	source, err := i.controller.post(path, "", params)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Annotatef(errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage)), "creating %s", description)
+			case http.StatusForbidden:
+				return nil, errors.Annotatef(errors.Wrap(err, NewPermissionError(svrErr.BodyMessage)), "creating %s", description)
+			}
+		}
+		return nil, errors.Annotatef(NewUnexpectedError(err), "creating %s", description)
+	}
+	entity, ok := source.(map[string]interface{})
+	if !ok {
+		return nil, NewDeserializationError("creating %s: unexpected response %T", description, source)
+	}
+	return entity, nil
+}
+
+// snapshotString returns the value of a scalar field of an entity as it
+// would be sent in a request, or "" if it is missing or null.
+func snapshotString(entity map[string]interface{}, key string) string {
+	return snapshotScalar(entity[key])
+}
+
+func snapshotScalar(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(value)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return fmt.Sprint(value)
+}
+
+// snapshotValues returns a list field of an entity.
+func snapshotValues(entity map[string]interface{}, key string) []interface{} {
+	values, _ := entity[key].([]interface{})
+	return values
+}
+
+// snapshotList returns the entities in a list field of an entity.
+func snapshotList(entity map[string]interface{}, key string) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, value := range snapshotValues(entity, key) {
+		if m, ok := value.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
@@ -0,0 +1,225 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type importSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&importSuite{})
+
+const importSnapshot = `
+{
+    "api_version": "2.0.0",
+    "machines": [],
+    "devices": [],
+    "subnets": [
+        {"id": 1, "cidr": "192.168.100.0/24", "name": "192.168.100.0/24",
+         "vlan": {"fabric": "fabric-0", "vid": 0, "id": 1}},
+        {"id": 2, "cidr": "10.0.42.0/24", "name": "storage", "gateway_ip": "10.0.42.1",
+         "dns_servers": ["8.8.8.8", "8.8.4.4"], "rdns_mode": 2,
+         "vlan": {"fabric": "fabric-0", "vid": 42, "id": 7}},
+        {"id": 3, "cidr": "192.168.122.0/24", "name": "192.168.122.0/24", "gateway_ip": null,
+         "vlan": {"fabric": "fabric-1", "vid": 0, "id": 5001}}
+    ],
+    "fabrics": [
+        {"id": 0, "name": "fabric-0", "class_type": null, "vlans": [
+            {"id": 1, "vid": 0, "name": "untagged", "mtu": 1500, "fabric": "fabric-0"},
+            {"id": 7, "vid": 42, "name": "storage", "mtu": 9000, "fabric": "fabric-0"}
+        ]},
+        {"id": 1, "name": "fabric-1", "class_type": null, "vlans": [
+            {"id": 5001, "vid": 0, "name": "untagged", "mtu": 1500, "fabric": "fabric-1"}
+        ]}
+    ],
+    "tags": [
+        {"name": "virtual", "comment": "VMs", "definition": "", "kernel_opts": null}
+    ],
+    "zones": [
+        {"name": "default", "description": ""},
+        {"name": "special", "description": "special description"}
+    ]
+}
+`
+
+func (s *importSuite) getImporter(c *gc.C) (*SimpleTestServer, *Importer) {
+	server, controller := createTestServerController(c, s)
+	importer, err := NewImporter(controller)
+	c.Assert(err, jc.ErrorIsNil)
+	return server, importer
+}
+
+func (*importSuite) readSnapshot(c *gc.C) *Snapshot {
+	snapshot, err := ReadSnapshot(bytes.NewBufferString(importSnapshot))
+	c.Assert(err, jc.ErrorIsNil)
+	return snapshot
+}
+
+// addTargetState sets up a controller that has the default zone and
+// fabric-0 with its untagged VLAN and subnet.
+func addTargetState(server *SimpleTestServer) {
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, `[{"name": "default", "description": ""}]`)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, `[
+        {"id": 0, "name": "fabric-0", "vlans": [{"id": 1, "vid": 0, "name": "untagged"}]}
+    ]`)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, `[{"id": 1, "cidr": "192.168.100.0/24"}]`)
+	server.AddGetResponse("/api/2.0/tags/", http.StatusOK, `[]`)
+}
+
+func (*importSuite) TestNewImporterOtherController(c *gc.C) {
+	var controller Controller
+	_, err := NewImporter(controller)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (*importSuite) TestReadSnapshotBad(c *gc.C) {
+	_, err := ReadSnapshot(bytes.NewBufferString("[]"))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *importSuite) TestApplyMissingSnapshot(c *gc.C) {
+	_, importer := s.getImporter(c)
+	_, err := importer.Apply(nil)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *importSuite) TestApply(c *gc.C) {
+	server, importer := s.getImporter(c)
+	addTargetState(server)
+	server.AddPostResponse("/api/2.0/zones/", http.StatusOK, `{"name": "special"}`)
+	server.AddPostResponse("/api/2.0/fabrics/0/vlans/", http.StatusOK, `{"id": 5042, "vid": 42}`)
+	server.AddPostResponse("/api/2.0/fabrics/", http.StatusOK, `
+        {"id": 1, "name": "fabric-1", "vlans": [{"id": 5001, "vid": 0, "name": "untagged"}]}`)
+	server.AddPostResponse("/api/2.0/subnets/", http.StatusOK, `{"id": 2}`)
+	server.AddPostResponse("/api/2.0/subnets/", http.StatusOK, `{"id": 3}`)
+	server.AddPostResponse("/api/2.0/tags/", http.StatusOK, `{"name": "virtual"}`)
+	server.ResetRequests()
+
+	result, err := importer.Apply(s.readSnapshot(c))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.Created, jc.DeepEquals, []string{
+		"zone special",
+		"vlan 42 on fabric fabric-0",
+		"fabric fabric-1",
+		"subnet 10.0.42.0/24",
+		"subnet 192.168.122.0/24",
+		"tag virtual",
+	})
+	c.Check(result.Existing, jc.DeepEquals, []string{
+		"zone default",
+		"fabric fabric-0",
+		"vlan 0 on fabric fabric-0",
+		"vlan 0 on fabric fabric-1",
+		"subnet 192.168.100.0/24",
+	})
+
+	var posts []*http.Request
+	for _, request := range server.LastNRequests(server.RequestCount()) {
+		if request.Method == "POST" {
+			posts = append(posts, request)
+		}
+	}
+	c.Assert(posts, gc.HasLen, 6)
+	c.Check(posts[0].PostForm.Get("name"), gc.Equals, "special")
+	c.Check(posts[0].PostForm.Get("description"), gc.Equals, "special description")
+	c.Check(posts[1].PostForm.Get("vid"), gc.Equals, "42")
+	c.Check(posts[1].PostForm.Get("name"), gc.Equals, "storage")
+	c.Check(posts[1].PostForm.Get("mtu"), gc.Equals, "9000")
+	c.Check(posts[2].PostForm.Get("name"), gc.Equals, "fabric-1")
+	_, hasClassType := posts[2].PostForm["class_type"]
+	c.Check(hasClassType, jc.IsFalse)
+	c.Check(posts[3].PostForm.Get("cidr"), gc.Equals, "10.0.42.0/24")
+	c.Check(posts[3].PostForm.Get("vlan"), gc.Equals, "5042")
+	c.Check(posts[3].PostForm.Get("name"), gc.Equals, "storage")
+	c.Check(posts[3].PostForm.Get("gateway_ip"), gc.Equals, "10.0.42.1")
+	c.Check(posts[3].PostForm.Get("dns_servers"), gc.Equals, "8.8.8.8,8.8.4.4")
+	c.Check(posts[3].PostForm.Get("rdns_mode"), gc.Equals, "2")
+	c.Check(posts[4].PostForm.Get("cidr"), gc.Equals, "192.168.122.0/24")
+	c.Check(posts[4].PostForm.Get("vlan"), gc.Equals, "5001")
+	_, hasGateway := posts[4].PostForm["gateway_ip"]
+	c.Check(hasGateway, jc.IsFalse)
+	c.Check(posts[5].PostForm.Get("name"), gc.Equals, "virtual")
+	c.Check(posts[5].PostForm.Get("comment"), gc.Equals, "VMs")
+}
+
+func (s *importSuite) TestApplyDryRun(c *gc.C) {
+	server, importer := s.getImporter(c)
+	addTargetState(server)
+	server.ResetRequests()
+	importer.DryRun = true
+
+	result, err := importer.Apply(s.readSnapshot(c))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.Created, jc.DeepEquals, []string{
+		"zone special",
+		"vlan 42 on fabric fabric-0",
+		"fabric fabric-1",
+		"vlan 0 on fabric fabric-1",
+		"subnet 10.0.42.0/24",
+		"subnet 192.168.122.0/24",
+		"tag virtual",
+	})
+	for _, request := range server.LastNRequests(server.RequestCount()) {
+		c.Check(request.Method, gc.Equals, "GET")
+	}
+}
+
+func (s *importSuite) TestApplyNothingMissing(c *gc.C) {
+	server, importer := s.getImporter(c)
+	snapshot := s.readSnapshot(c)
+	var buf bytes.Buffer
+	c.Assert(snapshot.WriteJSON(&buf), jc.ErrorIsNil)
+	// The target has the same state as the snapshot.
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, `[{"name": "default"}, {"name": "special"}]`)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, `[
+        {"id": 0, "name": "fabric-0", "vlans": [{"id": 1, "vid": 0}, {"id": 7, "vid": 42}]},
+        {"id": 1, "name": "fabric-1", "vlans": [{"id": 5001, "vid": 0}]}
+    ]`)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, `[
+        {"cidr": "192.168.100.0/24"}, {"cidr": "10.0.42.0/24"}, {"cidr": "192.168.122.0/24"}
+    ]`)
+	server.AddGetResponse("/api/2.0/tags/", http.StatusOK, `[{"name": "virtual"}]`)
+
+	reread, err := ReadSnapshot(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	result, err := importer.Apply(reread)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.Created, gc.HasLen, 0)
+	c.Check(result.Existing, gc.HasLen, 11)
+}
+
+func (s *importSuite) TestApplyCreateError(c *gc.C) {
+	server, importer := s.getImporter(c)
+	addTargetState(server)
+	server.AddPostResponse("/api/2.0/zones/", http.StatusForbidden, "not an admin")
+
+	result, err := importer.Apply(s.readSnapshot(c))
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Check(err, gc.ErrorMatches, "creating zone special: .*")
+	c.Check(result.Existing, jc.DeepEquals, []string{"zone default"})
+	c.Check(result.Created, gc.HasLen, 0)
+}
+
+func (s *importSuite) TestApplyMissingVLAN(c *gc.C) {
+	server, importer := s.getImporter(c)
+	addTargetState(server)
+	snapshot := &Snapshot{
+		Subnets: []map[string]interface{}{{
+			"cidr": "10.1.0.0/24",
+			"vlan": map[string]interface{}{"fabric": "fabric-9", "vid": 0},
+		}},
+	}
+	_, err := importer.Apply(snapshot)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Check(err, gc.ErrorMatches, `vlan 0 on fabric "fabric-9" for subnet 10.1.0.0/24 not found`)
+}
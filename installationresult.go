@@ -0,0 +1,119 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/base64"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+const (
+	// InstallLogName is the name of the installation result that holds
+	// the console output of the installer.
+	InstallLogName = "/tmp/install.log"
+
+	// CurtinLogsName is the name of the installation result that holds
+	// the tar archive of the curtin logs.
+	CurtinLogsName = "/tmp/curtin-logs.tar"
+)
+
+// InstallationResult is an output file recorded by MAAS while installing
+// the operating system on a machine.
+type InstallationResult struct {
+	ID   int
+	Name string
+	// ScriptResult is the exit status of the installer; non-zero values
+	// indicate failure.
+	ScriptResult int
+	Created      string
+	Updated      string
+	Data         []byte
+}
+
+func readInstallationResults(controllerVersion version.Number, source interface{}) ([]InstallationResult, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "installation result base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range installationResultDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no installation result read func for version %s", controllerVersion)
+	}
+	readFunc := installationResultDeserializationFuncs[deserialisationVersion]
+	return readInstallationResultList(valid, readFunc)
+}
+
+// readInstallationResultList expects the values of the sourceList to be
+// string maps.
+func readInstallationResultList(sourceList []interface{}, readFunc installationResultDeserializationFunc) ([]InstallationResult, error) {
+	result := make([]InstallationResult, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for installation result %d, %T", i, value)
+		}
+		installationResult, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "installation result %d", i)
+		}
+		result = append(result, installationResult)
+	}
+	return result, nil
+}
+
+type installationResultDeserializationFunc func(map[string]interface{}) (InstallationResult, error)
+
+var installationResultDeserializationFuncs = map[version.Number]installationResultDeserializationFunc{
+	twoDotOh: installationResult_2_0,
+}
+
+func installationResult_2_0(source map[string]interface{}) (InstallationResult, error) {
+	fields := schema.Fields{
+		"id":            intField(),
+		"name":          stringField(),
+		"script_result": intField(),
+		"created":       stringField(),
+		"updated":       stringField(),
+		"data":          stringField(),
+	}
+	defaults := schema.Defaults{
+		"id":      0,
+		"created": "",
+		"updated": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return InstallationResult{}, WrapWithDeserializationError(err, "installation result 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	// The data is always base64 encoded by MAAS, as it may be binary.
+	data, err := base64.StdEncoding.DecodeString(valid["data"].(string))
+	if err != nil {
+		return InstallationResult{}, WrapWithDeserializationError(err, "installation result %q data", valid["name"])
+	}
+	result := InstallationResult{
+		ID:           valid["id"].(int),
+		Name:         valid["name"].(string),
+		ScriptResult: valid["script_result"].(int),
+		Created:      valid["created"].(string),
+		Updated:      valid["updated"].(string),
+		Data:         data,
+	}
+	return result, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type installationResultSuite struct{}
+
+var _ = gc.Suite(&installationResultSuite{})
+
+func (*installationResultSuite) TestReadInstallationResultsBadSchema(c *gc.C) {
+	_, err := readInstallationResults(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `installation result base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*installationResultSuite) TestReadInstallationResults(c *gc.C) {
+	results, err := readInstallationResults(twoDotOh, parseJSON(c, installationResultsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 2)
+	result := results[0]
+	c.Check(result.ID, gc.Equals, 12)
+	c.Check(result.Name, gc.Equals, InstallLogName)
+	c.Check(result.ScriptResult, gc.Equals, 0)
+	c.Check(result.Created, gc.Equals, "2016-04-19T10:50:24.017")
+	c.Check(string(result.Data), gc.Equals, "curtin: Installation started.\ncurtin: Installation finished.\n")
+	c.Check(results[1].Name, gc.Equals, CurtinLogsName)
+	c.Check(string(results[1].Data), gc.Equals, "tarball")
+}
+
+func (*installationResultSuite) TestReadInstallationResultsBadData(c *gc.C) {
+	_, err := readInstallationResults(twoDotOh, parseJSON(c, `[{"name": "x", "script_result": 0, "data": "!!!"}]`))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (*installationResultSuite) TestLowVersion(c *gc.C) {
+	_, err := readInstallationResults(version.MustParse("1.9.0"), parseJSON(c, installationResultsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (*installationResultSuite) TestHighVersion(c *gc.C) {
+	results, err := readInstallationResults(version.MustParse("2.1.9"), parseJSON(c, installationResultsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 2)
+}
+
+const installationResultsResponse = `
+[
+    {
+        "id": 12,
+        "name": "/tmp/install.log",
+        "script_result": 0,
+        "result_type": 1,
+        "created": "2016-04-19T10:50:24.017",
+        "updated": "2016-04-19T10:50:24.017",
+        "data": "Y3VydGluOiBJbnN0YWxsYXRpb24gc3RhcnRlZC4KY3VydGluOiBJbnN0YWxsYXRpb24gZmluaXNoZWQuCg==",
+        "node": {
+            "system_id": "4y3ha3"
+        },
+        "resource_uri": "/MAAS/api/2.0/installation-results/"
+    },
+    {
+        "id": 13,
+        "name": "/tmp/curtin-logs.tar",
+        "script_result": 0,
+        "result_type": 1,
+        "created": "2016-04-19T10:50:24.017",
+        "updated": "2016-04-19T10:50:24.017",
+        "data": "dGFyYmFsbA==",
+        "node": {
+            "system_id": "4y3ha3"
+        },
+        "resource_uri": "/MAAS/api/2.0/installation-results/"
+    }
+]
+`
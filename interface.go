@@ -14,6 +14,8 @@ import (
 
 // Can't use interface as a type, so add an underscore. Yay.
 type interface_ struct {
+	rawJSON
+
 	controller *controller
 
 	resourceURI string
@@ -30,11 +32,17 @@ type interface_ struct {
 	macAddress   string
 	effectiveMTU int
 
+	linkSpeed      int
+	interfaceSpeed int
+
 	parents  []string
 	children []string
+
+	discovered []*discoveredAddress
 }
 
 func (i *interface_) updateFrom(other *interface_) {
+	i.rawJSON = other.rawJSON
 	i.resourceURI = other.resourceURI
 	i.id = other.id
 	i.name = other.name
@@ -45,8 +53,11 @@ func (i *interface_) updateFrom(other *interface_) {
 	i.links = other.links
 	i.macAddress = other.macAddress
 	i.effectiveMTU = other.effectiveMTU
+	i.linkSpeed = other.linkSpeed
+	i.interfaceSpeed = other.interfaceSpeed
 	i.parents = other.parents
 	i.children = other.children
+	i.discovered = other.discovered
 }
 
 // ID implements Interface.
@@ -95,8 +106,8 @@ func (i *interface_) VLAN() VLAN {
 // Links implements Interface.
 func (i *interface_) Links() []Link {
 	result := make([]Link, len(i.links))
-	for i, link := range i.links {
-		result[i] = link
+	for index, link := range i.links {
+		result[index] = link.withController(i.controller)
 	}
 	return result
 }
@@ -111,11 +122,23 @@ func (i *interface_) EffectiveMTU() int {
 	return i.effectiveMTU
 }
 
+// LinkSpeed implements Interface.
+func (i *interface_) LinkSpeed() int {
+	return i.linkSpeed
+}
+
+// InterfaceSpeed implements Interface.
+func (i *interface_) InterfaceSpeed() int {
+	return i.interfaceSpeed
+}
+
 // UpdateInterfaceArgs is an argument struct for calling Interface.Update.
 type UpdateInterfaceArgs struct {
 	Name       string
 	MACAddress string
 	VLAN       VLAN
+	// MTU, if not zero, is the new maximum transmission unit.
+	MTU int
 }
 
 func (a *UpdateInterfaceArgs) vlanID() int {
@@ -135,6 +158,7 @@ func (i *interface_) Update(args UpdateInterfaceArgs) error {
 	params.MaybeAdd("name", args.Name)
 	params.MaybeAdd("mac_address", args.MACAddress)
 	params.MaybeAddInt("vlan", args.vlanID())
+	params.MaybeAddInt("mtu", args.MTU)
 	source, err := i.controller.put(i.resourceURI, params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -148,7 +172,7 @@ func (i *interface_) Update(args UpdateInterfaceArgs) error {
 		return NewUnexpectedError(err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.apiVersion, i.controller.markLeaves(source))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -258,7 +282,7 @@ func (i *interface_) LinkSubnet(args LinkSubnetArgs) error {
 		return NewUnexpectedError(err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.apiVersion, i.controller.markLeaves(source))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -299,7 +323,7 @@ func (i *interface_) UnlinkSubnet(subnet Subnet) error {
 		return NewUnexpectedError(err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.apiVersion, i.controller.markLeaves(source))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -375,25 +399,34 @@ var interfaceDeserializationFuncs = map[version.Number]interfaceDeserializationF
 
 func interface_2_0(source map[string]interface{}) (*interface_, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
+		"resource_uri": stringField(),
 
-		"id":      schema.ForceInt(),
-		"name":    schema.String(),
-		"type":    schema.String(),
-		"enabled": schema.Bool(),
-		"tags":    schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+		"id":      intField(),
+		"name":    stringField(),
+		"type":    stringField(),
+		"enabled": boolField(),
+		"tags":    nullable(schema.List(stringField())),
 
-		"vlan":  schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"vlan":  nullable(schema.StringMap(schema.Any())),
 		"links": schema.List(schema.StringMap(schema.Any())),
 
-		"mac_address":   schema.OneOf(schema.Nil(""), schema.String()),
-		"effective_mtu": schema.ForceInt(),
+		"mac_address":   nullable(stringField()),
+		"effective_mtu": intField(),
+
+		"link_speed":      intField(),
+		"interface_speed": intField(),
 
-		"parents":  schema.List(schema.String()),
-		"children": schema.List(schema.String()),
+		"parents":  schema.List(stringField()),
+		"children": schema.List(stringField()),
+
+		"discovered": nullable(schema.List(schema.StringMap(schema.Any()))),
 	}
 	defaults := schema.Defaults{
 		"mac_address": "",
+		"discovered":  nil,
+		// MAAS 2.4 added the speeds, in Mbit/s.
+		"link_speed":      0,
+		"interface_speed": 0,
 	}
 	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(source, nil)
@@ -417,8 +450,17 @@ func interface_2_0(source map[string]interface{}) (*interface_, error) {
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	var discovered []*discoveredAddress
+	// If it's not a list then we know it's nil from the schema check.
+	if discoveredList, ok := valid["discovered"].([]interface{}); ok {
+		discovered, err = readDiscoveredAddressList(discoveredList)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	macAddress, _ := valid["mac_address"].(string)
 	result := &interface_{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 
 		id:      valid["id"].(int),
@@ -433,8 +475,13 @@ func interface_2_0(source map[string]interface{}) (*interface_, error) {
 		macAddress:   macAddress,
 		effectiveMTU: valid["effective_mtu"].(int),
 
+		linkSpeed:      valid["link_speed"].(int),
+		interfaceSpeed: valid["interface_speed"].(int),
+
 		parents:  convertToStringSlice(valid["parents"]),
 		children: convertToStringSlice(valid["children"]),
+
+		discovered: discovered,
 	}
 	return result, nil
 }
@@ -90,6 +90,21 @@ func (s *interfaceSuite) TestReadInterfaceNilMAC(c *gc.C) {
 	c.Assert(result.MACAddress(), gc.Equals, "")
 }
 
+func (s *interfaceSuite) TestReadInterfaceSpeeds(c *gc.C) {
+	result, err := readInterface(twoDotOh, parseJSON(c, interfaceResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.LinkSpeed(), gc.Equals, 0)
+	c.Check(result.InterfaceSpeed(), gc.Equals, 0)
+
+	json := parseJSON(c, interfaceResponse)
+	json.(map[string]interface{})["link_speed"] = 1000
+	json.(map[string]interface{})["interface_speed"] = 10000
+	result, err = readInterface(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.LinkSpeed(), gc.Equals, 1000)
+	c.Check(result.InterfaceSpeed(), gc.Equals, 10000)
+}
+
 func (*interfaceSuite) TestLowVersion(c *gc.C) {
 	_, err := readInterfaces(version.MustParse("1.9.0"), parseJSON(c, interfacesResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
@@ -400,6 +415,23 @@ func (s *interfaceSuite) TestUpdateGood(c *gc.C) {
 	c.Assert(form.Get("name"), gc.Equals, "eth42")
 	c.Assert(form.Get("mac_address"), gc.Equals, "c3-52-51-b4-50-cd")
 	c.Assert(form.Get("vlan"), gc.Equals, "13")
+	_, hasMTU := form["mtu"]
+	c.Assert(hasMTU, jc.IsFalse)
+}
+
+func (s *interfaceSuite) TestUpdateMTU(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"effective_mtu": 9000,
+	})
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, response)
+	err := iface.Update(UpdateInterfaceArgs{MTU: 9000})
+	c.Check(err, jc.ErrorIsNil)
+	c.Check(iface.EffectiveMTU(), gc.Equals, 9000)
+
+	form := server.LastRequest().PostForm
+	c.Assert(form, gc.HasLen, 1)
+	c.Assert(form.Get("mtu"), gc.Equals, "9000")
 }
 
 const (
@@ -0,0 +1,168 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// UpdateInterfacesArgs selects machine interfaces and describes the change
+// to make to them with Controller.UpdateInterfaces.
+type UpdateInterfacesArgs struct {
+	// Machines selects the machines whose interfaces are considered.
+	Machines MachinesArgs
+	// MachineTags, if not empty, limits the machines to those that have
+	// all of the tags.
+	MachineTags []string
+	// InterfaceTags, if not empty, limits the interfaces to those that
+	// have all of the tags.
+	InterfaceTags []string
+	// Fabric, if not empty, limits the interfaces to those whose VLAN is
+	// on the named fabric.
+	Fabric string
+
+	// VLAN, if not nil, is the VLAN to move the interfaces to.
+	VLAN VLAN
+	// MTU, if not zero, is the MTU to set on the interfaces.
+	MTU int
+
+	// Workers is the number of interfaces updated at the same time. If
+	// less than one, DefaultBatchWorkers is used.
+	Workers int
+}
+
+// Validate checks that a change is requested.
+func (a *UpdateInterfacesArgs) Validate() error {
+	if a.VLAN == nil && a.MTU == 0 {
+		return errors.NotValidf("missing VLAN and MTU")
+	}
+	if a.MTU < 0 {
+		return errors.NotValidf("negative MTU %d", a.MTU)
+	}
+	return nil
+}
+
+// InterfaceUpdate records the outcome for one interface selected by
+// Controller.UpdateInterfaces.
+type InterfaceUpdate struct {
+	Machine   Machine
+	Interface Interface
+	// Err is set if the interface could not be updated.
+	Err error
+}
+
+// UpdateInterfacesResult summarizes Controller.UpdateInterfaces. Each
+// selected interface is in exactly one of the lists, in the order the
+// machines and their interfaces were listed.
+type UpdateInterfacesResult struct {
+	// Updated holds the interfaces that were changed.
+	Updated []InterfaceUpdate
+	// Unchanged holds the interfaces that already had the VLAN and MTU.
+	Unchanged []InterfaceUpdate
+	// Failed holds the interfaces that could not be updated.
+	Failed []InterfaceUpdate
+}
+
+// String returns a one line summary of the result.
+func (r UpdateInterfacesResult) String() string {
+	return fmt.Sprintf("%d updated, %d unchanged, %d failed", len(r.Updated), len(r.Unchanged), len(r.Failed))
+}
+
+// UpdateInterfaces implements Controller.
+func (c *controller) UpdateInterfaces(args UpdateInterfacesArgs) (UpdateInterfacesResult, error) {
+	var result UpdateInterfacesResult
+	if err := args.Validate(); err != nil {
+		return result, errors.Trace(err)
+	}
+	machines, err := c.Machines(args.Machines)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+
+	var selected []InterfaceUpdate
+	for _, machine := range machines {
+		if !hasAllTags(machine.Tags(), args.MachineTags) {
+			continue
+		}
+		for _, iface := range machine.InterfaceSet() {
+			if !hasAllTags(iface.Tags(), args.InterfaceTags) {
+				continue
+			}
+			if args.Fabric != "" && (iface.VLAN() == nil || iface.VLAN().Fabric() != args.Fabric) {
+				continue
+			}
+			selected = append(selected, InterfaceUpdate{Machine: machine, Interface: iface})
+		}
+	}
+
+	changed := make([]bool, len(selected))
+	workers := args.Workers
+	if workers < 1 {
+		workers = DefaultBatchWorkers
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				changed[index], selected[index].Err = updateSelectedInterface(selected[index].Interface, args)
+			}
+		}()
+	}
+	for index := range selected {
+		indices <- index
+	}
+	close(indices)
+	wg.Wait()
+
+	for i, update := range selected {
+		switch {
+		case update.Err != nil:
+			result.Failed = append(result.Failed, update)
+		case changed[i]:
+			result.Updated = append(result.Updated, update)
+		default:
+			result.Unchanged = append(result.Unchanged, update)
+		}
+	}
+	return result, nil
+}
+
+// updateSelectedInterface updates the interface unless it already has the
+// requested VLAN and MTU, and reports whether a request was made.
+func updateSelectedInterface(iface Interface, args UpdateInterfacesArgs) (bool, error) {
+	var update UpdateInterfaceArgs
+	if args.VLAN != nil && (iface.VLAN() == nil || iface.VLAN().ID() != args.VLAN.ID()) {
+		update.VLAN = args.VLAN
+	}
+	if args.MTU != 0 && iface.EffectiveMTU() != args.MTU {
+		update.MTU = args.MTU
+	}
+	if update == (UpdateInterfaceArgs{}) {
+		return false, nil
+	}
+	if err := iface.Update(update); err != nil {
+		return false, errors.Annotatef(err, "updating interface %s", iface.Name())
+	}
+	return true, nil
+}
+
+func hasAllTags(tags, required []string) bool {
+	if len(required) == 0 {
+		return true
+	}
+	have := set.NewStrings(tags...)
+	for _, tag := range required {
+		if !have.Contains(tag) {
+			return false
+		}
+	}
+	return true
+}
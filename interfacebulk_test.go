@@ -0,0 +1,159 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type interfaceBulkSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&interfaceBulkSuite{})
+
+func bulkInterface(c *gc.C, id, vlanID int, fabric string, tags ...string) map[string]interface{} {
+	iface := parseJSON(c, interfaceResponse).(map[string]interface{})
+	iface["id"] = id
+	iface["name"] = fmt.Sprintf("eth%d", id)
+	iface["resource_uri"] = fmt.Sprintf("/MAAS/api/2.0/nodes/4y3ha6/interfaces/%d/", id)
+	iface["tags"] = tags
+	vlan := iface["vlan"].(map[string]interface{})
+	vlan["id"] = vlanID
+	vlan["fabric"] = fabric
+	return iface
+}
+
+func bulkInterfaceJSON(c *gc.C, id, vlanID int, fabric string) string {
+	bytes, err := json.Marshal(bulkInterface(c, id, vlanID, fabric))
+	c.Assert(err, jc.ErrorIsNil)
+	return string(bytes)
+}
+
+func (s *interfaceBulkSuite) getServerAndController(c *gc.C) (*SimpleTestServer, Controller) {
+	server, controller := createTestServerController(c, s)
+	machine1 := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id": "4y3ha3",
+		"tag_names": []string{"migrate"},
+		"interface_set": []interface{}{
+			bulkInterface(c, 40, 1, "fabric-0", "foo", "bar"),
+			bulkInterface(c, 41, 5001, "fabric-1"),
+		},
+	})
+	machine2 := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id": "4y3ha4",
+		"tag_names": []string{},
+		"interface_set": []interface{}{
+			bulkInterface(c, 50, 1, "fabric-0", "foo"),
+		},
+	})
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machine1+","+machine2+"]")
+	return server, controller
+}
+
+func (*interfaceBulkSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    UpdateInterfacesArgs
+		errText string
+	}{{
+		errText: "missing VLAN and MTU not valid",
+	}, {
+		args:    UpdateInterfacesArgs{MTU: -1},
+		errText: "negative MTU -1 not valid",
+	}, {
+		args: UpdateInterfacesArgs{MTU: 9000},
+	}, {
+		args: UpdateInterfacesArgs{VLAN: &fakeVLAN{id: 13}},
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *interfaceBulkSuite) TestUpdateInterfacesValidates(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+	_, err := controller.UpdateInterfaces(UpdateInterfacesArgs{})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *interfaceBulkSuite) TestUpdateInterfacesVLANByTagAndFabric(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddPutResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/", http.StatusOK, bulkInterfaceJSON(c, 40, 13, "fabric-0"))
+
+	result, err := controller.UpdateInterfaces(UpdateInterfacesArgs{
+		MachineTags: []string{"migrate"},
+		Fabric:      "fabric-0",
+		VLAN:        &fakeVLAN{id: 13},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.String(), gc.Equals, "1 updated, 0 unchanged, 0 failed")
+	c.Assert(result.Updated, gc.HasLen, 1)
+	c.Check(result.Updated[0].Machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(result.Updated[0].Interface.ID(), gc.Equals, 40)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, gc.HasLen, 1)
+	c.Check(form.Get("vlan"), gc.Equals, "13")
+}
+
+func (s *interfaceBulkSuite) TestUpdateInterfacesInterfaceTags(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	result, err := controller.UpdateInterfaces(UpdateInterfacesArgs{
+		InterfaceTags: []string{"foo"},
+		VLAN:          &fakeVLAN{id: 1},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	// Both interfaces tagged foo are already on VLAN 1.
+	c.Check(result.String(), gc.Equals, "0 updated, 2 unchanged, 0 failed")
+	c.Check(result.Unchanged[0].Interface.ID(), gc.Equals, 40)
+	c.Check(result.Unchanged[1].Interface.ID(), gc.Equals, 50)
+	c.Check(server.LastRequest().Method, gc.Equals, "GET")
+}
+
+func (s *interfaceBulkSuite) TestUpdateInterfacesMTU(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddPutResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/", http.StatusOK, bulkInterfaceJSON(c, 40, 1, "fabric-0"))
+	server.AddPutResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/41/", http.StatusForbidden, "not allowed")
+	server.AddPutResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/50/", http.StatusOK, bulkInterfaceJSON(c, 50, 1, "fabric-0"))
+
+	result, err := controller.UpdateInterfaces(UpdateInterfacesArgs{
+		MTU:     9000,
+		Workers: 2,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result.String(), gc.Equals, "2 updated, 0 unchanged, 1 failed")
+	c.Check(result.Updated[0].Interface.ID(), gc.Equals, 40)
+	c.Check(result.Updated[1].Interface.ID(), gc.Equals, 50)
+	c.Assert(result.Failed, gc.HasLen, 1)
+	c.Check(result.Failed[0].Interface.ID(), gc.Equals, 41)
+	c.Check(result.Failed[0].Err, jc.Satisfies, IsPermissionError)
+	c.Check(result.Failed[0].Err, gc.ErrorMatches, "updating interface eth41: not allowed")
+
+	for _, request := range server.LastNRequests(3) {
+		c.Check(request.Method, gc.Equals, "PUT")
+		c.Check(request.PostForm.Get("mtu"), gc.Equals, "9000")
+	}
+}
+
+func (s *interfaceBulkSuite) TestUpdateInterfacesMachinesError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusInternalServerError, "boom")
+	_, err := controller.UpdateInterfaces(UpdateInterfacesArgs{MTU: 9000})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
@@ -3,7 +3,13 @@
 
 package gomaasapi
 
-import "github.com/juju/utils/set"
+import (
+	"context"
+	"net"
+	"net/url"
+
+	"github.com/juju/utils/set"
+)
 
 const (
 	// Capability constants.
@@ -26,25 +32,142 @@ type Controller interface {
 
 	BootResources() ([]BootResource, error)
 
+	// ArchitectureKernels returns the architecture and kernel combinations
+	// that the boot resources can deploy, to choose a valid architecture
+	// constraint from.
+	ArchitectureKernels() ([]ArchKernel, error)
+
+	// HWEKernels returns the kernels that machines of the architecture can
+	// be deployed with for the series, according to the boot resources.
+	// See the HWEKernels function.
+	HWEKernels(series, arch string) ([]string, error)
+
+	// BootSources returns the sources that the MAAS controller imports boot
+	// images from.
+	BootSources() ([]BootSource, error)
+
+	// CreateBootSource adds a new source of boot images.
+	CreateBootSource(CreateBootSourceArgs) (BootSource, error)
+
 	// Fabrics returns the list of Fabrics defined in the MAAS controller.
 	Fabrics() ([]Fabric, error)
 
 	// Spaces returns the list of Spaces defined in the MAAS controller.
 	Spaces() ([]Space, error)
 
+	// Subnets returns the list of Subnets defined in the MAAS controller.
+	Subnets() ([]Subnet, error)
+
+	// SubnetByCIDR returns the subnet with the CIDR, such as
+	// "10.0.0.0/24". Any address in the subnet may be given in place of
+	// the network address. The subnets are cached, and read again when
+	// the CIDR is not found; a NoMatchError is returned if it is still not
+	// found.
+	SubnetByCIDR(cidr string) (Subnet, error)
+
+	// VLANByFabricAndVID returns the VLAN with the VID on the named fabric.
+	// It is cached in the same way as SubnetByCIDR.
+	VLANByFabricAndVID(fabric string, vid int) (VLAN, error)
+
+	// SpaceByName returns the space with the name. It is cached in the
+	// same way as SubnetByCIDR.
+	SpaceByName(name string) (Space, error)
+
+	// InvalidateNetworkIndex drops the subnets, VLANs and spaces cached
+	// for the lookups above, so that they are read again when next used,
+	// for example after they have been changed or deleted.
+	InvalidateNetworkIndex()
+
+	// PlanSubnets works out subnets of a parent network that do not
+	// overlap the existing subnets, and optionally creates them.
+	PlanSubnets(PlanSubnetsArgs) (SubnetPlan, error)
+
+	// CheckIPConflicts cross-references the addresses assigned to and
+	// discovered on the interfaces of the machines that match the args
+	// with the dynamic ranges of the subnets, reporting the conflicts.
+	CheckIPConflicts(MachinesArgs) ([]IPConflict, error)
+
 	// StaticRoutes returns the list of StaticRoutes defined in the MAAS controller.
 	StaticRoutes() ([]StaticRoute, error)
 
+	// EnableDHCP turns on DHCP for a VLAN. It creates the dynamic range,
+	// then sets the rack controllers and enables DHCP on the VLAN. If the
+	// VLAN cannot be updated, the dynamic range is removed again. The
+	// updated VLAN is returned.
+	EnableDHCP(EnableDHCPArgs) (VLAN, error)
+
 	// Zones lists all the zones known to the MAAS controller.
 	Zones() ([]Zone, error)
 
 	// Machines returns a list of machines that match the params.
 	Machines(MachinesArgs) ([]Machine, error)
 
+	// StreamMachines calls the callback with each machine that matches the
+	// params, decoding the response incrementally so that memory use does
+	// not grow with the number of machines. If the callback returns an
+	// error, no further machines are read and that error is returned.
+	StreamMachines(args MachinesArgs, callback func(Machine) error) error
+
+	// SearchMachines returns the machines that match the filters of the
+	// args. Filters are sent to MAAS where it supports them and applied to
+	// the machines read otherwise; the result says which was done for
+	// each filter.
+	SearchMachines(SearchMachinesArgs) (SearchMachinesResult, error)
+
 	// AllocateMachine will attempt to allocate a machine to the user.
-	// If successful, the allocated machine is returned.
+	// If successful, the allocated machine is returned. If the args have an
+	// IdempotencyKey that cannot be recorded, the allocated machine is
+	// returned along with the error.
 	AllocateMachine(AllocateMachineArgs) (Machine, ConstraintMatches, error)
 
+	// AllocateSpread allocates a number of machines spread as evenly as
+	// possible across zones or resource pools. If not enough machines can
+	// be allocated, those that were are released again.
+	AllocateSpread(AllocateSpreadArgs) ([]Machine, error)
+
+	// ApplyTagRules adds the tag of each rule to the machines that match
+	// its predicate and removes it from those that do not, returning the
+	// changes made for each rule.
+	ApplyTagRules(ApplyTagRulesArgs) ([]TagRuleResult, error)
+
+	// DeployMany deploys the machines described by the specs concurrently,
+	// allocating those without a Machine first, and waits until each one
+	// is deployed, fails to deploy or the context is done. The outcomes are
+	// in the same order as the specs. Machines that fail are not released.
+	// The deploying machines are polled together with one request.
+	DeployMany(context.Context, []MachineSpec) []DeployOutcome
+
+	// DeployWithRetry deploys a machine, allocating it first if needed.
+	// When deployment fails, it allocates a substitute with the same
+	// constraints and deploys that, up to the number of attempts in the
+	// args, optionally releasing the machines that failed. The result
+	// lists every machine tried.
+	DeployWithRetry(context.Context, DeployWithRetryArgs) (DeployRetryResult, error)
+
+	// VMHosts lists the VM hosts (pods) that MAAS can compose machines on.
+	VMHosts() ([]VMHost, error)
+
+	// DeployVMHost deploys the machine as a VM host of the type in the
+	// args, waits until it is deployed and MAAS has registered the VM
+	// host, and returns the VM host. The wait ends with an error if
+	// deployment fails or the context is done.
+	DeployVMHost(ctx context.Context, machine Machine, args StartArgs) (VMHost, error)
+
+	// WatchErasing waits until the machines that are erasing their disks
+	// are Ready or have failed, calling progress, if not nil, with the
+	// events each machine logs and when it finishes. It returns a
+	// CannotCompleteError naming the machines that failed.
+	WatchErasing(ctx context.Context, systemIDs []string, progress func(EraseProgress)) error
+
+	// SelectMachines returns the machines matching the args that are also
+	// selected by the selector expression. See Selector for the syntax.
+	SelectMachines(selector string, args MachinesArgs) ([]Machine, error)
+
+	// UpdateInterfaces moves the selected machine interfaces to a VLAN or
+	// sets their MTU, updating several at once. Failures are reported per
+	// interface in the result.
+	UpdateInterfaces(UpdateInterfacesArgs) (UpdateInterfacesResult, error)
+
 	// ReleaseMachines will stop the specified machines, and release them
 	// from the user making them available to be allocated again.
 	ReleaseMachines(ReleaseMachinesArgs) error
@@ -55,21 +178,174 @@ type Controller interface {
 	// CreateDevice creates and returns a new Device.
 	CreateDevice(CreateDeviceArgs) (Device, error)
 
+	// Account returns the operations on the account of the authenticated
+	// user.
+	Account() Account
+
+	// Nodes returns every node known to MAAS that matches the params,
+	// including machines, devices and rack and region controllers.
+	Nodes(NodesArgs) ([]GenericNode, error)
+
+	// RackControllers returns the rack controllers, including region
+	// controllers that also run the rack services.
+	RackControllers() ([]RackController, error)
+
+	// ImageSyncStatus compares the boot images on each rack controller
+	// with the boot resources of the region. Stale images on a rack are a
+	// common cause of machines failing to PXE boot.
+	ImageSyncStatus() ([]ImageSyncStatus, error)
+
+	// Describe returns the description of the API that the controller
+	// publishes, listing its handlers and the operations they support.
+	Describe() (APIDescription, error)
+
+	// Ping makes a small authenticated request and classifies the result,
+	// for use in health checks and readiness probes. Failures are
+	// described by the result, not returned as errors.
+	Ping(ctx context.Context) PingResult
+
 	// Files returns all the files that match the specified prefix.
 	Files(prefix string) ([]File, error)
 
 	// Return a single file by its filename.
 	GetFile(filename string) (File, error)
 
+	// The Get calls read a single entity by its ID, returning a
+	// NoMatchError if there is none. See ParseSystemID, ParseID and
+	// ParseNodeChildID for reading the IDs from strings.
+	GetMachine(systemID string) (Machine, error)
+	GetDevice(systemID string) (Device, error)
+	GetRackController(systemID string) (RackController, error)
+	GetInterface(NodeChildID) (Interface, error)
+	GetBlockDevice(NodeChildID) (BlockDevice, error)
+	GetSubnet(id int) (Subnet, error)
+	GetFabric(id int) (Fabric, error)
+	GetVLAN(id int) (VLAN, error)
+	GetSpace(id int) (Space, error)
+	GetStaticRoute(id int) (StaticRoute, error)
+	GetVMHost(id int) (VMHost, error)
+	GetBootSource(id int) (BootSource, error)
+	GetBootResource(id int) (BootResource, error)
+	GetZone(name string) (Zone, error)
+
 	// AddFile adds or replaces the content of the specified filename.
 	// If or when the MAAS api is able to return metadata about a single
 	// file without sending the content of the file, we can return a File
 	// instance here too.
 	AddFile(AddFileArgs) error
+
+	// GetConfig returns the value of the named region configuration item.
+	GetConfig(name string) (interface{}, error)
+
+	// SetConfig sets the named region configuration item to the value.
+	// Prefer the typed setters below for the values they cover, as they
+	// validate the value before sending it.
+	SetConfig(name, value string) error
+
+	// NTPServers returns the NTP servers the region uses.
+	NTPServers() ([]string, error)
+
+	// SetNTPServers sets the NTP servers, which may be hostnames or IP
+	// addresses.
+	SetNTPServers([]string) error
+
+	// UpstreamDNS returns the DNS servers that MAAS forwards queries to.
+	UpstreamDNS() ([]string, error)
+
+	// SetUpstreamDNS sets the DNS forwarders, which must be IP addresses.
+	SetUpstreamDNS([]string) error
+
+	// DNSSECValidation returns the DNSSEC validation mode.
+	DNSSECValidation() (DNSSECValidation, error)
+
+	// SetDNSSECValidation sets the DNSSEC validation mode.
+	SetDNSSECValidation(DNSSECValidation) error
+
+	// RemoteSyslog returns the host[:port] that machines send their syslog
+	// to, or the empty string if remote logging is not configured.
+	RemoteSyslog() (string, error)
+
+	// SetRemoteSyslog sets the host[:port] for remote syslog. An empty value
+	// disables remote logging.
+	SetRemoteSyslog(hostPort string) error
+
+	// CurtinVerbose returns whether curtin runs with verbose output.
+	CurtinVerbose() (bool, error)
+
+	// SetCurtinVerbose sets whether curtin runs with verbose output, which
+	// adds detail to the installation log of deployed machines.
+	SetCurtinVerbose(bool) error
+
+	// KernelOptions returns the kernel options MAAS passes to every node
+	// it boots.
+	KernelOptions() (string, error)
+
+	// SetKernelOptions sets the global kernel options. An empty value
+	// clears them.
+	SetKernelOptions(string) error
+}
+
+// MultiController holds Controllers for several MAAS regions, for
+// organizations that run more than one MAAS install, and fans calls out to
+// all of them at once. A failure in one region is reported for that region
+// without stopping the others.
+type MultiController interface {
+	// Regions returns the names of the regions, in the order given.
+	Regions() []string
+
+	// Region returns the Controller of the named region, or nil if there
+	// is no such region.
+	Region(name string) Controller
+
+	// Each calls the function with the Controller of each region
+	// concurrently, and returns the errors of the regions where it failed.
+	Each(func(region string, c Controller) error) []RegionError
+
+	// Machines lists the machines matching the args in every region.
+	Machines(MachinesArgs) ([]RegionMachine, []RegionError)
+
+	// FindMachinesByHostname returns the machines with the hostname in any
+	// region. As regions are separate installs, more than one may have a
+	// machine with the hostname.
+	FindMachinesByHostname(hostname string) ([]RegionMachine, []RegionError)
+}
+
+// VMHost represents a machine that MAAS composes virtual machines on, also
+// known as a pod.
+type VMHost interface {
+	ResourceCaller
+
+	ID() int
+	Name() string
+
+	// Type is the hypervisor of the VM host, such as "virsh" or "lxd".
+	Type() string
+
+	// HostSystemID is the system ID of the machine the VM host was
+	// deployed on, or the empty string if it was added by address.
+	HostSystemID() string
+
+	Tags() []string
+
+	// NUMANodes returns the NUMA layout of the host, used to pin the cores
+	// and back the memory of composed VMs with hugepages. It is empty for
+	// MAAS versions before 2.9.
+	NUMANodes() []VMHostNUMANode
+
+	// ComposeAndDeploy composes a VM on the host, waits for MAAS to
+	// commission it, then allocates and deploys it with the start args of
+	// the spec, waiting until it is deployed. The wait ends with an error
+	// if commissioning or deployment fails or the context is done. The
+	// machine, once composed, is returned even on error; it is not
+	// removed again.
+	ComposeAndDeploy(ctx context.Context, spec ComposeSpec) (Machine, error)
 }
 
 // File represents a file stored in the MAAS controller.
 type File interface {
+	RawEntity
+	ResourceCaller
+
 	// Filename is the name of the file. No path, just the filename.
 	Filename() string
 
@@ -92,6 +368,8 @@ type File interface {
 // VLAN 100, while a separate fabric in San Francisco may contain a VLAN 100,
 // whose attached subnets are completely different and unrelated.
 type Fabric interface {
+	RawEntity
+
 	ID() int
 	Name() string
 	ClassType() string
@@ -118,10 +396,16 @@ type Fabric interface {
 // object in the fabric will be associated to by default (unless otherwise
 // specified).
 type VLAN interface {
+	RawEntity
+
 	ID() int
 	Name() string
 	Fabric() string
 
+	// ExpandedFabric returns the fabric named by Fabric. It is nil unless
+	// the VLAN was read with reference expansion enabled.
+	ExpandedFabric() Fabric
+
 	// VID is the VLAN ID. eth0.10 -> VID = 10.
 	VID() int
 	// MTU (maximum transmission unit) is the largest size packet or frame,
@@ -138,12 +422,16 @@ type VLAN interface {
 // or a data centre. Users can then allocate nodes from specific physical zones,
 // to suit their redundancy or performance requirements.
 type Zone interface {
+	RawEntity
+
 	Name() string
 	Description() string
 }
 
 // BootResource is the bomb... find something to say here.
 type BootResource interface {
+	RawEntity
+
 	ID() int
 	Name() string
 	Type() string
@@ -152,8 +440,162 @@ type BootResource interface {
 	KernelFlavor() string
 }
 
+// BootSource is a location from which the MAAS controller imports boot
+// images, usually a simplestreams mirror.
+type BootSource interface {
+	RawEntity
+	ResourceCaller
+
+	ID() int
+	URL() string
+	KeyringFilename() string
+	// KeyringData is the base64 encoded GPG keyring used to verify the
+	// source. It is empty if KeyringFilename is used instead.
+	KeyringData() string
+
+	// Selections returns the images that are imported from this source.
+	Selections() ([]BootSourceSelection, error)
+
+	// CreateSelection adds an image selection to this source.
+	CreateSelection(CreateBootSourceSelectionArgs) (BootSourceSelection, error)
+
+	// Update the URL or keyring filename of the source.
+	Update(UpdateBootSourceArgs) error
+
+	// Delete removes the source, and all of its selections.
+	Delete() error
+}
+
+// BootSourceSelection identifies a set of images to import from a
+// BootSource.
+type BootSourceSelection interface {
+	RawEntity
+
+	ID() int
+	BootSourceID() int
+	OS() string
+	Release() string
+	// Arches, Subarches and Labels may contain "*" to select all values.
+	Arches() []string
+	Subarches() []string
+	Labels() []string
+
+	// Update the values of the selection.
+	Update(UpdateBootSourceSelectionArgs) error
+
+	// Delete removes the selection from its BootSource.
+	Delete() error
+}
+
+// Account manages the API tokens of the authenticated user.
+type Account interface {
+	// CreateToken creates a new API token. The name is optional.
+	CreateToken(name string) (AuthorisationToken, error)
+
+	// DeleteToken revokes the token with the given token key. Deleting the
+	// token used by the Controller ends its access.
+	DeleteToken(tokenKey string) error
+
+	// Tokens returns all the tokens of the user.
+	Tokens() ([]AuthorisationToken, error)
+}
+
+// AuthorisationToken is an OAuth token that authenticates with the MAAS API.
+type AuthorisationToken interface {
+	Name() string
+	ConsumerKey() string
+	TokenKey() string
+	TokenSecret() string
+
+	// APIKey returns the keys and secret joined in the form used for
+	// ControllerArgs.APIKey.
+	APIKey() string
+}
+
+// APIDescription is the description of the MAAS API returned by
+// Controller.Describe. It allows generic tools to discover the operations
+// available at runtime.
+type APIDescription interface {
+	Doc() string
+
+	// Hash identifies the version of the description. It is empty for
+	// controllers that do not report one.
+	Hash() string
+
+	Resources() []APIResource
+
+	// Resource returns the resource with the given name, such as
+	// "MachinesHandler", or nil if there is no such resource.
+	Resource(name string) APIResource
+}
+
+// APIResource is a part of the API, served by different handlers depending
+// on whether the request is authenticated.
+type APIResource interface {
+	Name() string
+
+	// Anonymous returns the handler for unauthenticated requests, or nil
+	// if the resource requires authentication.
+	Anonymous() APIHandler
+
+	// Authenticated returns the handler for authenticated requests, or nil
+	// if the resource is only available anonymously.
+	Authenticated() APIHandler
+}
+
+// APIHandler serves the requests for a path of the API.
+type APIHandler interface {
+	Name() string
+	Doc() string
+
+	// Path is the path template of the handler, such as
+	// "/MAAS/api/2.0/machines/{system_id}/".
+	Path() string
+	URI() string
+
+	// Params are the names of the parameters in the path template.
+	Params() []string
+
+	Actions() []APIAction
+
+	// Action returns the action for the HTTP method and op, which is empty
+	// for restful actions, or nil if the handler has no such action.
+	Action(method, op string) APIAction
+}
+
+// APIAction is an operation supported by an APIHandler.
+type APIAction interface {
+	Name() string
+
+	// Op is the value of the "op" parameter that selects the action. It
+	// is empty for restful actions, which are selected by method alone.
+	Op() string
+	Method() string
+	Doc() string
+	Restful() bool
+}
+
+// GenericNode is the common view of anything MAAS manages: machines,
+// devices and controllers. The NodeType says which of these it is.
+type GenericNode interface {
+	RawEntity
+	ResourceCaller
+
+	SystemID() string
+	Hostname() string
+	FQDN() string
+	NodeType() NodeType
+	IPAddresses() []string
+
+	// Zone returns nil if MAAS did not report a zone for the node.
+	Zone() Zone
+}
+
 // Device represents some form of device in MAAS.
 type Device interface {
+	RawEntity
+	ResourceCaller
+
 	// TODO: add domain
 	SystemID() string
 	Hostname() string
@@ -180,6 +622,9 @@ type Device interface {
 
 // Machine represents a physical machine.
 type Machine interface {
+	RawEntity
+	ResourceCaller
+
 	OwnerDataHolder
 
 	SystemID() string
@@ -193,7 +638,23 @@ type Machine interface {
 	Memory() int
 	CPUCount() int
 
+	// HWEKernel is the kernel the machine is deployed with, such as
+	// "hwe-16.04". It is empty for machines that are not deployed.
+	HWEKernel() string
+
+	// MinHWEKernel is the oldest kernel the machine may be deployed with,
+	// or empty if there is no minimum.
+	MinHWEKernel() string
+
+	// SetMinHWEKernel sets the oldest kernel the machine may be deployed
+	// with, for hardware that older kernels do not support. An empty
+	// kernel removes the minimum.
+	SetMinHWEKernel(kernel string) error
+
 	IPAddresses() []string
+
+	// PowerState is the power state reported by MAAS, such as "on". Use
+	// ParsePowerState for a PowerState.
 	PowerState() string
 
 	// Devices returns a list of devices that match the params and have
@@ -224,6 +685,11 @@ type Machine interface {
 	// BlockDevices returns all the physical and virtual block devices on the machine.
 	BlockDevices() []BlockDevice
 
+	// MatchesProfile compares the hardware of the machine with the
+	// profile, returning how it differs. The machine matches the profile
+	// if there are no mismatches.
+	MatchesProfile(Profile) []ProfileMismatch
+
 	Zone() Zone
 
 	// Start the machine and install the operating system specified in the args.
@@ -232,10 +698,120 @@ type Machine interface {
 	// CreateDevice creates a new Device with this Machine as the parent.
 	// The device will have one interface that is linked to the specified subnet.
 	CreateDevice(CreateMachineDeviceArgs) (Device, error)
+
+	// SetStorageLayout replaces the storage configuration of the machine
+	// with one of the standard layouts. The machine must be Ready; if it
+	// is not, a CannotCompleteError is returned.
+	SetStorageLayout(StorageLayout, StorageLayoutOptions) error
+
+	// InstallationResults returns the output recorded while the operating
+	// system was installed, including the installer console output and
+	// the curtin logs.
+	InstallationResults() ([]InstallationResult, error)
+
+	// InstallationLog returns the console output of the most recent
+	// installation. A NoMatchError is returned if there is none.
+	InstallationLog() ([]byte, error)
+
+	// Events returns the most recent entries in the machine's event log,
+	// newest first. If limit is zero the server's default is used.
+	Events(limit int) ([]Event, error)
+
+	// CurtinConfig returns the rendered curtin configuration, as YAML, that
+	// MAAS uses to install the machine. This includes the preseed and any
+	// curtin_userdata customisations. MAAS only renders the configuration
+	// for machines that are deploying or deployed; for other machines a
+	// BadRequestError is returned.
+	CurtinConfig() ([]byte, error)
+
+	// FailureInfo gathers the status, recent events and installer output
+	// of a machine whose status is one of the Failed states. A NotValid
+	// error is returned for machines that have not failed.
+	FailureInfo() (FailureInfo, error)
+
+	// PowerCycle powers the machine off, queries the BMC until it reports
+	// the machine off, powers it on and queries until it reports it on.
+	// Each query waits up to two minutes. A failure is returned as a
+	// PowerCycleError naming the stage that failed.
+	PowerCycle(ctx context.Context, args PowerCycleArgs) error
+
+	// SetHostname renames the machine. The name must be a single DNS
+	// label; the domain, if not empty, moves the machine into that domain.
+	// Invalid names are reported as an ArgumentError before any request is
+	// made.
+	SetHostname(name, domain string) error
+
+	// Update changes the attributes of the machine that are set in the
+	// args, and leaves the others as they are. Invalid values are reported
+	// as an ArgumentError before any request is made.
+	Update(args UpdateMachineArgs) error
+
+	// MarkBroken marks the machine Broken, recording the reason in its
+	// event log, so that it is not allocated until it is fixed. A
+	// CannotCompleteError is returned if its status does not allow it.
+	MarkBroken(reason string) error
+
+	// MarkFixed returns a Broken machine to Ready. The comment, if not
+	// empty, is recorded in the machine's event log. A CannotCompleteError
+	// is returned if the machine is not Broken.
+	MarkFixed(comment string) error
+
+	// Abort stops the current operation of the machine, such as
+	// commissioning, deploying or disk erasing. The comment, if not empty,
+	// is recorded in the machine's event log. A CannotCompleteError is
+	// returned if there is no operation to stop.
+	Abort(comment string) error
+}
+
+// RackController represents a rack controller, which serves DHCP, TFTP and
+// HTTP to the machines on the networks it is connected to.
+type RackController interface {
+	RawEntity
+	ResourceCaller
+
+	SystemID() string
+	Hostname() string
+	FQDN() string
+	IPAddresses() []string
+
+	// Version is the version of MAAS the rack controller runs. It is empty
+	// for versions of MAAS that do not report it.
+	Version() string
+
+	// Services returns the services that MAAS monitors on the rack
+	// controller, such as "dhcpd", "tftp" and "http".
+	Services() []Service
+
+	// Service returns the service with the name, or nil if there is none.
+	Service(name string) Service
+
+	// UnhealthyServices returns the services that are neither running nor
+	// deliberately off.
+	UnhealthyServices() []Service
+
+	// BootImages returns the boot images that the rack controller has, and
+	// whether they are in sync with the region.
+	BootImages() (RackBootImages, error)
+}
+
+// Service is a service that MAAS monitors on a controller.
+type Service interface {
+	Name() string
+
+	// Status is one of ServiceRunning, ServiceDegraded, ServiceDead,
+	// ServiceOff or ServiceUnknown.
+	Status() string
+
+	// StatusInfo explains the status, for example why a service is
+	// degraded. It is often empty for running services.
+	StatusInfo() string
 }
 
 // Space is a name for a collection of Subnets.
 type Space interface {
+	RawEntity
+	ResourceCaller
+
 	ID() int
 	Name() string
 	Subnets() []Subnet
@@ -243,11 +819,18 @@ type Space interface {
 
 // Subnet refers to an IP range on a VLAN.
 type Subnet interface {
+	RawEntity
+	ResourceCaller
+
 	ID() int
 	Name() string
 	Space() string
 	VLAN() VLAN
 
+	// ExpandedSpace returns the space named by Space. It is nil unless the
+	// subnet was read with reference expansion enabled.
+	ExpandedSpace() Space
+
 	Gateway() string
 	CIDR() string
 	// dns_mode
@@ -255,11 +838,30 @@ type Subnet interface {
 	// DNSServers is a list of ip addresses of the DNS servers for the subnet.
 	// This list may be empty.
 	DNSServers() []string
+
+	// Statistics returns the address usage of the subnet.
+	Statistics() (SubnetStatistics, error)
+
+	// UnreservedIPRanges returns the ranges of addresses in the subnet that
+	// are neither reserved nor in use.
+	UnreservedIPRanges() ([]IPRange, error)
+
+	// ReservedIPRanges returns the ranges of addresses in the subnet that
+	// are reserved or in use, along with the purpose of each range.
+	ReservedIPRanges() ([]IPRange, error)
+
+	// ForEachUsableIP calls the callback with each address in the subnet
+	// that is not in a reserved or dynamic range, or otherwise in use,
+	// according to the MAAS controller. If the callback returns an error,
+	// iteration stops and that error is returned.
+	ForEachUsableIP(callback func(net.IP) error) error
 }
 
 // StaticRoute defines an explicit route that users have requested to be added
 // for a given subnet.
 type StaticRoute interface {
+	RawEntity
+
 	// Source is the subnet that should have the route configured. (Machines
 	// inside Source should use GatewayIP to reach Destination addresses.)
 	Source() Subnet
@@ -277,6 +879,9 @@ type StaticRoute interface {
 
 // Interface represents a physical or virtual network interface on a Machine.
 type Interface interface {
+	RawEntity
+	ResourceCaller
+
 	ID() int
 	Name() string
 	// The parents of an interface are the names of interfaces that must exist
@@ -296,10 +901,29 @@ type Interface interface {
 	MACAddress() string
 	EffectiveMTU() int
 
+	// LinkSpeed is the speed the link negotiated, and InterfaceSpeed the
+	// fastest the interface supports, both in Mbit/s. They are zero when
+	// MAAS does not know them.
+	LinkSpeed() int
+	InterfaceSpeed() int
+
+	// Discovered returns the addresses that MAAS has observed on the
+	// interface, such as DHCP leases, as opposed to those it configured.
+	Discovered() []DiscoveredAddress
+
+	// DHCPLeases returns the discovered addresses on the subnets of the
+	// interface's DHCP links.
+	DHCPLeases() []DiscoveredAddress
+
+	// AddressDrift compares the discovered addresses with the links. If
+	// nothing has been discovered on the interface there is nothing to
+	// compare, and the drift is empty.
+	AddressDrift() AddressDrift
+
 	// Params is a JSON field, and defaults to an empty string, but is almost
 	// always a JSON object in practice. Gleefully ignoring it until we need it.
 
-	// Update the name, mac address or VLAN.
+	// Update the name, mac address, VLAN or MTU.
 	Update(UpdateInterfaceArgs) error
 
 	// Delete this interface.
@@ -316,6 +940,8 @@ type Interface interface {
 
 // Link represents a network link between an Interface and a Subnet.
 type Link interface {
+	RawEntity
+
 	ID() int
 	Mode() string
 	Subnet() Subnet
@@ -324,8 +950,21 @@ type Link interface {
 	IPAddress() string
 }
 
+// DiscoveredAddress is an address that MAAS observed on an interface, for
+// example from a DHCP lease or from traffic seen by a rack controller.
+type DiscoveredAddress interface {
+	RawEntity
+
+	// Subnet is the subnet containing the address, or nil if MAAS does
+	// not know of one.
+	Subnet() Subnet
+	IPAddress() string
+}
+
 // FileSystem represents a formatted filesystem mounted at a location.
 type FileSystem interface {
+	RawEntity
+
 	// Type is the format type, e.g. "ext4".
 	Type() string
 
@@ -337,6 +976,8 @@ type FileSystem interface {
 // Partition represents a partition of a block device. It may be mounted
 // as a filesystem.
 type Partition interface {
+	RawEntity
+
 	ID() int
 	Path() string
 	// FileSystem may be nil if not mounted.
@@ -350,6 +991,8 @@ type Partition interface {
 
 // BlockDevice represents an entire block device on the machine.
 type BlockDevice interface {
+	RawEntity
+
 	ID() int
 	Name() string
 	Model() string
@@ -367,6 +1010,33 @@ type BlockDevice interface {
 	// expose them on an as needed basis.
 }
 
+// RawEntity is implemented by the entities read from the MAAS controller.
+// It gives access to the fields that MAAS sends but the library does not
+// model yet.
+type RawEntity interface {
+	// Raw returns the JSON object that MAAS sent for the entity. Nested
+	// entities, such as the interfaces of a machine, are included.
+	Raw() []byte
+}
+
+// ResourceCaller is an entity that has its own resource URI in the MAAS
+// API. It lets operations that this package does not wrap be called on an
+// entity that is already held, without building its URL.
+//
+// Fabrics, VLANs, zones, block devices, partitions and static routes do
+// not implement it. They are also read nested in other entities, such as
+// the VLAN of a subnet or the zone of a machine, where they are not tied
+// to a Controller.
+type ResourceCaller interface {
+	// CallGet sends a GET to the resource URI of the entity with the op,
+	// which may be empty, and the params, and returns the response.
+	CallGet(op string, params url.Values) (JSONObject, error)
+
+	// CallPost sends a POST to the resource URI of the entity with the
+	// op, which may be empty, and the params, and returns the response.
+	CallPost(op string, params url.Values) (JSONObject, error)
+}
+
 // OwnerDataHolder represents any MAAS object that can store key/value
 // data.
 type OwnerDataHolder interface {
@@ -0,0 +1,265 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// IPConflictKind is the type of the constants that say what an IPConflict
+// is.
+type IPConflictKind string
+
+const (
+	// ConflictOverlappingRanges - two dynamic ranges of a subnet overlap.
+	ConflictOverlappingRanges IPConflictKind = "overlapping-dynamic-ranges"
+
+	// ConflictAssignedInDynamicRange - an address assigned to an interface
+	// is in a dynamic range, so the DHCP server may lease it to another.
+	ConflictAssignedInDynamicRange IPConflictKind = "assigned-in-dynamic-range"
+
+	// ConflictDuplicateAssignment - an address is assigned to more than
+	// one interface.
+	ConflictDuplicateAssignment IPConflictKind = "duplicate-assignment"
+
+	// ConflictDiscoveredElsewhere - an address assigned to one interface
+	// has been observed on another.
+	ConflictDiscoveredElsewhere IPConflictKind = "discovered-elsewhere"
+)
+
+// IPHolder identifies a machine interface involved in an IPConflict.
+type IPHolder struct {
+	SystemID  string
+	Interface string
+}
+
+func (h IPHolder) String() string {
+	return h.SystemID + ":" + h.Interface
+}
+
+// IPConflict is a finding of CheckIPConflicts.
+type IPConflict struct {
+	Kind IPConflictKind
+
+	// IPAddress is the address in conflict. It is empty for overlapping
+	// ranges.
+	IPAddress string
+
+	// CIDR is the subnet of the address or ranges, if known.
+	CIDR string
+
+	// Assigned are the interfaces the address is assigned to, and
+	// Discovered those it was observed on without being assigned.
+	Assigned   []IPHolder
+	Discovered []IPHolder
+
+	// Ranges are the dynamic ranges involved.
+	Ranges []IPRange
+}
+
+// CheckIPConflicts implements Controller.
+//
+// The addresses assigned to the machines' interfaces, other than by DHCP,
+// are checked against each other, against the dynamic ranges of their
+// subnets and against the addresses discovered on the other interfaces.
+// The findings are grouped by kind, in the order of the kind constants.
+func (c *controller) CheckIPConflicts(args MachinesArgs) ([]IPConflict, error) {
+	machines, err := c.Machines(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	subnets, err := c.Subnets()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	dynamic := make(map[string][]IPRange)
+	for _, subnet := range subnets {
+		reserved, err := subnet.ReservedIPRanges()
+		if err != nil {
+			return nil, errors.Annotatef(err, "subnet %s", subnet.CIDR())
+		}
+		for _, r := range reserved {
+			if isDynamicRange(r) {
+				dynamic[subnet.CIDR()] = append(dynamic[subnet.CIDR()], r)
+			}
+		}
+	}
+	cidrs := make([]string, len(subnets))
+	for i, subnet := range subnets {
+		cidrs[i] = subnet.CIDR()
+	}
+	return findIPConflicts(machines, cidrs, dynamic), nil
+}
+
+func isDynamicRange(r IPRange) bool {
+	for _, purpose := range r.Purpose {
+		if purpose == "dynamic" {
+			return true
+		}
+	}
+	return false
+}
+
+// ipUse is an address assigned to, or discovered on, an interface.
+type ipUse struct {
+	holder  IPHolder
+	address string
+	cidr    string
+}
+
+// findIPConflicts works out the conflicts between the addresses of the
+// machines and the dynamic ranges of the subnets, keyed by CIDR. The
+// ranges of a subnet are checked in the order of the cidrs.
+func findIPConflicts(machines []Machine, cidrs []string, dynamic map[string][]IPRange) []IPConflict {
+	var assigned, discovered []ipUse
+	for _, m := range machines {
+		for _, iface := range m.InterfaceSet() {
+			holder := IPHolder{SystemID: m.SystemID(), Interface: iface.Name()}
+			for _, link := range iface.Links() {
+				if link.IPAddress() == "" || strings.EqualFold(link.Mode(), string(LinkModeDHCP)) {
+					continue
+				}
+				assigned = append(assigned, ipUse{holder, link.IPAddress(), subnetCIDR(link.Subnet())})
+			}
+			for _, address := range iface.Discovered() {
+				if address.IPAddress() != "" {
+					discovered = append(discovered, ipUse{holder, address.IPAddress(), subnetCIDR(address.Subnet())})
+				}
+			}
+		}
+	}
+
+	var conflicts []IPConflict
+	for _, cidr := range cidrs {
+		conflicts = append(conflicts, overlappingRanges(cidr, dynamic[cidr])...)
+	}
+
+	for _, use := range assigned {
+		ip := net.ParseIP(use.address)
+		for _, r := range dynamic[use.cidr] {
+			if ip != nil && r.Contains(ip) {
+				conflicts = append(conflicts, IPConflict{
+					Kind:      ConflictAssignedInDynamicRange,
+					IPAddress: use.address,
+					CIDR:      use.cidr,
+					Assigned:  []IPHolder{use.holder},
+					Ranges:    []IPRange{r},
+				})
+			}
+		}
+	}
+
+	byAddress := make(map[string][]ipUse)
+	var addresses []string
+	for _, use := range assigned {
+		key := canonicalIP(use.address)
+		if _, ok := byAddress[key]; !ok {
+			addresses = append(addresses, key)
+		}
+		byAddress[key] = append(byAddress[key], use)
+	}
+	for _, address := range addresses {
+		uses := byAddress[address]
+		if len(uses) < 2 {
+			continue
+		}
+		conflict := IPConflict{
+			Kind:      ConflictDuplicateAssignment,
+			IPAddress: uses[0].address,
+			CIDR:      uses[0].cidr,
+		}
+		for _, use := range uses {
+			conflict.Assigned = append(conflict.Assigned, use.holder)
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	for _, address := range addresses {
+		uses := byAddress[address]
+		var elsewhere []IPHolder
+		for _, seen := range discovered {
+			if canonicalIP(seen.address) == address && !heldBy(uses, seen.holder) {
+				elsewhere = append(elsewhere, seen.holder)
+			}
+		}
+		if len(elsewhere) == 0 {
+			continue
+		}
+		conflict := IPConflict{
+			Kind:       ConflictDiscoveredElsewhere,
+			IPAddress:  uses[0].address,
+			CIDR:       uses[0].cidr,
+			Discovered: elsewhere,
+		}
+		for _, use := range uses {
+			conflict.Assigned = append(conflict.Assigned, use.holder)
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
+
+// overlappingRanges returns a conflict for each pair of overlapping
+// ranges.
+func overlappingRanges(cidr string, ranges []IPRange) []IPConflict {
+	type bound struct {
+		start, end net.IP
+		r          IPRange
+	}
+	var bounds []bound
+	for _, r := range ranges {
+		start, end := net.ParseIP(r.Start), net.ParseIP(r.End)
+		if start == nil || end == nil {
+			continue
+		}
+		bounds = append(bounds, bound{start.To16(), end.To16(), r})
+	}
+	sort.SliceStable(bounds, func(i, j int) bool {
+		return bytes.Compare(bounds[i].start, bounds[j].start) < 0
+	})
+	var conflicts []IPConflict
+	for i := range bounds {
+		for j := i + 1; j < len(bounds); j++ {
+			if bytes.Compare(bounds[j].start, bounds[i].end) > 0 {
+				break
+			}
+			conflicts = append(conflicts, IPConflict{
+				Kind:   ConflictOverlappingRanges,
+				CIDR:   cidr,
+				Ranges: []IPRange{bounds[i].r, bounds[j].r},
+			})
+		}
+	}
+	return conflicts
+}
+
+func heldBy(uses []ipUse, holder IPHolder) bool {
+	for _, use := range uses {
+		if use.holder == holder {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalIP returns the address in its canonical form, so that the
+// different ways of writing an IPv6 address compare equal.
+func canonicalIP(address string) string {
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String()
+	}
+	return address
+}
+
+func subnetCIDR(subnet Subnet) string {
+	if subnet == nil {
+		return ""
+	}
+	return subnet.CIDR()
+}
@@ -0,0 +1,204 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type ipConflictSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&ipConflictSuite{})
+
+const conflictCIDR = "192.168.100.0/24"
+
+// conflictMachine returns the machine of machineResponse with the system
+// ID, where eth0 has 192.168.100.4 and eth1 has 192.168.100.5, after
+// calling modify with its interfaces.
+func conflictMachine(c *gc.C, systemID string, modify func(eth0, eth1 map[string]interface{})) map[string]interface{} {
+	source := parseJSON(c, machineResponse).(map[string]interface{})
+	source["system_id"] = systemID
+	interfaces := source["interface_set"].([]interface{})
+	eth0 := interfaces[0].(map[string]interface{})
+	eth1 := interfaces[1].(map[string]interface{})
+	eth1["name"] = "eth1"
+	if modify != nil {
+		modify(eth0, eth1)
+	}
+	return source
+}
+
+func setLinkAddress(iface map[string]interface{}, address string) {
+	link := iface["links"].([]interface{})[0].(map[string]interface{})
+	link["ip_address"] = address
+}
+
+func setLinkMode(iface map[string]interface{}, mode string) {
+	link := iface["links"].([]interface{})[0].(map[string]interface{})
+	link["mode"] = mode
+}
+
+func addDiscovered(iface map[string]interface{}, address string) {
+	iface["discovered"] = []interface{}{map[string]interface{}{
+		"ip_address": address,
+		"subnet":     iface["links"].([]interface{})[0].(map[string]interface{})["subnet"],
+	}}
+}
+
+func readConflictMachines(c *gc.C, sources ...map[string]interface{}) []Machine {
+	list := make([]interface{}, len(sources))
+	for i, source := range sources {
+		list[i] = source
+	}
+	machines, err := readMachines(twoDotOh, list)
+	c.Assert(err, jc.ErrorIsNil)
+	result := make([]Machine, len(machines))
+	for i, m := range machines {
+		result[i] = m
+	}
+	return result
+}
+
+func dynamicRange(start, end string) IPRange {
+	return IPRange{Start: start, End: end, Purpose: []string{"dynamic"}}
+}
+
+func (*ipConflictSuite) TestNoConflicts(c *gc.C) {
+	machines := readConflictMachines(c, conflictMachine(c, "a", nil))
+	dynamic := map[string][]IPRange{
+		conflictCIDR: {dynamicRange("192.168.100.30", "192.168.100.94")},
+	}
+	c.Check(findIPConflicts(machines, []string{conflictCIDR}, dynamic), gc.HasLen, 0)
+}
+
+func (*ipConflictSuite) TestAssignedInDynamicRange(c *gc.C) {
+	machines := readConflictMachines(c, conflictMachine(c, "a", nil))
+	r := dynamicRange("192.168.100.5", "192.168.100.10")
+	conflicts := findIPConflicts(machines, []string{conflictCIDR}, map[string][]IPRange{conflictCIDR: {r}})
+	c.Check(conflicts, jc.DeepEquals, []IPConflict{{
+		Kind:      ConflictAssignedInDynamicRange,
+		IPAddress: "192.168.100.5",
+		CIDR:      conflictCIDR,
+		Assigned:  []IPHolder{{SystemID: "a", Interface: "eth1"}},
+		Ranges:    []IPRange{r},
+	}})
+}
+
+func (*ipConflictSuite) TestDHCPLinkInDynamicRange(c *gc.C) {
+	machines := readConflictMachines(c, conflictMachine(c, "a", func(eth0, eth1 map[string]interface{}) {
+		setLinkMode(eth1, "dhcp")
+	}))
+	r := dynamicRange("192.168.100.5", "192.168.100.10")
+	conflicts := findIPConflicts(machines, []string{conflictCIDR}, map[string][]IPRange{conflictCIDR: {r}})
+	c.Check(conflicts, gc.HasLen, 0)
+}
+
+func (*ipConflictSuite) TestDuplicateAssignment(c *gc.C) {
+	machines := readConflictMachines(c,
+		conflictMachine(c, "a", nil),
+		conflictMachine(c, "b", func(eth0, eth1 map[string]interface{}) {
+			setLinkAddress(eth1, "192.168.100.6")
+		}),
+	)
+	conflicts := findIPConflicts(machines, []string{conflictCIDR}, nil)
+	c.Check(conflicts, jc.DeepEquals, []IPConflict{{
+		Kind:      ConflictDuplicateAssignment,
+		IPAddress: "192.168.100.4",
+		CIDR:      conflictCIDR,
+		Assigned: []IPHolder{
+			{SystemID: "a", Interface: "eth0"},
+			{SystemID: "b", Interface: "eth0"},
+		},
+	}})
+	c.Check(conflicts[0].Assigned[1].String(), gc.Equals, "b:eth0")
+}
+
+func (*ipConflictSuite) TestDiscoveredElsewhere(c *gc.C) {
+	machines := readConflictMachines(c,
+		conflictMachine(c, "a", func(eth0, eth1 map[string]interface{}) {
+			// Seeing its own address is expected.
+			addDiscovered(eth0, "192.168.100.4")
+		}),
+		conflictMachine(c, "b", func(eth0, eth1 map[string]interface{}) {
+			setLinkAddress(eth0, "192.168.100.7")
+			setLinkAddress(eth1, "192.168.100.8")
+			addDiscovered(eth1, "192.168.100.5")
+		}),
+	)
+	conflicts := findIPConflicts(machines, []string{conflictCIDR}, nil)
+	c.Check(conflicts, jc.DeepEquals, []IPConflict{{
+		Kind:       ConflictDiscoveredElsewhere,
+		IPAddress:  "192.168.100.5",
+		CIDR:       conflictCIDR,
+		Assigned:   []IPHolder{{SystemID: "a", Interface: "eth1"}},
+		Discovered: []IPHolder{{SystemID: "b", Interface: "eth1"}},
+	}})
+}
+
+func (*ipConflictSuite) TestOverlappingRanges(c *gc.C) {
+	first := dynamicRange("192.168.100.100", "192.168.100.150")
+	second := dynamicRange("192.168.100.140", "192.168.100.200")
+	third := dynamicRange("192.168.100.201", "192.168.100.210")
+	conflicts := findIPConflicts(nil, []string{conflictCIDR}, map[string][]IPRange{
+		conflictCIDR: {second, third, first},
+	})
+	c.Check(conflicts, jc.DeepEquals, []IPConflict{{
+		Kind:   ConflictOverlappingRanges,
+		CIDR:   conflictCIDR,
+		Ranges: []IPRange{first, second},
+	}})
+}
+
+func (s *ipConflictSuite) TestCheckIPConflicts(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	server.AddGetResponse("/MAAS/api/2.0/subnets/1/?op=reserved_ip_ranges", http.StatusOK, `[
+		{"start": "192.168.100.1", "end": "192.168.100.1", "num_addresses": 1, "purpose": ["gateway-ip"]},
+		{"start": "192.168.100.5", "end": "192.168.100.9", "num_addresses": 5, "purpose": ["dynamic"]}
+	]`)
+	server.AddGetResponse("/MAAS/api/2.0/subnets/34/?op=reserved_ip_ranges", http.StatusOK, "[]")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	conflicts, err := controller.CheckIPConflicts(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(conflicts, gc.HasLen, 1)
+	c.Check(conflicts[0].Kind, gc.Equals, ConflictAssignedInDynamicRange)
+	c.Check(conflicts[0].IPAddress, gc.Equals, "192.168.100.5")
+	c.Check(conflicts[0].Ranges[0].Start, gc.Equals, "192.168.100.5")
+}
+
+func (s *ipConflictSuite) TestCheckIPConflictsRangesFail(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	server.AddGetResponse("/MAAS/api/2.0/subnets/1/?op=reserved_ip_ranges", http.StatusForbidden, "nope")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = controller.CheckIPConflicts(MachinesArgs{})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Check(err, gc.ErrorMatches, "subnet 192.168.100.0/24: nope")
+}
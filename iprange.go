@@ -0,0 +1,128 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"net"
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+// Contains returns true if the address is within the range.
+func (r IPRange) Contains(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	start, end, ok := r.bounds(len(ip))
+	if !ok {
+		return false
+	}
+	return bytes.Compare(ip, start) >= 0 && bytes.Compare(ip, end) <= 0
+}
+
+// bounds returns the start and end of the range as addresses of the given
+// length, or false if the range is malformed or of the other IP family.
+func (r IPRange) bounds(length int) (net.IP, net.IP, bool) {
+	start, end := net.ParseIP(r.Start), net.ParseIP(r.End)
+	if start == nil || end == nil {
+		return nil, nil, false
+	}
+	if length == net.IPv4len {
+		start, end = start.To4(), end.To4()
+		if start == nil || end == nil {
+			return nil, nil, false
+		}
+	} else if start.To4() != nil || end.To4() != nil {
+		return nil, nil, false
+	}
+	return start, end, true
+}
+
+// IterateIPs calls the callback with each usable address in the CIDR, in
+// order, skipping any addresses within the excluded ranges. For IPv4
+// subnets larger than a /31, the network and broadcast addresses are not
+// usable. Ranges of the other IP family are ignored. If the callback
+// returns an error, iteration stops and that error is returned.
+func IterateIPs(cidr string, exclude []IPRange, callback func(net.IP) error) error {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return errors.NotValidf("CIDR %q", cidr)
+	}
+	first := network.IP
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^network.Mask[i]
+	}
+	if ones, bits := network.Mask.Size(); bits == 8*net.IPv4len && ones < 31 {
+		first, _ = nextIP(first)
+		last = previousIP(last)
+	}
+
+	type bound struct{ start, end net.IP }
+	var ranges []bound
+	for _, r := range exclude {
+		start, end, ok := r.bounds(len(first))
+		if !ok {
+			if net.ParseIP(r.Start) == nil || net.ParseIP(r.End) == nil {
+				return errors.NotValidf("IP range %s-%s", r.Start, r.End)
+			}
+			continue
+		}
+		ranges = append(ranges, bound{start, end})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+	})
+
+	current := first
+	for bytes.Compare(current, last) <= 0 {
+		// Drop the ranges that are entirely before the current address.
+		for len(ranges) > 0 && bytes.Compare(ranges[0].end, current) < 0 {
+			ranges = ranges[1:]
+		}
+		if len(ranges) > 0 && bytes.Compare(ranges[0].start, current) <= 0 {
+			next, ok := nextIP(ranges[0].end)
+			if !ok {
+				return nil
+			}
+			current = next
+			continue
+		}
+		if err := callback(append(net.IP(nil), current...)); err != nil {
+			return err
+		}
+		next, ok := nextIP(current)
+		if !ok {
+			return nil
+		}
+		current = next
+	}
+	return nil
+}
+
+// nextIP returns the address after ip, or false if ip is the last address.
+func nextIP(ip net.IP) (net.IP, bool) {
+	next := append(net.IP(nil), ip...)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next, true
+		}
+	}
+	return next, false
+}
+
+// previousIP returns the address before ip, which must not be all zeros.
+func previousIP(ip net.IP) net.IP {
+	previous := append(net.IP(nil), ip...)
+	for i := len(previous) - 1; i >= 0; i-- {
+		previous[i]--
+		if previous[i] != 0xff {
+			break
+		}
+	}
+	return previous
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type ipRangeSuite struct{}
+
+var _ = gc.Suite(&ipRangeSuite{})
+
+func collectIPs(c *gc.C, cidr string, exclude []IPRange) []string {
+	var ips []string
+	err := IterateIPs(cidr, exclude, func(ip net.IP) error {
+		ips = append(ips, ip.String())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return ips
+}
+
+func (*ipRangeSuite) TestContains(c *gc.C) {
+	r := IPRange{Start: "10.0.0.10", End: "10.0.0.20"}
+	c.Check(r.Contains(net.ParseIP("10.0.0.10")), jc.IsTrue)
+	c.Check(r.Contains(net.ParseIP("10.0.0.20").To4()), jc.IsTrue)
+	c.Check(r.Contains(net.ParseIP("10.0.0.21")), jc.IsFalse)
+	c.Check(r.Contains(net.ParseIP("2001:db8::10")), jc.IsFalse)
+
+	r = IPRange{Start: "2001:db8::1", End: "2001:db8::ff"}
+	c.Check(r.Contains(net.ParseIP("2001:db8::10")), jc.IsTrue)
+	c.Check(r.Contains(net.ParseIP("10.0.0.10")), jc.IsFalse)
+}
+
+func (*ipRangeSuite) TestIterateIPv4(c *gc.C) {
+	ips := collectIPs(c, "10.0.0.0/29", nil)
+	c.Assert(ips, jc.DeepEquals, []string{
+		"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6",
+	})
+}
+
+func (*ipRangeSuite) TestIterateIPv4PointToPoint(c *gc.C) {
+	c.Assert(collectIPs(c, "10.0.0.0/31", nil), jc.DeepEquals, []string{"10.0.0.0", "10.0.0.1"})
+	c.Assert(collectIPs(c, "10.0.0.7/32", nil), jc.DeepEquals, []string{"10.0.0.7"})
+}
+
+func (*ipRangeSuite) TestIterateExcludes(c *gc.C) {
+	ips := collectIPs(c, "10.0.0.0/28", []IPRange{
+		{Start: "10.0.0.10", End: "10.0.0.20"},
+		{Start: "10.0.0.1", End: "10.0.0.1"},
+		{Start: "10.0.0.3", End: "10.0.0.5"},
+		{Start: "10.0.0.4", End: "10.0.0.6"},
+		{Start: "2001:db8::1", End: "2001:db8::5"},
+	})
+	c.Assert(ips, jc.DeepEquals, []string{"10.0.0.2", "10.0.0.7", "10.0.0.8", "10.0.0.9"})
+}
+
+func (*ipRangeSuite) TestIterateIPv6(c *gc.C) {
+	ips := collectIPs(c, "2001:db8::/126", []IPRange{
+		{Start: "2001:db8::", End: "2001:db8::"},
+	})
+	c.Assert(ips, jc.DeepEquals, []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"})
+}
+
+func (*ipRangeSuite) TestIterateEndOfAddressSpace(c *gc.C) {
+	ips := collectIPs(c, "255.255.255.254/31", nil)
+	c.Assert(ips, jc.DeepEquals, []string{"255.255.255.254", "255.255.255.255"})
+	ips = collectIPs(c, "255.255.255.252/30", []IPRange{{Start: "255.255.255.254", End: "255.255.255.255"}})
+	c.Assert(ips, jc.DeepEquals, []string{"255.255.255.253"})
+}
+
+func (*ipRangeSuite) TestIterateStops(c *gc.C) {
+	count := 0
+	err := IterateIPs("2001:db8::/64", nil, func(ip net.IP) error {
+		count++
+		if count == 3 {
+			return errors.New("enough")
+		}
+		return nil
+	})
+	c.Assert(err, gc.ErrorMatches, "enough")
+	c.Assert(count, gc.Equals, 3)
+}
+
+func (*ipRangeSuite) TestIterateBadCIDR(c *gc.C) {
+	err := IterateIPs("10.0.0.0", nil, func(net.IP) error { return nil })
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (*ipRangeSuite) TestIterateBadRange(c *gc.C) {
+	err := IterateIPs("10.0.0.0/24", []IPRange{{Start: "10.0.0.1", End: "bogus"}}, func(net.IP) error { return nil })
+	c.Assert(err, gc.ErrorMatches, "IP range 10.0.0.1-bogus not valid")
+}
@@ -4,6 +4,7 @@
 package gomaasapi
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,7 +24,9 @@ import (
 // There is one exception: a MAASObject is really a special kind of map,
 // so you can read it as either.
 // Reading a null item is also an error.  So before you try obj.Get*(),
-// first check obj.IsNil().
+// first check obj.IsNil().  Or use the TryGet*() methods, which return
+// whether the value had the type, or the Get*OrDefault() methods, which
+// return a default for a null or a value of another type.
 type JSONObject struct {
 	// Parsed value.  May actually be any of the types a JSONObject can
 	// wrap, except raw bytes.  If the object can only be interpreted
@@ -104,6 +107,29 @@ func Parse(client Client, input []byte) (JSONObject, error) {
 	return obj, nil
 }
 
+// ParseSafe parses a JSON blob into a JSONObject as Parse does, but never
+// panics: a nil input is an error, and so is input that is not valid JSON,
+// rather than being kept as raw bytes. Use it for responses that must be
+// JSON, so that a truncated body or an error page from a proxy is reported
+// to the caller instead of surfacing later as a failed conversion.
+func ParseSafe(client Client, input []byte) (obj JSONObject, err error) {
+	defer func() {
+		if failure := recover(); failure != nil {
+			obj, err = JSONObject{}, fmt.Errorf("cannot parse JSON: %v", failure)
+		}
+	}()
+	if input == nil {
+		return JSONObject{}, errors.New("cannot parse JSON: nil input")
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(input, &parsed); err != nil {
+		return JSONObject{}, fmt.Errorf("cannot parse JSON: %v", err)
+	}
+	obj = maasify(client, parsed)
+	obj.bytes = input
+	return obj, nil
+}
+
 // JSONObjectFromStruct takes a struct and converts it to a JSONObject
 func JSONObjectFromStruct(client Client, input interface{}) (JSONObject, error) {
 	j, err := json.MarshalIndent(input, "", "  ")
@@ -131,6 +157,54 @@ func (obj JSONObject) MarshalJSON() ([]byte, error) {
 // With MarshalJSON, JSONObject implements json.Marshaler.
 var _ json.Marshaler = (*JSONObject)(nil)
 
+// MarshalIndentStable serializes the object as JSON indented with indent,
+// with the keys of every object sorted, "<", ">" and "&" left as they are,
+// and a final newline. The same object always gives the same bytes, so
+// the output can be kept in snapshot tests or version control without
+// churn.
+func (obj JSONObject) MarshalIndentStable(indent string) ([]byte, error) {
+	return marshalIndentStable(plainJSON(obj), indent)
+}
+
+// plainJSON returns the value of the object built from the plain types
+// that json.Unmarshal produces, so that it can be encoded without calling
+// MarshalJSON.
+func plainJSON(obj JSONObject) interface{} {
+	switch value := obj.value.(type) {
+	case map[string]JSONObject:
+		return plainJSONMap(value)
+	case []JSONObject:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = plainJSON(item)
+		}
+		return result
+	}
+	return obj.value
+}
+
+func plainJSONMap(values map[string]JSONObject) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for key, item := range values {
+		result[key] = plainJSON(item)
+	}
+	return result
+}
+
+// marshalIndentStable encodes the value, which must not contain types
+// with their own MarshalJSON, for MarshalIndentStable. The json package
+// writes the keys of maps in sorted order.
+func marshalIndentStable(value interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // IsNil tells you whether a JSONObject is a JSON "null."
 // There is one irregularity.  If the original JSON blob was actually raw
 // data, not JSON, then its IsNil will return false because the object
@@ -149,6 +223,26 @@ func (obj JSONObject) IsNil() bool {
 	return obj.isNull
 }
 
+// IsNull tells you whether a JSONObject is an explicit JSON "null."  Unlike
+// IsNil, it is false for the zero JSONObject that looking up a missing key
+// in a map gives, so a field set to null, such as the owner of a machine
+// that nobody owns, can be told apart from one that was not sent at all.
+// Use Has to ask whether a map has a key.
+func (obj JSONObject) IsNull() bool {
+	return obj.isNull
+}
+
+// Has tells you whether the object is a JSON object with the key, whatever
+// its value, including null.
+func (obj JSONObject) Has(key string) bool {
+	values, ok := obj.value.(map[string]JSONObject)
+	if !ok {
+		return false
+	}
+	_, found := values[key]
+	return found
+}
+
 // GetString retrieves the object's value as a string.  If the value wasn't
 // a JSON string, that's an error.
 func (obj JSONObject) GetString() (value string, err error) {
@@ -213,3 +307,105 @@ func (obj JSONObject) GetBytes() ([]byte, error) {
 	}
 	return obj.bytes, nil
 }
+
+// TryGetString returns the object's value and true if it is a JSON string,
+// or "" and false if it is anything else, including a null.
+func (obj JSONObject) TryGetString() (string, bool) {
+	value, ok := obj.value.(string)
+	return value, ok
+}
+
+// TryGetFloat64 returns the object's value and true if it is a JSON number,
+// or 0 and false if it is anything else, including a null.
+func (obj JSONObject) TryGetFloat64() (float64, bool) {
+	value, ok := obj.value.(float64)
+	return value, ok
+}
+
+// TryGetBool returns the object's value and true if it is a JSON bool, or
+// false and false if it is anything else, including a null.
+func (obj JSONObject) TryGetBool() (bool, bool) {
+	value, ok := obj.value.(bool)
+	return value, ok
+}
+
+// TryGetMap returns the object's value and true if it is a JSON object, or
+// nil and false if it is anything else, including a null. Looking up a key
+// in the nil map is safe, and gives a JSONObject that reads as null.
+func (obj JSONObject) TryGetMap() (map[string]JSONObject, bool) {
+	value, ok := obj.value.(map[string]JSONObject)
+	return value, ok
+}
+
+// TryGetArray returns the object's value and true if it is a JSON list, or
+// nil and false if it is anything else, including a null.
+func (obj JSONObject) TryGetArray() ([]JSONObject, bool) {
+	value, ok := obj.value.([]JSONObject)
+	return value, ok
+}
+
+// GetStringOrDefault returns the object's value if it is a JSON string, and
+// defaultValue if it is anything else, including a null.
+func (obj JSONObject) GetStringOrDefault(defaultValue string) string {
+	if value, ok := obj.TryGetString(); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// GetFloat64OrDefault returns the object's value if it is a JSON number, and
+// defaultValue if it is anything else, including a null.
+func (obj JSONObject) GetFloat64OrDefault(defaultValue float64) float64 {
+	if value, ok := obj.TryGetFloat64(); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// GetBoolOrDefault returns the object's value if it is a JSON bool, and
+// defaultValue if it is anything else, including a null.
+func (obj JSONObject) GetBoolOrDefault(defaultValue bool) bool {
+	if value, ok := obj.TryGetBool(); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// DecodeList reads the object's value as a list of T.  If the value wasn't
+// a JSON list, or an item can't be read as T, that's an error.
+// A JSONObject item is returned as it is, and a MAASObject item is read
+// with GetMAASObject.  Any other type is decoded with encoding/json, so T
+// may be a string, a number, a bool, a map or a struct with json tags.
+func DecodeList[T any](obj JSONObject) ([]T, error) {
+	items, err := obj.GetArray()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, len(items))
+	for index, item := range items {
+		if err := decodeItem(item, &result[index]); err != nil {
+			return nil, fmt.Errorf("item %d: %v", index, err)
+		}
+	}
+	return result, nil
+}
+
+func decodeItem(item JSONObject, target interface{}) error {
+	switch target := target.(type) {
+	case *JSONObject:
+		*target = item
+		return nil
+	case *MAASObject:
+		obj, err := item.GetMAASObject()
+		if err != nil {
+			return err
+		}
+		*target = obj
+		return nil
+	}
+	data, err := item.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
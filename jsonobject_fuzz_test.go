@@ -0,0 +1,70 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/url"
+	"testing"
+)
+
+// FuzzParse checks that no input makes parsing, or reading the result with
+// any getter, panic. Run it with:
+//
+//	go test -run ^$ -fuzz FuzzParse
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		`{"resource_uri": "/api/2.0/machines/4y3ha3/", "hostname": "untasted-markita"}`,
+		`[{"resource_uri": 1}, null, true, 1.5, "text"]`,
+		`{"resource_uri": "%zz"}`,
+		`{"system_id": "4y3ha3", "interface_set": [{"id": 3`,
+		`1e400`,
+		`-0.0000000000000000000000000000001e-400`,
+		"\"\xff\xfe\"",
+		`<html><body>502 Bad Gateway</body></html>`,
+		``,
+		`null`,
+	} {
+		f.Add([]byte(seed))
+	}
+	apiURL, _ := url.Parse("http://maas.example.com/MAAS/api/2.0/")
+	client := Client{APIURL: apiURL}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		if obj, err := Parse(client, input); err == nil {
+			exerciseGetters(obj)
+		}
+		if obj, err := ParseSafe(client, input); err == nil {
+			exerciseGetters(obj)
+		}
+	})
+}
+
+// exerciseGetters reads the object, and everything in it, in every way it
+// can be read.
+func exerciseGetters(obj JSONObject) {
+	obj.IsNil()
+	obj.GetString()
+	obj.GetFloat64()
+	obj.GetBool()
+	obj.GetBytes()
+	obj.MarshalJSON()
+	obj.MarshalIndentStable("  ")
+	if maasObj, err := obj.GetMAASObject(); err == nil {
+		maasObj.GetField("hostname")
+		maasObj.SafeURI()
+		maasObj.SafeURL()
+		maasObj.MarshalJSON()
+	}
+	if values, err := obj.GetMap(); err == nil {
+		for _, value := range values {
+			exerciseGetters(value)
+		}
+	}
+	if items, err := obj.GetArray(); err == nil {
+		for _, item := range items {
+			exerciseGetters(item)
+		}
+		DecodeList[string](obj)
+		DecodeList[map[string]interface{}](obj)
+	}
+}
@@ -461,3 +461,212 @@ func (suite *JSONObjectSuite) TestBoolSerializesToJSON(c *C) {
 	c.Check(f, DeepEquals, []byte("false"))
 	c.Check(t, DeepEquals, []byte("true"))
 }
+
+// DecodeList reads lists of JSON values as slices of Go values.
+func (suite *JSONObjectSuite) TestDecodeListOfStrings(c *C) {
+	obj := maasify(Client{}, []interface{}{"a", "b"})
+	values, err := DecodeList[string](obj)
+	c.Assert(err, IsNil)
+	c.Check(values, DeepEquals, []string{"a", "b"})
+}
+
+// DecodeList decodes objects into structs.
+func (suite *JSONObjectSuite) TestDecodeListOfStructs(c *C) {
+	type zone struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	obj, err := Parse(Client{}, []byte(`[{"name": "a", "description": "first"}, {"name": "b"}]`))
+	c.Assert(err, IsNil)
+	zones, err := DecodeList[zone](obj)
+	c.Assert(err, IsNil)
+	c.Check(zones, DeepEquals, []zone{{"a", "first"}, {"b", ""}})
+}
+
+// DecodeList returns JSONObject and MAASObject items without conversion.
+func (suite *JSONObjectSuite) TestDecodeListOfMAASObjects(c *C) {
+	obj, err := Parse(Client{}, []byte(`[{"resource_uri": "/a/", "hostname": "x"}, 3]`))
+	c.Assert(err, IsNil)
+	items, err := DecodeList[JSONObject](obj)
+	c.Assert(err, IsNil)
+	c.Check(items, HasLen, 2)
+
+	_, err = DecodeList[MAASObject](obj)
+	c.Check(err, ErrorMatches, `item 1: Requested map, got float64\.`)
+
+	obj, err = Parse(Client{}, []byte(`[{"resource_uri": "/a/", "hostname": "x"}]`))
+	c.Assert(err, IsNil)
+	nodes, err := DecodeList[MAASObject](obj)
+	c.Assert(err, IsNil)
+	hostname, err := nodes[0].GetField("hostname")
+	c.Assert(err, IsNil)
+	c.Check(hostname, Equals, "x")
+}
+
+// DecodeList fails on values that aren't lists or items of the wrong type.
+func (suite *JSONObjectSuite) TestDecodeListErrors(c *C) {
+	_, err := DecodeList[string](maasify(Client{}, "a"))
+	c.Check(err, ErrorMatches, `Requested array, got string\.`)
+	_, err = DecodeList[string](maasify(Client{}, []interface{}{"a", 1.0}))
+	c.Check(err, ErrorMatches, `item 1: json: cannot unmarshal number into Go value of type string`)
+}
+
+func (suite *JSONObjectSuite) TestMarshalIndentStable(c *C) {
+	input := `{"zeta": [3, {"b": true, "a": null}], "alpha": "<a&b>", "mid": {"y": 1.5, "x": "x"}}`
+	obj, err := Parse(Client{}, []byte(input))
+	c.Assert(err, IsNil)
+	expected := `{
+  "alpha": "<a&b>",
+  "mid": {
+    "x": "x",
+    "y": 1.5
+  },
+  "zeta": [
+    3,
+    {
+      "a": null,
+      "b": true
+    }
+  ]
+}
+`
+	for i := 0; i < 5; i++ {
+		output, err := obj.MarshalIndentStable("  ")
+		c.Assert(err, IsNil)
+		c.Check(string(output), Equals, expected)
+	}
+}
+
+func (suite *JSONObjectSuite) TestMarshalIndentStableNull(c *C) {
+	output, err := maasify(Client{}, nil).MarshalIndentStable("\t")
+	c.Assert(err, IsNil)
+	c.Check(string(output), Equals, "null\n")
+}
+
+func (suite *JSONObjectSuite) TestParseSafeErrorsOnNilJSON(c *C) {
+	_, err := ParseSafe(Client{}, nil)
+	c.Check(err, ErrorMatches, "cannot parse JSON: nil input")
+}
+
+func (suite *JSONObjectSuite) TestParseSafeErrorsOnTruncatedJSON(c *C) {
+	_, err := ParseSafe(Client{}, []byte(`{"system_id": "4y3ha3", "interface_set": [`))
+	c.Check(err, ErrorMatches, "cannot parse JSON: unexpected end of JSON input")
+}
+
+func (suite *JSONObjectSuite) TestParseSafeErrorsOnNonJSON(c *C) {
+	_, err := ParseSafe(Client{}, []byte("<html>502 Bad Gateway</html>"))
+	c.Check(err, ErrorMatches, "cannot parse JSON: invalid character '<'.*")
+}
+
+func (suite *JSONObjectSuite) TestParseSafeErrorsOnHugeNumbers(c *C) {
+	_, err := ParseSafe(Client{}, []byte(`{"memory": 1e400}`))
+	c.Check(err, ErrorMatches, "cannot parse JSON: .*number 1e400.*")
+}
+
+func (suite *JSONObjectSuite) TestParseSafeReplacesInvalidUTF8(c *C) {
+	obj, err := ParseSafe(Client{}, []byte("\"bad \xff\""))
+	c.Assert(err, IsNil)
+	value, err := obj.GetString()
+	c.Assert(err, IsNil)
+	c.Check(value, Equals, "bad �")
+}
+
+func (suite *JSONObjectSuite) TestParseSafeKeepsBytes(c *C) {
+	blob := []byte(`{"hostname": "untasted-markita"}`)
+	obj, err := ParseSafe(Client{}, blob)
+	c.Assert(err, IsNil)
+	data, err := obj.GetBytes()
+	c.Assert(err, IsNil)
+	c.Check(data, DeepEquals, blob)
+	values, err := obj.GetMap()
+	c.Assert(err, IsNil)
+	hostname, err := values["hostname"].GetString()
+	c.Assert(err, IsNil)
+	c.Check(hostname, Equals, "untasted-markita")
+}
+
+func (suite *JSONObjectSuite) TestTryGetters(c *C) {
+	obj, err := Parse(Client{}, []byte(`{"name": "maas", "cores": 4, "enabled": true, "tags": ["virtual"], "owner": null}`))
+	c.Assert(err, IsNil)
+	values, ok := obj.TryGetMap()
+	c.Assert(ok, Equals, true)
+
+	name, ok := values["name"].TryGetString()
+	c.Check(name, Equals, "maas")
+	c.Check(ok, Equals, true)
+	cores, ok := values["cores"].TryGetFloat64()
+	c.Check(cores, Equals, float64(4))
+	c.Check(ok, Equals, true)
+	enabled, ok := values["enabled"].TryGetBool()
+	c.Check(enabled, Equals, true)
+	c.Check(ok, Equals, true)
+	tags, ok := values["tags"].TryGetArray()
+	c.Check(tags, HasLen, 1)
+	c.Check(ok, Equals, true)
+
+	owner, ok := values["owner"].TryGetString()
+	c.Check(owner, Equals, "")
+	c.Check(ok, Equals, false)
+	_, ok = values["name"].TryGetFloat64()
+	c.Check(ok, Equals, false)
+	_, ok = values["cores"].TryGetBool()
+	c.Check(ok, Equals, false)
+	_, ok = values["tags"].TryGetMap()
+	c.Check(ok, Equals, false)
+	_, ok = values["missing"].TryGetArray()
+	c.Check(ok, Equals, false)
+
+	// A failed TryGetMap gives a nil map, which is safe to index.
+	nested, ok := values["owner"].TryGetMap()
+	c.Check(ok, Equals, false)
+	c.Check(nested["name"].IsNil(), Equals, true)
+}
+
+func (suite *JSONObjectSuite) TestGetOrDefault(c *C) {
+	obj, err := Parse(Client{}, []byte(`{"name": "maas", "cores": 4, "enabled": false, "owner": null}`))
+	c.Assert(err, IsNil)
+	values, ok := obj.TryGetMap()
+	c.Assert(ok, Equals, true)
+
+	c.Check(values["name"].GetStringOrDefault("none"), Equals, "maas")
+	c.Check(values["owner"].GetStringOrDefault("none"), Equals, "none")
+	c.Check(values["missing"].GetStringOrDefault("none"), Equals, "none")
+	c.Check(values["cores"].GetStringOrDefault("none"), Equals, "none")
+	c.Check(values["cores"].GetFloat64OrDefault(1), Equals, float64(4))
+	c.Check(values["name"].GetFloat64OrDefault(1), Equals, float64(1))
+	c.Check(values["enabled"].GetBoolOrDefault(true), Equals, false)
+	c.Check(values["owner"].GetBoolOrDefault(true), Equals, true)
+}
+
+func (suite *JSONObjectSuite) TestNullAndMissing(c *C) {
+	obj, err := Parse(Client{}, []byte(`{"owner": null, "hostname": "maas"}`))
+	c.Assert(err, IsNil)
+	c.Check(obj.Has("owner"), Equals, true)
+	c.Check(obj.Has("hostname"), Equals, true)
+	c.Check(obj.Has("zone"), Equals, false)
+
+	values, err := obj.GetMap()
+	c.Assert(err, IsNil)
+	owner, missing := values["owner"], values["zone"]
+	c.Check(owner.IsNil(), Equals, true)
+	c.Check(owner.IsNull(), Equals, true)
+	c.Check(missing.IsNil(), Equals, true)
+	c.Check(missing.IsNull(), Equals, false)
+	c.Check(values["hostname"].IsNull(), Equals, false)
+}
+
+func (suite *JSONObjectSuite) TestIsNullAtTopLevel(c *C) {
+	obj, err := Parse(Client{}, []byte(" null "))
+	c.Assert(err, IsNil)
+	c.Check(obj.IsNull(), Equals, true)
+
+	obj, err = Parse(Client{}, []byte("not json"))
+	c.Assert(err, IsNil)
+	c.Check(obj.IsNull(), Equals, false)
+}
+
+func (suite *JSONObjectSuite) TestHasOnNonMap(c *C) {
+	c.Check(maasify(Client{}, "owner").Has("owner"), Equals, false)
+	c.Check(maasify(Client{}, nil).Has("owner"), Equals, false)
+	c.Check(JSONObject{}.Has("owner"), Equals, false)
+}
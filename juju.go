@@ -0,0 +1,98 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// The interfaces in this file have the same methods as the Controller,
+// Machine and Space interfaces of the upstream juju/gomaasapi package.
+// Controller, Machine and Space here have grown many more methods, so a
+// type written for upstream, such as a test fake in the Juju MAAS
+// provider, no longer implements them. Code that depends on these instead
+// can use this package and its upstream fakes unchanged. The values
+// returned by NewController, and the entities read through it, satisfy
+// them.
+
+// JujuController is the method set of the upstream Controller.
+type JujuController interface {
+	Capabilities() set.Strings
+	BootResources() ([]BootResource, error)
+	Fabrics() ([]Fabric, error)
+	Spaces() ([]Space, error)
+	StaticRoutes() ([]StaticRoute, error)
+	Zones() ([]Zone, error)
+	Machines(MachinesArgs) ([]Machine, error)
+	AllocateMachine(AllocateMachineArgs) (Machine, ConstraintMatches, error)
+	ReleaseMachines(ReleaseMachinesArgs) error
+	Devices(DevicesArgs) ([]Device, error)
+	CreateDevice(CreateDeviceArgs) (Device, error)
+	Files(prefix string) ([]File, error)
+	GetFile(filename string) (File, error)
+	AddFile(AddFileArgs) error
+}
+
+// JujuMachine is the method set of the upstream Machine.
+type JujuMachine interface {
+	OwnerDataHolder
+
+	SystemID() string
+	Hostname() string
+	FQDN() string
+	Tags() []string
+
+	OperatingSystem() string
+	DistroSeries() string
+	Architecture() string
+	Memory() int
+	CPUCount() int
+
+	IPAddresses() []string
+	PowerState() string
+
+	Devices(DevicesArgs) ([]Device, error)
+
+	StatusName() string
+	StatusMessage() string
+
+	BootInterface() Interface
+	InterfaceSet() []Interface
+	Interface(id int) Interface
+
+	PhysicalBlockDevices() []BlockDevice
+	PhysicalBlockDevice(id int) BlockDevice
+	BlockDevices() []BlockDevice
+
+	Zone() Zone
+
+	Start(StartArgs) error
+	CreateDevice(CreateMachineDeviceArgs) (Device, error)
+}
+
+// JujuSpace is the method set of the upstream Space.
+type JujuSpace interface {
+	ID() int
+	Name() string
+	Subnets() []Subnet
+}
+
+// These fail to compile if Controller, Machine or Space lose or change a
+// method that upstream has.
+var (
+	_ JujuController = Controller(nil)
+	_ JujuMachine    = Machine(nil)
+	_ JujuSpace      = Space(nil)
+)
+
+// NewJujuController creates an authenticated client to the MAAS API and
+// returns it as a JujuController. See NewController.
+func NewJujuController(args ControllerArgs) (JujuController, error) {
+	maas, err := NewController(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return maas, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type jujuSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&jujuSuite{})
+
+// fakeJujuMachine only implements what the upstream Machine has, as the
+// fakes in code written against upstream do.
+type fakeJujuMachine struct {
+	JujuMachine
+	systemID string
+}
+
+func (m *fakeJujuMachine) SystemID() string {
+	return m.systemID
+}
+
+// upstreamGlue stands in for code written against the upstream package.
+func upstreamGlue(machines []JujuMachine) []string {
+	var ids []string
+	for _, m := range machines {
+		ids = append(ids, m.SystemID())
+	}
+	return ids
+}
+
+func (s *jujuSuite) TestNewJujuController(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	server.AddGetResponse("/api/2.0/spaces/", http.StatusOK, spacesResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewJujuController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(controller.Capabilities().Contains(NetworksManagement), jc.IsTrue)
+
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	glue := []JujuMachine{machines[0], &fakeJujuMachine{systemID: "fake"}}
+	c.Check(upstreamGlue(glue), jc.DeepEquals, []string{"4y3ha3", "fake"})
+
+	spaces, err := controller.Spaces()
+	c.Assert(err, jc.ErrorIsNil)
+	var space JujuSpace = spaces[0]
+	c.Check(space.Name(), gc.Not(gc.Equals), "")
+}
+
+func (*jujuSuite) TestNewJujuControllerError(c *gc.C) {
+	_, err := NewJujuController(ControllerArgs{BaseURL: "wat://example.com"})
+	c.Assert(err, gc.NotNil)
+}
@@ -10,6 +10,10 @@ import (
 )
 
 type link struct {
+	rawJSON
+
+	controller *controller
+
 	id        int
 	mode      string
 	subnet    *subnet
@@ -24,6 +28,14 @@ func (k *link) ID() int {
 	return k.id
 }
 
+// withController returns a copy of the link that uses the controller, as
+// the links of an interface are shared by everyone reading it.
+func (k *link) withController(c *controller) *link {
+	copied := *k
+	copied.controller = c
+	return &copied
+}
+
 // Mode implements Link.
 func (k *link) Mode() string {
 	return k.mode
@@ -34,7 +46,7 @@ func (k *link) Subnet() Subnet {
 	if k.subnet == nil {
 		return nil
 	}
-	return k.subnet
+	return k.subnet.withController(k.controller)
 }
 
 // IPAddress implements Link.
@@ -88,10 +100,10 @@ var linkDeserializationFuncs = map[version.Number]linkDeserializationFunc{
 
 func link_2_0(source map[string]interface{}) (*link, error) {
 	fields := schema.Fields{
-		"id":         schema.ForceInt(),
-		"mode":       schema.String(),
+		"id":         intField(),
+		"mode":       stringField(),
 		"subnet":     schema.StringMap(schema.Any()),
-		"ip_address": schema.String(),
+		"ip_address": stringField(),
 	}
 	defaults := schema.Defaults{
 		"ip_address": "",
@@ -115,6 +127,7 @@ func link_2_0(source map[string]interface{}) (*link, error) {
 	}
 
 	result := &link{
+		rawJSON:   rawJSON{source},
 		id:        valid["id"].(int),
 		mode:      valid["mode"].(string),
 		subnet:    subnet,
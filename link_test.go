@@ -18,6 +18,17 @@ func (*linkSuite) TestNilSubnet(c *gc.C) {
 	c.Check(empty.Subnet() == nil, jc.IsTrue)
 }
 
+func (*linkSuite) TestSubnetLeavesSharedSubnet(c *gc.C) {
+	links, err := readLinks(twoDotOh, parseJSON(c, linksResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	link := links[0]
+	link.controller = &controller{}
+	subnet := link.Subnet().(*subnet)
+	c.Check(subnet.controller, gc.Equals, link.controller)
+	// The subnet read with the link is shared, so it is not changed.
+	c.Check(link.subnet.controller, gc.IsNil)
+}
+
 func (*linkSuite) TestReadLinksBadSchema(c *gc.C) {
 	_, err := readLinks(twoDotOh, "wat?")
 	c.Check(err, jc.Satisfies, IsDeserializationError)
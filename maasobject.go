@@ -42,6 +42,12 @@ func (obj MAASObject) MarshalJSON() ([]byte, error) {
 // With MarshalJSON, MAASObject implements json.Marshaler.
 var _ json.Marshaler = (*MAASObject)(nil)
 
+// MarshalIndentStable serializes the object as JSON in the same stable
+// form as JSONObject.MarshalIndentStable.
+func (obj MAASObject) MarshalIndentStable(indent string) ([]byte, error) {
+	return marshalIndentStable(plainJSONMap(obj.values), indent)
+}
+
 func marshalNode(node MAASObject) string {
 	res, _ := json.MarshalIndent(node, "", "  ")
 	return string(res)
@@ -88,6 +94,20 @@ func (obj MAASObject) GetField(name string) (string, error) {
 	return obj.values[name].GetString()
 }
 
+// Has tells you whether this MAAS object has the field, whatever its value,
+// including null.  Together with JSONObject.IsNull it tells a field that is
+// null apart from one that is missing.
+func (obj MAASObject) Has(name string) bool {
+	_, found := obj.values[name]
+	return found
+}
+
+// GetFieldOrDefault returns a string field of this MAAS object, or
+// defaultValue if the field is missing, null or not a string.
+func (obj MAASObject) GetFieldOrDefault(name, defaultValue string) string {
+	return obj.values[name].GetStringOrDefault(defaultValue)
+}
+
 // URI is the resource URI for this MAAS object.  It is an absolute path, but
 // without a network part.
 func (obj MAASObject) URI() *url.URL {
@@ -105,6 +125,28 @@ func (obj MAASObject) URL() *url.URL {
 	return obj.client.GetURL(obj.URI())
 }
 
+// SafeURI is like URI, but returns an error rather than panicking if the
+// object has no resource URI, as is the case for a zero MAASObject.
+func (obj MAASObject) SafeURI() (*url.URL, error) {
+	if obj.uri == nil {
+		return nil, noResourceURI
+	}
+	return url.Parse(obj.uri.String())
+}
+
+// SafeURL is like URL, but returns an error rather than panicking if the
+// object has no resource URI or no API URL to resolve it against.
+func (obj MAASObject) SafeURL() (*url.URL, error) {
+	uri, err := obj.SafeURI()
+	if err != nil {
+		return nil, err
+	}
+	if obj.client.APIURL == nil {
+		return nil, errors.New("not a MAAS object: no API URL")
+	}
+	return obj.client.GetURL(uri), nil
+}
+
 // GetMap returns all of the object's attributes in the form of a map.
 func (obj MAASObject) GetMap() map[string]JSONObject {
 	return obj.values
@@ -169,6 +211,20 @@ func (obj MAASObject) Delete() error {
 	return obj.client.Delete(uri)
 }
 
+// DeleteWithResponse deletes this object on the API, and returns the body
+// of the response. If the body is empty, the result is a JSON null.
+func (obj MAASObject) DeleteWithResponse() (JSONObject, error) {
+	uri := obj.URI()
+	result, err := obj.client.DeleteWithResponse(uri)
+	if err != nil {
+		return JSONObject{}, err
+	}
+	if len(result) == 0 {
+		return JSONObject{client: obj.client}, nil
+	}
+	return Parse(obj.client, result)
+}
+
 // CallGet invokes an idempotent API method on this object.
 func (obj MAASObject) CallGet(operation string, params url.Values) (JSONObject, error) {
 	uri := obj.URI()
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"net/url"
 
 	. "gopkg.in/check.v1"
@@ -105,6 +106,55 @@ func (suite *MAASObjectSuite) TestURL(c *C) {
 	c.Check(URL, DeepEquals, resourceURL)
 }
 
+func (suite *MAASObjectSuite) TestGetFieldOrDefault(c *C) {
+	input := map[string]interface{}{
+		resourceURI: "/a/resource/",
+		"hostname":  "untasted-markita",
+		"owner":     nil,
+		"cpu_count": 4.0,
+	}
+	obj := newJSONMAASObject(input, Client{})
+	c.Check(obj.GetFieldOrDefault("hostname", "none"), Equals, "untasted-markita")
+	c.Check(obj.GetFieldOrDefault("owner", "none"), Equals, "none")
+	c.Check(obj.GetFieldOrDefault("cpu_count", "none"), Equals, "none")
+	c.Check(obj.GetFieldOrDefault("missing", "none"), Equals, "none")
+	c.Check(MAASObject{}.GetFieldOrDefault("hostname", "none"), Equals, "none")
+}
+
+func (suite *MAASObjectSuite) TestHas(c *C) {
+	input := map[string]interface{}{resourceURI: "/a/resource/", "owner": nil}
+	obj := newJSONMAASObject(input, Client{})
+	c.Check(obj.Has("owner"), Equals, true)
+	c.Check(obj.GetMap()["owner"].IsNull(), Equals, true)
+	c.Check(obj.Has("zone"), Equals, false)
+	c.Check(obj.GetMap()["zone"].IsNull(), Equals, false)
+}
+
+func (suite *MAASObjectSuite) TestSafeURL(c *C) {
+	baseURL, err := url.Parse("http://example.com/")
+	c.Assert(err, IsNil)
+	input := map[string]interface{}{resourceURI: "/a/resource/"}
+	obj := newJSONMAASObject(input, Client{APIURL: baseURL})
+
+	uri, err := obj.SafeURI()
+	c.Assert(err, IsNil)
+	c.Check(uri.String(), Equals, "/a/resource/")
+	URL, err := obj.SafeURL()
+	c.Assert(err, IsNil)
+	c.Check(URL.String(), Equals, "http://example.com/a/resource/")
+}
+
+func (suite *MAASObjectSuite) TestSafeURLErrorsOnZeroObject(c *C) {
+	_, err := MAASObject{}.SafeURI()
+	c.Check(err, ErrorMatches, "not a MAAS object: no 'resource_uri' key")
+	_, err = MAASObject{}.SafeURL()
+	c.Check(err, ErrorMatches, "not a MAAS object: no 'resource_uri' key")
+
+	input := map[string]interface{}{resourceURI: "/a/resource/"}
+	_, err = newJSONMAASObject(input, Client{}).SafeURL()
+	c.Check(err, ErrorMatches, "not a MAAS object: no API URL")
+}
+
 // makeFakeMAASObject creates a MAASObject for some imaginary resource.
 // There is no actual HTTP service or resource attached.
 // serviceURL is the base URL of the service, and resourceURI is the path for
@@ -204,3 +254,44 @@ func (suite *MAASObjectSuite) TestSerializesToJSON(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(deserialized, DeepEquals, attrs)
 }
+
+func (suite *MAASObjectSuite) TestDeleteWithResponse(c *C) {
+	response := `{"released": ["10.0.0.5"]}`
+	server := newSingleServingServer("/api/1.0/nodes/n1/", response, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, IsNil)
+	obj := patchObject(c, *client, `{"resource_uri": "/api/1.0/nodes/n1/"}`)
+
+	result, err := obj.DeleteWithResponse()
+	c.Assert(err, IsNil)
+	released, err := result.GetMap()
+	c.Assert(err, IsNil)
+	addresses, err := released["released"].GetArray()
+	c.Assert(err, IsNil)
+	c.Check(addresses, HasLen, 1)
+}
+
+func (suite *MAASObjectSuite) TestDeleteWithResponseEmptyBody(c *C) {
+	server := newSingleServingServer("/api/1.0/nodes/n1/", "", http.StatusNoContent)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, IsNil)
+	obj := patchObject(c, *client, `{"resource_uri": "/api/1.0/nodes/n1/"}`)
+
+	result, err := obj.DeleteWithResponse()
+	c.Assert(err, IsNil)
+	c.Check(result.IsNil(), Equals, true)
+}
+
+func (suite *MAASObjectSuite) TestMarshalIndentStable(c *C) {
+	input := map[string]interface{}{
+		resourceURI: "/api/2.0/machines/4y3ha3/",
+		"hostname":  "node-1",
+		"tags":      []interface{}{"b", "a"},
+	}
+	obj := newJSONMAASObject(input, Client{})
+	output, err := obj.MarshalIndentStable("\t")
+	c.Assert(err, IsNil)
+	c.Check(string(output), Equals, "{\n\t\"hostname\": \"node-1\",\n\t\"resource_uri\": \"/api/2.0/machines/4y3ha3/\",\n\t\"tags\": [\n\t\t\"b\",\n\t\t\"a\"\n\t]\n}\n")
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// NormalizeMACAddress returns the 48 bit MAC address in the form MAAS
+// reports it, six lower case hex pairs separated by colons, such as
+// "52:54:00:c9:6a:45". Upper case hex is accepted, as are hyphens in place
+// of the colons, the dotted form "5254.00c9.6a45" and the bare form
+// "525400c96a45". Anything else is not valid.
+func NormalizeMACAddress(mac string) (string, error) {
+	hex := strings.ToLower(strings.TrimSpace(mac))
+	switch {
+	case len(hex) == 17 && (hex[2] == ':' || hex[2] == '-'):
+		separator := hex[2:3]
+		for i := 2; i < 17; i += 3 {
+			if hex[i:i+1] != separator {
+				return "", errors.NotValidf("MAC address %q", mac)
+			}
+		}
+		hex = strings.Replace(hex, separator, "", -1)
+	case len(hex) == 14 && hex[4] == '.' && hex[9] == '.':
+		hex = strings.Replace(hex, ".", "", -1)
+	}
+	if len(hex) != 12 || strings.Trim(hex, "0123456789abcdef") != "" {
+		return "", errors.NotValidf("MAC address %q", mac)
+	}
+	pairs := make([]string, 6)
+	for i := range pairs {
+		pairs[i] = hex[2*i : 2*i+2]
+	}
+	return strings.Join(pairs, ":"), nil
+}
+
+// normalizeMACAddresses normalizes the MAC addresses given in the named
+// argument field, returning an ArgumentError for the first that is not
+// valid or that is the same address as one before it.
+func normalizeMACAddresses(field string, macs []string) ([]string, error) {
+	if len(macs) == 0 {
+		return nil, nil
+	}
+	result := make([]string, len(macs))
+	seen := set.NewStrings()
+	for i, mac := range macs {
+		normalized, err := NormalizeMACAddress(mac)
+		if err != nil {
+			return nil, NewArgumentError(field, "%q is not a valid MAC address", mac)
+		}
+		if seen.Contains(normalized) {
+			return nil, NewArgumentError(field, "%q is given more than once", mac)
+		}
+		seen.Add(normalized)
+		result[i] = normalized
+	}
+	return result, nil
+}
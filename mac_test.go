@@ -0,0 +1,99 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type macSuite struct{}
+
+var _ = gc.Suite(&macSuite{})
+
+func (*macSuite) TestNormalizeMACAddress(c *gc.C) {
+	for i, mac := range []string{
+		"52:54:00:c9:6a:45",
+		"52:54:00:C9:6A:45",
+		"52-54-00-c9-6a-45",
+		" 52:54:00:c9:6a:45\n",
+		"5254.00c9.6a45",
+		"525400C96A45",
+	} {
+		c.Logf("test %d: %q", i, mac)
+		normalized, err := NormalizeMACAddress(mac)
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(normalized, gc.Equals, "52:54:00:c9:6a:45")
+	}
+}
+
+func (*macSuite) TestNormalizeMACAddressNotValid(c *gc.C) {
+	for i, mac := range []string{
+		"",
+		"a-mac-address",
+		"52:54:00:c9:6a",
+		"52:54:00:c9:6a:45:01",
+		"52:54-00:c9:6a:45",
+		"52:54:00:c9:6a:4g",
+		"5254:00c9:6a45",
+		"52540.0c9.6a45",
+	} {
+		c.Logf("test %d: %q", i, mac)
+		_, err := NormalizeMACAddress(mac)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
+
+func (*macSuite) TestNormalizeMACAddresses(c *gc.C) {
+	macs, err := normalizeMACAddresses("MACAddresses", nil)
+	c.Check(err, jc.ErrorIsNil)
+	c.Check(macs, gc.IsNil)
+
+	macs, err = normalizeMACAddresses("MACAddresses", []string{"52-54-00-C9-6A-45", "52:54:00:c9:6a:46"})
+	c.Check(err, jc.ErrorIsNil)
+	c.Check(macs, jc.DeepEquals, []string{"52:54:00:c9:6a:45", "52:54:00:c9:6a:46"})
+
+	_, err = normalizeMACAddresses("MACAddresses", []string{"52:54:00:c9:6a:45", "52-54-00-C9-6A-45"})
+	c.Check(err, jc.Satisfies, IsArgumentError)
+	c.Check(err, gc.ErrorMatches, `MACAddresses: "52-54-00-C9-6A-45" is given more than once`)
+}
+
+func (s *controllerSuite) TestCreateDeviceNormalizesMACAddresses(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/devices/", http.StatusOK, deviceResponse)
+	controller := s.getController(c)
+	_, err := controller.CreateDevice(CreateDeviceArgs{
+		MACAddresses: []string{"52-54-00-C9-6A-45"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	form := s.server.LastRequest().PostForm
+	c.Check(form["mac_addresses"], jc.DeepEquals, []string{"52:54:00:c9:6a:45"})
+}
+
+func (s *controllerSuite) TestCreateDeviceInvalidMACAddress(c *gc.C) {
+	controller := s.getController(c)
+	count := s.server.RequestCount()
+	_, err := controller.CreateDevice(CreateDeviceArgs{
+		MACAddresses: []string{"a-mac-address"},
+	})
+	c.Check(err, jc.Satisfies, IsArgumentError)
+	c.Check(err, gc.ErrorMatches, `MACAddresses: "a-mac-address" is not a valid MAC address`)
+	c.Check(s.server.RequestCount(), gc.Equals, count)
+}
+
+func (s *controllerSuite) TestMachinesNormalizesMACAddresses(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?mac_address=52%3A54%3A00%3A55%3Ab6%3A80", http.StatusOK, "["+machineResponse+"]")
+	controller := s.getController(c)
+	machines, err := controller.Machines(MachinesArgs{MACAddresses: []string{"5254.0055.B680"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 1)
+}
+
+func (s *controllerSuite) TestMachinesInvalidMACAddress(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Machines(MachinesArgs{MACAddresses: []string{"52:54:00"}})
+	c.Check(err, jc.Satisfies, IsArgumentError)
+}
@@ -4,16 +4,25 @@
 package gomaasapi
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
+	"github.com/juju/utils/set"
 	"github.com/juju/version"
 )
 
 type machine struct {
+	rawJSON
+
 	controller *controller
 
 	resourceURI string
@@ -29,6 +38,8 @@ type machine struct {
 	architecture    string
 	memory          int
 	cpuCount        int
+	hweKernel       string
+	minHWEKernel    string
 
 	ipAddresses []string
 	powerState  string
@@ -46,6 +57,7 @@ type machine struct {
 }
 
 func (m *machine) updateFrom(other *machine) {
+	m.rawJSON = other.rawJSON
 	m.resourceURI = other.resourceURI
 	m.systemID = other.systemID
 	m.hostname = other.hostname
@@ -55,6 +67,8 @@ func (m *machine) updateFrom(other *machine) {
 	m.architecture = other.architecture
 	m.memory = other.memory
 	m.cpuCount = other.cpuCount
+	m.hweKernel = other.hweKernel
+	m.minHWEKernel = other.minHWEKernel
 	m.ipAddresses = other.ipAddresses
 	m.powerState = other.powerState
 	m.statusName = other.statusName
@@ -220,15 +234,62 @@ type StartArgs struct {
 	DistroSeries string
 	Kernel       string
 	Comment      string
+
+	// VMHost, if set, deploys the machine as a VM host of that type, which
+	// MAAS registers once deployment completes. See DeployVMHost.
+	VMHost VMHostType
+
+	// CheckImage, if true, has Start read the boot resources before asking
+	// MAAS to deploy, and return an ArgumentError listing the available
+	// images if none matches DistroSeries, the machine's architecture and
+	// Kernel. It has no effect if DistroSeries is empty, as the default
+	// series is chosen by MAAS. See CheckDeployImage.
+	CheckImage bool
+}
+
+// distroSeriesPattern matches a series name, optionally prefixed with the
+// operating system, such as "xenial" or "centos/centos70".
+var distroSeriesPattern = regexp.MustCompile(`^([a-z0-9-]+/)?[a-z0-9.-]+$`)
+
+// Validate checks that UserData is Base64 encoded and that DistroSeries and
+// Kernel are well formed, returning an ArgumentError if not.
+func (a *StartArgs) Validate() error {
+	if _, err := base64.StdEncoding.DecodeString(a.UserData); err != nil {
+		return NewArgumentError("UserData", "not Base64 encoded: %v", err)
+	}
+	if a.DistroSeries != "" && !distroSeriesPattern.MatchString(a.DistroSeries) {
+		return NewArgumentError("DistroSeries", "%q is not a valid series", a.DistroSeries)
+	}
+	if strings.IndexFunc(a.Kernel, unicode.IsSpace) >= 0 {
+		return NewArgumentError("Kernel", "%q contains white space", a.Kernel)
+	}
+	if err := a.VMHost.Validate(); err != nil {
+		return NewArgumentError("VMHost", "%v", err)
+	}
+	return nil
 }
 
 // Start implements Machine.
 func (m *machine) Start(args StartArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.CheckImage {
+		if err := m.checkDeployImage(args); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	params := NewURLParams()
 	params.MaybeAdd("user_data", args.UserData)
 	params.MaybeAdd("distro_series", args.DistroSeries)
 	params.MaybeAdd("hwe_kernel", args.Kernel)
 	params.MaybeAdd("comment", args.Comment)
+	switch args.VMHost {
+	case VMHostKVM:
+		params.Values.Add("install_kvm", "true")
+	case VMHostLXD:
+		params.Values.Add("register_vmhost", "true")
+	}
 	result, err := m.controller.post(m.resourceURI, "deploy", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(ServerError); ok {
@@ -244,7 +305,7 @@ func (m *machine) Start(args StartArgs) error {
 		return NewUnexpectedError(err)
 	}
 
-	machine, err := readMachine(m.controller.apiVersion, result)
+	machine, err := readMachine(m.controller.apiVersion, m.controller.markLeaves(result))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -252,6 +313,165 @@ func (m *machine) Start(args StartArgs) error {
 	return nil
 }
 
+// SetStorageLayout implements Machine.
+func (m *machine) SetStorageLayout(layout StorageLayout, options StorageLayoutOptions) error {
+	if err := options.Validate(layout); err != nil {
+		return errors.Trace(err)
+	}
+	params := options.params(layout)
+	result, err := m.controller.post(m.resourceURI, "set_storage_layout", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusConflict:
+				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+
+	machine, err := readMachine(m.controller.apiVersion, m.controller.markLeaves(result))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	// The new layout replaces the partitions on the block devices.
+	m.physicalBlockDevices = machine.physicalBlockDevices
+	m.blockDevices = machine.blockDevices
+	return nil
+}
+
+// InstallationResults implements Machine.
+func (m *machine) InstallationResults() ([]InstallationResult, error) {
+	params := NewURLParams()
+	params.Values.Add("system_id", m.systemID)
+	source, err := m.controller.getQuery("installation-results", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	results, err := readInstallationResults(m.controller.apiVersion, m.controller.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return results, nil
+}
+
+// InstallationLog implements Machine.
+func (m *machine) InstallationLog() ([]byte, error) {
+	results, err := m.InstallationResults()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, result := range results {
+		if result.Name == InstallLogName {
+			return result.Data, nil
+		}
+	}
+	return nil, NewNoMatchError(fmt.Sprintf("no installation log for machine %q", m.systemID))
+}
+
+// Events implements Machine.
+func (m *machine) Events(limit int) ([]Event, error) {
+	params := NewURLParams()
+	params.Values.Add("id", m.systemID)
+	params.MaybeAddInt("limit", limit)
+	source, err := m.controller._get("events", "query", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	events, err := readEvents(m.controller.apiVersion, m.controller.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return events, nil
+}
+
+// CurtinConfig implements Machine.
+func (m *machine) CurtinConfig() ([]byte, error) {
+	config, err := m.controller._getRaw(m.resourceURI, "get_curtin_config", nil)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	return config, nil
+}
+
+// failureInfoEventCount is the number of recent events that FailureInfo
+// includes in its report.
+const failureInfoEventCount = 20
+
+// FailureInfo is a report on why a machine entered a failed state. It
+// combines the status of the machine, the tail of its event log and, for
+// failed deployments, the installer output.
+type FailureInfo struct {
+	SystemID      string
+	Hostname      string
+	StatusName    string
+	StatusMessage string
+
+	// Events are the most recent events for the machine, newest first.
+	Events []Event
+
+	// InstallationLog is the installer console output. It is only set for
+	// failed deployments, and may be empty if MAAS recorded no output.
+	InstallationLog []byte
+}
+
+// FailureInfo implements Machine.
+func (m *machine) FailureInfo() (FailureInfo, error) {
+	if !strings.HasPrefix(m.statusName, "Failed") {
+		return FailureInfo{}, errors.NotValidf("FailureInfo for machine %q with status %q", m.systemID, m.statusName)
+	}
+	info := FailureInfo{
+		SystemID:      m.systemID,
+		Hostname:      m.hostname,
+		StatusName:    m.statusName,
+		StatusMessage: m.statusMessage,
+	}
+	events, err := m.Events(failureInfoEventCount)
+	if err != nil {
+		return FailureInfo{}, errors.Annotate(err, "reading events")
+	}
+	info.Events = events
+	if m.statusName == "Failed deployment" {
+		log, err := m.InstallationLog()
+		if err != nil && !IsNoMatchError(err) {
+			return FailureInfo{}, errors.Annotate(err, "reading installation log")
+		}
+		info.InstallationLog = log
+	}
+	return info, nil
+}
+
 // CreateMachineDeviceArgs is an argument structure for Machine.CreateDevice.
 // Only InterfaceName and MACAddress fields are required, the others are only
 // used if set. If Subnet and VLAN are both set, Subnet.VLAN() must match the
@@ -274,6 +494,10 @@ func (a *CreateMachineDeviceArgs) Validate() error {
 		return errors.NotValidf("missing MACAddress")
 	}
 
+	if _, err := NormalizeMACAddress(a.MACAddress); err != nil {
+		return errors.Trace(err)
+	}
+
 	if a.Subnet != nil && a.VLAN != nil && a.Subnet.VLAN() != a.VLAN {
 		msg := fmt.Sprintf(
 			"given subnet %q on VLAN %d does not match given VLAN %d",
@@ -388,7 +612,7 @@ func (m *machine) SetOwnerData(ownerData map[string]string) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
-	machine, err := readMachine(m.controller.apiVersion, result)
+	machine, err := readMachine(m.controller.apiVersion, m.controller.markLeaves(result))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -412,10 +636,18 @@ func readMachine(controllerVersion version.Number, source interface{}) (*machine
 }
 
 func readMachines(controllerVersion version.Number, source interface{}) ([]*machine, error) {
+	return readSelectedMachines(controllerVersion, source, nil)
+}
+
+// readSelectedMachines reads the machines keeping only the selected fields,
+// as described for MachinesArgs.Fields. All the fields are kept if none
+// are selected.
+func readSelectedMachines(controllerVersion version.Number, source interface{}, fields []string) ([]*machine, error) {
 	readFunc, err := getMachineDeserializationFunc(controllerVersion)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	readFunc = selectMachineFields(readFunc, fields)
 
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -455,34 +687,143 @@ func readMachineList(sourceList []interface{}, readFunc machineDeserializationFu
 	return result, nil
 }
 
+// readMachineStream decodes a JSON array of machines from the reader one
+// element at a time, keeping only the selected fields, and calls the
+// callback with each machine as it is read.
+// Only a single machine is held in memory at once. If the callback returns
+// an error, decoding stops and that error is returned.
+func readMachineStream(controllerVersion version.Number, mode DecodeMode, reader io.Reader, fields []string, callback func(*machine) error) error {
+	readFunc, err := getMachineDeserializationFunc(controllerVersion)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	readFunc = selectMachineFields(readFunc, fields)
+	decoder := json.NewDecoder(reader)
+	token, err := decoder.Token()
+	if err != nil {
+		return WrapWithDeserializationError(err, "machine stream")
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return NewDeserializationError("machine stream: expected array, got %v", token)
+	}
+	for i := 0; decoder.More(); i++ {
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return WrapWithDeserializationError(err, "machine %d", i)
+		}
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return NewDeserializationError("unexpected value for machine %d, %T", i, value)
+		}
+		if mode != DecodeDefault {
+			source = markLeavesPath(source, fmt.Sprintf("[%d]", i), mode).(map[string]interface{})
+		}
+		machine, err := readFunc(source)
+		if err != nil {
+			return errors.Annotatef(err, "machine %d", i)
+		}
+		if err := callback(machine); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return WrapWithDeserializationError(err, "machine stream")
+	}
+	return nil
+}
+
 type machineDeserializationFunc func(map[string]interface{}) (*machine, error)
 
+// alwaysSelectedMachineFields are kept whatever fields are selected, as a
+// machine cannot be acted on without them.
+var alwaysSelectedMachineFields = []string{"resource_uri", "system_id"}
+
+// machineZeroFields stand in for the fields that are not selected, so that
+// the schema check passes and the accessors return zero values.
+var machineZeroFields = map[string]interface{}{
+	"resource_uri":            "",
+	"system_id":               "",
+	"hostname":                "",
+	"fqdn":                    "",
+	"tag_names":               []interface{}{},
+	"owner_data":              map[string]interface{}{},
+	"osystem":                 "",
+	"distro_series":           "",
+	"architecture":            nil,
+	"memory":                  0,
+	"cpu_count":               0,
+	"ip_addresses":            []interface{}{},
+	"power_state":             "",
+	"status_name":             "",
+	"status_message":          nil,
+	"boot_interface":          nil,
+	"interface_set":           []interface{}{},
+	"zone":                    map[string]interface{}{"name": "", "description": "", "resource_uri": ""},
+	"physicalblockdevice_set": []interface{}{},
+	"blockdevice_set":         []interface{}{},
+}
+
+// selectMachineFields returns a deserialization func that drops the fields
+// of the source that are not selected before reading it with readFunc. The
+// raw JSON of the machine only has the selected fields.
+func selectMachineFields(readFunc machineDeserializationFunc, fields []string) machineDeserializationFunc {
+	if len(fields) == 0 {
+		return readFunc
+	}
+	keep := set.NewStrings(fields...).Union(set.NewStrings(alwaysSelectedMachineFields...))
+	return func(source map[string]interface{}) (*machine, error) {
+		selected := make(map[string]interface{}, keep.Size())
+		filled := make(map[string]interface{}, len(machineZeroFields))
+		for key, value := range machineZeroFields {
+			filled[key] = value
+		}
+		for key, value := range source {
+			if keep.Contains(key) {
+				selected[key] = value
+				filled[key] = value
+			}
+		}
+		result, err := readFunc(filled)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if !keep.Contains("zone") {
+			result.zone = nil
+		}
+		result.rawJSON = rawJSON{selected}
+		return result, nil
+	}
+}
+
 var machineDeserializationFuncs = map[version.Number]machineDeserializationFunc{
 	twoDotOh: machine_2_0,
 }
 
 func machine_2_0(source map[string]interface{}) (*machine, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
-
-		"system_id":  schema.String(),
-		"hostname":   schema.String(),
-		"fqdn":       schema.String(),
-		"tag_names":  schema.List(schema.String()),
-		"owner_data": schema.StringMap(schema.String()),
-
-		"osystem":       schema.String(),
-		"distro_series": schema.String(),
-		"architecture":  schema.OneOf(schema.Nil(""), schema.String()),
-		"memory":        schema.ForceInt(),
-		"cpu_count":     schema.ForceInt(),
-
-		"ip_addresses":   schema.List(schema.String()),
-		"power_state":    schema.String(),
-		"status_name":    schema.String(),
-		"status_message": schema.OneOf(schema.Nil(""), schema.String()),
-
-		"boot_interface": schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"resource_uri": stringField(),
+
+		"system_id":  stringField(),
+		"hostname":   stringField(),
+		"fqdn":       stringField(),
+		"tag_names":  schema.List(stringField()),
+		"owner_data": schema.StringMap(stringField()),
+
+		"osystem":       stringField(),
+		"distro_series": stringField(),
+		"architecture":  nullable(stringField()),
+		"memory":        intField(),
+		"cpu_count":     intField(),
+
+		"hwe_kernel":     nullable(stringField()),
+		"min_hwe_kernel": nullable(stringField()),
+
+		"ip_addresses":   schema.List(stringField()),
+		"power_state":    stringField(),
+		"status_name":    stringField(),
+		"status_message": nullable(stringField()),
+
+		"boot_interface": nullable(schema.StringMap(schema.Any())),
 		"interface_set":  schema.List(schema.StringMap(schema.Any())),
 		"zone":           schema.StringMap(schema.Any()),
 
@@ -490,7 +831,9 @@ func machine_2_0(source map[string]interface{}) (*machine, error) {
 		"blockdevice_set":         schema.List(schema.StringMap(schema.Any())),
 	}
 	defaults := schema.Defaults{
-		"architecture": "",
+		"architecture":   "",
+		"hwe_kernel":     "",
+		"min_hwe_kernel": "",
 	}
 	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(source, nil)
@@ -526,8 +869,11 @@ func machine_2_0(source map[string]interface{}) (*machine, error) {
 		return nil, errors.Trace(err)
 	}
 	architecture, _ := valid["architecture"].(string)
+	hweKernel, _ := valid["hwe_kernel"].(string)
+	minHWEKernel, _ := valid["min_hwe_kernel"].(string)
 	statusMessage, _ := valid["status_message"].(string)
 	result := &machine{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 
 		systemID:  valid["system_id"].(string),
@@ -541,6 +887,8 @@ func machine_2_0(source map[string]interface{}) (*machine, error) {
 		architecture:    architecture,
 		memory:          valid["memory"].(int),
 		cpuCount:        valid["cpu_count"].(int),
+		hweKernel:       hweKernel,
+		minHWEKernel:    minHWEKernel,
 
 		ipAddresses:   convertToStringSlice(valid["ip_addresses"]),
 		powerState:    valid["power_state"].(string),
@@ -4,8 +4,10 @@
 package gomaasapi
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/juju/errors"
 	"github.com/juju/testing"
@@ -133,6 +135,155 @@ func (s *machineSuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, *machine
 	return server, machine
 }
 
+func (s *machineSuite) TestInstallationResults(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusOK, installationResultsResponse)
+	results, err := machine.InstallationResults()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 2)
+	c.Assert(results[1].Name, gc.Equals, CurtinLogsName)
+}
+
+func (s *machineSuite) TestInstallationResultsForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusForbidden, "not yours")
+	_, err := machine.InstallationResults()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *machineSuite) TestInstallationLog(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusOK, installationResultsResponse)
+	log, err := machine.InstallationLog()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(log), gc.Equals, "curtin: Installation started.\ncurtin: Installation finished.\n")
+}
+
+func (s *machineSuite) TestInstallationLogMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusOK, "[]")
+	_, err := machine.InstallationLog()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, `no installation log for machine "4y3ha3"`)
+}
+
+func (s *machineSuite) TestEvents(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&limit=5&op=query", http.StatusOK, eventsResponse)
+	events, err := machine.Events(5)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(events, gc.HasLen, 2)
+	c.Assert(events[0].Type, gc.Equals, "Failed deployment")
+}
+
+func (s *machineSuite) TestFailureInfoNotFailed(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	_, err := machine.FailureInfo()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestFailureInfoDeployment(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.statusName = "Failed deployment"
+	machine.statusMessage = "Installation failed"
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&limit=20&op=query", http.StatusOK, eventsResponse)
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusOK, installationResultsResponse)
+	info, err := machine.FailureInfo()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(info.SystemID, gc.Equals, "4y3ha3")
+	c.Check(info.Hostname, gc.Equals, "untasted-markita")
+	c.Check(info.StatusName, gc.Equals, "Failed deployment")
+	c.Check(info.StatusMessage, gc.Equals, "Installation failed")
+	c.Check(info.Events, gc.HasLen, 2)
+	c.Check(string(info.InstallationLog), jc.Contains, "Installation finished")
+}
+
+func (s *machineSuite) TestFailureInfoDeploymentNoLog(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.statusName = "Failed deployment"
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&limit=20&op=query", http.StatusOK, eventsResponse)
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusOK, "[]")
+	info, err := machine.FailureInfo()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(info.InstallationLog, gc.HasLen, 0)
+}
+
+func (s *machineSuite) TestFailureInfoCommissioning(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.statusName = "Failed commissioning"
+	server.AddGetResponse("/api/2.0/events/?id=4y3ha3&limit=20&op=query", http.StatusOK, eventsResponse)
+	info, err := machine.FailureInfo()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(info.Events, gc.HasLen, 2)
+	c.Check(info.InstallationLog, gc.IsNil)
+	// Only the events are requested.
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *machineSuite) TestFailureInfoEventsError(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	machine.statusName = "Failed deployment"
+	_, err := machine.FailureInfo()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, gc.ErrorMatches, "(?s)reading events: .*")
+}
+
+func (s *machineSuite) TestCurtinConfig(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	config := "partitioning_commands:\n  builtin: [curtin, block-meta, custom]\n"
+	server.AddGetResponse(machine.resourceURI+"?op=get_curtin_config", http.StatusOK, config)
+	result, err := machine.CurtinConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(result), gc.Equals, config)
+}
+
+func (s *machineSuite) TestCurtinConfigNotDeploying(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_curtin_config", http.StatusBadRequest, "Failed to retrieve curtin config: Node must be in the DEPLOYING or DEPLOYED state.")
+	_, err := machine.CurtinConfig()
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *machineSuite) TestSetStorageLayout(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name": "Ready",
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", http.StatusOK, response)
+	err := machine.SetStorageLayout(StorageLayoutLVM, StorageLayoutOptions{
+		RootSize: 10 * 1024 * 1024 * 1024,
+		LVM:      &LVMLayoutOptions{VolumeGroupName: "vg0"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Ready")
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("storage_layout"), gc.Equals, "lvm")
+	c.Check(form.Get("root_size"), gc.Equals, "10737418240")
+	c.Check(form.Get("vg_name"), gc.Equals, "vg0")
+}
+
+func (s *machineSuite) TestSetStorageLayoutValidates(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	err := machine.SetStorageLayout(StorageLayoutFlat, StorageLayoutOptions{LVM: &LVMLayoutOptions{}})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestSetStorageLayoutNotReady(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", http.StatusConflict, "Cannot change the storage layout on a node that is not Ready.")
+	err := machine.SetStorageLayout(StorageLayoutFlat, StorageLayoutOptions{})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *machineSuite) TestSetStorageLayoutBadRequest(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", http.StatusBadRequest, "root_device: not a device")
+	err := machine.SetStorageLayout(StorageLayoutFlat, StorageLayoutOptions{RootDevice: 99})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+}
+
 func (s *machineSuite) TestStart(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	response := updateJSONMap(c, machineResponse, map[string]interface{}{
@@ -161,6 +312,44 @@ func (s *machineSuite) TestStart(c *gc.C) {
 	c.Check(form.Get("comment"), gc.Equals, "a comment")
 }
 
+func (s *machineSuite) TestStartArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args StartArgs
+		err  string
+	}{{
+		args: StartArgs{},
+	}, {
+		args: StartArgs{UserData: "I2Nsb3VkLWNvbmZpZwo=", DistroSeries: "ubuntu/xenial", Kernel: "hwe-16.04"},
+	}, {
+		args: StartArgs{DistroSeries: "centos70"},
+	}, {
+		args: StartArgs{UserData: "#cloud-config"},
+		err:  "UserData: not Base64 encoded: .*",
+	}, {
+		args: StartArgs{DistroSeries: "Xenial Xerus"},
+		err:  `DistroSeries: "Xenial Xerus" is not a valid series`,
+	}, {
+		args: StartArgs{Kernel: "hwe 16.04"},
+		err:  `Kernel: "hwe 16.04" contains white space`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, IsArgumentError)
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
+
+func (s *machineSuite) TestStartValidatesBeforeRequest(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	err := machine.Start(StartArgs{UserData: "not base64!"})
+	c.Assert(err, jc.Satisfies, IsArgumentError)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
 func (s *machineSuite) TestStartMachineNotFound(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusNotFound, "can't find machine")
@@ -235,7 +424,7 @@ func (s *machineSuite) TestCreateMachineDeviceArgsValidate(c *gc.C) {
 	}, {
 		args: CreateMachineDeviceArgs{
 			InterfaceName: "eth1",
-			MACAddress:    "something",
+			MACAddress:    "52:54:00:c9:6a:49",
 			Subnet: &fakeSubnet{
 				cidr: "1.2.3.4/5",
 				vlan: &fakeVLAN{id: 42},
@@ -247,21 +436,21 @@ func (s *machineSuite) TestCreateMachineDeviceArgsValidate(c *gc.C) {
 		args: CreateMachineDeviceArgs{
 			Hostname:      "is-optional",
 			InterfaceName: "eth1",
-			MACAddress:    "something",
+			MACAddress:    "52:54:00:c9:6a:49",
 			Subnet:        nil,
 			VLAN:          &fakeVLAN{},
 		},
 	}, {
 		args: CreateMachineDeviceArgs{
 			InterfaceName: "eth1",
-			MACAddress:    "something",
+			MACAddress:    "52:54:00:c9:6a:49",
 			Subnet:        &fakeSubnet{},
 			VLAN:          nil,
 		},
 	}, {
 		args: CreateMachineDeviceArgs{
 			InterfaceName: "eth1",
-			MACAddress:    "something",
+			MACAddress:    "52:54:00:c9:6a:49",
 			Subnet:        nil,
 			VLAN:          nil,
 		},
@@ -287,7 +476,7 @@ func (s *machineSuite) TestCreateDeviceValidates(c *gc.C) {
 func (s *machineSuite) TestCreateDevice(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	// The createDeviceResponse returns a single interface with the name "eth0".
-	server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, createDeviceResponse)
+	server.AddPostResponse("/api/2.0/devices/", http.StatusOK, createDeviceResponse)
 	updateInterfaceResponse := updateJSONMap(c, interfaceResponse, map[string]interface{}{
 		"name":         "eth4",
 		"links":        []interface{}{},
@@ -302,7 +491,7 @@ func (s *machineSuite) TestCreateDevice(c *gc.C) {
 	subnet := machine.BootInterface().Links()[0].Subnet()
 	device, err := machine.CreateDevice(CreateMachineDeviceArgs{
 		InterfaceName: "eth4",
-		MACAddress:    "fake-mac-address",
+		MACAddress:    "52:54:00:c9:6a:4a",
 		Subnet:        subnet,
 		VLAN:          subnet.VLAN(),
 	})
@@ -314,7 +503,7 @@ func (s *machineSuite) TestCreateDevice(c *gc.C) {
 func (s *machineSuite) TestCreateDeviceWithoutSubnetOrVLAN(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	// The createDeviceResponse returns a single interface with the name "eth0".
-	server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, createDeviceResponse)
+	server.AddPostResponse("/api/2.0/devices/", http.StatusOK, createDeviceResponse)
 	updateInterfaceResponse := updateJSONMap(c, interfaceResponse, map[string]interface{}{
 		"name":         "eth4",
 		"links":        []interface{}{},
@@ -323,7 +512,7 @@ func (s *machineSuite) TestCreateDeviceWithoutSubnetOrVLAN(c *gc.C) {
 	server.AddPutResponse("/MAAS/api/2.0/nodes/4y3haf/interfaces/48/", http.StatusOK, updateInterfaceResponse)
 	device, err := machine.CreateDevice(CreateMachineDeviceArgs{
 		InterfaceName: "eth4",
-		MACAddress:    "fake-mac-address",
+		MACAddress:    "52:54:00:c9:6a:4a",
 		Subnet:        nil,
 		VLAN:          nil,
 	})
@@ -337,7 +526,7 @@ func (s *machineSuite) TestCreateDeviceWithoutSubnetOrVLAN(c *gc.C) {
 func (s *machineSuite) TestCreateDeviceWithVLANOnly(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	// The createDeviceResponse returns a single interface with the name "eth0".
-	server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, createDeviceResponse)
+	server.AddPostResponse("/api/2.0/devices/", http.StatusOK, createDeviceResponse)
 	updateInterfaceResponse := updateJSONMap(c, interfaceResponse, map[string]interface{}{
 		"name": "eth4",
 		"vlan": map[string]interface{}{
@@ -354,7 +543,7 @@ func (s *machineSuite) TestCreateDeviceWithVLANOnly(c *gc.C) {
 	server.AddPutResponse("/MAAS/api/2.0/nodes/4y3haf/interfaces/48/", http.StatusOK, updateInterfaceResponse)
 	device, err := machine.CreateDevice(CreateMachineDeviceArgs{
 		InterfaceName: "eth4",
-		MACAddress:    "fake-mac-address",
+		MACAddress:    "52:54:00:c9:6a:4a",
 		Subnet:        nil,
 		VLAN:          &fakeVLAN{id: 42},
 	})
@@ -367,7 +556,7 @@ func (s *machineSuite) TestCreateDeviceWithVLANOnly(c *gc.C) {
 func (s *machineSuite) TestCreateDeviceTriesToDeleteDeviceOnError(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	// The createDeviceResponse returns a single interface with the name "eth0".
-	server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, createDeviceResponse)
+	server.AddPostResponse("/api/2.0/devices/", http.StatusOK, createDeviceResponse)
 	updateInterfaceResponse := updateJSONMap(c, interfaceResponse, map[string]interface{}{
 		"name":         "eth4",
 		"links":        []interface{}{},
@@ -379,7 +568,7 @@ func (s *machineSuite) TestCreateDeviceTriesToDeleteDeviceOnError(c *gc.C) {
 	subnet := machine.BootInterface().Links()[0].Subnet()
 	_, err := machine.CreateDevice(CreateMachineDeviceArgs{
 		InterfaceName: "eth4",
-		MACAddress:    "fake-mac-address",
+		MACAddress:    "52:54:00:c9:6a:4a",
 		Subnet:        subnet,
 	})
 	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
@@ -1362,3 +1551,104 @@ var (
 ]
 `
 )
+
+func (*machineSuite) TestReadMachineStream(c *gc.C) {
+	var machines []*machine
+	err := readMachineStream(twoDotOh, DecodeDefault, strings.NewReader(machinesResponse), nil, func(m *machine) error {
+		machines = append(machines, m)
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	c.Assert(machines[0].SystemID(), gc.Equals, "4y3ha3")
+}
+
+func (*machineSuite) TestReadMachineStreamNotArray(c *gc.C) {
+	err := readMachineStream(twoDotOh, DecodeDefault, strings.NewReader(machineResponse), nil, func(*machine) error {
+		return nil
+	})
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `machine stream: expected array, got {`)
+}
+
+func (*machineSuite) TestReadMachineStreamTruncated(c *gc.C) {
+	truncated := machinesResponse[:len(machinesResponse)/2]
+	count := 0
+	err := readMachineStream(twoDotOh, DecodeDefault, strings.NewReader(truncated), nil, func(*machine) error {
+		count++
+		return nil
+	})
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(count, gc.Not(gc.Equals), 3)
+}
+
+func (*machineSuite) TestReadMachineStreamStrictPath(c *gc.C) {
+	source := "[" + machineResponse + "," + strings.Replace(machineResponse, `"hostname": "untasted-markita"`, `"hostname": 42`, 1) + "]"
+	err := readMachineStream(twoDotOh, DecodeStrict, strings.NewReader(source), nil, func(*machine) error {
+		return nil
+	})
+	parseErr, ok := GetParseError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(parseErr.Path, gc.Equals, "[1].hostname")
+}
+
+func (*machineSuite) TestReadSelectedMachines(c *gc.C) {
+	machines, err := readSelectedMachines(twoDotOh, parseJSON(c, machinesResponse), []string{"hostname", "status_name"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	machine := machines[0]
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(machine.Hostname(), gc.Equals, "untasted-markita")
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+	c.Check(machine.resourceURI, gc.Equals, "/MAAS/api/2.0/machines/4y3ha3/")
+	c.Check(machine.FQDN(), gc.Equals, "")
+	c.Check(machine.Memory(), gc.Equals, 0)
+	c.Check(machine.Tags(), gc.HasLen, 0)
+	c.Check(machine.InterfaceSet(), gc.HasLen, 0)
+	c.Check(machine.BootInterface(), gc.IsNil)
+	c.Check(machine.Zone(), gc.IsNil)
+
+	var raw map[string]interface{}
+	c.Assert(json.Unmarshal(machine.Raw(), &raw), jc.ErrorIsNil)
+	c.Check(raw, gc.HasLen, 4)
+	c.Check(raw["hostname"], gc.Equals, "untasted-markita")
+}
+
+func (*machineSuite) TestReadSelectedMachinesZone(c *gc.C) {
+	machines, err := readSelectedMachines(twoDotOh, parseJSON(c, machinesResponse), []string{"zone"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines[0].Zone(), gc.NotNil)
+	c.Check(machines[0].Zone().Name(), gc.Equals, "default")
+	c.Check(machines[0].Hostname(), gc.Equals, "")
+}
+
+func (*machineSuite) TestReadSelectedMachinesStrict(c *gc.C) {
+	source := markLeaves(parseJSON(c, machinesResponse), DecodeStrict)
+	machines, err := readSelectedMachines(twoDotOh, source, []string{"memory"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].Memory(), gc.Equals, 1024)
+	c.Check(machines[0].CPUCount(), gc.Equals, 0)
+}
+
+func (*machineSuite) TestReadSelectedMachinesBadField(c *gc.C) {
+	source := "[" + strings.Replace(machineResponse, `"hostname": "untasted-markita"`, `"hostname": ["a"]`, 1) + "]"
+	_, err := readSelectedMachines(twoDotOh, parseJSON(c, source), []string{"hostname"})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+
+	// Fields that are not selected are not checked.
+	machines, err := readSelectedMachines(twoDotOh, parseJSON(c, source), []string{"status_name"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].Hostname(), gc.Equals, "")
+}
+
+func (*machineSuite) TestReadMachineStreamFields(c *gc.C) {
+	var machines []*machine
+	err := readMachineStream(twoDotOh, DecodeDefault, strings.NewReader(machinesResponse), []string{"hostname"}, func(m *machine) error {
+		machines = append(machines, m)
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	c.Check(machines[0].Hostname(), gc.Equals, "untasted-markita")
+	c.Check(machines[0].StatusName(), gc.Equals, "")
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+)
+
+// MarkBroken implements Machine.
+func (m *machine) MarkBroken(reason string) error {
+	return errors.Trace(m.action("mark_broken", reason))
+}
+
+// MarkFixed implements Machine.
+func (m *machine) MarkFixed(comment string) error {
+	return errors.Trace(m.action("mark_fixed", comment))
+}
+
+// Abort implements Machine.
+func (m *machine) Abort(comment string) error {
+	return errors.Trace(m.action("abort", comment))
+}
+
+// action posts the op for the machine with the comment, if any, and
+// updates the machine from the response. A machine whose status does not
+// allow the op is reported as a CannotCompleteError.
+func (m *machine) action(op, comment string) error {
+	params := NewURLParams()
+	params.MaybeAdd("comment", comment)
+	result, err := m.controller.post(m.resourceURI, op, params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusConflict, http.StatusServiceUnavailable:
+				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	machine, err := readMachine(m.controller.apiVersion, m.controller.markLeaves(result))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
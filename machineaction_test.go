@@ -0,0 +1,55 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *machineSuite) TestMarkBroken(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	broken := updateJSONMap(c, machineResponse, map[string]interface{}{"status_name": "Broken"})
+	server.AddPostResponse(machine.resourceURI+"?op=mark_broken", http.StatusOK, broken)
+	err := machine.MarkBroken("disk 2 clicking")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Broken")
+	c.Check(server.LastRequest().PostForm.Get("comment"), gc.Equals, "disk 2 clicking")
+}
+
+func (s *machineSuite) TestMarkFixed(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	ready := updateJSONMap(c, machineResponse, map[string]interface{}{"status_name": "Ready"})
+	server.AddPostResponse(machine.resourceURI+"?op=mark_fixed", http.StatusOK, ready)
+	err := machine.MarkFixed("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.StatusName(), gc.Equals, "Ready")
+	_, sent := server.LastRequest().PostForm["comment"]
+	c.Check(sent, jc.IsFalse)
+}
+
+func (s *machineSuite) TestMarkFixedNotBroken(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=mark_fixed", http.StatusConflict, "Node cannot be marked fixed")
+	err := machine.MarkFixed("replaced disk")
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, "Node cannot be marked fixed")
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+}
+
+func (s *machineSuite) TestAbort(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=abort", http.StatusOK, machineResponse)
+	err := machine.Abort("wrong series")
+	c.Assert(err, jc.ErrorIsNil)
+	request := server.LastRequest()
+	c.Check(request.URL.Query().Get("op"), gc.Equals, "abort")
+	c.Check(request.PostForm.Get("comment"), gc.Equals, "wrong series")
+
+	server.AddPostResponse(machine.resourceURI+"?op=abort", http.StatusForbidden, "not yours")
+	err = machine.Abort("")
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
@@ -0,0 +1,325 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// MachineChangeKind says how a machine in a MachineCache changed.
+type MachineChangeKind string
+
+const (
+	// MachineAdded is reported for a machine that was not in the cache.
+	MachineAdded MachineChangeKind = "added"
+	// MachineChanged is reported when a cached machine is replaced by one
+	// with different content.
+	MachineChanged MachineChangeKind = "changed"
+	// MachineRemoved is reported when a machine leaves the cache.
+	MachineRemoved MachineChangeKind = "removed"
+)
+
+// MachineChange is passed to the subscribers of a MachineCache.
+type MachineChange struct {
+	Kind     MachineChangeKind
+	SystemID string
+	// Machine is the new state of the machine. It is nil if the machine
+	// was removed.
+	Machine Machine
+	// Previous is the state of the machine before the change. It is nil
+	// if the machine was added.
+	Previous Machine
+}
+
+// MachineCache is an in-memory view of machines, indexed by system ID,
+// hostname and tag, that is kept current from change notifications. It
+// is safe for concurrent use.
+//
+// The cache does not watch MAAS itself. The owner either polls with
+// Refresh, or calls Invalidate for the machines that a notification
+// reports as changed and then Sync to read them again. Machines can also
+// be given directly to Update and Remove.
+type MachineCache struct {
+	mu         sync.RWMutex
+	machines   map[string]Machine
+	byHostname map[string]string
+	byTag      map[string]set.Strings
+	stale      set.Strings
+
+	// notifyMu serializes the calls to subscribers so that they see the
+	// changes in the order they were made.
+	notifyMu    sync.Mutex
+	subscribers map[int]func(MachineChange)
+	nextID      int
+}
+
+// NewMachineCache returns an empty MachineCache.
+func NewMachineCache() *MachineCache {
+	return &MachineCache{
+		machines:    make(map[string]Machine),
+		byHostname:  make(map[string]string),
+		byTag:       make(map[string]set.Strings),
+		stale:       set.NewStrings(),
+		subscribers: make(map[int]func(MachineChange)),
+	}
+}
+
+// Subscribe arranges for callback to be called with each change made to the
+// cache, after the change is made. Callbacks are called one at a time and
+// must not subscribe or unsubscribe. The returned function stops the
+// callbacks.
+func (mc *MachineCache) Subscribe(callback func(MachineChange)) (unsubscribe func()) {
+	mc.notifyMu.Lock()
+	defer mc.notifyMu.Unlock()
+	id := mc.nextID
+	mc.nextID++
+	mc.subscribers[id] = callback
+	return func() {
+		mc.notifyMu.Lock()
+		defer mc.notifyMu.Unlock()
+		delete(mc.subscribers, id)
+	}
+}
+
+// Get returns the machine with the system ID.
+func (mc *MachineCache) Get(systemID string) (Machine, bool) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	machine, ok := mc.machines[systemID]
+	return machine, ok
+}
+
+// ByHostname returns the machine with the hostname.
+func (mc *MachineCache) ByHostname(hostname string) (Machine, bool) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	machine, ok := mc.machines[mc.byHostname[hostname]]
+	return machine, ok
+}
+
+// ByTag returns the machines with the tag, ordered by system ID.
+func (mc *MachineCache) ByTag(tag string) []Machine {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	ids, ok := mc.byTag[tag]
+	if !ok {
+		return nil
+	}
+	var result []Machine
+	for _, id := range ids.SortedValues() {
+		result = append(result, mc.machines[id])
+	}
+	return result
+}
+
+// All returns the cached machines, ordered by system ID.
+func (mc *MachineCache) All() []Machine {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	ids := make([]string, 0, len(mc.machines))
+	for id := range mc.machines {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	result := make([]Machine, len(ids))
+	for i, id := range ids {
+		result[i] = mc.machines[id]
+	}
+	return result
+}
+
+// Len returns the number of cached machines.
+func (mc *MachineCache) Len() int {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return len(mc.machines)
+}
+
+// Update adds the machines to the cache, replacing any cached machines
+// with the same system IDs. Machines that are not in the list are kept.
+func (mc *MachineCache) Update(machines []Machine) {
+	mc.apply(machines, nil, false)
+}
+
+// Remove removes the machines with the system IDs from the cache.
+func (mc *MachineCache) Remove(systemIDs ...string) {
+	mc.apply(nil, systemIDs, false)
+}
+
+// Invalidate marks the machines with the system IDs as out of date, so that
+// the next Sync reads them again. The machines stay in the cache until then.
+func (mc *MachineCache) Invalidate(systemIDs ...string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for _, id := range systemIDs {
+		mc.stale.Add(id)
+	}
+}
+
+// Stale returns the system IDs that have been invalidated since the last
+// Sync or Refresh, sorted.
+func (mc *MachineCache) Stale() []string {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.stale.SortedValues()
+}
+
+// Sync reads the invalidated machines from the controller. Those that are
+// found replace the cached machines, and those that are not are removed.
+// If reading fails, the machines stay invalidated. Machines invalidated
+// while Sync is reading are left for the next Sync, as what was read may
+// predate their change.
+func (mc *MachineCache) Sync(controller Controller) error {
+	// The invalidations are taken before reading, so that those made
+	// during the read are kept.
+	mc.mu.Lock()
+	stale := mc.stale
+	mc.stale = set.NewStrings()
+	mc.mu.Unlock()
+	if stale.IsEmpty() {
+		return nil
+	}
+	machines, err := controller.Machines(MachinesArgs{SystemIDs: stale.SortedValues()})
+	if err != nil {
+		mc.mu.Lock()
+		mc.stale = mc.stale.Union(stale)
+		mc.mu.Unlock()
+		return errors.Annotate(err, "reading invalidated machines")
+	}
+	found := set.NewStrings()
+	for _, machine := range machines {
+		found.Add(machine.SystemID())
+	}
+	mc.apply(machines, stale.Difference(found).SortedValues(), false)
+	return nil
+}
+
+// Refresh replaces the contents of the cache with the machines matching
+// the args, as polling would. Cached machines that are not returned are
+// removed, and the invalidations made before the machines are read are
+// cleared. As for Sync, those made while Refresh is reading are kept.
+func (mc *MachineCache) Refresh(controller Controller, args MachinesArgs) error {
+	stale := set.NewStrings(mc.Stale()...)
+	machines, err := controller.Machines(args)
+	if err != nil {
+		return errors.Annotate(err, "reading machines")
+	}
+	mc.apply(machines, nil, true)
+	mc.mu.Lock()
+	mc.stale = mc.stale.Difference(stale)
+	mc.mu.Unlock()
+	return nil
+}
+
+// apply updates the indexes for the machines added and removed, then tells
+// the subscribers. If replace is true, cached machines that are not in
+// machines are removed as well.
+func (mc *MachineCache) apply(machines []Machine, removed []string, replace bool) {
+	// The notify lock is taken first so that another change cannot be
+	// reported between this change being made and it being reported.
+	mc.notifyMu.Lock()
+	defer mc.notifyMu.Unlock()
+
+	var changes []MachineChange
+	mc.mu.Lock()
+	seen := set.NewStrings()
+	for _, machine := range machines {
+		id := machine.SystemID()
+		seen.Add(id)
+		previous, ok := mc.machines[id]
+		if !ok {
+			changes = append(changes, MachineChange{Kind: MachineAdded, SystemID: id, Machine: machine})
+		} else {
+			if !sameRaw(previous, machine) {
+				changes = append(changes, MachineChange{Kind: MachineChanged, SystemID: id, Machine: machine, Previous: previous})
+			}
+			mc.unindex(previous)
+		}
+		mc.index(machine)
+	}
+	if replace {
+		for id := range mc.machines {
+			if !seen.Contains(id) {
+				removed = append(removed, id)
+			}
+		}
+		sort.Strings(removed)
+	}
+	for _, id := range removed {
+		previous, ok := mc.machines[id]
+		if !ok {
+			continue
+		}
+		mc.unindex(previous)
+		changes = append(changes, MachineChange{Kind: MachineRemoved, SystemID: id, Previous: previous})
+	}
+	mc.mu.Unlock()
+
+	for _, change := range changes {
+		for _, callback := range mc.sortedSubscribers() {
+			callback(change)
+		}
+	}
+}
+
+// sortedSubscribers returns the callbacks in the order they subscribed.
+// The caller must hold notifyMu.
+func (mc *MachineCache) sortedSubscribers() []func(MachineChange) {
+	ids := make([]int, 0, len(mc.subscribers))
+	for id := range mc.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	callbacks := make([]func(MachineChange), len(ids))
+	for i, id := range ids {
+		callbacks[i] = mc.subscribers[id]
+	}
+	return callbacks
+}
+
+// index adds the machine to the maps. The caller must hold mu.
+func (mc *MachineCache) index(machine Machine) {
+	id := machine.SystemID()
+	mc.machines[id] = machine
+	if hostname := machine.Hostname(); hostname != "" {
+		mc.byHostname[hostname] = id
+	}
+	for _, tag := range machine.Tags() {
+		ids, ok := mc.byTag[tag]
+		if !ok {
+			ids = set.NewStrings()
+			mc.byTag[tag] = ids
+		}
+		ids.Add(id)
+	}
+}
+
+// unindex removes the machine from the maps. The caller must hold mu.
+func (mc *MachineCache) unindex(machine Machine) {
+	id := machine.SystemID()
+	delete(mc.machines, id)
+	if hostname := machine.Hostname(); mc.byHostname[hostname] == id {
+		delete(mc.byHostname, hostname)
+	}
+	for _, tag := range machine.Tags() {
+		if ids, ok := mc.byTag[tag]; ok {
+			ids.Remove(id)
+			if ids.IsEmpty() {
+				delete(mc.byTag, tag)
+			}
+		}
+	}
+}
+
+// sameRaw returns true if the entities were read from the same JSON.
+// Entities without raw JSON are always taken to differ.
+func sameRaw(a, b RawEntity) bool {
+	rawA, rawB := a.Raw(), b.Raw()
+	return rawA != nil && rawB != nil && bytes.Equal(rawA, rawB)
+}
@@ -0,0 +1,268 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type machineCacheSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&machineCacheSuite{})
+
+func cacheMachineJSON(c *gc.C, systemID, hostname, status string, tags ...string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	return updateJSONMap(c, machineJSON(c, systemID, status, ""), map[string]interface{}{
+		"hostname":  hostname,
+		"tag_names": tags,
+	})
+}
+
+func cacheMachine(c *gc.C, systemID, hostname, status string, tags ...string) Machine {
+	machines, err := readMachines(twoDotOh, parseJSON(c, "["+cacheMachineJSON(c, systemID, hostname, status, tags...)+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	return machines[0]
+}
+
+type recordedChange struct {
+	kind     MachineChangeKind
+	systemID string
+}
+
+func recordChanges(cache *MachineCache) *[]recordedChange {
+	var changes []recordedChange
+	cache.Subscribe(func(change MachineChange) {
+		changes = append(changes, recordedChange{change.Kind, change.SystemID})
+	})
+	return &changes
+}
+
+func (*machineCacheSuite) TestUpdateIndexes(c *gc.C) {
+	cache := NewMachineCache()
+	cache.Update([]Machine{
+		cacheMachine(c, "a", "alpha", "Ready", "gpu", "nvme"),
+		cacheMachine(c, "b", "beta", "Ready", "gpu"),
+	})
+	c.Check(cache.Len(), gc.Equals, 2)
+	machine, ok := cache.Get("a")
+	c.Assert(ok, jc.IsTrue)
+	c.Check(machine.Hostname(), gc.Equals, "alpha")
+	machine, ok = cache.ByHostname("beta")
+	c.Assert(ok, jc.IsTrue)
+	c.Check(machine.SystemID(), gc.Equals, "b")
+	c.Check(selectedIDs(cache.ByTag("gpu")), jc.DeepEquals, []string{"a", "b"})
+	c.Check(selectedIDs(cache.ByTag("nvme")), jc.DeepEquals, []string{"a"})
+	c.Check(cache.ByTag("missing"), gc.HasLen, 0)
+	c.Check(selectedIDs(cache.All()), jc.DeepEquals, []string{"a", "b"})
+
+	// A new hostname and tags replace the old ones.
+	cache.Update([]Machine{cacheMachine(c, "a", "gamma", "Ready", "nvme")})
+	_, ok = cache.ByHostname("alpha")
+	c.Check(ok, jc.IsFalse)
+	machine, ok = cache.ByHostname("gamma")
+	c.Assert(ok, jc.IsTrue)
+	c.Check(machine.SystemID(), gc.Equals, "a")
+	c.Check(selectedIDs(cache.ByTag("gpu")), jc.DeepEquals, []string{"b"})
+}
+
+func (*machineCacheSuite) TestChangesReported(c *gc.C) {
+	cache := NewMachineCache()
+	changes := recordChanges(cache)
+	cache.Update([]Machine{cacheMachine(c, "a", "alpha", "Ready")})
+	// The same content is not reported again.
+	cache.Update([]Machine{cacheMachine(c, "a", "alpha", "Ready")})
+	cache.Update([]Machine{cacheMachine(c, "a", "alpha", "Deploying")})
+	cache.Remove("a", "unknown")
+	c.Check(*changes, jc.DeepEquals, []recordedChange{
+		{MachineAdded, "a"},
+		{MachineChanged, "a"},
+		{MachineRemoved, "a"},
+	})
+	_, ok := cache.Get("a")
+	c.Check(ok, jc.IsFalse)
+	c.Check(cache.ByTag("virtual"), gc.HasLen, 0)
+}
+
+func (*machineCacheSuite) TestChangeValues(c *gc.C) {
+	cache := NewMachineCache()
+	var changes []MachineChange
+	cache.Subscribe(func(change MachineChange) {
+		changes = append(changes, change)
+	})
+	cache.Update([]Machine{cacheMachine(c, "a", "alpha", "Ready")})
+	cache.Update([]Machine{cacheMachine(c, "a", "alpha", "Deploying")})
+	cache.Remove("a")
+	c.Assert(changes, gc.HasLen, 3)
+	c.Check(changes[0].Previous, gc.IsNil)
+	c.Check(changes[1].Previous.StatusName(), gc.Equals, "Ready")
+	c.Check(changes[1].Machine.StatusName(), gc.Equals, "Deploying")
+	c.Check(changes[2].Machine, gc.IsNil)
+	c.Check(changes[2].Previous.StatusName(), gc.Equals, "Deploying")
+}
+
+func (*machineCacheSuite) TestUnsubscribe(c *gc.C) {
+	cache := NewMachineCache()
+	calls := 0
+	unsubscribe := cache.Subscribe(func(MachineChange) { calls++ })
+	cache.Update([]Machine{cacheMachine(c, "a", "alpha", "Ready")})
+	unsubscribe()
+	cache.Remove("a")
+	c.Check(calls, gc.Equals, 1)
+}
+
+func (s *machineCacheSuite) TestRefresh(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	cache := NewMachineCache()
+	cache.Update([]Machine{
+		cacheMachine(c, "a", "alpha", "Ready"),
+		cacheMachine(c, "b", "beta", "Ready"),
+	})
+	cache.Invalidate("a")
+	changes := recordChanges(cache)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK,
+		"["+cacheMachineJSON(c, "a", "alpha", "Ready")+","+cacheMachineJSON(c, "c", "gamma", "Ready")+"]")
+
+	err := cache.Refresh(controller, MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(*changes, jc.DeepEquals, []recordedChange{
+		{MachineAdded, "c"},
+		{MachineRemoved, "b"},
+	})
+	c.Check(selectedIDs(cache.All()), jc.DeepEquals, []string{"a", "c"})
+	c.Check(cache.Stale(), gc.HasLen, 0)
+}
+
+func (s *machineCacheSuite) TestRefreshError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusInternalServerError, "boom")
+	cache := NewMachineCache()
+	err := cache.Refresh(controller, MachinesArgs{})
+	c.Assert(err, gc.ErrorMatches, "reading machines: .*")
+}
+
+func (s *machineCacheSuite) TestSync(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	cache := NewMachineCache()
+	cache.Update([]Machine{
+		cacheMachine(c, "a", "alpha", "Ready"),
+		cacheMachine(c, "b", "beta", "Ready"),
+		cacheMachine(c, "c", "gamma", "Ready"),
+	})
+	changes := recordChanges(cache)
+
+	// Nothing is read until a machine is invalidated.
+	c.Assert(cache.Sync(controller), jc.ErrorIsNil)
+
+	cache.Invalidate("b", "a")
+	c.Check(cache.Stale(), jc.DeepEquals, []string{"a", "b"})
+	server.AddGetResponse("/api/2.0/machines/?id=a&id=b", http.StatusOK, "["+cacheMachineJSON(c, "a", "alpha", "Deploying")+"]")
+	c.Assert(cache.Sync(controller), jc.ErrorIsNil)
+	c.Check(*changes, jc.DeepEquals, []recordedChange{
+		{MachineChanged, "a"},
+		{MachineRemoved, "b"},
+	})
+	c.Check(selectedIDs(cache.All()), jc.DeepEquals, []string{"a", "c"})
+	c.Check(cache.Stale(), gc.HasLen, 0)
+}
+
+func (s *machineCacheSuite) TestSyncErrorKeepsInvalidations(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=a", http.StatusInternalServerError, "boom")
+	cache := NewMachineCache()
+	cache.Invalidate("a")
+	err := cache.Sync(controller)
+	c.Assert(err, gc.ErrorMatches, "reading invalidated machines: .*")
+	c.Check(cache.Stale(), jc.DeepEquals, []string{"a"})
+}
+
+// invalidatingController calls invalidate when machines are read, as a
+// notification arriving during a Sync would.
+type invalidatingController struct {
+	Controller
+	invalidate func()
+}
+
+func (c invalidatingController) Machines(args MachinesArgs) ([]Machine, error) {
+	c.invalidate()
+	return c.Controller.Machines(args)
+}
+
+func (s *machineCacheSuite) TestSyncKeepsInvalidationsDuringRead(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=a", http.StatusOK, "["+cacheMachineJSON(c, "a", "alpha", "Deploying")+"]")
+	cache := NewMachineCache()
+	cache.Invalidate("a")
+	err := cache.Sync(invalidatingController{
+		Controller: controller,
+		invalidate: func() { cache.Invalidate("a", "b") },
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(selectedIDs(cache.All()), jc.DeepEquals, []string{"a"})
+	c.Check(cache.Stale(), jc.DeepEquals, []string{"a", "b"})
+}
+
+func (s *machineCacheSuite) TestRefreshKeepsInvalidationsDuringRead(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+cacheMachineJSON(c, "a", "alpha", "Ready")+"]")
+	cache := NewMachineCache()
+	cache.Invalidate("a")
+	err := cache.Refresh(invalidatingController{
+		Controller: controller,
+		invalidate: func() { cache.Invalidate("b") },
+	}, MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(cache.Stale(), jc.DeepEquals, []string{"b"})
+}
+
+func (s *machineCacheSuite) TestSyncErrorMergesInvalidations(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=a", http.StatusInternalServerError, "boom")
+	cache := NewMachineCache()
+	cache.Invalidate("a")
+	err := cache.Sync(invalidatingController{
+		Controller: controller,
+		invalidate: func() { cache.Invalidate("b") },
+	})
+	c.Assert(err, gc.ErrorMatches, "reading invalidated machines: .*")
+	c.Check(cache.Stale(), jc.DeepEquals, []string{"a", "b"})
+}
+
+func (*machineCacheSuite) TestConcurrentUse(c *gc.C) {
+	cache := NewMachineCache()
+	var mu sync.Mutex
+	changes := 0
+	cache.Subscribe(func(MachineChange) {
+		mu.Lock()
+		changes++
+		mu.Unlock()
+	})
+	var machines []Machine
+	for i := 0; i < 10; i++ {
+		machines = append(machines, cacheMachine(c, fmt.Sprintf("m%d", i), fmt.Sprintf("host%d", i), "Ready", "gpu"))
+	}
+	var wg sync.WaitGroup
+	for _, machine := range machines {
+		wg.Add(1)
+		go func(machine Machine) {
+			defer wg.Done()
+			cache.Update([]Machine{machine})
+			cache.ByTag("gpu")
+			cache.All()
+		}(machine)
+	}
+	wg.Wait()
+	c.Check(cache.Len(), gc.Equals, 10)
+	c.Check(cache.ByTag("gpu"), gc.HasLen, 10)
+	c.Check(changes, gc.Equals, 10)
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+)
+
+// UpdateMachineArgs is an argument struct for Machine.Update. Only the
+// fields that are set are sent, so the attributes that are left out keep
+// their values on the server, and a field set to its zero value, such as
+// Some(0) for SwapSize or Some("") for Description, is sent to clear the
+// attribute.
+type UpdateMachineArgs struct {
+	// Hostname, if set, renames the machine. Use SetHostname to move the
+	// machine to another domain as well.
+	Hostname Optional[string]
+
+	Description  Optional[string]
+	Architecture Optional[string]
+	CPUCount     Optional[int]
+	// Memory is in MiB.
+	Memory Optional[int]
+	// SwapSize is in bytes. Zero disables swap.
+	SwapSize Optional[int]
+	// MinHWEKernel is the oldest kernel the machine may be deployed with.
+	// An empty value removes the minimum.
+	MinHWEKernel Optional[string]
+	Zone         Optional[string]
+}
+
+// isEmpty returns whether no field is set.
+func (a *UpdateMachineArgs) isEmpty() bool {
+	return !(a.Hostname.set || a.Description.set || a.Architecture.set ||
+		a.CPUCount.set || a.Memory.set || a.SwapSize.set ||
+		a.MinHWEKernel.set || a.Zone.set)
+}
+
+// Validate checks the fields that are set.
+func (a *UpdateMachineArgs) Validate() error {
+	if name, ok := a.Hostname.Get(); ok {
+		if err := validateHostname(name, ""); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if arch, ok := a.Architecture.Get(); ok {
+		if _, err := NormalizeArchitecture(arch); err != nil {
+			return NewArgumentError("Architecture", "%q is not a valid architecture", arch)
+		}
+	}
+	if count, ok := a.CPUCount.Get(); ok && count < 1 {
+		return NewArgumentError("CPUCount", "%d must be at least 1", count)
+	}
+	if memory, ok := a.Memory.Get(); ok && memory < 1 {
+		return NewArgumentError("Memory", "%d must be at least 1", memory)
+	}
+	if size, ok := a.SwapSize.Get(); ok && size < 0 {
+		return NewArgumentError("SwapSize", "%d must not be negative", size)
+	}
+	if kernel, ok := a.MinHWEKernel.Get(); ok && strings.IndexFunc(kernel, unicode.IsSpace) >= 0 {
+		return NewArgumentError("MinHWEKernel", "%q contains white space", kernel)
+	}
+	return nil
+}
+
+func (a *UpdateMachineArgs) params() *URLParams {
+	params := NewURLParams()
+	addOptional(params, "hostname", a.Hostname)
+	addOptional(params, "description", a.Description)
+	if arch, ok := a.Architecture.Get(); ok {
+		arch, _ = NormalizeArchitecture(arch)
+		params.Values.Add("architecture", arch)
+	}
+	addOptional(params, "cpu_count", a.CPUCount)
+	addOptional(params, "memory", a.Memory)
+	addOptional(params, "swap_size", a.SwapSize)
+	addOptional(params, "min_hwe_kernel", a.MinHWEKernel)
+	addOptional(params, "zone", a.Zone)
+	return params
+}
+
+// Update implements Machine.
+func (m *machine) Update(args UpdateMachineArgs) error {
+	if args.isEmpty() {
+		return nil
+	}
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	result, err := m.controller.put(m.resourceURI, args.params().Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusNotFound:
+				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return NewUnexpectedError(err)
+	}
+	machine, err := readMachine(m.controller.apiVersion, m.controller.markLeaves(result))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
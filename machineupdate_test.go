@@ -0,0 +1,130 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type machineUpdateSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&machineUpdateSuite{})
+
+func (*machineUpdateSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    UpdateMachineArgs
+		message string
+	}{{
+		args: UpdateMachineArgs{Description: Some(""), SwapSize: Some(0), MinHWEKernel: Some("")},
+	}, {
+		args:    UpdateMachineArgs{Hostname: Some("node.maas")},
+		message: `name: "node.maas" contains a dot, pass the domain separately`,
+	}, {
+		args:    UpdateMachineArgs{Architecture: Some("amd 64")},
+		message: `Architecture: "amd 64" is not a valid architecture`,
+	}, {
+		args:    UpdateMachineArgs{CPUCount: Some(0)},
+		message: `CPUCount: 0 must be at least 1`,
+	}, {
+		args:    UpdateMachineArgs{Memory: Some(-1)},
+		message: `Memory: -1 must be at least 1`,
+	}, {
+		args:    UpdateMachineArgs{SwapSize: Some(-1)},
+		message: `SwapSize: -1 must not be negative`,
+	}, {
+		args:    UpdateMachineArgs{MinHWEKernel: Some("hwe 16.04")},
+		message: `MinHWEKernel: "hwe 16.04" contains white space`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.message == "" {
+			c.Check(err, jc.ErrorIsNil)
+			continue
+		}
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (*machineUpdateSuite) TestParams(c *gc.C) {
+	args := UpdateMachineArgs{
+		Description:  Some(""),
+		Architecture: Some("x86_64"),
+		Memory:       Some(4096),
+		SwapSize:     Some(0),
+	}
+	c.Check(args.params().Values, jc.DeepEquals, url.Values{
+		"description":  {""},
+		"architecture": {"amd64"},
+		"memory":       {"4096"},
+		"swap_size":    {"0"},
+	})
+}
+
+func (s *machineUpdateSuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, *machine) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, machines[0].(*machine)
+}
+
+func (s *machineUpdateSuite) TestUpdate(c *gc.C) {
+	server, m := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"memory":         8192,
+		"min_hwe_kernel": "",
+	})
+	server.AddPutResponse(m.resourceURI, http.StatusOK, response)
+
+	err := m.Update(UpdateMachineArgs{Memory: Some(8192), MinHWEKernel: Some("")})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(m.Memory(), gc.Equals, 8192)
+	c.Check(m.MinHWEKernel(), gc.Equals, "")
+	request := server.LastRequest()
+	c.Check(request.Method, gc.Equals, "PUT")
+	c.Check(request.PostForm, jc.DeepEquals, url.Values{
+		"memory":         {"8192"},
+		"min_hwe_kernel": {""},
+	})
+}
+
+func (s *machineUpdateSuite) TestUpdateNothing(c *gc.C) {
+	server, m := s.getServerAndMachine(c)
+	count := server.RequestCount()
+	err := m.Update(UpdateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *machineUpdateSuite) TestUpdateInvalid(c *gc.C) {
+	server, m := s.getServerAndMachine(c)
+	count := server.RequestCount()
+	err := m.Update(UpdateMachineArgs{CPUCount: Some(0)})
+	c.Assert(err, jc.Satisfies, IsArgumentError)
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *machineUpdateSuite) TestUpdateForbidden(c *gc.C) {
+	server, m := s.getServerAndMachine(c)
+	server.AddPutResponse(m.resourceURI, http.StatusForbidden, "not yours")
+	err := m.Update(UpdateMachineArgs{Description: Some("mine")})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// Region is a named MAAS region and its Controller, for NewMultiController.
+type Region struct {
+	Name       string
+	Controller Controller
+}
+
+// RegionMachine is a machine found by a MultiController, with the region
+// it is in.
+type RegionMachine struct {
+	Region  string
+	Machine Machine
+}
+
+// RegionError is the failure of a MultiController call in one region.
+type RegionError struct {
+	Region string
+	Err    error
+}
+
+// Error implements error.
+func (e RegionError) Error() string {
+	return fmt.Sprintf("region %s: %v", e.Region, e.Err)
+}
+
+type multiController struct {
+	regions []Region
+}
+
+// NewMultiController returns a MultiController for the regions, which must
+// have different, non-empty names and a Controller each.
+func NewMultiController(regions []Region) (MultiController, error) {
+	if len(regions) == 0 {
+		return nil, NewArgumentError("regions", "no regions")
+	}
+	seen := make(map[string]bool)
+	for _, region := range regions {
+		if region.Name == "" {
+			return nil, NewArgumentError("regions", "region with no name")
+		}
+		if seen[region.Name] {
+			return nil, NewArgumentError("regions", "duplicate region %q", region.Name)
+		}
+		if region.Controller == nil {
+			return nil, NewArgumentError("regions", "region %q has no controller", region.Name)
+		}
+		seen[region.Name] = true
+	}
+	return &multiController{regions: append([]Region(nil), regions...)}, nil
+}
+
+// Regions implements MultiController.
+func (m *multiController) Regions() []string {
+	names := make([]string, len(m.regions))
+	for i, region := range m.regions {
+		names[i] = region.Name
+	}
+	return names
+}
+
+// Region implements MultiController.
+func (m *multiController) Region(name string) Controller {
+	for _, region := range m.regions {
+		if region.Name == name {
+			return region.Controller
+		}
+	}
+	return nil
+}
+
+// Each implements MultiController.
+//
+// The errors are in the order of the regions.
+func (m *multiController) Each(call func(region string, c Controller) error) []RegionError {
+	errs := make([]error, len(m.regions))
+	var wg sync.WaitGroup
+	for i, region := range m.regions {
+		wg.Add(1)
+		go func(i int, region Region) {
+			defer wg.Done()
+			errs[i] = call(region.Name, region.Controller)
+		}(i, region)
+	}
+	wg.Wait()
+	var result []RegionError
+	for i, err := range errs {
+		if err != nil {
+			result = append(result, RegionError{Region: m.regions[i].Name, Err: err})
+		}
+	}
+	return result
+}
+
+// Machines implements MultiController.
+//
+// The machines are grouped by region, in the order of the regions.
+func (m *multiController) Machines(args MachinesArgs) ([]RegionMachine, []RegionError) {
+	found := make([][]Machine, len(m.regions))
+	index := make(map[string]int)
+	for i, region := range m.regions {
+		index[region.Name] = i
+	}
+	errs := m.Each(func(region string, c Controller) error {
+		machines, err := c.Machines(args)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		found[index[region]] = machines
+		return nil
+	})
+	var result []RegionMachine
+	for i, machines := range found {
+		for _, machine := range machines {
+			result = append(result, RegionMachine{Region: m.regions[i].Name, Machine: machine})
+		}
+	}
+	return result, errs
+}
+
+// FindMachinesByHostname implements MultiController.
+func (m *multiController) FindMachinesByHostname(hostname string) ([]RegionMachine, []RegionError) {
+	return m.Machines(MachinesArgs{Hostnames: []string{hostname}})
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type multiSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&multiSuite{})
+
+func (s *multiSuite) region(c *gc.C, name string) (*SimpleTestServer, Region) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	maas, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, Region{Name: name, Controller: maas}
+}
+
+func (s *multiSuite) TestNewMultiControllerValidates(c *gc.C) {
+	_, east := s.region(c, "east")
+	for i, test := range []struct {
+		regions []Region
+		message string
+	}{{
+		message: "regions: no regions",
+	}, {
+		regions: []Region{{Controller: east.Controller}},
+		message: "regions: region with no name",
+	}, {
+		regions: []Region{east, east},
+		message: `regions: duplicate region "east"`,
+	}, {
+		regions: []Region{{Name: "west"}},
+		message: `regions: region "west" has no controller`,
+	}} {
+		c.Logf("test %d", i)
+		_, err := NewMultiController(test.regions)
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *multiSuite) TestRegions(c *gc.C) {
+	_, east := s.region(c, "east")
+	_, west := s.region(c, "west")
+	multi, err := NewMultiController([]Region{west, east})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(multi.Regions(), jc.DeepEquals, []string{"west", "east"})
+	c.Check(multi.Region("east"), gc.Equals, east.Controller)
+	c.Check(multi.Region("north"), gc.IsNil)
+}
+
+func (s *multiSuite) TestFindMachinesByHostname(c *gc.C) {
+	eastServer, east := s.region(c, "east")
+	westServer, west := s.region(c, "west")
+	northServer, north := s.region(c, "north")
+	eastServer.AddGetResponse("/api/2.0/machines/?hostname=untasted-markita", http.StatusOK, "["+machineResponse+"]")
+	westServer.AddGetResponse("/api/2.0/machines/?hostname=untasted-markita", http.StatusOK, "[]")
+	northServer.AddGetResponse("/api/2.0/machines/?hostname=untasted-markita", http.StatusForbidden, "go away")
+	multi, err := NewMultiController([]Region{north, east, west})
+	c.Assert(err, jc.ErrorIsNil)
+
+	machines, errs := multi.FindMachinesByHostname("untasted-markita")
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].Region, gc.Equals, "east")
+	c.Check(machines[0].Machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Assert(errs, gc.HasLen, 1)
+	c.Check(errs[0].Region, gc.Equals, "north")
+	c.Check(errs[0].Err, jc.Satisfies, IsUnexpectedError)
+	c.Check(errs[0], gc.ErrorMatches, `region north: unexpected: ServerError: 403 Forbidden \(go away\)`)
+}
+
+func (s *multiSuite) TestMachinesOrderedByRegion(c *gc.C) {
+	eastServer, east := s.region(c, "east")
+	westServer, west := s.region(c, "west")
+	eastServer.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	westServer.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+","+machineResponse+"]")
+	multi, err := NewMultiController([]Region{west, east})
+	c.Assert(err, jc.ErrorIsNil)
+
+	machines, errs := multi.Machines(MachinesArgs{})
+	c.Check(errs, gc.HasLen, 0)
+	var regions []string
+	for _, m := range machines {
+		regions = append(regions, m.Region)
+	}
+	c.Check(regions, jc.DeepEquals, []string{"west", "west", "east"})
+}
+
+func (s *multiSuite) TestEach(c *gc.C) {
+	_, east := s.region(c, "east")
+	_, west := s.region(c, "west")
+	multi, err := NewMultiController([]Region{east, west})
+	c.Assert(err, jc.ErrorIsNil)
+	errs := multi.Each(func(region string, maas Controller) error {
+		if region == "west" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	c.Check(errs, jc.DeepEquals, []RegionError{{Region: "west", Err: errs[0].Err}})
+	c.Check(errs[0].Error(), gc.Equals, "region west: boom")
+}
@@ -0,0 +1,123 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// networkIndex caches the subnets, VLANs and spaces of a controller by the
+// keys they are most often looked up by. Each map is nil until it is
+// first needed, and is read again when a lookup misses, so that entities
+// added since it was read are found.
+type networkIndex struct {
+	mu      sync.Mutex
+	subnets map[string]Subnet
+	vlans   map[fabricVID]VLAN
+	spaces  map[string]Space
+}
+
+type fabricVID struct {
+	fabric string
+	vid    int
+}
+
+// cidrKey returns the network of the CIDR in its canonical form, so that
+// "10.0.0.1/24" and "10.0.0.0/24" find the same subnet.
+func cidrKey(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return network.String(), nil
+}
+
+// SubnetByCIDR implements Controller.
+func (c *controller) SubnetByCIDR(cidr string) (Subnet, error) {
+	key, err := cidrKey(cidr)
+	if err != nil {
+		return nil, NewArgumentError("cidr", "%q is not a valid CIDR", cidr)
+	}
+	index := c.networks
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if subnet, ok := index.subnets[key]; ok {
+		return subnet, nil
+	}
+	subnets, err := c.Subnets()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	index.subnets = make(map[string]Subnet, len(subnets))
+	for _, subnet := range subnets {
+		if key, err := cidrKey(subnet.CIDR()); err == nil {
+			index.subnets[key] = subnet
+		}
+	}
+	if subnet, ok := index.subnets[key]; ok {
+		return subnet, nil
+	}
+	return nil, NewNoMatchError(fmt.Sprintf("no subnet with CIDR %q", cidr))
+}
+
+// VLANByFabricAndVID implements Controller.
+func (c *controller) VLANByFabricAndVID(fabric string, vid int) (VLAN, error) {
+	key := fabricVID{fabric: fabric, vid: vid}
+	index := c.networks
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if vlan, ok := index.vlans[key]; ok {
+		return vlan, nil
+	}
+	fabrics, err := c.Fabrics()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	index.vlans = make(map[fabricVID]VLAN)
+	for _, f := range fabrics {
+		for _, vlan := range f.VLANs() {
+			index.vlans[fabricVID{fabric: f.Name(), vid: vlan.VID()}] = vlan
+		}
+	}
+	if vlan, ok := index.vlans[key]; ok {
+		return vlan, nil
+	}
+	return nil, NewNoMatchError(fmt.Sprintf("no VLAN %d on fabric %q", vid, fabric))
+}
+
+// SpaceByName implements Controller.
+func (c *controller) SpaceByName(name string) (Space, error) {
+	index := c.networks
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if space, ok := index.spaces[name]; ok {
+		return space, nil
+	}
+	spaces, err := c.Spaces()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	index.spaces = make(map[string]Space, len(spaces))
+	for _, space := range spaces {
+		index.spaces[space.Name()] = space
+	}
+	if space, ok := index.spaces[name]; ok {
+		return space, nil
+	}
+	return nil, NewNoMatchError(fmt.Sprintf("no space %q", name))
+}
+
+// InvalidateNetworkIndex implements Controller.
+func (c *controller) InvalidateNetworkIndex() {
+	index := c.networks
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.subnets = nil
+	index.vlans = nil
+	index.spaces = nil
+}
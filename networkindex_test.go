@@ -0,0 +1,83 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *controllerSuite) TestSubnetByCIDR(c *gc.C) {
+	controller := s.getController(c)
+	subnet, err := controller.SubnetByCIDR("192.168.122.0/24")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(subnet.CIDR(), gc.Equals, "192.168.122.0/24")
+
+	// Later lookups, by any address in the subnet, use the cache.
+	count := s.server.RequestCount()
+	subnet, err = controller.SubnetByCIDR("192.168.100.7/24")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(subnet.CIDR(), gc.Equals, "192.168.100.0/24")
+	c.Check(s.server.RequestCount(), gc.Equals, count)
+}
+
+func (s *controllerSuite) TestSubnetByCIDRMissReads(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.SubnetByCIDR("192.168.122.0/24")
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	count := s.server.RequestCount()
+	_, err = controller.SubnetByCIDR("10.0.0.0/8")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, `no subnet with CIDR "10.0.0.0/8"`)
+	c.Check(s.server.RequestCount(), gc.Equals, count+1)
+}
+
+func (s *controllerSuite) TestSubnetByCIDRInvalid(c *gc.C) {
+	controller := s.getController(c)
+	count := s.server.RequestCount()
+	_, err := controller.SubnetByCIDR("192.168.122.0")
+	c.Check(err, jc.Satisfies, IsArgumentError)
+	c.Check(s.server.RequestCount(), gc.Equals, count)
+}
+
+func (s *controllerSuite) TestVLANByFabricAndVID(c *gc.C) {
+	controller := s.getController(c)
+	vlan, err := controller.VLANByFabricAndVID("fabric-1", 0)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(vlan.Fabric(), gc.Equals, "fabric-1")
+	c.Check(vlan.VID(), gc.Equals, 0)
+
+	s.server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, fabricResponse)
+	_, err = controller.VLANByFabricAndVID("fabric-1", 10)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, `no VLAN 10 on fabric "fabric-1"`)
+}
+
+func (s *controllerSuite) TestSpaceByName(c *gc.C) {
+	controller := s.getController(c)
+	space, err := controller.SpaceByName("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(space.Name(), gc.Equals, "space-0")
+
+	s.server.AddGetResponse("/api/2.0/spaces/", http.StatusOK, spacesResponse)
+	_, err = controller.SpaceByName("space-9")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *controllerSuite) TestInvalidateNetworkIndex(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.SpaceByName("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	controller.InvalidateNetworkIndex()
+	s.server.AddGetResponse("/api/2.0/spaces/", http.StatusOK, spacesResponse)
+	count := s.server.RequestCount()
+	_, err = controller.SpaceByName("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.server.RequestCount(), gc.Equals, count+1)
+}
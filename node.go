@@ -0,0 +1,259 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// NodeType identifies the kind of a GenericNode, using the values of the MAAS
+// node_type field.
+type NodeType int
+
+const (
+	NodeTypeMachine                 NodeType = 0
+	NodeTypeDevice                  NodeType = 1
+	NodeTypeRackController          NodeType = 2
+	NodeTypeRegionController        NodeType = 3
+	NodeTypeRegionAndRackController NodeType = 4
+)
+
+var nodeTypeNames = map[NodeType]string{
+	NodeTypeMachine:                 "Machine",
+	NodeTypeDevice:                  "Device",
+	NodeTypeRackController:          "Rack controller",
+	NodeTypeRegionController:        "Region controller",
+	NodeTypeRegionAndRackController: "Region and rack controller",
+}
+
+// String returns the name MAAS uses for the node type.
+func (t NodeType) String() string {
+	if name, ok := nodeTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("NodeType(%d)", int(t))
+}
+
+// IsController reports whether the node type is a rack or region
+// controller.
+func (t NodeType) IsController() bool {
+	switch t {
+	case NodeTypeRackController, NodeTypeRegionController, NodeTypeRegionAndRackController:
+		return true
+	}
+	return false
+}
+
+// IsRackController reports whether the node type runs the rack controller
+// services.
+func (t NodeType) IsRackController() bool {
+	return t == NodeTypeRackController || t == NodeTypeRegionAndRackController
+}
+
+// IsRegionController reports whether the node type runs the region
+// controller services.
+func (t NodeType) IsRegionController() bool {
+	return t == NodeTypeRegionController || t == NodeTypeRegionAndRackController
+}
+
+type node struct {
+	rawJSON
+
+	controller *controller
+
+	resourceURI string
+
+	systemID string
+	hostname string
+	fqdn     string
+	nodeType NodeType
+
+	ipAddresses []string
+	zone        *zone
+}
+
+// SystemID implements GenericNode.
+func (n *node) SystemID() string {
+	return n.systemID
+}
+
+// Hostname implements GenericNode.
+func (n *node) Hostname() string {
+	return n.hostname
+}
+
+// FQDN implements GenericNode.
+func (n *node) FQDN() string {
+	return n.fqdn
+}
+
+// NodeType implements GenericNode.
+func (n *node) NodeType() NodeType {
+	return n.nodeType
+}
+
+// IPAddresses implements GenericNode.
+func (n *node) IPAddresses() []string {
+	return n.ipAddresses
+}
+
+// Zone implements GenericNode.
+func (n *node) Zone() Zone {
+	if n.zone == nil {
+		return nil
+	}
+	return n.zone
+}
+
+// NodesArgs is an argument struct for selecting nodes.
+type NodesArgs struct {
+	Hostnames    []string
+	MACAddresses []string
+	SystemIDs    []string
+	Domain       string
+	Zone         string
+
+	// NodeTypes, if not empty, limits the result to nodes of the given
+	// types. MAAS does not filter on the node type, so this is applied
+	// to the response.
+	NodeTypes []NodeType
+}
+
+// Nodes implements Controller.
+func (c *controller) Nodes(args NodesArgs) ([]GenericNode, error) {
+	macs, err := normalizeMACAddresses("MACAddresses", args.MACAddresses)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAddMany("hostname", args.Hostnames)
+	params.MaybeAddMany("mac_address", macs)
+	params.MaybeAddMany("id", args.SystemIDs)
+	params.MaybeAdd("domain", args.Domain)
+	params.MaybeAdd("zone", args.Zone)
+	source, err := c.getQuery("nodes", params.Values)
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	nodes, err := readNodes(c.apiVersion, c.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []GenericNode
+	for _, n := range nodes {
+		if !nodeTypeMatches(n.nodeType, args.NodeTypes) {
+			continue
+		}
+		n.controller = c
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func nodeTypeMatches(nodeType NodeType, types []NodeType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == nodeType {
+			return true
+		}
+	}
+	return false
+}
+
+func readNodes(controllerVersion version.Number, source interface{}) ([]*node, error) {
+	var deserialisationVersion version.Number
+	for v := range nodeDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no node read func for version %s", controllerVersion)
+	}
+	readFunc := nodeDeserializationFuncs[deserialisationVersion]
+
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	return readNodeList(valid, readFunc)
+}
+
+// readNodeList expects the values of the sourceList to be string maps.
+func readNodeList(sourceList []interface{}, readFunc nodeDeserializationFunc) ([]*node, error) {
+	result := make([]*node, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for node %d, %T", i, value)
+		}
+		node, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "node %d", i)
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+type nodeDeserializationFunc func(map[string]interface{}) (*node, error)
+
+var nodeDeserializationFuncs = map[version.Number]nodeDeserializationFunc{
+	twoDotOh: node_2_0,
+}
+
+func node_2_0(source map[string]interface{}) (*node, error) {
+	fields := schema.Fields{
+		"resource_uri": stringField(),
+
+		"system_id": stringField(),
+		"hostname":  stringField(),
+		"fqdn":      stringField(),
+		"node_type": intField(),
+
+		"ip_addresses": schema.List(stringField()),
+		"zone":         nullable(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"ip_addresses": []interface{}{},
+		"zone":         nil,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var zone *zone
+	if zoneMap, ok := valid["zone"].(map[string]interface{}); ok {
+		if zone, err = zone_2_0(zoneMap); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	result := &node{
+		rawJSON:     rawJSON{source},
+		resourceURI: valid["resource_uri"].(string),
+
+		systemID: valid["system_id"].(string),
+		hostname: valid["hostname"].(string),
+		fqdn:     valid["fqdn"].(string),
+		nodeType: NodeType(valid["node_type"].(int)),
+
+		ipAddresses: convertToStringSlice(valid["ip_addresses"]),
+		zone:        zone,
+	}
+	return result, nil
+}
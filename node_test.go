@@ -0,0 +1,190 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type nodeSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&nodeSuite{})
+
+func (*nodeSuite) TestNodeTypeString(c *gc.C) {
+	c.Check(NodeTypeMachine.String(), gc.Equals, "Machine")
+	c.Check(NodeTypeDevice.String(), gc.Equals, "Device")
+	c.Check(NodeTypeRackController.String(), gc.Equals, "Rack controller")
+	c.Check(NodeTypeRegionController.String(), gc.Equals, "Region controller")
+	c.Check(NodeTypeRegionAndRackController.String(), gc.Equals, "Region and rack controller")
+	c.Check(NodeType(42).String(), gc.Equals, "NodeType(42)")
+}
+
+func (*nodeSuite) TestNodeTypeControllers(c *gc.C) {
+	for _, test := range []struct {
+		nodeType NodeType
+		any      bool
+		rack     bool
+		region   bool
+	}{
+		{NodeTypeMachine, false, false, false},
+		{NodeTypeDevice, false, false, false},
+		{NodeTypeRackController, true, true, false},
+		{NodeTypeRegionController, true, false, true},
+		{NodeTypeRegionAndRackController, true, true, true},
+	} {
+		c.Logf("%s", test.nodeType)
+		c.Check(test.nodeType.IsController(), gc.Equals, test.any)
+		c.Check(test.nodeType.IsRackController(), gc.Equals, test.rack)
+		c.Check(test.nodeType.IsRegionController(), gc.Equals, test.region)
+	}
+}
+
+func (*nodeSuite) TestNilZone(c *gc.C) {
+	var empty node
+	c.Check(empty.Zone() == nil, jc.IsTrue)
+}
+
+func (*nodeSuite) TestReadNodesBadSchema(c *gc.C) {
+	_, err := readNodes(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `node base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*nodeSuite) TestReadNodes(c *gc.C) {
+	nodes, err := readNodes(twoDotOh, parseJSON(c, nodesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 4)
+
+	machine := nodes[0]
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(machine.Hostname(), gc.Equals, "untasted-markita")
+	c.Check(machine.FQDN(), gc.Equals, "untasted-markita.maas")
+	c.Check(machine.NodeType(), gc.Equals, NodeTypeMachine)
+	c.Check(machine.IPAddresses(), jc.DeepEquals, []string{"192.168.100.4"})
+	c.Assert(machine.Zone(), gc.NotNil)
+	c.Check(machine.Zone().Name(), gc.Equals, "default")
+
+	c.Check(nodes[1].NodeType(), gc.Equals, NodeTypeDevice)
+	c.Check(nodes[2].NodeType(), gc.Equals, NodeTypeRackController)
+
+	region := nodes[3]
+	c.Check(region.NodeType(), gc.Equals, NodeTypeRegionAndRackController)
+	c.Check(region.IPAddresses(), gc.HasLen, 0)
+	c.Check(region.Zone() == nil, jc.IsTrue)
+}
+
+func (*nodeSuite) TestLowVersion(c *gc.C) {
+	_, err := readNodes(version.MustParse("1.9.0"), parseJSON(c, nodesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (*nodeSuite) TestHighVersion(c *gc.C) {
+	nodes, err := readNodes(version.MustParse("2.1.9"), parseJSON(c, nodesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 4)
+}
+
+func (s *controllerSuite) TestNodes(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/nodes/", http.StatusOK, nodesResponse)
+	controller := s.getController(c)
+	nodes, err := controller.Nodes(NodesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 4)
+	c.Check(nodes[2].Hostname(), gc.Equals, "rack-one")
+}
+
+func (s *controllerSuite) TestNodesFilterNodeTypes(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/nodes/", http.StatusOK, nodesResponse)
+	controller := s.getController(c)
+	nodes, err := controller.Nodes(NodesArgs{
+		NodeTypes: []NodeType{NodeTypeRackController, NodeTypeRegionAndRackController},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 2)
+	c.Check(nodes[0].Hostname(), gc.Equals, "rack-one")
+	c.Check(nodes[1].Hostname(), gc.Equals, "region-one")
+}
+
+func (s *controllerSuite) TestNodesArgs(c *gc.C) {
+	controller := s.getController(c)
+	// The test server has no response for this query, all we want is the
+	// request to check the values were set.
+	controller.Nodes(NodesArgs{
+		Hostnames:    []string{"untasted-markita"},
+		MACAddresses: []string{"52:54:00:c9:6a:47"},
+		SystemIDs:    []string{"something-else"},
+		Domain:       "magic",
+		Zone:         "foo",
+		NodeTypes:    []NodeType{NodeTypeDevice},
+	})
+	request := s.server.LastRequest()
+	// There should be one entry in the form values for each of the args
+	// except NodeTypes, which is applied to the response.
+	c.Assert(request.URL.Query(), gc.HasLen, 5)
+}
+
+func (s *controllerSuite) TestNodesServerError(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/nodes/", http.StatusInternalServerError, "boom")
+	controller := s.getController(c)
+	_, err := controller.Nodes(NodesArgs{})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+const nodesResponse = `
+[
+    {
+        "system_id": "4y3ha3",
+        "hostname": "untasted-markita",
+        "fqdn": "untasted-markita.maas",
+        "node_type": 0,
+        "node_type_name": "Machine",
+        "ip_addresses": ["192.168.100.4"],
+        "zone": {
+            "description": "",
+            "resource_uri": "/MAAS/api/2.0/zones/default/",
+            "name": "default"
+        },
+        "resource_uri": "/MAAS/api/2.0/machines/4y3ha3/"
+    },
+    {
+        "system_id": "4y3haf",
+        "hostname": "furnacelike-brittney",
+        "fqdn": "furnacelike-brittney.maas",
+        "node_type": 1,
+        "node_type_name": "Device",
+        "ip_addresses": ["192.168.100.11"],
+        "zone": {
+            "description": "",
+            "resource_uri": "/MAAS/api/2.0/zones/default/",
+            "name": "default"
+        },
+        "resource_uri": "/MAAS/api/2.0/devices/4y3haf/"
+    },
+    {
+        "system_id": "8mp3yr",
+        "hostname": "rack-one",
+        "fqdn": "rack-one.maas",
+        "node_type": 2,
+        "node_type_name": "Rack controller",
+        "ip_addresses": ["192.168.100.2"],
+        "zone": null,
+        "resource_uri": "/MAAS/api/2.0/rackcontrollers/8mp3yr/"
+    },
+    {
+        "system_id": "pr8g4k",
+        "hostname": "region-one",
+        "fqdn": "region-one.maas",
+        "node_type": 4,
+        "node_type_name": "Region and rack controller",
+        "resource_uri": "/MAAS/api/2.0/regioncontrollers/pr8g4k/"
+    }
+]
+`
@@ -4,33 +4,100 @@
 package gomaasapi
 
 import (
+	"bytes"
+	"context"
+	"crypto"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
+	"sync/atomic"
+
+	"github.com/juju/errors"
 )
 
-// Not a true uuidgen, but at least creates same length random
-func generateNonce() (string, error) {
-	randBytes := make([]byte, 16)
-	_, err := rand.Read(randBytes)
-	if err != nil {
-		return "", err
+// NonceSource generates the nonces for signed requests. MAAS rejects a
+// request with 401 "nonce_used" if its nonce was already used with the
+// same token and timestamp, so a source must not repeat itself, even when
+// called from many goroutines at once.
+type NonceSource interface {
+	Nonce() (string, error)
+}
+
+// NonceSourceFunc adapts a function to a NonceSource.
+type NonceSourceFunc func() (string, error)
+
+// Nonce implements NonceSource.
+func (f NonceSourceFunc) Nonce() (string, error) {
+	return f()
+}
+
+// randomNonceSource makes nonces from 16 bytes from crypto/rand followed by
+// a sequence number. The random bytes make collisions between processes
+// vanishingly unlikely, and the sequence number rules them out between the
+// requests of one process even if the random source misbehaves.
+type randomNonceSource struct {
+	sequence uint64
+}
+
+// NewRandomNonceSource returns the NonceSource that signers use by default.
+// It is safe for concurrent use.
+func NewRandomNonceSource() NonceSource {
+	return &randomNonceSource{}
+}
+
+// Nonce implements NonceSource.
+func (s *randomNonceSource) Nonce() (string, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:16]); err != nil {
+		return "", errors.Annotate(err, "generating nonce")
 	}
-	return fmt.Sprintf("%16x", randBytes), nil
+	binary.BigEndian.PutUint64(nonce[16:], atomic.AddUint64(&s.sequence, 1))
+	return hex.EncodeToString(nonce[:]), nil
 }
 
-func generateTimestamp() string {
-	return strconv.Itoa(int(time.Now().Unix()))
+// defaultNonceSource is shared by the signers that are not given a source,
+// so that its sequence covers every request of the process.
+var defaultNonceSource = NewRandomNonceSource()
+
+func generateTimestamp(clock Clock) string {
+	return strconv.Itoa(int(clockOrWall(clock).Now().Unix()))
 }
 
 type OAuthSigner interface {
 	OAuthSign(request *http.Request) error
 }
 
+// ContextOAuthSigner may be implemented by an OAuthSigner that needs the
+// context of the request being signed, for example to fetch short-lived
+// credentials from a secret store with the caller's deadline. The client
+// calls OAuthSignContext instead of OAuthSign when it is available.
+type ContextOAuthSigner interface {
+	OAuthSigner
+	OAuthSignContext(ctx context.Context, request *http.Request) error
+}
+
+// signRequest signs the request with the signer, passing the request's
+// context to signers that accept one.
+func signRequest(signer OAuthSigner, request *http.Request) error {
+	if contextSigner, ok := signer.(ContextOAuthSigner); ok {
+		return contextSigner.OAuthSignContext(request.Context(), request)
+	}
+	return signer.OAuthSign(request)
+}
+
 type OAuthToken struct {
 	ConsumerKey    string
 	ConsumerSecret string
@@ -38,16 +105,75 @@ type OAuthToken struct {
 	TokenSecret    string
 }
 
+// OAuthSignatureMode determines where a signer places the OAuth parameters
+// in the request.
+type OAuthSignatureMode int
+
+const (
+	// OAuthHeaderMode sends the OAuth parameters in the Authorization
+	// header. This is the default.
+	OAuthHeaderMode OAuthSignatureMode = iota
+
+	// OAuthQueryMode sends the OAuth parameters in the query string, for
+	// use when a proxy between the client and MAAS strips the
+	// Authorization header.
+	OAuthQueryMode
+)
+
+// Validate ensures that the mode is a known one.
+func (m OAuthSignatureMode) Validate() error {
+	switch m {
+	case OAuthHeaderMode, OAuthQueryMode:
+		return nil
+	}
+	return errors.NotValidf("OAuth signature mode %d", int(m))
+}
+
 // Trick to ensure *plainTextOAuthSigner implements the OAuthSigner interface.
 var _ OAuthSigner = (*plainTextOAuthSigner)(nil)
 
+// plainTextOAuthSigner is not changed by signing, so it is safe to use from
+// many goroutines at once as long as its NonceSource is.
 type plainTextOAuthSigner struct {
-	token *OAuthToken
-	realm string
+	token  *OAuthToken
+	realm  string
+	mode   OAuthSignatureMode
+	clock  Clock
+	nonces NonceSource
+}
+
+// clockedSigner is implemented by the signers in this package, so that the
+// client can have them take their timestamps from its Clock.
+type clockedSigner interface {
+	withClock(Clock) OAuthSigner
+}
+
+func (signer plainTextOAuthSigner) withClock(clock Clock) OAuthSigner {
+	signer.clock = clock
+	return signer
 }
 
 func NewPlainTestOAuthSigner(token *OAuthToken, realm string) (OAuthSigner, error) {
-	return &plainTextOAuthSigner{token, realm}, nil
+	return NewPlainTextOAuthSignerWithMode(token, realm, OAuthHeaderMode)
+}
+
+// NewPlainTextOAuthSignerWithMode returns a PLAINTEXT signer that places the
+// OAuth parameters according to mode.
+func NewPlainTextOAuthSignerWithMode(token *OAuthToken, realm string, mode OAuthSignatureMode) (OAuthSigner, error) {
+	return NewPlainTextOAuthSignerWithNonces(token, realm, mode, nil)
+}
+
+// NewPlainTextOAuthSignerWithNonces returns a PLAINTEXT signer that places
+// the OAuth parameters according to mode and takes its nonces from the
+// source. If nonces is nil, the source from NewRandomNonceSource is used.
+func NewPlainTextOAuthSignerWithNonces(token *OAuthToken, realm string, mode OAuthSignatureMode, nonces NonceSource) (OAuthSigner, error) {
+	if err := mode.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if nonces == nil {
+		nonces = defaultNonceSource
+	}
+	return &plainTextOAuthSigner{token: token, realm: realm, mode: mode, nonces: nonces}, nil
 }
 
 // OAuthSignPLAINTEXT signs the provided request using the OAuth PLAINTEXT
@@ -55,26 +181,255 @@ func NewPlainTestOAuthSigner(token *OAuthToken, realm string) (OAuthSigner, erro
 func (signer plainTextOAuthSigner) OAuthSign(request *http.Request) error {
 
 	signature := signer.token.ConsumerSecret + `&` + signer.token.TokenSecret
-	nonce, err := generateNonce()
+	nonces := signer.nonces
+	if nonces == nil {
+		nonces = defaultNonceSource
+	}
+	nonce, err := nonces.Nonce()
 	if err != nil {
-		return err
+		return errors.Trace(err)
 	}
 	authData := map[string]string{
-		"realm":                  signer.realm,
 		"oauth_consumer_key":     signer.token.ConsumerKey,
 		"oauth_token":            signer.token.TokenKey,
 		"oauth_signature_method": "PLAINTEXT",
 		"oauth_signature":        signature,
-		"oauth_timestamp":        generateTimestamp(),
+		"oauth_timestamp":        generateTimestamp(signer.clock),
 		"oauth_nonce":            nonce,
 		"oauth_version":          "1.0",
 	}
-	// Build OAuth header.
-	var authHeader []string
+	placeOAuthParams(request, authData, signer.realm, signer.mode)
+	return nil
+}
+
+// placeOAuthParams adds the OAuth parameters to the request as the mode
+// says.
+func placeOAuthParams(request *http.Request, authData map[string]string, realm string, mode OAuthSignatureMode) {
+	if mode == OAuthQueryMode {
+		// The realm is only meaningful in the header, so it is left out.
+		// Set replaces any parameters from an earlier signing of the same
+		// request, as happens when it is retried.
+		query := request.URL.Query()
+		for key, value := range authData {
+			query.Set(key, value)
+		}
+		request.URL.RawQuery = canonicalQuery(query)
+		return
+	}
+	params := map[string]string{"realm": realm}
 	for key, value := range authData {
-		authHeader = append(authHeader, fmt.Sprintf(`%s="%s"`, key, url.QueryEscape(value)))
+		params[key] = value
+	}
+	// Build OAuth header, with the parameters in a fixed order and
+	// encoded as RFC 5849 section 3.5.1 requires.
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var authHeader []string
+	for _, key := range keys {
+		authHeader = append(authHeader, fmt.Sprintf(`%s="%s"`, key, percentEncode(params[key])))
 	}
 	strHeader := "OAuth " + strings.Join(authHeader, ", ")
-	request.Header.Add("Authorization", strHeader)
+	// Set rather than add, so that a retried request does not carry the
+	// header from its first signing, whose nonce has been used.
+	request.Header.Set("Authorization", strHeader)
+}
+
+// Trick to ensure *rsaSHA1OAuthSigner implements the OAuthSigner interface.
+var _ OAuthSigner = (*rsaSHA1OAuthSigner)(nil)
+
+// rsaSHA1OAuthSigner signs requests with the OAuth RSA-SHA1 method, for API
+// gateways in front of MAAS that require asymmetric signatures. Like
+// plainTextOAuthSigner it is not changed by signing.
+type rsaSHA1OAuthSigner struct {
+	token  *OAuthToken
+	key    *rsa.PrivateKey
+	realm  string
+	mode   OAuthSignatureMode
+	clock  Clock
+	nonces NonceSource
+}
+
+func (signer rsaSHA1OAuthSigner) withClock(clock Clock) OAuthSigner {
+	signer.clock = clock
+	return signer
+}
+
+// NewRSASHA1OAuthSigner returns a signer that signs requests with the
+// private key using the OAuth RSA-SHA1 method, placing the OAuth
+// parameters according to mode. The secrets of the token are not used.
+// If nonces is nil, the source from NewRandomNonceSource is used.
+func NewRSASHA1OAuthSigner(token *OAuthToken, realm string, key *rsa.PrivateKey, mode OAuthSignatureMode, nonces NonceSource) (OAuthSigner, error) {
+	if err := mode.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if key == nil {
+		return nil, errors.NotValidf("missing RSA key")
+	}
+	if nonces == nil {
+		nonces = defaultNonceSource
+	}
+	return &rsaSHA1OAuthSigner{token: token, key: key, realm: realm, mode: mode, nonces: nonces}, nil
+}
+
+// ParseRSAPrivateKey reads an RSA private key from PEM data in PKCS #1 or
+// PKCS #8 form.
+func ParseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.NotValidf("RSA private key: no PEM data")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.NewNotValid(err, "RSA private key")
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.NotValidf("RSA private key: %T", parsed)
+	}
+	return key, nil
+}
+
+// OAuthSign signs the request using the OAuth RSA-SHA1 method:
+// https://tools.ietf.org/html/rfc5849#section-3.4.3.
+func (signer rsaSHA1OAuthSigner) OAuthSign(request *http.Request) error {
+	nonces := signer.nonces
+	if nonces == nil {
+		nonces = defaultNonceSource
+	}
+	nonce, err := nonces.Nonce()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	authData := map[string]string{
+		"oauth_consumer_key":     signer.token.ConsumerKey,
+		"oauth_token":            signer.token.TokenKey,
+		"oauth_signature_method": "RSA-SHA1",
+		"oauth_timestamp":        generateTimestamp(signer.clock),
+		"oauth_nonce":            nonce,
+		"oauth_version":          "1.0",
+	}
+	base, err := signatureBaseString(request, authData)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	digest := sha1.Sum([]byte(base))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer.key, crypto.SHA1, digest[:])
+	if err != nil {
+		return errors.Annotate(err, "signing request")
+	}
+	authData["oauth_signature"] = base64.StdEncoding.EncodeToString(signature)
+	placeOAuthParams(request, authData, signer.realm, signer.mode)
 	return nil
 }
+
+// signatureBaseString returns the string that RFC 5849 section 3.4.1 says
+// is signed, made from the method, the URL, the query, the form body and
+// the OAuth parameters. Any OAuth parameters already in the query, from an
+// earlier signing of the request, are left out.
+func signatureBaseString(request *http.Request, authData map[string]string) (string, error) {
+	params := make(url.Values)
+	for key, values := range request.URL.Query() {
+		if !strings.HasPrefix(key, "oauth_") {
+			params[key] = values
+		}
+	}
+	for key, value := range authData {
+		params.Set(key, value)
+	}
+	form, err := formBody(request)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	for key, values := range form {
+		params[key] = append(params[key], values...)
+	}
+
+	type pair struct{ key, value string }
+	var pairs []pair
+	for key, values := range params {
+		for _, value := range values {
+			pairs = append(pairs, pair{percentEncode(key), percentEncode(value)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].key != pairs[j].key {
+			return pairs[i].key < pairs[j].key
+		}
+		return pairs[i].value < pairs[j].value
+	})
+	normalized := make([]string, len(pairs))
+	for i, p := range pairs {
+		normalized[i] = p.key + "=" + p.value
+	}
+
+	baseURL := url.URL{
+		Scheme: strings.ToLower(request.URL.Scheme),
+		Path:   request.URL.EscapedPath(),
+	}
+	baseURL.Host = signatureHost(baseURL.Scheme, request.URL)
+	return strings.Join([]string{
+		strings.ToUpper(request.Method),
+		percentEncode(baseURL.String()),
+		percentEncode(strings.Join(normalized, "&")),
+	}, "&"), nil
+}
+
+// signatureHost returns the host of the URL as the server sees it in the
+// Host header: in lower case, without the default port of the scheme, and
+// without the zone of an IPv6 literal, which is only used to pick the
+// interface to connect from. IPv6 literals keep their brackets.
+func signatureHost(scheme string, u *url.URL) string {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	return host
+}
+
+// formBody returns the parameters of a form encoded request body, leaving
+// the body to be read again when the request is sent. Other bodies, such
+// as multipart ones, are not signed.
+func formBody(request *http.Request) (url.Values, error) {
+	contentType := request.Header.Get("Content-Type")
+	if request.Body == nil || !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return nil, nil
+	}
+	var content []byte
+	var err error
+	if request.GetBody != nil {
+		var body io.ReadCloser
+		body, err = request.GetBody()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		content, err = ioutil.ReadAll(body)
+		body.Close()
+	} else {
+		content, err = ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		request.Body = ioutil.NopCloser(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, errors.Annotate(err, "reading request body")
+	}
+	form, err := url.ParseQuery(string(content))
+	if err != nil {
+		return nil, errors.Annotate(err, "parsing request body")
+	}
+	return form, nil
+}
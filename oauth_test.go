@@ -0,0 +1,389 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing/iotest"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type oauthSuite struct{}
+
+var _ = gc.Suite(&oauthSuite{})
+
+var testOAuthToken = &OAuthToken{
+	ConsumerKey: "consumer",
+	TokenKey:    "token",
+	TokenSecret: "secret",
+}
+
+func (*oauthSuite) TestHeaderMode(c *gc.C) {
+	signer, err := NewPlainTestOAuthSigner(testOAuthToken, "MAAS API")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/api/2.0/machines/?op=list", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = signer.OAuthSign(request)
+	c.Assert(err, jc.ErrorIsNil)
+	header := request.Header.Get("Authorization")
+	c.Check(header, gc.Matches, "^OAuth .*")
+	c.Check(header, jc.Contains, `oauth_token="token"`)
+	c.Check(header, jc.Contains, `realm="MAAS%20API"`)
+	c.Check(request.URL.RawQuery, gc.Equals, "op=list")
+}
+
+func (*oauthSuite) TestQueryMode(c *gc.C) {
+	signer, err := NewPlainTextOAuthSignerWithMode(testOAuthToken, "MAAS API", OAuthQueryMode)
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/api/2.0/machines/?op=list", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = signer.OAuthSign(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(request.Header.Get("Authorization"), gc.Equals, "")
+	query := request.URL.Query()
+	c.Check(query.Get("op"), gc.Equals, "list")
+	c.Check(query.Get("oauth_consumer_key"), gc.Equals, "consumer")
+	c.Check(query.Get("oauth_token"), gc.Equals, "token")
+	c.Check(query.Get("oauth_signature"), gc.Equals, "&secret")
+	c.Check(query.Get("oauth_signature_method"), gc.Equals, "PLAINTEXT")
+	c.Check(query.Get("realm"), gc.Equals, "")
+}
+
+func (*oauthSuite) TestQueryModeResigning(c *gc.C) {
+	signer, err := NewPlainTextOAuthSignerWithMode(testOAuthToken, "MAAS API", OAuthQueryMode)
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/api/2.0/machines/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	c.Check(request.URL.Query()["oauth_nonce"], gc.HasLen, 1)
+}
+
+func (*oauthSuite) TestBadMode(c *gc.C) {
+	_, err := NewPlainTextOAuthSignerWithMode(testOAuthToken, "MAAS API", OAuthSignatureMode(42))
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, "OAuth signature mode 42 not valid")
+}
+
+func (*oauthSuite) TestHeaderModeResigning(c *gc.C) {
+	signer, err := NewPlainTestOAuthSigner(testOAuthToken, "MAAS API")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/api/2.0/machines/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	first := request.Header.Get("Authorization")
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	c.Check(request.Header["Authorization"], gc.HasLen, 1)
+	c.Check(request.Header.Get("Authorization"), gc.Not(gc.Equals), first)
+}
+
+func (*oauthSuite) TestRandomNonceSourceConcurrent(c *gc.C) {
+	source := NewRandomNonceSource()
+	const goroutines, each = 10, 100
+	nonces := make(chan string, goroutines*each)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < each; j++ {
+				nonce, err := source.Nonce()
+				c.Check(err, jc.ErrorIsNil)
+				nonces <- nonce
+			}
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+	seen := make(map[string]bool)
+	for nonce := range nonces {
+		c.Check(nonce, gc.Matches, "[0-9a-f]{48}")
+		c.Check(seen[nonce], jc.IsFalse)
+		seen[nonce] = true
+	}
+	c.Check(seen, gc.HasLen, goroutines*each)
+}
+
+func (*oauthSuite) TestSignerUsesNonceSource(c *gc.C) {
+	source := NonceSourceFunc(func() (string, error) { return "not-random", nil })
+	signer, err := NewPlainTextOAuthSignerWithNonces(testOAuthToken, "MAAS API", OAuthQueryMode, source)
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/api/2.0/machines/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	c.Check(request.URL.Query().Get("oauth_nonce"), gc.Equals, "not-random")
+}
+
+func (*oauthSuite) TestSignerNonceSourceError(c *gc.C) {
+	source := NonceSourceFunc(func() (string, error) { return "", errors.New("out of entropy") })
+	signer, err := NewPlainTextOAuthSignerWithNonces(testOAuthToken, "MAAS API", OAuthHeaderMode, source)
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/api/2.0/machines/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(signer.OAuthSign(request), gc.ErrorMatches, "out of entropy")
+	c.Check(request.Header.Get("Authorization"), gc.Equals, "")
+}
+
+func (*oauthSuite) TestControllerNonceSource(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	var count int
+	_, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+		NonceSource: NonceSourceFunc(func() (string, error) {
+			count++
+			return "nonce", nil
+		}),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(count, gc.Equals, server.RequestCount())
+	c.Check(server.LastRequest().Header.Get("Authorization"), jc.Contains, `oauth_nonce="nonce"`)
+}
+
+type contextKey string
+
+type recordingContextSigner struct {
+	value interface{}
+	err   error
+}
+
+func (s *recordingContextSigner) OAuthSign(request *http.Request) error {
+	return errors.New("OAuthSign called")
+}
+
+func (s *recordingContextSigner) OAuthSignContext(ctx context.Context, request *http.Request) error {
+	s.value = ctx.Value(contextKey("credential"))
+	return s.err
+}
+
+func (*oauthSuite) TestSignRequestPassesContext(c *gc.C) {
+	signer := &recordingContextSigner{}
+	ctx := context.WithValue(context.Background(), contextKey("credential"), "vault")
+	request, err := http.NewRequest("GET", "http://example.com/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	err = signRequest(signer, request.WithContext(ctx))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(signer.value, gc.Equals, "vault")
+}
+
+func (*oauthSuite) TestSignRequestPlainSigner(c *gc.C) {
+	signer, err := NewPlainTestOAuthSigner(testOAuthToken, "MAAS API")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", "http://example.com/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	err = signRequest(signer, request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(request.Header.Get("Authorization"), gc.Not(gc.Equals), "")
+}
+
+func (*oauthSuite) TestClientReturnsContextSignerError(c *gc.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request %s", r.URL)
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.Signer = &recordingContextSigner{err: errors.New("credentials expired")}
+	request, err := http.NewRequest("GET", server.URL, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.DoRaw(request)
+	c.Assert(err, gc.ErrorMatches, "signing request: credentials expired")
+}
+
+var (
+	testRSAKeyOnce sync.Once
+	testRSAKey     *rsa.PrivateKey
+)
+
+func rsaKey(c *gc.C) *rsa.PrivateKey {
+	testRSAKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		c.Assert(err, jc.ErrorIsNil)
+		testRSAKey = key
+	})
+	return testRSAKey
+}
+
+func fixedNonces() NonceSource {
+	return NonceSourceFunc(func() (string, error) { return "nonce", nil })
+}
+
+func checkRSASignature(c *gc.C, key *rsa.PrivateKey, base, signature string) {
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	c.Assert(err, jc.ErrorIsNil)
+	digest := sha1.Sum([]byte(base))
+	c.Check(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, digest[:], raw), jc.ErrorIsNil)
+}
+
+func (*oauthSuite) TestRSASHA1QueryMode(c *gc.C) {
+	key := rsaKey(c)
+	signer, err := NewRSASHA1OAuthSigner(testOAuthToken, "MAAS API", key, OAuthQueryMode, fixedNonces())
+	c.Assert(err, jc.ErrorIsNil)
+	signer = signer.(clockedSigner).withClock(&testClock{now: time.Unix(1000, 0)})
+	request, err := http.NewRequest("GET", "HTTP://Example.COM:80/api/2.0/machines/?op=list&hostname=a%20b", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	query := request.URL.Query()
+	c.Check(query.Get("oauth_signature_method"), gc.Equals, "RSA-SHA1")
+	c.Check(query.Get("realm"), gc.Equals, "")
+	base := "GET&http%3A%2F%2Fexample.com%2Fapi%2F2.0%2Fmachines%2F&" +
+		"hostname%3Da%2520b%26oauth_consumer_key%3Dconsumer%26oauth_nonce%3Dnonce%26" +
+		"oauth_signature_method%3DRSA-SHA1%26oauth_timestamp%3D1000%26" +
+		"oauth_token%3Dtoken%26oauth_version%3D1.0%26op%3Dlist"
+	checkRSASignature(c, key, base, query.Get("oauth_signature"))
+
+	// Signing again replaces the parameters, and leaves the old ones out
+	// of the signature.
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	c.Check(request.URL.Query()["oauth_signature"], gc.HasLen, 1)
+	checkRSASignature(c, key, base, request.URL.Query().Get("oauth_signature"))
+}
+
+func (*oauthSuite) TestRSASHA1HeaderModeSignsForm(c *gc.C) {
+	key := rsaKey(c)
+	signer, err := NewRSASHA1OAuthSigner(testOAuthToken, "MAAS API", key, OAuthHeaderMode, fixedNonces())
+	c.Assert(err, jc.ErrorIsNil)
+	signer = signer.(clockedSigner).withClock(&testClock{now: time.Unix(1000, 0)})
+	request, err := http.NewRequest("POST", "https://example.com:5240/MAAS/api/2.0/machines/?op=allocate", strings.NewReader("zone=a&tags=x"))
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	header := request.Header.Get("Authorization")
+	c.Check(header, jc.Contains, `oauth_signature_method="RSA-SHA1"`)
+	c.Check(header, jc.Contains, `realm="MAAS%20API"`)
+	signature := regexp.MustCompile(`oauth_signature="([^"]*)"`).FindStringSubmatch(header)
+	c.Assert(signature, gc.HasLen, 2)
+	encoded, err := url.PathUnescape(signature[1])
+	c.Assert(err, jc.ErrorIsNil)
+	base := "POST&https%3A%2F%2Fexample.com%3A5240%2FMAAS%2Fapi%2F2.0%2Fmachines%2F&" +
+		"oauth_consumer_key%3Dconsumer%26oauth_nonce%3Dnonce%26" +
+		"oauth_signature_method%3DRSA-SHA1%26oauth_timestamp%3D1000%26" +
+		"oauth_token%3Dtoken%26oauth_version%3D1.0%26op%3Dallocate%26tags%3Dx%26zone%3Da"
+	checkRSASignature(c, key, base, encoded)
+
+	body, err := ioutil.ReadAll(request.Body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(body), gc.Equals, "zone=a&tags=x")
+}
+
+func (*oauthSuite) TestRSASHA1BodyWithoutGetBody(c *gc.C) {
+	signer, err := NewRSASHA1OAuthSigner(testOAuthToken, "MAAS API", rsaKey(c), OAuthHeaderMode, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("PUT", "http://example.com/", ioutil.NopCloser(strings.NewReader("a=b")))
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	c.Assert(signer.OAuthSign(request), jc.ErrorIsNil)
+	body, err := ioutil.ReadAll(request.Body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(body), gc.Equals, "a=b")
+}
+
+func (*oauthSuite) TestRSASHA1BodyReadError(c *gc.C) {
+	signer, err := NewRSASHA1OAuthSigner(testOAuthToken, "MAAS API", rsaKey(c), OAuthHeaderMode, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("POST", "http://example.com/", strings.NewReader("a=b"))
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(io.MultiReader(strings.NewReader("a=b&c"), iotest.ErrReader(errors.New("boom")))), nil
+	}
+
+	err = signer.OAuthSign(request)
+	c.Check(err, gc.ErrorMatches, "reading request body: boom")
+	c.Check(request.Header.Get("Authorization"), gc.Equals, "")
+}
+
+func (*oauthSuite) TestNewRSASHA1OAuthSignerValidates(c *gc.C) {
+	_, err := NewRSASHA1OAuthSigner(testOAuthToken, "MAAS API", nil, OAuthHeaderMode, nil)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	_, err = NewRSASHA1OAuthSigner(testOAuthToken, "MAAS API", rsaKey(c), OAuthSignatureMode(7), nil)
+	c.Check(err, gc.NotNil)
+}
+
+func (*oauthSuite) TestParseRSAPrivateKey(c *gc.C) {
+	key := rsaKey(c)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	c.Assert(err, jc.ErrorIsNil)
+	for i, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		c.Logf("test %d", i)
+		parsed, err := ParseRSAPrivateKey(pem.EncodeToMemory(block))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(parsed.Equal(key), jc.IsTrue)
+	}
+
+	_, err = ParseRSAPrivateKey([]byte("not a key"))
+	c.Check(err, gc.ErrorMatches, "RSA private key: no PEM data not valid")
+	_, err = ParseRSAPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("junk")}))
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (*oauthSuite) TestControllerSigningKey(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	_, err := NewController(ControllerArgs{
+		BaseURL:    server.URL,
+		APIKey:     "fake:as:key",
+		SigningKey: rsaKey(c),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().Header.Get("Authorization"), jc.Contains, `oauth_signature_method="RSA-SHA1"`)
+}
+
+func (*oauthSuite) TestSignatureHost(c *gc.C) {
+	for i, test := range []struct {
+		url, host string
+	}{
+		{"http://MAAS.example.com/MAAS/", "maas.example.com"},
+		{"http://maas:80/MAAS/", "maas"},
+		{"https://maas:443/MAAS/", "maas"},
+		{"https://maas:80/MAAS/", "maas:80"},
+		{"http://[2001:DB8::1]/MAAS/", "[2001:db8::1]"},
+		{"http://[2001:db8::1]:80/MAAS/", "[2001:db8::1]"},
+		{"http://[2001:db8::1]:5240/MAAS/", "[2001:db8::1]:5240"},
+		{"http://[fe80::1%25eth0]:5240/MAAS/", "[fe80::1]:5240"},
+	} {
+		c.Logf("test %d", i)
+		u, err := url.Parse(test.url)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(signatureHost(u.Scheme, u), gc.Equals, test.host)
+	}
+}
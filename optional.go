@@ -0,0 +1,47 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import "fmt"
+
+// Optional is a value of an update that is only sent if it was set. The
+// zero Optional is not set, so the fields of an update struct the caller
+// leaves out are not sent, and the server keeps their current values. A
+// set Optional is sent even if its value is the zero value of T, which is
+// how an attribute is cleared, as Some("") does for a string.
+type Optional[T any] struct {
+	value T
+	set   bool
+}
+
+// Some returns an Optional set to the value.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{value: value, set: true}
+}
+
+// Get returns the value and whether it was set.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.set
+}
+
+// IsSet returns whether the value was set.
+func (o Optional[T]) IsSet() bool {
+	return o.set
+}
+
+// String returns the value as it is sent, or "<unset>".
+func (o Optional[T]) String() string {
+	if !o.set {
+		return "<unset>"
+	}
+	return fmt.Sprint(o.value)
+}
+
+// addOptional adds the (name, value) pair iff value is set, even if it is
+// the zero value of T.
+func addOptional[T any](p *URLParams, name string, value Optional[T]) {
+	if value.set {
+		p.Values.Add(name, fmt.Sprint(value.value))
+	}
+}
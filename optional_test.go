@@ -0,0 +1,43 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type optionalSuite struct{}
+
+var _ = gc.Suite(&optionalSuite{})
+
+func (*optionalSuite) TestOptional(c *gc.C) {
+	var unset Optional[int]
+	value, ok := unset.Get()
+	c.Check(value, gc.Equals, 0)
+	c.Check(ok, jc.IsFalse)
+	c.Check(unset.IsSet(), jc.IsFalse)
+	c.Check(unset.String(), gc.Equals, "<unset>")
+
+	zero := Some(0)
+	value, ok = zero.Get()
+	c.Check(value, gc.Equals, 0)
+	c.Check(ok, jc.IsTrue)
+	c.Check(zero.String(), gc.Equals, "0")
+}
+
+func (*optionalSuite) TestAddOptional(c *gc.C) {
+	params := NewURLParams()
+	addOptional(params, "unset", Optional[string]{})
+	addOptional(params, "empty", Some(""))
+	addOptional(params, "zero", Some(0))
+	addOptional(params, "false", Some(false))
+	c.Check(params.Values, jc.DeepEquals, url.Values{
+		"empty": {""},
+		"zero":  {"0"},
+		"false": {"false"},
+	})
+}
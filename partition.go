@@ -10,6 +10,8 @@ import (
 )
 
 type partition struct {
+	rawJSON
+
 	resourceURI string
 
 	id   int
@@ -101,16 +103,16 @@ var partitionDeserializationFuncs = map[version.Number]partitionDeserializationF
 
 func partition_2_0(source map[string]interface{}) (*partition, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
+		"resource_uri": stringField(),
 
-		"id":   schema.ForceInt(),
-		"path": schema.String(),
-		"uuid": schema.OneOf(schema.Nil(""), schema.String()),
+		"id":   intField(),
+		"path": stringField(),
+		"uuid": nullable(stringField()),
 
-		"used_for": schema.String(),
-		"size":     schema.ForceUint(),
+		"used_for": stringField(),
+		"size":     uintField(),
 
-		"filesystem": schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+		"filesystem": nullable(schema.StringMap(schema.Any())),
 	}
 	defaults := schema.Defaults{
 		"uuid": "",
@@ -133,6 +135,7 @@ func partition_2_0(source map[string]interface{}) (*partition, error) {
 	}
 	uuid, _ := valid["uuid"].(string)
 	result := &partition{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 		id:          valid["id"].(int),
 		path:        valid["path"].(string),
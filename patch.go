@@ -0,0 +1,211 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Merge returns a copy of this object with the changes deep-merged into
+// it: a map in changes is merged into the map of the same name, a nil
+// value removes the attribute, and any other value replaces the attribute.
+// The changes must be encodable as JSON. The object itself is not changed,
+// and its resource URI cannot be.
+func (obj MAASObject) Merge(changes map[string]interface{}) (MAASObject, error) {
+	if _, ok := changes[resourceURI]; ok {
+		return MAASObject{}, fmt.Errorf("cannot change %s", resourceURI)
+	}
+	// Round trip the changes through JSON so that they hold the same types
+	// as a parsed response.
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return MAASObject{}, fmt.Errorf("cannot encode changes: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return MAASObject{}, err
+	}
+	values := mergeJSONMap(obj.values, decoded, obj.client)
+	return MAASObject{values: values, client: obj.client, uri: obj.uri}, nil
+}
+
+// mergeJSONMap returns a copy of values with the changes deep-merged into
+// it.
+func mergeJSONMap(values map[string]JSONObject, changes map[string]interface{}, client Client) map[string]JSONObject {
+	result := make(map[string]JSONObject, len(values)+len(changes))
+	for key, value := range values {
+		result[key] = value
+	}
+	for key, change := range changes {
+		switch change := change.(type) {
+		case nil:
+			delete(result, key)
+		case map[string]interface{}:
+			if existing, err := result[key].GetMap(); err == nil {
+				result[key] = JSONObject{value: mergeJSONMap(existing, change, client), client: client}
+				continue
+			}
+			result[key] = maasify(client, change)
+		default:
+			result[key] = maasify(client, change)
+		}
+	}
+	return result
+}
+
+// Delta returns the parameters that would change the base object into this
+// one: the attributes that were added or that have different values,
+// encoded as form values. Lists become repeated values, null becomes the
+// empty string, and numbers are written without exponents. Attributes
+// that were removed are ignored, since an update cannot remove them.
+// Changed maps, lists of lists and lists of maps cannot be encoded, and
+// nor can emptied lists, so they are errors.
+func (obj MAASObject) Delta(base MAASObject) (url.Values, error) {
+	keys := make([]string, 0, len(obj.values))
+	for key := range obj.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := make(url.Values)
+	for _, key := range keys {
+		value := obj.values[key]
+		if original, ok := base.values[key]; ok && sameJSON(original, value) {
+			continue
+		}
+		if key == resourceURI {
+			return nil, fmt.Errorf("cannot change %s", resourceURI)
+		}
+		encoded, err := formValues(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %v", key, err)
+		}
+		params[key] = encoded
+	}
+	return params, nil
+}
+
+// UpdateDelta sends only the attributes that differ from base, the version
+// that this object was modified from, so that attributes changed on the
+// server since base was read are not overwritten with stale values. It
+// returns the object's new value as received from the API. If nothing
+// changed, the object is read again instead. To refuse the update if the
+// object changed at all, use UpdateIf with the Delta and base.Unmodified.
+func (obj MAASObject) UpdateDelta(base MAASObject) (MAASObject, error) {
+	params, err := obj.Delta(base)
+	if err != nil {
+		return MAASObject{}, err
+	}
+	if len(params) == 0 {
+		return obj.Get()
+	}
+	return obj.Update(params)
+}
+
+// sameJSON reports whether the objects encode to the same JSON. Map keys
+// are sorted when encoding, so the order they were read in does not matter.
+func sameJSON(a, b JSONObject) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(encodedA) == string(encodedB)
+}
+
+// formValues encodes a JSON value as form values.
+func formValues(obj JSONObject) ([]string, error) {
+	if obj.IsNil() {
+		return []string{""}, nil
+	}
+	switch value := obj.value.(type) {
+	case []JSONObject:
+		if len(value) == 0 {
+			return nil, fmt.Errorf("cannot send an empty list")
+		}
+		result := make([]string, len(value))
+		for i, item := range value {
+			encoded, err := formValue(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = encoded
+		}
+		return result, nil
+	}
+	encoded, err := formValue(obj)
+	if err != nil {
+		return nil, err
+	}
+	return []string{encoded}, nil
+}
+
+// formValue encodes a JSON scalar as a form value.
+func formValue(obj JSONObject) (string, error) {
+	if obj.IsNil() {
+		return "", nil
+	}
+	switch value := obj.value.(type) {
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case map[string]JSONObject:
+		return "", fmt.Errorf("cannot send a map")
+	}
+	return "", fmt.Errorf("cannot send a nested list")
+}
+
+// updatedField is the attribute in which MAAS records when an object was
+// last changed.
+const updatedField = "updated"
+
+// Precondition is an attribute value that UpdateIf requires the object on
+// the server to still have.
+type Precondition struct {
+	// Field is the attribute to compare.
+	Field string
+
+	// Value is the attribute's value when the object was read, encoded
+	// as a form value.
+	Value string
+}
+
+// Unmodified returns a Precondition that the object's "updated" timestamp
+// has not changed since this copy was read.
+func (obj MAASObject) Unmodified() (Precondition, error) {
+	value, ok := obj.values[updatedField]
+	if !ok {
+		return Precondition{}, fmt.Errorf("object has no %q attribute", updatedField)
+	}
+	encoded, err := formValue(value)
+	if err != nil {
+		return Precondition{}, fmt.Errorf("attribute %q: %v", updatedField, err)
+	}
+	return Precondition{Field: updatedField, Value: encoded}, nil
+}
+
+// UpdateIf reads the object from the API and, if it still meets the
+// precondition, updates it with the params. Otherwise it returns a
+// ConflictError and sends no update. MAAS has no conditional requests, so
+// a change made between the read and the update is not detected; the check
+// narrows the window rather than closing it.
+func (obj MAASObject) UpdateIf(params url.Values, precondition Precondition) (MAASObject, error) {
+	current, err := obj.Get()
+	if err != nil {
+		return MAASObject{}, err
+	}
+	actual := ""
+	if value, ok := current.values[precondition.Field]; ok {
+		if actual, err = formValue(value); err != nil {
+			return MAASObject{}, fmt.Errorf("attribute %q: %v", precondition.Field, err)
+		}
+	}
+	if actual != precondition.Value {
+		return MAASObject{}, NewConflictError(precondition.Field, precondition.Value, actual)
+	}
+	return obj.Update(params)
+}
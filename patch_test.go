@@ -0,0 +1,207 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	. "gopkg.in/check.v1"
+)
+
+type PatchSuite struct{}
+
+var _ = Suite(&PatchSuite{})
+
+func patchObject(c *C, client Client, source string) MAASObject {
+	obj, err := Parse(client, []byte(source))
+	c.Assert(err, IsNil)
+	maasObj, err := obj.GetMAASObject()
+	c.Assert(err, IsNil)
+	return maasObj
+}
+
+const patchBase = `{
+	"resource_uri": "/api/1.0/nodes/n1/",
+	"hostname": "alpha",
+	"memory": 1024,
+	"tags": ["a", "b"],
+	"power": {"type": "ipmi", "address": "10.0.0.1"},
+	"comment": "keep"
+}`
+
+func (*PatchSuite) TestMergeDeep(c *C) {
+	base := patchObject(c, Client{}, patchBase)
+	merged, err := base.Merge(map[string]interface{}{
+		"hostname": "beta",
+		"power":    map[string]interface{}{"address": "10.0.0.2"},
+		"comment":  nil,
+		"zone":     "lab",
+	})
+	c.Assert(err, IsNil)
+	c.Check(marshalNode(merged), Equals, marshalNode(patchObject(c, Client{}, `{
+		"resource_uri": "/api/1.0/nodes/n1/",
+		"hostname": "beta",
+		"memory": 1024,
+		"tags": ["a", "b"],
+		"power": {"type": "ipmi", "address": "10.0.0.2"},
+		"zone": "lab"
+	}`)))
+	// The original is not changed.
+	hostname, err := base.GetField("hostname")
+	c.Assert(err, IsNil)
+	c.Check(hostname, Equals, "alpha")
+	c.Check(merged.URI().String(), Equals, "/api/1.0/nodes/n1/")
+}
+
+func (*PatchSuite) TestMergeErrors(c *C) {
+	base := patchObject(c, Client{}, patchBase)
+	_, err := base.Merge(map[string]interface{}{resourceURI: "/elsewhere/"})
+	c.Check(err, ErrorMatches, "cannot change resource_uri")
+	_, err = base.Merge(map[string]interface{}{"bad": make(chan int)})
+	c.Check(err, ErrorMatches, "cannot encode changes: .*")
+}
+
+func (*PatchSuite) TestDelta(c *C) {
+	base := patchObject(c, Client{}, patchBase)
+	modified, err := base.Merge(map[string]interface{}{
+		"hostname": "beta",
+		"memory":   2048,
+		"tags":     []string{"c"},
+		"comment":  nil,
+		"owner":    nil,
+		"deployed": true,
+	})
+	c.Assert(err, IsNil)
+	// Re-parsing the base changes the map order, but not the delta.
+	params, err := modified.Delta(patchObject(c, Client{}, patchBase))
+	c.Assert(err, IsNil)
+	c.Check(params, DeepEquals, url.Values{
+		"hostname": {"beta"},
+		"memory":   {"2048"},
+		"tags":     {"c"},
+		"deployed": {"true"},
+	})
+}
+
+func (*PatchSuite) TestDeltaNull(c *C) {
+	base := patchObject(c, Client{}, patchBase)
+	modified := patchObject(c, Client{}, `{"resource_uri": "/api/1.0/nodes/n1/", "hostname": null}`)
+	params, err := modified.Delta(base)
+	c.Assert(err, IsNil)
+	c.Check(params, DeepEquals, url.Values{"hostname": {""}})
+}
+
+func (*PatchSuite) TestDeltaUnchanged(c *C) {
+	base := patchObject(c, Client{}, patchBase)
+	params, err := base.Delta(base)
+	c.Assert(err, IsNil)
+	c.Check(params, HasLen, 0)
+}
+
+func (*PatchSuite) TestDeltaErrors(c *C) {
+	base := patchObject(c, Client{}, patchBase)
+	for i, test := range []struct {
+		changes map[string]interface{}
+		message string
+	}{{
+		changes: map[string]interface{}{"power": map[string]interface{}{"type": "virsh"}},
+		message: `attribute "power": cannot send a map`,
+	}, {
+		changes: map[string]interface{}{"tags": []interface{}{[]string{"a"}}},
+		message: `attribute "tags": cannot send a nested list`,
+	}, {
+		changes: map[string]interface{}{"tags": []string{}},
+		message: `attribute "tags": cannot send an empty list`,
+	}} {
+		c.Logf("test %d", i)
+		modified, err := base.Merge(test.changes)
+		c.Assert(err, IsNil)
+		_, err = modified.Delta(base)
+		c.Check(err, ErrorMatches, test.message)
+	}
+}
+
+func (*PatchSuite) TestUpdateDeltaSendsOnlyChanges(c *C) {
+	response := `{"resource_uri": "/api/1.0/nodes/n1/", "hostname": "beta"}`
+	server := newSingleServingServer("/api/1.0/nodes/n1/", response, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, IsNil)
+	base := patchObject(c, *client, patchBase)
+	modified, err := base.Merge(map[string]interface{}{"hostname": "beta"})
+	c.Assert(err, IsNil)
+
+	updated, err := modified.UpdateDelta(base)
+	c.Assert(err, IsNil)
+	c.Check(*server.requestContent, Equals, "hostname=beta")
+	hostname, err := updated.GetField("hostname")
+	c.Assert(err, IsNil)
+	c.Check(hostname, Equals, "beta")
+}
+
+func (*PatchSuite) TestUpdateDeltaUnchangedReads(c *C) {
+	server := newSingleServingServer("/api/1.0/nodes/n1/", patchBase, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, IsNil)
+	base := patchObject(c, *client, patchBase)
+
+	_, err = base.UpdateDelta(base)
+	c.Assert(err, IsNil)
+	c.Check(*server.requestContent, Equals, "")
+}
+
+const preconditionObject = `{"resource_uri": "/api/2.0/nodes/n1/", "hostname": "alpha", "updated": "2016-09-01T10:00:00"}`
+
+func (*PatchSuite) TestUnmodified(c *C) {
+	obj := patchObject(c, Client{}, preconditionObject)
+	precondition, err := obj.Unmodified()
+	c.Assert(err, IsNil)
+	c.Check(precondition, Equals, Precondition{Field: "updated", Value: "2016-09-01T10:00:00"})
+
+	_, err = patchObject(c, Client{}, patchBase).Unmodified()
+	c.Check(err, ErrorMatches, `object has no "updated" attribute`)
+}
+
+func (*PatchSuite) TestUpdateIfUnchanged(c *C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/nodes/n1/", http.StatusOK, preconditionObject)
+	server.AddPutResponse("/api/2.0/nodes/n1/", http.StatusOK,
+		`{"resource_uri": "/api/2.0/nodes/n1/", "hostname": "beta", "updated": "2016-09-01T10:05:00"}`)
+	server.Start()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, IsNil)
+	obj := patchObject(c, *client, preconditionObject)
+	precondition, err := obj.Unmodified()
+	c.Assert(err, IsNil)
+
+	updated, err := obj.UpdateIf(url.Values{"hostname": {"beta"}}, precondition)
+	c.Assert(err, IsNil)
+	hostname, err := updated.GetField("hostname")
+	c.Assert(err, IsNil)
+	c.Check(hostname, Equals, "beta")
+	c.Check(server.LastRequest().Method, Equals, "PUT")
+}
+
+func (*PatchSuite) TestUpdateIfConflict(c *C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/nodes/n1/", http.StatusOK,
+		`{"resource_uri": "/api/2.0/nodes/n1/", "hostname": "gamma", "updated": "2016-09-01T10:01:00"}`)
+	server.Start()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, IsNil)
+	obj := patchObject(c, *client, preconditionObject)
+	precondition, err := obj.Unmodified()
+	c.Assert(err, IsNil)
+
+	_, err = obj.UpdateIf(url.Values{"hostname": {"beta"}}, precondition)
+	c.Assert(IsConflictError(err), Equals, true)
+	conflict := err.(*ConflictError)
+	c.Check(conflict.Expected, Equals, "2016-09-01T10:00:00")
+	c.Check(conflict.Actual, Equals, "2016-09-01T10:01:00")
+	c.Check(server.LastRequest().Method, Equals, "GET")
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// PingStatus classifies the result of Controller.Ping.
+type PingStatus int
+
+const (
+	// PingOK means the controller answered the authenticated request.
+	PingOK PingStatus = iota
+
+	// PingUnreachable means no response was received, because the
+	// connection failed or the context was done first.
+	PingUnreachable
+
+	// PingAuthFailed means the controller rejected the credentials.
+	PingAuthFailed
+
+	// PingWrongVersion means the controller does not serve the API version
+	// that the client uses.
+	PingWrongVersion
+
+	// PingProxyError means a proxy between the client and the controller
+	// failed, or something other than MAAS answered the request.
+	PingProxyError
+
+	// PingServerError means the controller answered with an error.
+	PingServerError
+)
+
+var pingStatusNames = map[PingStatus]string{
+	PingOK:           "ok",
+	PingUnreachable:  "unreachable",
+	PingAuthFailed:   "auth-failed",
+	PingWrongVersion: "wrong-version",
+	PingProxyError:   "proxy-error",
+	PingServerError:  "server-error",
+}
+
+// String returns the name of the status, such as "auth-failed".
+func (s PingStatus) String() string {
+	if name, ok := pingStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("PingStatus(%d)", int(s))
+}
+
+// PingResult is the outcome of Controller.Ping.
+type PingResult struct {
+	Status PingStatus
+
+	// Latency is how long the request took.
+	Latency time.Duration
+
+	// Err describes the failure. It is nil if Status is PingOK.
+	Err error
+}
+
+// Ping implements Controller.
+func (c *controller) Ping(ctx context.Context) PingResult {
+	started := c.clock.Now()
+	status, err := c.ping(ctx)
+	return PingResult{
+		Status:  status,
+		Latency: c.clock.Now().Sub(started),
+		Err:     err,
+	}
+}
+
+// ping asks the controller who the user is, which is the smallest request
+// that needs valid credentials, and classifies the result.
+func (c *controller) ping(ctx context.Context) (PingStatus, error) {
+	request, err := c.client.newGetRequest(&url.URL{Path: "users/"}, "whoami", nil)
+	if err != nil {
+		return PingUnreachable, errors.Trace(err)
+	}
+	response, err := c.client.DoRaw(request.WithContext(ctx))
+	if err != nil {
+		if isProxyConnectError(err) {
+			return PingProxyError, errors.Trace(err)
+		}
+		return PingUnreachable, errors.Trace(err)
+	}
+	body, err := readAndClose(response.Body)
+	if err != nil {
+		return PingUnreachable, errors.Trace(err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		err := newServerError(response, body)
+		switch response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return PingAuthFailed, err
+		case http.StatusNotFound, http.StatusGone:
+			return PingWrongVersion, err
+		case http.StatusProxyAuthRequired, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return PingProxyError, err
+		}
+		return PingServerError, err
+	}
+	// MAAS describes the user with a JSON object, and older versions with
+	// a JSON string. Anything else came from something other than MAAS,
+	// such as a captive portal.
+	var user interface{}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return PingProxyError, errors.Annotatef(err, "unexpected response %q", truncate(string(body), 80))
+	}
+	switch user.(type) {
+	case map[string]interface{}, string:
+		return PingOK, nil
+	}
+	return PingProxyError, errors.Errorf("unexpected response %q", truncate(string(body), 80))
+}
+
+// isProxyConnectError reports whether the error is the failure to connect
+// to the proxy of the HTTP client.
+func isProxyConnectError(err error) bool {
+	urlErr, ok := errors.Cause(err).(*url.Error)
+	if !ok {
+		return false
+	}
+	opErr, ok := urlErr.Err.(*net.OpError)
+	return ok && opErr.Op == "proxyconnect"
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length] + "..."
+}
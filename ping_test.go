@@ -0,0 +1,126 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type pingSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&pingSuite{})
+
+func (s *pingSuite) TestPingOK(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, whoamiResponse)
+	result := controller.Ping(context.Background())
+	c.Assert(result.Err, jc.ErrorIsNil)
+	c.Check(result.Status, gc.Equals, PingOK)
+	c.Check(result.Latency > 0, jc.IsTrue)
+	c.Check(server.LastRequest().Header.Get("Authorization"), gc.Matches, "^OAuth .*")
+}
+
+func (s *pingSuite) TestPingOKUserName(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	result := controller.Ping(context.Background())
+	c.Assert(result.Err, jc.ErrorIsNil)
+	c.Check(result.Status, gc.Equals, PingOK)
+}
+
+func (s *pingSuite) TestPingStatusCodes(c *gc.C) {
+	for _, test := range []struct {
+		code   int
+		status PingStatus
+	}{
+		{http.StatusUnauthorized, PingAuthFailed},
+		{http.StatusForbidden, PingAuthFailed},
+		{http.StatusNotFound, PingWrongVersion},
+		{http.StatusBadGateway, PingProxyError},
+		{http.StatusGatewayTimeout, PingProxyError},
+		{http.StatusProxyAuthRequired, PingProxyError},
+		{http.StatusInternalServerError, PingServerError},
+	} {
+		server, controller := createTestServerController(c, s)
+		server.AddGetResponse("/api/2.0/users/?op=whoami", test.code, "no")
+		result := controller.Ping(context.Background())
+		c.Check(result.Status, gc.Equals, test.status, gc.Commentf("code %d", test.code))
+		svrErr, ok := GetServerError(result.Err)
+		c.Assert(ok, jc.IsTrue)
+		c.Check(svrErr.StatusCode, gc.Equals, test.code)
+	}
+}
+
+func (s *pingSuite) TestPingNotMAAS(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, "<html>Sign in to the network</html>")
+	result := controller.Ping(context.Background())
+	c.Check(result.Status, gc.Equals, PingProxyError)
+	c.Check(result.Err, gc.ErrorMatches, `unexpected response "<html>Sign in to the network</html>": .*`)
+}
+
+func (s *pingSuite) TestPingNotUser(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, "[]")
+	result := controller.Ping(context.Background())
+	c.Check(result.Status, gc.Equals, PingProxyError)
+	c.Check(result.Err, gc.ErrorMatches, `unexpected response "\[\]"`)
+}
+
+func (s *pingSuite) TestPingUnreachable(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.Close()
+	result := controller.Ping(context.Background())
+	c.Check(result.Status, gc.Equals, PingUnreachable)
+	c.Check(result.Err, gc.NotNil)
+}
+
+func (s *pingSuite) TestPingContextDone(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := controller.Ping(ctx)
+	c.Check(result.Status, gc.Equals, PingUnreachable)
+	c.Check(result.Err, gc.ErrorMatches, ".*context canceled")
+}
+
+func (s *pingSuite) TestPingProxyUnreachable(c *gc.C) {
+	_, target := createTestServerController(c, s)
+	proxy := httptest.NewServer(http.NotFoundHandler())
+	proxyURL, err := url.Parse(proxy.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	proxy.Close()
+	target.(*controller).client.HTTPClient = &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+	}
+	result := target.Ping(context.Background())
+	c.Check(result.Status, gc.Equals, PingProxyError)
+}
+
+func (*pingSuite) TestPingStatusString(c *gc.C) {
+	c.Check(PingOK.String(), gc.Equals, "ok")
+	c.Check(PingAuthFailed.String(), gc.Equals, "auth-failed")
+	c.Check(PingWrongVersion.String(), gc.Equals, "wrong-version")
+	c.Check(PingProxyError.String(), gc.Equals, "proxy-error")
+	c.Check(PingStatus(42).String(), gc.Equals, "PingStatus(42)")
+}
+
+const whoamiResponse = `
+{
+    "is_superuser": true,
+    "username": "admin",
+    "email": "admin@example.com",
+    "is_local": true,
+    "resource_uri": "/MAAS/api/2.0/users/admin/"
+}
+`
@@ -0,0 +1,122 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+var (
+	// powerPollInterval is how often PowerCycle queries the power state
+	// while it waits for a change.
+	powerPollInterval = 5 * time.Second
+
+	// powerStateTimeout is how long PowerCycle waits for the power state
+	// to change after each power request.
+	powerStateTimeout = 2 * time.Minute
+)
+
+// PowerCycleStage names a stage of Machine.PowerCycle.
+type PowerCycleStage string
+
+// The stages of Machine.PowerCycle, in order.
+const (
+	PowerCycleOff        PowerCycleStage = "powering off"
+	PowerCycleConfirmOff PowerCycleStage = "confirming power off"
+	PowerCycleOn         PowerCycleStage = "powering on"
+	PowerCycleConfirmOn  PowerCycleStage = "confirming power on"
+)
+
+// PowerCycleArgs is an argument struct for Machine.PowerCycle.
+type PowerCycleArgs struct {
+	// Comment is recorded in the machine's event log with both the power
+	// off and the power on.
+	Comment string
+}
+
+// PowerCycle implements Machine.
+func (m *machine) PowerCycle(ctx context.Context, args PowerCycleArgs) error {
+	if err := m.power(ctx, "power_off", args.Comment); err != nil {
+		return NewPowerCycleError(PowerCycleOff, err)
+	}
+	if err := m.waitForPowerState(ctx, PowerOff); err != nil {
+		return NewPowerCycleError(PowerCycleConfirmOff, err)
+	}
+	if err := m.power(ctx, "power_on", args.Comment); err != nil {
+		return NewPowerCycleError(PowerCycleOn, err)
+	}
+	if err := m.waitForPowerState(ctx, PowerOn); err != nil {
+		return NewPowerCycleError(PowerCycleConfirmOn, err)
+	}
+	return nil
+}
+
+// power asks MAAS to power the machine on or off with the op, and updates
+// the machine from the response.
+func (m *machine) power(ctx context.Context, op, comment string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Trace(err)
+	}
+	return m.action(op, comment)
+}
+
+// waitForPowerState queries the BMC until it reports the wanted state, it
+// reports an error, powerStateTimeout passes or the context is done.
+func (m *machine) waitForPowerState(ctx context.Context, want PowerState) error {
+	clock := m.controller.clock
+	deadline := clock.Now().Add(powerStateTimeout)
+	for {
+		state, err := m.queryPowerState()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		m.powerState = state
+		switch ParsePowerState(state) {
+		case want:
+			return nil
+		case PowerError:
+			return NewCannotCompleteError(fmt.Sprintf("machine %s: power state is %q", m.systemID, state))
+		}
+		if !clock.Now().Before(deadline) {
+			message := fmt.Sprintf("machine %s: power state %q after %v, want %q", m.systemID, state, powerStateTimeout, want)
+			return errors.NewTimeout(nil, message)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-clock.After(powerPollInterval):
+		}
+	}
+}
+
+// queryPowerState asks the BMC for the machine's power state. This can be
+// slow since MAAS contacts the BMC before it responds.
+func (m *machine) queryPowerState() (string, error) {
+	result, err := m.controller.getOp(m.resourceURI, "query_power_state")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return "", errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return "", errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			case http.StatusServiceUnavailable:
+				return "", errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
+			}
+		}
+		return "", NewUnexpectedError(err)
+	}
+	checker := schema.FieldMap(schema.Fields{"state": stringField()}, nil)
+	coerced, err := checker.Coerce(m.controller.markLeaves(result), nil)
+	if err != nil {
+		return "", WrapWithDeserializationError(err, "power state schema check failed")
+	}
+	return coerced.(map[string]interface{})["state"].(string), nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type powerCycleSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&powerCycleSuite{})
+
+func (s *powerCycleSuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, *machine, *testClock) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	clock := newTestClock()
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+		Clock:   clock,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, machines[0].(*machine), clock
+}
+
+func addPowerState(server *SimpleTestServer, m *machine, states ...string) {
+	for _, state := range states {
+		server.AddGetResponse(m.resourceURI+"?op=query_power_state", http.StatusOK, `{"state": "`+state+`"}`)
+	}
+}
+
+func (s *powerCycleSuite) TestPowerCycle(c *gc.C) {
+	server, m, clock := s.getServerAndMachine(c)
+	server.AddPostResponse(m.resourceURI+"?op=power_off", http.StatusOK, machineResponse)
+	addPowerState(server, m, "on", "off")
+	server.AddPostResponse(m.resourceURI+"?op=power_on", http.StatusOK, machineResponse)
+	addPowerState(server, m, "off", "off", "on")
+
+	err := m.PowerCycle(context.Background(), PowerCycleArgs{Comment: "replacing the NIC"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(m.PowerState(), gc.Equals, "on")
+	c.Check(clock.Waits(), jc.DeepEquals, []time.Duration{powerPollInterval, powerPollInterval, powerPollInterval})
+	requests := server.LastNRequests(7)
+	c.Check(requests[0].URL.Query().Get("op"), gc.Equals, "power_off")
+	c.Check(requests[0].PostForm.Get("comment"), gc.Equals, "replacing the NIC")
+	c.Check(requests[3].URL.Query().Get("op"), gc.Equals, "power_on")
+	c.Check(requests[3].PostForm.Get("comment"), gc.Equals, "replacing the NIC")
+}
+
+func (s *powerCycleSuite) TestPowerOffFails(c *gc.C) {
+	server, m, _ := s.getServerAndMachine(c)
+	server.AddPostResponse(m.resourceURI+"?op=power_off", http.StatusForbidden, "no power for you")
+
+	err := m.PowerCycle(context.Background(), PowerCycleArgs{})
+	c.Assert(err, jc.Satisfies, IsPowerCycleError)
+	c.Check(err, gc.ErrorMatches, "powering off: no power for you")
+	pcErr := err.(*PowerCycleError)
+	c.Check(pcErr.Stage, gc.Equals, PowerCycleOff)
+	c.Check(pcErr.Reason, jc.Satisfies, IsPermissionError)
+}
+
+func (s *powerCycleSuite) TestConfirmOffTimesOut(c *gc.C) {
+	server, m, clock := s.getServerAndMachine(c)
+	s.PatchValue(&powerStateTimeout, 2*powerPollInterval)
+	server.AddPostResponse(m.resourceURI+"?op=power_off", http.StatusOK, machineResponse)
+	addPowerState(server, m, "on", "on", "on")
+
+	err := m.PowerCycle(context.Background(), PowerCycleArgs{})
+	c.Assert(err, jc.Satisfies, IsPowerCycleError)
+	pcErr := err.(*PowerCycleError)
+	c.Check(pcErr.Stage, gc.Equals, PowerCycleConfirmOff)
+	c.Check(pcErr.Reason, jc.Satisfies, errors.IsTimeout)
+	c.Check(err, gc.ErrorMatches, `confirming power off: machine 4y3ha3: power state "on" after 10s, want "off"`)
+	c.Check(clock.Waits(), gc.HasLen, 2)
+}
+
+func (s *powerCycleSuite) TestConfirmOnPowerError(c *gc.C) {
+	server, m, _ := s.getServerAndMachine(c)
+	server.AddPostResponse(m.resourceURI+"?op=power_off", http.StatusOK, machineResponse)
+	addPowerState(server, m, "off")
+	server.AddPostResponse(m.resourceURI+"?op=power_on", http.StatusOK, machineResponse)
+	addPowerState(server, m, "error")
+
+	err := m.PowerCycle(context.Background(), PowerCycleArgs{})
+	c.Assert(err, jc.Satisfies, IsPowerCycleError)
+	pcErr := err.(*PowerCycleError)
+	c.Check(pcErr.Stage, gc.Equals, PowerCycleConfirmOn)
+	c.Check(pcErr.Reason, jc.Satisfies, IsCannotCompleteError)
+	c.Check(m.PowerState(), gc.Equals, "error")
+}
+
+func (s *powerCycleSuite) TestPowerOnConflict(c *gc.C) {
+	server, m, _ := s.getServerAndMachine(c)
+	server.AddPostResponse(m.resourceURI+"?op=power_off", http.StatusOK, machineResponse)
+	addPowerState(server, m, "off")
+	server.AddPostResponse(m.resourceURI+"?op=power_on", http.StatusConflict, "locked")
+
+	err := m.PowerCycle(context.Background(), PowerCycleArgs{})
+	c.Assert(err, jc.Satisfies, IsPowerCycleError)
+	pcErr := err.(*PowerCycleError)
+	c.Check(pcErr.Stage, gc.Equals, PowerCycleOn)
+	c.Check(pcErr.Reason, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *powerCycleSuite) TestContextDone(c *gc.C) {
+	_, m, _ := s.getServerAndMachine(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := m.PowerCycle(ctx, PowerCycleArgs{})
+	c.Assert(err, jc.Satisfies, IsPowerCycleError)
+	c.Check(errors.Cause(err.(*PowerCycleError).Reason), gc.Equals, context.Canceled)
+}
+
+func (s *powerCycleSuite) TestBadPowerStateResponse(c *gc.C) {
+	server, m, _ := s.getServerAndMachine(c)
+	server.AddPostResponse(m.resourceURI+"?op=power_off", http.StatusOK, machineResponse)
+	server.AddGetResponse(m.resourceURI+"?op=query_power_state", http.StatusOK, `{"status": "off"}`)
+
+	err := m.PowerCycle(context.Background(), PowerCycleArgs{})
+	c.Assert(err, jc.Satisfies, IsPowerCycleError)
+	c.Check(err.(*PowerCycleError).Reason, jc.Satisfies, IsDeserializationError)
+}
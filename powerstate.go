@@ -0,0 +1,44 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+// PowerState is the power state of a machine as last reported by its BMC.
+type PowerState string
+
+// The power states of a machine.
+const (
+	PowerOn      PowerState = "on"
+	PowerOff     PowerState = "off"
+	PowerUnknown PowerState = "unknown"
+	PowerError   PowerState = "error"
+)
+
+// ParsePowerState returns the PowerState for the power_state reported by
+// the API. Anything other than "on", "off" or "error", including an empty
+// state, is PowerUnknown.
+func ParsePowerState(state string) PowerState {
+	switch PowerState(state) {
+	case PowerOn, PowerOff, PowerError:
+		return PowerState(state)
+	}
+	return PowerUnknown
+}
+
+// Known returns whether the state says whether the machine is on.
+func (s PowerState) Known() bool {
+	return s == PowerOn || s == PowerOff
+}
+
+// CheckPowerKnown returns a PowerStateUnknownError if the power state of
+// the machine is unknown or an error, unless force is true. Call it before
+// destructive actions such as deploying, releasing or erasing a machine:
+// a machine whose BMC cannot be read may still be running a workload, and
+// acting on it risks provisioning it twice.
+func CheckPowerKnown(m Machine, force bool) error {
+	state := ParsePowerState(m.PowerState())
+	if force || state.Known() {
+		return nil
+	}
+	return NewPowerStateUnknownError(m.SystemID(), state)
+}
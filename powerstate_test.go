@@ -0,0 +1,42 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type powerStateSuite struct{}
+
+var _ = gc.Suite(&powerStateSuite{})
+
+func (*powerStateSuite) TestParsePowerState(c *gc.C) {
+	for state, expected := range map[string]PowerState{
+		"on":      PowerOn,
+		"off":     PowerOff,
+		"error":   PowerError,
+		"unknown": PowerUnknown,
+		"":        PowerUnknown,
+		"ON":      PowerUnknown,
+	} {
+		c.Check(ParsePowerState(state), gc.Equals, expected, gc.Commentf("%q", state))
+	}
+	c.Check(PowerOn.Known(), jc.IsTrue)
+	c.Check(PowerError.Known(), jc.IsFalse)
+}
+
+func (*powerStateSuite) TestCheckPowerKnown(c *gc.C) {
+	c.Check(CheckPowerKnown(&machine{systemID: "4y3ha3", powerState: "off"}, false), jc.ErrorIsNil)
+
+	unknown := &machine{systemID: "4y3ha3", powerState: "unknown"}
+	err := CheckPowerKnown(unknown, false)
+	c.Check(err, jc.Satisfies, IsPowerStateUnknownError)
+	c.Check(err, gc.ErrorMatches, "machine 4y3ha3: power state is unknown, use force to act on it anyway")
+	c.Check(CheckPowerKnown(unknown, true), jc.ErrorIsNil)
+
+	err = CheckPowerKnown(&machine{systemID: "4y3ha3", powerState: "error"}, false)
+	c.Assert(err, jc.Satisfies, IsPowerStateUnknownError)
+	c.Check(err.(*PowerStateUnknownError).State, gc.Equals, PowerError)
+}
@@ -0,0 +1,165 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/utils/set"
+)
+
+// Profile describes the hardware a machine is expected to have, such as the
+// specification of a delivery from a vendor. Zero values are not checked.
+type Profile struct {
+	MinCPUCount int
+	// MinMemory represented in MB.
+	MinMemory int
+
+	// Disks are the physical disks the machine must have. Each disk of
+	// the machine satisfies at most one of them.
+	Disks []DiskProfile
+
+	// NICs are the physical network interfaces the machine must have.
+	// Each interface of the machine satisfies at most one of them.
+	NICs []NICProfile
+}
+
+// DiskProfile describes a physical disk.
+type DiskProfile struct {
+	// MinSize is in bytes.
+	MinSize uint64
+	// Tags must all be on the disk, such as "ssd".
+	Tags []string
+}
+
+func (d DiskProfile) String() string {
+	desc := fmt.Sprintf("disk of at least %d bytes", d.MinSize)
+	if len(d.Tags) > 0 {
+		desc += " tagged " + strings.Join(d.Tags, ", ")
+	}
+	return desc
+}
+
+func (d DiskProfile) matches(device BlockDevice) bool {
+	return device.Size() >= d.MinSize && set.NewStrings(d.Tags...).Difference(set.NewStrings(device.Tags()...)).IsEmpty()
+}
+
+// NICProfile describes a physical network interface.
+type NICProfile struct {
+	// MinSpeed is compared with the InterfaceSpeed, in Mbit/s. An
+	// interface with an unknown speed only satisfies a zero MinSpeed.
+	MinSpeed int
+}
+
+func (n NICProfile) String() string {
+	return fmt.Sprintf("interface of at least %d Mbit/s", n.MinSpeed)
+}
+
+func (n NICProfile) matches(iface Interface) bool {
+	return iface.InterfaceSpeed() >= n.MinSpeed
+}
+
+// ProfileMismatch is a way in which a machine differs from a Profile.
+type ProfileMismatch struct {
+	// Field is the field of the Profile, such as "MinMemory" or
+	// "Disks[1]".
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (m ProfileMismatch) String() string {
+	return fmt.Sprintf("%s: expected %s, got %s", m.Field, m.Expected, m.Actual)
+}
+
+// MatchesProfile implements Machine.
+func (m *machine) MatchesProfile(p Profile) []ProfileMismatch {
+	var mismatches []ProfileMismatch
+	if m.cpuCount < p.MinCPUCount {
+		mismatches = append(mismatches, ProfileMismatch{
+			Field:    "MinCPUCount",
+			Expected: fmt.Sprintf("at least %d CPUs", p.MinCPUCount),
+			Actual:   fmt.Sprintf("%d", m.cpuCount),
+		})
+	}
+	if m.memory < p.MinMemory {
+		mismatches = append(mismatches, ProfileMismatch{
+			Field:    "MinMemory",
+			Expected: fmt.Sprintf("at least %d MB", p.MinMemory),
+			Actual:   fmt.Sprintf("%d MB", m.memory),
+		})
+	}
+
+	disks := m.PhysicalBlockDevices()
+	diskMatches := matchProfile(len(p.Disks), len(disks), func(want, have int) bool {
+		return p.Disks[want].matches(disks[have])
+	})
+	for i, match := range diskMatches {
+		if match < 0 {
+			mismatches = append(mismatches, ProfileMismatch{
+				Field:    fmt.Sprintf("Disks[%d]", i),
+				Expected: p.Disks[i].String(),
+				Actual:   fmt.Sprintf("no unused matching disk in %d disks", len(disks)),
+			})
+		}
+	}
+
+	var nics []Interface
+	for _, iface := range m.InterfaceSet() {
+		if iface.Type() == "physical" {
+			nics = append(nics, iface)
+		}
+	}
+	nicMatches := matchProfile(len(p.NICs), len(nics), func(want, have int) bool {
+		return p.NICs[want].matches(nics[have])
+	})
+	for i, match := range nicMatches {
+		if match < 0 {
+			mismatches = append(mismatches, ProfileMismatch{
+				Field:    fmt.Sprintf("NICs[%d]", i),
+				Expected: p.NICs[i].String(),
+				Actual:   fmt.Sprintf("no unused matching interface in %d interfaces", len(nics)),
+			})
+		}
+	}
+	return mismatches
+}
+
+// matchProfile pairs each wanted item with a distinct item the machine has,
+// pairing as many as possible. It returns the index of the item paired
+// with each wanted item, or -1 if it could not be paired. A greedy pairing
+// could use a large disk for a small requirement and leave a large
+// requirement unmet, so augmenting paths are used instead.
+func matchProfile(wanted, have int, fits func(want, have int) bool) []int {
+	pairedWith := make([]int, have)
+	for i := range pairedWith {
+		pairedWith[i] = -1
+	}
+	var augment func(want int, seen []bool) bool
+	augment = func(want int, seen []bool) bool {
+		for h := 0; h < have; h++ {
+			if seen[h] || !fits(want, h) {
+				continue
+			}
+			seen[h] = true
+			if pairedWith[h] < 0 || augment(pairedWith[h], seen) {
+				pairedWith[h] = want
+				return true
+			}
+		}
+		return false
+	}
+	result := make([]int, wanted)
+	for want := range result {
+		result[want] = -1
+		augment(want, make([]bool, have))
+	}
+	for h, want := range pairedWith {
+		if want >= 0 {
+			result[want] = h
+		}
+	}
+	return result
+}
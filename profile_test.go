@@ -0,0 +1,152 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type profileSuite struct{}
+
+var _ = gc.Suite(&profileSuite{})
+
+// profileMachine returns the machine of machineResponse, which has one CPU,
+// 1024 MB of memory, two 8 GiB rotary disks and two physical interfaces,
+// after calling modify with its source.
+func (*profileSuite) profileMachine(c *gc.C, modify func(source map[string]interface{})) Machine {
+	source := parseJSON(c, "["+machineResponse+"]")
+	if modify != nil {
+		modify(source.([]interface{})[0].(map[string]interface{}))
+	}
+	machines, err := readMachines(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	return machines[0]
+}
+
+func setDiskSize(source map[string]interface{}, index int, size uint64) {
+	disk := source["physicalblockdevice_set"].([]interface{})[index].(map[string]interface{})
+	disk["size"] = size
+}
+
+func setInterfaceSpeed(source map[string]interface{}, speed int) {
+	for _, value := range source["interface_set"].([]interface{}) {
+		value.(map[string]interface{})["interface_speed"] = speed
+	}
+}
+
+const gib = 1 << 30
+
+func (s *profileSuite) TestMatches(c *gc.C) {
+	m := s.profileMachine(c, func(source map[string]interface{}) {
+		setInterfaceSpeed(source, 1000)
+	})
+	mismatches := m.MatchesProfile(Profile{
+		MinCPUCount: 1,
+		MinMemory:   1024,
+		Disks: []DiskProfile{
+			{MinSize: 8 * gib, Tags: []string{"rotary"}},
+			{MinSize: 8 * gib},
+		},
+		NICs: []NICProfile{{MinSpeed: 1000}},
+	})
+	c.Check(mismatches, gc.HasLen, 0)
+}
+
+func (s *profileSuite) TestEmptyProfileMatches(c *gc.C) {
+	m := s.profileMachine(c, nil)
+	c.Check(m.MatchesProfile(Profile{}), gc.HasLen, 0)
+}
+
+func (s *profileSuite) TestCPUAndMemory(c *gc.C) {
+	m := s.profileMachine(c, nil)
+	mismatches := m.MatchesProfile(Profile{MinCPUCount: 8, MinMemory: 16384})
+	c.Check(mismatches, jc.DeepEquals, []ProfileMismatch{{
+		Field:    "MinCPUCount",
+		Expected: "at least 8 CPUs",
+		Actual:   "1",
+	}, {
+		Field:    "MinMemory",
+		Expected: "at least 16384 MB",
+		Actual:   "1024 MB",
+	}})
+	c.Check(mismatches[1].String(), gc.Equals, "MinMemory: expected at least 16384 MB, got 1024 MB")
+}
+
+func (s *profileSuite) TestTooFewDisks(c *gc.C) {
+	m := s.profileMachine(c, nil)
+	mismatches := m.MatchesProfile(Profile{
+		Disks: []DiskProfile{{MinSize: gib}, {MinSize: gib}, {MinSize: gib}},
+	})
+	c.Check(mismatches, jc.DeepEquals, []ProfileMismatch{{
+		Field:    "Disks[2]",
+		Expected: "disk of at least 1073741824 bytes",
+		Actual:   "no unused matching disk in 2 disks",
+	}})
+}
+
+func (s *profileSuite) TestDiskTags(c *gc.C) {
+	m := s.profileMachine(c, nil)
+	mismatches := m.MatchesProfile(Profile{
+		Disks: []DiskProfile{{MinSize: gib, Tags: []string{"ssd"}}},
+	})
+	c.Assert(mismatches, gc.HasLen, 1)
+	c.Check(mismatches[0].Field, gc.Equals, "Disks[0]")
+	c.Check(mismatches[0].Expected, gc.Equals, "disk of at least 1073741824 bytes tagged ssd")
+}
+
+func (s *profileSuite) TestDiskSize(c *gc.C) {
+	m := s.profileMachine(c, nil)
+	mismatches := m.MatchesProfile(Profile{
+		Disks: []DiskProfile{{MinSize: 8 * gib}, {MinSize: 100 * gib}},
+	})
+	c.Assert(mismatches, gc.HasLen, 1)
+	c.Check(mismatches[0].Field, gc.Equals, "Disks[1]")
+}
+
+func (s *profileSuite) TestDisksPairedToMatchAll(c *gc.C) {
+	// A greedy pairing would use the large first disk for the small
+	// requirement, leaving nothing for the large one.
+	m := s.profileMachine(c, func(source map[string]interface{}) {
+		setDiskSize(source, 0, 20*gib)
+		setDiskSize(source, 1, 10*gib)
+	})
+	mismatches := m.MatchesProfile(Profile{
+		Disks: []DiskProfile{{MinSize: 10 * gib}, {MinSize: 20 * gib}},
+	})
+	c.Check(mismatches, gc.HasLen, 0)
+}
+
+func (s *profileSuite) TestNICSpeed(c *gc.C) {
+	m := s.profileMachine(c, func(source map[string]interface{}) {
+		setInterfaceSpeed(source, 1000)
+	})
+	mismatches := m.MatchesProfile(Profile{NICs: []NICProfile{{MinSpeed: 10000}}})
+	c.Check(mismatches, jc.DeepEquals, []ProfileMismatch{{
+		Field:    "NICs[0]",
+		Expected: "interface of at least 10000 Mbit/s",
+		Actual:   "no unused matching interface in 2 interfaces",
+	}})
+}
+
+func (s *profileSuite) TestNICUnknownSpeed(c *gc.C) {
+	m := s.profileMachine(c, nil)
+	c.Check(m.MatchesProfile(Profile{NICs: []NICProfile{{}}}), gc.HasLen, 0)
+	c.Check(m.MatchesProfile(Profile{NICs: []NICProfile{{MinSpeed: 100}}}), gc.HasLen, 1)
+}
+
+func (s *profileSuite) TestTooFewNICs(c *gc.C) {
+	m := s.profileMachine(c, nil)
+	mismatches := m.MatchesProfile(Profile{NICs: []NICProfile{{}, {}, {}}})
+	c.Assert(mismatches, gc.HasLen, 1)
+	c.Check(mismatches[0].Field, gc.Equals, "NICs[2]")
+}
+
+func (*profileSuite) TestMatchProfile(c *gc.C) {
+	// Wanted 0 fits have 0 and 1, wanted 1 fits only have 0.
+	fits := func(want, have int) bool { return want == 0 || have == 0 }
+	c.Check(matchProfile(2, 2, fits), jc.DeepEquals, []int{1, 0})
+	c.Check(matchProfile(3, 2, fits), jc.DeepEquals, []int{1, 0, -1})
+	c.Check(matchProfile(1, 0, fits), jc.DeepEquals, []int{-1})
+}
@@ -0,0 +1,266 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// The statuses that MAAS reports for the services of a rack controller.
+const (
+	ServiceRunning  = "running"
+	ServiceDegraded = "degraded"
+	ServiceDead     = "dead"
+	ServiceOff      = "off"
+	ServiceUnknown  = "unknown"
+)
+
+type rackController struct {
+	rawJSON
+
+	controller *controller
+
+	resourceURI string
+
+	systemID string
+	hostname string
+	fqdn     string
+	version  string
+
+	ipAddresses []string
+	services    []*service
+}
+
+// SystemID implements RackController.
+func (r *rackController) SystemID() string {
+	return r.systemID
+}
+
+// Hostname implements RackController.
+func (r *rackController) Hostname() string {
+	return r.hostname
+}
+
+// FQDN implements RackController.
+func (r *rackController) FQDN() string {
+	return r.fqdn
+}
+
+// Version implements RackController.
+func (r *rackController) Version() string {
+	return r.version
+}
+
+// IPAddresses implements RackController.
+func (r *rackController) IPAddresses() []string {
+	return r.ipAddresses
+}
+
+// Services implements RackController.
+func (r *rackController) Services() []Service {
+	result := make([]Service, len(r.services))
+	for i, v := range r.services {
+		result[i] = v
+	}
+	return result
+}
+
+// Service implements RackController.
+func (r *rackController) Service(name string) Service {
+	for _, s := range r.services {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// UnhealthyServices implements RackController.
+func (r *rackController) UnhealthyServices() []Service {
+	var result []Service
+	for _, s := range r.services {
+		// Services that are off are not expected to run on this rack.
+		if s.status != ServiceRunning && s.status != ServiceOff {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+type service struct {
+	name       string
+	status     string
+	statusInfo string
+}
+
+// Name implements Service.
+func (s *service) Name() string {
+	return s.name
+}
+
+// Status implements Service.
+func (s *service) Status() string {
+	return s.status
+}
+
+// StatusInfo implements Service.
+func (s *service) StatusInfo() string {
+	return s.statusInfo
+}
+
+// RackControllers implements Controller.
+func (c *controller) RackControllers() ([]RackController, error) {
+	source, err := c.get("rackcontrollers")
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			if svrErr.StatusCode == http.StatusForbidden {
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	racks, err := readRackControllers(c.apiVersion, c.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []RackController
+	for _, r := range racks {
+		r.controller = c
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func readRackControllers(controllerVersion version.Number, source interface{}) ([]*rackController, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "rack controller base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range rackControllerDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no rack controller read func for version %s", controllerVersion)
+	}
+	readFunc := rackControllerDeserializationFuncs[deserialisationVersion]
+	return readRackControllerList(valid, readFunc)
+}
+
+// readRackControllerList expects the values of the sourceList to be string maps.
+func readRackControllerList(sourceList []interface{}, readFunc rackControllerDeserializationFunc) ([]*rackController, error) {
+	result := make([]*rackController, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for rack controller %d, %T", i, value)
+		}
+		rack, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "rack controller %d", i)
+		}
+		result = append(result, rack)
+	}
+	return result, nil
+}
+
+type rackControllerDeserializationFunc func(map[string]interface{}) (*rackController, error)
+
+var rackControllerDeserializationFuncs = map[version.Number]rackControllerDeserializationFunc{
+	twoDotOh: rackController_2_0,
+}
+
+func rackController_2_0(source map[string]interface{}) (*rackController, error) {
+	fields := schema.Fields{
+		"resource_uri": stringField(),
+
+		"system_id": stringField(),
+		"hostname":  stringField(),
+		"fqdn":      stringField(),
+		"version":   nullable(stringField()),
+
+		"ip_addresses": schema.List(stringField()),
+		"service_set":  schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		// Versions of MAAS before 2.3 do not report their version.
+		"version": "",
+		// Nor do older versions report the services.
+		"service_set": []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "rack controller 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	services, err := readServiceList(valid["service_set"].([]interface{}))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	version, _ := valid["version"].(string)
+	result := &rackController{
+		rawJSON:     rawJSON{source},
+		resourceURI: valid["resource_uri"].(string),
+		systemID:    valid["system_id"].(string),
+		hostname:    valid["hostname"].(string),
+		fqdn:        valid["fqdn"].(string),
+		version:     version,
+		ipAddresses: convertToStringSlice(valid["ip_addresses"]),
+		services:    services,
+	}
+	return result, nil
+}
+
+func readServiceList(sourceList []interface{}) ([]*service, error) {
+	result := make([]*service, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for service %d, %T", i, value)
+		}
+		s, err := service_2_0(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "service %d", i)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func service_2_0(source map[string]interface{}) (*service, error) {
+	fields := schema.Fields{
+		"name":        stringField(),
+		"status":      stringField(),
+		"status_info": nullable(stringField()),
+	}
+	defaults := schema.Defaults{
+		"status_info": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "service 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	statusInfo, _ := valid["status_info"].(string)
+	result := &service{
+		name:       valid["name"].(string),
+		status:     valid["status"].(string),
+		statusInfo: statusInfo,
+	}
+	return result, nil
+}
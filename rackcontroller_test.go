@@ -0,0 +1,157 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type rackControllerSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&rackControllerSuite{})
+
+func (*rackControllerSuite) TestReadRackControllersBadSchema(c *gc.C) {
+	_, err := readRackControllers(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `rack controller base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*rackControllerSuite) TestReadRackControllers(c *gc.C) {
+	racks, err := readRackControllers(twoDotOh, parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+
+	rack := racks[0]
+	c.Check(rack.SystemID(), gc.Equals, "4y3h7n")
+	c.Check(rack.Hostname(), gc.Equals, "rack-1")
+	c.Check(rack.FQDN(), gc.Equals, "rack-1.maas")
+	c.Check(rack.Version(), gc.Equals, "2.3.0")
+	c.Check(rack.IPAddresses(), jc.DeepEquals, []string{"192.168.100.2"})
+
+	services := rack.Services()
+	c.Assert(services, gc.HasLen, 4)
+	c.Check(services[0].Name(), gc.Equals, "rackd")
+	c.Check(services[0].Status(), gc.Equals, ServiceRunning)
+	c.Check(services[0].StatusInfo(), gc.Equals, "")
+	c.Check(services[1].Name(), gc.Equals, "dhcpd")
+	c.Check(services[1].Status(), gc.Equals, ServiceDegraded)
+	c.Check(services[1].StatusInfo(), gc.Equals, "no subnets configured")
+}
+
+func (*rackControllerSuite) TestReadRackControllersWithoutServices(c *gc.C) {
+	source := parseJSON(c, rackControllersResponse)
+	rack := source.([]interface{})[0].(map[string]interface{})
+	delete(rack, "version")
+	delete(rack, "service_set")
+	racks, err := readRackControllers(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(racks[0].Version(), gc.Equals, "")
+	c.Check(racks[0].Services(), gc.HasLen, 0)
+	c.Check(racks[0].UnhealthyServices(), gc.HasLen, 0)
+}
+
+func (*rackControllerSuite) TestReadRackControllersBadService(c *gc.C) {
+	source := parseJSON(c, rackControllersResponse)
+	rack := source.([]interface{})[0].(map[string]interface{})
+	rack["service_set"] = []interface{}{map[string]interface{}{"name": "tftp"}}
+	_, err := readRackControllers(twoDotOh, source)
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, `rack controller 0: service 0: service 2.0 schema check failed: .*`)
+}
+
+func (*rackControllerSuite) TestLowVersion(c *gc.C) {
+	_, err := readRackControllers(version.MustParse("1.9.0"), parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no rack controller read func for version 1.9.0`)
+}
+
+func (*rackControllerSuite) TestHighVersion(c *gc.C) {
+	racks, err := readRackControllers(version.MustParse("2.1.9"), parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+}
+
+func (*rackControllerSuite) TestService(c *gc.C) {
+	racks, err := readRackControllers(twoDotOh, parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	tftp := racks[0].Service("tftp")
+	c.Assert(tftp, gc.NotNil)
+	c.Check(tftp.Status(), gc.Equals, ServiceOff)
+	c.Check(racks[0].Service("ntp"), gc.IsNil)
+}
+
+func (*rackControllerSuite) TestUnhealthyServices(c *gc.C) {
+	racks, err := readRackControllers(twoDotOh, parseJSON(c, rackControllersResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	unhealthy := racks[0].UnhealthyServices()
+	c.Assert(unhealthy, gc.HasLen, 2)
+	c.Check(unhealthy[0].Name(), gc.Equals, "dhcpd")
+	c.Check(unhealthy[1].Name(), gc.Equals, "http")
+	c.Check(unhealthy[1].Status(), gc.Equals, ServiceDead)
+}
+
+func (s *rackControllerSuite) getServerAndController(c *gc.C) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+func (s *rackControllerSuite) TestControllerRackControllers(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, rackControllersResponse)
+	racks, err := controller.RackControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+	c.Check(racks[0].SystemID(), gc.Equals, "4y3h7n")
+	c.Check(racks[0].Service("dhcpd").Status(), gc.Equals, ServiceDegraded)
+}
+
+func (s *rackControllerSuite) TestControllerRackControllersForbidden(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusForbidden, "admins only")
+	_, err := controller.RackControllers()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "admins only")
+}
+
+func (s *rackControllerSuite) TestControllerRackControllersUnexpected(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusInternalServerError, "boom")
+	_, err := controller.RackControllers()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+var rackControllersResponse = `
+[
+    {
+        "resource_uri": "/MAAS/api/2.0/rackcontrollers/4y3h7n/",
+        "system_id": "4y3h7n",
+        "hostname": "rack-1",
+        "fqdn": "rack-1.maas",
+        "version": "2.3.0",
+        "ip_addresses": ["192.168.100.2"],
+        "service_set": [
+            {"name": "rackd", "status": "running", "status_info": ""},
+            {"name": "dhcpd", "status": "degraded", "status_info": "no subnets configured"},
+            {"name": "tftp", "status": "off", "status_info": null},
+            {"name": "http", "status": "dead", "status_info": "http service is not running"}
+        ]
+    }
+]
+`
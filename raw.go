@@ -0,0 +1,46 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+)
+
+// rawJSON is embedded in the entities to implement RawEntity. It holds the
+// object that the entity was read from.
+type rawJSON struct {
+	source map[string]interface{}
+}
+
+// Raw implements RawEntity.
+func (r rawJSON) Raw() []byte {
+	if r.source == nil {
+		return nil
+	}
+	// The source was decoded from JSON, so it can always be encoded again.
+	raw, _ := json.Marshal(unmarkLeaves(r.source))
+	return raw
+}
+
+// unmarkLeaves returns a copy of source with the jsonLeaf values added by
+// markLeaves replaced by the values they hold.
+func unmarkLeaves(source interface{}) interface{} {
+	switch value := source.(type) {
+	case jsonLeaf:
+		return value.value
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			result[key] = unmarkLeaves(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = unmarkLeaves(item)
+		}
+		return result
+	}
+	return source
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type rawSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&rawSuite{})
+
+func rawMap(c *gc.C, entity RawEntity) map[string]interface{} {
+	var result map[string]interface{}
+	c.Assert(json.Unmarshal(entity.Raw(), &result), jc.ErrorIsNil)
+	return result
+}
+
+func (*rawSuite) TestRawIncludesUnknownFields(c *gc.C) {
+	source := parseJSON(c, `[{"name": "default", "description": "", "resource_uri": "/MAAS/api/2.0/zones/default/", "new_field": {"answer": 42}}]`)
+	zones, err := readZones(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(zones, gc.HasLen, 1)
+	raw := rawMap(c, zones[0])
+	c.Check(raw["name"], gc.Equals, "default")
+	c.Check(raw["new_field"], jc.DeepEquals, map[string]interface{}{"answer": float64(42)})
+}
+
+func (*rawSuite) TestRawNestedEntities(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	machine := machines[0]
+	c.Check(rawMap(c, machine)["system_id"], gc.Equals, machine.SystemID())
+	iface := machine.InterfaceSet()[0]
+	c.Check(rawMap(c, iface)["name"], gc.Equals, iface.Name())
+	c.Check(rawMap(c, iface.VLAN())["vid"], gc.Equals, float64(iface.VLAN().VID()))
+}
+
+func (*rawSuite) TestRawUnmarksLeaves(c *gc.C) {
+	source := markLeaves(parseJSON(c, zoneResponse), DecodeStrict)
+	zones, err := readZones(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(zones[0].Raw()), gc.Equals,
+		`{"description":"default description","name":"default","resource_uri":"/MAAS/api/2.0/zones/default/"}`)
+}
+
+func (*rawSuite) TestRawEmpty(c *gc.C) {
+	c.Check(rawJSON{}.Raw(), gc.IsNil)
+}
+
+func (s *rawSuite) TestRawUpdated(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	machine := machines[0].(*machine)
+
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name": "Deploying",
+		"new_field":   "new value",
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, response)
+	c.Assert(machine.Start(StartArgs{}), jc.ErrorIsNil)
+	c.Check(rawMap(c, machine)["new_field"], gc.Equals, "new value")
+}
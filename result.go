@@ -0,0 +1,107 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CallResult describes the requests a call made, for tracking the latency
+// and error rates of a MAAS region. See Measure.
+type CallResult struct {
+	// Err is the error the call returned.
+	Err error
+
+	// Duration is how long the call took.
+	Duration time.Duration
+
+	// Attempts is the number of requests sent, including retries.
+	Attempts int
+
+	// StatusCode is the HTTP status of the last response, or zero if no
+	// response was received.
+	StatusCode int
+
+	// BodyLength is the number of bytes read from the response bodies.
+	BodyLength int64
+}
+
+// Measure makes the call with a Controller that records the requests it
+// sends, and returns the error of the call with the details of those
+// requests. The call should assign its typed results to variables outside
+// it:
+//
+//	var zones []Zone
+//	result := Measure(maas, func(maas Controller) (err error) {
+//		zones, err = maas.Zones()
+//		return err
+//	})
+//
+// Entities read in the call are tied to the recording Controller, so calls
+// on them after Measure returns are not recorded. If c was not returned by
+// NewController, the call is made with c and only the error and duration
+// are set.
+func Measure(c Controller, call func(Controller) error) CallResult {
+	recorder := &callRecorder{}
+	measured, clock := c, WallClock
+	if inner, ok := c.(*controller); ok {
+		withRecorder := *inner
+		client := *inner.client
+		client.recorder = recorder
+		withRecorder.client = &client
+		measured, clock = &withRecorder, inner.clock
+	}
+	start := clock.Now()
+	err := call(measured)
+	result := recorder.result()
+	result.Err = err
+	result.Duration = clock.Now().Sub(start)
+	return result
+}
+
+// callRecorder counts the requests of a Client.
+type callRecorder struct {
+	mu         sync.Mutex
+	attempts   int
+	statusCode int
+	bodyLength int64
+}
+
+func (r *callRecorder) record(response *http.Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	r.statusCode = 0
+	if response != nil {
+		r.statusCode = response.StatusCode
+		response.Body = &countingBody{ReadCloser: response.Body, recorder: r}
+	}
+}
+
+func (r *callRecorder) addBytes(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodyLength += int64(n)
+}
+
+func (r *callRecorder) result() CallResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return CallResult{Attempts: r.attempts, StatusCode: r.statusCode, BodyLength: r.bodyLength}
+}
+
+// countingBody adds the bytes read from a response body to its recorder.
+type countingBody struct {
+	io.ReadCloser
+	recorder *callRecorder
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.recorder.addBytes(n)
+	return n, err
+}
@@ -0,0 +1,113 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type resultSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&resultSuite{})
+
+func (s *resultSuite) getServerAndController(c *gc.C) (*SimpleTestServer, Controller, *testClock) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	clock := newTestClock()
+	maas, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+		Clock:   clock,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, maas, clock
+}
+
+func (s *resultSuite) TestMeasure(c *gc.C) {
+	server, maas, clock := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+
+	var zones []Zone
+	result := Measure(maas, func(maas Controller) (err error) {
+		zones, err = maas.Zones()
+		clock.After(3 * time.Second)
+		return err
+	})
+	c.Assert(result.Err, jc.ErrorIsNil)
+	c.Check(zones, gc.HasLen, 2)
+	c.Check(result.Attempts, gc.Equals, 1)
+	c.Check(result.StatusCode, gc.Equals, http.StatusOK)
+	c.Check(result.BodyLength, gc.Equals, int64(len(zoneResponse)))
+	c.Check(result.Duration, gc.Equals, 3*time.Second)
+
+	// The controller passed in does not record.
+	_, err := maas.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(maas.(*controller).client.recorder, gc.IsNil)
+}
+
+func (s *resultSuite) TestMeasureRetries(c *gc.C) {
+	zoneRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/2.0/users/":
+			w.Write([]byte(`"captain awesome"`))
+		case "/api/2.0/version/":
+			w.Write([]byte(versionResponse))
+		default:
+			zoneRequests++
+			if zoneRequests == 1 {
+				w.Header().Set(RetryAfterHeaderName, "1")
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, "gone", http.StatusNotFound)
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	maas, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+		Clock:   newTestClock(),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	result := Measure(maas, func(maas Controller) error {
+		_, err := maas.Zones()
+		return err
+	})
+	c.Check(result.Err, gc.NotNil)
+	c.Check(result.Attempts, gc.Equals, 2)
+	c.Check(result.StatusCode, gc.Equals, http.StatusNotFound)
+	c.Check(result.BodyLength, gc.Equals, int64(len("busy\n")+len("gone\n")))
+}
+
+type fakeMeasuredController struct {
+	Controller
+}
+
+func (s *resultSuite) TestMeasureOtherController(c *gc.C) {
+	fake := fakeMeasuredController{}
+	var called Controller
+	result := Measure(fake, func(maas Controller) error {
+		called = maas
+		return errors.New("boom")
+	})
+	c.Check(called, gc.Equals, Controller(fake))
+	c.Check(result.Err, gc.ErrorMatches, "boom")
+	c.Check(result.Attempts, gc.Equals, 0)
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// RequestPriority is the class a RequestScheduler puts a request in.
+type RequestPriority int
+
+const (
+	// PriorityInteractive is for requests a person is waiting on, such as
+	// reading or changing a single machine.
+	PriorityInteractive RequestPriority = iota
+
+	// PriorityBulk is for background work such as listing every machine.
+	PriorityBulk
+)
+
+type requestPriorityKey struct{}
+
+// WithRequestPriority returns a context that puts the requests made with
+// it in the priority class, overriding the class a RequestScheduler would
+// otherwise choose. Values above PriorityBulk are treated as PriorityBulk,
+// and values below PriorityInteractive as PriorityInteractive.
+func WithRequestPriority(ctx context.Context, priority RequestPriority) context.Context {
+	return context.WithValue(ctx, requestPriorityKey{}, priority)
+}
+
+// RequestScheduler limits the requests in progress on the Clients that
+// share it, and when requests are waiting, lets the interactive ones go
+// before the bulk ones. This stops tools that show single machines from
+// waiting behind batch jobs that list the whole region. Requests in the
+// same class go in the order they arrived. Bulk requests wait for as long
+// as there are interactive ones waiting.
+//
+// Unless its context says otherwise, a request is bulk if it is a GET of a
+// top level collection, such as machines/ or subnets/, and interactive
+// otherwise. A request holds its place until its response body is closed.
+type RequestScheduler struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	waiting [2][]chan struct{}
+}
+
+// NewRequestScheduler returns a scheduler that allows at most limit
+// requests in progress at once.
+func NewRequestScheduler(limit int) (*RequestScheduler, error) {
+	if limit < 1 {
+		return nil, errors.NotValidf("request limit %d", limit)
+	}
+	return &RequestScheduler{limit: limit}, nil
+}
+
+// acquire waits until a request of the priority may start, or the context
+// is done.
+func (s *RequestScheduler) acquire(ctx context.Context, priority RequestPriority) error {
+	if priority < PriorityInteractive {
+		priority = PriorityInteractive
+	} else if priority > PriorityBulk {
+		priority = PriorityBulk
+	}
+	s.mu.Lock()
+	if s.active < s.limit && len(s.waiting[PriorityInteractive]) == 0 &&
+		(priority == PriorityInteractive || len(s.waiting[PriorityBulk]) == 0) {
+		s.active++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	s.waiting[priority] = append(s.waiting[priority], ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.waiting[priority]
+	for i, waiter := range queue {
+		if waiter == ready {
+			s.waiting[priority] = append(queue[:i], queue[i+1:]...)
+			return errors.Trace(ctx.Err())
+		}
+	}
+	// The place was given to this request as the context finished, so
+	// pass it on.
+	s.releaseLocked()
+	return errors.Trace(ctx.Err())
+}
+
+// release ends a request, letting the next waiting one start.
+func (s *RequestScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+func (s *RequestScheduler) releaseLocked() {
+	for priority := range s.waiting {
+		if queue := s.waiting[priority]; len(queue) > 0 {
+			s.waiting[priority] = queue[1:]
+			close(queue[0])
+			return
+		}
+	}
+	s.active--
+}
+
+// requestPriority returns the class of the request sent to the API at
+// apiURL.
+func requestPriority(request *http.Request, apiURL string) RequestPriority {
+	if priority, ok := request.Context().Value(requestPriorityKey{}).(RequestPriority); ok {
+		return priority
+	}
+	if request.Method != http.MethodGet {
+		return PriorityInteractive
+	}
+	path := strings.Trim(strings.TrimPrefix(request.URL.Path, apiURL), "/")
+	if path != "" && !strings.Contains(path, "/") {
+		return PriorityBulk
+	}
+	return PriorityInteractive
+}
+
+// scheduledBody releases the place of its request when it is closed.
+type scheduledBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *scheduledBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,210 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type schedulerSuite struct{}
+
+var _ = gc.Suite(&schedulerSuite{})
+
+func (*schedulerSuite) TestNewRequestSchedulerLimit(c *gc.C) {
+	_, err := NewRequestScheduler(0)
+	c.Check(err, gc.ErrorMatches, "request limit 0 not valid")
+}
+
+// queued starts a request of the priority, which sends the label when it
+// starts, and waits until it is in the queue behind the others.
+func queued(c *gc.C, s *RequestScheduler, priority RequestPriority, label string, started chan<- string) {
+	queuedAs(c, s, priority, priority, label, started)
+}
+
+// queuedAs is queued for a request of the priority that waits in the
+// queue of the class.
+func queuedAs(c *gc.C, s *RequestScheduler, priority, class RequestPriority, label string, started chan<- string) {
+	s.mu.Lock()
+	ahead := len(s.waiting[class])
+	s.mu.Unlock()
+	go func() {
+		c.Check(s.acquire(context.Background(), priority), jc.ErrorIsNil)
+		started <- label
+	}()
+	for {
+		s.mu.Lock()
+		count := len(s.waiting[class])
+		s.mu.Unlock()
+		if count > ahead {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (*schedulerSuite) TestInteractiveFirst(c *gc.C) {
+	s, err := NewRequestScheduler(1)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.acquire(context.Background(), PriorityBulk), jc.ErrorIsNil)
+
+	started := make(chan string, 3)
+	queued(c, s, PriorityBulk, "bulk", started)
+	queued(c, s, PriorityInteractive, "interactive-1", started)
+	queued(c, s, PriorityInteractive, "interactive-2", started)
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		s.release()
+		select {
+		case label := <-started:
+			order = append(order, label)
+		case <-time.After(5 * time.Second):
+			c.Fatalf("request not started")
+		}
+	}
+	c.Check(order, jc.DeepEquals, []string{"interactive-1", "interactive-2", "bulk"})
+	s.release()
+	c.Check(s.active, gc.Equals, 0)
+}
+
+func (*schedulerSuite) TestAcquireCancelled(c *gc.C) {
+	s, err := NewRequestScheduler(1)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.acquire(context.Background(), PriorityInteractive), jc.ErrorIsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = s.acquire(ctx, PriorityBulk)
+	c.Check(errors.Cause(err), gc.Equals, context.DeadlineExceeded)
+	c.Check(s.waiting[PriorityBulk], gc.HasLen, 0)
+
+	s.release()
+	c.Check(s.active, gc.Equals, 0)
+	c.Check(s.acquire(context.Background(), PriorityBulk), jc.ErrorIsNil)
+}
+
+func (*schedulerSuite) TestAcquireClampsPriority(c *gc.C) {
+	s, err := NewRequestScheduler(1)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.acquire(context.Background(), PriorityInteractive), jc.ErrorIsNil)
+
+	started := make(chan string, 2)
+	queued(c, s, PriorityBulk, "bulk", started)
+	queuedAs(c, s, RequestPriority(-1), PriorityInteractive, "interactive", started)
+	queuedAs(c, s, RequestPriority(7), PriorityBulk, "bulk-7", started)
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		s.release()
+		select {
+		case label := <-started:
+			order = append(order, label)
+		case <-time.After(5 * time.Second):
+			c.Fatalf("request not started")
+		}
+	}
+	c.Check(order, jc.DeepEquals, []string{"interactive", "bulk", "bulk-7"})
+}
+
+func (*schedulerSuite) TestRequestPriority(c *gc.C) {
+	for i, test := range []struct {
+		method   string
+		url      string
+		ctx      context.Context
+		expected RequestPriority
+	}{{
+		method:   "GET",
+		url:      "http://maas/MAAS/api/2.0/machines/",
+		expected: PriorityBulk,
+	}, {
+		method:   "GET",
+		url:      "http://maas/MAAS/api/2.0/machines/?op=list_allocated",
+		expected: PriorityBulk,
+	}, {
+		method:   "GET",
+		url:      "http://maas/MAAS/api/2.0/machines/4y3ha3/",
+		expected: PriorityInteractive,
+	}, {
+		method:   "POST",
+		url:      "http://maas/MAAS/api/2.0/machines/",
+		expected: PriorityInteractive,
+	}, {
+		method:   "GET",
+		url:      "http://maas/MAAS/api/2.0/machines/4y3ha3/",
+		ctx:      WithRequestPriority(context.Background(), PriorityBulk),
+		expected: PriorityBulk,
+	}} {
+		c.Logf("test %d", i)
+		request, err := http.NewRequest(test.method, test.url, nil)
+		c.Assert(err, jc.ErrorIsNil)
+		if test.ctx != nil {
+			request = request.WithContext(test.ctx)
+		}
+		c.Check(requestPriority(request, "/MAAS/api/2.0/"), gc.Equals, test.expected)
+	}
+}
+
+func (*schedulerSuite) TestClientHoldsPlaceUntilBodyClosed(c *gc.C) {
+	var inFlight, most int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			old := atomic.LoadInt32(&most)
+			if n <= old || atomic.CompareAndSwapInt32(&most, old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte(`"ok"`))
+	}))
+	defer server.Close()
+	scheduler, err := NewRequestScheduler(1)
+	c.Assert(err, jc.ErrorIsNil)
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.Scheduler = scheduler
+
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := client.Get(&url.URL{Path: "machines/"}, "", nil)
+			done <- err
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		c.Check(<-done, jc.ErrorIsNil)
+	}
+	c.Check(atomic.LoadInt32(&most), gc.Equals, int32(1))
+	c.Check(scheduler.active, gc.Equals, 0)
+}
+
+func (*schedulerSuite) TestControllerScheduler(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	server.Start()
+	defer server.Close()
+	scheduler, err := NewRequestScheduler(2)
+	c.Assert(err, jc.ErrorIsNil)
+	maas, err := NewController(ControllerArgs{
+		BaseURL:   server.URL,
+		APIKey:    "fake:as:key",
+		Scheduler: scheduler,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = maas.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(scheduler.active, gc.Equals, 0)
+}
@@ -0,0 +1,173 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// FilterStrategy says where a filter of a machine search was applied.
+type FilterStrategy string
+
+const (
+	// FilterServer - the filter was sent to MAAS, so only the machines
+	// that match it were returned.
+	FilterServer FilterStrategy = "server"
+
+	// FilterClient - MAAS does not support the filter, so all the
+	// machines were read and those that do not match it dropped.
+	FilterClient FilterStrategy = "client"
+)
+
+// The names of the filters of a SearchMachinesArgs, as reported in
+// SearchMachinesResult.Strategies.
+const (
+	SearchHostname  = "hostname"
+	SearchMAC       = "mac_address"
+	SearchTags      = "tags"
+	SearchZone      = "zone"
+	SearchOwnerData = "owner_data"
+)
+
+// serverTagsVersion is the first MAAS version that filters the machine
+// list by tags.
+var serverTagsVersion = [2]int{2, 5}
+
+// SearchMachinesArgs is an argument struct for Controller.SearchMachines.
+// Machines must match every filter that is set.
+type SearchMachinesArgs struct {
+	// Hostnames, if set, matches machines with any of the hostnames.
+	Hostnames []string
+
+	// MACAddresses, if set, matches machines with an interface that has
+	// any of the MAC addresses.
+	MACAddresses []string
+
+	// Tags, if set, matches machines with all the tags.
+	Tags []string
+
+	// Zone, if set, matches machines in the zone.
+	Zone string
+
+	// OwnerData, if set, matches machines whose owner data has all the
+	// keys with the values.
+	OwnerData map[string]string
+}
+
+// SearchMachinesResult is returned by Controller.SearchMachines.
+type SearchMachinesResult struct {
+	// Machines are the machines that match all the filters.
+	Machines []Machine
+
+	// Strategies maps the name of each filter that was set, such as
+	// SearchTags, to where it was applied. Filters applied on the server
+	// reduce the number of machines read, so a caller searching often by
+	// a client side filter may want to combine it with a server side one.
+	Strategies map[string]FilterStrategy
+}
+
+// SearchMachines implements Controller.
+func (c *controller) SearchMachines(args SearchMachinesArgs) (SearchMachinesResult, error) {
+	var empty SearchMachinesResult
+	macs, err := normalizeMACAddresses("MACAddresses", args.MACAddresses)
+	if err != nil {
+		return empty, errors.Trace(err)
+	}
+	strategies := make(map[string]FilterStrategy)
+	params := NewURLParams()
+	if len(args.Hostnames) > 0 {
+		params.MaybeAddMany("hostname", args.Hostnames)
+		strategies[SearchHostname] = FilterServer
+	}
+	if len(macs) > 0 {
+		params.MaybeAddMany("mac_address", macs)
+		strategies[SearchMAC] = FilterServer
+	}
+	if args.Zone != "" {
+		params.MaybeAdd("zone", args.Zone)
+		strategies[SearchZone] = FilterServer
+	}
+	if len(args.Tags) > 0 {
+		strategies[SearchTags] = FilterClient
+		if versionAtLeast(c.serverVersion, serverTagsVersion) {
+			params.MaybeAddMany("tags", args.Tags)
+			strategies[SearchTags] = FilterServer
+		}
+	}
+	if len(args.OwnerData) > 0 {
+		strategies[SearchOwnerData] = FilterClient
+	}
+	source, err := c.getQuery("machines", params.Values)
+	if err != nil {
+		return empty, NewUnexpectedError(err)
+	}
+	machines, err := readMachines(c.apiVersion, c.markLeaves(source))
+	if err != nil {
+		return empty, errors.Trace(err)
+	}
+	// Every filter is checked here too, as older versions of MAAS ignore
+	// the parameters they do not know.
+	var result []Machine
+	for _, m := range machines {
+		m.controller = c
+		if searchMatches(m, args, macs) {
+			result = append(result, m)
+		}
+	}
+	return SearchMachinesResult{Machines: result, Strategies: strategies}, nil
+}
+
+// searchMatches returns whether the machine matches the filters of the args.
+func searchMatches(m *machine, args SearchMachinesArgs, macs []string) bool {
+	if len(args.Hostnames) > 0 && !set.NewStrings(args.Hostnames...).Contains(m.hostname) {
+		return false
+	}
+	if len(macs) > 0 {
+		wanted := set.NewStrings(macs...)
+		found := false
+		for _, iface := range m.interfaceSet {
+			if mac, err := NormalizeMACAddress(iface.MACAddress()); err == nil && wanted.Contains(mac) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if args.Zone != "" && (m.zone == nil || m.zone.Name() != args.Zone) {
+		return false
+	}
+	if len(args.Tags) > 0 {
+		tags := set.NewStrings(m.tags...)
+		for _, tag := range args.Tags {
+			if !tags.Contains(tag) {
+				return false
+			}
+		}
+	}
+	return ownerDataMatches(m.ownerData, args.OwnerData)
+}
+
+var serverVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// versionAtLeast returns whether the MAAS version, such as "2.9.2~rc1", is
+// at least the major and minor version. Versions that cannot be parsed,
+// such as "unknown", are taken to be older.
+func versionAtLeast(serverVersion string, least [2]int) bool {
+	match := serverVersionPattern.FindStringSubmatch(serverVersion)
+	if match == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	if major != least[0] {
+		return major > least[0]
+	}
+	return minor >= least[1]
+}
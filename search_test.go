@@ -0,0 +1,114 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type searchSuite struct{}
+
+var _ = gc.Suite(&searchSuite{})
+
+func (*searchSuite) getController(c *gc.C, serverVersion string, machines map[string]string) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK,
+		strings.Replace(versionResponse, `"unknown"`, `"`+serverVersion+`"`, 1))
+	for query, response := range machines {
+		server.AddGetResponse("/api/2.0/machines/"+query, http.StatusOK, response)
+	}
+	server.Start()
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+func hostnames(machines []Machine) []string {
+	var result []string
+	for _, m := range machines {
+		result = append(result, m.Hostname())
+	}
+	return result
+}
+
+func (s *searchSuite) TestTagsClientSide(c *gc.C) {
+	server, controller := s.getController(c, "unknown", map[string]string{
+		"?zone=default": machinesResponse,
+	})
+	defer server.Close()
+	result, err := controller.SearchMachines(SearchMachinesArgs{
+		Tags: []string{"magic"},
+		Zone: "default",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(hostnames(result.Machines), jc.DeepEquals, []string{"untasted-markita"})
+	c.Check(result.Strategies, jc.DeepEquals, map[string]FilterStrategy{
+		SearchTags: FilterClient,
+		SearchZone: FilterServer,
+	})
+	c.Check(server.LastRequest().URL.Query().Get("tags"), gc.Equals, "")
+}
+
+func (s *searchSuite) TestTagsServerSide(c *gc.C) {
+	server, controller := s.getController(c, "2.9.2", map[string]string{
+		"?tags=virtual": machinesResponse,
+	})
+	defer server.Close()
+	result, err := controller.SearchMachines(SearchMachinesArgs{
+		Tags:      []string{"virtual"},
+		OwnerData: map[string]string{"braid": "jonathan blow"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(hostnames(result.Machines), jc.DeepEquals, []string{"lowlier-glady", "icier-nina"})
+	c.Check(result.Strategies, jc.DeepEquals, map[string]FilterStrategy{
+		SearchTags:      FilterServer,
+		SearchOwnerData: FilterClient,
+	})
+}
+
+func (s *searchSuite) TestServerFiltersChecked(c *gc.C) {
+	// An old MAAS that ignores the filters returns all the machines.
+	server, controller := s.getController(c, "2.0.0", map[string]string{
+		"?mac_address=52%3A54%3A00%3A33%3A6b%3A2c": machinesResponse,
+	})
+	defer server.Close()
+	result, err := controller.SearchMachines(SearchMachinesArgs{
+		MACAddresses: []string{"52-54-00-33-6b-2c"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(hostnames(result.Machines), jc.DeepEquals, []string{"lowlier-glady"})
+	c.Check(result.Strategies, jc.DeepEquals, map[string]FilterStrategy{SearchMAC: FilterServer})
+}
+
+func (s *searchSuite) TestInvalidMAC(c *gc.C) {
+	server, controller := s.getController(c, "unknown", nil)
+	defer server.Close()
+	_, err := controller.SearchMachines(SearchMachinesArgs{MACAddresses: []string{"nope"}})
+	c.Check(err, jc.Satisfies, IsArgumentError)
+}
+
+func (*searchSuite) TestVersionAtLeast(c *gc.C) {
+	for _, test := range []struct {
+		version  string
+		expected bool
+	}{
+		{"2.5.0", true},
+		{"2.10", true},
+		{"3.0.1~rc1", true},
+		{"2.4.2", false},
+		{"1.9", false},
+		{"unknown", false},
+		{"", false},
+	} {
+		c.Check(versionAtLeast(test.version, [2]int{2, 5}), gc.Equals, test.expected, gc.Commentf(test.version))
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+)
+
+// Selector picks machines by their tags, zone, pool and architecture. It is
+// evaluated on the client, over machines that have already been listed.
+//
+// A selector expression is made of terms combined with "and", "or", "not"
+// and parentheses. "not" binds tightest and "and" binds tighter than "or".
+// Terms are:
+//
+//	name        the machine has the tag name
+//	tag:name    the same
+//	zone:name   the machine is in the zone
+//	pool:name   the machine is in the resource pool
+//	arch:name   the machine's architecture is name, or name/subarch
+//
+// For example:
+//
+//	(gpu or tag:nvme) and not zone:lab and arch:amd64
+type Selector struct {
+	expression string
+	root       selectorNode
+}
+
+// ParseSelector parses a selector expression. The error satisfies
+// errors.IsNotValid if the expression is malformed.
+func ParseSelector(expression string) (*Selector, error) {
+	p := &selectorParser{tokens: scanSelector(expression)}
+	root, err := p.parseOr()
+	if err == nil && !p.done() {
+		err = errors.Errorf("unexpected %q", p.peek())
+	}
+	if err != nil {
+		return nil, errors.NewNotValid(err, "selector "+quoteSelector(expression))
+	}
+	return &Selector{expression: expression, root: root}, nil
+}
+
+// String returns the expression that the selector was parsed from.
+func (s *Selector) String() string {
+	return s.expression
+}
+
+// Matches returns true if the machine is selected.
+func (s *Selector) Matches(machine Machine) bool {
+	return s.root.matches(machine)
+}
+
+// Filter returns the selected machines, in the order given.
+func (s *Selector) Filter(machines []Machine) []Machine {
+	var result []Machine
+	for _, machine := range machines {
+		if s.Matches(machine) {
+			result = append(result, machine)
+		}
+	}
+	return result
+}
+
+// SelectMachines implements Controller.
+func (c *controller) SelectMachines(expression string, args MachinesArgs) ([]Machine, error) {
+	selector, err := ParseSelector(expression)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	machines, err := c.Machines(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return selector.Filter(machines), nil
+}
+
+func quoteSelector(expression string) string {
+	return `"` + strings.Replace(expression, `"`, `\"`, -1) + `"`
+}
+
+type selectorNode interface {
+	matches(Machine) bool
+}
+
+type selectorAnd []selectorNode
+
+func (n selectorAnd) matches(machine Machine) bool {
+	for _, child := range n {
+		if !child.matches(machine) {
+			return false
+		}
+	}
+	return true
+}
+
+type selectorOr []selectorNode
+
+func (n selectorOr) matches(machine Machine) bool {
+	for _, child := range n {
+		if child.matches(machine) {
+			return true
+		}
+	}
+	return false
+}
+
+type selectorNot struct {
+	child selectorNode
+}
+
+func (n selectorNot) matches(machine Machine) bool {
+	return !n.child.matches(machine)
+}
+
+type selectorTerm struct {
+	kind  string
+	value string
+}
+
+func (t selectorTerm) matches(machine Machine) bool {
+	switch t.kind {
+	case "tag":
+		for _, tag := range machine.Tags() {
+			if tag == t.value {
+				return true
+			}
+		}
+		return false
+	case "zone":
+		zone := machine.Zone()
+		return zone != nil && zone.Name() == t.value
+	case "pool":
+		return machinePoolName(machine) == t.value
+	case "arch":
+		arch := machine.Architecture()
+		return arch == t.value || strings.HasPrefix(arch, t.value+"/")
+	}
+	return false
+}
+
+// machinePoolName returns the name of the resource pool that the machine is
+// in. Pools are not part of the Machine interface since MAAS versions before
+// 2.4 do not have them, so the name is read from the raw JSON. It is empty
+// if the machine has no pool.
+func machinePoolName(machine Machine) string {
+	var fields struct {
+		Pool *struct {
+			Name string `json:"name"`
+		} `json:"pool"`
+	}
+	if err := json.Unmarshal(machine.Raw(), &fields); err != nil || fields.Pool == nil {
+		return ""
+	}
+	return fields.Pool.Name
+}
+
+var selectorKinds = map[string]bool{
+	"tag":  true,
+	"zone": true,
+	"pool": true,
+	"arch": true,
+}
+
+// scanSelector splits the expression into parentheses and words.
+func scanSelector(expression string) []string {
+	var tokens []string
+	word := -1
+	for i, r := range expression {
+		if r == '(' || r == ')' || unicode.IsSpace(r) {
+			if word >= 0 {
+				tokens = append(tokens, expression[word:i])
+				word = -1
+			}
+			if r == '(' || r == ')' {
+				tokens = append(tokens, string(r))
+			}
+			continue
+		}
+		if word < 0 {
+			word = i
+		}
+	}
+	if word >= 0 {
+		tokens = append(tokens, expression[word:])
+	}
+	return tokens
+}
+
+// selectorParser is a recursive descent parser over the scanned tokens.
+type selectorParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *selectorParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *selectorParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *selectorParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *selectorParser) parseOr() (selectorNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := selectorOr{node}
+	for p.peek() == "or" {
+		p.next()
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *selectorParser) parseAnd() (selectorNode, error) {
+	node, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	nodes := selectorAnd{node}
+	for p.peek() == "and" {
+		p.next()
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *selectorParser) parseNot() (selectorNode, error) {
+	if p.peek() == "not" {
+		p.next()
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return selectorNot{node}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *selectorParser) parseTerm() (selectorNode, error) {
+	switch token := p.next(); token {
+	case "":
+		return nil, errors.New("unexpected end of expression")
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New(`missing ")"`)
+		}
+		return node, nil
+	case ")", "and", "or":
+		return nil, errors.Errorf("unexpected %q", token)
+	default:
+		kind, value := "tag", token
+		if i := strings.Index(token, ":"); i >= 0 {
+			kind, value = token[:i], token[i+1:]
+			if !selectorKinds[kind] {
+				return nil, errors.Errorf("unknown predicate %q", kind)
+			}
+		}
+		if value == "" {
+			return nil, errors.Errorf("%q has no value", token)
+		}
+		return selectorTerm{kind: kind, value: value}, nil
+	}
+}
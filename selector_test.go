@@ -0,0 +1,135 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type selectorSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&selectorSuite{})
+
+// selectorMachinesJSON returns three machines:
+//
+//	alpha: tags gpu, nvme; zone default; pool prod; amd64/generic
+//	beta:  tag gpu; zone lab; no pool; arm64/generic
+//	gamma: no tags; zone default; pool dev; amd64/hwe-16.04
+func selectorMachinesJSON(c *gc.C) string {
+	zone := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":         name,
+			"description":  "",
+			"resource_uri": "/MAAS/api/2.0/zones/" + name + "/",
+		}
+	}
+	alpha := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id":    "alpha",
+		"tag_names":    []string{"gpu", "nvme"},
+		"zone":         zone("default"),
+		"pool":         map[string]interface{}{"id": 1, "name": "prod"},
+		"architecture": "amd64/generic",
+	})
+	beta := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id":    "beta",
+		"tag_names":    []string{"gpu"},
+		"zone":         zone("lab"),
+		"architecture": "arm64/generic",
+	})
+	gamma := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id":    "gamma",
+		"tag_names":    []string{},
+		"zone":         zone("default"),
+		"pool":         map[string]interface{}{"id": 2, "name": "dev"},
+		"architecture": "amd64/hwe-16.04",
+	})
+	return "[" + alpha + "," + beta + "," + gamma + "]"
+}
+
+func selectedIDs(machines []Machine) []string {
+	var ids []string
+	for _, machine := range machines {
+		ids = append(ids, machine.SystemID())
+	}
+	return ids
+}
+
+func (*selectorSuite) TestFilter(c *gc.C) {
+	read, err := readMachines(twoDotOh, parseJSON(c, selectorMachinesJSON(c)))
+	c.Assert(err, jc.ErrorIsNil)
+	var machines []Machine
+	for _, m := range read {
+		machines = append(machines, m)
+	}
+	for i, test := range []struct {
+		expression string
+		expected   []string
+	}{
+		{"gpu", []string{"alpha", "beta"}},
+		{"tag:nvme", []string{"alpha"}},
+		{"zone:default", []string{"alpha", "gamma"}},
+		{"pool:prod", []string{"alpha"}},
+		{"pool:dev or pool:prod", []string{"alpha", "gamma"}},
+		{"arch:amd64", []string{"alpha", "gamma"}},
+		{"arch:amd64/generic", []string{"alpha"}},
+		{"arch:amd", nil},
+		{"not gpu", []string{"gamma"}},
+		{"not not gpu", []string{"alpha", "beta"}},
+		{"gpu and not nvme", []string{"beta"}},
+		{"gpu and zone:default or pool:dev", []string{"alpha", "gamma"}},
+		{"gpu and (zone:default or pool:dev)", []string{"alpha"}},
+		{"(gpu)and(zone:lab)", []string{"beta"}},
+		{"missing", nil},
+	} {
+		c.Logf("test %d: %s", i, test.expression)
+		selector, err := ParseSelector(test.expression)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(selector.String(), gc.Equals, test.expression)
+		c.Check(selectedIDs(selector.Filter(machines)), jc.DeepEquals, test.expected)
+	}
+}
+
+func (*selectorSuite) TestParseErrors(c *gc.C) {
+	for i, test := range []struct {
+		expression string
+		message    string
+	}{
+		{"", `selector "": unexpected end of expression`},
+		{"gpu and", `selector "gpu and": unexpected end of expression`},
+		{"or gpu", `selector "or gpu": unexpected "or"`},
+		{"(gpu", `selector "\(gpu": missing "\)"`},
+		{"gpu)", `selector "gpu\)": unexpected "\)"`},
+		{"gpu nvme", `selector "gpu nvme": unexpected "nvme"`},
+		{"rack:one", `selector "rack:one": unknown predicate "rack"`},
+		{"zone:", `selector "zone:": "zone:" has no value`},
+	} {
+		c.Logf("test %d: %s", i, test.expression)
+		_, err := ParseSelector(test.expression)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *selectorSuite) TestSelectMachines(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?hostname=untasted-markita", http.StatusOK, selectorMachinesJSON(c))
+	machines, err := controller.SelectMachines("gpu and zone:default", MachinesArgs{Hostnames: []string{"untasted-markita"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(selectedIDs(machines), jc.DeepEquals, []string{"alpha"})
+}
+
+func (s *selectorSuite) TestSelectMachinesBadSelector(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	_, err := controller.SelectMachines("gpu and", MachinesArgs{})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	// The machines are not read.
+	c.Check(server.LastRequest().URL.Path, gc.Not(gc.Equals), "/api/2.0/machines/")
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+)
+
+// BodySerializer encodes the body of a PUT or POST request, returning the
+// content and its content type.
+type BodySerializer interface {
+	Serialize(body interface{}) (content []byte, contentType string, err error)
+}
+
+var (
+	// FormSerializer encodes url.Values as a urlencoded form, as most of
+	// the API expects. *OrderedParams are encoded in order.
+	FormSerializer BodySerializer = formSerializer{}
+
+	// JSONSerializer encodes the body as JSON, for the endpoints that
+	// accept it. url.Values are sent as an object whose fields are strings,
+	// or lists of strings for parameters with more than one value, and
+	// *OrderedParams likewise. Other bodies are marshalled as they are.
+	JSONSerializer BodySerializer = jsonSerializer{}
+)
+
+type formSerializer struct{}
+
+// Serialize implements BodySerializer.
+func (formSerializer) Serialize(body interface{}) ([]byte, string, error) {
+	content, err := appendForm(nil, body)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, formContentType, nil
+}
+
+const formContentType = "application/x-www-form-urlencoded"
+
+// appendForm appends the form encoding of the body to dst.
+func appendForm(dst []byte, body interface{}) ([]byte, error) {
+	switch body := body.(type) {
+	case nil:
+		return dst, nil
+	case url.Values:
+		return appendCanonicalQuery(dst, body), nil
+	case *OrderedParams:
+		return body.appendEncoded(dst), nil
+	case map[string]string:
+		values := make(url.Values, len(body))
+		for key, value := range body {
+			values.Set(key, value)
+		}
+		return appendCanonicalQuery(dst, values), nil
+	}
+	return nil, errors.NotValidf("form body of type %T", body)
+}
+
+type jsonSerializer struct{}
+
+// Serialize implements BodySerializer.
+func (jsonSerializer) Serialize(body interface{}) ([]byte, string, error) {
+	if params, ok := body.(*OrderedParams); ok {
+		body = params.Values()
+	}
+	if values, ok := body.(url.Values); ok {
+		object := make(map[string]interface{}, len(values))
+		for key, list := range values {
+			if len(list) == 1 {
+				object[key] = list[0]
+			} else {
+				object[key] = list
+			}
+		}
+		body = object
+	}
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", errors.Annotate(err, "encoding JSON body")
+	}
+	return content, "application/json", nil
+}
+
+// Send performs a request with the body encoded by the serializer, which
+// defaults to FormSerializer. The operation, if any, should already be in
+// the query of the uri.
+func (client Client) Send(method string, uri *url.URL, body interface{}, serializer BodySerializer) ([]byte, error) {
+	if serializer == nil {
+		serializer = FormSerializer
+	}
+	if serializer == FormSerializer {
+		return client.sendForm(method, uri, body)
+	}
+	content, contentType, err := serializer.Serialize(body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	url := client.GetURL(uri)
+	request, err := http.NewRequest(method, url.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	return client.dispatchRequest(request)
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type serializerSuite struct{}
+
+var _ = gc.Suite(&serializerSuite{})
+
+func (*serializerSuite) TestFormSerializer(c *gc.C) {
+	content, contentType, err := FormSerializer.Serialize(url.Values{"b": {"2", "3"}, "a": {"1"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(contentType, gc.Equals, "application/x-www-form-urlencoded")
+	c.Check(string(content), gc.Equals, "a=1&b=2&b=3")
+
+	content, _, err = FormSerializer.Serialize(map[string]string{"name": "eth0"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "name=eth0")
+
+	content, _, err = FormSerializer.Serialize(nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "")
+}
+
+func (*serializerSuite) TestFormSerializerBadBody(c *gc.C) {
+	_, _, err := FormSerializer.Serialize([]int{1})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `form body of type \[\]int not valid`)
+}
+
+func (*serializerSuite) TestJSONSerializer(c *gc.C) {
+	content, contentType, err := JSONSerializer.Serialize(url.Values{"b": {"2", "3"}, "a": {"1"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(contentType, gc.Equals, "application/json")
+	c.Check(string(content), gc.Equals, `{"a":"1","b":["2","3"]}`)
+
+	content, _, err = JSONSerializer.Serialize(map[string]interface{}{"cores": 4, "pinned": true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, `{"cores":4,"pinned":true}`)
+}
+
+func (*serializerSuite) TestJSONSerializerBadBody(c *gc.C) {
+	_, _, err := JSONSerializer.Serialize(make(chan int))
+	c.Check(err, gc.ErrorMatches, "encoding JSON body: .*")
+}
+
+func (*serializerSuite) TestClientSendJSON(c *gc.C) {
+	server := newSingleServingServer("/api/2.0/pods/1/?op=compose", `{"ok": true}`, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	uri := &url.URL{Path: "pods/1/", RawQuery: "op=compose"}
+
+	result, err := client.Send("POST", uri, map[string]int{"cores": 4}, JSONSerializer)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, `{"ok": true}`)
+	c.Check(*server.requestContent, gc.Equals, `{"cores":4}`)
+	c.Check(server.requestHeader.Get("Content-Type"), gc.Equals, "application/json")
+}
+
+func (*serializerSuite) TestClientSendDefaultsToForm(c *gc.C) {
+	server := newSingleServingServer("/api/2.0/zones/", `{}`, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Send("PUT", &url.URL{Path: "zones/"}, url.Values{"name": {"special"}}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(*server.requestContent, gc.Equals, "name=special")
+	c.Check(server.requestHeader.Get("Content-Type"), gc.Equals, "application/x-www-form-urlencoded")
+}
+
+func (*serializerSuite) TestControllerSend(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddPostResponse("/api/2.0/pods/1/?op=compose", http.StatusOK, `{"system_id": "4y3h7n"}`)
+	server.Start()
+	defer server.Close()
+	maas, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := maas.(*controller).send("POST", "pods/1", "compose", map[string]int{"cores": 4}, JSONSerializer)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result, jc.DeepEquals, map[string]interface{}{"system_id": "4y3h7n"})
+	c.Check(server.LastRequest().Header.Get("Content-Type"), gc.Equals, "application/json")
+}
+
+func (*serializerSuite) TestSerializersOrderedParams(c *gc.C) {
+	params := NewOrderedParams()
+	params.Add("b", "2")
+	params.Add("a", "1")
+	params.Add("b", "3")
+
+	content, _, err := FormSerializer.Serialize(params)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, "b=2&a=1&b=3")
+
+	content, _, err = JSONSerializer.Serialize(params)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(content), gc.Equals, `{"a":"1","b":["2","3"]}`)
+}
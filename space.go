@@ -10,8 +10,9 @@ import (
 )
 
 type space struct {
-	// Add the controller in when we need to do things with the space.
-	// controller Controller
+	rawJSON
+
+	controller *controller
 
 	resourceURI string
 
@@ -35,7 +36,7 @@ func (s *space) Name() string {
 func (s *space) Subnets() []Subnet {
 	var result []Subnet
 	for _, subnet := range s.subnets {
-		result = append(result, subnet)
+		result = append(result, subnet.withController(s.controller))
 	}
 	return result
 }
@@ -86,9 +87,9 @@ var spaceDeserializationFuncs = map[version.Number]spaceDeserializationFunc{
 
 func space_2_0(source map[string]interface{}) (*space, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"name":         schema.String(),
+		"resource_uri": stringField(),
+		"id":           intField(),
+		"name":         stringField(),
 		"subnets":      schema.List(schema.StringMap(schema.Any())),
 	}
 	checker := schema.FieldMap(fields, nil) // no defaults
@@ -106,6 +107,7 @@ func space_2_0(source map[string]interface{}) (*space, error) {
 	}
 
 	result := &space{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 		id:          valid["id"].(int),
 		name:        valid["name"].(string),
@@ -0,0 +1,166 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// AllocateSpreadArgs is an argument struct for passing the number of
+// machines to allocate, their constraints and the zones or pools to spread
+// them across into AllocateSpread.
+type AllocateSpreadArgs struct {
+	// Count is the number of machines to allocate.
+	Count int
+
+	// Constraints is used for every allocation. Its Zone, or Pool when
+	// spreading across pools, must be empty as AllocateSpread sets it.
+	// IdempotencyKey and DryRun are not supported.
+	Constraints AllocateMachineArgs
+
+	// Zones are the names of the zones to spread the machines across. If
+	// both Zones and Pools are empty, all the zones of the controller
+	// other than those in Constraints.NotInZone are used.
+	Zones []string
+
+	// Pools are the names of the resource pools to spread the machines
+	// across. Only one of Zones and Pools may be set.
+	Pools []string
+}
+
+// Validate makes sure that the count is positive and that the constraints
+// do not conflict with spreading.
+func (a *AllocateSpreadArgs) Validate() error {
+	if a.Count <= 0 {
+		return NewArgumentError("Count", "%d is not positive", a.Count)
+	}
+	if len(a.Zones) > 0 && len(a.Pools) > 0 {
+		return NewArgumentError("Pools", "cannot spread across both zones and pools")
+	}
+	if len(a.Pools) > 0 {
+		if a.Constraints.Pool != "" {
+			return NewArgumentError("Constraints.Pool", "cannot be set when spreading across pools")
+		}
+	} else if a.Constraints.Zone != "" {
+		return NewArgumentError("Constraints.Zone", "cannot be set when spreading across zones")
+	}
+	if a.Constraints.IdempotencyKey != "" {
+		return NewArgumentError("Constraints.IdempotencyKey", "not supported when spreading")
+	}
+	if a.Constraints.DryRun {
+		return NewArgumentError("Constraints.DryRun", "not supported when spreading")
+	}
+	if a.Count > 1 && a.Constraints.Hostname != "" {
+		return NewArgumentError("Constraints.Hostname", "cannot allocate %d machines with the same hostname", a.Count)
+	}
+	return errors.Trace(a.Constraints.Validate())
+}
+
+// AllocateSpread implements Controller.
+//
+// Each machine is allocated in the zone or pool with the fewest machines
+// allocated so far, in the order given. A zone or pool with no matching
+// machines is skipped for the rest of the allocations, so the spread is
+// best effort. If Count machines cannot be allocated, or any allocation
+// fails for another reason, the machines already allocated are released
+// and the error is returned. Returns an error that satisfies
+// IsNoMatchError if there are not enough matching machines.
+func (c *controller) AllocateSpread(args AllocateSpreadArgs) ([]Machine, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	kind, names, err := c.spreadDomains(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(names) == 0 {
+		return nil, NewNoMatchError(fmt.Sprintf("no %ss to spread machines across", kind))
+	}
+
+	counts := make([]int, len(names))
+	exhausted := make([]bool, len(names))
+	var allocated []Machine
+	for len(allocated) < args.Count {
+		next := -1
+		for i := range names {
+			if !exhausted[i] && (next < 0 || counts[i] < counts[next]) {
+				next = i
+			}
+		}
+		if next < 0 {
+			err := NewNoMatchError(fmt.Sprintf(
+				"allocated %d of %d machines across %ss %s",
+				len(allocated), args.Count, kind, strings.Join(names, ", ")))
+			return nil, c.releaseSpread(allocated, args.Constraints.Comment, err)
+		}
+		constraints := args.Constraints
+		if kind == "pool" {
+			constraints.Pool = names[next]
+		} else {
+			constraints.Zone = names[next]
+		}
+		machine, _, err := c.AllocateMachine(constraints)
+		if IsNoMatchError(err) {
+			logger.Debugf("no machines to allocate in %s %q", kind, names[next])
+			exhausted[next] = true
+			continue
+		}
+		if err != nil {
+			return nil, c.releaseSpread(allocated, args.Constraints.Comment, errors.Annotatef(err, "allocating in %s %q", kind, names[next]))
+		}
+		allocated = append(allocated, machine)
+		counts[next]++
+	}
+	return allocated, nil
+}
+
+// spreadDomains returns whether the machines are spread across zones or
+// pools, and the names to use.
+func (c *controller) spreadDomains(args AllocateSpreadArgs) (string, []string, error) {
+	if len(args.Pools) > 0 {
+		return "pool", args.Pools, nil
+	}
+	if len(args.Zones) > 0 {
+		return "zone", args.Zones, nil
+	}
+	zones, err := c.Zones()
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+	excluded := set.NewStrings(args.Constraints.NotInZone...)
+	var names []string
+	for _, zone := range zones {
+		if !excluded.Contains(zone.Name()) {
+			names = append(names, zone.Name())
+		}
+	}
+	return "zone", names, nil
+}
+
+// releaseSpread releases the machines allocated before the cause, and
+// returns the cause. The release is recorded with the comment of the
+// allocation. If the machines cannot be released, the returned error says
+// so as well.
+func (c *controller) releaseSpread(allocated []Machine, comment string, cause error) error {
+	if len(allocated) == 0 {
+		return cause
+	}
+	systemIDs := make([]string, len(allocated))
+	for i, m := range allocated {
+		systemIDs[i] = m.SystemID()
+	}
+	err := c.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs: systemIDs,
+		Comment:   actionComment("releasing partial spread allocation", comment),
+	})
+	if err != nil {
+		logger.Errorf("cannot release machines %s: %v", strings.Join(systemIDs, ", "), err)
+		return errors.Annotatef(cause, "machines %s not released (%v)", strings.Join(systemIDs, ", "), err)
+	}
+	return cause
+}
@@ -0,0 +1,236 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type spreadSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&spreadSuite{})
+
+func (s *spreadSuite) getServerAndController(c *gc.C) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+	return server, controller
+}
+
+func addAllocateResponse(c *gc.C, server *SimpleTestServer, systemID string) {
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK,
+		updateJSONMap(c, machineResponse, map[string]interface{}{
+			"system_id":           systemID,
+			"constraints_by_type": map[string]interface{}{},
+		}))
+}
+
+// allocateForms returns the forms of the allocate requests made.
+func allocateForms(server *SimpleTestServer) []map[string]string {
+	var forms []map[string]string
+	for _, request := range server.LastNRequests(server.RequestCount()) {
+		if request.URL.Query().Get("op") != "allocate" {
+			continue
+		}
+		forms = append(forms, map[string]string{
+			"zone": request.PostForm.Get("zone"),
+			"pool": request.PostForm.Get("pool"),
+		})
+	}
+	return forms
+}
+
+func systemIDs(machines []Machine) []string {
+	var ids []string
+	for _, m := range machines {
+		ids = append(ids, m.SystemID())
+	}
+	return ids
+}
+
+func (s *spreadSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		args  AllocateSpreadArgs
+		field string
+	}{{
+		args:  AllocateSpreadArgs{},
+		field: "Count",
+	}, {
+		args:  AllocateSpreadArgs{Count: 1, Zones: []string{"a"}, Pools: []string{"b"}},
+		field: "Pools",
+	}, {
+		args:  AllocateSpreadArgs{Count: 1, Constraints: AllocateMachineArgs{Zone: "a"}},
+		field: "Constraints.Zone",
+	}, {
+		args:  AllocateSpreadArgs{Count: 1, Pools: []string{"a"}, Constraints: AllocateMachineArgs{Pool: "b"}},
+		field: "Constraints.Pool",
+	}, {
+		args:  AllocateSpreadArgs{Count: 1, Constraints: AllocateMachineArgs{IdempotencyKey: "k"}},
+		field: "Constraints.IdempotencyKey",
+	}, {
+		args:  AllocateSpreadArgs{Count: 1, Constraints: AllocateMachineArgs{DryRun: true}},
+		field: "Constraints.DryRun",
+	}, {
+		args:  AllocateSpreadArgs{Count: 2, Constraints: AllocateMachineArgs{Hostname: "node"}},
+		field: "Constraints.Hostname",
+	}, {
+		args:  AllocateSpreadArgs{Count: 1, Constraints: AllocateMachineArgs{MinCPUCount: -1}},
+		field: "MinCPUCount",
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(errors.Cause(err).(*ArgumentError).Field, gc.Equals, test.field)
+	}
+	args := AllocateSpreadArgs{Count: 1, Pools: []string{"a"}, Constraints: AllocateMachineArgs{Zone: "z"}}
+	c.Check(args.Validate(), jc.ErrorIsNil)
+}
+
+func (s *spreadSuite) TestAllocateSpreadAcrossAllZones(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	addAllocateResponse(c, server, "m1")
+	addAllocateResponse(c, server, "m2")
+	addAllocateResponse(c, server, "m3")
+
+	machines, err := controller.AllocateSpread(AllocateSpreadArgs{Count: 3})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(systemIDs(machines), jc.DeepEquals, []string{"m1", "m2", "m3"})
+	c.Check(allocateForms(server), jc.DeepEquals, []map[string]string{
+		{"zone": "default", "pool": ""},
+		{"zone": "special", "pool": ""},
+		{"zone": "default", "pool": ""},
+	})
+}
+
+func (s *spreadSuite) TestAllocateSpreadExcludesNotInZone(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	addAllocateResponse(c, server, "m1")
+	addAllocateResponse(c, server, "m2")
+
+	_, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count:       2,
+		Constraints: AllocateMachineArgs{NotInZone: []string{"default"}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	forms := allocateForms(server)
+	c.Check(forms, gc.HasLen, 2)
+	for _, form := range forms {
+		c.Check(form["zone"], gc.Equals, "special")
+	}
+}
+
+func (s *spreadSuite) TestAllocateSpreadAcrossPools(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	addAllocateResponse(c, server, "m1")
+	addAllocateResponse(c, server, "m2")
+
+	machines, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count:       2,
+		Pools:       []string{"gold", "silver"},
+		Constraints: AllocateMachineArgs{Zone: "default"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 2)
+	c.Check(allocateForms(server), jc.DeepEquals, []map[string]string{
+		{"zone": "default", "pool": "gold"},
+		{"zone": "default", "pool": "silver"},
+	})
+}
+
+func (s *spreadSuite) TestAllocateSpreadSkipsExhaustedZone(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	addAllocateResponse(c, server, "m1")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "no machines in b")
+	addAllocateResponse(c, server, "m2")
+	addAllocateResponse(c, server, "m3")
+
+	machines, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count: 3,
+		Zones: []string{"a", "b", "c"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(systemIDs(machines), jc.DeepEquals, []string{"m1", "m2", "m3"})
+	c.Check(allocateForms(server), jc.DeepEquals, []map[string]string{
+		{"zone": "a", "pool": ""},
+		{"zone": "b", "pool": ""},
+		{"zone": "c", "pool": ""},
+		{"zone": "a", "pool": ""},
+	})
+}
+
+func (s *spreadSuite) TestAllocateSpreadNotEnoughReleases(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	addAllocateResponse(c, server, "m1")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "none in b")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "none left in a")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+
+	_, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count: 2,
+		Zones: []string{"a", "b"},
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err.Error(), gc.Equals, "allocated 1 of 2 machines across zones a, b")
+	release := server.LastRequest()
+	c.Check(release.URL.Query().Get("op"), gc.Equals, "release")
+	c.Check(release.PostForm["machines"], jc.DeepEquals, []string{"m1"})
+}
+
+func (s *spreadSuite) TestAllocateSpreadErrorReleases(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	addAllocateResponse(c, server, "m1")
+	addAllocateResponse(c, server, "m2")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusInternalServerError, "boom")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+
+	_, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count:       3,
+		Constraints: AllocateMachineArgs{Comment: "ticket 42"},
+		Zones:       []string{"a", "b", "c"},
+	})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Check(err, gc.ErrorMatches, `allocating in zone "c": .*`)
+	release := server.LastRequest()
+	c.Check(release.PostForm["machines"], jc.DeepEquals, []string{"m1", "m2"})
+	c.Check(release.PostForm.Get("comment"), gc.Equals, "releasing partial spread allocation: ticket 42")
+}
+
+func (s *spreadSuite) TestAllocateSpreadReleaseFails(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	addAllocateResponse(c, server, "m1")
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "none")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusForbidden, "not yours")
+
+	_, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count: 2,
+		Zones: []string{"a"},
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err.Error(), gc.Equals, "machines m1 not released (not yours): allocated 1 of 2 machines across zones a")
+}
+
+func (s *spreadSuite) TestAllocateSpreadNoZones(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, "[]")
+	_, err := controller.AllocateSpread(AllocateSpreadArgs{Count: 1})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err.Error(), gc.Equals, "no zones to spread machines across")
+}
@@ -0,0 +1,349 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// sqliteDB is a minimal read-only reader for SQLite 3 database files. It
+// only supports what is needed to read the profiles stored by the maas CLI:
+// walking table b-trees and decoding their records. Indexes, WAL files and
+// write support are out of scope.
+type sqliteDB struct {
+	data       []byte
+	pageSize   int
+	usableSize int
+}
+
+const sqliteMagic = "SQLite format 3\x00"
+
+// isSQLite reports whether the data starts with the SQLite file header.
+func isSQLite(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sqliteMagic))
+}
+
+func newSQLiteDB(data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || !isSQLite(data) {
+		return nil, errors.NotValidf("SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, errors.NotValidf("SQLite page size %d", pageSize)
+	}
+	return &sqliteDB{
+		data:       data,
+		pageSize:   pageSize,
+		usableSize: pageSize - int(data[20]),
+	}, nil
+}
+
+// page returns the content of the numbered page, counting from one.
+func (db *sqliteDB) page(number uint32) ([]byte, error) {
+	start := (int(number) - 1) * db.pageSize
+	if number == 0 || start+db.pageSize > len(db.data) {
+		return nil, errors.NotValidf("SQLite page %d", number)
+	}
+	return db.data[start : start+db.pageSize], nil
+}
+
+// readTable returns the rows of the named table, with the values in each
+// row keyed by column name.
+func (db *sqliteDB) readTable(name string) ([]map[string]interface{}, error) {
+	// The schema table is always rooted at page one, and has the columns
+	// type, name, tbl_name, rootpage and sql.
+	var rootPage uint32
+	var columns []string
+	err := db.walkTable(1, func(_ int64, record []interface{}) error {
+		if len(record) < 5 || record[0] != "table" || record[1] != name {
+			return nil
+		}
+		page, ok := record[3].(int64)
+		if !ok {
+			return errors.NotValidf("root page for table %q", name)
+		}
+		sql, _ := record[4].(string)
+		rootPage = uint32(page)
+		columns = sqliteColumns(sql)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if rootPage == 0 {
+		return nil, errors.NotFoundf("table %q", name)
+	}
+	var rows []map[string]interface{}
+	err = db.walkTable(rootPage, func(rowid int64, record []interface{}) error {
+		row := make(map[string]interface{})
+		for i, column := range columns {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		// An INTEGER PRIMARY KEY column is an alias for the rowid and is
+		// stored as null in the record.
+		if len(columns) > 0 && row[columns[0]] == nil {
+			row[columns[0]] = rowid
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return rows, nil
+}
+
+// sqliteColumns extracts the column names from a CREATE TABLE statement.
+// Table constraints, such as UNIQUE (a, b), are skipped.
+func sqliteColumns(sql string) []string {
+	open := strings.Index(sql, "(")
+	close := strings.LastIndex(sql, ")")
+	if open < 0 || close < open {
+		return nil
+	}
+	var columns []string
+	depth, start := 0, open+1
+	for i := open + 1; i <= close; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')', ',':
+			if sql[i] == ')' && depth > 0 {
+				depth--
+				continue
+			}
+			if depth > 0 {
+				continue
+			}
+			fields := strings.Fields(sql[start:i])
+			start = i + 1
+			if len(fields) == 0 {
+				continue
+			}
+			switch strings.ToUpper(fields[0]) {
+			case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+				continue
+			}
+			columns = append(columns, strings.Trim(fields[0], "\"`[]"))
+		}
+	}
+	return columns
+}
+
+// walkTable calls the callback with the rowid and decoded record of each
+// row in the table b-tree rooted at the given page, in rowid order.
+func (db *sqliteDB) walkTable(root uint32, callback func(int64, []interface{}) error) error {
+	visited := make(map[uint32]bool)
+	var walk func(number uint32) error
+	walk = func(number uint32) error {
+		if visited[number] {
+			return errors.NotValidf("SQLite b-tree with cycle at page %d", number)
+		}
+		visited[number] = true
+		page, err := db.page(number)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		header := 0
+		if number == 1 {
+			header = 100
+		}
+		if header+8 > len(page) {
+			return errors.NotValidf("SQLite page %d", number)
+		}
+		kind := page[header]
+		cellCount := int(binary.BigEndian.Uint16(page[header+3 : header+5]))
+		pointers := header + 8
+		if kind == 0x05 {
+			pointers = header + 12
+		}
+		if pointers+2*cellCount > len(page) {
+			return errors.NotValidf("SQLite page %d", number)
+		}
+		for i := 0; i < cellCount; i++ {
+			offset := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+			if offset >= len(page) {
+				return errors.NotValidf("SQLite cell offset %d on page %d", offset, number)
+			}
+			cell := page[offset:]
+			switch kind {
+			case 0x05:
+				if len(cell) < 4 {
+					return errors.NotValidf("SQLite cell on page %d", number)
+				}
+				if err := walk(binary.BigEndian.Uint32(cell)); err != nil {
+					return err
+				}
+			case 0x0d:
+				rowid, payload, err := db.leafCell(cell)
+				if err != nil {
+					return errors.Annotatef(err, "page %d cell %d", number, i)
+				}
+				record, err := sqliteRecord(payload)
+				if err != nil {
+					return errors.Annotatef(err, "page %d cell %d", number, i)
+				}
+				if err := callback(rowid, record); err != nil {
+					return err
+				}
+			default:
+				return errors.NotValidf("SQLite table page type %#x", kind)
+			}
+		}
+		if kind == 0x05 {
+			return walk(binary.BigEndian.Uint32(page[header+8:]))
+		}
+		return nil
+	}
+	return walk(root)
+}
+
+// leafCell decodes a table leaf cell, following overflow pages for payloads
+// that do not fit on the page.
+func (db *sqliteDB) leafCell(cell []byte) (int64, []byte, error) {
+	size, n := sqliteVarint(cell)
+	if n == 0 {
+		return 0, nil, errors.NotValidf("SQLite payload size")
+	}
+	rowid, m := sqliteVarint(cell[n:])
+	if m == 0 {
+		return 0, nil, errors.NotValidf("SQLite rowid")
+	}
+	cell = cell[n+m:]
+	payloadSize := int(size)
+	usable := db.usableSize
+	maxLocal := usable - 35
+	local := payloadSize
+	if payloadSize > maxLocal {
+		minLocal := (usable-12)*32/255 - 23
+		local = minLocal + (payloadSize-minLocal)%(usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if payloadSize < 0 || payloadSize > len(db.data) || len(cell) < local {
+		return 0, nil, errors.NotValidf("SQLite cell payload")
+	}
+	payload := make([]byte, 0, payloadSize)
+	payload = append(payload, cell[:local]...)
+	if local == payloadSize {
+		return int64(rowid), payload, nil
+	}
+	if len(cell) < local+4 {
+		return 0, nil, errors.NotValidf("SQLite overflow pointer")
+	}
+	next := binary.BigEndian.Uint32(cell[local:])
+	for visited := 0; len(payload) < payloadSize; visited++ {
+		if next == 0 || visited > len(db.data)/db.pageSize {
+			return 0, nil, errors.NotValidf("SQLite overflow chain")
+		}
+		page, err := db.page(next)
+		if err != nil {
+			return 0, nil, errors.Trace(err)
+		}
+		next = binary.BigEndian.Uint32(page)
+		chunk := page[4:usable]
+		if remaining := payloadSize - len(payload); len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		payload = append(payload, chunk...)
+	}
+	return int64(rowid), payload, nil
+}
+
+// sqliteRecord decodes a record into nil, int64, float64, string or []byte
+// values.
+func sqliteRecord(payload []byte) ([]interface{}, error) {
+	headerSize, n := sqliteVarint(payload)
+	if n == 0 || headerSize < uint64(n) || headerSize > uint64(len(payload)) {
+		return nil, errors.NotValidf("SQLite record header")
+	}
+	header := payload[n:headerSize]
+	body := payload[headerSize:]
+	var values []interface{}
+	for len(header) > 0 {
+		serialType, n := sqliteVarint(header)
+		if n == 0 {
+			return nil, errors.NotValidf("SQLite serial type")
+		}
+		header = header[n:]
+		var size int
+		switch {
+		case serialType <= 4:
+			size = int(serialType)
+		case serialType == 5:
+			size = 6
+		case serialType == 6, serialType == 7:
+			size = 8
+		case serialType == 8, serialType == 9:
+			size = 0
+		case serialType >= 12:
+			if (serialType-12)/2 > uint64(len(body)) {
+				return nil, errors.NotValidf("SQLite record body")
+			}
+			size = int(serialType-12) / 2
+		default:
+			return nil, errors.NotValidf("SQLite serial type %d", serialType)
+		}
+		if size > len(body) {
+			return nil, errors.NotValidf("SQLite record body")
+		}
+		field := body[:size]
+		body = body[size:]
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType <= 6:
+			// Big-endian two's complement integers of varying width.
+			var value int64
+			if field[0]&0x80 != 0 {
+				value = -1
+			}
+			for _, b := range field {
+				value = value<<8 | int64(b)
+			}
+			values = append(values, value)
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(field)))
+		case serialType == 8:
+			values = append(values, int64(0))
+		case serialType == 9:
+			values = append(values, int64(1))
+		case serialType%2 == 0:
+			values = append(values, append([]byte(nil), field...))
+		default:
+			values = append(values, string(field))
+		}
+	}
+	return values, nil
+}
+
+// sqliteVarint decodes a SQLite variable length integer, returning the
+// value and the number of bytes read, or zero bytes if data is too short.
+func sqliteVarint(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(data) {
+			return 0, 0
+		}
+		if i == 8 {
+			return value<<8 | uint64(data[i]), 9
+		}
+		value = value<<7 | uint64(data[i]&0x7f)
+		if data[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return value, 9
+}
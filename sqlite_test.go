@@ -0,0 +1,144 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type sqliteSuite struct{}
+
+var _ = gc.Suite(&sqliteSuite{})
+
+// cliDatabase is a gzipped maas CLI database with 512 byte pages, so that
+// the profiles table has interior pages and the "big" profile overflows.
+// It holds the profiles admin, anon, big and user0 to user11.
+const cliDatabase = `
+H4sIAAvc0WoC/+2YPWzbRhTH75HWp+14CAIhg4EDpxYwJJL69lQ5EAKjitM4MtrACAyaYloi
+FCXrTo0KQ4M8Z8iUuV07d+7azp07dyqKdkkLdOnd2aQim2zC6lIEbc7Qu3cE+efv3Xt6Pvv+
+vY5LHfxoMOpbFJeRggDQBxgjxFyE1tB8APusXFq/aiioaMM6/IEU6CLownfofzBOIFPY3IRZ
+lVrHnjMcDR65nkOCWbm1325127jb2um0cXAVv+f28O5et327vY8/2t+909p/gD9sP9jCvtV3
+cLf9SRfv3WWfg04HH+zt3jtob+GeRS2807m7835JSRdubQJy/Z4zIScey+mRNaYDsT4KXnJk
+BJ7KMLOcNcWTCj9zdw3+hBfw2/ni3fhPjlX+hc7nmMmleQmoeWY3wEPwO/wCP8GP8AM8AQ++
+h2/hG/gavoTn8BTO3m2cjJFXb8KYOCPD2Ahd/VpOLQi3uR54jbXAq68GXi0feNVc4FWygVfO
+BJ6ZDjwjFXj6Ska9Dsfup2pWvQGWP/CVrFrIWb2+6/MWcHiq8SajbWNtpGtbWOsNbL6YvObQ
+plv4JQljeQlzeYny8hKV5SWqy0vUlpeox0uw/KdfV6axPElTQnnJKFEJNWpIKFJDQpUaS5Up
+y3/mQkdCrRoSitWoS9CQUKqGhFo1JdSqKaOfxtYq/72UQEdCvZoS2qopoVZNCbVqSqhVU0Kt
+mv+sVh9Op+ss/yqaIPgc0mjy752Fnj1RU+j6V4/ZqWQeB1vwOMYjj68+o3S4XSqxi0VnYvWH
+nlO0B/3SnVbrfskauiWzqJf47fbI6Tk+dS2PsMcOtWObXz2mwhLtId8Zh9gjd0jdgc9uOdVG
+DhmMR7bDH+B/APlKCt2YfcyPRnMavrqKY+hF/mNuV82K/ioYf+x5V18/nc4ykEKFs4w4gr30
+Rr5c8pWHmv3Y+YJfp8FMHHYLjdqIIP8nCE7RCza9+TFT0iz02VQcVuehi+XV0PuWRcxk6beF
+DBWWmNFRz5RUCGEsQhjREEZCCCFDhSVGHMRKCKEvQujREHpCCCFDhSX6W5P/XBh1dTHqanTU
+1YRRCxkqLKnGbX02hKgsQlSiISoJIYQMFZZU4iAyIUR5EaIcDVFOCCFkqLCk/Nbkfy2MurEY
+dSM66kbCqIUMFZY04rZ+NYSoL0LUoyHqCSGEDBWW1OMg8iFEbRGiFg1RSwghZKiwpPZ3+Xd4
+/n9l0xvKeXYjhW6eZc7/+XGp38Y13MQd96LlXvTcuKabvTZHudR1jZi2ayTtu+dC9Hxij8fl
+fz3Mf3ORpBkN0kzIIWSosKQZDfEX9rCZOQAYAAA=
+`
+
+func cliDatabaseBytes(c *gc.C) []byte {
+	compressed, err := base64.StdEncoding.DecodeString(cliDatabase)
+	c.Assert(err, jc.ErrorIsNil)
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	c.Assert(err, jc.ErrorIsNil)
+	data, err := ioutil.ReadAll(reader)
+	c.Assert(err, jc.ErrorIsNil)
+	return data
+}
+
+// writeCLIDatabase writes the test database to a temporary directory and
+// returns its path.
+func writeCLIDatabase(c *gc.C) string {
+	path := filepath.Join(c.MkDir(), ".maascli.db")
+	err := ioutil.WriteFile(path, cliDatabaseBytes(c), 0600)
+	c.Assert(err, jc.ErrorIsNil)
+	return path
+}
+
+func (*sqliteSuite) TestVarint(c *gc.C) {
+	for i, test := range []struct {
+		data  []byte
+		value uint64
+		size  int
+	}{
+		{[]byte{0x00}, 0, 1},
+		{[]byte{0x7f}, 127, 1},
+		{[]byte{0x81, 0x00}, 128, 2},
+		{[]byte{0x82, 0xa3, 0x04}, 0x9184, 3},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 0xffffffffffffffff, 9},
+		{[]byte{0x81}, 0, 0},
+	} {
+		c.Logf("test %d", i)
+		value, size := sqliteVarint(test.data)
+		c.Check(value, gc.Equals, test.value)
+		c.Check(size, gc.Equals, test.size)
+	}
+}
+
+func (*sqliteSuite) TestRecord(c *gc.C) {
+	// Header of 8 bytes, including its size: null, int8, int16, zero, one, text(3), blob(2).
+	payload := []byte{8, 0, 1, 2, 8, 9, 19, 16, 0xfe, 0x01, 0x00, 'a', 'b', 'c', 0xca, 0xfe}
+	record, err := sqliteRecord(payload)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(record, jc.DeepEquals, []interface{}{
+		nil, int64(-2), int64(256), int64(0), int64(1), "abc", []byte{0xca, 0xfe},
+	})
+}
+
+func (*sqliteSuite) TestRecordTruncated(c *gc.C) {
+	_, err := sqliteRecord([]byte{3, 19, 1, 'a'})
+	c.Assert(err, gc.ErrorMatches, "SQLite record body not valid")
+}
+
+func (*sqliteSuite) TestRecordMalformed(c *gc.C) {
+	for _, payload := range [][]byte{
+		// The header size is smaller than its own varint.
+		{0x00, 0x01},
+		// The header size is a varint too large for an int.
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		// A text serial type too large for an int.
+		{10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'a'},
+	} {
+		_, err := sqliteRecord(payload)
+		c.Check(err, gc.ErrorMatches, "SQLite record (header|body) not valid", gc.Commentf("%x", payload))
+	}
+}
+
+func (*sqliteSuite) TestColumns(c *gc.C) {
+	columns := sqliteColumns("CREATE TABLE profiles (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE, data BLOB, size DECIMAL(10, 2), UNIQUE (name, data))")
+	c.Assert(columns, jc.DeepEquals, []string{"id", "name", "data", "size"})
+}
+
+func (*sqliteSuite) TestNotSQLite(c *gc.C) {
+	_, err := newSQLiteDB([]byte(`{"admin": {}}`))
+	c.Assert(err, gc.ErrorMatches, "SQLite database not valid")
+}
+
+func (*sqliteSuite) TestReadTable(c *gc.C) {
+	db, err := newSQLiteDB(cliDatabaseBytes(c))
+	c.Assert(err, jc.ErrorIsNil)
+	rows, err := db.readTable("profiles")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rows, gc.HasLen, 15)
+	c.Check(rows[0]["id"], gc.Equals, int64(1))
+	c.Check(rows[0]["name"], gc.Equals, "admin")
+	c.Check(rows[14]["name"], gc.Equals, "user11")
+	// The big profile is larger than a page, so it is read from the
+	// overflow pages.
+	c.Check(rows[2]["name"], gc.Equals, "big")
+	c.Check(len(rows[2]["data"].(string)) > 512, jc.IsTrue)
+}
+
+func (*sqliteSuite) TestReadTableMissing(c *gc.C) {
+	db, err := newSQLiteDB(cliDatabaseBytes(c))
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = db.readTable("missing")
+	c.Assert(err, gc.ErrorMatches, `table "missing" not found`)
+}
@@ -10,6 +10,8 @@ import (
 )
 
 type staticRoute struct {
+	rawJSON
+
 	resourceURI string
 
 	id          int
@@ -90,12 +92,12 @@ var staticRouteDeserializationFuncs = map[version.Number]staticRouteDeserializat
 
 func staticRoute_2_0(source map[string]interface{}) (*staticRoute, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
+		"resource_uri": stringField(),
+		"id":           intField(),
 		"source":       schema.StringMap(schema.Any()),
 		"destination":  schema.StringMap(schema.Any()),
-		"gateway_ip":   schema.String(),
-		"metric":       schema.ForceInt(),
+		"gateway_ip":   stringField(),
+		"metric":       intField(),
 	}
 	checker := schema.FieldMap(fields, nil) // no defaults
 	coerced, err := checker.Coerce(source, nil)
@@ -119,6 +121,7 @@ func staticRoute_2_0(source map[string]interface{}) (*staticRoute, error) {
 	}
 
 	result := &staticRoute{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 		id:          valid["id"].(int),
 		gatewayIP:   valid["gateway_ip"].(string),
@@ -0,0 +1,120 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+// StorageLayout is the name of a storage layout that MAAS can apply to the
+// disks of a Ready machine.
+type StorageLayout string
+
+const (
+	StorageLayoutFlat   StorageLayout = "flat"
+	StorageLayoutLVM    StorageLayout = "lvm"
+	StorageLayoutBcache StorageLayout = "bcache"
+	StorageLayoutBlank  StorageLayout = "blank"
+	StorageLayoutCustom StorageLayout = "custom"
+)
+
+// BcacheCacheMode is the caching mode for a bcache layout.
+type BcacheCacheMode string
+
+const (
+	BcacheWriteBack    BcacheCacheMode = "writeback"
+	BcacheWriteThrough BcacheCacheMode = "writethrough"
+	BcacheWriteAround  BcacheCacheMode = "writearound"
+)
+
+// StorageLayoutOptions holds the options for Machine.SetStorageLayout. All
+// values are optional; MAAS picks defaults for any that are not set. Sizes
+// are in bytes. RootDevice and BootSize do not apply to the blank layout.
+type StorageLayoutOptions struct {
+	// BootSize is the size of the boot partition.
+	BootSize uint64
+	// RootDevice is the ID of the block device to put the root partition on.
+	RootDevice int
+	// RootSize is the size of the root partition.
+	RootSize uint64
+
+	// LVM may only be set for the lvm layout.
+	LVM *LVMLayoutOptions
+	// Bcache may only be set for the bcache layout.
+	Bcache *BcacheLayoutOptions
+}
+
+// LVMLayoutOptions are the options specific to the lvm layout.
+type LVMLayoutOptions struct {
+	VolumeGroupName   string
+	LogicalVolumeName string
+	LogicalVolumeSize uint64
+}
+
+// BcacheLayoutOptions are the options specific to the bcache layout.
+type BcacheLayoutOptions struct {
+	// CacheDevice is the ID of the block device to use as the cache.
+	CacheDevice int
+	CacheMode   BcacheCacheMode
+	CacheSize   uint64
+	// CacheNoPart uses the whole cache device rather than a partition.
+	CacheNoPart bool
+}
+
+// Validate ensures that the options are applicable to the layout.
+func (o *StorageLayoutOptions) Validate(layout StorageLayout) error {
+	switch layout {
+	case StorageLayoutFlat, StorageLayoutLVM, StorageLayoutBcache, StorageLayoutCustom:
+	case StorageLayoutBlank:
+		if o.BootSize != 0 || o.RootDevice != 0 || o.RootSize != 0 {
+			return errors.NotValidf("boot or root options with blank layout")
+		}
+	case "":
+		return errors.NotValidf("missing layout")
+	default:
+		return errors.NotValidf("storage layout %q", string(layout))
+	}
+	if o.LVM != nil && layout != StorageLayoutLVM {
+		return errors.NotValidf("LVM options with %s layout", layout)
+	}
+	if o.Bcache != nil {
+		if layout != StorageLayoutBcache {
+			return errors.NotValidf("Bcache options with %s layout", layout)
+		}
+		switch o.Bcache.CacheMode {
+		case "", BcacheWriteBack, BcacheWriteThrough, BcacheWriteAround:
+		default:
+			return errors.NotValidf("bcache cache mode %q", string(o.Bcache.CacheMode))
+		}
+	}
+	return nil
+}
+
+func (o *StorageLayoutOptions) params(layout StorageLayout) *URLParams {
+	params := NewURLParams()
+	params.Values.Add("storage_layout", string(layout))
+	maybeAddSize(params, "boot_size", o.BootSize)
+	params.MaybeAddInt("root_device", o.RootDevice)
+	maybeAddSize(params, "root_size", o.RootSize)
+	if o.LVM != nil {
+		params.MaybeAdd("vg_name", o.LVM.VolumeGroupName)
+		params.MaybeAdd("lv_name", o.LVM.LogicalVolumeName)
+		maybeAddSize(params, "lv_size", o.LVM.LogicalVolumeSize)
+	}
+	if o.Bcache != nil {
+		params.MaybeAddInt("cache_device", o.Bcache.CacheDevice)
+		params.MaybeAdd("cache_mode", string(o.Bcache.CacheMode))
+		maybeAddSize(params, "cache_size", o.Bcache.CacheSize)
+		params.MaybeAddBool("cache_no_part", o.Bcache.CacheNoPart)
+	}
+	return params
+}
+
+func maybeAddSize(params *URLParams, name string, size uint64) {
+	if size != 0 {
+		params.Values.Add(name, strconv.FormatUint(size, 10))
+	}
+}
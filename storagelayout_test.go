@@ -0,0 +1,85 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type storageLayoutSuite struct{}
+
+var _ = gc.Suite(&storageLayoutSuite{})
+
+func (*storageLayoutSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		layout  StorageLayout
+		options StorageLayoutOptions
+		errText string
+	}{{
+		errText: "missing layout not valid",
+	}, {
+		layout:  "zfs",
+		errText: `storage layout "zfs" not valid`,
+	}, {
+		layout: StorageLayoutFlat,
+	}, {
+		layout:  StorageLayoutFlat,
+		options: StorageLayoutOptions{LVM: &LVMLayoutOptions{}},
+		errText: "LVM options with flat layout not valid",
+	}, {
+		layout:  StorageLayoutLVM,
+		options: StorageLayoutOptions{LVM: &LVMLayoutOptions{VolumeGroupName: "vg0"}},
+	}, {
+		layout:  StorageLayoutLVM,
+		options: StorageLayoutOptions{Bcache: &BcacheLayoutOptions{}},
+		errText: "Bcache options with lvm layout not valid",
+	}, {
+		layout:  StorageLayoutBcache,
+		options: StorageLayoutOptions{Bcache: &BcacheLayoutOptions{CacheMode: "sometimes"}},
+		errText: `bcache cache mode "sometimes" not valid`,
+	}, {
+		layout:  StorageLayoutBcache,
+		options: StorageLayoutOptions{Bcache: &BcacheLayoutOptions{CacheMode: BcacheWriteThrough}},
+	}, {
+		layout:  StorageLayoutBlank,
+		options: StorageLayoutOptions{RootSize: 1024},
+		errText: "boot or root options with blank layout not valid",
+	}, {
+		layout: StorageLayoutBlank,
+	}} {
+		c.Logf("test %d", i)
+		err := test.options.Validate(test.layout)
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (*storageLayoutSuite) TestParams(c *gc.C) {
+	options := StorageLayoutOptions{
+		BootSize:   512 * 1024 * 1024,
+		RootDevice: 34,
+		Bcache: &BcacheLayoutOptions{
+			CacheDevice: 35,
+			CacheMode:   BcacheWriteBack,
+			CacheNoPart: true,
+		},
+	}
+	params := options.params(StorageLayoutBcache)
+	c.Assert(params.Values, jc.DeepEquals, url.Values{
+		"storage_layout": {"bcache"},
+		"boot_size":      {"536870912"},
+		"root_device":    {"34"},
+		"cache_device":   {"35"},
+		"cache_mode":     {"writeback"},
+		"cache_no_part":  {"true"},
+	})
+}
@@ -4,14 +4,18 @@
 package gomaasapi
 
 import (
+	"net"
+	"net/http"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type subnet struct {
-	// Add the controller in when we need to do things with the subnet.
-	// controller Controller
+	rawJSON
+
+	controller *controller
 
 	resourceURI string
 
@@ -24,6 +28,18 @@ type subnet struct {
 	cidr    string
 
 	dnsServers []string
+
+	// expandedSpace is only set when references were expanded.
+	expandedSpace *space
+}
+
+// withController returns a copy of the subnet that uses the controller.
+// Subnets nested in other entities are shared by everyone reading those
+// entities, so the controller is not set on them in place.
+func (s *subnet) withController(c *controller) *subnet {
+	copied := *s
+	copied.controller = c
+	return &copied
 }
 
 // ID implements Subnet.
@@ -41,6 +57,14 @@ func (s *subnet) Space() string {
 	return s.space
 }
 
+// ExpandedSpace implements Subnet.
+func (s *subnet) ExpandedSpace() Space {
+	if s.expandedSpace == nil {
+		return nil
+	}
+	return s.expandedSpace
+}
+
 // VLAN implements Subnet.
 func (s *subnet) VLAN() VLAN {
 	if s.vlan == nil {
@@ -64,6 +88,188 @@ func (s *subnet) DNSServers() []string {
 	return s.dnsServers
 }
 
+// Statistics implements Subnet.
+func (s *subnet) Statistics() (SubnetStatistics, error) {
+	var empty SubnetStatistics
+	source, err := s.controller.getOp(s.resourceURI, "statistics")
+	if err != nil {
+		return empty, s.translateError(err)
+	}
+	return readSubnetStatistics(s.controller.markLeaves(source))
+}
+
+// UnreservedIPRanges implements Subnet.
+func (s *subnet) UnreservedIPRanges() ([]IPRange, error) {
+	source, err := s.controller.getOp(s.resourceURI, "unreserved_ip_ranges")
+	if err != nil {
+		return nil, s.translateError(err)
+	}
+	return readIPRanges(s.controller.markLeaves(source))
+}
+
+// ReservedIPRanges implements Subnet.
+func (s *subnet) ReservedIPRanges() ([]IPRange, error) {
+	source, err := s.controller.getOp(s.resourceURI, "reserved_ip_ranges")
+	if err != nil {
+		return nil, s.translateError(err)
+	}
+	return readIPRanges(s.controller.markLeaves(source))
+}
+
+// ForEachUsableIP implements Subnet.
+func (s *subnet) ForEachUsableIP(callback func(net.IP) error) error {
+	reserved, err := s.ReservedIPRanges()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return IterateIPs(s.cidr, reserved, callback)
+}
+
+func (s *subnet) translateError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+// SubnetStatistics describes the address usage of a subnet as calculated by
+// the MAAS controller.
+type SubnetStatistics struct {
+	// TotalAddresses is the number of usable addresses in the subnet.
+	TotalAddresses int
+	// NumAvailable is the number of addresses that are not in use or
+	// reserved.
+	NumAvailable int
+	// NumUnavailable is the number of addresses that are in use or reserved.
+	NumUnavailable int
+	// LargestAvailable is the size of the largest contiguous free range.
+	LargestAvailable int
+	// Usage is the fraction of the addresses that are unavailable, in the
+	// range 0 to 1.
+	Usage float64
+
+	FirstAddress string
+	LastAddress  string
+	IPVersion    int
+}
+
+// UsagePercent returns the Usage as a percentage.
+func (s SubnetStatistics) UsagePercent() float64 {
+	return s.Usage * 100
+}
+
+// AvailablePercent returns the percentage of the addresses in the subnet
+// that are available.
+func (s SubnetStatistics) AvailablePercent() float64 {
+	if s.TotalAddresses == 0 {
+		return 0
+	}
+	return float64(s.NumAvailable) * 100 / float64(s.TotalAddresses)
+}
+
+// IPRange is a contiguous range of addresses within a subnet.
+type IPRange struct {
+	Start        string
+	End          string
+	NumAddresses int
+	// Purpose describes why a reserved range is reserved, e.g. "dynamic",
+	// "reserved", "gateway-ip" or "assigned-ip". It is empty for unreserved
+	// ranges.
+	Purpose []string
+}
+
+func readSubnetStatistics(source interface{}) (SubnetStatistics, error) {
+	var empty SubnetStatistics
+	fields := schema.Fields{
+		"total_addresses":   intField(),
+		"num_available":     intField(),
+		"num_unavailable":   intField(),
+		"largest_available": intField(),
+		"usage":             floatField(),
+		"first_address":     stringField(),
+		"last_address":      stringField(),
+		"ip_version":        intField(),
+	}
+	defaults := schema.Defaults{
+		"first_address": "",
+		"last_address":  "",
+		"ip_version":    0,
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return empty, WrapWithDeserializationError(err, "subnet statistics schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+	return SubnetStatistics{
+		TotalAddresses:   valid["total_addresses"].(int),
+		NumAvailable:     valid["num_available"].(int),
+		NumUnavailable:   valid["num_unavailable"].(int),
+		LargestAvailable: valid["largest_available"].(int),
+		Usage:            valid["usage"].(float64),
+		FirstAddress:     valid["first_address"].(string),
+		LastAddress:      valid["last_address"].(string),
+		IPVersion:        valid["ip_version"].(int),
+	}, nil
+}
+
+func readIPRanges(source interface{}) ([]IPRange, error) {
+	fields := schema.Fields{
+		"start":         stringField(),
+		"end":           stringField(),
+		"num_addresses": intField(),
+		"purpose":       schema.List(stringField()),
+	}
+	defaults := schema.Defaults{
+		"purpose": schema.Omit,
+	}
+	checker := schema.List(schema.FieldMap(fields, defaults))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "ip range schema check failed")
+	}
+	valid := coerced.([]interface{})
+	result := make([]IPRange, len(valid))
+	for i, value := range valid {
+		// These casts are all safe because of the schema check.
+		item := value.(map[string]interface{})
+		result[i] = IPRange{
+			Start:        item["start"].(string),
+			End:          item["end"].(string),
+			NumAddresses: item["num_addresses"].(int),
+			Purpose:      convertToStringSlice(item["purpose"]),
+		}
+	}
+	return result, nil
+}
+
+func readSubnet(controllerVersion version.Number, source interface{}) (*subnet, error) {
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, errors.Annotatef(err, "subnet base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range subnetDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, errors.Errorf("no subnet read func for version %s", controllerVersion)
+	}
+	return subnetDeserializationFuncs[deserialisationVersion](valid)
+}
+
 func readSubnets(controllerVersion version.Number, source interface{}) ([]*subnet, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -110,14 +316,14 @@ var subnetDeserializationFuncs = map[version.Number]subnetDeserializationFunc{
 
 func subnet_2_0(source map[string]interface{}) (*subnet, error) {
 	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"name":         schema.String(),
-		"space":        schema.String(),
-		"gateway_ip":   schema.OneOf(schema.Nil(""), schema.String()),
-		"cidr":         schema.String(),
+		"resource_uri": stringField(),
+		"id":           intField(),
+		"name":         stringField(),
+		"space":        stringField(),
+		"gateway_ip":   nullable(stringField()),
+		"cidr":         stringField(),
 		"vlan":         schema.StringMap(schema.Any()),
-		"dns_servers":  schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+		"dns_servers":  nullable(schema.List(stringField())),
 	}
 	checker := schema.FieldMap(fields, nil) // no defaults
 	coerced, err := checker.Coerce(source, nil)
@@ -139,6 +345,7 @@ func subnet_2_0(source map[string]interface{}) (*subnet, error) {
 	gateway, _ := valid["gateway_ip"].(string)
 
 	result := &subnet{
+		rawJSON:     rawJSON{source},
 		resourceURI: valid["resource_uri"].(string),
 		id:          valid["id"].(int),
 		name:        valid["name"].(string),
@@ -4,12 +4,18 @@
 package gomaasapi
 
 import (
+	"net"
+	"net/http"
+
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type subnetSuite struct{}
+type subnetSuite struct {
+	testing.CleanupSuite
+}
 
 var _ = gc.Suite(&subnetSuite{})
 
@@ -51,6 +57,166 @@ func (*subnetSuite) TestHighVersion(c *gc.C) {
 	c.Assert(subnets, gc.HasLen, 2)
 }
 
+func (s *subnetSuite) getServerAndSubnet(c *gc.C) (*SimpleTestServer, *subnet) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	subnets, err := controller.Subnets()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 2)
+	subnet := subnets[0].(*subnet)
+	server.ResetRequests()
+	return server, subnet
+}
+
+func (s *subnetSuite) TestStatistics(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI+"?op=statistics", http.StatusOK, subnetStatisticsResponse)
+	stats, err := subnet.Statistics()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(stats, jc.DeepEquals, SubnetStatistics{
+		TotalAddresses:   254,
+		NumAvailable:     190,
+		NumUnavailable:   64,
+		LargestAvailable: 160,
+		Usage:            0.25196850393700787,
+		FirstAddress:     "192.168.100.1",
+		LastAddress:      "192.168.100.254",
+		IPVersion:        4,
+	})
+	c.Check(stats.UsagePercent(), gc.Equals, 25.196850393700787)
+	c.Check(stats.AvailablePercent(), gc.Equals, float64(190)*100/254)
+}
+
+func (s *subnetSuite) TestStatisticsNotFound(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI+"?op=statistics", http.StatusNotFound, "no such subnet")
+	_, err := subnet.Statistics()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, "no such subnet")
+}
+
+func (s *subnetSuite) TestStatisticsBadSchema(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI+"?op=statistics", http.StatusOK, `{"usage": "lots"}`)
+	_, err := subnet.Statistics()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *subnetSuite) TestUnreservedIPRanges(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI+"?op=unreserved_ip_ranges", http.StatusOK, unreservedIPRangesResponse)
+	ranges, err := subnet.UnreservedIPRanges()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(ranges, jc.DeepEquals, []IPRange{{
+		Start:        "192.168.100.2",
+		End:          "192.168.100.29",
+		NumAddresses: 28,
+	}, {
+		Start:        "192.168.100.95",
+		End:          "192.168.100.254",
+		NumAddresses: 160,
+	}})
+}
+
+func (s *subnetSuite) TestReservedIPRanges(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI+"?op=reserved_ip_ranges", http.StatusOK, reservedIPRangesResponse)
+	ranges, err := subnet.ReservedIPRanges()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(ranges, jc.DeepEquals, []IPRange{{
+		Start:        "192.168.100.1",
+		End:          "192.168.100.1",
+		NumAddresses: 1,
+		Purpose:      []string{"gateway-ip"},
+	}, {
+		Start:        "192.168.100.30",
+		End:          "192.168.100.94",
+		NumAddresses: 65,
+		Purpose:      []string{"dynamic", "assigned-ip"},
+	}})
+}
+
+func (s *subnetSuite) TestReservedIPRangesUnexpected(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI+"?op=reserved_ip_ranges", http.StatusConflict, "wat?")
+	_, err := subnet.ReservedIPRanges()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *subnetSuite) TestForEachUsableIP(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI+"?op=reserved_ip_ranges", http.StatusOK, reservedIPRangesResponse)
+	var ips []string
+	err := subnet.ForEachUsableIP(func(ip net.IP) error {
+		ips = append(ips, ip.String())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	// The same addresses as the unreserved ranges.
+	c.Assert(ips, gc.HasLen, 28+160)
+	c.Check(ips[0], gc.Equals, "192.168.100.2")
+	c.Check(ips[27], gc.Equals, "192.168.100.29")
+	c.Check(ips[28], gc.Equals, "192.168.100.95")
+	c.Check(ips[len(ips)-1], gc.Equals, "192.168.100.254")
+}
+
+func (s *subnetSuite) TestForEachUsableIPError(c *gc.C) {
+	server, subnet := s.getServerAndSubnet(c)
+	server.AddGetResponse(subnet.resourceURI+"?op=reserved_ip_ranges", http.StatusNotFound, "no such subnet")
+	err := subnet.ForEachUsableIP(func(ip net.IP) error {
+		c.Fatalf("unexpected address %s", ip)
+		return nil
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+var subnetStatisticsResponse = `
+{
+    "num_available": 190,
+    "largest_available": 160,
+    "num_unavailable": 64,
+    "total_addresses": 254,
+    "usage": 0.25196850393700787,
+    "usage_string": "25%",
+    "available_string": "75%",
+    "first_address": "192.168.100.1",
+    "last_address": "192.168.100.254",
+    "ip_version": 4
+}
+`
+
+var unreservedIPRangesResponse = `
+[
+    {
+        "start": "192.168.100.2",
+        "end": "192.168.100.29",
+        "num_addresses": 28
+    },
+    {
+        "start": "192.168.100.95",
+        "end": "192.168.100.254",
+        "num_addresses": 160
+    }
+]
+`
+
+var reservedIPRangesResponse = `
+[
+    {
+        "start": "192.168.100.1",
+        "end": "192.168.100.1",
+        "num_addresses": 1,
+        "purpose": ["gateway-ip"]
+    },
+    {
+        "start": "192.168.100.30",
+        "end": "192.168.100.94",
+        "num_addresses": 65,
+        "purpose": ["dynamic", "assigned-ip"]
+    }
+]
+`
+
 var subnetResponse = `
 [
     {
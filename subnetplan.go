@@ -0,0 +1,228 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+// PlanSubnetsArgs is an argument struct for passing the parent network and
+// the sizes of the subnets wanted into PlanSubnets.
+type PlanSubnetsArgs struct {
+	// Parent is the network, in CIDR form, to carve the subnets out of.
+	Parent string
+
+	// PrefixLengths are the sizes of the subnets wanted, such as 24 for
+	// a /24. Each must be at least the prefix length of the parent.
+	PrefixLengths []int
+
+	// Create, if true, creates the planned subnets in MAAS.
+	Create bool
+
+	// VLAN, if not nil, is the VLAN the created subnets are on. Otherwise
+	// MAAS puts them on the untagged VLAN of the default fabric.
+	VLAN VLAN
+}
+
+// Validate makes sure that the parent is a valid CIDR and that each prefix
+// length fits in it.
+func (a *PlanSubnetsArgs) Validate() error {
+	_, parent, err := net.ParseCIDR(a.Parent)
+	if err != nil {
+		return NewArgumentError("Parent", "%q is not a valid CIDR", a.Parent)
+	}
+	if len(a.PrefixLengths) == 0 {
+		return NewArgumentError("PrefixLengths", "no subnets requested")
+	}
+	ones, bits := parent.Mask.Size()
+	for _, length := range a.PrefixLengths {
+		if length < ones || length > bits {
+			return NewArgumentError("PrefixLengths", "/%d does not fit in %s", length, parent)
+		}
+	}
+	return nil
+}
+
+// SubnetPlan is the result of PlanSubnets.
+type SubnetPlan struct {
+	// CIDRs are the planned subnets, in the order of the prefix lengths.
+	CIDRs []string
+
+	// Created are the subnets created in MAAS, if asked to create them.
+	Created []Subnet
+}
+
+// PlanSubnets implements Controller.
+//
+// Returns an error that satisfies IsNoMatchError if the parent does not
+// have room for the subnets. If creating a subnet fails, the plan holds
+// the subnets created before it.
+func (c *controller) PlanSubnets(args PlanSubnetsArgs) (SubnetPlan, error) {
+	if err := args.Validate(); err != nil {
+		return SubnetPlan{}, errors.Trace(err)
+	}
+	subnets, err := c.Subnets()
+	if err != nil {
+		return SubnetPlan{}, errors.Trace(err)
+	}
+	existing := make([]string, len(subnets))
+	for i, subnet := range subnets {
+		existing[i] = subnet.CIDR()
+	}
+	cidrs, err := PlanSubnetCIDRs(args.Parent, args.PrefixLengths, existing)
+	if err != nil {
+		return SubnetPlan{}, errors.Trace(err)
+	}
+	plan := SubnetPlan{CIDRs: cidrs}
+	if !args.Create {
+		return plan, nil
+	}
+	for _, cidr := range cidrs {
+		subnet, err := c.createSubnet(cidr, args.VLAN)
+		if err != nil {
+			return plan, errors.Annotatef(err, "creating subnet %s", cidr)
+		}
+		plan.Created = append(plan.Created, subnet)
+	}
+	return plan, nil
+}
+
+func (c *controller) createSubnet(cidr string, vlan VLAN) (Subnet, error) {
+	params := NewURLParams()
+	params.Values.Add("cidr", cidr)
+	if vlan != nil {
+		params.Values.Add("vlan", strconv.Itoa(vlan.ID()))
+	}
+	result, err := c.post("subnets", "", params.Values)
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusBadRequest:
+				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		return nil, NewUnexpectedError(err)
+	}
+	subnet, err := readSubnet(c.apiVersion, c.markLeaves(result))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	subnet.controller = c
+	return subnet, nil
+}
+
+// PlanSubnetCIDRs works out subnets of the parent with the prefix lengths
+// that overlap neither each other nor the existing networks. The larger
+// subnets are placed first, each at the lowest free address, so that small
+// subnets do not fragment the parent. The subnets are returned in the
+// order of the prefix lengths. Returns an error that satisfies
+// IsNoMatchError if the parent does not have room for them.
+func PlanSubnetCIDRs(parent string, prefixLengths []int, existing []string) ([]string, error) {
+	args := PlanSubnetsArgs{Parent: parent, PrefixLengths: prefixLengths}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	_, parentNet, _ := net.ParseCIDR(parent)
+	_, bits := parentNet.Mask.Size()
+
+	var taken []addressBlock
+	for _, cidr := range existing {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Annotatef(err, "existing network")
+		}
+		if _, otherBits := network.Mask.Size(); otherBits == bits {
+			taken = append(taken, newAddressBlock(network))
+		}
+	}
+
+	order := make([]int, len(prefixLengths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return prefixLengths[order[i]] < prefixLengths[order[j]]
+	})
+
+	parentBlock := newAddressBlock(parentNet)
+	result := make([]string, len(prefixLengths))
+	for _, index := range order {
+		block, ok := firstFreeBlock(parentBlock, prefixLengths[index], bits, taken)
+		if !ok {
+			return nil, NewNoMatchError(fmt.Sprintf("no room for a /%d in %s", prefixLengths[index], parentNet))
+		}
+		taken = append(taken, block)
+		result[index] = block.String(bits)
+	}
+	return result, nil
+}
+
+// addressBlock is a network as the range of addresses first to last.
+type addressBlock struct {
+	first, last *big.Int
+	prefix      int
+}
+
+func newAddressBlock(network *net.IPNet) addressBlock {
+	ones, bits := network.Mask.Size()
+	first := new(big.Int).SetBytes(network.IP.Mask(network.Mask))
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last := new(big.Int).Sub(new(big.Int).Add(first, size), big.NewInt(1))
+	return addressBlock{first: first, last: last, prefix: ones}
+}
+
+func (b addressBlock) overlaps(other addressBlock) bool {
+	return b.first.Cmp(other.last) <= 0 && other.first.Cmp(b.last) <= 0
+}
+
+// String returns the block in CIDR form.
+func (b addressBlock) String(bits int) string {
+	ip := make(net.IP, bits/8)
+	raw := b.first.Bytes()
+	copy(ip[len(ip)-len(raw):], raw)
+	return fmt.Sprintf("%s/%d", ip, b.prefix)
+}
+
+// firstFreeBlock returns the lowest block with the prefix length in the
+// parent that does not overlap any of the taken blocks.
+func firstFreeBlock(parent addressBlock, prefix, bits int, taken []addressBlock) (addressBlock, bool) {
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix))
+	first := new(big.Int).Set(parent.first)
+	for {
+		last := new(big.Int).Sub(new(big.Int).Add(first, size), big.NewInt(1))
+		if last.Cmp(parent.last) > 0 {
+			return addressBlock{}, false
+		}
+		candidate := addressBlock{first: first, last: last, prefix: prefix}
+		var blocker *addressBlock
+		for i := range taken {
+			if candidate.overlaps(taken[i]) {
+				blocker = &taken[i]
+				break
+			}
+		}
+		if blocker == nil {
+			return candidate, true
+		}
+		// Move to the first aligned block after the one in the way.
+		next := new(big.Int).Add(blocker.last, big.NewInt(1))
+		remainder := new(big.Int).Mod(next, size)
+		if remainder.Sign() != 0 {
+			next.Add(next, new(big.Int).Sub(size, remainder))
+		}
+		if next.Cmp(first) <= 0 {
+			next = new(big.Int).Add(first, size)
+		}
+		first = next
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type subnetPlanSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&subnetPlanSuite{})
+
+func (*subnetPlanSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		args  PlanSubnetsArgs
+		field string
+	}{{
+		args:  PlanSubnetsArgs{Parent: "10.0.0.0", PrefixLengths: []int{24}},
+		field: "Parent",
+	}, {
+		args:  PlanSubnetsArgs{Parent: "10.0.0.0/16"},
+		field: "PrefixLengths",
+	}, {
+		args:  PlanSubnetsArgs{Parent: "10.0.0.0/16", PrefixLengths: []int{24, 8}},
+		field: "PrefixLengths",
+	}, {
+		args:  PlanSubnetsArgs{Parent: "10.0.0.0/16", PrefixLengths: []int{33}},
+		field: "PrefixLengths",
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(errors.Cause(err).(*ArgumentError).Field, gc.Equals, test.field)
+	}
+}
+
+func (*subnetPlanSuite) TestPlanSubnetCIDRs(c *gc.C) {
+	for i, test := range []struct {
+		parent   string
+		lengths  []int
+		existing []string
+		expected []string
+	}{{
+		parent:   "10.0.0.0/16",
+		lengths:  []int{24, 24},
+		expected: []string{"10.0.0.0/24", "10.0.1.0/24"},
+	}, {
+		// The larger subnet is placed first.
+		parent:   "10.0.0.0/16",
+		lengths:  []int{26, 24},
+		expected: []string{"10.0.1.0/26", "10.0.0.0/24"},
+	}, {
+		parent:   "10.0.0.0/16",
+		lengths:  []int{24, 23},
+		existing: []string{"10.0.0.0/24", "10.0.2.128/25", "192.168.0.0/16"},
+		expected: []string{"10.0.1.0/24", "10.0.4.0/23"},
+	}, {
+		// Existing networks that contain the parent leave no room.
+		parent:   "10.0.0.0/24",
+		lengths:  []int{24},
+		existing: []string{"10.0.0.0/24"},
+	}, {
+		parent:   "10.0.0.0/24",
+		lengths:  []int{25, 25, 26},
+		expected: nil,
+	}, {
+		parent:   "2001:db8::/48",
+		lengths:  []int{64, 64},
+		existing: []string{"2001:db8::/64", "10.0.0.0/8"},
+		expected: []string{"2001:db8:0:1::/64", "2001:db8:0:2::/64"},
+	}} {
+		c.Logf("test %d", i)
+		cidrs, err := PlanSubnetCIDRs(test.parent, test.lengths, test.existing)
+		if test.expected == nil {
+			c.Check(err, jc.Satisfies, IsNoMatchError)
+			continue
+		}
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(cidrs, jc.DeepEquals, test.expected)
+	}
+}
+
+func (*subnetPlanSuite) TestPlanSubnetCIDRsNoRoomMessage(c *gc.C) {
+	_, err := PlanSubnetCIDRs("10.0.0.0/24", []int{25}, []string{"10.0.0.64/26", "10.0.0.128/26"})
+	c.Check(err, gc.ErrorMatches, `no room for a /25 in 10.0.0.0/24`)
+}
+
+func (*subnetPlanSuite) TestPlanSubnetCIDRsBadExisting(c *gc.C) {
+	_, err := PlanSubnetCIDRs("10.0.0.0/24", []int{25}, []string{"wat"})
+	c.Check(err, gc.ErrorMatches, `existing network: invalid CIDR address: wat`)
+}
+
+func (s *subnetPlanSuite) getServerAndController(c *gc.C) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+// createdSubnet returns the first subnet of subnetResponse with the CIDR.
+func createdSubnet(c *gc.C, cidr string) string {
+	source := parseJSON(c, subnetResponse).([]interface{})[0]
+	subnet := source.(map[string]interface{})
+	subnet["cidr"] = cidr
+	subnet["name"] = cidr
+	bytes, err := json.Marshal(subnet)
+	c.Assert(err, jc.ErrorIsNil)
+	return string(bytes)
+}
+
+func (s *subnetPlanSuite) TestPlanSubnets(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	plan, err := controller.PlanSubnets(PlanSubnetsArgs{
+		Parent:        "192.168.0.0/16",
+		PrefixLengths: []int{24, 24},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(plan.CIDRs, jc.DeepEquals, []string{"192.168.0.0/24", "192.168.1.0/24"})
+	c.Check(plan.Created, gc.HasLen, 0)
+	c.Check(server.LastRequest().Method, gc.Equals, "GET")
+}
+
+func (s *subnetPlanSuite) TestPlanSubnetsAvoidsExisting(c *gc.C) {
+	_, controller := s.getServerAndController(c)
+	plan, err := controller.PlanSubnets(PlanSubnetsArgs{
+		Parent:        "192.168.96.0/19",
+		PrefixLengths: []int{22, 24},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	// 192.168.100.0/24 is in the first /22 after 192.168.96.0/22.
+	c.Check(plan.CIDRs, jc.DeepEquals, []string{"192.168.96.0/22", "192.168.101.0/24"})
+}
+
+func (s *subnetPlanSuite) TestPlanSubnetsCreate(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddPostResponse("/api/2.0/subnets/", http.StatusOK, createdSubnet(c, "10.1.0.0/24"))
+	server.AddPostResponse("/api/2.0/subnets/", http.StatusOK, createdSubnet(c, "10.1.1.0/24"))
+	vlans, err := readVLANs(twoDotOh, parseJSON(c, vlanResponseWithName))
+	c.Assert(err, jc.ErrorIsNil)
+
+	plan, err := controller.PlanSubnets(PlanSubnetsArgs{
+		Parent:        "10.1.0.0/16",
+		PrefixLengths: []int{24, 24},
+		Create:        true,
+		VLAN:          vlans[0],
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(plan.Created, gc.HasLen, 2)
+	c.Check(plan.Created[1].CIDR(), gc.Equals, "10.1.1.0/24")
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("cidr"), gc.Equals, "10.1.1.0/24")
+	c.Check(form.Get("vlan"), gc.Equals, "1")
+}
+
+func (s *subnetPlanSuite) TestPlanSubnetsCreateFails(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddPostResponse("/api/2.0/subnets/", http.StatusOK, createdSubnet(c, "10.1.0.0/24"))
+	server.AddPostResponse("/api/2.0/subnets/", http.StatusForbidden, "admins only")
+
+	plan, err := controller.PlanSubnets(PlanSubnetsArgs{
+		Parent:        "10.1.0.0/16",
+		PrefixLengths: []int{24, 24},
+		Create:        true,
+	})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Check(err, gc.ErrorMatches, `creating subnet 10.1.1.0/24: admins only`)
+	c.Check(plan.CIDRs, gc.HasLen, 2)
+	c.Assert(plan.Created, gc.HasLen, 1)
+	c.Check(plan.Created[0].CIDR(), gc.Equals, "10.1.0.0/24")
+	c.Check(server.LastRequest().PostForm.Get("vlan"), gc.Equals, "")
+}
@@ -0,0 +1,200 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// TagPredicate decides from the hardware facts of a machine, as recorded
+// by commissioning, whether the machine should have a tag.
+type TagPredicate func(Machine) bool
+
+// MatchProfile returns a predicate that is true for the machines that
+// match the profile.
+func MatchProfile(p Profile) TagPredicate {
+	return func(m Machine) bool {
+		return len(m.MatchesProfile(p)) == 0
+	}
+}
+
+// MatchAll returns a predicate that is true when all the predicates are.
+func MatchAll(predicates ...TagPredicate) TagPredicate {
+	return func(m Machine) bool {
+		for _, p := range predicates {
+			if !p(m) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAny returns a predicate that is true when any of the predicates is.
+func MatchAny(predicates ...TagPredicate) TagPredicate {
+	return func(m Machine) bool {
+		for _, p := range predicates {
+			if p(m) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchNone returns a predicate that is true when none of the predicates
+// are.
+func MatchNone(predicates ...TagPredicate) TagPredicate {
+	matchAny := MatchAny(predicates...)
+	return func(m Machine) bool {
+		return !matchAny(m)
+	}
+}
+
+// TagRule is a tag that the machines matching the predicate should have,
+// and the others should not.
+type TagRule struct {
+	Tag string
+	// Comment is used if the tag has to be created.
+	Comment string
+	Match   TagPredicate
+}
+
+// ApplyTagRulesArgs is an argument struct for passing the rules and the
+// machines to apply them to into ApplyTagRules.
+type ApplyTagRulesArgs struct {
+	Rules []TagRule
+
+	// Machines selects the machines the rules are applied to. A tag is
+	// not removed from machines that are not selected.
+	Machines MachinesArgs
+
+	// DryRun, if true, works out the changes without making them.
+	DryRun bool
+}
+
+// tagNamePattern matches the tag names that MAAS accepts.
+var tagNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Validate makes sure that each rule has a valid tag name, that no tag has
+// two rules and that each rule has a predicate.
+func (a *ApplyTagRulesArgs) Validate() error {
+	tags := set.NewStrings()
+	for _, rule := range a.Rules {
+		if !tagNamePattern.MatchString(rule.Tag) {
+			return NewArgumentError("Rules", "%q is not a valid tag name", rule.Tag)
+		}
+		if tags.Contains(rule.Tag) {
+			return NewArgumentError("Rules", "tag %q has more than one rule", rule.Tag)
+		}
+		tags.Add(rule.Tag)
+		if rule.Match == nil {
+			return NewArgumentError("Rules", "tag %q has no predicate", rule.Tag)
+		}
+	}
+	return nil
+}
+
+// TagRuleResult records the changes made for a TagRule.
+type TagRuleResult struct {
+	Tag string
+	// Added and Removed are the system IDs of the machines that the tag
+	// was added to or removed from, or would be for a dry run.
+	Added   []string
+	Removed []string
+	// Err is set if the tag could not be updated.
+	Err error
+}
+
+// ApplyTagRules implements Controller.
+//
+// The tag of a rule is created if MAAS does not have it. A failure to
+// apply one rule is recorded in its result and does not stop the others.
+func (c *controller) ApplyTagRules(args ApplyTagRulesArgs) ([]TagRuleResult, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	machines, err := c.Machines(args.Machines)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	results := make([]TagRuleResult, len(args.Rules))
+	for i, rule := range args.Rules {
+		results[i] = tagRuleChanges(rule, machines)
+		if args.DryRun || (len(results[i].Added) == 0 && len(results[i].Removed) == 0) {
+			continue
+		}
+		if err := c.updateTagNodes(rule, results[i].Added, results[i].Removed); err != nil {
+			results[i].Err = errors.Annotatef(err, "tag %q", rule.Tag)
+		}
+	}
+	return results, nil
+}
+
+// tagRuleChanges works out which machines need the tag of the rule added
+// or removed.
+func tagRuleChanges(rule TagRule, machines []Machine) TagRuleResult {
+	result := TagRuleResult{Tag: rule.Tag}
+	for _, m := range machines {
+		has := set.NewStrings(m.Tags()...).Contains(rule.Tag)
+		wants := rule.Match(m)
+		switch {
+		case wants && !has:
+			result.Added = append(result.Added, m.SystemID())
+		case has && !wants:
+			result.Removed = append(result.Removed, m.SystemID())
+		}
+	}
+	return result
+}
+
+// updateTagNodes creates the tag if needed, and then adds it to and removes
+// it from the machines.
+func (c *controller) updateTagNodes(rule TagRule, add, remove []string) error {
+	if err := c.ensureTag(rule.Tag, rule.Comment); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAddMany("add", add)
+	params.MaybeAddMany("remove", remove)
+	if _, err := c.post("tags/"+rule.Tag, "update_nodes", params.Values); err != nil {
+		return tagError(err)
+	}
+	return nil
+}
+
+func (c *controller) ensureTag(name, comment string) error {
+	_, err := c.get("tags/" + name)
+	if err == nil {
+		return nil
+	}
+	if svrErr, ok := errors.Cause(err).(ServerError); !ok || svrErr.StatusCode != http.StatusNotFound {
+		return tagError(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	params.MaybeAdd("comment", comment)
+	if _, err := c.post("tags", "", params.Values); err != nil {
+		return tagError(err)
+	}
+	return nil
+}
+
+func tagError(err error) error {
+	if svrErr, ok := errors.Cause(err).(ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest:
+			return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
+		case http.StatusNotFound:
+			return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
+		}
+	}
+	return NewUnexpectedError(err)
+}
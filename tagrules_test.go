@@ -0,0 +1,192 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type tagRulesSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&tagRulesSuite{})
+
+// getServerAndController returns a controller with two machines: "small"
+// with one CPU and the tags virtual and magic, and "big" with 16 CPUs and
+// no tags.
+func (s *tagRulesSuite) getServerAndController(c *gc.C) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	small := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id": "small",
+	})
+	big := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"system_id": "big",
+		"cpu_count": 16,
+		"tag_names": []string{},
+	})
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+small+","+big+"]")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+func manyCPUs(m Machine) bool {
+	return m.CPUCount() >= 8
+}
+
+func (*tagRulesSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		rules   []TagRule
+		message string
+	}{{
+		rules:   []TagRule{{Tag: "", Match: manyCPUs}},
+		message: `"" is not a valid tag name`,
+	}, {
+		rules:   []TagRule{{Tag: "two words", Match: manyCPUs}},
+		message: `"two words" is not a valid tag name`,
+	}, {
+		rules:   []TagRule{{Tag: "big", Match: manyCPUs}, {Tag: "big", Match: manyCPUs}},
+		message: `tag "big" has more than one rule`,
+	}, {
+		rules:   []TagRule{{Tag: "big"}},
+		message: `tag "big" has no predicate`,
+	}} {
+		c.Logf("test %d", i)
+		args := ApplyTagRulesArgs{Rules: test.rules}
+		err := args.Validate()
+		c.Check(err, jc.Satisfies, IsArgumentError)
+		c.Check(err, gc.ErrorMatches, "Rules: "+test.message)
+	}
+}
+
+func (*tagRulesSuite) TestPredicates(c *gc.C) {
+	yes := func(Machine) bool { return true }
+	no := func(Machine) bool { return false }
+	c.Check(MatchAll(yes, yes)(nil), jc.IsTrue)
+	c.Check(MatchAll(yes, no)(nil), jc.IsFalse)
+	c.Check(MatchAll()(nil), jc.IsTrue)
+	c.Check(MatchAny(no, yes)(nil), jc.IsTrue)
+	c.Check(MatchAny(no, no)(nil), jc.IsFalse)
+	c.Check(MatchAny()(nil), jc.IsFalse)
+	c.Check(MatchNone(no, no)(nil), jc.IsTrue)
+	c.Check(MatchNone(no, yes)(nil), jc.IsFalse)
+}
+
+func (s *tagRulesSuite) TestMatchProfile(c *gc.C) {
+	_, controller := s.getServerAndController(c)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	predicate := MatchProfile(Profile{MinCPUCount: 8})
+	c.Check(predicate(machines[0]), jc.IsFalse)
+	c.Check(predicate(machines[1]), jc.IsTrue)
+}
+
+func (s *tagRulesSuite) TestApplyTagRules(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/tags/many-cpus/", http.StatusNotFound, "no such tag")
+	server.AddPostResponse("/api/2.0/tags/", http.StatusOK, `{"name": "many-cpus"}`)
+	server.AddPostResponse("/api/2.0/tags/many-cpus/?op=update_nodes", http.StatusOK, `{"added": 1, "removed": 0}`)
+	server.AddGetResponse("/api/2.0/tags/virtual/", http.StatusOK, `{"name": "virtual"}`)
+	server.AddPostResponse("/api/2.0/tags/virtual/?op=update_nodes", http.StatusOK, `{"added": 1, "removed": 1}`)
+
+	results, err := controller.ApplyTagRules(ApplyTagRulesArgs{
+		Rules: []TagRule{{
+			Tag:     "many-cpus",
+			Comment: "eight or more CPUs",
+			Match:   manyCPUs,
+		}, {
+			Tag:   "virtual",
+			Match: manyCPUs,
+		}, {
+			Tag:   "magic",
+			Match: MatchNone(manyCPUs),
+		}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(results, jc.DeepEquals, []TagRuleResult{
+		{Tag: "many-cpus", Added: []string{"big"}},
+		{Tag: "virtual", Added: []string{"big"}, Removed: []string{"small"}},
+		{Tag: "magic"},
+	})
+
+	requests := server.LastNRequests(5)
+	c.Check(requests[1].URL.Path, gc.Equals, "/api/2.0/tags/")
+	c.Check(requests[1].PostForm.Get("name"), gc.Equals, "many-cpus")
+	c.Check(requests[1].PostForm.Get("comment"), gc.Equals, "eight or more CPUs")
+	c.Check(requests[2].PostForm["add"], jc.DeepEquals, []string{"big"})
+	c.Check(requests[2].PostForm["remove"], gc.HasLen, 0)
+	c.Check(requests[4].PostForm["add"], jc.DeepEquals, []string{"big"})
+	c.Check(requests[4].PostForm["remove"], jc.DeepEquals, []string{"small"})
+}
+
+func (s *tagRulesSuite) TestApplyTagRulesDryRun(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.ResetRequests()
+	results, err := controller.ApplyTagRules(ApplyTagRulesArgs{
+		Rules:  []TagRule{{Tag: "virtual", Match: manyCPUs}},
+		DryRun: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(results, jc.DeepEquals, []TagRuleResult{
+		{Tag: "virtual", Added: []string{"big"}, Removed: []string{"small"}},
+	})
+	// Only the machines are read.
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *tagRulesSuite) TestApplyTagRulesFailureRecorded(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/tags/virtual/", http.StatusOK, `{"name": "virtual"}`)
+	server.AddPostResponse("/api/2.0/tags/virtual/?op=update_nodes", http.StatusForbidden, "admins only")
+	server.AddGetResponse("/api/2.0/tags/many-cpus/", http.StatusOK, `{"name": "many-cpus"}`)
+	server.AddPostResponse("/api/2.0/tags/many-cpus/?op=update_nodes", http.StatusOK, `{"added": 1, "removed": 0}`)
+
+	results, err := controller.ApplyTagRules(ApplyTagRulesArgs{
+		Rules: []TagRule{
+			{Tag: "virtual", Match: manyCPUs},
+			{Tag: "many-cpus", Match: manyCPUs},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 2)
+	c.Check(results[0].Err, jc.Satisfies, IsPermissionError)
+	c.Check(results[0].Err, gc.ErrorMatches, `tag "virtual": admins only`)
+	c.Check(results[1].Err, jc.ErrorIsNil)
+}
+
+func (s *tagRulesSuite) TestApplyTagRulesCreateFails(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/tags/many-cpus/", http.StatusNotFound, "no such tag")
+	server.AddPostResponse("/api/2.0/tags/", http.StatusBadRequest, "bad tag")
+
+	results, err := controller.ApplyTagRules(ApplyTagRulesArgs{
+		Rules: []TagRule{{Tag: "many-cpus", Match: manyCPUs}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(results[0].Err, jc.Satisfies, IsBadRequestError)
+}
+
+func (s *tagRulesSuite) TestApplyTagRulesMachinesFail(c *gc.C) {
+	server, controller := s.getServerAndController(c)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusInternalServerError, "boom")
+	// Use up the good machines response to get to the failure.
+	_, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.ApplyTagRules(ApplyTagRulesArgs{
+		Rules: []TagRule{{Tag: "many-cpus", Match: manyCPUs}},
+	})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
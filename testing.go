@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 )
 
 type singleServingServer struct {
@@ -99,9 +100,49 @@ type SimpleTestServer struct {
 	deleteResponses     map[string][]simpleResponse
 	deleteResponseIndex map[string]int
 
+	// scenarios are keyed by the method and path, separated by a space.
+	scenarios map[string]*scenario
+
+	// mu guards the responses and requests, as clients such as
+	// DeployMany make requests concurrently.
+	mu       sync.Mutex
 	requests []*http.Request
 }
 
+// ScenarioStep is a response in a scenario added to a SimpleTestServer.
+type ScenarioStep struct {
+	Status int
+	Body   string
+
+	// Times is the number of requests the step answers before the next
+	// step is used. Zero is the same as one.
+	Times int
+}
+
+// scenario is the steps for an endpoint and how many requests it has
+// answered.
+type scenario struct {
+	steps  []ScenarioStep
+	served int
+}
+
+// next returns the step for the next request, counting it.
+func (sc *scenario) next() ScenarioStep {
+	n := sc.served
+	sc.served++
+	for _, step := range sc.steps {
+		times := step.Times
+		if times < 1 {
+			times = 1
+		}
+		if n < times {
+			return step
+		}
+		n -= times
+	}
+	return sc.steps[len(sc.steps)-1]
+}
+
 func NewSimpleServer() *SimpleTestServer {
 	server := &SimpleTestServer{
 		getResponses:        make(map[string][]simpleResponse),
@@ -112,6 +153,7 @@ func NewSimpleServer() *SimpleTestServer {
 		postResponseIndex:   make(map[string]int),
 		deleteResponses:     make(map[string][]simpleResponse),
 		deleteResponseIndex: make(map[string]int),
+		scenarios:           make(map[string]*scenario),
 	}
 	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.handler))
 	return server
@@ -119,25 +161,70 @@ func NewSimpleServer() *SimpleTestServer {
 
 func (s *SimpleTestServer) AddGetResponse(path string, status int, body string) {
 	logger.Debugf("add get response for: %s, %d", path, status)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.getResponses[path] = append(s.getResponses[path], simpleResponse{status: status, body: body})
 }
 
 func (s *SimpleTestServer) AddPutResponse(path string, status int, body string) {
 	logger.Debugf("add put response for: %s, %d", path, status)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.putResponses[path] = append(s.putResponses[path], simpleResponse{status: status, body: body})
 }
 
 func (s *SimpleTestServer) AddPostResponse(path string, status int, body string) {
 	logger.Debugf("add post response for: %s, %d", path, status)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.postResponses[path] = append(s.postResponses[path], simpleResponse{status: status, body: body})
 }
 
 func (s *SimpleTestServer) AddDeleteResponse(path string, status int, body string) {
 	logger.Debugf("add delete response for: %s, %d", path, status)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.deleteResponses[path] = append(s.deleteResponses[path], simpleResponse{status: status, body: body})
 }
 
+// AddScenario scripts the responses to the requests with the method and
+// path, replacing any scenario already added for them. The steps answer
+// the requests in order, each for its Times requests, and the last step
+// then answers every later request rather than the server running out of
+// responses. This suits tests of code that polls, for example:
+//
+//	server.AddScenario("GET", "/api/2.0/machines/?id=4y3ha3",
+//		ScenarioStep{Status: http.StatusOK, Body: deploying, Times: 3},
+//		ScenarioStep{Status: http.StatusOK, Body: deployed},
+//	)
+//
+// answers the first three polls with the deploying machine and all later
+// ones with the deployed machine. A scenario takes precedence over the
+// responses added for the same path with AddGetResponse and the others.
+func (s *SimpleTestServer) AddScenario(method, path string, steps ...ScenarioStep) {
+	if len(steps) == 0 {
+		panic("scenario without steps")
+	}
+	logger.Debugf("add %s scenario for: %s, %d steps", strings.ToLower(method), path, len(steps))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scenarios[method+" "+path] = &scenario{steps: steps}
+}
+
+// ScenarioRequests returns the number of requests that the scenario for
+// the method and path has answered.
+func (s *SimpleTestServer) ScenarioRequests(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok := s.scenarios[method+" "+path]; ok {
+		return sc.served
+	}
+	return 0
+}
+
 func (s *SimpleTestServer) LastRequest() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	pos := len(s.requests) - 1
 	if pos < 0 {
 		return nil
@@ -146,6 +233,8 @@ func (s *SimpleTestServer) LastRequest() *http.Request {
 }
 
 func (s *SimpleTestServer) LastNRequests(n int) []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	start := len(s.requests) - n
 	if start < 0 {
 		start = 0
@@ -154,10 +243,14 @@ func (s *SimpleTestServer) LastNRequests(n int) []*http.Request {
 }
 
 func (s *SimpleTestServer) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return len(s.requests)
 }
 
 func (s *SimpleTestServer) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.requests = nil
 }
 
@@ -205,8 +298,16 @@ func (s *SimpleTestServer) handler(writer http.ResponseWriter, request *http.Req
 	default:
 		panic("unsupported method " + method)
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.requests = append(s.requests, request)
 	uri := request.URL.String()
+	if sc, ok := s.scenarios[method+" "+uri]; ok {
+		step := sc.next()
+		writer.WriteHeader(step.Status)
+		fmt.Fprint(writer, step.Body)
+		return
+	}
 	testResponses, found := responses[uri]
 	if !found {
 		errorMsg := fmt.Sprintf("Error 404: page not found ('%v').", uri)
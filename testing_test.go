@@ -0,0 +1,63 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type simpleTestServerSuite struct{}
+
+var _ = gc.Suite(&simpleTestServerSuite{})
+
+func (*simpleTestServerSuite) TestScenario(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/things/", http.StatusOK, "canned")
+	server.AddScenario("GET", "/api/2.0/things/",
+		ScenarioStep{Status: http.StatusServiceUnavailable, Body: "busy"},
+		ScenarioStep{Status: http.StatusOK, Body: "pending", Times: 2},
+		ScenarioStep{Status: http.StatusOK, Body: "done"},
+	)
+	server.Start()
+	defer server.Close()
+
+	var bodies []string
+	for i := 0; i < 6; i++ {
+		response, err := http.Get(server.URL + "/api/2.0/things/")
+		c.Assert(err, jc.ErrorIsNil)
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		c.Assert(err, jc.ErrorIsNil)
+		bodies = append(bodies, string(body))
+	}
+	c.Check(bodies, jc.DeepEquals, []string{"busy", "pending", "pending", "done", "done", "done"})
+	c.Check(server.ScenarioRequests("GET", "/api/2.0/things/"), gc.Equals, 6)
+	c.Check(server.ScenarioRequests("POST", "/api/2.0/things/"), gc.Equals, 0)
+	c.Check(server.RequestCount(), gc.Equals, 6)
+}
+
+func (*simpleTestServerSuite) TestScenarioWithoutSteps(c *gc.C) {
+	server := NewSimpleServer()
+	c.Check(func() { server.AddScenario("GET", "/api/2.0/things/") }, gc.PanicMatches, "scenario without steps")
+}
+
+func (s *deploySuite) TestDeployManyScenario(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+	server.AddScenario("GET", "/api/2.0/machines/?id=4y3ha3",
+		ScenarioStep{Status: http.StatusOK, Body: "[" + machineJSON(c, "4y3ha3", "Deploying", "") + "]", Times: 4},
+		ScenarioStep{Status: http.StatusOK, Body: "[" + machineJSON(c, "4y3ha3", "Deployed", "") + "]"},
+	)
+
+	outcomes := controller.DeployMany(context.Background(), []MachineSpec{{Machine: machine}})
+	c.Assert(outcomes, gc.HasLen, 1)
+	c.Check(outcomes[0].Err, jc.ErrorIsNil)
+	c.Check(outcomes[0].Machine.StatusName(), gc.Equals, "Deployed")
+	c.Check(server.ScenarioRequests("GET", "/api/2.0/machines/?id=4y3ha3"), gc.Equals, 5)
+}
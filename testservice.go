@@ -798,7 +798,7 @@ func newDeviceHandler(server *TestServer, w http.ResponseWriter, r *http.Request
 	values := r.PostForm
 
 	// TODO(mfood): generate a "proper" uuid for the system Id.
-	uuid, err := generateNonce()
+	uuid, err := defaultNonceSource.Nonce()
 	checkError(err)
 	systemId := fmt.Sprintf("node-%v", uuid)
 	// At least one MAC address must be specified.
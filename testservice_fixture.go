@@ -0,0 +1,319 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+// testServerFixture is the object store of a TestServer as it is written
+// to fixture files. The resource URIs that depend on the API version of
+// the server are set again when the fixture is loaded, and the operations
+// the server has recorded are not kept. Lists are sorted so that saving
+// the same store always writes the same file.
+type testServerFixture struct {
+	VersionJSON            string                                       `json:"version_json,omitempty"`
+	Nodes                  []map[string]interface{}                     `json:"nodes,omitempty"`
+	OwnedNodes             []string                                     `json:"owned_nodes,omitempty"`
+	NodeMetadata           map[string]Node                              `json:"node_metadata,omitempty"`
+	NodeDetails            map[string]string                            `json:"node_details,omitempty"`
+	Files                  map[string][]byte                            `json:"files,omitempty"`
+	Networks               []map[string]interface{}                     `json:"networks,omitempty"`
+	NetworksPerNode        map[string][]string                          `json:"networks_per_node,omitempty"`
+	IPAddressesPerNetwork  map[string][]string                          `json:"ip_addresses_per_network,omitempty"`
+	MACAddressesPerNetwork map[string]map[string]map[string]interface{} `json:"mac_addresses_per_network,omitempty"`
+	Zones                  []map[string]interface{}                     `json:"zones,omitempty"`
+	BootImages             map[string][]interface{}                     `json:"boot_images,omitempty"`
+	NodegroupInterfaces    map[string][]interface{}                     `json:"nodegroup_interfaces,omitempty"`
+	Devices                []*TestDevice                                `json:"devices,omitempty"`
+	Subnets                []fixtureSubnet                              `json:"subnets,omitempty"`
+	Spaces                 []fixtureSpace                               `json:"spaces,omitempty"`
+	StaticRoutes           []fixtureStaticRoute                         `json:"static_routes,omitempty"`
+}
+
+// fixtureSubnet adds the addresses that TestSubnet leaves out of its JSON.
+type fixtureSubnet struct {
+	TestSubnet
+	InUseIPAddresses   []fixtureIP    `json:"in_use_ip_addresses,omitempty"`
+	FixedAddressRanges []AddressRange `json:"fixed_address_ranges,omitempty"`
+}
+
+type fixtureIP struct {
+	IP      string   `json:"ip"`
+	Purpose []string `json:"purpose,omitempty"`
+}
+
+type fixtureSpace struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type fixtureStaticRoute struct {
+	ID              uint   `json:"id"`
+	SourceCIDR      string `json:"source"`
+	DestinationCIDR string `json:"destination"`
+	GatewayIP       string `json:"gateway_ip"`
+	Metric          uint   `json:"metric"`
+}
+
+// SaveFixture writes the nodes, files, networks, zones, devices, subnets,
+// spaces and static routes of the server to the file as JSON, so that a
+// test environment built once can be kept in version control and loaded
+// by other test suites with LoadFixture. The operations recorded by the
+// server are not saved.
+func (server *TestServer) SaveFixture(filename string) error {
+	data, err := marshalIndentStable(server.fixture(), "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(ioutil.WriteFile(filename, data, 0644))
+}
+
+// LoadFixture clears the server, as Clear does, and fills it with the
+// objects in the file written by SaveFixture. The resource URIs of the
+// objects are those of the server's API version, which need not be the
+// version of the server that saved the file.
+func (server *TestServer) LoadFixture(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var fixture testServerFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return errors.Annotatef(err, "reading fixture %q", filename)
+	}
+	server.Clear()
+	if err := server.loadFixture(fixture); err != nil {
+		return errors.Annotatef(err, "loading fixture %q", filename)
+	}
+	return nil
+}
+
+func (server *TestServer) fixture() testServerFixture {
+	fixture := testServerFixture{
+		VersionJSON:            server.versionJSON,
+		NodeMetadata:           server.nodeMetadata,
+		NodeDetails:            server.nodeDetails,
+		Files:                  make(map[string][]byte),
+		NetworksPerNode:        server.networksPerNode,
+		IPAddressesPerNetwork:  server.ipAddressesPerNetwork,
+		MACAddressesPerNetwork: make(map[string]map[string]map[string]interface{}),
+		BootImages:             plainJSONLists(server.bootImages),
+		NodegroupInterfaces:    plainJSONLists(server.nodegroupsInterfaces),
+	}
+	for _, id := range sortedKeys(server.nodes) {
+		fixture.Nodes = append(fixture.Nodes, plainJSONMap(server.nodes[id].GetMap()))
+	}
+	for id, owned := range server.ownedNodes {
+		if owned {
+			fixture.OwnedNodes = append(fixture.OwnedNodes, id)
+		}
+	}
+	sort.Strings(fixture.OwnedNodes)
+	for filename, file := range server.files {
+		content, _ := file.GetField("content")
+		// The content was encoded by NewFile, so it always decodes.
+		fixture.Files[filename], _ = base64.StdEncoding.DecodeString(content)
+	}
+	for _, name := range sortedKeys(server.networks) {
+		fixture.Networks = append(fixture.Networks, plainJSONMap(server.networks[name].GetMap()))
+	}
+	for network, macs := range server.macAddressesPerNetwork {
+		fixture.MACAddressesPerNetwork[network] = make(map[string]map[string]interface{})
+		for systemID, attrs := range macs {
+			fixture.MACAddressesPerNetwork[network][systemID], _ = plainJSON(attrs).(map[string]interface{})
+		}
+	}
+	zoneNames := make([]string, 0, len(server.zones))
+	for name := range server.zones {
+		zoneNames = append(zoneNames, name)
+	}
+	sort.Strings(zoneNames)
+	for _, name := range zoneNames {
+		zone, _ := plainJSON(server.zones[name]).(map[string]interface{})
+		fixture.Zones = append(fixture.Zones, zone)
+	}
+	deviceIDs := make([]string, 0, len(server.devices))
+	for id := range server.devices {
+		deviceIDs = append(deviceIDs, id)
+	}
+	sort.Strings(deviceIDs)
+	for _, id := range deviceIDs {
+		fixture.Devices = append(fixture.Devices, server.devices[id])
+	}
+	for id := uint(1); id < server.nextSubnet; id++ {
+		subnet, ok := server.subnets[id]
+		if !ok {
+			continue
+		}
+		saved := fixtureSubnet{TestSubnet: subnet, FixedAddressRanges: subnet.FixedAddressRanges}
+		// The resource URI depends on the version of the server, so it is
+		// set again when the fixture is loaded.
+		saved.ResourceURI = ""
+		for _, ip := range subnet.InUseIPAddresses {
+			saved.InUseIPAddresses = append(saved.InUseIPAddresses, fixtureIP{IP: ip.String(), Purpose: ip.Purpose})
+		}
+		fixture.Subnets = append(fixture.Subnets, saved)
+	}
+	for id := uint(1); id < server.nextSpace; id++ {
+		if space, ok := server.spaces[id]; ok {
+			fixture.Spaces = append(fixture.Spaces, fixtureSpace{ID: space.ID, Name: space.Name})
+		}
+	}
+	for id := uint(1); id < server.nextStaticRoute; id++ {
+		if route, ok := server.staticRoutes[id]; ok {
+			fixture.StaticRoutes = append(fixture.StaticRoutes, fixtureStaticRoute{
+				ID:              route.ID,
+				SourceCIDR:      route.sourceCIDR,
+				DestinationCIDR: route.destinationCIDR,
+				GatewayIP:       route.GatewayIP,
+				Metric:          route.Metric,
+			})
+		}
+	}
+	return fixture
+}
+
+func (server *TestServer) loadFixture(fixture testServerFixture) error {
+	if fixture.VersionJSON != "" {
+		server.versionJSON = fixture.VersionJSON
+	}
+	for i, attrs := range fixture.Nodes {
+		systemID, ok := attrs["system_id"].(string)
+		if !ok {
+			return errors.Errorf("node %d has no system_id", i)
+		}
+		attrs[resourceURI] = getNodeURL(server.version, systemID)
+		server.nodes[systemID] = newJSONMAASObject(attrs, server.client)
+	}
+	for _, systemID := range fixture.OwnedNodes {
+		server.ownedNodes[systemID] = true
+	}
+	copyStringMap(server.nodeDetails, fixture.NodeDetails)
+	for systemID, node := range fixture.NodeMetadata {
+		server.nodeMetadata[systemID] = node
+	}
+	for filename, content := range fixture.Files {
+		server.NewFile(filename, content)
+	}
+	for i, attrs := range fixture.Networks {
+		name, ok := attrs["name"].(string)
+		if !ok {
+			return errors.Errorf("network %d has no name", i)
+		}
+		attrs[resourceURI] = getNetworkURL(server.version, name)
+		server.networks[name] = newJSONMAASObject(attrs, server.client)
+	}
+	for systemID, names := range fixture.NetworksPerNode {
+		server.networksPerNode[systemID] = names
+	}
+	for network, ips := range fixture.IPAddressesPerNetwork {
+		server.ipAddressesPerNetwork[network] = ips
+	}
+	for network, macs := range fixture.MACAddressesPerNetwork {
+		server.macAddressesPerNetwork[network] = make(map[string]JSONObject)
+		for systemID, attrs := range macs {
+			server.macAddressesPerNetwork[network][systemID] = maasify(server.client, attrs)
+		}
+	}
+	for i, attrs := range fixture.Zones {
+		name, ok := attrs["name"].(string)
+		if !ok {
+			return errors.Errorf("zone %d has no name", i)
+		}
+		server.zones[name] = maasify(server.client, attrs)
+	}
+	for uuid, images := range fixture.BootImages {
+		server.bootImages[uuid] = maasifyList(server.client, images)
+	}
+	for uuid, interfaces := range fixture.NodegroupInterfaces {
+		server.nodegroupsInterfaces[uuid] = maasifyList(server.client, interfaces)
+	}
+	for _, device := range fixture.Devices {
+		server.devices[device.SystemId] = device
+	}
+	for _, saved := range fixture.Subnets {
+		subnet := saved.TestSubnet
+		subnet.ResourceURI = fmt.Sprintf("%s%d/", getSubnetsEndpoint(server.version), subnet.ID)
+		for _, ip := range saved.InUseIPAddresses {
+			inUse := IPFromString(ip.IP)
+			inUse.Purpose = ip.Purpose
+			subnet.InUseIPAddresses = append(subnet.InUseIPAddresses, inUse)
+		}
+		for _, ar := range saved.FixedAddressRanges {
+			ar.startUint = IPFromString(ar.Start).UInt64()
+			ar.endUint = IPFromString(ar.End).UInt64()
+			subnet.FixedAddressRanges = append(subnet.FixedAddressRanges, ar)
+		}
+		server.subnets[subnet.ID] = subnet
+		server.subnetNameToID[subnet.Name] = subnet.ID
+		if subnet.ID >= server.nextSubnet {
+			server.nextSubnet = subnet.ID + 1
+		}
+	}
+	for _, saved := range fixture.Spaces {
+		server.spaces[saved.ID] = &TestSpace{
+			ID:          saved.ID,
+			Name:        saved.Name,
+			ResourceURI: fmt.Sprintf("/api/%s/spaces/%d/", server.version, saved.ID),
+		}
+		server.spaceNameToID[saved.Name] = saved.ID
+		if saved.ID >= server.nextSpace {
+			server.nextSpace = saved.ID + 1
+		}
+	}
+	for _, saved := range fixture.StaticRoutes {
+		server.staticRoutes[saved.ID] = &TestStaticRoute{
+			ID:              saved.ID,
+			ResourceURI:     fmt.Sprintf("/api/%s/static-routes/%d/", server.version, saved.ID),
+			sourceCIDR:      saved.SourceCIDR,
+			destinationCIDR: saved.DestinationCIDR,
+			GatewayIP:       saved.GatewayIP,
+			Metric:          saved.Metric,
+		}
+		if saved.ID >= server.nextStaticRoute {
+			server.nextStaticRoute = saved.ID + 1
+		}
+	}
+	return nil
+}
+
+func sortedKeys(objects map[string]MAASObject) []string {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func plainJSONLists(lists map[string][]JSONObject) map[string][]interface{} {
+	result := make(map[string][]interface{}, len(lists))
+	for key, list := range lists {
+		for _, obj := range list {
+			result[key] = append(result[key], plainJSON(obj))
+		}
+	}
+	return result
+}
+
+func maasifyList(client Client, values []interface{}) []JSONObject {
+	result := make([]JSONObject, len(values))
+	for i, value := range values {
+		result[i] = maasify(client, value)
+	}
+	return result
+}
+
+func copyStringMap(dst, src map[string]string) {
+	for key, value := range src {
+		dst[key] = value
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	jc "github.com/juju/testing/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (suite *TestServerSuite) populateForFixture() {
+	suite.server.NewNode(`{"system_id": "mysystemid", "hostname": "node-1"}`)
+	suite.server.NewFile("filename", []byte("file content"))
+	suite.server.AddZone("zone-a", "the first zone")
+	suite.server.AddDevice(&TestDevice{
+		SystemId:     "device-1",
+		MACAddresses: []string{"52:54:00:11:22:33"},
+		Hostname:     "device-host",
+	})
+	suite.server.NewSpace(spaceJSON(CreateSpace{Name: "space-a"}))
+	subnet := suite.server.NewSubnet(subnetJSON(newSubnetOnSpace("space-a", 3)))
+	suite.server.NewIPAddress("192.168.3.10", subnet.Name)
+	suite.server.NewStaticRoute(staticRouteJSON(CreateStaticRoute{
+		SourceCIDR:      subnet.CIDR,
+		DestinationCIDR: "10.0.0.0/8",
+		GatewayIP:       subnet.GatewayIP,
+		Metric:          10,
+	}))
+}
+
+func (suite *TestServerSuite) TestFixtureRoundTrip(c *C) {
+	suite.populateForFixture()
+	dir := c.MkDir()
+	filename := filepath.Join(dir, "fixture.json")
+	c.Assert(suite.server.SaveFixture(filename), jc.ErrorIsNil)
+
+	loaded := NewTestServer("2.0")
+	defer loaded.Close()
+	loaded.NewNode(`{"system_id": "stale"}`)
+	c.Assert(loaded.LoadFixture(filename), jc.ErrorIsNil)
+
+	c.Assert(loaded.nodes, HasLen, 1)
+	node := loaded.nodes["mysystemid"]
+	c.Check(node.URI().Path, Equals, "/api/2.0/nodes/mysystemid/")
+	hostname, err := node.GetField("hostname")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(hostname, Equals, "node-1")
+
+	file, err := loaded.files["filename"].GetField("content")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(file, Equals, "ZmlsZSBjb250ZW50")
+	c.Check(loaded.zones, HasLen, 1)
+	c.Check(loaded.devices["device-1"].Hostname, Equals, "device-host")
+	c.Assert(loaded.spaces, HasLen, 1)
+	c.Assert(loaded.subnets, HasLen, 1)
+	for _, subnet := range loaded.subnets {
+		c.Check(subnet.CIDR, Equals, "192.168.3.0/24")
+		c.Check(subnet.InUseIPAddresses, HasLen, 1)
+		c.Check(subnet.ResourceURI, Equals, fmt.Sprintf("/api/2.0/subnets/%d/", subnet.ID))
+	}
+	c.Check(loaded.staticRoutes, HasLen, 1)
+
+	// New objects do not reuse the IDs of the loaded ones.
+	space := loaded.NewSpace(spaceJSON(CreateSpace{Name: "space-b"}))
+	c.Check(loaded.spaces, HasLen, 2)
+	c.Check(space.Name, Equals, "space-b")
+
+	// The file only depends on the objects, not the server.
+	again := NewTestServer("1.0")
+	defer again.Close()
+	c.Assert(again.LoadFixture(filename), jc.ErrorIsNil)
+	for _, subnet := range again.subnets {
+		c.Check(subnet.ResourceURI, Equals, fmt.Sprintf("/api/1.0/subnets/%d/", subnet.ID))
+	}
+	other := filepath.Join(dir, "other.json")
+	c.Assert(again.SaveFixture(other), jc.ErrorIsNil)
+	first, err := ioutil.ReadFile(filename)
+	c.Assert(err, jc.ErrorIsNil)
+	second, err := ioutil.ReadFile(other)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(second), Equals, string(first))
+}
+
+func (suite *TestServerSuite) TestLoadFixtureErrors(c *C) {
+	dir := c.MkDir()
+	err := suite.server.LoadFixture(filepath.Join(dir, "missing.json"))
+	c.Check(err, ErrorMatches, ".*no such file or directory")
+
+	filename := filepath.Join(dir, "bad.json")
+	c.Assert(ioutil.WriteFile(filename, []byte("{"), 0644), jc.ErrorIsNil)
+	err = suite.server.LoadFixture(filename)
+	c.Check(err, ErrorMatches, `reading fixture ".*bad.json": .*`)
+}
@@ -0,0 +1,272 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// TransportOptions tunes the HTTP transport a Client uses to talk to the
+// MAAS region. Without options the Client opens a new connection for every
+// request, which is simple but slow for callers that make many requests per
+// second. Use DefaultTransportOptions as a starting point and adjust from
+// there.
+type TransportOptions struct {
+	// ForceAttemptHTTP2 makes the transport try HTTP/2 when connecting
+	// over TLS. Default: true.
+	ForceAttemptHTTP2 bool
+
+	// DisableKeepAlives closes each connection after a single request.
+	// Default: false.
+	DisableKeepAlives bool
+
+	// MaxIdleConns limits the total number of idle connections kept for
+	// reuse. Zero means no limit. Default: 100.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost limits the idle connections kept per host. A
+	// busy client talking to a single region should raise this from the
+	// net/http default of 2. Default: 16.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept before it is
+	// closed. Zero means no limit. Default: 90 seconds.
+	IdleConnTimeout time.Duration
+
+	// TLSHandshakeTimeout bounds the TLS handshake. Zero means no limit.
+	// Default: 10 seconds.
+	TLSHandshakeTimeout time.Duration
+
+	// TLSSessionCacheSize is the number of TLS sessions cached for
+	// resumption. Zero disables the cache. Default: 64.
+	TLSSessionCacheSize int
+
+	// DialTimeout bounds establishing the TCP connection. Zero means no
+	// limit. Default: 30 seconds.
+	DialTimeout time.Duration
+
+	// KeepAlive is the interval between TCP keep-alive probes. Zero means
+	// the default of the net package, currently 15 seconds. Default: 30
+	// seconds.
+	KeepAlive time.Duration
+
+	// DialContext, if not nil, opens the connections to the region, or to
+	// the proxy, in place of a net.Dialer, for regions that can only be
+	// reached through a bastion host. DialTimeout and KeepAlive are not
+	// used with it. See TunnelDialContext. Default: nil.
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// PreferIPv6, if true, connects to the IPv6 addresses of a host name
+	// that has both IPv6 and IPv4 addresses before trying the IPv4 ones.
+	// It is not used with DialContext or UnixSocket. Default: false.
+	PreferIPv6 bool
+
+	// UnixSocket, if not empty, is the path of a unix socket that every
+	// connection is opened to, whatever the host of the URL, for regions
+	// that listen locally, as in snap-confined setups and test harnesses.
+	// It cannot be used with DialContext or ProxyURL, and the proxy
+	// environment variables are ignored. Default: empty.
+	UnixSocket string
+
+	// ProxyURL, if not empty, is the proxy the requests are sent through,
+	// such as "http://jumphost.dc1:3128" or "socks5://localhost:1080". It
+	// takes the place of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables, so clients in one process can use different
+	// proxies. Default: empty, which uses the environment.
+	ProxyURL string
+}
+
+// DefaultTransportOptions returns the recommended options for a client
+// making frequent requests to a region.
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSSessionCacheSize: 64,
+		DialTimeout:         30 * time.Second,
+		KeepAlive:           30 * time.Second,
+	}
+}
+
+// Validate ensures that none of the limits or timeouts are negative.
+func (o *TransportOptions) Validate() error {
+	if o.MaxIdleConns < 0 {
+		return errors.NotValidf("negative MaxIdleConns")
+	}
+	if o.MaxIdleConnsPerHost < 0 {
+		return errors.NotValidf("negative MaxIdleConnsPerHost")
+	}
+	if o.IdleConnTimeout < 0 {
+		return errors.NotValidf("negative IdleConnTimeout")
+	}
+	if o.TLSHandshakeTimeout < 0 {
+		return errors.NotValidf("negative TLSHandshakeTimeout")
+	}
+	if o.TLSSessionCacheSize < 0 {
+		return errors.NotValidf("negative TLSSessionCacheSize")
+	}
+	if o.DialTimeout < 0 {
+		return errors.NotValidf("negative DialTimeout")
+	}
+	if o.KeepAlive < 0 {
+		return errors.NotValidf("negative KeepAlive")
+	}
+	if _, err := o.proxyURL(); err != nil {
+		return errors.Trace(err)
+	}
+	if o.UnixSocket != "" && (o.DialContext != nil || o.ProxyURL != "") {
+		return errors.NotValidf("UnixSocket with DialContext or ProxyURL")
+	}
+	return nil
+}
+
+// proxyURL returns the parsed ProxyURL, or nil if it is empty.
+func (o *TransportOptions) proxyURL() (*url.URL, error) {
+	if o.ProxyURL == "" {
+		return nil, nil
+	}
+	proxy, err := url.Parse(o.ProxyURL)
+	if err != nil {
+		return nil, errors.NotValidf("ProxyURL")
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.NotValidf("ProxyURL scheme %q", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return nil, errors.NotValidf("ProxyURL %q without a host", proxy.Redacted())
+	}
+	return proxy, nil
+}
+
+// NewHTTPClient returns an http.Client whose transport is configured from
+// the options. Set the result as the HTTPClient of a Client to use it.
+func NewHTTPClient(options TransportOptions) (*http.Client, error) {
+	if err := options.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	dialer := &net.Dialer{
+		Timeout:   options.DialTimeout,
+		KeepAlive: options.KeepAlive,
+	}
+	proxy := http.ProxyFromEnvironment
+	if proxyURL, _ := options.proxyURL(); proxyURL != nil {
+		proxy = http.ProxyURL(proxyURL)
+	}
+	dial := dialer.DialContext
+	if options.PreferIPv6 {
+		dial = preferIPv6(dialer.DialContext, net.DefaultResolver.LookupIPAddr)
+	}
+	if options.DialContext != nil {
+		dial = options.DialContext
+	}
+	if options.UnixSocket != "" {
+		proxy = nil
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", options.UnixSocket)
+		}
+	}
+	transport := &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dial,
+		ForceAttemptHTTP2:   options.ForceAttemptHTTP2,
+		DisableKeepAlives:   options.DisableKeepAlives,
+		MaxIdleConns:        options.MaxIdleConns,
+		MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
+		IdleConnTimeout:     options.IdleConnTimeout,
+		TLSHandshakeTimeout: options.TLSHandshakeTimeout,
+	}
+	if options.TLSSessionCacheSize > 0 {
+		transport.TLSClientConfig = &tls.Config{
+			ClientSessionCache: tls.NewLRUClientSessionCache(options.TLSSessionCacheSize),
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// preferIPv6 returns a dial function that looks up the addresses of a host
+// name itself, and tries them with dial, the IPv6 ones first, returning the
+// first connection made or the last error.
+func preferIPv6(
+	dial func(ctx context.Context, network, address string) (net.Conn, error),
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error),
+) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, address)
+		}
+		addrs, err := lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(addrs, func(i, j int) bool {
+			return addrs[i].IP.To4() == nil && addrs[j].IP.To4() != nil
+		})
+		err = errors.Errorf("no addresses for %q", host)
+		for _, addr := range addrs {
+			var conn net.Conn
+			if conn, err = dial(ctx, network, net.JoinHostPort(addr.String(), port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// Dialer opens connections. It is implemented by the *ssh.Client of
+// golang.org/x/crypto/ssh, which opens them from the SSH server, and by the
+// SOCKS5 dialer of golang.org/x/net/proxy.
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// TunnelDialContext returns a function for the DialContext of
+// TransportOptions that opens connections with the dialer, such as an SSH
+// client connected to a bastion host. If the dialer has a DialContext
+// method it is used. Otherwise, when the context is done before Dial
+// returns, the dial is abandoned and its connection closed once it is
+// made.
+func TunnelDialContext(dialer Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	type contextDialer interface {
+		DialContext(ctx context.Context, network, address string) (net.Conn, error)
+	}
+	if withContext, ok := dialer.(contextDialer); ok {
+		return withContext.DialContext
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		type dialed struct {
+			conn net.Conn
+			err  error
+		}
+		done := make(chan dialed, 1)
+		go func() {
+			conn, err := dialer.Dial(network, address)
+			done <- dialed{conn, err}
+		}()
+		select {
+		case result := <-done:
+			return result.conn, result.err
+		case <-ctx.Done():
+			go func() {
+				if result := <-done; result.conn != nil {
+					result.conn.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type transportSuite struct{}
+
+var _ = gc.Suite(&transportSuite{})
+
+func (*transportSuite) TestDefaultTransportOptions(c *gc.C) {
+	options := DefaultTransportOptions()
+	c.Check(options.Validate(), jc.ErrorIsNil)
+	c.Check(options.ForceAttemptHTTP2, jc.IsTrue)
+	c.Check(options.DisableKeepAlives, jc.IsFalse)
+	c.Check(options.MaxIdleConnsPerHost, gc.Equals, 16)
+	c.Check(options.IdleConnTimeout, gc.Equals, 90*time.Second)
+}
+
+func (*transportSuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		options TransportOptions
+		errText string
+	}{{
+		options: TransportOptions{MaxIdleConns: -1},
+		errText: "negative MaxIdleConns not valid",
+	}, {
+		options: TransportOptions{MaxIdleConnsPerHost: -1},
+		errText: "negative MaxIdleConnsPerHost not valid",
+	}, {
+		options: TransportOptions{IdleConnTimeout: -time.Second},
+		errText: "negative IdleConnTimeout not valid",
+	}, {
+		options: TransportOptions{TLSSessionCacheSize: -1},
+		errText: "negative TLSSessionCacheSize not valid",
+	}, {
+		options: TransportOptions{ProxyURL: "ftp://proxy:21"},
+		errText: `ProxyURL scheme "ftp" not valid`,
+	}, {
+		options: TransportOptions{ProxyURL: "http://user:secret@/"},
+		errText: `ProxyURL "http://user:xxxxx@/" without a host not valid`,
+	}, {
+		options: TransportOptions{ProxyURL: "socks5://localhost:1080"},
+	}, {
+		options: TransportOptions{},
+	}} {
+		c.Logf("test %d", i)
+		err := test.options.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (*transportSuite) TestNewHTTPClient(c *gc.C) {
+	httpClient, err := NewHTTPClient(DefaultTransportOptions())
+	c.Assert(err, jc.ErrorIsNil)
+	transport := httpClient.Transport.(*http.Transport)
+	c.Check(transport.ForceAttemptHTTP2, jc.IsTrue)
+	c.Check(transport.MaxIdleConns, gc.Equals, 100)
+	c.Check(transport.MaxIdleConnsPerHost, gc.Equals, 16)
+	c.Check(transport.IdleConnTimeout, gc.Equals, 90*time.Second)
+	c.Check(transport.TLSHandshakeTimeout, gc.Equals, 10*time.Second)
+	c.Assert(transport.TLSClientConfig, gc.NotNil)
+	c.Check(transport.TLSClientConfig.ClientSessionCache, gc.NotNil)
+}
+
+func (*transportSuite) TestNewHTTPClientNoSessionCache(c *gc.C) {
+	httpClient, err := NewHTTPClient(TransportOptions{})
+	c.Assert(err, jc.ErrorIsNil)
+	transport := httpClient.Transport.(*http.Transport)
+	c.Check(transport.TLSClientConfig, gc.IsNil)
+}
+
+func (*transportSuite) TestNewHTTPClientValidates(c *gc.C) {
+	_, err := NewHTTPClient(TransportOptions{DialTimeout: -time.Second})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+// countingServer returns a server that reports the number of connections
+// made to it.
+func countingServer() (*httptest.Server, *int32) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, `"ok"`)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	return server, &connections
+}
+
+func (*transportSuite) TestClientReusesConnections(c *gc.C) {
+	server, connections := countingServer()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.HTTPClient, err = NewHTTPClient(DefaultTransportOptions())
+	c.Assert(err, jc.ErrorIsNil)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Get(&url.URL{Path: "version/"}, "", nil)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	c.Assert(atomic.LoadInt32(connections), gc.Equals, int32(1))
+}
+
+func (*transportSuite) TestClientWithoutHTTPClientClosesConnections(c *gc.C) {
+	server, connections := countingServer()
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Get(&url.URL{Path: "version/"}, "", nil)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	c.Assert(atomic.LoadInt32(connections), gc.Equals, int32(3))
+}
+
+func (*transportSuite) TestNewHTTPClientProxyURL(c *gc.C) {
+	options := DefaultTransportOptions()
+	options.ProxyURL = "http://jumphost.dc1:3128"
+	httpClient, err := NewHTTPClient(options)
+	c.Assert(err, jc.ErrorIsNil)
+	transport := httpClient.Transport.(*http.Transport)
+	request, err := http.NewRequest("GET", "http://maas.dc1/MAAS/api/2.0/version/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	proxy, err := transport.Proxy(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(proxy.String(), gc.Equals, "http://jumphost.dc1:3128")
+}
+
+func (*transportSuite) TestControllerProxyURL(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	target, err := url.Parse(server.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	var hosts []string
+	forward := httputil.NewSingleHostReverseProxy(target)
+	proxy := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		hosts = append(hosts, request.URL.Host)
+		forward.ServeHTTP(writer, request)
+	}))
+	defer proxy.Close()
+
+	_, err = NewController(ControllerArgs{
+		BaseURL:  "http://maas.dc1/",
+		APIKey:   "fake:as:key",
+		ProxyURL: proxy.URL,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(hosts, jc.DeepEquals, []string{"maas.dc1", "maas.dc1"})
+}
+
+func (*transportSuite) TestControllerProxyURLInvalid(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:  "http://maas.dc1/",
+		APIKey:   "fake:as:key",
+		ProxyURL: "gopher://proxy",
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+// redirectDialer connects to its target whatever the address, as a bastion
+// host would, and records the addresses.
+type redirectDialer struct {
+	target    string
+	addresses []string
+	block     chan struct{}
+}
+
+func (d *redirectDialer) Dial(network, address string) (net.Conn, error) {
+	if d.block != nil {
+		<-d.block
+	}
+	d.addresses = append(d.addresses, address)
+	return net.Dial(network, d.target)
+}
+
+func (*transportSuite) TestTunnelDialContext(c *gc.C) {
+	server, connections := countingServer()
+	defer server.Close()
+	dialer := &redirectDialer{target: server.Listener.Addr().String()}
+	options := DefaultTransportOptions()
+	options.DialContext = TunnelDialContext(dialer)
+	client, err := NewAnonymousClient("http://maas.internal:5240/MAAS/", "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.HTTPClient, err = NewHTTPClient(options)
+	c.Assert(err, jc.ErrorIsNil)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(&url.URL{Path: "version/"}, "", nil)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	c.Check(dialer.addresses, jc.DeepEquals, []string{"maas.internal:5240"})
+	c.Check(atomic.LoadInt32(connections), gc.Equals, int32(1))
+}
+
+func (*transportSuite) TestTunnelDialContextCancelled(c *gc.C) {
+	server, connections := countingServer()
+	defer server.Close()
+	dialer := &redirectDialer{target: server.Listener.Addr().String(), block: make(chan struct{})}
+	dial := TunnelDialContext(dialer)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := dial(ctx, "tcp", "maas.internal:5240")
+	c.Check(err, gc.Equals, context.Canceled)
+
+	// The connection made after the dial was abandoned is closed.
+	close(dialer.block)
+	for i := 0; i < 500 && atomic.LoadInt32(connections) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	c.Check(atomic.LoadInt32(connections), gc.Equals, int32(1))
+}
+
+type contextDialer struct {
+	redirectDialer
+	contexts int
+}
+
+func (d *contextDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.contexts++
+	return d.Dial(network, address)
+}
+
+func (*transportSuite) TestTunnelDialContextUsesDialContext(c *gc.C) {
+	server, _ := countingServer()
+	defer server.Close()
+	dialer := &contextDialer{redirectDialer: redirectDialer{target: server.Listener.Addr().String()}}
+	conn, err := TunnelDialContext(dialer)(context.Background(), "tcp", "maas.internal:5240")
+	c.Assert(err, jc.ErrorIsNil)
+	conn.Close()
+	c.Check(dialer.contexts, gc.Equals, 1)
+}
+
+func (*transportSuite) TestValidateUnixSocket(c *gc.C) {
+	options := TransportOptions{UnixSocket: "/run/maas.sock", ProxyURL: "http://proxy:3128"}
+	c.Check(options.Validate(), gc.ErrorMatches, "UnixSocket with DialContext or ProxyURL not valid")
+	options = TransportOptions{UnixSocket: "/run/maas.sock", DialContext: (&net.Dialer{}).DialContext}
+	c.Check(options.Validate(), jc.Satisfies, errors.IsNotValid)
+}
+
+func (*transportSuite) TestNewHTTPClientUnixSocketIgnoresProxy(c *gc.C) {
+	options := DefaultTransportOptions()
+	options.UnixSocket = "/run/maas.sock"
+	httpClient, err := NewHTTPClient(options)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(httpClient.Transport.(*http.Transport).Proxy, gc.IsNil)
+}
+
+func (*transportSuite) TestControllerUnixSocket(c *gc.C) {
+	socket := filepath.Join(c.MkDir(), "maas.sock")
+	listener, err := net.Listen("unix", socket)
+	c.Assert(err, jc.ErrorIsNil)
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	server.Server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	options := DefaultTransportOptions()
+	options.UnixSocket = socket
+	maas, err := NewController(ControllerArgs{
+		BaseURL:   "http://maas.local/",
+		APIKey:    "fake:as:key",
+		Transport: &options,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	zones, err := maas.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(zones, gc.HasLen, 2)
+	c.Check(server.LastRequest().Host, gc.Equals, "maas.local")
+}
+
+// countingRoundTripper counts the requests it passes on.
+type countingRoundTripper struct {
+	requests int32
+}
+
+func (t *countingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func (*transportSuite) TestControllerHTTPClient(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	transport := &countingRoundTripper{}
+	_, err := NewController(ControllerArgs{
+		BaseURL:    server.URL,
+		APIKey:     "fake:as:key",
+		HTTPClient: &http.Client{Transport: transport},
+		ProxyURL:   "gopher://ignored",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(atomic.LoadInt32(&transport.requests), gc.Equals, int32(2))
+}
+
+func (*transportSuite) TestPreferIPv6(c *gc.C) {
+	var dialed []string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return nil, errors.New("refused")
+	}
+	lookup := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		c.Check(host, gc.Equals, "maas.example.com")
+		return []net.IPAddr{
+			{IP: net.ParseIP("192.0.2.1")},
+			{IP: net.ParseIP("2001:db8::1")},
+			{IP: net.ParseIP("192.0.2.2")},
+			{IP: net.ParseIP("fe80::1"), Zone: "eth0"},
+		}, nil
+	}
+	_, err := preferIPv6(dial, lookup)(context.Background(), "tcp", "maas.example.com:5240")
+	c.Check(err, gc.ErrorMatches, "refused")
+	c.Check(dialed, jc.DeepEquals, []string{
+		"[2001:db8::1]:5240",
+		"[fe80::1%eth0]:5240",
+		"192.0.2.1:5240",
+		"192.0.2.2:5240",
+	})
+
+	// Addresses are dialed as they are.
+	dialed = nil
+	preferIPv6(dial, lookup)(context.Background(), "tcp", "[2001:db8::2]:5240")
+	c.Check(dialed, jc.DeepEquals, []string{"[2001:db8::2]:5240"})
+}
+
+func (*transportSuite) TestPreferIPv6FallsBackToIPv4(c *gc.C) {
+	server, _ := countingServer()
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	c.Assert(err, jc.ErrorIsNil)
+	var dialer net.Dialer
+	lookup := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("::1")}}, nil
+	}
+	conn, err := preferIPv6(dialer.DialContext, lookup)(context.Background(), "tcp", "maas.local:"+port)
+	c.Assert(err, jc.ErrorIsNil)
+	defer conn.Close()
+	c.Check(conn.RemoteAddr().String(), gc.Equals, server.Listener.Addr().String())
+}
+
+func (*transportSuite) TestNewHTTPClientPreferIPv6(c *gc.C) {
+	server, _ := countingServer()
+	defer server.Close()
+	options := DefaultTransportOptions()
+	options.PreferIPv6 = true
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.HTTPClient, err = NewHTTPClient(options)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+	c.Assert(err, jc.ErrorIsNil)
+}
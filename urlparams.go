@@ -46,3 +46,80 @@ func (p *URLParams) MaybeAddMany(name string, values []string) {
 		p.MaybeAdd(name, value)
 	}
 }
+
+// Param is a single name and value of an OrderedParams.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// OrderedParams is a list of parameters that are sent in the order they
+// were added, including between different names. url.Values keeps the
+// order of the values of each name, but not the order of the names, and
+// form encoding sorts them. Use OrderedParams for ops that pair repeated
+// parameters by position, or to control exactly what the server sees.
+type OrderedParams struct {
+	params []Param
+}
+
+// NewOrderedParams returns an empty OrderedParams.
+func NewOrderedParams() *OrderedParams {
+	return &OrderedParams{}
+}
+
+// Add appends the (name, value) pair.
+func (p *OrderedParams) Add(name, value string) {
+	p.params = append(p.params, Param{Name: name, Value: value})
+}
+
+// AddMany appends a (name, value) pair for each of the values.
+func (p *OrderedParams) AddMany(name string, values ...string) {
+	for _, value := range values {
+		p.Add(name, value)
+	}
+}
+
+// MaybeAdd appends the (name, value) pair iff value is not empty.
+func (p *OrderedParams) MaybeAdd(name, value string) {
+	if value != "" {
+		p.Add(name, value)
+	}
+}
+
+// Len returns the number of (name, value) pairs.
+func (p *OrderedParams) Len() int {
+	return len(p.params)
+}
+
+// Params returns a copy of the (name, value) pairs in order.
+func (p *OrderedParams) Params() []Param {
+	return append([]Param(nil), p.params...)
+}
+
+// Values returns the parameters as url.Values, losing the order between
+// names.
+func (p *OrderedParams) Values() url.Values {
+	values := make(url.Values)
+	for _, param := range p.params {
+		values.Add(param.Name, param.Value)
+	}
+	return values
+}
+
+// Encode returns the parameters form encoded in order.
+func (p *OrderedParams) Encode() string {
+	return string(p.appendEncoded(nil))
+}
+
+// appendEncoded appends the Encode encoding of the parameters to dst.
+func (p *OrderedParams) appendEncoded(dst []byte) []byte {
+	for i, param := range p.params {
+		if i > 0 {
+			dst = append(dst, '&')
+		}
+		dst = appendPercentEncoded(dst, param.Name)
+		dst = append(dst, '=')
+		dst = appendPercentEncoded(dst, param.Value)
+	}
+	return dst
+}
@@ -65,3 +65,23 @@ func (*urlParamsSuite) TestNewMaybeAddManyValues(c *gc.C) {
 	params.MaybeAddMany("foo", []string{"two", "", "values"})
 	c.Assert(params.Values.Encode(), gc.Equals, "foo=two&foo=values")
 }
+
+func (*urlParamsSuite) TestOrderedParamsKeepOrder(c *gc.C) {
+	params := gomaasapi.NewOrderedParams()
+	params.Add("not_tags", "virtual")
+	params.Add("mac_addresses", "52:54:00:00:00:01")
+	params.AddMany("not_tags", "gpu", "arm")
+	params.MaybeAdd("zone", "")
+	params.MaybeAdd("mac_addresses", "52:54:00:00:00:02")
+	c.Assert(params.Len(), gc.Equals, 5)
+	c.Assert(params.Encode(), gc.Equals,
+		"not_tags=virtual&mac_addresses=52%3A54%3A00%3A00%3A00%3A01&not_tags=gpu&not_tags=arm&mac_addresses=52%3A54%3A00%3A00%3A00%3A02")
+	c.Assert(params.Params()[1], gc.Equals, gomaasapi.Param{Name: "mac_addresses", Value: "52:54:00:00:00:01"})
+	c.Assert(params.Values()["not_tags"], gc.DeepEquals, []string{"virtual", "gpu", "arm"})
+}
+
+func (*urlParamsSuite) TestOrderedParamsEmpty(c *gc.C) {
+	params := gomaasapi.NewOrderedParams()
+	c.Assert(params.Encode(), gc.Equals, "")
+	c.Assert(params.Values(), gc.HasLen, 0)
+}
@@ -10,6 +10,8 @@ import (
 )
 
 type vlan struct {
+	rawJSON
+
 	// Add the controller in when we need to do things with the vlan.
 	// controller Controller
 
@@ -25,6 +27,9 @@ type vlan struct {
 
 	primaryRack   string
 	secondaryRack string
+
+	// expandedFabric is only set when references were expanded.
+	expandedFabric *fabric
 }
 
 // ID implements VLAN.
@@ -42,6 +47,14 @@ func (v *vlan) Fabric() string {
 	return v.fabric
 }
 
+// ExpandedFabric implements VLAN.
+func (v *vlan) ExpandedFabric() Fabric {
+	if v.expandedFabric == nil {
+		return nil
+	}
+	return v.expandedFabric
+}
+
 // VID implements VLAN.
 func (v *vlan) VID() int {
 	return v.vid
@@ -75,6 +88,29 @@ func readVLANs(controllerVersion version.Number, source interface{}) ([]*vlan, e
 	}
 	valid := coerced.([]interface{})
 
+	readFunc, err := getVLANDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return readVLANList(valid, readFunc)
+}
+
+func readVLAN(controllerVersion version.Number, source interface{}) (*vlan, error) {
+	readFunc, err := getVLANDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "vlan base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func getVLANDeserializationFunc(controllerVersion version.Number) (vlanDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range vlanDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -84,8 +120,7 @@ func readVLANs(controllerVersion version.Number, source interface{}) ([]*vlan, e
 	if deserialisationVersion == version.Zero {
 		return nil, errors.Errorf("no vlan read func for version %s", controllerVersion)
 	}
-	readFunc := vlanDeserializationFuncs[deserialisationVersion]
-	return readVLANList(valid, readFunc)
+	return vlanDeserializationFuncs[deserialisationVersion], nil
 }
 
 func readVLANList(sourceList []interface{}, readFunc vlanDeserializationFunc) ([]*vlan, error) {
@@ -112,16 +147,16 @@ var vlanDeserializationFuncs = map[version.Number]vlanDeserializationFunc{
 
 func vlan_2_0(source map[string]interface{}) (*vlan, error) {
 	fields := schema.Fields{
-		"id":           schema.ForceInt(),
-		"resource_uri": schema.String(),
-		"name":         schema.OneOf(schema.Nil(""), schema.String()),
-		"fabric":       schema.String(),
-		"vid":          schema.ForceInt(),
-		"mtu":          schema.ForceInt(),
-		"dhcp_on":      schema.Bool(),
+		"id":           intField(),
+		"resource_uri": stringField(),
+		"name":         nullable(stringField()),
+		"fabric":       stringField(),
+		"vid":          intField(),
+		"mtu":          intField(),
+		"dhcp_on":      boolField(),
 		// racks are not always set.
-		"primary_rack":   schema.OneOf(schema.Nil(""), schema.String()),
-		"secondary_rack": schema.OneOf(schema.Nil(""), schema.String()),
+		"primary_rack":   nullable(stringField()),
+		"secondary_rack": nullable(stringField()),
 	}
 	checker := schema.FieldMap(fields, nil)
 	coerced, err := checker.Coerce(source, nil)
@@ -140,6 +175,7 @@ func vlan_2_0(source map[string]interface{}) (*vlan, error) {
 	name, _ := valid["name"].(string)
 
 	result := &vlan{
+		rawJSON:       rawJSON{source},
 		resourceURI:   valid["resource_uri"].(string),
 		id:            valid["id"].(int),
 		name:          name,
@@ -0,0 +1,304 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// VMHostType is the kind of hypervisor a VM host runs.
+type VMHostType string
+
+const (
+	// VMHostKVM is a libvirt KVM host, installed with install_kvm.
+	VMHostKVM VMHostType = "virsh"
+
+	// VMHostLXD is an LXD host, installed with register_vmhost. It needs
+	// MAAS 2.9 or later.
+	VMHostLXD VMHostType = "lxd"
+)
+
+// Validate ensures that the type is empty or a known one.
+func (t VMHostType) Validate() error {
+	switch t {
+	case "", VMHostKVM, VMHostLXD:
+		return nil
+	}
+	return errors.NotValidf("VM host type %q", string(t))
+}
+
+// vmHostPollInterval is how often DeployVMHost looks for the VM host
+// once the machine is deployed.
+var vmHostPollInterval = 15 * time.Second
+
+type vmHost struct {
+	rawJSON
+
+	controller *controller
+
+	resourceURI string
+
+	id           int
+	name         string
+	hostType     string
+	hostSystemID string
+	tags         []string
+	numaNodes    []VMHostNUMANode
+}
+
+// VMHostNUMANode describes the cores and hugepages of a NUMA node of a VM
+// host, as reported by MAAS 2.9 and later.
+type VMHostNUMANode struct {
+	NodeID         int
+	FreeCores      []int
+	AllocatedCores []int
+	Hugepages      []VMHostHugepages
+}
+
+// VMHostHugepages describes the hugepages of one page size on a NUMA node.
+// The sizes are in bytes.
+type VMHostHugepages struct {
+	PageSize  uint64
+	Allocated uint64
+	Free      uint64
+}
+
+// ID implements VMHost.
+func (h *vmHost) ID() int {
+	return h.id
+}
+
+// Name implements VMHost.
+func (h *vmHost) Name() string {
+	return h.name
+}
+
+// Type implements VMHost.
+func (h *vmHost) Type() string {
+	return h.hostType
+}
+
+// HostSystemID implements VMHost.
+func (h *vmHost) HostSystemID() string {
+	return h.hostSystemID
+}
+
+// Tags implements VMHost.
+func (h *vmHost) Tags() []string {
+	return h.tags
+}
+
+// NUMANodes implements VMHost.
+func (h *vmHost) NUMANodes() []VMHostNUMANode {
+	return h.numaNodes
+}
+
+// VMHosts implements Controller.
+func (c *controller) VMHosts() ([]VMHost, error) {
+	source, err := c.get("pods")
+	if err != nil {
+		return nil, NewUnexpectedError(err)
+	}
+	hosts, err := readVMHosts(c.apiVersion, c.markLeaves(source))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []VMHost
+	for _, h := range hosts {
+		h.controller = c
+		result = append(result, h)
+	}
+	return result, nil
+}
+
+// DeployVMHost implements Controller.
+func (c *controller) DeployVMHost(ctx context.Context, machine Machine, args StartArgs) (VMHost, error) {
+	if args.VMHost == "" {
+		return nil, NewArgumentError("VMHost", "no VM host type")
+	}
+	if err := machine.Start(args); err != nil {
+		return nil, errors.Annotatef(err, "starting machine %s", machine.SystemID())
+	}
+	deployed, err := c.waitForDeployment(ctx, machine)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return c.waitForVMHost(ctx, deployed.SystemID())
+}
+
+// waitForVMHost polls the VM hosts until there is one on the machine, or
+// the context is done.
+func (c *controller) waitForVMHost(ctx context.Context, systemID string) (VMHost, error) {
+	for {
+		hosts, err := c.VMHosts()
+		if err != nil {
+			return nil, errors.Annotatef(err, "waiting for VM host on %s", systemID)
+		}
+		for _, host := range hosts {
+			if host.HostSystemID() == systemID {
+				return host, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Annotatef(ctx.Err(), "waiting for VM host on %s", systemID)
+		case <-c.clock.After(vmHostPollInterval):
+		}
+	}
+}
+
+func readVMHosts(controllerVersion version.Number, source interface{}) ([]*vmHost, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, errors.Annotatef(err, "VM host base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range vmHostDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, errors.Errorf("no VM host read func for version %s", controllerVersion)
+	}
+	readFunc := vmHostDeserializationFuncs[deserialisationVersion]
+	return readVMHostList(valid, readFunc)
+}
+
+// readVMHostList expects the values of the sourceList to be string maps.
+func readVMHostList(sourceList []interface{}, readFunc vmHostDeserializationFunc) ([]*vmHost, error) {
+	result := make([]*vmHost, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("unexpected value for VM host %d, %T", i, value)
+		}
+		host, err := readFunc(source)
+		if err != nil {
+			return nil, errors.Annotatef(err, "VM host %d", i)
+		}
+		result = append(result, host)
+	}
+	return result, nil
+}
+
+type vmHostDeserializationFunc func(map[string]interface{}) (*vmHost, error)
+
+var vmHostDeserializationFuncs = map[version.Number]vmHostDeserializationFunc{
+	twoDotOh: vmHost_2_0,
+}
+
+func vmHost_2_0(source map[string]interface{}) (*vmHost, error) {
+	fields := schema.Fields{
+		"resource_uri": stringField(),
+		"id":           intField(),
+		"name":         stringField(),
+		"type":         stringField(),
+		// The host is null for VM hosts that were added by address
+		// rather than deployed from a machine.
+		"host": nullable(schema.StringMap(schema.Any())),
+		"tags": schema.List(stringField()),
+		// MAAS 2.9 added the NUMA layout.
+		"numa_pinning": schema.List(schema.StringMap(schema.Any())),
+	}
+	defaults := schema.Defaults{
+		"host":         nil,
+		"tags":         []interface{}{},
+		"numa_pinning": []interface{}{},
+	}
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "VM host 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var hostSystemID string
+	if host := valid["host"]; host != nil {
+		hostChecker := schema.FieldMap(schema.Fields{"system_id": stringField()}, nil)
+		coerced, err := hostChecker.Coerce(host, nil)
+		if err != nil {
+			return nil, WrapWithDeserializationError(err, "VM host 2.0 host schema check failed")
+		}
+		hostSystemID = coerced.(map[string]interface{})["system_id"].(string)
+	}
+	var numaNodes []VMHostNUMANode
+	for i, value := range valid["numa_pinning"].([]interface{}) {
+		node, err := readVMHostNUMANode(value.(map[string]interface{}))
+		if err != nil {
+			return nil, errors.Annotatef(err, "NUMA node %d", i)
+		}
+		numaNodes = append(numaNodes, node)
+	}
+	result := &vmHost{
+		rawJSON:      rawJSON{source},
+		resourceURI:  valid["resource_uri"].(string),
+		id:           valid["id"].(int),
+		name:         valid["name"].(string),
+		hostType:     valid["type"].(string),
+		hostSystemID: hostSystemID,
+		tags:         convertToStringSlice(valid["tags"]),
+		numaNodes:    numaNodes,
+	}
+	return result, nil
+}
+
+func readVMHostNUMANode(source map[string]interface{}) (VMHostNUMANode, error) {
+	hugepages := schema.FieldMap(schema.Fields{
+		"page_size": uintField(),
+		"allocated": uintField(),
+		"free":      uintField(),
+	}, nil)
+	fields := schema.Fields{
+		"node_id": intField(),
+		"cores": schema.FieldMap(schema.Fields{
+			"allocated": schema.List(intField()),
+			"free":      schema.List(intField()),
+		}, nil),
+		"memory": schema.FieldMap(schema.Fields{
+			"hugepages": schema.List(hugepages),
+		}, schema.Defaults{"hugepages": []interface{}{}}),
+	}
+	checker := schema.FieldMap(fields, nil)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return VMHostNUMANode{}, WrapWithDeserializationError(err, "VM host NUMA node schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	cores := valid["cores"].(map[string]interface{})
+	node := VMHostNUMANode{
+		NodeID:         valid["node_id"].(int),
+		FreeCores:      convertToIntSlice(cores["free"]),
+		AllocatedCores: convertToIntSlice(cores["allocated"]),
+	}
+	memory := valid["memory"].(map[string]interface{})
+	for _, value := range memory["hugepages"].([]interface{}) {
+		pages := value.(map[string]interface{})
+		node.Hugepages = append(node.Hugepages, VMHostHugepages{
+			PageSize:  pages["page_size"].(uint64),
+			Allocated: pages["allocated"].(uint64),
+			Free:      pages["free"].(uint64),
+		})
+	}
+	return node, nil
+}
+
+func convertToIntSlice(field interface{}) []int {
+	values := field.([]interface{})
+	result := make([]int, len(values))
+	for i, value := range values {
+		result[i] = value.(int)
+	}
+	return result
+}
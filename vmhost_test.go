@@ -0,0 +1,189 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type vmHostSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&vmHostSuite{})
+
+func (s *vmHostSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.PatchValue(&deployPollInterval, time.Millisecond)
+	s.PatchValue(&vmHostPollInterval, time.Millisecond)
+}
+
+const vmHostsResponse = `
+[
+    {
+        "id": 1,
+        "name": "gentle-eel",
+        "type": "virsh",
+        "resource_uri": "/MAAS/api/2.0/pods/1/",
+        "host": {"system_id": "4y3ha3", "__incomplete__": true},
+        "tags": ["pod-console-logging"],
+        "total": {"cores": 8, "memory": 16384, "local_storage": 100000000000},
+        "numa_pinning": [
+            {
+                "node_id": 0,
+                "cores": {"allocated": [0, 1], "free": [2, 3]},
+                "memory": {
+                    "general": {"allocated": 1073741824, "free": 3221225472},
+                    "hugepages": [{"page_size": 2097152, "allocated": 0, "free": 2147483648}]
+                }
+            },
+            {
+                "node_id": 1,
+                "cores": {"allocated": [], "free": [4, 5, 6, 7]},
+                "memory": {"general": {"allocated": 0, "free": 4294967296}}
+            }
+        ]
+    },
+    {
+        "id": 2,
+        "name": "by-address",
+        "type": "lxd",
+        "resource_uri": "/MAAS/api/2.0/pods/2/",
+        "host": null
+    }
+]
+`
+
+func (*vmHostSuite) TestReadVMHosts(c *gc.C) {
+	hosts, err := readVMHosts(twoDotOh, parseJSON(c, vmHostsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(hosts, gc.HasLen, 2)
+	host := hosts[0]
+	c.Check(host.ID(), gc.Equals, 1)
+	c.Check(host.Name(), gc.Equals, "gentle-eel")
+	c.Check(host.Type(), gc.Equals, "virsh")
+	c.Check(host.HostSystemID(), gc.Equals, "4y3ha3")
+	c.Check(host.Tags(), jc.DeepEquals, []string{"pod-console-logging"})
+	c.Check(host.NUMANodes(), jc.DeepEquals, []VMHostNUMANode{{
+		NodeID:         0,
+		FreeCores:      []int{2, 3},
+		AllocatedCores: []int{0, 1},
+		Hugepages:      []VMHostHugepages{{PageSize: 2097152, Free: 2147483648}},
+	}, {
+		NodeID:         1,
+		FreeCores:      []int{4, 5, 6, 7},
+		AllocatedCores: []int{},
+	}})
+	c.Check(hosts[1].HostSystemID(), gc.Equals, "")
+	c.Check(hosts[1].NUMANodes(), gc.HasLen, 0)
+	c.Check(hosts[1].Tags(), gc.HasLen, 0)
+}
+
+func (*vmHostSuite) TestReadVMHostsStrict(c *gc.C) {
+	for _, mode := range []DecodeMode{DecodeStrict, DecodeLenient} {
+		hosts, err := readVMHosts(twoDotOh, markLeaves(parseJSON(c, vmHostsResponse), mode))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(hosts[0].HostSystemID(), gc.Equals, "4y3ha3")
+		c.Check(hosts[1].HostSystemID(), gc.Equals, "")
+	}
+}
+
+func (*vmHostSuite) TestReadVMHostsBadSchema(c *gc.C) {
+	_, err := readVMHosts(twoDotOh, parseJSON(c, `[{"id": "one"}]`))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (*vmHostSuite) TestVMHostTypeValidate(c *gc.C) {
+	c.Check(VMHostKVM.Validate(), jc.ErrorIsNil)
+	c.Check(VMHostType("").Validate(), jc.ErrorIsNil)
+	c.Check(VMHostType("esx").Validate(), gc.ErrorMatches, `VM host type "esx" not valid`)
+	args := StartArgs{VMHost: "esx"}
+	c.Check(args.Validate(), jc.Satisfies, IsArgumentError)
+}
+
+func (s *vmHostSuite) TestVMHosts(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/pods/", http.StatusOK, vmHostsResponse)
+	hosts, err := controller.VMHosts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(hosts, gc.HasLen, 2)
+}
+
+func (s *vmHostSuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, Controller, Machine) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Allocated", "")+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+	return server, controller, machines[0]
+}
+
+func (s *vmHostSuite) TestStartSendsVMHostType(c *gc.C) {
+	for _, test := range []struct {
+		hostType VMHostType
+		param    string
+	}{
+		{VMHostKVM, "install_kvm"},
+		{VMHostLXD, "register_vmhost"},
+	} {
+		server, _, machine := s.getServerAndMachine(c)
+		server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+		err := machine.Start(StartArgs{VMHost: test.hostType})
+		c.Assert(err, jc.ErrorIsNil)
+		form := server.LastRequest().PostForm
+		c.Check(form.Get(test.param), gc.Equals, "true")
+		c.Check(form, gc.HasLen, 1)
+	}
+}
+
+func (s *vmHostSuite) TestDeployVMHost(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Deployed", "")+"]")
+	// Registration finishes after deployment.
+	server.AddGetResponse("/api/2.0/pods/", http.StatusOK, "[]")
+	server.AddGetResponse("/api/2.0/pods/", http.StatusOK, vmHostsResponse)
+
+	host, err := controller.DeployVMHost(context.Background(), machine, StartArgs{VMHost: VMHostKVM})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(host.Name(), gc.Equals, "gentle-eel")
+	c.Check(host.HostSystemID(), gc.Equals, "4y3ha3")
+}
+
+func (s *vmHostSuite) TestDeployVMHostNeedsType(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	_, err := controller.DeployVMHost(context.Background(), machine, StartArgs{})
+	c.Check(err, jc.Satisfies, IsArgumentError)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *vmHostSuite) TestDeployVMHostFails(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Failed deployment", "no KVM")+"]")
+
+	_, err := controller.DeployVMHost(context.Background(), machine, StartArgs{VMHost: VMHostLXD})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err, gc.ErrorMatches, "machine 4y3ha3: Failed deployment: no KVM")
+}
+
+func (s *vmHostSuite) TestDeployVMHostWaitCancelled(c *gc.C) {
+	server, controller, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=deploy", http.StatusOK, machineJSON(c, "4y3ha3", "Deploying", ""))
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineJSON(c, "4y3ha3", "Deployed", "")+"]")
+	for i := 0; i < 100; i++ {
+		server.AddGetResponse("/api/2.0/pods/", http.StatusOK, "[]")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := controller.DeployVMHost(ctx, machine, StartArgs{VMHost: VMHostKVM})
+	c.Check(err, gc.ErrorMatches, "waiting for VM host on 4y3ha3: context deadline exceeded")
+}
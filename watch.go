@@ -0,0 +1,221 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// DefaultWatchPollInterval is the poll interval used by Watch when
+// WatchArgs.PollInterval is zero.
+const DefaultWatchPollInterval = 30 * time.Second
+
+// WatchEventType says what happened to a watched object.
+type WatchEventType string
+
+const (
+	// WatchAdded is delivered for an object that was not seen before,
+	// including every object in the first listing.
+	WatchAdded WatchEventType = "added"
+	// WatchModified is delivered for an object whose content changed, and
+	// for every object on a resync.
+	WatchModified WatchEventType = "modified"
+	// WatchDeleted is delivered for an object that is no longer listed.
+	WatchDeleted WatchEventType = "deleted"
+	// WatchError is delivered when the objects could not be listed. The
+	// watch carries on and lists them again at the next interval.
+	WatchError WatchEventType = "error"
+)
+
+// WatchEvent is passed to the handler given to Watch.
+type WatchEvent struct {
+	Type WatchEventType
+
+	// Key identifies the object within its source, such as the system ID
+	// of a machine. It is empty for WatchError.
+	Key string
+
+	// Object is the current state of the object. It is nil for
+	// WatchDeleted and WatchError.
+	Object RawEntity
+
+	// Previous is the state of the object before a WatchModified or
+	// WatchDeleted event.
+	Previous RawEntity
+
+	// Resync is true for the WatchModified events of a periodic resync,
+	// where Object and Previous are the same.
+	Resync bool
+
+	// Err is the listing error for WatchError.
+	Err error
+}
+
+// WatchSource lists the objects that Watch follows.
+type WatchSource interface {
+	// List returns the objects that exist, keyed by an identity that is
+	// stable for the life of each object.
+	List() (map[string]RawEntity, error)
+}
+
+// WatchSourceFunc adapts a function to a WatchSource.
+type WatchSourceFunc func() (map[string]RawEntity, error)
+
+// List implements WatchSource.
+func (f WatchSourceFunc) List() (map[string]RawEntity, error) {
+	return f()
+}
+
+// MachineSource returns a WatchSource for the machines matching the args,
+// keyed by system ID.
+func MachineSource(controller Controller, args MachinesArgs) WatchSource {
+	return WatchSourceFunc(func() (map[string]RawEntity, error) {
+		machines, err := controller.Machines(args)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		objects := make(map[string]RawEntity, len(machines))
+		for _, machine := range machines {
+			objects[machine.SystemID()] = machine
+		}
+		return objects, nil
+	})
+}
+
+// DeviceSource returns a WatchSource for the devices matching the args,
+// keyed by system ID.
+func DeviceSource(controller Controller, args DevicesArgs) WatchSource {
+	return WatchSourceFunc(func() (map[string]RawEntity, error) {
+		devices, err := controller.Devices(args)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		objects := make(map[string]RawEntity, len(devices))
+		for _, device := range devices {
+			objects[device.SystemID()] = device
+		}
+		return objects, nil
+	})
+}
+
+// ZoneSource returns a WatchSource for the zones, keyed by name.
+func ZoneSource(controller Controller) WatchSource {
+	return WatchSourceFunc(func() (map[string]RawEntity, error) {
+		zones, err := controller.Zones()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		objects := make(map[string]RawEntity, len(zones))
+		for _, zone := range zones {
+			objects[zone.Name()] = zone
+		}
+		return objects, nil
+	})
+}
+
+// FabricSource returns a WatchSource for the fabrics, keyed by ID.
+func FabricSource(controller Controller) WatchSource {
+	return WatchSourceFunc(func() (map[string]RawEntity, error) {
+		fabrics, err := controller.Fabrics()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		objects := make(map[string]RawEntity, len(fabrics))
+		for _, fabric := range fabrics {
+			objects[strconv.Itoa(fabric.ID())] = fabric
+		}
+		return objects, nil
+	})
+}
+
+// WatchArgs is an argument struct for passing parameters to Watch.
+type WatchArgs struct {
+	// PollInterval is how often the source is listed. If zero,
+	// DefaultWatchPollInterval is used.
+	PollInterval time.Duration
+
+	// ResyncInterval, if not zero, is how often every known object is
+	// delivered again as a WatchModified event with Resync set, so that a
+	// handler can correct any drift in the state it keeps.
+	ResyncInterval time.Duration
+
+	// Clock is used for the intervals. If nil, WallClock is used.
+	Clock Clock
+}
+
+// Watch lists the source every poll interval and calls the handler with
+// the differences from the previous listing, until the context is done,
+// when it returns the context's error. Objects are compared by their raw
+// JSON. The handler is called from the calling goroutine, one event at a
+// time, with the events of each listing in key order.
+func Watch(ctx context.Context, source WatchSource, args WatchArgs, handler func(WatchEvent)) error {
+	clock := clockOrWall(args.Clock)
+	interval := args.PollInterval
+	if interval <= 0 {
+		interval = DefaultWatchPollInterval
+	}
+	known := make(map[string]RawEntity)
+	lastResync := clock.Now()
+	for {
+		objects, err := source.List()
+		if err != nil {
+			handler(WatchEvent{Type: WatchError, Err: err})
+		} else {
+			resync := args.ResyncInterval > 0 && clock.Now().Sub(lastResync) >= args.ResyncInterval
+			if resync {
+				lastResync = clock.Now()
+			}
+			for _, event := range diffWatched(known, objects, resync) {
+				handler(event)
+			}
+			known = objects
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(interval):
+		}
+	}
+}
+
+// diffWatched returns the events that turn the known objects into the
+// current ones, ordered by key. If resync is true, unchanged objects are
+// reported too.
+func diffWatched(known, current map[string]RawEntity, resync bool) []WatchEvent {
+	keys := make([]string, 0, len(known)+len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range known {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var events []WatchEvent
+	for _, key := range keys {
+		object, exists := current[key]
+		previous, existed := known[key]
+		switch {
+		case !existed:
+			events = append(events, WatchEvent{Type: WatchAdded, Key: key, Object: object})
+		case !exists:
+			events = append(events, WatchEvent{Type: WatchDeleted, Key: key, Previous: previous})
+		case !sameRaw(previous, object):
+			events = append(events, WatchEvent{Type: WatchModified, Key: key, Object: object, Previous: previous})
+		case resync:
+			events = append(events, WatchEvent{Type: WatchModified, Key: key, Object: object, Previous: object, Resync: true})
+		}
+	}
+	return events
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type watchSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&watchSuite{})
+
+// watchZone returns a zone whose raw JSON holds the description.
+func watchZone(c *gc.C, name, description string) RawEntity {
+	zones, err := readZones(twoDotOh, parseJSON(c, `[{"name": "`+name+`", "description": "`+description+`", "resource_uri": "/MAAS/api/2.0/zones/`+name+`/"}]`))
+	c.Assert(err, jc.ErrorIsNil)
+	return zones[0]
+}
+
+// scriptedSource returns the listings in turn, and cancels the watch after
+// the last one.
+func scriptedSource(cancel func(), listings ...func() (map[string]RawEntity, error)) WatchSource {
+	return WatchSourceFunc(func() (map[string]RawEntity, error) {
+		listing := listings[0]
+		listings = listings[1:]
+		if len(listings) == 0 {
+			cancel()
+		}
+		return listing()
+	})
+}
+
+func listing(objects map[string]RawEntity) func() (map[string]RawEntity, error) {
+	return func() (map[string]RawEntity, error) { return objects, nil }
+}
+
+type watchedEvent struct {
+	Type   WatchEventType
+	Key    string
+	Resync bool
+}
+
+func runWatch(c *gc.C, args WatchArgs, listings ...func() (map[string]RawEntity, error)) []watchedEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	var events []watchedEvent
+	err := Watch(ctx, scriptedSource(cancel, listings...), args, func(event WatchEvent) {
+		events = append(events, watchedEvent{event.Type, event.Key, event.Resync})
+	})
+	c.Assert(errors.Cause(err), gc.Equals, context.Canceled)
+	return events
+}
+
+func (*watchSuite) TestWatchDiffs(c *gc.C) {
+	a1 := watchZone(c, "a", "one")
+	a2 := watchZone(c, "a", "two")
+	b := watchZone(c, "b", "")
+	clock := newTestClock()
+	events := runWatch(c, WatchArgs{Clock: clock},
+		listing(map[string]RawEntity{"b": b, "a": a1}),
+		listing(map[string]RawEntity{"b": b, "a": a1}),
+		listing(map[string]RawEntity{"a": a2}),
+	)
+	c.Check(events, jc.DeepEquals, []watchedEvent{
+		{WatchAdded, "a", false},
+		{WatchAdded, "b", false},
+		{WatchModified, "a", false},
+		{WatchDeleted, "b", false},
+	})
+	c.Check(clock.Waits(), jc.DeepEquals, []time.Duration{DefaultWatchPollInterval, DefaultWatchPollInterval})
+}
+
+func (*watchSuite) TestWatchResync(c *gc.C) {
+	a := watchZone(c, "a", "")
+	b := watchZone(c, "b", "")
+	objects := map[string]RawEntity{"a": a, "b": b}
+	events := runWatch(c, WatchArgs{
+		Clock:          newTestClock(),
+		PollInterval:   time.Minute,
+		ResyncInterval: 2 * time.Minute,
+	}, listing(objects), listing(objects), listing(objects), listing(objects))
+	c.Check(events, jc.DeepEquals, []watchedEvent{
+		{WatchAdded, "a", false},
+		{WatchAdded, "b", false},
+		{WatchModified, "a", true},
+		{WatchModified, "b", true},
+	})
+}
+
+func (*watchSuite) TestWatchListError(c *gc.C) {
+	a := watchZone(c, "a", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	failed := func() (map[string]RawEntity, error) { return nil, errors.New("boom") }
+	var events []WatchEvent
+	err := Watch(ctx, scriptedSource(cancel,
+		listing(map[string]RawEntity{"a": a}), failed, listing(map[string]RawEntity{"a": a}),
+	), WatchArgs{Clock: newTestClock()}, func(event WatchEvent) {
+		events = append(events, event)
+	})
+	c.Assert(err, gc.Equals, context.Canceled)
+	// The failed listing does not make the object look deleted.
+	c.Assert(events, gc.HasLen, 2)
+	c.Check(events[0].Type, gc.Equals, WatchAdded)
+	c.Check(events[1].Type, gc.Equals, WatchError)
+	c.Check(events[1].Err, gc.ErrorMatches, "boom")
+}
+
+func (*watchSuite) TestDiffWatchedValues(c *gc.C) {
+	a1 := watchZone(c, "a", "one")
+	a2 := watchZone(c, "a", "two")
+	events := diffWatched(map[string]RawEntity{"a": a1}, map[string]RawEntity{"a": a2}, false)
+	c.Assert(events, gc.HasLen, 1)
+	c.Check(events[0].Object, gc.Equals, a2)
+	c.Check(events[0].Previous, gc.Equals, a1)
+
+	events = diffWatched(map[string]RawEntity{"a": a1}, nil, false)
+	c.Assert(events, gc.HasLen, 1)
+	c.Check(events[0].Object, gc.IsNil)
+	c.Check(events[0].Previous, gc.Equals, a1)
+}
+
+func (s *watchSuite) TestMachineSource(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	objects, err := MachineSource(controller, MachinesArgs{}).List()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(objects, gc.HasLen, 3)
+	c.Check(objects["4y3ha3"].(Machine).Hostname(), gc.Equals, "untasted-markita")
+}
+
+func (s *watchSuite) TestZoneSource(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	objects, err := ZoneSource(controller).List()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(objects, gc.HasLen, 2)
+	c.Check(objects["default"].(Zone).Name(), gc.Equals, "default")
+}
+
+func (s *watchSuite) TestSourceError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusInternalServerError, "boom")
+	_, err := FabricSource(controller).List()
+	c.Assert(err, gc.NotNil)
+}
@@ -10,6 +10,8 @@ import (
 )
 
 type zone struct {
+	rawJSON
+
 	// Add the controller in when we need to do things with the zone.
 	// controller Controller
 
@@ -75,9 +77,9 @@ var zoneDeserializationFuncs = map[version.Number]zoneDeserializationFunc{
 
 func zone_2_0(source map[string]interface{}) (*zone, error) {
 	fields := schema.Fields{
-		"name":         schema.String(),
-		"description":  schema.String(),
-		"resource_uri": schema.String(),
+		"name":         stringField(),
+		"description":  stringField(),
+		"resource_uri": stringField(),
 	}
 	checker := schema.FieldMap(fields, nil) // no defaults
 	coerced, err := checker.Coerce(source, nil)
@@ -89,6 +91,7 @@ func zone_2_0(source map[string]interface{}) (*zone, error) {
 	// contains fields of the right type.
 
 	result := &zone{
+		rawJSON:     rawJSON{source},
 		name:        valid["name"].(string),
 		description: valid["description"].(string),
 		resourceURI: valid["resource_uri"].(string),